@@ -11,8 +11,10 @@ import (
 	_ "k8s.io/client-go/plugin/pkg/client/auth" // Load all auth plugins
 
 	"github.com/jetstack/version-checker/pkg/client"
+	"github.com/jetstack/version-checker/pkg/client/util"
 	"github.com/jetstack/version-checker/pkg/controller"
 	"github.com/jetstack/version-checker/pkg/metrics"
+	"github.com/jetstack/version-checker/pkg/metrics/prometheus"
 )
 
 const (
@@ -28,6 +30,18 @@ func NewCommand(ctx context.Context) *cobra.Command {
 		Long:  helpOutput,
 		RunE: func(cmd *cobra.Command, args []string) error {
 			opts.complete()
+			if err := opts.loadCABundles(); err != nil {
+				return err
+			}
+			if err := opts.loadPullSecrets(); err != nil {
+				return err
+			}
+			if err := opts.loadHostTimeouts(); err != nil {
+				return err
+			}
+			if err := opts.loadSignatureVerifier(); err != nil {
+				return err
+			}
 
 			logLevel, err := logrus.ParseLevel(opts.LogLevel)
 			if err != nil {
@@ -38,6 +52,7 @@ func NewCommand(ctx context.Context) *cobra.Command {
 			nlog := logrus.New()
 			nlog.SetOutput(os.Stdout)
 			nlog.SetLevel(logLevel)
+			nlog.AddHook(util.NewRedactHook(opts.Client.Secrets()...))
 			log := logrus.NewEntry(nlog)
 
 			restConfig, err := opts.kubeConfigFlags.ToRESTConfig()
@@ -50,11 +65,13 @@ func NewCommand(ctx context.Context) *cobra.Command {
 				return fmt.Errorf("failed to build kubernetes client: %s", err)
 			}
 
-			metrics := metrics.New(log)
+			metrics := metrics.New(log, prometheus.New())
 			if err := metrics.Run(opts.MetricsServingAddress); err != nil {
 				return fmt.Errorf("failed to start metrics server: %s", err)
 			}
 
+			opts.Client.ErrorResponseFunc = metrics.IncRegistryErrorResponse
+
 			client, err := client.New(ctx, log, opts.Client)
 			if err != nil {
 				return fmt.Errorf("failed to setup image registry clients: %s", err)
@@ -73,8 +90,11 @@ func NewCommand(ctx context.Context) *cobra.Command {
 
 			log.Infof("flag --test-all-containers=%t %s", opts.DefaultTestAll, defaultTestAllInfoMsg)
 
+			opts.Version.ImageCacheAgeFunc = metrics.ObserveImageCacheAge
+			opts.Version.ImageCacheStampedeFunc = metrics.IncImageCacheStampede
+
 			c := controller.New(opts.CacheTimeout, metrics,
-				client, kubeClient, log, opts.DefaultTestAll)
+				client, kubeClient, log, opts.DefaultTestAll, opts.Version)
 
 			return c.Run(ctx, opts.CacheTimeout/2)
 		},
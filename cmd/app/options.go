@@ -2,6 +2,7 @@ package app
 
 import (
 	"fmt"
+	"io/ioutil"
 	"os"
 	"regexp"
 	"strings"
@@ -14,6 +15,7 @@ import (
 
 	"github.com/jetstack/version-checker/pkg/client"
 	"github.com/jetstack/version-checker/pkg/client/selfhosted"
+	"github.com/jetstack/version-checker/pkg/version"
 )
 
 const (
@@ -33,20 +35,33 @@ const (
 
 	envGCRAccessToken = "GCR_TOKEN"
 
+	envICRAPIKey  = "ICR_API_KEY"
+	envICRAccount = "ICR_ACCOUNT"
+
 	envQuayToken = "QUAY_TOKEN"
 
-	envSelfhostedPrefix   = "SELFHOSTED"
-	envSelfhostedUsername = "USERNAME"
-	envSelfhostedPassword = "PASSWORD"
-	envSelfhostedBearer   = "TOKEN"
-	envSelfhostedHost     = "HOST"
+	envSelfhostedPrefix    = "SELFHOSTED"
+	envSelfhostedUsername  = "USERNAME"
+	envSelfhostedPassword  = "PASSWORD"
+	envSelfhostedBearer    = "TOKEN"
+	envSelfhostedHost      = "HOST"
+	envSelfhostedAuthURL   = "AUTH_URL"
+	envSelfhostedAuthScope = "AUTH_SCOPE"
+
+	envSelfhostedProxyUsername = "PROXY_USERNAME"
+	envSelfhostedProxyPassword = "PROXY_PASSWORD"
 )
 
 var (
-	selfhostedHostReg     = regexp.MustCompile("^VERSION_CHECKER_SELFHOSTED_HOST_(.*)")
-	selfhostedUsernameReg = regexp.MustCompile("^VERSION_CHECKER_SELFHOSTED_USERNAME_(.*)")
-	selfhostedPasswordReg = regexp.MustCompile("^VERSION_CHECKER_SELFHOSTED_PASSWORD_(.*)")
-	selfhostedTokenReg    = regexp.MustCompile("^VERSION_CHECKER_SELFHOSTED_TOKEN_(.*)")
+	selfhostedHostReg      = regexp.MustCompile("^VERSION_CHECKER_SELFHOSTED_HOST_(.*)")
+	selfhostedUsernameReg  = regexp.MustCompile("^VERSION_CHECKER_SELFHOSTED_USERNAME_(.*)")
+	selfhostedPasswordReg  = regexp.MustCompile("^VERSION_CHECKER_SELFHOSTED_PASSWORD_(.*)")
+	selfhostedTokenReg     = regexp.MustCompile("^VERSION_CHECKER_SELFHOSTED_TOKEN_(.*)")
+	selfhostedAuthURLReg   = regexp.MustCompile("^VERSION_CHECKER_SELFHOSTED_AUTH_URL_(.*)")
+	selfhostedAuthScopeReg = regexp.MustCompile("^VERSION_CHECKER_SELFHOSTED_AUTH_SCOPE_(.*)")
+
+	selfhostedProxyUsernameReg = regexp.MustCompile("^VERSION_CHECKER_SELFHOSTED_PROXY_USERNAME_(.*)")
+	selfhostedProxyPasswordReg = regexp.MustCompile("^VERSION_CHECKER_SELFHOSTED_PROXY_PASSWORD_(.*)")
 )
 
 // Options is a struct to hold options for the version-checker
@@ -58,8 +73,13 @@ type Options struct {
 
 	kubeConfigFlags *genericclioptions.ConfigFlags
 	selfhosted      selfhosted.Options
+	caFiles         map[string]string
+	hostTimeouts    map[string]string
+	pullSecretFiles []string
+	cosign          version.CosignOptions
 
-	Client client.Options
+	Client  client.Options
+	Version version.Options
 }
 
 func (o *Options) addFlags(cmd *cobra.Command) {
@@ -106,9 +126,98 @@ func (o *Options) addAppFlags(fs *pflag.FlagSet) {
 	fs.StringVarP(&o.LogLevel,
 		"log-level", "v", "info",
 		"Log level (debug, info, warn, error, fatal, panic).")
+
+	fs.BoolVar(&o.Version.JSONLogs,
+		"version-json-logs", false,
+		"If enabled, logs of image version lookups are formatted as JSON, with "+
+			"latest tag decisions logged at debug level with the image, registry "+
+			"and decision fields.")
+
+	fs.DurationVar(&o.Version.StatsInterval,
+		"cache-stats-interval", 0,
+		"If set, a snapshot of the image cache hit ratio and registry call rate "+
+			"is logged at this interval. Disabled if zero.")
+
+	fs.IntVar(&o.Client.PageSize,
+		"registry-page-size", 0,
+		"The number of tags requested per page from registries which support "+
+			"pagination, capped at each registry's maximum. Registry defaults are "+
+			"used if zero.")
+
+	fs.IntVar(&o.Client.MaxRetries,
+		"registry-max-retries", 0,
+		"The number of times a registry request is retried on transient failures, "+
+			"such as network errors, server errors and rate limiting. Disabled if zero.")
+
+	fs.DurationVar(&o.Client.RetryBackoff,
+		"registry-retry-backoff", 0,
+		"The backoff before retrying a registry request, doubling after each retry. "+
+			"Defaults to 200ms if zero.")
+
+	fs.Int64Var(&o.Client.MaxResponseBytes,
+		"registry-max-response-bytes", 0,
+		"The maximum size in bytes of a registry response body, where larger "+
+			"responses fail. Unlimited if zero.")
+
+	fs.StringToStringVar(&o.caFiles,
+		"registry-ca-file", nil,
+		"PEM encoded CA bundle file to trust for a registry host, in the form "+
+			"host=path. The host may include a port. May be given multiple times. "+
+			"Hosts without a bundle trust the system pool.")
+
+	fs.StringToStringVar(&o.hostTimeouts,
+		"registry-host-timeout", nil,
+		"Timeout of requests to a registry host, and of listing its tags, in the "+
+			"form host=duration, e.g. registry.internal=2m. May be given multiple "+
+			"times. Docker Hub is docker.io. Hosts without a timeout use the "+
+			"default of each registry client.")
+
+	fs.StringToStringVar(&o.Client.Mirrors,
+		"registry-mirror", nil,
+		"Pull-through mirror to fetch a registry host's images from, in the form "+
+			"host=mirror[/path], where the path is prefixed to the repository. "+
+			"May be given multiple times. Docker Hub images are mirrored by docker.io.")
+
+	fs.DurationVar(&o.Client.UnsupportedHostTTL,
+		"registry-unsupported-host-ttl", 0,
+		"How long lookups of a registry host, not matched by any registry client, "+
+			"fail fast once the host is found not to serve the registry API, such as "+
+			"a web server or a host which does not exist. Disabled by default.")
+
+	fs.StringToStringVar(&o.Client.HostBasePaths,
+		"registry-host-base-path", nil,
+		"Path a self-hosted registry's API is mounted under, such as by a proxy, "+
+			"in the form host=path, e.g. registry.internal=/registry. May be given "+
+			"multiple times.")
+
+	fs.StringVar(&o.cosign.Key,
+		"cosign-key", "",
+		"Path or URL of the public key that images must be signed by, for "+
+			"containers requiring signatures. Requires the cosign binary.")
+
+	fs.StringVar(&o.cosign.CertificateIdentity,
+		"cosign-certificate-identity", "",
+		"Certificate identity that keyless image signatures must be signed by, "+
+			"for containers requiring signatures. Requires --cosign-certificate-oidc-issuer.")
+
+	fs.StringVar(&o.cosign.CertificateOIDCIssuer,
+		"cosign-certificate-oidc-issuer", "",
+		"Certificate OIDC issuer that keyless image signatures must be signed by, "+
+			"for containers requiring signatures.")
+
+	fs.StringVar(&o.cosign.Path,
+		"cosign-path", "cosign",
+		"Path of the cosign binary used to verify image signatures.")
 }
 
 func (o *Options) addAuthFlags(fs *pflag.FlagSet) {
+	/// Pull secrets
+	fs.StringSliceVar(&o.pullSecretFiles,
+		"registry-pull-secret-file", nil,
+		"Decoded .dockerconfigjson file of a kubernetes.io/dockerconfigjson image "+
+			"pull secret, whose credentials are used for each registry host it "+
+			"contains. May be given multiple times, where later files take precedence.")
+
 	/// ACR
 	fs.StringVar(&o.Client.ACR.Username,
 		"acr-username", "",
@@ -183,6 +292,21 @@ func (o *Options) addAuthFlags(fs *pflag.FlagSet) {
 		))
 	///
 
+	/// ICR
+	fs.StringVar(&o.Client.ICR.APIKey,
+		"icr-api-key", "",
+		fmt.Sprintf(
+			"IBM Cloud API key for read access to private ICR registries (%s_%s).",
+			envPrefix, envICRAPIKey,
+		))
+	fs.StringVar(&o.Client.ICR.Account,
+		"icr-account", "",
+		fmt.Sprintf(
+			"IBM Cloud account ID which owns the ICR registry namespaces (%s_%s).",
+			envPrefix, envICRAccount,
+		))
+	///
+
 	/// Quay
 	fs.StringVar(&o.Client.Quay.Token,
 		"quay-token", "",
@@ -218,6 +342,36 @@ func (o *Options) addAuthFlags(fs *pflag.FlagSet) {
 			"Full host of the selfhosted registry. Include http[s] scheme (%s_%s",
 			envPrefix, envSelfhostedHost,
 		))
+	fs.StringVar(&o.selfhosted.TokenURL,
+		"selfhosted-auth-url", "",
+		fmt.Sprintf(
+			"URL of the token service of a selfhosted registry, for registries "+
+				"where it cannot be discovered. Tokens are requested per repository, "+
+				"using the username and password if set (%s_%s).",
+			envPrefix, envSelfhostedAuthURL,
+		))
+	fs.StringVar(&o.selfhosted.TokenScope,
+		"selfhosted-auth-scope", "",
+		fmt.Sprintf(
+			"Scope requested from the selfhosted registry token service, where "+
+				"{repository} is replaced with the image repository. Defaults to "+
+				"repository:{repository}:pull (%s_%s).",
+			envPrefix, envSelfhostedAuthScope,
+		))
+	fs.StringVar(&o.selfhosted.ProxyUsername,
+		"selfhosted-proxy-username", "",
+		fmt.Sprintf(
+			"Username to authenticate with a proxy in front of a selfhosted registry, "+
+				"sent as the Proxy-Authorization header of requests to the registry "+
+				"host, in addition to the registry's own auth (%s_%s).",
+			envPrefix, envSelfhostedProxyUsername,
+		))
+	fs.StringVar(&o.selfhosted.ProxyPassword,
+		"selfhosted-proxy-password", "",
+		fmt.Sprintf(
+			"Password to authenticate with a proxy in front of a selfhosted registry (%s_%s).",
+			envPrefix, envSelfhostedProxyPassword,
+		))
 	///
 }
 
@@ -243,6 +397,9 @@ func (o *Options) complete() {
 
 		{envGCRAccessToken, &o.Client.GCR.Token},
 
+		{envICRAPIKey, &o.Client.ICR.APIKey},
+		{envICRAccount, &o.Client.ICR.Account},
+
 		{envQuayToken, &o.Client.Quay.Token},
 	} {
 		for _, env := range envs {
@@ -255,6 +412,82 @@ func (o *Options) complete() {
 	o.assignSelfhosted(envs)
 }
 
+// loadCABundles will read the registry CA bundle files into the client
+// options.
+func (o *Options) loadCABundles() error {
+	if len(o.caFiles) == 0 {
+		return nil
+	}
+
+	o.Client.CABundles = make(map[string][]byte, len(o.caFiles))
+	for host, path := range o.caFiles {
+		bundle, err := ioutil.ReadFile(path)
+		if err != nil {
+			return fmt.Errorf("failed to read CA bundle for registry host %q: %s", host, err)
+		}
+
+		o.Client.CABundles[host] = bundle
+	}
+
+	return nil
+}
+
+// loadHostTimeouts will parse the registry host timeouts into the client
+// options.
+func (o *Options) loadHostTimeouts() error {
+	if len(o.hostTimeouts) == 0 {
+		return nil
+	}
+
+	o.Client.HostTimeouts = make(map[string]time.Duration, len(o.hostTimeouts))
+	for host, s := range o.hostTimeouts {
+		timeout, err := time.ParseDuration(s)
+		if err != nil {
+			return fmt.Errorf("failed to parse timeout of registry host %q: %s", host, err)
+		}
+		if timeout <= 0 {
+			return fmt.Errorf("timeout of registry host %q must be positive, got %s", host, timeout)
+		}
+
+		o.Client.HostTimeouts[host] = timeout
+	}
+
+	return nil
+}
+
+// loadPullSecrets will read the registry credentials of the image pull secret
+// files into the client options.
+func (o *Options) loadPullSecrets() error {
+	for _, path := range o.pullSecretFiles {
+		data, err := ioutil.ReadFile(path)
+		if err != nil {
+			return fmt.Errorf("failed to read registry pull secret: %s", err)
+		}
+
+		if err := o.Client.AddDockerConfigJSON(data); err != nil {
+			return fmt.Errorf("failed to load registry pull secret %q: %s", path, err)
+		}
+	}
+
+	return nil
+}
+
+// loadSignatureVerifier will configure the cosign signature verifier of the
+// version options, if a trusted identity is set.
+func (o *Options) loadSignatureVerifier() error {
+	if len(o.cosign.Key) == 0 && len(o.cosign.CertificateIdentity) == 0 && len(o.cosign.CertificateOIDCIssuer) == 0 {
+		return nil
+	}
+
+	verifier, err := version.NewCosignVerifier(o.cosign)
+	if err != nil {
+		return fmt.Errorf("failed to configure cosign: %s", err)
+	}
+
+	o.Version.SignatureVerifier = verifier
+	return nil
+}
+
 func (o *Options) assignEnv(env, key string, assign *string) bool {
 	pair := strings.SplitN(env, "=", 2)
 	if len(pair) < 2 {
@@ -309,6 +542,30 @@ func (o *Options) assignSelfhosted(envs []string) {
 			o.Client.Selfhosted[matches[1]].Bearer = pair[1]
 			continue
 		}
+
+		if matches := selfhostedAuthURLReg.FindStringSubmatch(strings.ToUpper(pair[0])); len(matches) == 2 {
+			initOptions(matches[1])
+			o.Client.Selfhosted[matches[1]].TokenURL = pair[1]
+			continue
+		}
+
+		if matches := selfhostedAuthScopeReg.FindStringSubmatch(strings.ToUpper(pair[0])); len(matches) == 2 {
+			initOptions(matches[1])
+			o.Client.Selfhosted[matches[1]].TokenScope = pair[1]
+			continue
+		}
+
+		if matches := selfhostedProxyUsernameReg.FindStringSubmatch(strings.ToUpper(pair[0])); len(matches) == 2 {
+			initOptions(matches[1])
+			o.Client.Selfhosted[matches[1]].ProxyUsername = pair[1]
+			continue
+		}
+
+		if matches := selfhostedProxyPasswordReg.FindStringSubmatch(strings.ToUpper(pair[0])); len(matches) == 2 {
+			initOptions(matches[1])
+			o.Client.Selfhosted[matches[1]].ProxyPassword = pair[1]
+			continue
+		}
 	}
 
 	if len(o.selfhosted.Host) > 0 {
@@ -1,15 +1,20 @@
 package app
 
 import (
+	"io/ioutil"
 	"os"
+	"path/filepath"
 	"reflect"
 	"testing"
+	"time"
 
+	"github.com/jetstack/version-checker/pkg/api"
 	"github.com/jetstack/version-checker/pkg/client"
 	"github.com/jetstack/version-checker/pkg/client/acr"
 	"github.com/jetstack/version-checker/pkg/client/docker"
 	"github.com/jetstack/version-checker/pkg/client/ecr"
 	"github.com/jetstack/version-checker/pkg/client/gcr"
+	"github.com/jetstack/version-checker/pkg/client/icr"
 	"github.com/jetstack/version-checker/pkg/client/quay"
 	"github.com/jetstack/version-checker/pkg/client/selfhosted"
 )
@@ -37,6 +42,8 @@ func TestComplete(t *testing.T) {
 				{"VERSION_CHECKER_ECR_SECRET_ACCESS_KEY", "ecr-secret-access-token"},
 				{"VERSION_CHECKER_ECR_SESSION_TOKEN", "ecr-session-token"},
 				{"VERSION_CHECKER_GCR_TOKEN", "gcr-token"},
+				{"VERSION_CHECKER_ICR_API_KEY", "icr-api-key"},
+				{"VERSION_CHECKER_ICR_ACCOUNT", "icr-account"},
 				{"VERSION_CHECKER_QUAY_TOKEN", "quay-token"},
 				{"VERSION_CHECKER_SELFHOSTED_HOST_FOO", "docker.joshvanl.com"},
 				{"VERSION_CHECKER_SELFHOSTED_USERNAME_FOO", "joshvanl"},
@@ -62,6 +69,10 @@ func TestComplete(t *testing.T) {
 				GCR: gcr.Options{
 					Token: "gcr-token",
 				},
+				ICR: icr.Options{
+					APIKey:  "icr-api-key",
+					Account: "icr-account",
+				},
 				Quay: quay.Options{
 					Token: "quay-token",
 				},
@@ -81,6 +92,10 @@ func TestComplete(t *testing.T) {
 				{"VERSION_CHECKER_SELFHOSTED_USERNAME_BAR", "bar.joshvanl"},
 				{"VERSION_CHECKER_SELFHOSTED_PASSWORD_BAR", "bar-password"},
 				{"VERSION_CHECKER_SELFHOSTED_TOKEN_BAR", "my-bar-token"},
+				{"VERSION_CHECKER_SELFHOSTED_AUTH_URL_BAR", "https://auth.joshvanl.com/token"},
+				{"VERSION_CHECKER_SELFHOSTED_AUTH_SCOPE_BAR", "repository:{repository}:pull,push"},
+				{"VERSION_CHECKER_SELFHOSTED_PROXY_USERNAME_BAR", "bar-proxy"},
+				{"VERSION_CHECKER_SELFHOSTED_PROXY_PASSWORD_BAR", "bar-proxy-password"},
 				{"VERSION_CHECKER_ACR_USERNAME", "acr-username"},
 				{"VERSION_CHECKER_ACR_PASSWORD", "acr-password"},
 				{"VERSION_CHECKER_ACR_REFRESH_TOKEN", "acr-token"},
@@ -91,6 +106,8 @@ func TestComplete(t *testing.T) {
 				{"VERSION_CHECKER_ECR_SECRET_ACCESS_KEY", "ecr-secret-access-token"},
 				{"VERSION_CHECKER_ECR_SESSION_TOKEN", "ecr-session-token"},
 				{"VERSION_CHECKER_GCR_TOKEN", "gcr-token"},
+				{"VERSION_CHECKER_ICR_API_KEY", "icr-api-key"},
+				{"VERSION_CHECKER_ICR_ACCOUNT", "icr-account"},
 				{"VERSION_CHECKER_QUAY_TOKEN", "quay-token"},
 				{"VERSION_CHECKER_SELFHOSTED_HOST_FOO", "docker.joshvanl.com"},
 				{"VERSION_CHECKER_SELFHOSTED_USERNAME_FOO", "joshvanl"},
@@ -116,6 +133,10 @@ func TestComplete(t *testing.T) {
 				GCR: gcr.Options{
 					Token: "gcr-token",
 				},
+				ICR: icr.Options{
+					APIKey:  "icr-api-key",
+					Account: "icr-account",
+				},
 				Quay: quay.Options{
 					Token: "quay-token",
 				},
@@ -127,10 +148,14 @@ func TestComplete(t *testing.T) {
 						Bearer:   "my-token",
 					},
 					"BAR": &selfhosted.Options{
-						Host:     "bar.docker.joshvanl.com",
-						Username: "bar.joshvanl",
-						Password: "bar-password",
-						Bearer:   "my-bar-token",
+						Host:          "bar.docker.joshvanl.com",
+						Username:      "bar.joshvanl",
+						Password:      "bar-password",
+						Bearer:        "my-bar-token",
+						TokenURL:      "https://auth.joshvanl.com/token",
+						TokenScope:    "repository:{repository}:pull,push",
+						ProxyUsername: "bar-proxy",
+						ProxyPassword: "bar-proxy-password",
 					},
 				},
 			},
@@ -255,3 +280,92 @@ func TestAssignSelfhosted(t *testing.T) {
 		})
 	}
 }
+
+func TestLoadCABundles(t *testing.T) {
+	dir, err := ioutil.TempDir("", "version-checker")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	path := filepath.Join(dir, "ca.pem")
+	if err := ioutil.WriteFile(path, []byte("my-ca-bundle"), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	o := &Options{caFiles: map[string]string{"registry.example.com": path}}
+	if err := o.loadCABundles(); err != nil {
+		t.Fatal(err)
+	}
+
+	expBundles := map[string][]byte{"registry.example.com": []byte("my-ca-bundle")}
+	if !reflect.DeepEqual(o.Client.CABundles, expBundles) {
+		t.Errorf("unexpected CA bundles, exp=%q got=%q", expBundles, o.Client.CABundles)
+	}
+
+	o = &Options{caFiles: map[string]string{"registry.example.com": filepath.Join(dir, "missing.pem")}}
+	if err := o.loadCABundles(); err == nil {
+		t.Error("expected error for missing CA bundle file, got none")
+	}
+}
+
+func TestLoadHostTimeouts(t *testing.T) {
+	o := &Options{hostTimeouts: map[string]string{
+		"docker.io":         "10s",
+		"registry.internal": "2m",
+	}}
+	if err := o.loadHostTimeouts(); err != nil {
+		t.Fatal(err)
+	}
+
+	expTimeouts := map[string]time.Duration{
+		"docker.io":         time.Second * 10,
+		"registry.internal": time.Minute * 2,
+	}
+	if !reflect.DeepEqual(o.Client.HostTimeouts, expTimeouts) {
+		t.Errorf("unexpected host timeouts, exp=%v got=%v", expTimeouts, o.Client.HostTimeouts)
+	}
+
+	for _, timeout := range []string{"soon", "0s", "-1m"} {
+		o = &Options{hostTimeouts: map[string]string{"registry.internal": timeout}}
+		if err := o.loadHostTimeouts(); err == nil {
+			t.Errorf("expected error for timeout %q, got none", timeout)
+		}
+	}
+}
+
+func TestLoadPullSecrets(t *testing.T) {
+	dir, err := ioutil.TempDir("", "version-checker")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	first, second := filepath.Join(dir, "first.json"), filepath.Join(dir, "second.json")
+	for path, data := range map[string]string{
+		first:  `{"auths": {"ghcr.io": {"registrytoken": "old-token"}, "quay.io": {"registrytoken": "quay-token"}}}`,
+		second: `{"auths": {"ghcr.io": {"registrytoken": "new-token"}}}`,
+	} {
+		if err := ioutil.WriteFile(path, []byte(data), 0600); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	o := &Options{pullSecretFiles: []string{first, second}}
+	if err := o.loadPullSecrets(); err != nil {
+		t.Fatal(err)
+	}
+
+	expCreds := map[string]*api.Credentials{
+		"ghcr.io": {Token: "new-token"},
+		"quay.io": {Token: "quay-token"},
+	}
+	if !reflect.DeepEqual(o.Client.HostCredentials, expCreds) {
+		t.Errorf("unexpected host credentials, exp=%+v got=%+v", expCreds, o.Client.HostCredentials)
+	}
+
+	o = &Options{pullSecretFiles: []string{filepath.Join(dir, "missing.json")}}
+	if err := o.loadPullSecrets(); err == nil {
+		t.Error("expected error for missing pull secret file, got none")
+	}
+}
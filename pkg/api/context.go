@@ -0,0 +1,48 @@
+package api
+
+import "context"
+
+// Credentials are registry credentials which can be carried by a context, to
+// be used for the requests of a single lookup. Credentials on the context take
+// precedence over those the registry client was configured with. These are
+// not used by the ACR and ECR clients.
+type Credentials struct {
+	Username string
+	Password string
+	Token    string
+}
+
+// credentialsKey is the context key for request credentials.
+type credentialsKey struct{}
+
+// ContextWithCredentials returns a copy of the given context which carries the
+// given registry credentials.
+func ContextWithCredentials(ctx context.Context, creds *Credentials) context.Context {
+	return context.WithValue(ctx, credentialsKey{}, creds)
+}
+
+// CredentialsFromContext returns the registry credentials carried by the given
+// context, if any.
+func CredentialsFromContext(ctx context.Context) (*Credentials, bool) {
+	creds, ok := ctx.Value(credentialsKey{}).(*Credentials)
+	return creds, ok && creds != nil
+}
+
+// tagPrefixKey is the context key for the tag prefix of a lookup.
+type tagPrefixKey struct{}
+
+// ContextWithTagPrefix returns a copy of the given context which carries the
+// given tag prefix, which every tag of interest to the lookup begins with.
+// Registry clients which support filtering tags server side may list only
+// the tags matching the prefix, to reduce the tags transferred. Filters are
+// not exact, so tags without the prefix may still be listed.
+func ContextWithTagPrefix(ctx context.Context, prefix string) context.Context {
+	return context.WithValue(ctx, tagPrefixKey{}, prefix)
+}
+
+// TagPrefixFromContext returns the tag prefix carried by the given context,
+// if any.
+func TagPrefixFromContext(ctx context.Context) (string, bool) {
+	prefix, ok := ctx.Value(tagPrefixKey{}).(string)
+	return prefix, ok && len(prefix) > 0
+}
@@ -1,8 +1,12 @@
 package api
 
 import (
+	"reflect"
 	"regexp"
+	"strings"
 	"time"
+
+	"github.com/jetstack/version-checker/pkg/version/semver"
 )
 
 const (
@@ -14,6 +18,10 @@ const (
 	// mirroring images.
 	OverrideURLAnnotationKey = "override-url.version-checker.io"
 
+	// PromotionURLAnnotationKey will only select tags which have also been
+	// promoted to the given image URL, such as a production registry.
+	PromotionURLAnnotationKey = "promotion-url.version-checker.io"
+
 	// UseSHAAnnotationKey is used to comparing the SHA digests of images. This
 	// is silently set to true if the container image using using the SHA digest
 	// as its tag.
@@ -21,7 +29,8 @@ const (
 
 	// MatchRegexAnnotationKey will enforce that tags that are looked up must
 	// match this regex. UseMetaDataAnnotationKey is not required when this is
-	// set. All other options are ignored when this is set.
+	// set. Tags must also pass every other option which is set, see
+	// Options.MatchRegex for the order in which they are applied.
 	MatchRegexAnnotationKey = "match-regex.version-checker.io"
 
 	// UseMetaDataAnnotationKey is defined as a tag containing anything after the
@@ -35,29 +44,471 @@ const (
 	// PinMinorAnnotationKey will pin the minor version to check.
 	PinMinorAnnotationKey = "pin-minor.version-checker.io"
 
-	// PinPatchAnnotationKey will pin the patch version to check.
+	// PinPatchAnnotationKey will pin the patch version to check. Prefixing the
+	// version with ">=" will pin a minimum patch version instead.
+	// e.g. 3, >=3
 	PinPatchAnnotationKey = "pin-patch.version-checker.io"
+
+	// RequireSignatureAnnotationKey will only select tags whose image is
+	// signed by a trusted identity, using the configured cosign verifier.
+	RequireSignatureAnnotationKey = "require-signature.version-checker.io"
+
+	// RequireConfigLabelsAnnotationKey will only select tags whose image config
+	// has all of the given labels, in the form key=value,key=value.
+	RequireConfigLabelsAnnotationKey = "require-config-labels.version-checker.io"
+
+	// RequireManifestMediaTypesAnnotationKey will only select tags whose
+	// manifest is of one of the given media types, comma separated, where
+	// "oci" is short for the OCI image index and manifest media types.
+	// e.g. oci, application/vnd.docker.distribution.manifest.list.v2+json
+	RequireManifestMediaTypesAnnotationKey = "require-manifest-media-types.version-checker.io"
+
+	// UseConfigTimestampAnnotationKey will take the timestamp of tags selected
+	// by SHA from the creation time of their image, rather than the registry
+	// listing.
+	UseConfigTimestampAnnotationKey = "use-config-timestamp.version-checker.io"
+
+	// RequireTimestampAnnotationKey will fail the lookup of tags selected by
+	// SHA if no tag has a timestamp, rather than selecting a tag without one.
+	RequireTimestampAnnotationKey = "require-timestamp.version-checker.io"
+
+	// SanitizeAnnotationKey will sanitize malformed tag versions before they
+	// are parsed, using the given rule. One of "build" or "prerelease".
+	// e.g. build: 1.2.3.4 -> 1.2.3+4, prerelease: 1.2.3_1 -> 1.2.3-1
+	SanitizeAnnotationKey = "sanitize.version-checker.io"
+)
+
+// HelmChartConfigMediaType is the config media type of Helm charts stored as
+// OCI artifacts.
+const HelmChartConfigMediaType = "application/vnd.cncf.helm.config.v1+json"
+
+// The media types of OCI image indexes and manifests.
+const (
+	OCIImageIndexMediaType    = "application/vnd.oci.image.index.v1+json"
+	OCIImageManifestMediaType = "application/vnd.oci.image.manifest.v1+json"
 )
 
 // Options is used to describe what restrictions should be used for determining
-// the latest image.
+// the latest image. Options should be treated as immutable once passed to a
+// lookup. Lookups take a copy of the options before use, so options mutated
+// concurrently do not affect lookups already in flight, however the caller
+// must still synchronise mutations with the start of lookups.
 type Options struct {
 	OverrideURL *string `json:"override-url,omitempty"`
 
+	// PromotionURL restricts the latest tag to be selected from only tags
+	// which also exist in this image URL, such as a production registry which
+	// versions are promoted to from a staging registry. Tags are matched by
+	// name, and the promotion image's tags are fetched and cached the same as
+	// those of any image, with the credentials of the lookup's context.
+	// e.g. prod.example.com/jetstack/version-checker
+	PromotionURL *string `json:"promotion-url,omitempty"`
+
+	// ChannelPaths are the repository paths of the channels of the image, for
+	// registries which separate channels by repository rather than by tag,
+	// e.g. myapp/stable and myapp/beta. The latest tag is selected across the
+	// repositories of these paths under the image, or under OverrideURL if
+	// set, where a single path selects from only that channel. The returned
+	// tag's Source is the repository it was resolved from. Results and
+	// constraints are resolved per repository. Cannot be used with
+	// FloatingTag.
+	// e.g. given myapp and stable, selects the latest tag of myapp/stable
+	ChannelPaths []string `json:"channel-paths,omitempty"`
+
 	// UseSHA cannot be used with any other options
 	UseSHA bool `json:"use-sha,omitempty"`
 
+	// MatchRegex restricts the latest tag to be selected from only tags which
+	// match the regex, which may have metadata without UseMetaData. Filters
+	// are applied in order of precedence: DenyVersions, the regex,
+	// VersionConstraints, MaxVersion, VersionBand, the pins, and then
+	// PreReleaseChannel, where a tag must pass every filter which is set. Has
+	// no effect if UseSHA or FloatingTag is set.
 	MatchRegex *string `json:"match-regex,omitempty"`
 
 	// UseMetaData defines whether tags with '-alpha', '-debian.0' etc. is
 	// permissible.
 	UseMetaData bool `json:"use-metadata,omitempty"`
 
+	// UseNewerPreRelease will select the latest pre-release over the latest
+	// stable version, only if the pre-release's major, minor and patch version
+	// is greater than the stable version. Has no effect if UseMetaData is set,
+	// or when no stable version exists.
+	// e.g. given 1.2.0 and 1.3.0-rc.1, selects 1.3.0-rc.1
+	//      given 1.2.0 and 1.2.0-rc.1, selects 1.2.0
+	//      given 1.2.0, 1.3.0 and 1.3.0-rc.1, selects 1.3.0
+	UseNewerPreRelease bool `json:"use-newer-prerelease,omitempty"`
+
+	// FallbackToPreRelease will select the latest pre-release, as with
+	// UseMetaData, when no stable version passes the options, such as for new
+	// projects which have only published release candidates. Stable versions
+	// always take precedence. Has no effect if UseMetaData or
+	// PreReleaseChannel is set.
+	// e.g. given 0.1.0-rc.1 and 0.1.0-rc.2, selects 0.1.0-rc.2
+	//      given 0.1.0 and 0.2.0-rc.1, selects 0.1.0
+	FallbackToPreRelease bool `json:"fallback-to-prerelease,omitempty"`
+
+	// SkipPreReleaseMinors will ignore the pre-releases of a major and minor
+	// version which has no stable version, so that a newer minor with only
+	// pre-releases is not selected over the latest stable version by
+	// UseNewerPreRelease. Pre-releases of minors with a stable version are
+	// still permitted. Has no effect if pre-releases are not permitted.
+	// e.g. given 1.5.0, 1.6.0-rc.1, selects 1.5.0
+	//      given 1.5.0, 1.5.1-rc.1, 1.6.0-rc.1, selects 1.5.1-rc.1
+	//      given 1.5.0, 1.6.0, 1.6.1-rc.1, selects 1.6.1-rc.1
+	SkipPreReleaseMinors bool `json:"skip-prerelease-minors,omitempty"`
+
+	// SanitizeRule is the rule used to map malformed tag versions, such as
+	// four component versions, onto a semantic version before parsing. One of
+	// "build" or "prerelease". Versions sanitized to build metadata are
+	// permissible without UseMetaData.
+	// e.g. build:      1.2.3.4 -> 1.2.3+4
+	//      prerelease: 1.2.3.4 -> 1.2.3-4
+	SanitizeRule string `json:"sanitize,omitempty"`
+
+	// PreferSuffix will prefer tags whose suffix following the patch digit
+	// matches, over other tags of the same major, minor and patch version.
+	// Tags with this suffix are permissible without UseMetaData. If no tag of
+	// the latest version has the suffix, the plain version is selected.
+	// e.g. given -slim and 1.2.3, 1.2.3-slim, 1.2.4, selects 1.2.4
+	//      given -slim and 1.2.3, 1.2.3-slim, selects 1.2.3-slim
+	PreferSuffix string `json:"prefer-suffix,omitempty"`
+
+	// PreReleaseChannel pins the lookup to the pre-releases of the given
+	// channel, e.g. nightly, which are ordered by the channel's numeric
+	// identifier, such as a build date, rather than by version. Versions with
+	// the same identifier are ordered by version. Tags of other channels and
+	// stable versions are ignored.
+	// e.g. given nightly and 1.2.4-nightly.20231231, 1.2.3-nightly.20240101,
+	//      selects 1.2.3-nightly.20240101
+	PreReleaseChannel string `json:"prerelease-channel,omitempty"`
+
+	// FloatingTag pins the lookup to the given tag, e.g. stable, returning the
+	// tag's current image rather than selecting the latest version. The
+	// returned image is marked as Drifted if its digest differs from
+	// FloatingTagDigest. All other options are ignored when this is set.
+	FloatingTag *string `json:"floating-tag,omitempty"`
+
+	// FloatingTagDigest is the previously observed digest of FloatingTag, used
+	// to detect drift. Drift is never reported if empty.
+	FloatingTagDigest string `json:"floating-tag-digest,omitempty"`
+
+	// PointerTag selects the version which the given tag points to, e.g.
+	// current, being the latest version of the tags sharing the tag's digest,
+	// rather than computing the latest version. The other options filter the
+	// versions sharing the digest, where the pointer tag itself is never
+	// selected. Has no effect if FloatingTag is set.
+	// e.g. given current and current, v1.2.0 sharing a digest, and v1.3.0,
+	//      selects v1.2.0
+	PointerTag *string `json:"pointer-tag,omitempty"`
+
+	// RejectDowngrade will cause resolving the latest tag against a current
+	// tag to error if the selected tag is a lower version than the current
+	// tag, such as after a registry rollback. Current tags which are not
+	// valid versions are never considered a downgrade.
+	RejectDowngrade bool `json:"reject-downgrade,omitempty"`
+
+	// CandidateTags restricts the latest tag to be selected from only these
+	// tags. Tags which do not exist in the registry are ignored.
+	// e.g. given 1.2.3, 1.4.0 and tags 1.2.3, 1.3.0, 1.4.0, 2.0.0, selects 1.4.0
+	CandidateTags []string `json:"candidate-tags,omitempty"`
+
+	// DenyVersions are versions which are never selected, such as releases
+	// yanked for a known vulnerability, so that the next best version is
+	// selected instead. Versions are matched exactly, including any metadata,
+	// ignoring a "v" prefix. Entries which are not valid versions are ignored.
+	// e.g. given 1.2.3 and tags 1.2.2, v1.2.3, 1.2.3-rc.1, selects 1.2.2
+	DenyVersions []string `json:"deny-versions,omitempty"`
+
+	// StripBuildMetadata will remove build metadata from the version of the
+	// returned latest tag, so that it is a stable comparison key. Versions
+	// which differ only by build metadata are equal, so the selected tag may
+	// otherwise alternate between them. The full version is still used when
+	// selecting the latest tag.
+	// e.g. 1.2.3+build.1 -> 1.2.3
+	StripBuildMetadata bool `json:"strip-build-metadata,omitempty"`
+
+	// FallbackToSHA will select the latest tag by image timestamp, as with
+	// UseSHA, from the tags which are not valid versions when no tag
+	// satisfies the version constraints. Selecting by version always takes
+	// precedence, so only repositories without a matching version fall back.
+	// Has no effect if UseSHA or FloatingTag is set.
+	// e.g. given latest and main, selects the most recently pushed
+	FallbackToSHA bool `json:"fallback-to-sha,omitempty"`
+
+	// HelmChartsOnly restricts the latest tag to be selected from only tags
+	// of Helm charts stored as OCI artifacts, identified by the config media
+	// type of their manifest. Tags whose config media type is unknown are
+	// ignored. Has no effect if UseSHA or FloatingTag is set.
+	HelmChartsOnly bool `json:"helm-charts-only,omitempty"`
+
+	// RequireSignature restricts the latest tag to be selected from only tags
+	// whose image is signed by a trusted identity, as verified by the version
+	// getter's signature verifier. Tags of unsigned or untrusted images, and
+	// tags without a digest, are skipped. Has no effect if FloatingTag is set.
+	RequireSignature bool `json:"require-signature,omitempty"`
+
+	// RequireConfigLabels restricts the latest tag to be selected from only
+	// tags whose image config has all of these labels, such as those stamped
+	// by docker build --label. The config of each candidate tag is fetched,
+	// most recent first, until one has the labels, where configs are cached
+	// by image digest. For manifest lists and indexes, the config of the first
+	// platform image is used. Has no effect if FloatingTag is set.
+	// e.g. quality=ga
+	RequireConfigLabels map[string]string `json:"require-config-labels,omitempty"`
+
+	// RequireManifestMediaTypes restricts the latest tag to be selected from
+	// only tags whose manifest is of one of these media types, such as to
+	// skip versions only published as legacy Docker manifests when OCI
+	// manifests are required. The manifest of each candidate tag is fetched,
+	// most recent first, until one is of a required media type, where
+	// manifests are cached by image digest. Has no effect if FloatingTag is
+	// set.
+	// e.g. application/vnd.oci.image.index.v1+json,
+	//      application/vnd.oci.image.manifest.v1+json
+	RequireManifestMediaTypes []string `json:"require-manifest-media-types,omitempty"`
+
+	// VerifyManifest restricts the latest tag to be selected from only tags
+	// whose manifest can be fetched, skipping tags which are listed by the
+	// registry but whose image has been partially deleted. The manifest of
+	// each candidate tag is fetched, most recent first, until one is found,
+	// where manifests are cached by image digest. Has no effect if
+	// FloatingTag is set.
+	VerifyManifest bool `json:"verify-manifest,omitempty"`
+
+	// UseConfigTimestamp will replace the timestamp of the candidate tags
+	// selected by timestamp, with UseSHA or FallbackToSHA, with the creation
+	// time of their image, taken from the image manifest or config blob. This
+	// is for registries whose listing timestamps are missing or unreliable,
+	// at the cost of fetching the manifest of every candidate tag. Manifests
+	// are cached by digest. Tags whose creation time is unknown keep their
+	// listing timestamp.
+	UseConfigTimestamp bool `json:"use-config-timestamp,omitempty"`
+
+	// RequireTimestamp will return a not found error when selecting by
+	// timestamp with UseSHA and no candidate tag has a timestamp. Tags without
+	// a timestamp are only ever selected if no other tag has one, so otherwise
+	// the first such tag is selected. Has no effect unless UseSHA is set.
+	RequireTimestamp bool `json:"require-timestamp,omitempty"`
+
+	// BeforeTime restricts the latest tag to be selected from only tags whose
+	// timestamp is at or before this time, so that the latest tag as of a
+	// point in time may be resolved. Tags without a timestamp are ignored.
+	// Has no effect if FloatingTag is set.
+	BeforeTime *time.Time `json:"before-time,omitempty"`
+
+	// Architecture and OS restrict the latest tag to be selected by version
+	// from only tags which publish an image of this platform, skipping
+	// versions which have dropped it. Platforms are those listed by the
+	// registry for each tag, where the tag of the matching platform image is
+	// selected. Tags listed without any platform are never skipped, as their
+	// platforms are unknown. Has no effect if UseSHA or TagMapper is set.
+	// e.g. Architecture=arm64, OS=linux
+	Architecture string `json:"architecture,omitempty"`
+	OS           string `json:"os,omitempty"`
+
 	PinMajor *int64 `json:"pin-major,omitempty"`
 	PinMinor *int64 `json:"pin-minor,omitempty"`
 	PinPatch *int64 `json:"pin-patch,omitempty"`
 
+	// PinPatchMinimum will treat PinPatch as the minimum patch version, rather
+	// than an exact match. Pins are filters applied to every tag before the
+	// latest version is selected, so this only permits patch versions within
+	// the pinned major and minor versions.
+	// e.g. PinMajor=1, PinMinor=2, PinPatch=3 selects the latest of 1.2.>=3
+	PinPatchMinimum bool `json:"pin-patch-minimum,omitempty"`
+
 	RegexMatcher *regexp.Regexp `json:"-"`
+
+	// VersionExtractor is used to extract the version from tags which embed it
+	// in a larger string, e.g. myapp-1.2.3-linux-amd64. The regex must contain
+	// a named capture group "version", which is parsed as the tag's version.
+	// Tags which do not match are ignored.
+	VersionExtractor *regexp.Regexp `json:"-"`
+
+	// VersionConstraints restricts the latest tag to be selected from only
+	// tags whose version satisfies the constraints, comparing their major,
+	// minor and patch versions. Applied after RegexMatcher, see MatchRegex.
+	VersionConstraints *semver.Constraints `json:"-"`
+
+	// MaxVersion is the ceiling of the latest tag, where tags whose version is
+	// strictly greater are ignored. Unlike the pins, every version at or
+	// below the ceiling may be selected. Pre-releases of the ceiling are below
+	// it. Applied after VersionConstraints, see MatchRegex.
+	// e.g. MaxVersion=2.5.99 holds at 2.5.x, selecting 2.4.0 over 2.6.0
+	MaxVersion *semver.SemVer `json:"-"`
+
+	// VersionBand restricts the latest tag to be selected from only tags whose
+	// version is within the band, for banded upgrades. Composes with
+	// VersionConstraints and MaxVersion, where a tag must pass each. Applied
+	// after MaxVersion, see MatchRegex.
+	// e.g. Min=1.4.0, Max=1.6.0 selects the latest of >=1.4.0 <1.6.0
+	VersionBand *VersionBand `json:"-"`
+
+	// TagMapper maps each tag onto a comparable value which orders the tags,
+	// for versioning schemes other than semantic versions, in place of
+	// selecting by version. The tag with the greatest value is selected,
+	// where tags of equal value are ordered by timestamp. Only RegexMatcher,
+	// CandidateTags, HelmChartsOnly, BeforeTime and RequireSignature apply to
+	// mapped tags. Has no effect if UseSHA or FloatingTag is set. Results are
+	// not cached when set, as the mapper cannot be compared between lookups.
+	// e.g. mapping 20240101-build2-abc123 onto its date and build number
+	TagMapper TagMapper `json:"-"`
+
+	// Versioner parses and orders the versions of tags when selecting the
+	// latest tag by version, such as semver.Strict for strict SemVer 2.0.0
+	// compliance. Lookups are cached by the versioner's name, so versioners
+	// of the same name must have the same rules. Tags are listed in full,
+	// rather than sorted by the registry. Defaults to semver.Default if nil.
+	Versioner semver.Versioner `json:"-"`
+}
+
+// TagMapper maps the given tag onto a value ordering it against all other
+// tags mapped by the same mapper. Returns false if the tag should be skipped.
+type TagMapper func(tag string) (Comparable, bool)
+
+// Comparable is a value ordering tags, as returned by a TagMapper.
+type Comparable interface {
+	// Less returns whether this value is ordered before the given value,
+	// which is always returned by the same TagMapper.
+	Less(other Comparable) bool
+}
+
+// VersionBand is a window of versions, from Min inclusive to Max exclusive,
+// where either bound is unbounded if nil. As with version constraints, only
+// the major, minor and patch versions are compared, ignoring metadata, so
+// pre-releases of Min are within the band, and those of Max are not.
+// e.g. Min=1.4.0, Max=1.6.0 contains 1.4.0-rc.1 and 1.5.9, but not 1.6.0-rc.1
+type VersionBand struct {
+	Min *semver.SemVer
+	Max *semver.SemVer
+}
+
+// Contains returns whether the given version is within the band. Versions
+// which are not valid are never within the band.
+func (b *VersionBand) Contains(v *semver.SemVer) bool {
+	if !v.IsValid() {
+		return false
+	}
+
+	if b.Min != nil && v.CoreLessThan(b.Min) {
+		return false
+	}
+
+	return b.Max == nil || v.CoreLessThan(b.Max)
+}
+
+// String returns the band as version constraints.
+// e.g. >=1.4.0, <1.6.0
+func (b *VersionBand) String() string {
+	var bounds []string
+	if b.Min != nil {
+		bounds = append(bounds, ">="+b.Min.String())
+	}
+	if b.Max != nil {
+		bounds = append(bounds, "<"+b.Max.String())
+	}
+
+	return strings.Join(bounds, ", ")
+}
+
+// DeepCopy returns a copy of the options, which shares no mutable state with
+// the original. Regexes and version constraints are shared, as they are safe
+// for concurrent use.
+func (o *Options) DeepCopy() *Options {
+	if o == nil {
+		return nil
+	}
+
+	c := *o
+	c.OverrideURL = copyString(o.OverrideURL)
+	c.PromotionURL = copyString(o.PromotionURL)
+	c.MatchRegex = copyString(o.MatchRegex)
+	c.FloatingTag = copyString(o.FloatingTag)
+	c.PointerTag = copyString(o.PointerTag)
+	c.PinMajor = copyInt64(o.PinMajor)
+	c.PinMinor = copyInt64(o.PinMinor)
+	c.PinPatch = copyInt64(o.PinPatch)
+
+	if o.VersionBand != nil {
+		band := *o.VersionBand
+		c.VersionBand = &band
+	}
+
+	if o.BeforeTime != nil {
+		beforeTime := *o.BeforeTime
+		c.BeforeTime = &beforeTime
+	}
+
+	if o.CandidateTags != nil {
+		c.CandidateTags = append([]string(nil), o.CandidateTags...)
+	}
+
+	if o.ChannelPaths != nil {
+		c.ChannelPaths = append([]string(nil), o.ChannelPaths...)
+	}
+
+	if o.DenyVersions != nil {
+		c.DenyVersions = append([]string(nil), o.DenyVersions...)
+	}
+
+	if o.RequireManifestMediaTypes != nil {
+		c.RequireManifestMediaTypes = append([]string(nil), o.RequireManifestMediaTypes...)
+	}
+
+	if o.RequireConfigLabels != nil {
+		c.RequireConfigLabels = make(map[string]string, len(o.RequireConfigLabels))
+		for k, v := range o.RequireConfigLabels {
+			c.RequireConfigLabels[k] = v
+		}
+	}
+
+	return &c
+}
+
+// WithDefaults returns a copy of the options, where each unset field is taken
+// from the given defaults. Pointer, slice and regex fields are unset if nil,
+// and value fields are unset if they are their zero value, such as false or
+// empty. Set fields always take precedence over the defaults, however this
+// means a default value field of true, or non-empty, cannot be unset.
+// e.g. defaults PinMajor=1, UseMetaData=true with PinMajor=2 returns
+// PinMajor=2, UseMetaData=true
+func (o *Options) WithDefaults(defaults *Options) *Options {
+	merged := o.DeepCopy()
+	if merged == nil {
+		merged = new(Options)
+	}
+	if defaults == nil {
+		return merged
+	}
+
+	mergedV := reflect.ValueOf(merged).Elem()
+	defaultsV := reflect.ValueOf(defaults.DeepCopy()).Elem()
+	for i := 0; i < mergedV.NumField(); i++ {
+		if field := mergedV.Field(i); field.IsZero() {
+			field.Set(defaultsV.Field(i))
+		}
+	}
+
+	return merged
+}
+
+func copyString(s *string) *string {
+	if s == nil {
+		return nil
+	}
+
+	c := *s
+	return &c
+}
+
+func copyInt64(i *int64) *int64 {
+	if i == nil {
+		return nil
+	}
+
+	c := *i
+	return &c
 }
 
 // ImageTag describes a container image tag.
@@ -67,4 +518,96 @@ type ImageTag struct {
 	Timestamp    time.Time `json:"timestamp"`
 	Architecture string    `json:"architecture,omitempty"`
 	OS           string    `json:"os,omitempty"`
+
+	// ConfigMediaType is the media type of the config of the tag's manifest,
+	// which identifies the type of OCI artifacts, if known. Empty for
+	// manifest lists and indexes.
+	// e.g. application/vnd.cncf.helm.config.v1+json
+	ConfigMediaType string `json:"configMediaType,omitempty"`
+
+	// Source is the image URL the tag was resolved from, only set when
+	// resolving the latest tag across several image URLs.
+	Source string `json:"source,omitempty"`
+
+	// Drifted is set when looking up a floating tag, and the tag's digest has
+	// changed from the previously observed digest.
+	Drifted bool `json:"drifted,omitempty"`
+
+	// NewerPreRelease is the latest pre-release of a greater major, minor or
+	// patch version than the tag, set when the latest version is selected
+	// without UseMetaData, so that pre-releases excluded from selection are
+	// visible. Not set when the tag is selected from a sorted listing.
+	NewerPreRelease *ImageTag `json:"newerPreRelease,omitempty"`
+
+	// Truncated is set on each tag of a partial listing of an image's tags,
+	// where a page of the listing failed after earlier pages were listed, so
+	// that tags selected best effort are known to be selected from incomplete
+	// tags. See the PartialTagPages option of the version getter.
+	Truncated bool `json:"truncated,omitempty"`
+
+	// Stale is set when the tag is selected from cached tags served past the
+	// cache timeout, as refreshing them failed whilst the registry host is
+	// unhealthy. See the ServeStale option of the version getter.
+	Stale bool `json:"stale,omitempty"`
+}
+
+// ImageManifest describes the manifest of a container image reference.
+type ImageManifest struct {
+	Digest    string `json:"digest"`
+	MediaType string `json:"mediaType"`
+
+	// Timestamp is the creation time of the image, if known. For multi-arch
+	// images, the creation time of the index itself is preferred over that of
+	// any platform image.
+	Timestamp time.Time `json:"timestamp,omitempty"`
+
+	// Size is the total size in bytes of the image config and layers. This is
+	// zero for manifest lists, as their size is per platform.
+	Size int64 `json:"size"`
+
+	// Platforms lists the platforms available for a manifest list.
+	Platforms []Platform `json:"platforms,omitempty"`
+
+	Annotations map[string]string `json:"annotations,omitempty"`
+}
+
+// ImageConfig describes the config blob of a container image.
+type ImageConfig struct {
+	Digest string `json:"digest"`
+
+	// Created is the creation time of the image, if known.
+	Created time.Time `json:"created,omitempty"`
+
+	// Labels are the labels of the image, such as those set by the Dockerfile
+	// LABEL instruction.
+	Labels map[string]string `json:"labels,omitempty"`
+}
+
+// Platform describes the platform which an image runs on.
+type Platform struct {
+	OS           string `json:"os"`
+	Architecture string `json:"architecture"`
+	Variant      string `json:"variant,omitempty"`
+}
+
+// TagMetadata describes a container image tag, enriched with the metadata of
+// its manifest.
+type TagMetadata struct {
+	ImageTag
+
+	Size        int64             `json:"size"`
+	Platforms   []Platform        `json:"platforms,omitempty"`
+	Annotations map[string]string `json:"annotations,omitempty"`
+}
+
+// DigestMetadata describes a single image digest, along with all of the tags
+// which alias it.
+type DigestMetadata struct {
+	SHA       string    `json:"sha"`
+	Tags      []string  `json:"tags"`
+	Timestamp time.Time `json:"timestamp"`
+
+	Size        int64             `json:"size"`
+	Platforms   []Platform        `json:"platforms,omitempty"`
+	Annotations map[string]string `json:"annotations,omitempty"`
 }
@@ -2,7 +2,11 @@ package cache
 
 import (
 	"context"
+	"hash/fnv"
+	"sort"
+	"strings"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/sirupsen/logrus"
@@ -12,21 +16,177 @@ import (
 
 // Cache is a generic cache store.
 type Cache struct {
+	// Statistics are updated atomically, so are kept first for alignment.
+	hits, misses, fetchErrors, staleServed, stampedes uint64
+
 	log *logrus.Entry
 
-	mu      sync.RWMutex
 	timeout time.Duration
 	handler Handler
+	opts    Options
+	clock   Clock
+
+	// shards hold the items of the cache, by the hash of their index, so that
+	// access to items of different shards does not contend.
+	shards []*shard
+
+	hostMu sync.Mutex
+	// hostFailures holds the number of consecutive fetch failures per host.
+	hostFailures map[string]int
+
+	inflightMu sync.Mutex
+	// inflight holds the number of fetches in flight per fetch index.
+	inflight map[string]int
+}
+
+// defaultShards is the number of shards of the cache, if not configured.
+const defaultShards = 32
 
+// shard is a partition of the items of the cache, with its own lock.
+type shard struct {
+	mu    sync.RWMutex
 	store map[string]*cacheItem
 }
 
+// Options are used to configure optional behaviour of the cache.
+type Options struct {
+	// ServeStale will cause items which failed to refresh with a host failure
+	// to continue to be served past their timeout. Stale items are not
+	// garbage collected whilst their host is unhealthy.
+	ServeStale bool
+
+	// MaxStale is the maximum duration past the timeout that a stale item will
+	// be served for. No limit if zero.
+	MaxStale time.Duration
+
+	// HostFunc returns the host of the given index, used to track the health of
+	// hosts. Each index is treated as its own host if nil.
+	HostFunc func(index string) string
+
+	// HostFailureFunc returns whether the given fetch error is a failure of
+	// the host, such as a network error or server error, rather than the host
+	// rejecting the request. Only host failures mark the host unhealthy, and
+	// other errors mark it healthy, as the host responded. Every error is a
+	// host failure if nil.
+	HostFailureFunc func(err error) bool
+
+	// AgeFunc is called with the index and age of each item served from the
+	// cache rather than fetched, being the time since the item was committed,
+	// including stale items. Disabled if nil.
+	AgeFunc func(index string, age time.Duration)
+
+	// StampedeFunc is called with the fetch index of each fetch started whilst
+	// a fetch of the same fetch index is already in flight, such as by items
+	// of different indexes sharing a fetch index, so that stampedes on the
+	// remote may be detected. Gets of the same index never fetch concurrently,
+	// as they wait for the in-flight fetch. Disabled if nil.
+	StampedeFunc func(fetchIndex string)
+
+	// EvictFunc is called with the index of each item removed by the garbage
+	// collector, so that state derived from the item may also be removed.
+	// Disabled if nil.
+	EvictFunc func(index string)
+
+	// Clock is the source of the current time, used to expire and garbage
+	// collect items. Defaults to the real time if nil.
+	Clock Clock
+
+	// Shards is the number of shards the items are partitioned into by index,
+	// each with its own lock, reducing contention between Gets and the garbage
+	// collector. Defaults to 32 if zero.
+	Shards int
+}
+
+// Clock is a source of the current time.
+type Clock interface {
+	Now() time.Time
+}
+
+// realClock is a Clock of the real time.
+type realClock struct{}
+
+func (realClock) Now() time.Time {
+	return time.Now()
+}
+
+// RealClock returns a Clock of the real time.
+func RealClock() Clock {
+	return realClock{}
+}
+
+// Stats are the counters of a Cache since it was created.
+type Stats struct {
+	// Hits is the number of Gets served from the cache.
+	Hits uint64
+
+	// Misses is the number of Gets which fetched the item using the handler,
+	// i.e. the number of calls to the remote.
+	Misses uint64
+
+	// FetchErrors is the number of fetches which failed.
+	FetchErrors uint64
+
+	// StaleServed is the number of stale items served after a failed fetch.
+	StaleServed uint64
+
+	// Stampedes is the number of fetches started whilst a fetch of the same
+	// fetch index was already in flight.
+	Stampedes uint64
+
+	// Items is the number of items currently held in the cache.
+	Items int
+}
+
 // cacheItem is a single item for the cache stored. This cache item is
 // periodically garbage collected.
 type cacheItem struct {
-	mu        sync.Mutex
+	// mu is held for the whole of a Get or Refresh of the item, including
+	// its fetch.
+	mu sync.Mutex
+
+	// users is the number of Gets and Refreshes of the item in progress. It
+	// is only incremented whilst holding the lock of the item's shard, so
+	// that the garbage collector never removes an item about to be fetched.
+	users int32
+
+	// stateMu guards the fields below, which are written whilst also holding
+	// mu, so that the garbage collector and statistics may read them without
+	// waiting on an in-flight fetch. Holders of mu may read them without
+	// stateMu.
+	stateMu   sync.RWMutex
 	timestamp time.Time
 	i         interface{}
+
+	// fetchDuration is the duration of the last fetch of the item, whether
+	// or not it succeeded.
+	fetchDuration time.Duration
+
+	// hits, misses and fetchErrors are the counters of Gets of the item, as
+	// those of Stats.
+	hits, misses, fetchErrors uint64
+}
+
+// Entry is a snapshot of an item held in the cache.
+type Entry struct {
+	Index string
+
+	// Value is the item last committed, which must not be modified. Nil if
+	// the item has never been fetched successfully.
+	Value interface{}
+
+	// Committed is the time the item was last committed, and Expires the
+	// time after which it is fetched again. Both are zero if the item has
+	// never been fetched successfully.
+	Committed time.Time
+	Expires   time.Time
+
+	// FetchDuration is the duration of the last fetch of the item, whether
+	// or not it succeeded.
+	FetchDuration time.Duration
+
+	// Hits, Misses and FetchErrors are the counters of the item since it was
+	// created, as those of Stats.
+	Hits, Misses, FetchErrors uint64
 }
 
 // Handler is an interface for implementations of the cache fetch
@@ -36,52 +196,381 @@ type Handler interface {
 }
 
 // New returns a new generic Cache
-func New(log *logrus.Entry, timeout time.Duration, handler Handler) *Cache {
-	return &Cache{
-		log:     log.WithField("cache", "handler"),
-		handler: handler,
-		timeout: timeout,
-		store:   make(map[string]*cacheItem),
+func New(log *logrus.Entry, timeout time.Duration, handler Handler, opts Options) *Cache {
+	clock := opts.Clock
+	if clock == nil {
+		clock = RealClock()
 	}
+
+	shards := opts.Shards
+	if shards <= 0 {
+		shards = defaultShards
+	}
+
+	c := &Cache{
+		log:          log.WithField("cache", "handler"),
+		handler:      handler,
+		timeout:      timeout,
+		opts:         opts,
+		clock:        clock,
+		shards:       make([]*shard, shards),
+		hostFailures: make(map[string]int),
+		inflight:     make(map[string]int),
+	}
+	for i := range c.shards {
+		c.shards[i] = &shard{store: make(map[string]*cacheItem)}
+	}
+
+	return c
+}
+
+// shard returns the shard holding the item of the given index.
+func (c *Cache) shard(index string) *shard {
+	hash := fnv.New32a()
+	_, _ = hash.Write([]byte(index))
+	return c.shards[hash.Sum32()%uint32(len(c.shards))]
+}
+
+// lookup returns the item of the given index, if held in the cache.
+func (c *Cache) lookup(index string) (*cacheItem, bool) {
+	s := c.shard(index)
+
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	item, ok := s.store[index]
+	return item, ok
 }
 
 // Get returns the cache item from the store given the index. Will populate
 // the cache if the index does not currently exist.
 func (c *Cache) Get(ctx context.Context, index string, fetchIndex string, opts *api.Options) (interface{}, error) {
-	c.mu.RLock()
-	item, ok := c.store[index]
-	c.mu.RUnlock()
+	i, _, err := c.GetWithStale(ctx, index, fetchIndex, opts)
+	return i, err
+}
 
-	// If the item doesn't yet exist, create a new zero item.
-	if !ok {
-		c.mu.Lock()
-		item = new(cacheItem)
-		c.store[index] = item
-		c.mu.Unlock()
-	}
+// GetWithStale is the same as Get, but will also return whether the item is
+// stale. Stale items are only returned when the cache is configured to serve
+// stale, and refreshing the item failed with a host failure, see
+// HostFailureFunc. Other errors are returned, as the host responded.
+func (c *Cache) GetWithStale(ctx context.Context, index string, fetchIndex string, opts *api.Options) (interface{}, bool, error) {
+	item := c.acquire(index)
+	defer item.release()
 
 	item.mu.Lock()
 	defer item.mu.Unlock()
 
 	// Test if exists in the cache or is too old
-	if item.timestamp.Add(c.timeout).Before(time.Now()) {
+	if item.timestamp.Add(c.timeout).Before(c.clock.Now()) {
 		// Fetch a new item to commit
-		i, err := c.handler.Fetch(ctx, fetchIndex, opts)
+		atomic.AddUint64(&c.misses, 1)
+		item.count(&item.misses)
+		i, err := c.fetch(ctx, item, fetchIndex, opts)
 		if err != nil {
-			return nil, err
+			atomic.AddUint64(&c.fetchErrors, 1)
+			item.count(&item.fetchErrors)
+			c.recordFetch(index, !c.hostFailure(err))
+
+			if c.hostFailure(err) && c.serveable(item.timestamp, c.clock.Now()) {
+				atomic.AddUint64(&c.staleServed, 1)
+				c.log.Warnf("failed to refresh item, serving stale: %q: %s", index, err)
+				c.observeAge(index, item)
+				return item.i, true, nil
+			}
+
+			return nil, false, err
 		}
+		c.recordFetch(index, true)
 
 		// Commit to the cache
 		c.log.Debugf("committing item: %q", index)
-		item.timestamp = time.Now()
-		item.i = i
+		item.commit(c.clock.Now(), i)
 
-		return i, nil
+		return i, false, nil
 	}
 
+	atomic.AddUint64(&c.hits, 1)
+	item.count(&item.hits)
 	c.log.Debugf("found: %q", index)
+	c.observeAge(index, item)
+
+	return item.i, false, nil
+}
+
+// fetch will fetch the given item using the handler, recording the duration
+// of the fetch, and whether a fetch of the same fetch index is already in
+// flight. The item must be locked.
+func (c *Cache) fetch(ctx context.Context, item *cacheItem, fetchIndex string, opts *api.Options) (interface{}, error) {
+	c.startFetch(fetchIndex)
+	defer c.finishFetch(fetchIndex)
+
+	start := c.clock.Now()
+	i, err := c.handler.Fetch(ctx, fetchIndex, opts)
+
+	item.stateMu.Lock()
+	item.fetchDuration = c.clock.Now().Sub(start)
+	item.stateMu.Unlock()
+
+	return i, err
+}
+
+// startFetch will record a fetch of the given fetch index as in flight,
+// counting a stampede if another fetch of the index is already in flight.
+func (c *Cache) startFetch(fetchIndex string) {
+	c.inflightMu.Lock()
+	stampede := c.inflight[fetchIndex] > 0
+	c.inflight[fetchIndex]++
+	c.inflightMu.Unlock()
+
+	if stampede {
+		atomic.AddUint64(&c.stampedes, 1)
+		c.log.Debugf("fetch already in flight: %q", fetchIndex)
+
+		if c.opts.StampedeFunc != nil {
+			c.opts.StampedeFunc(fetchIndex)
+		}
+	}
+}
+
+// finishFetch will record a fetch of the given fetch index as finished.
+func (c *Cache) finishFetch(fetchIndex string) {
+	c.inflightMu.Lock()
+	defer c.inflightMu.Unlock()
+
+	if c.inflight[fetchIndex]--; c.inflight[fetchIndex] <= 0 {
+		delete(c.inflight, fetchIndex)
+	}
+}
+
+// observeAge will call the age func, if set, with the age of the given item
+// being served. The item must be locked.
+func (c *Cache) observeAge(index string, item *cacheItem) {
+	if c.opts.AgeFunc != nil {
+		c.opts.AgeFunc(index, c.clock.Now().Sub(item.timestamp))
+	}
+}
+
+// Has returns whether a fresh item of the given index is held in the cache,
+// without fetching it, or waiting on an in-flight fetch of the item.
+func (c *Cache) Has(index string) bool {
+	item, ok := c.lookup(index)
+	if !ok {
+		return false
+	}
+
+	item.stateMu.RLock()
+	defer item.stateMu.RUnlock()
+
+	return !item.timestamp.Add(c.timeout).Before(c.clock.Now())
+}
+
+// Refresh will fetch the item of the given index, regardless of whether it is
+// currently cached. The item is only committed to the cache if the fetch
+// succeeds, so that a failed refresh retains the previously cached item.
+func (c *Cache) Refresh(ctx context.Context, index string, fetchIndex string, opts *api.Options) (interface{}, error) {
+	item := c.acquire(index)
+	defer item.release()
+
+	item.mu.Lock()
+	defer item.mu.Unlock()
+
+	atomic.AddUint64(&c.misses, 1)
+	item.count(&item.misses)
+	i, err := c.fetch(ctx, item, fetchIndex, opts)
+	if err != nil {
+		atomic.AddUint64(&c.fetchErrors, 1)
+		item.count(&item.fetchErrors)
+		c.recordFetch(index, !c.hostFailure(err))
+		return nil, err
+	}
+	c.recordFetch(index, true)
 
-	return item.i, nil
+	c.log.Debugf("committing refreshed item: %q", index)
+	item.commit(c.clock.Now(), i)
+
+	return i, nil
+}
+
+// acquire returns the item of the given index, creating it if it does not
+// exist, counted as in use until released.
+func (c *Cache) acquire(index string) *cacheItem {
+	s := c.shard(index)
+
+	s.mu.RLock()
+	item, ok := s.store[index]
+	if ok {
+		atomic.AddInt32(&item.users, 1)
+	}
+	s.mu.RUnlock()
+	if ok {
+		return item
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	// Another caller may have created the item whilst unlocked.
+	item, ok = s.store[index]
+	if !ok {
+		item = new(cacheItem)
+		s.store[index] = item
+	}
+	atomic.AddInt32(&item.users, 1)
+
+	return item
+}
+
+// release will mark a use of the item, returned by acquire, as finished.
+func (item *cacheItem) release() {
+	atomic.AddInt32(&item.users, -1)
+}
+
+// items returns a snapshot of all items held in the cache, keyed by index,
+// locking each shard in turn.
+func (c *Cache) items() map[string]*cacheItem {
+	items := make(map[string]*cacheItem)
+	for _, s := range c.shards {
+		s.mu.RLock()
+		for index, item := range s.store {
+			items[index] = item
+		}
+		s.mu.RUnlock()
+	}
+
+	return items
+}
+
+// Stats returns a snapshot of the cache statistics.
+func (c *Cache) Stats() Stats {
+	var items int
+	for _, s := range c.shards {
+		s.mu.RLock()
+		items += len(s.store)
+		s.mu.RUnlock()
+	}
+
+	return Stats{
+		Hits:        atomic.LoadUint64(&c.hits),
+		Misses:      atomic.LoadUint64(&c.misses),
+		FetchErrors: atomic.LoadUint64(&c.fetchErrors),
+		StaleServed: atomic.LoadUint64(&c.staleServed),
+		Stampedes:   atomic.LoadUint64(&c.stampedes),
+		Items:       items,
+	}
+}
+
+// FetchDurations returns the duration of the last fetch of each item held in
+// the cache, keyed by index, such as to identify slow remotes. Items which
+// have not been fetched are omitted, and in-flight fetches are not waited on.
+func (c *Cache) FetchDurations() map[string]time.Duration {
+	items := c.items()
+
+	durations := make(map[string]time.Duration, len(items))
+	for index, item := range items {
+		item.stateMu.RLock()
+		if item.fetchDuration > 0 {
+			durations[index] = item.fetchDuration
+		}
+		item.stateMu.RUnlock()
+	}
+
+	return durations
+}
+
+// Entries returns a snapshot of every item held in the cache, sorted by
+// index, such as to inspect which items are cached.
+func (c *Cache) Entries() []Entry {
+	items := c.items()
+
+	entries := make([]Entry, 0, len(items))
+	for index, item := range items {
+		// Only the state lock is taken, so that in-flight fetches do not
+		// stall the snapshot.
+		item.stateMu.RLock()
+		entry := Entry{
+			Index:         index,
+			Value:         item.i,
+			FetchDuration: item.fetchDuration,
+			Hits:          item.hits,
+			Misses:        item.misses,
+			FetchErrors:   item.fetchErrors,
+		}
+		if !item.timestamp.IsZero() {
+			entry.Committed = item.timestamp
+			entry.Expires = item.timestamp.Add(c.timeout)
+		}
+		item.stateMu.RUnlock()
+
+		entries = append(entries, entry)
+	}
+
+	sort.Slice(entries, func(i, j int) bool {
+		return entries[i].Index < entries[j].Index
+	})
+
+	return entries
+}
+
+// serveable returns whether a stale item committed at the given timestamp is
+// able to be served.
+func (c *Cache) serveable(timestamp time.Time, now time.Time) bool {
+	if !c.opts.ServeStale || timestamp.IsZero() {
+		return false
+	}
+
+	return c.opts.MaxStale == 0 ||
+		timestamp.Add(c.timeout+c.opts.MaxStale).After(now)
+}
+
+// recordFetch will record the result of a fetch against the index's host.
+func (c *Cache) recordFetch(index string, success bool) {
+	host := c.host(index)
+
+	c.hostMu.Lock()
+	defer c.hostMu.Unlock()
+
+	if success {
+		delete(c.hostFailures, host)
+	} else {
+		c.hostFailures[host]++
+	}
+}
+
+// hostFailure returns whether the given fetch error is a failure of the host.
+func (c *Cache) hostFailure(err error) bool {
+	if c.opts.HostFailureFunc == nil {
+		return true
+	}
+
+	return c.opts.HostFailureFunc(err)
+}
+
+// host returns the host of the given index.
+func (c *Cache) host(index string) string {
+	if c.opts.HostFunc == nil {
+		return index
+	}
+
+	return c.opts.HostFunc(index)
+}
+
+// PurgePrefix will remove all items from the cache whose index starts with
+// the given prefix, so that they are fetched again on next Get. Returns the
+// number of items removed. An empty prefix removes all items.
+func (c *Cache) PurgePrefix(prefix string) int {
+	var purged int
+	for _, s := range c.shards {
+		s.mu.Lock()
+		for index := range s.store {
+			if strings.HasPrefix(index, prefix) {
+				delete(s.store, index)
+				purged++
+			}
+		}
+		s.mu.Unlock()
+	}
+
+	return purged
 }
 
 // StartGarbageCollector is a blocking func that will run the garbage collector
@@ -93,18 +582,115 @@ func (c *Cache) StartGarbageCollector(refreshRate time.Duration) {
 
 	for {
 		<-ticker.C
+		c.garbageCollect(log, c.clock.Now())
+	}
+}
 
-		c.mu.Lock()
+// garbageCollect will remove all items from the cache that are stale at the
+// given time. Stale items whose host is unhealthy are retained if the cache
+// is configured to serve stale. Each shard is collected in turn, and is only
+// locked whilst removing its stale items, so that Gets of other shards, and
+// of fresh items, are not stalled.
+func (c *Cache) garbageCollect(log *logrus.Entry, now time.Time) {
+	for _, s := range c.shards {
+		stale := c.staleItems(log, s, now)
+		if len(stale) == 0 {
+			continue
+		}
 
-		now := time.Now()
-		for index, item := range c.store {
-			if item.timestamp.Add(c.timeout).Before(now) {
+		evicted := c.evict(log, s, stale, now)
 
-				log.Debugf("removing stale cache item: %q", index)
-				delete(c.store, index)
+		if c.opts.EvictFunc != nil {
+			for _, index := range evicted {
+				c.opts.EvictFunc(index)
 			}
 		}
+	}
+}
 
-		c.mu.Unlock()
+// staleItems returns the items of the given shard which are collectable at
+// the given time, keyed by index. The shard is only read locked whilst the
+// items are listed, so that collecting does not block access to the shard.
+func (c *Cache) staleItems(log *logrus.Entry, s *shard, now time.Time) map[string]*cacheItem {
+	s.mu.RLock()
+	items := make(map[string]*cacheItem, len(s.store))
+	for index, item := range s.store {
+		items[index] = item
 	}
+	s.mu.RUnlock()
+
+	stale := make(map[string]*cacheItem)
+	for index, item := range items {
+		if c.collectable(log, index, item, now) {
+			stale[index] = item
+		}
+	}
+
+	return stale
+}
+
+// evict will remove the given stale items from the given shard, returning
+// the indexes removed. Each item is checked again whilst the shard is locked,
+// as it may have been recreated, or acquired to be fetched, since it was
+// found to be stale.
+func (c *Cache) evict(log *logrus.Entry, s *shard, stale map[string]*cacheItem, now time.Time) []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var evicted []string
+	for index, item := range stale {
+		if s.store[index] != item || !c.collectable(log, index, item, now) {
+			continue
+		}
+
+		log.Debugf("removing stale cache item: %q", index)
+		delete(s.store, index)
+		evicted = append(evicted, index)
+	}
+
+	return evicted
+}
+
+// commit will set the given fetched value of the item, committed at the given
+// time. The item lock must be held.
+func (item *cacheItem) commit(now time.Time, i interface{}) {
+	item.stateMu.Lock()
+	defer item.stateMu.Unlock()
+
+	item.timestamp = now
+	item.i = i
+}
+
+// count will increment the given counter of the item. The item lock must be
+// held.
+func (item *cacheItem) count(counter *uint64) {
+	item.stateMu.Lock()
+	*counter++
+	item.stateMu.Unlock()
+}
+
+// collectable returns whether the given item is stale at the given time, and
+// should be removed by the garbage collector.
+func (c *Cache) collectable(log *logrus.Entry, index string, item *cacheItem, now time.Time) bool {
+	// The item lock is held for the duration of fetches, so only the state
+	// lock is taken, so that a slow fetch does not stall garbage collection.
+	item.stateMu.RLock()
+	timestamp := item.timestamp
+	item.stateMu.RUnlock()
+
+	// Items in use are retained, as they may be about to be committed.
+	if atomic.LoadInt32(&item.users) > 0 || !timestamp.Add(c.timeout).Before(now) {
+		return false
+	}
+
+	c.hostMu.Lock()
+	unhealthy := c.hostFailures[c.host(index)] > 0
+	c.hostMu.Unlock()
+
+	if unhealthy && c.serveable(timestamp, now) {
+		log.Debugf("retaining stale cache item of unhealthy host: %q", index)
+		return false
+	}
+
+	return true
 }
@@ -0,0 +1,1035 @@
+package cache
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"reflect"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/sirupsen/logrus"
+
+	"github.com/jetstack/version-checker/pkg/api"
+)
+
+// fakeHandler returns the index as the fetched item, or an error if set.
+type fakeHandler struct {
+	err   error
+	calls int
+
+	// onFetch, if set, is called with the index of each fetch.
+	onFetch func(index string)
+}
+
+func (f *fakeHandler) Fetch(_ context.Context, index string, _ *api.Options) (interface{}, error) {
+	f.calls++
+
+	if f.onFetch != nil {
+		f.onFetch(index)
+	}
+
+	if f.err != nil {
+		return nil, f.err
+	}
+
+	return index, nil
+}
+
+// fakeClock is a Clock which only advances when told to.
+type fakeClock struct {
+	mu  sync.Mutex
+	now time.Time
+}
+
+func newFakeClock() *fakeClock {
+	return &fakeClock{now: time.Unix(1600000000, 0)}
+}
+
+func (f *fakeClock) Now() time.Time {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.now
+}
+
+// Advance will move the clock forward by the given duration.
+func (f *fakeClock) Advance(d time.Duration) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.now = f.now.Add(d)
+}
+
+func newTestCache(handler Handler, timeout time.Duration, opts Options) *Cache {
+	log := logrus.New()
+	log.SetLevel(logrus.PanicLevel)
+
+	return New(logrus.NewEntry(log), timeout, handler, opts)
+}
+
+// hostFunc returns the host of "{host}/{path}" indexes.
+func hostFunc(index string) string {
+	return strings.SplitN(index, "/", 2)[0]
+}
+
+func TestServeStale(t *testing.T) {
+	handler := new(fakeHandler)
+	clock := newFakeClock()
+	c := newTestCache(handler, time.Millisecond, Options{
+		Clock:      clock,
+		ServeStale: true,
+		HostFunc:   hostFunc,
+	})
+
+	i, stale, err := c.GetWithStale(context.TODO(), "quay.io/foo", "quay.io/foo", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if i != "quay.io/foo" || stale {
+		t.Errorf("unexpected item, exp=%q (stale=false) got=%v (stale=%t)", "quay.io/foo", i, stale)
+	}
+
+	// Registry outage
+	handler.err = errors.New("registry unavailable")
+	clock.Advance(time.Millisecond * 2)
+
+	i, stale, err = c.GetWithStale(context.TODO(), "quay.io/foo", "quay.io/foo", nil)
+	if err != nil {
+		t.Fatalf("expected stale item to be served, got error: %s", err)
+	}
+	if i != "quay.io/foo" || !stale {
+		t.Errorf("unexpected item, exp=%q (stale=true) got=%v (stale=%t)", "quay.io/foo", i, stale)
+	}
+
+	// New items for the unhealthy host cannot be served.
+	if _, err := c.Get(context.TODO(), "quay.io/bar", "quay.io/bar", nil); err == nil {
+		t.Error("expected error for item never successfully fetched, got none")
+	}
+
+	// Stale items should be retained whilst the host is unhealthy.
+	c.garbageCollect(c.log, clock.Now())
+	if _, ok := c.lookup("quay.io/foo"); !ok {
+		t.Error("expected stale item of unhealthy host to be retained by garbage collector")
+	}
+
+	// Registry recovers
+	handler.err = nil
+
+	i, stale, err = c.GetWithStale(context.TODO(), "quay.io/foo", "quay.io/foo", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if stale {
+		t.Error("expected fresh item after recovery")
+	}
+	if failures := c.hostFailures["quay.io"]; failures != 0 {
+		t.Errorf("expected host failures to be reset, got=%d", failures)
+	}
+
+	// Once healthy, stale items should be garbage collected.
+	c.garbageCollect(c.log, clock.Now().Add(time.Second))
+	if _, ok := c.lookup("quay.io/foo"); ok {
+		t.Error("expected stale item of healthy host to be garbage collected")
+	}
+}
+
+func TestHostFailureFunc(t *testing.T) {
+	errNotFound := errors.New("image not found")
+	errUnavailable := errors.New("registry unavailable")
+
+	handler := new(fakeHandler)
+	clock := newFakeClock()
+	c := newTestCache(handler, time.Millisecond, Options{
+		Clock:      clock,
+		ServeStale: true,
+		HostFunc:   hostFunc,
+		HostFailureFunc: func(err error) bool {
+			return errors.Is(err, errUnavailable)
+		},
+	})
+
+	if _, err := c.Get(context.TODO(), "quay.io/foo", "quay.io/foo", nil); err != nil {
+		t.Fatal(err)
+	}
+	clock.Advance(time.Millisecond * 2)
+
+	// Errors which are not host failures should leave the host healthy.
+	handler.err = errNotFound
+	if _, err := c.Get(context.TODO(), "quay.io/bar", "quay.io/bar", nil); !errors.Is(err, errNotFound) {
+		t.Errorf("unexpected error, exp=%v got=%v", errNotFound, err)
+	}
+	if failures := c.hostFailures["quay.io"]; failures != 0 {
+		t.Errorf("expected host to be healthy after request error, got failures=%d", failures)
+	}
+	c.garbageCollect(c.log, clock.Now())
+	if _, ok := c.lookup("quay.io/foo"); ok {
+		t.Error("expected stale item of healthy host to be garbage collected")
+	}
+
+	// Host failures should mark the host unhealthy.
+	handler.err = errUnavailable
+	if _, err := c.Get(context.TODO(), "quay.io/bar", "quay.io/bar", nil); !errors.Is(err, errUnavailable) {
+		t.Errorf("unexpected error, exp=%v got=%v", errUnavailable, err)
+	}
+	if failures := c.hostFailures["quay.io"]; failures != 1 {
+		t.Errorf("expected host to be unhealthy after host failure, got failures=%d", failures)
+	}
+
+	// A request error after a host failure should mark the host healthy again.
+	handler.err = errNotFound
+	if _, err := c.Get(context.TODO(), "quay.io/bar", "quay.io/bar", nil); !errors.Is(err, errNotFound) {
+		t.Errorf("unexpected error, exp=%v got=%v", errNotFound, err)
+	}
+	if failures := c.hostFailures["quay.io"]; failures != 0 {
+		t.Errorf("expected host failures to be reset, got=%d", failures)
+	}
+}
+
+func TestServeStaleHostFailureOnly(t *testing.T) {
+	errNotFound := errors.New("image not found")
+	errUnavailable := errors.New("registry unavailable")
+
+	handler := new(fakeHandler)
+	clock := newFakeClock()
+	c := newTestCache(handler, time.Millisecond, Options{
+		Clock:      clock,
+		ServeStale: true,
+		HostFunc:   hostFunc,
+		HostFailureFunc: func(err error) bool {
+			return errors.Is(err, errUnavailable)
+		},
+	})
+
+	if _, err := c.Get(context.TODO(), "quay.io/foo", "quay.io/foo", nil); err != nil {
+		t.Fatal(err)
+	}
+	clock.Advance(time.Millisecond * 2)
+
+	// Errors which are not host failures should be returned.
+	handler.err = errNotFound
+	if _, stale, err := c.GetWithStale(context.TODO(), "quay.io/foo", "quay.io/foo", nil); !errors.Is(err, errNotFound) || stale {
+		t.Errorf("unexpected result, exp=%v (stale=false) got=%v (stale=%t)", errNotFound, err, stale)
+	}
+
+	// Host failures should serve stale.
+	handler.err = errUnavailable
+	i, stale, err := c.GetWithStale(context.TODO(), "quay.io/foo", "quay.io/foo", nil)
+	if err != nil {
+		t.Fatalf("expected stale item to be served, got error: %s", err)
+	}
+	if i != "quay.io/foo" || !stale {
+		t.Errorf("unexpected item, exp=%q (stale=true) got=%v (stale=%t)", "quay.io/foo", i, stale)
+	}
+}
+
+func TestServeStaleDisabled(t *testing.T) {
+	handler := new(fakeHandler)
+	clock := newFakeClock()
+	c := newTestCache(handler, time.Millisecond, Options{Clock: clock, HostFunc: hostFunc})
+
+	if _, err := c.Get(context.TODO(), "quay.io/foo", "quay.io/foo", nil); err != nil {
+		t.Fatal(err)
+	}
+
+	handler.err = errors.New("registry unavailable")
+	clock.Advance(time.Millisecond * 2)
+
+	if _, err := c.Get(context.TODO(), "quay.io/foo", "quay.io/foo", nil); err == nil {
+		t.Error("expected error when not serving stale, got none")
+	}
+
+	c.garbageCollect(c.log, clock.Now())
+	if _, ok := c.lookup("quay.io/foo"); ok {
+		t.Error("expected stale item to be garbage collected when not serving stale")
+	}
+}
+
+func TestServeStaleMaxStale(t *testing.T) {
+	handler := new(fakeHandler)
+	clock := newFakeClock()
+	c := newTestCache(handler, time.Millisecond, Options{
+		Clock:      clock,
+		ServeStale: true,
+		MaxStale:   time.Millisecond,
+		HostFunc:   hostFunc,
+	})
+
+	if _, err := c.Get(context.TODO(), "quay.io/foo", "quay.io/foo", nil); err != nil {
+		t.Fatal(err)
+	}
+
+	handler.err = errors.New("registry unavailable")
+	clock.Advance(time.Millisecond * 5)
+
+	if _, err := c.Get(context.TODO(), "quay.io/foo", "quay.io/foo", nil); err == nil {
+		t.Error("expected error when item is past max stale, got none")
+	}
+}
+
+func TestStats(t *testing.T) {
+	handler := new(fakeHandler)
+	clock := newFakeClock()
+	c := newTestCache(handler, time.Millisecond, Options{Clock: clock, ServeStale: true})
+
+	for _, index := range []string{"quay.io/foo", "quay.io/foo", "quay.io/bar"} {
+		if _, err := c.Get(context.TODO(), index, index, nil); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// Failed refresh which serves stale
+	handler.err = errors.New("registry unavailable")
+	clock.Advance(time.Millisecond * 2)
+	if _, err := c.Get(context.TODO(), "quay.io/foo", "quay.io/foo", nil); err != nil {
+		t.Fatal(err)
+	}
+
+	expStats := Stats{
+		Hits:        1,
+		Misses:      3,
+		FetchErrors: 1,
+		StaleServed: 1,
+		Items:       2,
+	}
+	if stats := c.Stats(); stats != expStats {
+		t.Errorf("unexpected stats, exp=%+v got=%+v", expStats, stats)
+	}
+}
+
+func TestRefresh(t *testing.T) {
+	handler := new(fakeHandler)
+	c := newTestCache(handler, time.Hour, Options{})
+
+	if _, err := c.Get(context.TODO(), "quay.io/foo", "quay.io/foo", nil); err != nil {
+		t.Fatal(err)
+	}
+
+	// Refresh should fetch, even though the item is cached.
+	i, err := c.Refresh(context.TODO(), "quay.io/foo", "quay.io/foo", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if i != "quay.io/foo" || handler.calls != 2 {
+		t.Errorf("unexpected refresh, exp=%q (calls=2) got=%v (calls=%d)", "quay.io/foo", i, handler.calls)
+	}
+
+	// A failed refresh should keep the cached item.
+	handler.err = errors.New("registry unavailable")
+	if _, err := c.Refresh(context.TODO(), "quay.io/foo", "quay.io/foo", nil); err == nil {
+		t.Fatal("expected error from failed refresh, got none")
+	}
+
+	i, err = c.Get(context.TODO(), "quay.io/foo", "quay.io/foo", nil)
+	if err != nil {
+		t.Fatalf("expected cached item to be kept after failed refresh, got error: %s", err)
+	}
+	if i != "quay.io/foo" || handler.calls != 3 {
+		t.Errorf("unexpected cached item, exp=%q (calls=3) got=%v (calls=%d)", "quay.io/foo", i, handler.calls)
+	}
+}
+
+func TestExpiry(t *testing.T) {
+	handler := new(fakeHandler)
+	clock := newFakeClock()
+	c := newTestCache(handler, time.Hour, Options{Clock: clock})
+
+	if _, err := c.Get(context.TODO(), "quay.io/foo", "quay.io/foo", nil); err != nil {
+		t.Fatal(err)
+	}
+
+	// Items within the timeout should be served from the cache.
+	clock.Advance(time.Minute * 30)
+	for _, index := range []string{"quay.io/foo", "quay.io/bar"} {
+		if _, err := c.Get(context.TODO(), index, index, nil); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if handler.calls != 2 {
+		t.Errorf("expected item to be served from cache, exp=2 got=%d calls", handler.calls)
+	}
+
+	// quay.io/foo has now expired, where quay.io/bar is still fresh.
+	clock.Advance(time.Minute * 31)
+	c.garbageCollect(c.log, clock.Now())
+	if _, ok := c.lookup("quay.io/foo"); ok {
+		t.Error("expected expired item to be garbage collected")
+	}
+	if _, ok := c.lookup("quay.io/bar"); !ok {
+		t.Error("expected fresh item to be retained by garbage collector")
+	}
+
+	// Expired items should be fetched again.
+	clock.Advance(time.Hour)
+	if _, err := c.Get(context.TODO(), "quay.io/bar", "quay.io/bar", nil); err != nil {
+		t.Fatal(err)
+	}
+	if handler.calls != 3 {
+		t.Errorf("expected expired item to be fetched, exp=3 got=%d calls", handler.calls)
+	}
+}
+
+func TestEvictFunc(t *testing.T) {
+	handler := new(fakeHandler)
+	clock := newFakeClock()
+
+	var evicted []string
+	c := newTestCache(handler, time.Hour, Options{
+		Clock:     clock,
+		EvictFunc: func(index string) { evicted = append(evicted, index) },
+	})
+
+	if _, err := c.Get(context.TODO(), "quay.io/foo", "quay.io/foo", nil); err != nil {
+		t.Fatal(err)
+	}
+	clock.Advance(time.Minute * 30)
+	if _, err := c.Get(context.TODO(), "quay.io/bar", "quay.io/bar", nil); err != nil {
+		t.Fatal(err)
+	}
+
+	// Only items removed by the garbage collector should be evicted.
+	clock.Advance(time.Minute * 31)
+	c.garbageCollect(c.log, clock.Now())
+	if len(evicted) != 1 || evicted[0] != "quay.io/foo" {
+		t.Errorf("unexpected evicted items, exp=%v got=%v", []string{"quay.io/foo"}, evicted)
+	}
+
+	c.garbageCollect(c.log, clock.Now())
+	if len(evicted) != 1 {
+		t.Errorf("expected items to be evicted once, got=%v", evicted)
+	}
+}
+
+func TestGarbageCollectInFlightFetch(t *testing.T) {
+	handler := new(fakeHandler)
+	clock := newFakeClock()
+	c := newTestCache(handler, time.Millisecond, Options{Clock: clock})
+
+	for _, index := range []string{"quay.io/foo", "quay.io/bar"} {
+		if _, err := c.Get(context.TODO(), index, index, nil); err != nil {
+			t.Fatal(err)
+		}
+	}
+	clock.Advance(time.Millisecond * 2)
+
+	started, release := make(chan struct{}), make(chan struct{})
+	handler.onFetch = func(index string) {
+		close(started)
+		<-release
+	}
+
+	fetched := make(chan error, 1)
+	go func() {
+		_, err := c.Get(context.TODO(), "quay.io/bar", "quay.io/bar", nil)
+		fetched <- err
+	}()
+	<-started
+
+	collected := make(chan struct{})
+	go func() {
+		c.garbageCollect(c.log, clock.Now())
+		close(collected)
+	}()
+
+	select {
+	case <-collected:
+	case <-time.After(time.Second * 5):
+		close(release)
+		t.Fatal("expected garbage collection not to wait for in-flight fetch")
+	}
+
+	if _, ok := c.lookup("quay.io/foo"); ok {
+		t.Error("expected stale item to be garbage collected during in-flight fetch")
+	}
+	if _, ok := c.lookup("quay.io/bar"); !ok {
+		t.Error("expected item being fetched to be retained by garbage collector")
+	}
+
+	close(release)
+	if err := <-fetched; err != nil {
+		t.Fatal(err)
+	}
+
+	c.garbageCollect(c.log, clock.Now())
+	if _, ok := c.lookup("quay.io/bar"); !ok {
+		t.Error("expected fetched item to be retained by garbage collector")
+	}
+}
+
+func TestGarbageCollectFetchStarted(t *testing.T) {
+	handler := new(fakeHandler)
+	clock := newFakeClock()
+	c := newTestCache(handler, time.Millisecond, Options{Clock: clock})
+
+	if _, err := c.Get(context.TODO(), "quay.io/foo", "quay.io/foo", nil); err != nil {
+		t.Fatal(err)
+	}
+	clock.Advance(time.Millisecond * 2)
+
+	s := c.shard("quay.io/foo")
+	if stale := c.staleItems(c.log, s, clock.Now()); len(stale) != 1 {
+		t.Fatalf("expected stale item, got=%v", stale)
+	}
+
+	// An item acquired after being found stale should not be evicted.
+	item := c.acquire("quay.io/foo")
+	stale := map[string]*cacheItem{"quay.io/foo": item}
+	if evicted := c.evict(c.log, s, stale, clock.Now()); len(evicted) != 0 {
+		t.Errorf("expected acquired item not to be evicted, got=%v", evicted)
+	}
+	item.release()
+
+	// A fetch started after the item was found stale should not be lost.
+	started, release := make(chan struct{}), make(chan struct{})
+	handler.onFetch = func(string) {
+		close(started)
+		<-release
+	}
+
+	stale = c.staleItems(c.log, s, clock.Now())
+	fetched := make(chan error, 1)
+	go func() {
+		_, err := c.Get(context.TODO(), "quay.io/foo", "quay.io/foo", nil)
+		fetched <- err
+	}()
+	<-started
+
+	if evicted := c.evict(c.log, s, stale, clock.Now()); len(evicted) != 0 {
+		t.Errorf("expected item being fetched not to be evicted, got=%v", evicted)
+	}
+
+	close(release)
+	if err := <-fetched; err != nil {
+		t.Fatal(err)
+	}
+	if !c.Has("quay.io/foo") {
+		t.Error("expected fetched item to be held")
+	}
+
+	// Once released, stale items should be evicted.
+	clock.Advance(time.Millisecond * 2)
+	if evicted := c.evict(c.log, s, stale, clock.Now()); len(evicted) != 1 {
+		t.Errorf("expected stale item to be evicted, got=%v", evicted)
+	}
+}
+
+func TestPurgePrefix(t *testing.T) {
+	indexes := []string{
+		"quay.io/jetstack/cert-manager",
+		"quay.io/jetstack/version-checker",
+		"quay.io/prometheus/prometheus",
+		"gcr.io/jetstack/version-checker",
+	}
+
+	tests := map[string]struct {
+		prefix    string
+		expPurged int
+		expKept   []string
+	}{
+		"host prefix should purge all images of the host": {
+			prefix:    "quay.io/",
+			expPurged: 3,
+			expKept:   []string{"gcr.io/jetstack/version-checker"},
+		},
+		"repo prefix should purge only images of the repo": {
+			prefix:    "quay.io/jetstack/",
+			expPurged: 2,
+			expKept:   []string{"quay.io/prometheus/prometheus", "gcr.io/jetstack/version-checker"},
+		},
+		"unmatched prefix should purge nothing": {
+			prefix:    "docker.io/",
+			expPurged: 0,
+			expKept:   indexes,
+		},
+		"empty prefix should purge everything": {
+			prefix:    "",
+			expPurged: 4,
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			handler := new(fakeHandler)
+			c := newTestCache(handler, time.Hour, Options{})
+
+			for _, index := range indexes {
+				if _, err := c.Get(context.TODO(), index, index, nil); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			if purged := c.PurgePrefix(test.prefix); purged != test.expPurged {
+				t.Errorf("unexpected number of purged items, exp=%d got=%d", test.expPurged, purged)
+			}
+
+			for _, index := range test.expKept {
+				if !c.Has(index) {
+					t.Errorf("expected %q to be kept", index)
+				}
+			}
+			if items := c.Stats().Items; items != len(test.expKept) {
+				t.Errorf("unexpected number of items, exp=%d got=%d", len(test.expKept), items)
+			}
+		})
+	}
+}
+
+func TestAgeFunc(t *testing.T) {
+	var ages []time.Duration
+
+	handler := new(fakeHandler)
+	clock := newFakeClock()
+	c := newTestCache(handler, time.Minute, Options{
+		ServeStale: true,
+		HostFunc:   hostFunc,
+		Clock:      clock,
+		AgeFunc: func(index string, age time.Duration) {
+			if index != "quay.io/foo" {
+				t.Errorf("unexpected index, exp=quay.io/foo got=%s", index)
+			}
+			ages = append(ages, age)
+		},
+	})
+
+	get := func() {
+		if _, err := c.Get(context.TODO(), "quay.io/foo", "quay.io/foo", nil); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// Fetched items are not served from the cache.
+	get()
+
+	clock.Advance(time.Second * 10)
+	get()
+	clock.Advance(time.Second * 20)
+	get()
+
+	// Stale items are served from the cache.
+	handler.err = errors.New("registry unavailable")
+	clock.Advance(time.Minute)
+	get()
+
+	// Refreshed items are fetched.
+	handler.err = nil
+	clock.Advance(time.Minute)
+	get()
+
+	exp := []time.Duration{time.Second * 10, time.Second * 30, time.Second * 90}
+	if len(ages) != len(exp) {
+		t.Fatalf("unexpected ages, exp=%v got=%v", exp, ages)
+	}
+	for i := range exp {
+		if ages[i] != exp[i] {
+			t.Errorf("unexpected ages, exp=%v got=%v", exp, ages)
+		}
+	}
+}
+
+func TestFetchDurations(t *testing.T) {
+	clock := newFakeClock()
+	latencies := map[string]time.Duration{
+		"quay.io/foo": time.Second,
+		"quay.io/bar": time.Second * 3,
+	}
+
+	handler := &fakeHandler{
+		onFetch: func(index string) {
+			clock.Advance(latencies[index])
+		},
+	}
+	c := newTestCache(handler, time.Minute, Options{ServeStale: true, HostFunc: hostFunc, Clock: clock})
+
+	if durations := c.FetchDurations(); len(durations) != 0 {
+		t.Errorf("unexpected durations before fetching, exp=map[] got=%v", durations)
+	}
+
+	for index := range latencies {
+		if _, err := c.Get(context.TODO(), index, index, nil); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	assertDurations := func(exp map[string]time.Duration) {
+		t.Helper()
+
+		if durations := c.FetchDurations(); !reflect.DeepEqual(durations, exp) {
+			t.Errorf("unexpected durations, exp=%v got=%v", exp, durations)
+		}
+	}
+
+	assertDurations(map[string]time.Duration{
+		"quay.io/foo": time.Second,
+		"quay.io/bar": time.Second * 3,
+	})
+
+	// Items served from the cache keep the duration of their last fetch.
+	if _, err := c.Get(context.TODO(), "quay.io/foo", "quay.io/foo", nil); err != nil {
+		t.Fatal(err)
+	}
+	assertDurations(map[string]time.Duration{
+		"quay.io/foo": time.Second,
+		"quay.io/bar": time.Second * 3,
+	})
+
+	// Failed refreshes record their duration.
+	handler.err = errors.New("registry unavailable")
+	latencies["quay.io/bar"] = time.Second * 10
+	if _, err := c.Refresh(context.TODO(), "quay.io/bar", "quay.io/bar", nil); err == nil {
+		t.Fatal("expected refresh error, got none")
+	}
+	assertDurations(map[string]time.Duration{
+		"quay.io/foo": time.Second,
+		"quay.io/bar": time.Second * 10,
+	})
+}
+
+// countingHandler returns the index as the fetched item, counting fetches
+// atomically so that it may be used concurrently.
+type countingHandler struct {
+	calls uint64
+}
+
+func (c *countingHandler) Fetch(_ context.Context, index string, _ *api.Options) (interface{}, error) {
+	atomic.AddUint64(&c.calls, 1)
+	return index, nil
+}
+
+// Run with -race to verify access across shards is synchronised.
+func TestConcurrentAccess(t *testing.T) {
+	for _, shards := range []int{1, defaultShards} {
+		t.Run(fmt.Sprintf("shards=%d", shards), func(t *testing.T) {
+			clock := newFakeClock()
+			c := newTestCache(new(countingHandler), time.Minute, Options{
+				Clock:      clock,
+				ServeStale: true,
+				HostFunc:   hostFunc,
+				Shards:     shards,
+			})
+
+			var wg sync.WaitGroup
+			for i := 0; i < 8; i++ {
+				wg.Add(1)
+				go func(i int) {
+					defer wg.Done()
+
+					for j := 0; j < 200; j++ {
+						index := fmt.Sprintf("quay.io/image-%d", (i*200+j)%50)
+						item, err := c.Get(context.TODO(), index, index, nil)
+						if err != nil {
+							t.Error(err)
+							return
+						}
+						if item != index {
+							t.Errorf("unexpected item, exp=%q got=%v", index, item)
+							return
+						}
+
+						switch j % 50 {
+						case 0:
+							c.garbageCollect(c.log, clock.Now())
+						case 10:
+							c.PurgePrefix(index)
+						case 20:
+							if _, err := c.Refresh(context.TODO(), index, index, nil); err != nil {
+								t.Error(err)
+								return
+							}
+						case 30:
+							c.Stats()
+							c.FetchDurations()
+						case 40:
+							clock.Advance(time.Minute)
+						}
+					}
+				}(i)
+			}
+			wg.Wait()
+
+			// Every item is now stale, so should be collected.
+			clock.Advance(time.Hour)
+			c.garbageCollect(c.log, clock.Now())
+			if items := c.Stats().Items; items != 0 {
+				t.Errorf("expected all items to be garbage collected, exp=0 got=%d", items)
+			}
+		})
+	}
+}
+
+// BenchmarkGetParallel measures Gets of cached items whilst the garbage
+// collector runs, comparing a single shard, which contends as one lock, with
+// the default number of shards.
+func BenchmarkGetParallel(b *testing.B) {
+	indexes := make([]string, 1000)
+	for i := range indexes {
+		indexes[i] = fmt.Sprintf("quay.io/image-%d", i)
+	}
+
+	for _, shards := range []int{1, defaultShards} {
+		b.Run(fmt.Sprintf("shards=%d", shards), func(b *testing.B) {
+			c := newTestCache(new(countingHandler), time.Hour, Options{Shards: shards})
+			for _, index := range indexes {
+				if _, err := c.Get(context.TODO(), index, index, nil); err != nil {
+					b.Fatal(err)
+				}
+			}
+
+			stop := make(chan struct{})
+			done := make(chan struct{})
+			go func() {
+				defer close(done)
+				for {
+					select {
+					case <-stop:
+						return
+					default:
+						c.garbageCollect(c.log, time.Now())
+					}
+				}
+			}()
+
+			b.ResetTimer()
+			b.RunParallel(func(pb *testing.PB) {
+				var i int
+				for pb.Next() {
+					index := indexes[i%len(indexes)]
+					if _, err := c.Get(context.TODO(), index, index, nil); err != nil {
+						b.Error(err)
+						return
+					}
+					i++
+				}
+			})
+			b.StopTimer()
+
+			close(stop)
+			<-done
+		})
+	}
+}
+
+// barrierHandler returns the index as the fetched item, blocking each fetch
+// until the given number of fetches are in flight.
+type barrierHandler struct {
+	wg *sync.WaitGroup
+}
+
+func (b *barrierHandler) Fetch(_ context.Context, index string, _ *api.Options) (interface{}, error) {
+	b.wg.Done()
+	b.wg.Wait()
+	return index, nil
+}
+
+// Run with -race to verify stampedes are counted safely.
+func TestStampedes(t *testing.T) {
+	const concurrency = 8
+
+	t.Run("concurrent misses sharing a fetch index should be stampedes", func(t *testing.T) {
+		var (
+			wg     sync.WaitGroup
+			called uint64
+		)
+		wg.Add(concurrency)
+
+		c := newTestCache(&barrierHandler{wg: &wg}, time.Hour, Options{
+			StampedeFunc: func(fetchIndex string) {
+				if fetchIndex != "quay.io/foo" {
+					t.Errorf("unexpected stampede fetch index, exp=%q got=%q", "quay.io/foo", fetchIndex)
+				}
+				atomic.AddUint64(&called, 1)
+			},
+		})
+
+		var getters sync.WaitGroup
+		for i := 0; i < concurrency; i++ {
+			getters.Add(1)
+			go func(i int) {
+				defer getters.Done()
+
+				// Each index is scoped differently, so fetches the same index.
+				index := fmt.Sprintf("scope-%d/quay.io/foo", i)
+				if _, err := c.Get(context.TODO(), index, "quay.io/foo", nil); err != nil {
+					t.Error(err)
+				}
+			}(i)
+		}
+		getters.Wait()
+
+		if stampedes := c.Stats().Stampedes; stampedes != concurrency-1 {
+			t.Errorf("unexpected stampedes, exp=%d got=%d", concurrency-1, stampedes)
+		}
+		if called != concurrency-1 {
+			t.Errorf("unexpected stampede func calls, exp=%d got=%d", concurrency-1, called)
+		}
+	})
+
+	t.Run("concurrent misses of the same index should not be stampedes", func(t *testing.T) {
+		handler := new(countingHandler)
+		c := newTestCache(handler, time.Hour, Options{})
+
+		var getters sync.WaitGroup
+		for i := 0; i < concurrency; i++ {
+			getters.Add(1)
+			go func() {
+				defer getters.Done()
+
+				if _, err := c.Get(context.TODO(), "quay.io/foo", "quay.io/foo", nil); err != nil {
+					t.Error(err)
+				}
+			}()
+		}
+		getters.Wait()
+
+		if calls := atomic.LoadUint64(&handler.calls); calls != 1 {
+			t.Errorf("expected a single fetch, exp=1 got=%d", calls)
+		}
+		if stampedes := c.Stats().Stampedes; stampedes != 0 {
+			t.Errorf("unexpected stampedes, exp=0 got=%d", stampedes)
+		}
+	})
+
+	t.Run("sequential misses sharing a fetch index should not be stampedes", func(t *testing.T) {
+		c := newTestCache(new(countingHandler), time.Hour, Options{})
+
+		for i := 0; i < concurrency; i++ {
+			index := fmt.Sprintf("scope-%d/quay.io/foo", i)
+			if _, err := c.Get(context.TODO(), index, "quay.io/foo", nil); err != nil {
+				t.Fatal(err)
+			}
+		}
+
+		if stampedes := c.Stats().Stampedes; stampedes != 0 {
+			t.Errorf("unexpected stampedes, exp=0 got=%d", stampedes)
+		}
+	})
+}
+
+func TestEntries(t *testing.T) {
+	clock := newFakeClock()
+	handler := new(fakeHandler)
+	c := newTestCache(handler, time.Minute, Options{Clock: clock})
+
+	if entries := c.Entries(); len(entries) != 0 {
+		t.Errorf("unexpected entries before fetching, exp=[] got=%v", entries)
+	}
+
+	committed := clock.Now()
+	for _, index := range []string{"quay.io/foo", "quay.io/bar", "quay.io/foo"} {
+		if _, err := c.Get(context.TODO(), index, index, nil); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// Items which failed to be fetched are held without a value.
+	handler.err = errors.New("registry unavailable")
+	if _, err := c.Get(context.TODO(), "gcr.io/baz", "gcr.io/baz", nil); err == nil {
+		t.Fatal("expected fetch error, got none")
+	}
+
+	exp := []Entry{
+		{Index: "gcr.io/baz", Misses: 1, FetchErrors: 1},
+		{
+			Index: "quay.io/bar", Value: "quay.io/bar",
+			Committed: committed, Expires: committed.Add(time.Minute),
+			Misses: 1,
+		},
+		{
+			Index: "quay.io/foo", Value: "quay.io/foo",
+			Committed: committed, Expires: committed.Add(time.Minute),
+			Hits: 1, Misses: 1,
+		},
+	}
+	if entries := c.Entries(); !reflect.DeepEqual(entries, exp) {
+		t.Errorf("unexpected entries, exp=%+v got=%+v", exp, entries)
+	}
+}
+
+func TestEntriesInFlightFetch(t *testing.T) {
+	handler := new(fakeHandler)
+	c := newTestCache(handler, time.Minute, Options{Clock: newFakeClock()})
+
+	if _, err := c.Get(context.TODO(), "quay.io/foo", "quay.io/foo", nil); err != nil {
+		t.Fatal(err)
+	}
+
+	started, release := make(chan struct{}), make(chan struct{})
+	handler.onFetch = func(string) {
+		close(started)
+		<-release
+	}
+
+	fetched := make(chan error, 1)
+	go func() {
+		_, err := c.Get(context.TODO(), "quay.io/bar", "quay.io/bar", nil)
+		fetched <- err
+	}()
+	<-started
+
+	listed := make(chan []Entry, 1)
+	go func() {
+		listed <- c.Entries()
+	}()
+
+	select {
+	case entries := <-listed:
+		if len(entries) != 2 || entries[0].Index != "quay.io/bar" || entries[0].Value != nil {
+			t.Errorf("unexpected entries during in-flight fetch, got=%+v", entries)
+		}
+	case <-time.After(time.Second * 5):
+		close(release)
+		t.Fatal("expected entries not to wait for in-flight fetch")
+	}
+
+	close(release)
+	if err := <-fetched; err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestFetchDurationsAndHasInFlightFetch(t *testing.T) {
+	handler := new(fakeHandler)
+	c := newTestCache(handler, time.Minute, Options{Clock: newFakeClock()})
+
+	if _, err := c.Get(context.TODO(), "quay.io/foo", "quay.io/foo", nil); err != nil {
+		t.Fatal(err)
+	}
+
+	started, release := make(chan struct{}), make(chan struct{})
+	handler.onFetch = func(string) {
+		close(started)
+		<-release
+	}
+
+	fetched := make(chan error, 1)
+	go func() {
+		_, err := c.Get(context.TODO(), "quay.io/bar", "quay.io/bar", nil)
+		fetched <- err
+	}()
+	<-started
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+
+		c.FetchDurations()
+		if c.Has("quay.io/bar") {
+			t.Error("expected item being fetched not to be held")
+		}
+		if !c.Has("quay.io/foo") {
+			t.Error("expected fresh item to be held")
+		}
+	}()
+
+	select {
+	case <-done:
+	case <-time.After(time.Second * 5):
+		close(release)
+		t.Fatal("expected fetch durations and has not to wait for in-flight fetch")
+	}
+
+	close(release)
+	if err := <-fetched; err != nil {
+		t.Fatal(err)
+	}
+}
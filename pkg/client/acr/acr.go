@@ -7,7 +7,6 @@ import (
 	"fmt"
 	"io/ioutil"
 	"net/http"
-	"sync"
 	"time"
 
 	"github.com/Azure/go-autorest/autorest"
@@ -15,23 +14,25 @@ import (
 	"github.com/dgrijalva/jwt-go"
 
 	"github.com/jetstack/version-checker/pkg/api"
+	clienterrors "github.com/jetstack/version-checker/pkg/client/errors"
 	"github.com/jetstack/version-checker/pkg/client/util"
 )
 
 const (
 	userAgent = "jetstack/version-checker"
+
+	// acrTokenScope is the scope of access tokens requested from the registry.
+	acrTokenScope = "repository:*:*"
 )
 
 type Client struct {
 	*http.Client
 	Options
 
-	cacheMu         sync.Mutex
-	cachedACRClient map[string]*acrClient
+	tokens *util.TokenCache
 }
 
 type acrClient struct {
-	tokenExpiry time.Time
 	*autorest.Client
 }
 
@@ -55,7 +56,8 @@ type ACRManifestResponse struct {
 
 func New(opts Options) (*Client, error) {
 	client := &http.Client{
-		Timeout: time.Second * 5,
+		Timeout:       time.Second * 5,
+		CheckRedirect: util.CheckRedirect,
 	}
 
 	if len(opts.RefreshToken) > 0 &&
@@ -64,9 +66,9 @@ func New(opts Options) (*Client, error) {
 	}
 
 	return &Client{
-		Options:         opts,
-		Client:          client,
-		cachedACRClient: make(map[string]*acrClient),
+		Options: opts,
+		Client:  client,
+		tokens:  util.NewTokenCache(),
 	}, nil
 }
 
@@ -85,10 +87,14 @@ func (c *Client) Tags(ctx context.Context, host, repo, image string) ([]api.Imag
 		return nil, err
 	}
 
+	body, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+
 	var manifestResp ACRManifestResponse
-	if err := json.NewDecoder(resp.Body).Decode(&manifestResp); err != nil {
-		return nil, fmt.Errorf("%s: failed to decode manifest response: %s",
-			host, err)
+	if err := json.Unmarshal(body, &manifestResp); err != nil {
+		return nil, clienterrors.NewErrorDecode(host, resp.StatusCode, body, err)
 	}
 
 	var tags []api.ImageTag
@@ -151,30 +157,11 @@ func (c *Client) getManifestsWithClient(ctx context.Context, client *acrClient,
 }
 
 func (c *Client) getACRClient(ctx context.Context, host string) (*acrClient, error) {
-	c.cacheMu.Lock()
-	defer c.cacheMu.Unlock()
-
-	if client, ok := c.cachedACRClient[host]; ok && time.Now().After(client.tokenExpiry) {
-		return client, nil
-	}
-
-	var (
-		client *acrClient
-		err    error
-	)
-
 	if len(c.RefreshToken) > 0 {
-		client, err = c.getAccessTokenClient(ctx, host)
-	} else {
-		client, err = c.getBasicAuthClient(host)
-	}
-	if err != nil {
-		return nil, err
+		return c.getAccessTokenClient(ctx, host)
 	}
 
-	c.cachedACRClient[host] = client
-
-	return client, nil
+	return c.getBasicAuthClient(host)
 }
 
 func (c *Client) getBasicAuthClient(host string) (*acrClient, error) {
@@ -182,12 +169,35 @@ func (c *Client) getBasicAuthClient(host string) (*acrClient, error) {
 	client.Authorizer = autorest.NewBasicAuthorizer(c.Username, c.Password)
 
 	return &acrClient{
-		Client:      &client,
-		tokenExpiry: time.Unix(1<<63-1, 0),
+		Client: &client,
 	}, nil
 }
 
+// getAccessTokenClient will return a client authorized with an access token
+// for the given host, exchanging the refresh token for a new access token if
+// the cached token is not set or is due to expire.
 func (c *Client) getAccessTokenClient(ctx context.Context, host string) (*acrClient, error) {
+	accessToken, err := c.tokens.Get(ctx, host, acrTokenScope, func(ctx context.Context) (string, time.Time, error) {
+		return c.requestAccessToken(ctx, host)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	client := autorest.NewClientWithUserAgent(userAgent)
+	client.Authorizer = autorest.NewBearerAuthorizer(&adal.Token{
+		RefreshToken: c.RefreshToken,
+		AccessToken:  accessToken,
+	})
+
+	return &acrClient{
+		Client: &client,
+	}, nil
+}
+
+// requestAccessToken will exchange the refresh token for a new access token
+// for the given host, returning the token and its expiry.
+func (c *Client) requestAccessToken(ctx context.Context, host string) (string, time.Time, error) {
 	client := autorest.NewClientWithUserAgent(userAgent)
 	urlParameters := map[string]interface{}{
 		"url": "https://" + host,
@@ -196,7 +206,7 @@ func (c *Client) getAccessTokenClient(ctx context.Context, host string) (*acrCli
 	formDataParameters := map[string]interface{}{
 		"grant_type":    "refresh_token",
 		"refresh_token": c.RefreshToken,
-		"scope":         "repository:*:*",
+		"scope":         acrTokenScope,
 		"service":       host,
 	}
 
@@ -207,38 +217,28 @@ func (c *Client) getAccessTokenClient(ctx context.Context, host string) (*acrCli
 		autorest.WithFormData(autorest.MapToValues(formDataParameters)))
 	req, err := preparer.Prepare((&http.Request{}).WithContext(ctx))
 	if err != nil {
-		return nil, err
+		return "", time.Time{}, err
 	}
 
 	resp, err := autorest.SendWithSender(client, req,
 		autorest.DoRetryForStatusCodes(client.RetryAttempts, client.RetryDuration, autorest.StatusCodesForRetry...))
 	if err != nil {
-		return nil, fmt.Errorf("%s: failed to request access token: %s",
+		return "", time.Time{}, fmt.Errorf("%s: failed to request access token: %s",
 			host, err)
 	}
 
 	var respToken ACRAccessTokenResponse
 	if err := json.NewDecoder(resp.Body).Decode(&respToken); err != nil {
-		return nil, fmt.Errorf("%s: failed to decode access token response: %s",
+		return "", time.Time{}, fmt.Errorf("%s: failed to decode access token response: %s",
 			host, err)
 	}
 
 	exp, err := getTokenExpiration(respToken.AccessToken)
 	if err != nil {
-		return nil, fmt.Errorf("%s: %s", host, err)
-	}
-
-	token := &adal.Token{
-		RefreshToken: c.RefreshToken,
-		AccessToken:  respToken.AccessToken,
+		return "", time.Time{}, fmt.Errorf("%s: %s", host, err)
 	}
 
-	client.Authorizer = autorest.NewBearerAuthorizer(token)
-
-	return &acrClient{
-		tokenExpiry: exp,
-		Client:      &client,
-	}, nil
+	return respToken.AccessToken, exp, nil
 }
 
 func getTokenExpiration(token string) (time.Time, error) {
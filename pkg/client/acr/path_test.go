@@ -27,6 +27,10 @@ func TestIsHost(t *testing.T) {
 			host:  "versionchecker.azurecr.io",
 			expIs: true,
 		},
+		"azurecr.io in mixed case with a port should be true": {
+			host:  "VersionChecker.azurecr.IO:443",
+			expIs: true,
+		},
 		"foodazurecr.io should be false": {
 			host:  "fooazurecr.io",
 			expIs: false,
@@ -2,8 +2,12 @@ package client
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"net/http"
 	"strings"
+	"sync"
+	"time"
 
 	"github.com/sirupsen/logrus"
 
@@ -11,9 +15,12 @@ import (
 	"github.com/jetstack/version-checker/pkg/client/acr"
 	"github.com/jetstack/version-checker/pkg/client/docker"
 	"github.com/jetstack/version-checker/pkg/client/ecr"
+	clienterrors "github.com/jetstack/version-checker/pkg/client/errors"
 	"github.com/jetstack/version-checker/pkg/client/gcr"
+	"github.com/jetstack/version-checker/pkg/client/icr"
 	"github.com/jetstack/version-checker/pkg/client/quay"
 	"github.com/jetstack/version-checker/pkg/client/selfhosted"
+	"github.com/jetstack/version-checker/pkg/client/util"
 )
 
 // ImageClient represents a image registry client that can list available tags
@@ -35,35 +42,298 @@ type ImageClient interface {
 	Tags(ctx context.Context, host, repo, image string) ([]api.ImageTag, error)
 }
 
+// ManifestClient is an ImageClient which is also able to fetch the manifest
+// of an image reference.
+type ManifestClient interface {
+	ImageClient
+
+	// Manifest will return the manifest of the given host, repo, image and
+	// reference, which is either a tag or digest.
+	Manifest(ctx context.Context, host, repo, image, reference string) (*api.ImageManifest, error)
+}
+
+// ConfigClient is an ImageClient which is also able to fetch the image config
+// of an image reference.
+type ConfigClient interface {
+	ImageClient
+
+	// Config will return the image config of the given host, repo, image and
+	// reference, which is either a tag or digest.
+	Config(ctx context.Context, host, repo, image, reference string) (*api.ImageConfig, error)
+}
+
+// PagedTagsClient is an ImageClient which is also able to list tags in the
+// pages returned by the registry, so that tags may be consumed as they arrive
+// rather than buffered in full.
+type PagedTagsClient interface {
+	ImageClient
+
+	// TagPages will call page with each page of tags of the given host, repo
+	// and image, in the order returned by the registry, until page returns an
+	// error or all tags have been listed. Any error of page is returned.
+	TagPages(ctx context.Context, host, repo, image string, page func([]api.ImageTag) error) error
+}
+
+// SortedTagsClient is an ImageClient which is also able to list tags in pages
+// sorted by descending semantic version, so that listing can stop early once
+// the latest version has been found.
+type SortedTagsClient interface {
+	ImageClient
+
+	// SortedTags will call page with each page of tags of the given host, repo
+	// and image, sorted by descending semantic version, until page returns
+	// false or all tags have been listed.
+	SortedTags(ctx context.Context, host, repo, image string, page func([]api.ImageTag) bool) error
+}
+
+// ValidatingClient is an ImageClient which is also able to cheaply check
+// that the tags of an image may be listed, without listing them.
+type ValidatingClient interface {
+	ImageClient
+
+	// Validate will return an error if the tags of the given host, repo and
+	// image cannot be listed, as a clienterrors.ErrorUnauthorized,
+	// clienterrors.ErrorImageNotFound or clienterrors.ErrorNetwork where
+	// known.
+	Validate(ctx context.Context, host, repo, image string) error
+}
+
 // Client is a container image registry client to list tags of given image
 // URLs.
 type Client struct {
 	clients        []ImageClient
 	fallbackClient ImageClient
+	mirrors        mirrors
+	credentials    map[string]*api.Credentials
+	timeouts       map[string]time.Duration
+
+	// mu guards the registered clients, along with the classifications of
+	// hosts and the hosts found to be unsupported, which are reset when a
+	// client is registered.
+	mu              sync.RWMutex
+	classifications map[string]hostClassification
+	unsupported     map[string]unsupportedHost
+	unsupportedTTL  time.Duration
+	now             func() time.Time
+
+	requireClientMatch bool
 }
 
-// Options used to configure client authentication.
+// Options used to configure client authentication and behaviour.
 type Options struct {
+	// RequireClientMatch will cause image URLs whose host is not explicitly
+	// matched by a registry client to error, rather than falling back to the
+	// generic Docker V2 API client.
+	RequireClientMatch bool
+
+	// PageSize is the number of tags requested per page from registries which
+	// support it, unless overridden by the registry's options. Each registry
+	// caps the page size at its maximum, and uses its own default if zero.
+	PageSize int
+
+	// CABundles are PEM encoded CA bundles keyed by registry host, optionally
+	// including the port. Requests to each host trust only its bundle, where
+	// hosts without a bundle trust the system pool. Not used by the ACR and ECR
+	// clients, whose requests are made by their SDKs.
+	CABundles map[string][]byte
+
+	// Mirrors are pull-through mirror prefixes, in the form host[/path], keyed
+	// by the registry host they mirror. Images of a mirrored host are fetched
+	// from the mirror, with the mirror's path prefixed to the repository,
+	// where Docker Hub images without a host are mirrored by "docker.io".
+	// Image URLs referencing a mirror are resolved as their logical image.
+	// e.g. docker.io=mirror.internal/docker.io fetches docker.io/library/nginx
+	//      from mirror.internal/docker.io/library/nginx
+	Mirrors map[string]string
+
+	// HostCredentials are registry credentials keyed by registry host, used
+	// for lookups whose context carries no credentials, so that existing
+	// image pull secrets may be reused. Docker Hub's credentials are keyed by
+	// "docker.io". As with context credentials, these are not used by the ACR
+	// and ECR clients, and the Docker Hub and GCR clients only use tokens.
+	// Registered from pull secrets with AddDockerConfigJSON.
+	HostCredentials map[string]*api.Credentials
+
+	// HostTimeouts are timeouts keyed by registry host, as HostCredentials,
+	// so that slow registries may be given longer than fast ones. Listing the
+	// tags of an image, and each request to the host, is cancelled once its
+	// host's timeout has elapsed, in place of the default timeout of each
+	// client's requests, in addition to any deadline of the context. Hosts
+	// without a timeout use the clients' defaults. Request timeouts are not
+	// used by the ACR and ECR clients.
+	// e.g. docker.io=10s, registry.internal=2m
+	HostTimeouts map[string]time.Duration
+
+	// HostBasePaths are the paths registry APIs are mounted under, keyed by
+	// registry host as HostCredentials, for registries served under a sub-path
+	// such as by a proxy. Only used by the self-hosted clients, and the
+	// generic Docker V2 API client, where a self-hosted client's BasePath is
+	// overridden.
+	// e.g. registry.internal=/registry requests tags from
+	//      registry.internal/registry/v2/{repo/image}/tags/list
+	HostBasePaths map[string]string
+
+	// UnsupportedHostTTL is how long lookups of a registry host, not matched
+	// by any registry client, fail fast with a
+	// clienterrors.ErrorUnsupportedHost once the fallback client found the
+	// host not to serve the registry API, such as a web server responding
+	// with HTML, or a host which does not exist. Transient failures, and
+	// those of missing images or auth, are never cached. Disabled if zero.
+	UnsupportedHostTTL time.Duration
+
+	// MaxRetries is the number of times a registry request is retried, when
+	// the RetryPredicate decides the request may succeed if retried. Retries
+	// wait for the RetryBackoff, doubling after each retry, which defaults to
+	// 200ms if zero. Retries are bounded by the timeout of each client's
+	// requests. Not used by the ACR and ECR clients. Disabled if zero.
+	MaxRetries   int
+	RetryBackoff time.Duration
+
+	// MaxResponseBytes is the maximum size in bytes of registry response
+	// bodies, guarding against huge responses of broken or malicious
+	// registries. Larger responses fail with a
+	// clienterrors.ErrorResponseTooLarge. Not used by the ACR and ECR clients.
+	// Unlimited if zero.
+	MaxResponseBytes int64
+
+	// RetryPredicate decides whether a registry request should be retried,
+	// given its response or error, such as to retry registry specific
+	// statuses. Defaults to util.DefaultRetryPredicate if nil.
+	RetryPredicate util.RetryPredicate
+
+	// ErrorResponseFunc is called with the host and status code of each error
+	// response, with a 4xx or 5xx status, of registry requests, including
+	// each attempt of retried requests, such as to count responses by status
+	// code. Not used by the ACR and ECR clients. Disabled if nil.
+	ErrorResponseFunc util.StatusFunc
+
+	// RequestIDContextKey is the context key of the request ID of lookups,
+	// whose string value is set as the RequestIDHeader of each registry
+	// request made with the context, such as to correlate registry requests
+	// with upstream request logs. Not used by the ACR and ECR clients.
+	// Disabled if nil.
+	RequestIDContextKey interface{}
+
+	// RequestIDHeader is the header the request ID is set as. Defaults to
+	// util.DefaultRequestIDHeader if empty.
+	RequestIDHeader string
+
 	ACR        acr.Options
 	ECR        ecr.Options
 	GCR        gcr.Options
+	ICR        icr.Options
 	Docker     docker.Options
 	Quay       quay.Options
 	Selfhosted map[string]*selfhosted.Options
 }
 
+// Secrets returns all credentials configured in the options, so that they may
+// be redacted from logs.
+func (o Options) Secrets() []string {
+	secrets := []string{
+		o.ACR.Password, o.ACR.RefreshToken,
+		o.ECR.SecretAccessKey, o.ECR.SessionToken,
+		o.GCR.Token,
+		o.ICR.APIKey,
+		o.Docker.Password, o.Docker.Token,
+		o.Quay.Token,
+	}
+
+	for _, sOpts := range o.Selfhosted {
+		secrets = append(secrets, sOpts.Password, sOpts.Bearer, sOpts.ProxyPassword)
+	}
+
+	for _, creds := range o.HostCredentials {
+		secrets = append(secrets, creds.Password, creds.Token)
+	}
+
+	var nonEmpty []string
+	for _, secret := range secrets {
+		if len(secret) > 0 {
+			nonEmpty = append(nonEmpty, secret)
+		}
+	}
+
+	return nonEmpty
+}
+
+// wrapTransport returns the given transport of registry requests, wrapped so
+// that network errors are classified, response bodies are limited, request
+// IDs are set, error responses are reported, and requests are retried if
+// enabled.
+func (o Options) wrapTransport(transport http.RoundTripper) http.RoundTripper {
+	// Network errors of all requests are classified, so that it is known
+	// whether failures may succeed if retried.
+	transport = util.NewNetworkErrorTransport(transport)
+	if o.MaxResponseBytes > 0 {
+		transport = util.NewMaxBodyTransport(transport, o.MaxResponseBytes)
+	}
+	if o.RequestIDContextKey != nil {
+		transport = util.NewRequestIDTransport(transport, o.RequestIDContextKey, o.RequestIDHeader)
+	}
+	if o.ErrorResponseFunc != nil {
+		transport = util.NewStatusTransport(transport, o.ErrorResponseFunc)
+	}
+	if o.MaxRetries > 0 {
+		transport = util.NewRetryTransport(transport, o.RetryPredicate, o.MaxRetries, o.RetryBackoff)
+	}
+
+	return transport
+}
+
 func New(ctx context.Context, log *logrus.Entry, opts Options) (*Client, error) {
+	var transport http.RoundTripper
+	if len(opts.CABundles) > 0 {
+		caTransport, err := util.NewCATransport(opts.CABundles)
+		if err != nil {
+			return nil, fmt.Errorf("failed to load registry CA bundles: %s", err)
+		}
+		transport = caTransport
+	}
+	transport = opts.wrapTransport(transport)
+
+	basePaths := make(map[string]string, len(opts.HostBasePaths))
+	for host, basePath := range opts.HostBasePaths {
+		basePaths[credentialsHost(host)] = basePath
+	}
+
+	mirrors, err := newMirrors(opts.Mirrors)
+	if err != nil {
+		return nil, err
+	}
+
 	acrClient, err := acr.New(opts.ACR)
 	if err != nil {
 		return nil, fmt.Errorf("failed to create acr client: %s", err)
 	}
+	if opts.Docker.PageSize == 0 {
+		opts.Docker.PageSize = opts.PageSize
+	}
 	dockerClient, err := docker.New(ctx, opts.Docker)
 	if err != nil {
 		return nil, fmt.Errorf("failed to create docker client: %s", err)
 	}
 
-	var selfhostedClients []ImageClient
+	var (
+		selfhostedClients []ImageClient
+		httpClients       []*http.Client
+	)
 	for _, sOpts := range opts.Selfhosted {
+		// Copy the options so that defaults are not set on those configured.
+		withDefaults := *sOpts
+		if withDefaults.PageSize == 0 {
+			withDefaults.PageSize = opts.PageSize
+		}
+		if withDefaults.Transport == nil {
+			withDefaults.Transport = transport
+		} else {
+			withDefaults.Transport = opts.wrapTransport(withDefaults.Transport)
+		}
+		if withDefaults.HostBasePaths == nil {
+			withDefaults.HostBasePaths = basePaths
+		}
+		sOpts = &withDefaults
+
 		sClient, err := selfhosted.New(ctx, log, sOpts)
 		if err != nil {
 			return nil, fmt.Errorf("failed to create selfhosted client %q: %s",
@@ -71,61 +341,357 @@ func New(ctx context.Context, log *logrus.Entry, opts Options) (*Client, error)
 		}
 
 		selfhostedClients = append(selfhostedClients, sClient)
+		httpClients = append(httpClients, sClient.Client)
 	}
 
-	fallbackClient, err := selfhosted.New(ctx, log, new(selfhosted.Options))
+	fallbackClient, err := selfhosted.New(ctx, log, &selfhosted.Options{
+		PageSize:      opts.PageSize,
+		Transport:     transport,
+		HostBasePaths: basePaths,
+	})
 	if err != nil {
 		return nil, fmt.Errorf("failed to create fallback client: %s", err)
 	}
 
+	gcrClient, icrClient, quayClient := gcr.New(opts.GCR), icr.New(opts.ICR), quay.New(opts.Quay)
+	for _, httpClient := range []*http.Client{
+		dockerClient.Client, gcrClient.Client, icrClient.Client, quayClient.Client,
+	} {
+		httpClient.Transport = transport
+	}
+	httpClients = append(httpClients, fallbackClient.Client, gcrClient.Client, icrClient.Client, quayClient.Client)
+
 	c := &Client{
 		clients: append(
 			selfhostedClients,
 			acrClient,
 			ecr.New(opts.ECR),
 			dockerClient,
-			gcr.New(opts.GCR),
-			quay.New(opts.Quay),
+			gcrClient,
+			icrClient,
+			quayClient,
 		),
-		fallbackClient: fallbackClient,
+		fallbackClient:     fallbackClient,
+		mirrors:            mirrors,
+		credentials:        make(map[string]*api.Credentials, len(opts.HostCredentials)),
+		timeouts:           make(map[string]time.Duration, len(opts.HostTimeouts)),
+		classifications:    make(map[string]hostClassification),
+		unsupported:        make(map[string]unsupportedHost),
+		unsupportedTTL:     opts.UnsupportedHostTTL,
+		now:                time.Now,
+		requireClientMatch: opts.RequireClientMatch,
+	}
+
+	for host, creds := range opts.HostCredentials {
+		c.credentials[credentialsHost(host)] = creds
+	}
+	for host, timeout := range opts.HostTimeouts {
+		c.timeouts[credentialsHost(host)] = timeout
+	}
+
+	if len(c.timeouts) > 0 {
+		for _, httpClient := range httpClients {
+			c.setHostTimeouts(httpClient, func(req *http.Request) string {
+				return req.URL.Host
+			})
+		}
+		// The Docker Hub client only makes requests of Docker Hub, whose API
+		// host differs from that of its images.
+		c.setHostTimeouts(dockerClient.Client, func(*http.Request) string {
+			return dockerHubHost
+		})
 	}
 
 	for _, client := range append(c.clients, fallbackClient) {
 		log.Debugf("registered client %q", client.Name())
 	}
+	for _, rule := range mirrors {
+		log.Debugf("registered mirror %q for host %q", rule.prefix(), rule.host)
+	}
 
 	return c, nil
 }
 
+// withTimeout returns the given context with the timeout of the given host
+// set, if configured, along with the func cancelling it.
+func (c *Client) withTimeout(ctx context.Context, host string) (context.Context, context.CancelFunc) {
+	if timeout, ok := c.timeouts[credentialsHost(host)]; ok && timeout > 0 {
+		return context.WithTimeout(ctx, timeout)
+	}
+
+	return ctx, func() {}
+}
+
+// setHostTimeouts will replace the timeout of the given HTTP client with the
+// timeout of the host of each request, as returned by the given func, where
+// requests of hosts without a timeout keep the client's timeout.
+func (c *Client) setHostTimeouts(httpClient *http.Client, hostFunc func(req *http.Request) string) {
+	defaultTimeout := httpClient.Timeout
+	httpClient.Timeout = 0
+	httpClient.Transport = util.NewTimeoutTransport(httpClient.Transport, func(req *http.Request) time.Duration {
+		if timeout, ok := c.timeouts[credentialsHost(hostFunc(req))]; ok && timeout > 0 {
+			return timeout
+		}
+
+		return defaultTimeout
+	})
+}
+
 // Tags returns the full list of image tags available, for a given image URL.
 func (c *Client) Tags(ctx context.Context, imageURL string) ([]api.ImageTag, error) {
-	client, host, path := c.fromImageURL(imageURL)
+	client, host, repo, image, err := c.resolve(imageURL)
+	if err != nil {
+		return nil, err
+	}
+
+	ctx, cancel := c.withTimeout(ctx, host)
+	defer cancel()
+
+	tags, err := client.Tags(c.withCredentials(ctx, host), host, repo, image)
+	if err != nil {
+		return nil, c.checkUnsupported(client, host, err)
+	}
+
+	return tags, nil
+}
+
+// TagPages will list the tags of the given image URL, calling page with each
+// page of tags as it is returned by the registry, until page returns an
+// error, which is returned. Tags of registry clients which do not support
+// paged listing are listed in full as a single page.
+func (c *Client) TagPages(ctx context.Context, imageURL string, page func([]api.ImageTag) error) error {
+	client, host, repo, image, err := c.resolve(imageURL)
+	if err != nil {
+		return err
+	}
+
+	ctx, cancel := c.withTimeout(ctx, host)
+	defer cancel()
+	ctx = c.withCredentials(ctx, host)
+
+	if pagedClient, ok := client.(PagedTagsClient); ok {
+		return c.checkUnsupported(client, host, pagedClient.TagPages(ctx, host, repo, image, page))
+	}
+
+	tags, err := client.Tags(ctx, host, repo, image)
+	if err != nil {
+		return c.checkUnsupported(client, host, err)
+	}
+
+	return page(tags)
+}
+
+// errStopValidate stops listing the tags of an image being validated, once
+// the first page has been listed.
+var errStopValidate = errors.New("validated")
+
+// Validate will check that the given image URL is matched by a registry
+// client, and that its tags may be listed, without listing them in full.
+// Registry clients which cannot validate cheaply instead list the first page
+// of tags, which for those without paged listing is all tags. Returns a
+// clienterrors.ErrorNoClientMatch if no client matched the image's host and
+// matches are required, along with the errors of ValidatingClient.
+func (c *Client) Validate(ctx context.Context, imageURL string) error {
+	client, host, repo, image, err := c.resolve(imageURL)
+	if err != nil {
+		return err
+	}
+
+	ctx, cancel := c.withTimeout(ctx, host)
+	defer cancel()
+	ctx = c.withCredentials(ctx, host)
+
+	if validatingClient, ok := client.(ValidatingClient); ok {
+		return c.checkUnsupported(client, host, validatingClient.Validate(ctx, host, repo, image))
+	}
+
+	if pagedClient, ok := client.(PagedTagsClient); ok {
+		err := pagedClient.TagPages(ctx, host, repo, image, func([]api.ImageTag) error {
+			return errStopValidate
+		})
+		if errors.Is(err, errStopValidate) {
+			return nil
+		}
+		return c.checkUnsupported(client, host, err)
+	}
+
+	_, err = client.Tags(ctx, host, repo, image)
+	return c.checkUnsupported(client, host, err)
+}
+
+// SortedTags will list the tags of the given image URL in pages sorted by
+// descending semantic version, calling page with each until it returns false.
+// Returns false if the image's registry client does not support sorted
+// listing, in which case page is never called.
+func (c *Client) SortedTags(ctx context.Context, imageURL string, page func([]api.ImageTag) bool) (bool, error) {
+	client, host, repo, image, err := c.resolve(imageURL)
+	if err != nil {
+		return false, err
+	}
+
+	sortedClient, ok := client.(SortedTagsClient)
+	if !ok {
+		return false, nil
+	}
+
+	ctx, cancel := c.withTimeout(ctx, host)
+	defer cancel()
+
+	return true, c.checkUnsupported(client, host,
+		sortedClient.SortedTags(c.withCredentials(ctx, host), host, repo, image, page))
+}
+
+// Manifest returns the manifest of the given reference, which is either a tag
+// or digest, for a given image URL.
+func (c *Client) Manifest(ctx context.Context, imageURL, reference string) (*api.ImageManifest, error) {
+	client, host, repo, image, err := c.resolve(imageURL)
+	if err != nil {
+		return nil, err
+	}
+
+	manifestClient, ok := client.(ManifestClient)
+	if !ok {
+		return nil, fmt.Errorf("registry client %q does not support fetching manifests",
+			client.Name())
+	}
+
+	ctx, cancel := c.withTimeout(ctx, host)
+	defer cancel()
+
+	manifest, err := manifestClient.Manifest(c.withCredentials(ctx, host), host, repo, image, reference)
+	if err != nil {
+		return nil, c.checkUnsupported(client, host, err)
+	}
+
+	return manifest, nil
+}
+
+// Config returns the image config of the given reference, which is either a
+// tag or digest, for a given image URL.
+func (c *Client) Config(ctx context.Context, imageURL, reference string) (*api.ImageConfig, error) {
+	client, host, repo, image, err := c.resolve(imageURL)
+	if err != nil {
+		return nil, err
+	}
+
+	configClient, ok := client.(ConfigClient)
+	if !ok {
+		return nil, fmt.Errorf("registry client %q does not support fetching image configs",
+			client.Name())
+	}
+
+	ctx, cancel := c.withTimeout(ctx, host)
+	defer cancel()
+
+	config, err := configClient.Config(c.withCredentials(ctx, host), host, repo, image, reference)
+	if err != nil {
+		return nil, c.checkUnsupported(client, host, err)
+	}
+
+	return config, nil
+}
+
+// ClientName returns the name of the registry client which would handle the
+// given image URL, without performing any requests. Image URLs which are not
+// matched by any client return the name of the fallback client. Image URLs of
+// a mirrored host return the name of the mirror's client.
+func (c *Client) ClientName(imageURL string) string {
+	client, _, _, _, _ := c.resolve(imageURL)
+	return client.Name()
+}
+
+// OriginalImageURL returns the logical image URL of an image URL referencing
+// a mirror, or the image URL unchanged if it does not reference a mirror.
+// e.g. mirror.internal/docker.io/library/nginx -> docker.io/library/nginx
+func (c *Client) OriginalImageURL(imageURL string) string {
+	return c.mirrors.original(imageURL)
+}
+
+// resolve will return the registry client, host, repo and image to request
+// for the given image URL. Image URLs of a mirrored host are rewritten onto
+// the mirror, where the repo and image are split as by the mirrored host's
+// client. Returns an error if no client explicitly matched the host to
+// request, and matches are required.
+func (c *Client) resolve(imageURL string) (ImageClient, string, string, string, error) {
+	client, host, path, matched := c.fromImageURL(c.mirrors.original(imageURL))
 	repo, image := client.RepoImageFromPath(path)
-	return client.Tags(ctx, host, repo, image)
+
+	if rule, ok := c.mirrors.forHost(host); ok {
+		host = rule.mirrorHost
+		repo = util.JoinRepoImage(rule.mirrorPath, repo)
+		client, matched = c.fromHost(host)
+	}
+
+	if !matched && c.requireClientMatch {
+		return client, host, repo, image, clienterrors.NewErrorNoClientMatch(host)
+	}
+	if !matched {
+		if err := c.unsupportedHostErr(host); err != nil {
+			return client, host, repo, image, err
+		}
+	}
+
+	return client, host, repo, image, nil
 }
 
 // fromImageURL will return the appropriate registry client for a given
-// image URL, and the host + path to search
-func (c *Client) fromImageURL(imageURL string) (ImageClient, string, string) {
-	var host, path string
-
-	if strings.Contains(imageURL, ".") || strings.Contains(imageURL, ":") {
-		split := strings.SplitN(imageURL, "/", 2)
-		if len(split) < 2 {
-			path = imageURL
-		} else {
-			host, path = split[0], split[1]
-		}
-	} else {
-		path = imageURL
+// image URL, and the host + path to search. Returns false if no client
+// explicitly matched the host, and the fallback client is used.
+func (c *Client) fromImageURL(imageURL string) (ImageClient, string, string, bool) {
+	host, path := splitImageURL(imageURL)
+	client, matched := c.fromHost(host)
+	return client, host, path, matched
+}
+
+// fromHost will return the appropriate registry client for a given host.
+// Returns false if no client explicitly matched the host, and the fallback
+// client is used. The classification of each host is cached, until a client
+// is registered.
+func (c *Client) fromHost(host string) (ImageClient, bool) {
+	c.mu.RLock()
+	class, ok := c.classifications[host]
+	c.mu.RUnlock()
+	if ok {
+		return class.client, class.matched
 	}
 
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	// fall back to docker with no path split
+	class = hostClassification{client: c.fallbackClient}
 	for _, client := range c.clients {
 		if client.IsHost(host) {
-			return client, host, path
+			class = hostClassification{client: client, matched: true}
+			break
 		}
 	}
 
-	// fall back to docker with no path split
-	return c.fallbackClient, host, path
+	c.classifications[host] = class
+
+	return class.client, class.matched
+}
+
+// HostFromImageURL returns the registry host of the given image URL. Returns
+// an empty string if the image URL contains no host.
+func HostFromImageURL(imageURL string) string {
+	host, _ := splitImageURL(imageURL)
+	return host
+}
+
+// splitImageURL will split the given image URL into its host and path. As with
+// docker, the first component is only a host if it contains a '.' or ':', or
+// is localhost, so that repository paths may contain dots and underscores.
+// e.g. my_org/my.app -> "", my_org/my.app
+func splitImageURL(imageURL string) (string, string) {
+	split := strings.SplitN(imageURL, "/", 2)
+	if len(split) < 2 {
+		return "", imageURL
+	}
+
+	if strings.ContainsAny(split[0], ".:") || split[0] == "localhost" {
+		return split[0], split[1]
+	}
+
+	return "", imageURL
 }
@@ -2,17 +2,35 @@ package client
 
 import (
 	"context"
+	"encoding/base64"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
 	"reflect"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"syscall"
 	"testing"
+	"time"
 
 	"github.com/sirupsen/logrus"
 
+	"github.com/jetstack/version-checker/pkg/api"
 	"github.com/jetstack/version-checker/pkg/client/acr"
 	"github.com/jetstack/version-checker/pkg/client/docker"
 	"github.com/jetstack/version-checker/pkg/client/ecr"
+	clienterrors "github.com/jetstack/version-checker/pkg/client/errors"
 	"github.com/jetstack/version-checker/pkg/client/gcr"
+	"github.com/jetstack/version-checker/pkg/client/icr"
 	"github.com/jetstack/version-checker/pkg/client/quay"
 	"github.com/jetstack/version-checker/pkg/client/selfhosted"
+	"github.com/jetstack/version-checker/pkg/client/util"
 )
 
 func TestFromImageURL(t *testing.T) {
@@ -32,54 +50,63 @@ func TestFromImageURL(t *testing.T) {
 		expClient ImageClient
 		expHost   string
 		expPath   string
+		expMatch  bool
 	}{
 		"an empty image URL should be selfhosted": {
 			url:       "",
 			expClient: new(docker.Client),
 			expHost:   "",
 			expPath:   "",
+			expMatch:  true,
 		},
 		"single name should be docker": {
 			url:       "nginx",
 			expClient: new(docker.Client),
 			expHost:   "",
 			expPath:   "nginx",
+			expMatch:  true,
 		},
 		"two names should be docker": {
 			url:       "joshvanl/version-checker",
 			expClient: new(docker.Client),
 			expHost:   "",
 			expPath:   "joshvanl/version-checker",
+			expMatch:  true,
 		},
 		"three names should be docker": {
 			url:       "jetstack/joshvanl/version-checker",
 			expClient: new(docker.Client),
 			expHost:   "",
 			expPath:   "jetstack/joshvanl/version-checker",
+			expMatch:  true,
 		},
 		"docker.com should be docker": {
 			url:       "docker.com/joshvanl/version-checker",
 			expClient: new(docker.Client),
 			expHost:   "docker.com",
 			expPath:   "joshvanl/version-checker",
+			expMatch:  true,
 		},
 		"docker.io should be docker": {
 			url:       "docker.io/joshvanl/version-checker",
 			expClient: new(docker.Client),
 			expHost:   "docker.io",
 			expPath:   "joshvanl/version-checker",
+			expMatch:  true,
 		},
 		"docker.com with sub should be docker": {
 			url:       "foo.docker.com/joshvanl/version-checker",
 			expClient: new(docker.Client),
 			expHost:   "foo.docker.com",
 			expPath:   "joshvanl/version-checker",
+			expMatch:  true,
 		},
 		"docker.io with sub should be docker": {
 			url:       "bar.docker.io/registry/joshvanl/version-checker",
 			expClient: new(docker.Client),
 			expHost:   "bar.docker.io",
 			expPath:   "registry/joshvanl/version-checker",
+			expMatch:  true,
 		},
 
 		"versionchecker.azurecr.io should be acr": {
@@ -87,12 +114,14 @@ func TestFromImageURL(t *testing.T) {
 			expClient: new(acr.Client),
 			expHost:   "versionchecker.azurecr.io",
 			expPath:   "jetstack-cre/version-checker",
+			expMatch:  true,
 		},
 		"versionchecker.azurecr.io with single path should be acr": {
 			url:       "versionchecker.azurecr.io/version-checker",
 			expClient: new(acr.Client),
 			expHost:   "versionchecker.azurecr.io",
 			expPath:   "version-checker",
+			expMatch:  true,
 		},
 
 		"123.dkr.foo.amazon.com should be ecr": {
@@ -100,12 +129,14 @@ func TestFromImageURL(t *testing.T) {
 			expClient: new(ecr.Client),
 			expHost:   "123.dkr.ecr.foo.amazonaws.com",
 			expPath:   "version-checker",
+			expMatch:  true,
 		},
 		"hello.dkr.eu-west-1.amazon.com.cn should be ecr": {
 			url:       "hello.dkr.ecr.eu-west-1.amazonaws.com.cn/jetstack/joshvanl/version-checker",
 			expClient: new(ecr.Client),
 			expHost:   "hello.dkr.ecr.eu-west-1.amazonaws.com.cn",
 			expPath:   "jetstack/joshvanl/version-checker",
+			expMatch:  true,
 		},
 
 		"gcr.io should be gcr": {
@@ -113,12 +144,29 @@ func TestFromImageURL(t *testing.T) {
 			expClient: new(gcr.Client),
 			expHost:   "gcr.io",
 			expPath:   "jetstack-cre/version-checker",
+			expMatch:  true,
 		},
 		"gcr.io with subdomain should be gcr": {
 			url:       "us.gcr.io/k8s-artifacts-prod/ingress-nginx/nginx",
 			expClient: new(gcr.Client),
 			expHost:   "us.gcr.io",
 			expPath:   "k8s-artifacts-prod/ingress-nginx/nginx",
+			expMatch:  true,
+		},
+
+		"icr.io should be icr": {
+			url:       "icr.io/namespace/version-checker",
+			expClient: new(icr.Client),
+			expHost:   "icr.io",
+			expPath:   "namespace/version-checker",
+			expMatch:  true,
+		},
+		"regional icr.io should be icr": {
+			url:       "us.icr.io/namespace/version-checker",
+			expClient: new(icr.Client),
+			expHost:   "us.icr.io",
+			expPath:   "namespace/version-checker",
+			expMatch:  true,
 		},
 
 		"quay.io should be quay": {
@@ -126,18 +174,48 @@ func TestFromImageURL(t *testing.T) {
 			expClient: new(quay.Client),
 			expHost:   "quay.io",
 			expPath:   "jetstack/version-checker",
+			expMatch:  true,
 		},
 		"quay.io with subdomain should be quay": {
 			url:       "us.quay.io/k8s-artifacts-prod/ingress-nginx/nginx",
 			expClient: new(quay.Client),
 			expHost:   "us.quay.io",
 			expPath:   "k8s-artifacts-prod/ingress-nginx/nginx",
+			expMatch:  true,
+		},
+		"docker namespace with underscores and image with dots should be docker": {
+			url:       "my_org/my.app",
+			expClient: new(docker.Client),
+			expHost:   "",
+			expPath:   "my_org/my.app",
+			expMatch:  true,
+		},
+		"docker image with dots should be docker": {
+			url:       "org/sub.app",
+			expClient: new(docker.Client),
+			expHost:   "",
+			expPath:   "org/sub.app",
+			expMatch:  true,
+		},
+		"docker.io with underscores and dots should be docker": {
+			url:       "docker.io/my_org/my.app",
+			expClient: new(docker.Client),
+			expHost:   "docker.io",
+			expPath:   "my_org/my.app",
+			expMatch:  true,
+		},
+		"localhost should be a host": {
+			url:       "localhost/my_org/my.app",
+			expClient: new(selfhosted.Client),
+			expHost:   "localhost",
+			expPath:   "my_org/my.app",
 		},
 		"selfhosted should be selfhosted": {
 			url:       "docker.repositories.yourdomain.com/ingress-nginx/nginx",
 			expClient: new(selfhosted.Client),
 			expHost:   "docker.repositories.yourdomain.com",
 			expPath:   "ingress-nginx/nginx",
+			expMatch:  true,
 		},
 		"selfhosted with different domain should be fallback": {
 			url:       "registry.opensource.zalan.do/teapot/external-dns",
@@ -149,7 +227,7 @@ func TestFromImageURL(t *testing.T) {
 
 	for name, test := range tests {
 		t.Run(name, func(t *testing.T) {
-			client, host, path := handler.fromImageURL(test.url)
+			client, host, path, match := handler.fromImageURL(test.url)
 			if reflect.TypeOf(client) != reflect.TypeOf(test.expClient) {
 				t.Errorf("unexpected client, exp=%v got=%v",
 					reflect.TypeOf(test.expClient), reflect.TypeOf(client))
@@ -164,6 +242,1357 @@ func TestFromImageURL(t *testing.T) {
 				t.Errorf("unexpected path, exp=%s got=%s",
 					test.expPath, path)
 			}
+
+			if match != test.expMatch {
+				t.Errorf("unexpected match, exp=%t got=%t",
+					test.expMatch, match)
+			}
+		})
+	}
+}
+
+func TestRequireClientMatch(t *testing.T) {
+	tests := map[string]struct {
+		url                string
+		requireClientMatch bool
+		expNoMatchErr      bool
+	}{
+		"gcr.io should match": {
+			url:                "gcr.io/jetstack-cre/version-checker",
+			requireClientMatch: true,
+			expNoMatchErr:      false,
+		},
+		"quay.io should match": {
+			url:                "quay.io/jetstack/version-checker",
+			requireClientMatch: true,
+			expNoMatchErr:      false,
+		},
+		"unknown host should error when client match required": {
+			url:                "registry.opensource.zalan.do/teapot/external-dns",
+			requireClientMatch: true,
+			expNoMatchErr:      true,
+		},
+		"unknown host should not error when client match not required": {
+			url:                "registry.opensource.zalan.do/teapot/external-dns",
+			requireClientMatch: false,
+			expNoMatchErr:      false,
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			handler, err := New(context.TODO(), logrus.NewEntry(logrus.New()), Options{
+				RequireClientMatch: test.requireClientMatch,
+			})
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			// Use a cancelled context so no requests reach the registries.
+			ctx, cancel := context.WithCancel(context.TODO())
+			cancel()
+
+			_, err = handler.Tags(ctx, test.url)
+			if noMatch := clienterrors.IsNoClientMatch(err); noMatch != test.expNoMatchErr {
+				t.Errorf("unexpected no client match error, exp=%t got=%t (%v)",
+					test.expNoMatchErr, noMatch, err)
+			}
+		})
+	}
+}
+
+func TestClientName(t *testing.T) {
+	handler, err := New(context.TODO(), logrus.NewEntry(logrus.New()), Options{
+		Selfhosted: map[string]*selfhosted.Options{
+			"yourdomain": {
+				Host: "https://docker.repositories.yourdomain.com",
+			},
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := map[string]struct {
+		url     string
+		expName string
+	}{
+		"single name should be dockerhub": {
+			url:     "nginx",
+			expName: "dockerhub",
+		},
+		"docker.io should be dockerhub": {
+			url:     "docker.io/jetstack/version-checker",
+			expName: "dockerhub",
+		},
+		"gcr.io should be gcr": {
+			url:     "gcr.io/jetstack-cre/version-checker",
+			expName: "gcr",
+		},
+		"quay.io should be quay": {
+			url:     "quay.io/jetstack/version-checker",
+			expName: "quay",
+		},
+		"mixed case quay.io should be quay": {
+			url:     "Quay.IO/jetstack/version-checker",
+			expName: "quay",
+		},
+		"gcr.io with a port should be gcr": {
+			url:     "gcr.io:443/jetstack-cre/version-checker",
+			expName: "gcr",
+		},
+		"mixed case docker.io should be dockerhub": {
+			url:     "DOCKER.io/jetstack/version-checker",
+			expName: "dockerhub",
+		},
+		"mixed case selfhosted should be selfhosted host": {
+			url:     "Docker.Repositories.YourDomain.com/jetstack/version-checker",
+			expName: "https://docker.repositories.yourdomain.com",
+		},
+		"ecr should be ecr": {
+			url:     "123456789.dkr.ecr.us-east-1.amazonaws.com/version-checker",
+			expName: "ecr",
+		},
+		"acr should be acr": {
+			url:     "myregistry.azurecr.io/jetstack/version-checker",
+			expName: "acr",
+		},
+		"icr should be icr": {
+			url:     "us.icr.io/jetstack/version-checker",
+			expName: "icr",
+		},
+		"selfhosted should be selfhosted host": {
+			url:     "docker.repositories.yourdomain.com/jetstack/version-checker",
+			expName: "https://docker.repositories.yourdomain.com",
+		},
+		"unknown host should be fallback": {
+			url:     "registry.example.com/jetstack/version-checker",
+			expName: "dockerapi",
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			if clientName := handler.ClientName(test.url); clientName != test.expName {
+				t.Errorf("unexpected client name, exp=%q got=%q", test.expName, clientName)
+			}
+		})
+	}
+}
+
+func TestPageSize(t *testing.T) {
+	sOpts := &selfhosted.Options{Host: "https://docker.repositories.yourdomain.com"}
+	sOptsOverride := &selfhosted.Options{Host: "https://registry.example.com", PageSize: 1000}
+
+	c, err := New(context.TODO(), logrus.NewEntry(logrus.New()), Options{
+		PageSize: 50,
+		Selfhosted: map[string]*selfhosted.Options{
+			"default":  sOpts,
+			"override": sOptsOverride,
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expPageSizes := map[string]int{
+		"docker.repositories.yourdomain.com": 50,
+		"registry.example.com":               1000,
+	}
+
+	for _, client := range c.clients {
+		switch client := client.(type) {
+		case *docker.Client:
+			if client.PageSize != 50 {
+				t.Errorf("unexpected docker page size, exp=50 got=%d", client.PageSize)
+			}
+		case *selfhosted.Client:
+			for host, exp := range expPageSizes {
+				if client.IsHost(host) && client.PageSize != exp {
+					t.Errorf("%s: unexpected selfhosted page size, exp=%d got=%d", host, exp, client.PageSize)
+				}
+			}
+		}
+	}
+
+	if sOpts.PageSize != 0 {
+		t.Errorf("expected configured selfhosted options not to be modified, got page size=%d", sOpts.PageSize)
+	}
+}
+
+func TestMirrors(t *testing.T) {
+	handler, err := New(context.TODO(), logrus.NewEntry(logrus.New()), Options{
+		Mirrors: map[string]string{
+			"docker.io": "mirror.internal/docker.io",
+			"quay.io":   "mirror.internal/quay",
+			"gcr.io":    "gcr-mirror.internal",
+		},
+		Selfhosted: map[string]*selfhosted.Options{
+			"mirror": {
+				Host: "https://mirror.internal",
+			},
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := map[string]struct {
+		url                string
+		expClient          ImageClient
+		expHost            string
+		expRepo, expImage  string
+		expOriginalURL     string
+		expNoMirrorRewrite bool
+	}{
+		"single name should be mirrored as a docker hub library image": {
+			url:            "nginx",
+			expClient:      new(selfhosted.Client),
+			expHost:        "mirror.internal",
+			expRepo:        "docker.io/library",
+			expImage:       "nginx",
+			expOriginalURL: "nginx",
+		},
+		"docker.io should be mirrored with the mirror path": {
+			url:            "docker.io/jetstack/version-checker",
+			expClient:      new(selfhosted.Client),
+			expHost:        "mirror.internal",
+			expRepo:        "docker.io/jetstack",
+			expImage:       "version-checker",
+			expOriginalURL: "docker.io/jetstack/version-checker",
+		},
+		"quay.io should be mirrored with its own mirror path": {
+			url:            "quay.io/jetstack/version-checker",
+			expClient:      new(selfhosted.Client),
+			expHost:        "mirror.internal",
+			expRepo:        "quay/jetstack",
+			expImage:       "version-checker",
+			expOriginalURL: "quay.io/jetstack/version-checker",
+		},
+		"mirror without a path should use the fallback client": {
+			url:            "gcr.io/jetstack-cre/version-checker",
+			expClient:      new(selfhosted.Client),
+			expHost:        "gcr-mirror.internal",
+			expRepo:        "jetstack-cre",
+			expImage:       "version-checker",
+			expOriginalURL: "gcr.io/jetstack-cre/version-checker",
+		},
+		"mirror image URL should resolve as its logical image": {
+			url:            "mirror.internal/docker.io/library/nginx",
+			expClient:      new(selfhosted.Client),
+			expHost:        "mirror.internal",
+			expRepo:        "docker.io/library",
+			expImage:       "nginx",
+			expOriginalURL: "docker.io/library/nginx",
+		},
+		"unmirrored host should not be rewritten": {
+			url:            "us.icr.io/jetstack/version-checker",
+			expClient:      new(icr.Client),
+			expHost:        "us.icr.io",
+			expRepo:        "jetstack",
+			expImage:       "version-checker",
+			expOriginalURL: "us.icr.io/jetstack/version-checker",
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			client, host, repo, image, err := handler.resolve(test.url)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if reflect.TypeOf(client) != reflect.TypeOf(test.expClient) {
+				t.Errorf("unexpected client, exp=%v got=%v",
+					reflect.TypeOf(test.expClient), reflect.TypeOf(client))
+			}
+			if host != test.expHost || repo != test.expRepo || image != test.expImage {
+				t.Errorf("unexpected host, repo and image, exp=%s %s %s got=%s %s %s",
+					test.expHost, test.expRepo, test.expImage, host, repo, image)
+			}
+			if originalURL := handler.OriginalImageURL(test.url); originalURL != test.expOriginalURL {
+				t.Errorf("unexpected original image URL, exp=%q got=%q", test.expOriginalURL, originalURL)
+			}
+		})
+	}
+}
+
+func TestMirrorsInvalid(t *testing.T) {
+	tests := map[string]map[string]string{
+		"empty host should error":           {"": "mirror.internal"},
+		"empty mirror should error":         {"docker.io": "/"},
+		"mirror with a scheme should error": {"docker.io": "https://mirror.internal"},
+	}
+
+	for name, mirrors := range tests {
+		t.Run(name, func(t *testing.T) {
+			if _, err := New(context.TODO(), logrus.NewEntry(logrus.New()), Options{Mirrors: mirrors}); err == nil {
+				t.Error("expected error, got none")
+			}
+		})
+	}
+}
+
+func TestMirrorTags(t *testing.T) {
+	var paths []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		paths = append(paths, r.URL.Path)
+		w.Write([]byte(`{"name":"docker.io/library/nginx","tags":[]}`))
+	}))
+	defer server.Close()
+
+	mirrorHost := strings.TrimPrefix(server.URL, "http://")
+
+	handler, err := New(context.TODO(), logrus.NewEntry(logrus.New()), Options{
+		Mirrors: map[string]string{
+			"docker.io": mirrorHost + "/docker.io",
+		},
+		Selfhosted: map[string]*selfhosted.Options{
+			"mirror": {
+				Host: server.URL,
+			},
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := handler.Tags(context.TODO(), "nginx"); err != nil {
+		t.Fatal(err)
+	}
+
+	expPaths := []string{"/v2/docker.io/library/nginx/tags/list"}
+	if !reflect.DeepEqual(paths, expPaths) {
+		t.Errorf("unexpected requests to mirror, exp=%v got=%v", expPaths, paths)
+	}
+}
+
+// roundTripperFunc is an http.RoundTripper of a func.
+type roundTripperFunc func(*http.Request) (*http.Response, error)
+
+func (f roundTripperFunc) RoundTrip(req *http.Request) (*http.Response, error) {
+	return f(req)
+}
+
+func TestNetworkErrors(t *testing.T) {
+	tests := map[string]struct {
+		err          error
+		expKind      clienterrors.NetworkErrorKind
+		expRetryable bool
+	}{
+		"dial timeout should be retryable": {
+			err:          &net.OpError{Op: "dial", Net: "tcp", Err: context.DeadlineExceeded},
+			expKind:      clienterrors.NetworkErrorTimeout,
+			expRetryable: true,
+		},
+		"dns not found should not be retryable": {
+			err: &net.OpError{Op: "dial", Net: "tcp", Err: &net.DNSError{
+				Err: "no such host", Name: "registry.example.com", IsNotFound: true,
+			}},
+			expKind:      clienterrors.NetworkErrorDNSNotFound,
+			expRetryable: false,
+		},
+		"connection refused should be retryable": {
+			err: &net.OpError{Op: "dial", Net: "tcp",
+				Err: os.NewSyscallError("connect", syscall.ECONNREFUSED)},
+			expKind:      clienterrors.NetworkErrorConnectionRefused,
+			expRetryable: true,
+		},
+		"connection reset should be retryable": {
+			err: &net.OpError{Op: "read", Net: "tcp",
+				Err: os.NewSyscallError("read", syscall.ECONNRESET)},
+			expKind:      clienterrors.NetworkErrorConnectionReset,
+			expRetryable: true,
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			handler, err := New(context.TODO(), logrus.NewEntry(logrus.New()), Options{
+				Selfhosted: map[string]*selfhosted.Options{
+					"example": {
+						Host: "https://registry.example.com",
+						Transport: roundTripperFunc(func(*http.Request) (*http.Response, error) {
+							return nil, test.err
+						}),
+					},
+				},
+			})
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			_, err = handler.Tags(context.TODO(), "registry.example.com/jetstack/version-checker")
+
+			var network *clienterrors.ErrorNetwork
+			if !errors.As(err, &network) {
+				t.Fatalf("expected network error, got: %v", err)
+			}
+			if network.Kind != test.expKind || network.Host != "registry.example.com" {
+				t.Errorf("unexpected network error, exp=%s from %q got=%s from %q",
+					test.expKind, "registry.example.com", network.Kind, network.Host)
+			}
+			if retryable := clienterrors.IsRetryable(err); retryable != test.expRetryable {
+				t.Errorf("unexpected retryable, exp=%t got=%t", test.expRetryable, retryable)
+			}
+		})
+	}
+}
+
+// testDockerConfigJSON is a representative decoded .dockerconfigjson of a
+// kubernetes.io/dockerconfigjson Secret, as created by kubectl.
+const testDockerConfigJSON = `{
+  "auths": {
+    "https://index.docker.io/v1/": {
+      "auth": "aHViLXVzZXI6aHViLXBhc3N3b3Jk"
+    },
+    "registry.example.com:5000": {
+      "username": "example-user",
+      "password": "example-password",
+      "email": "user@example.com",
+      "auth": "ZXhhbXBsZS11c2VyOmV4YW1wbGUtcGFzc3dvcmQ="
+    },
+    "https://GHCR.io": {
+      "registrytoken": "ghcr-token"
+    }
+  }
+}`
+
+func TestParseDockerConfigJSON(t *testing.T) {
+	creds, err := ParseDockerConfigJSON([]byte(testDockerConfigJSON))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expCreds := map[string]*api.Credentials{
+		"docker.io":                 {Username: "hub-user", Password: "hub-password"},
+		"registry.example.com:5000": {Username: "example-user", Password: "example-password"},
+		"ghcr.io":                   {Token: "ghcr-token"},
+	}
+	if !reflect.DeepEqual(creds, expCreds) {
+		t.Errorf("unexpected credentials, exp=%+v got=%+v", expCreds, creds)
+	}
+
+	for name, data := range map[string]string{
+		"invalid json":      `{"auths": `,
+		"invalid auth":      `{"auths": {"ghcr.io": {"auth": "!!!"}}}`,
+		"auth without pair": `{"auths": {"ghcr.io": {"auth": "dXNlcg=="}}}`,
+		"empty registry":    `{"auths": {"": {"username": "user"}}}`,
+	} {
+		if _, err := ParseDockerConfigJSON([]byte(data)); err == nil {
+			t.Errorf("%s: expected error, got none", name)
+		}
+	}
+}
+
+func TestHostCredentials(t *testing.T) {
+	var opts Options
+	if err := opts.AddDockerConfigJSON([]byte(testDockerConfigJSON)); err != nil {
+		t.Fatal(err)
+	}
+
+	var (
+		mu             sync.Mutex
+		authorizations = make(map[string]string)
+	)
+
+	opts.Selfhosted = make(map[string]*selfhosted.Options)
+	for name, host := range map[string]string{
+		"example": "https://registry.example.com:5000",
+		"ghcr":    "https://ghcr.io",
+		"other":   "https://other.example.com",
+	} {
+		opts.Selfhosted[name] = &selfhosted.Options{
+			Host: host,
+			Transport: roundTripperFunc(func(req *http.Request) (*http.Response, error) {
+				mu.Lock()
+				authorizations[req.URL.Host] = req.Header.Get("Authorization")
+				mu.Unlock()
+
+				return &http.Response{
+					StatusCode: http.StatusOK,
+					Header:     http.Header{"Content-Type": []string{"application/json"}},
+					Body:       ioutil.NopCloser(strings.NewReader(`{"tags": []}`)),
+				}, nil
+			}),
+		}
+	}
+
+	handler, err := New(context.TODO(), logrus.NewEntry(logrus.New()), opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	basic := func(username, password string) string {
+		return "Basic " + base64.StdEncoding.EncodeToString([]byte(username+":"+password))
+	}
+
+	tests := map[string]struct {
+		ctx              context.Context
+		imageURL         string
+		expHost          string
+		expAuthorization string
+	}{
+		"host with a port should use its username and password": {
+			ctx:              context.TODO(),
+			imageURL:         "registry.example.com:5000/jetstack/version-checker",
+			expHost:          "registry.example.com:5000",
+			expAuthorization: basic("example-user", "example-password"),
+		},
+		"host of a registry URL should use its token": {
+			ctx:              context.TODO(),
+			imageURL:         "ghcr.io/jetstack/version-checker",
+			expHost:          "ghcr.io",
+			expAuthorization: "Bearer ghcr-token",
+		},
+		"host without credentials should not be authenticated": {
+			ctx:              context.TODO(),
+			imageURL:         "other.example.com/jetstack/version-checker",
+			expHost:          "other.example.com",
+			expAuthorization: "",
+		},
+		"context credentials should take precedence": {
+			ctx:              api.ContextWithCredentials(context.TODO(), &api.Credentials{Token: "context-token"}),
+			imageURL:         "ghcr.io/jetstack/version-checker",
+			expHost:          "ghcr.io",
+			expAuthorization: "Bearer context-token",
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			if _, err := handler.Tags(test.ctx, test.imageURL); err != nil {
+				t.Fatal(err)
+			}
+
+			mu.Lock()
+			defer mu.Unlock()
+
+			authorization, ok := authorizations[test.expHost]
+			if !ok {
+				t.Fatalf("expected request to host %q, got %v", test.expHost, authorizations)
+			}
+			if authorization != test.expAuthorization {
+				t.Errorf("unexpected authorization, exp=%q got=%q", test.expAuthorization, authorization)
+			}
+		})
+	}
+
+	// Docker Hub images without a host use the credentials of docker.io.
+	creds, ok := api.CredentialsFromContext(handler.withCredentials(context.TODO(), ""))
+	if !ok || creds.Username != "hub-user" {
+		t.Errorf("expected docker hub credentials, got: %+v", creds)
+	}
+
+	if secrets := opts.Secrets(); !containsString(secrets, "example-password") || !containsString(secrets, "ghcr-token") {
+		t.Errorf("expected host credentials to be redacted, got: %v", secrets)
+	}
+}
+
+func containsString(ss []string, s string) bool {
+	for _, e := range ss {
+		if e == s {
+			return true
+		}
+	}
+
+	return false
+}
+
+func TestHostTimeouts(t *testing.T) {
+	var (
+		mu        sync.Mutex
+		deadlines = make(map[string]time.Time)
+	)
+
+	opts := Options{
+		HostTimeouts: map[string]time.Duration{
+			"fast.example.com":         time.Second,
+			"https://slow.example.com": time.Hour,
+			"index.docker.io":          time.Minute,
+		},
+		Selfhosted: make(map[string]*selfhosted.Options),
+	}
+	for name, host := range map[string]string{
+		"fast":    "https://fast.example.com",
+		"slow":    "https://slow.example.com",
+		"default": "https://default.example.com",
+	} {
+		opts.Selfhosted[name] = &selfhosted.Options{
+			Host: host,
+			Transport: roundTripperFunc(func(req *http.Request) (*http.Response, error) {
+				mu.Lock()
+				deadlines[req.URL.Host], _ = req.Context().Deadline()
+				mu.Unlock()
+
+				return &http.Response{
+					StatusCode: http.StatusOK,
+					Header:     http.Header{"Content-Type": []string{"application/json"}},
+					Body:       ioutil.NopCloser(strings.NewReader(`{"tags": []}`)),
+				}, nil
+			}),
+		}
+	}
+
+	handler, err := New(context.TODO(), logrus.NewEntry(logrus.New()), opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := map[string]struct {
+		imageURL    string
+		host        string
+		expDeadline time.Duration
+	}{
+		"fast host should have a strict deadline": {
+			imageURL:    "fast.example.com/jetstack/version-checker",
+			host:        "fast.example.com",
+			expDeadline: time.Second,
+		},
+		"slow host should have a lenient deadline": {
+			imageURL:    "slow.example.com/jetstack/version-checker",
+			host:        "slow.example.com",
+			expDeadline: time.Hour,
+		},
+		"host without a timeout should have the client's default deadline": {
+			imageURL:    "default.example.com/jetstack/version-checker",
+			host:        "default.example.com",
+			expDeadline: time.Second * 10,
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			start := time.Now()
+			if _, err := handler.Tags(context.TODO(), test.imageURL); err != nil {
+				t.Fatal(err)
+			}
+
+			mu.Lock()
+			defer mu.Unlock()
+
+			deadline, ok := deadlines[test.host]
+			if !ok {
+				t.Fatalf("expected request to host %q, got %v", test.host, deadlines)
+			}
+
+			// The deadline is set between the start and end of the call.
+			if deadline.Before(start.Add(test.expDeadline)) || deadline.After(time.Now().Add(test.expDeadline)) {
+				t.Errorf("unexpected deadline, exp=%s got=%s", test.expDeadline, deadline.Sub(start))
+			}
 		})
 	}
+
+	// Docker Hub images without a host use the timeout of docker.io.
+	ctx, cancel := handler.withTimeout(context.TODO(), "")
+	defer cancel()
+	if _, ok := ctx.Deadline(); !ok {
+		t.Error("expected docker hub deadline, got none")
+	}
+
+	// A context deadline earlier than the host's timeout is kept.
+	parent, parentCancel := context.WithTimeout(context.TODO(), time.Millisecond)
+	defer parentCancel()
+	parentDeadline, _ := parent.Deadline()
+	ctx, cancel = handler.withTimeout(parent, "slow.example.com")
+	defer cancel()
+	if deadline, _ := ctx.Deadline(); !deadline.Equal(parentDeadline) {
+		t.Errorf("unexpected deadline, exp=%s got=%s", parentDeadline, deadline)
+	}
+}
+
+func TestRetryPredicate(t *testing.T) {
+	const statusEnhanceYourCalm = 420
+
+	tests := map[string]struct {
+		predicate util.RetryPredicate
+		expErr    bool
+	}{
+		"custom predicate should retry custom status": {
+			predicate: func(resp *http.Response, err error) bool {
+				return err == nil && resp.StatusCode == statusEnhanceYourCalm
+			},
+			expErr: false,
+		},
+		"default predicate should not retry custom status": {
+			expErr: true,
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			var requests int32
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				if atomic.AddInt32(&requests, 1) <= 2 {
+					w.WriteHeader(statusEnhanceYourCalm)
+					return
+				}
+
+				w.Write([]byte(`{"tags": []}`))
+			}))
+			defer server.Close()
+
+			handler, err := New(context.TODO(), logrus.NewEntry(logrus.New()), Options{
+				MaxRetries:     2,
+				RetryBackoff:   time.Millisecond,
+				RetryPredicate: test.predicate,
+				Selfhosted: map[string]*selfhosted.Options{
+					"example": {Host: server.URL},
+				},
+			})
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			_, err = handler.Tags(context.TODO(), strings.TrimPrefix(server.URL, "http://")+"/jetstack/version-checker")
+			if (err != nil) != test.expErr {
+				t.Errorf("unexpected error, exp=%t got=%v", test.expErr, err)
+			}
+		})
+	}
+}
+
+func TestErrorResponseFunc(t *testing.T) {
+	tests := map[string]struct {
+		statuses   []int
+		maxRetries int
+		expCodes   []int
+	}{
+		"successful response should not be reported": {
+			statuses: []int{http.StatusOK},
+		},
+		"redirected response should not be reported": {
+			statuses: []int{http.StatusFound, http.StatusOK},
+		},
+		"unauthorized should be reported": {
+			statuses: []int{http.StatusUnauthorized},
+			expCodes: []int{http.StatusUnauthorized},
+		},
+		"forbidden should be reported": {
+			statuses: []int{http.StatusForbidden},
+			expCodes: []int{http.StatusForbidden},
+		},
+		"not found should be reported": {
+			statuses: []int{http.StatusNotFound},
+			expCodes: []int{http.StatusNotFound},
+		},
+		"each retried response should be reported": {
+			statuses:   []int{http.StatusTooManyRequests, http.StatusServiceUnavailable, http.StatusOK},
+			maxRetries: 2,
+			expCodes:   []int{http.StatusTooManyRequests, http.StatusServiceUnavailable},
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			var requests int32
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				status := test.statuses[atomic.AddInt32(&requests, 1)-1]
+				switch {
+				case status == http.StatusFound:
+					http.Redirect(w, r, r.URL.Path+"?redirected=true", status)
+				case status >= http.StatusBadRequest:
+					w.WriteHeader(status)
+				default:
+					w.Write([]byte(`{"tags": []}`))
+				}
+			}))
+			defer server.Close()
+
+			var (
+				mu    sync.Mutex
+				hosts []string
+				codes []int
+			)
+
+			host := strings.TrimPrefix(server.URL, "http://")
+			handler, err := New(context.TODO(), logrus.NewEntry(logrus.New()), Options{
+				MaxRetries:   test.maxRetries,
+				RetryBackoff: time.Millisecond,
+				ErrorResponseFunc: func(host string, statusCode int) {
+					mu.Lock()
+					defer mu.Unlock()
+					hosts = append(hosts, host)
+					codes = append(codes, statusCode)
+				},
+				Selfhosted: map[string]*selfhosted.Options{
+					"example": {Host: server.URL},
+				},
+			})
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			// Error responses fail the request, which is not under test.
+			_, _ = handler.Tags(context.TODO(), host+"/jetstack/version-checker")
+
+			if !reflect.DeepEqual(codes, test.expCodes) {
+				t.Errorf("unexpected status codes, exp=%v got=%v", test.expCodes, codes)
+			}
+			for _, h := range hosts {
+				if h != host {
+					t.Errorf("unexpected host, exp=%s got=%s", host, h)
+				}
+			}
+		})
+	}
+}
+
+// requestIDKey is the context key of request IDs in tests.
+type requestIDKey struct{}
+
+func TestRequestID(t *testing.T) {
+	tests := map[string]struct {
+		header    string
+		requestID interface{}
+		custom    bool
+		expHeader string
+		expID     string
+	}{
+		"request ID should be set as the default header": {
+			requestID: "abc-123",
+			expHeader: util.DefaultRequestIDHeader,
+			expID:     "abc-123",
+		},
+		"request ID should be set as the configured header": {
+			header:    "X-Correlation-ID",
+			requestID: "abc-123",
+			expHeader: "X-Correlation-ID",
+			expID:     "abc-123",
+		},
+		"request ID should be set with a custom registry transport": {
+			requestID: "abc-123",
+			custom:    true,
+			expHeader: util.DefaultRequestIDHeader,
+			expID:     "abc-123",
+		},
+		"no request ID should not set the header": {
+			expHeader: util.DefaultRequestIDHeader,
+		},
+		"non-string request ID should not set the header": {
+			requestID: 123,
+			expHeader: util.DefaultRequestIDHeader,
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			var (
+				mu  sync.Mutex
+				ids []string
+			)
+
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				mu.Lock()
+				ids = append(ids, r.Header.Get(test.expHeader))
+				mu.Unlock()
+
+				w.Write([]byte(`{"tags": []}`))
+			}))
+			defer server.Close()
+
+			sOpts := &selfhosted.Options{Host: server.URL}
+			if test.custom {
+				sOpts.Transport = http.DefaultTransport
+			}
+
+			handler, err := New(context.TODO(), logrus.NewEntry(logrus.New()), Options{
+				RequestIDContextKey: requestIDKey{},
+				RequestIDHeader:     test.header,
+				Selfhosted:          map[string]*selfhosted.Options{"example": sOpts},
+			})
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			ctx := context.TODO()
+			if test.requestID != nil {
+				ctx = context.WithValue(ctx, requestIDKey{}, test.requestID)
+			}
+
+			host := strings.TrimPrefix(server.URL, "http://")
+			if _, err := handler.Tags(ctx, host+"/jetstack/version-checker"); err != nil {
+				t.Fatal(err)
+			}
+
+			if len(ids) != 1 || ids[0] != test.expID {
+				t.Errorf("unexpected request IDs, exp=[%s] got=%v", test.expID, ids)
+			}
+		})
+	}
+}
+
+func TestRegistries(t *testing.T) {
+	tests := map[string]struct {
+		requireClientMatch bool
+		expNames           []string
+	}{
+		"built-in and selfhosted clients should be reported with the fallback last": {
+			expNames: []string{
+				"https://docker.repositories.yourdomain.com",
+				"acr", "ecr", "dockerhub", "gcr", "icr", "quay",
+				"dockerapi",
+			},
+		},
+		"fallback should not be reported when a client match is required": {
+			requireClientMatch: true,
+			expNames: []string{
+				"https://docker.repositories.yourdomain.com",
+				"acr", "ecr", "dockerhub", "gcr", "icr", "quay",
+			},
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			handler, err := New(context.TODO(), logrus.NewEntry(logrus.New()), Options{
+				RequireClientMatch: test.requireClientMatch,
+				Selfhosted: map[string]*selfhosted.Options{
+					"yourdomain": {
+						Host: "https://docker.repositories.yourdomain.com",
+					},
+				},
+			})
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			registries := handler.Registries()
+
+			var names []string
+			for _, registry := range registries {
+				names = append(names, registry.Name)
+			}
+			if !reflect.DeepEqual(names, test.expNames) {
+				t.Fatalf("unexpected registries, exp=%v got=%v", test.expNames, names)
+			}
+
+			// Each example host should be matched by its own client.
+			examples := strings.NewReplacer("*", "example", "<account>", "123456789", "<region>", "us-east-1")
+			for _, registry := range registries {
+				if len(registry.DisplayName) == 0 || len(registry.HostPatterns) == 0 {
+					t.Errorf("%s: expected registry to be described, got=%+v", registry.Name, registry)
+				}
+				if registry.Fallback != (registry.Name == "dockerapi") {
+					t.Errorf("%s: unexpected fallback, got=%t", registry.Name, registry.Fallback)
+				}
+				if registry.Fallback {
+					continue
+				}
+
+				for _, pattern := range registry.HostPatterns {
+					host := examples.Replace(pattern)
+					if clientName := handler.ClientName(host + "/jetstack/version-checker"); clientName != registry.Name {
+						t.Errorf("%s: unexpected client of host %q, exp=%q got=%q", registry.Name, host, registry.Name, clientName)
+					}
+				}
+			}
+		})
+	}
+}
+
+func TestHostBasePaths(t *testing.T) {
+	var (
+		mu    sync.Mutex
+		paths []string
+	)
+	server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		paths = append(paths, r.URL.Path)
+		mu.Unlock()
+
+		w.Write([]byte(`{"tags": []}`))
+	}))
+	defer server.Close()
+	host := strings.TrimPrefix(server.URL, "https://")
+
+	caBundle := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: server.Certificate().Raw})
+
+	tests := map[string]struct {
+		opts    Options
+		expPath string
+	}{
+		"fallback client should request the base path of the host": {
+			opts: Options{
+				HostBasePaths: map[string]string{"https://" + strings.ToUpper(host): "/registry/"},
+			},
+			expPath: "/registry/v2/jetstack/version-checker/tags/list",
+		},
+		"selfhosted client should request the base path of the host over its own": {
+			opts: Options{
+				HostBasePaths: map[string]string{host: "/mirror"},
+				Selfhosted: map[string]*selfhosted.Options{
+					"registry": {Host: server.URL, BasePath: "/registry"},
+				},
+			},
+			expPath: "/mirror/v2/jetstack/version-checker/tags/list",
+		},
+		"selfhosted client should request its own base path without one of the host": {
+			opts: Options{
+				HostBasePaths: map[string]string{"registry.internal": "/mirror"},
+				Selfhosted: map[string]*selfhosted.Options{
+					"registry": {Host: server.URL + "/registry"},
+				},
+			},
+			expPath: "/registry/v2/jetstack/version-checker/tags/list",
+		},
+		"no base path should request the root": {
+			expPath: "/v2/jetstack/version-checker/tags/list",
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			mu.Lock()
+			paths = nil
+			mu.Unlock()
+
+			test.opts.CABundles = map[string][]byte{host: caBundle}
+			handler, err := New(context.TODO(), logrus.NewEntry(logrus.New()), test.opts)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if _, err := handler.Tags(context.TODO(), host+"/jetstack/version-checker"); err != nil {
+				t.Fatal(err)
+			}
+
+			mu.Lock()
+			defer mu.Unlock()
+
+			if exp := []string{test.expPath}; !reflect.DeepEqual(paths, exp) {
+				t.Errorf("unexpected request paths, exp=%v got=%v", exp, paths)
+			}
+		})
+	}
+}
+
+func TestValidate(t *testing.T) {
+	var (
+		mu       sync.Mutex
+		requests []string
+	)
+
+	handler, err := New(context.TODO(), logrus.NewEntry(logrus.New()), Options{
+		RequireClientMatch: true,
+		HostCredentials: map[string]*api.Credentials{
+			"registry.example.com": {Token: "token"},
+		},
+		Selfhosted: map[string]*selfhosted.Options{
+			"registry": {
+				Host: "https://registry.example.com",
+				Transport: roundTripperFunc(func(req *http.Request) (*http.Response, error) {
+					mu.Lock()
+					requests = append(requests, req.URL.RequestURI())
+					mu.Unlock()
+
+					status := http.StatusOK
+					if req.Header.Get("Authorization") != "Bearer token" {
+						status = http.StatusUnauthorized
+					} else if !strings.HasPrefix(req.URL.Path, "/v2/jetstack/version-checker/") {
+						status = http.StatusNotFound
+					}
+
+					return &http.Response{
+						StatusCode: status,
+						Header:     http.Header{"Content-Type": []string{"application/json"}},
+						Body:       ioutil.NopCloser(strings.NewReader(`{"tags": ["v0.1.0"]}`)),
+					}, nil
+				}),
+			},
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := map[string]struct {
+		imageURL string
+		expErr   func(error) bool
+	}{
+		"resolvable image should not error": {
+			imageURL: "registry.example.com/jetstack/version-checker",
+		},
+		"missing image should be not found": {
+			imageURL: "registry.example.com/jetstack/missing",
+			expErr:   clienterrors.IsImageNotFound,
+		},
+		"image of an unmatched host should not match a client": {
+			imageURL: "unknown.example.com/jetstack/version-checker",
+			expErr:   clienterrors.IsNoClientMatch,
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			mu.Lock()
+			requests = nil
+			mu.Unlock()
+
+			err := handler.Validate(context.TODO(), test.imageURL)
+			if test.expErr == nil {
+				if err != nil {
+					t.Fatalf("unexpected error: %s", err)
+				}
+			} else if err == nil || !test.expErr(err) {
+				t.Fatalf("unexpected error, got=%v", err)
+			}
+
+			mu.Lock()
+			defer mu.Unlock()
+			if len(requests) > 1 {
+				t.Errorf("expected at most a single request, got=%v", requests)
+			}
+		})
+	}
+
+	// Credentials of the context are used over those of the host.
+	ctx := api.ContextWithCredentials(context.TODO(), &api.Credentials{Token: "other"})
+	if err := handler.Validate(ctx, "registry.example.com/jetstack/version-checker"); !clienterrors.IsUnauthorized(err) {
+		t.Errorf("expected unauthorized error, got=%v", err)
+	}
+}
+
+// hostClient is an ImageClient of a single host, counting its host matches.
+func TestRegistriesConcurrentRegister(t *testing.T) {
+	handler, err := New(context.TODO(), logrus.NewEntry(logrus.New()), Options{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	before := len(handler.Registries())
+
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(2)
+		go func(i int) {
+			defer wg.Done()
+			handler.RegisterClient(&hostClient{host: fmt.Sprintf("registry-%d.example.com", i)})
+		}(i)
+		go func() {
+			defer wg.Done()
+			handler.Registries()
+		}()
+	}
+	wg.Wait()
+
+	if registries := handler.Registries(); len(registries) != before+10 {
+		t.Errorf("unexpected number of registries, exp=%d got=%d", before+10, len(registries))
+	}
+}
+
+// deadlineClient is a registry client of a single host, recording the
+// deadline of the context of each request.
+type deadlineClient struct {
+	hostClient
+
+	mu        sync.Mutex
+	deadlines map[string]time.Time
+}
+
+func (d *deadlineClient) record(ctx context.Context, method string) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.deadlines[method], _ = ctx.Deadline()
+}
+
+func (d *deadlineClient) SortedTags(ctx context.Context, _, _, _ string, _ func([]api.ImageTag) bool) error {
+	d.record(ctx, "sorted tags")
+	return nil
+}
+
+func (d *deadlineClient) Manifest(ctx context.Context, _, _, _, _ string) (*api.ImageManifest, error) {
+	d.record(ctx, "manifest")
+	return new(api.ImageManifest), nil
+}
+
+func (d *deadlineClient) Config(ctx context.Context, _, _, _, _ string) (*api.ImageConfig, error) {
+	d.record(ctx, "config")
+	return new(api.ImageConfig), nil
+}
+
+func TestHostTimeoutsRequests(t *testing.T) {
+	handler, err := New(context.TODO(), logrus.NewEntry(logrus.New()), Options{
+		HostTimeouts: map[string]time.Duration{"fast.example.com": time.Second},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	client := &deadlineClient{
+		hostClient: hostClient{host: "fast.example.com"},
+		deadlines:  make(map[string]time.Time),
+	}
+	handler.RegisterClient(client)
+
+	const imageURL = "fast.example.com/version-checker"
+	start := time.Now()
+	if _, err := handler.SortedTags(context.TODO(), imageURL, func([]api.ImageTag) bool { return false }); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := handler.Manifest(context.TODO(), imageURL, "v0.1.0"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := handler.Config(context.TODO(), imageURL, "v0.1.0"); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, method := range []string{"sorted tags", "manifest", "config"} {
+		deadline, ok := client.deadlines[method]
+		if !ok || deadline.IsZero() {
+			t.Errorf("expected %s request to have a deadline, got none", method)
+			continue
+		}
+
+		// The deadline is set between the start and end of the calls.
+		if deadline.Before(start.Add(time.Second)) || deadline.After(time.Now().Add(time.Second)) {
+			t.Errorf("unexpected %s deadline, exp=%s got=%s", method, time.Second, deadline.Sub(start))
+		}
+	}
+}
+
+type hostClient struct {
+	host    string
+	matches int32
+}
+
+func (h *hostClient) Name() string { return "host" }
+
+func (h *hostClient) IsHost(host string) bool {
+	atomic.AddInt32(&h.matches, 1)
+	return host == h.host
+}
+
+func (h *hostClient) RepoImageFromPath(path string) (string, string) {
+	return "", path
+}
+
+func (h *hostClient) Tags(context.Context, string, string, string) ([]api.ImageTag, error) {
+	return []api.ImageTag{{Tag: "v0.1.0"}}, nil
+}
+
+func TestUnsupportedHost(t *testing.T) {
+	var requests int32
+
+	// A web server which does not serve the registry API.
+	web := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&requests, 1)
+		w.Header().Set("Content-Type", "text/html")
+		w.Write([]byte("<html><body>Welcome</body></html>"))
+	}))
+	defer web.Close()
+	webHost := strings.TrimPrefix(web.URL, "https://")
+
+	// A registry without the requested image.
+	registry := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&requests, 1)
+		w.WriteHeader(http.StatusNotFound)
+		w.Write([]byte(`{"errors": [{"code": "NAME_UNKNOWN"}]}`))
+	}))
+	defer registry.Close()
+	registryHost := strings.TrimPrefix(registry.URL, "https://")
+
+	newHandler := func(ttl time.Duration) *Client {
+		handler, err := New(context.TODO(), logrus.NewEntry(logrus.New()), Options{
+			UnsupportedHostTTL: ttl,
+			CABundles: map[string][]byte{
+				webHost:      pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: web.Certificate().Raw}),
+				registryHost: pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: registry.Certificate().Raw}),
+			},
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		return handler
+	}
+
+	expRequests := func(exp int32) {
+		t.Helper()
+		if got := atomic.SwapInt32(&requests, 0); got != exp {
+			t.Errorf("unexpected requests, exp=%d got=%d", exp, got)
+		}
+	}
+
+	handler := newHandler(time.Minute)
+	now := time.Now()
+	handler.now = func() time.Time { return now }
+	webImage := webHost + "/jetstack/version-checker"
+
+	_, err := handler.Tags(context.TODO(), webImage)
+	if !clienterrors.IsUnsupportedHost(err) || !clienterrors.IsDecode(err) {
+		t.Fatalf("expected unsupported host decode error, got=%v", err)
+	}
+	expRequests(1)
+
+	// Later lookups of the host fail fast, without detecting it again.
+	for _, lookup := range []func() error{
+		func() error { _, err := handler.Tags(context.TODO(), webImage); return err },
+		func() error { return handler.Validate(context.TODO(), webHost+"/jetstack/other") },
+		func() error { _, err := handler.Manifest(context.TODO(), webImage, "v0.1.0"); return err },
+	} {
+		if err := lookup(); !clienterrors.IsUnsupportedHost(err) {
+			t.Errorf("expected unsupported host error, got=%v", err)
+		}
+	}
+	expRequests(0)
+
+	// Fetching manifests and configs of the host also detects it.
+	for name, lookup := range map[string]func(handler *Client) error{
+		"manifest": func(handler *Client) error {
+			_, err := handler.Manifest(context.TODO(), webImage, "v0.1.0")
+			return err
+		},
+		"config": func(handler *Client) error { _, err := handler.Config(context.TODO(), webImage, "v0.1.0"); return err },
+	} {
+		handler := newHandler(time.Minute)
+		if err := lookup(handler); !clienterrors.IsUnsupportedHost(err) {
+			t.Errorf("expected unsupported host error of %s, got=%v", name, err)
+		}
+		expRequests(1)
+
+		if _, err := handler.Tags(context.TODO(), webImage); !clienterrors.IsUnsupportedHost(err) {
+			t.Errorf("expected unsupported host error after %s, got=%v", name, err)
+		}
+		expRequests(0)
+	}
+
+	// The host is detected again once the failure expires.
+	now = now.Add(time.Minute)
+	if _, err := handler.Tags(context.TODO(), webImage); !clienterrors.IsUnsupportedHost(err) {
+		t.Errorf("expected unsupported host error, got=%v", err)
+	}
+	expRequests(1)
+
+	// Registering a client of the host resets its classification.
+	client := &hostClient{host: webHost}
+	handler.RegisterClient(client)
+	for i := 0; i < 2; i++ {
+		tags, err := handler.Tags(context.TODO(), webImage)
+		if err != nil {
+			t.Fatalf("unexpected error: %s", err)
+		}
+		if len(tags) != 1 {
+			t.Errorf("unexpected tags of registered client, exp=1 got=%d", len(tags))
+		}
+	}
+	if matches := atomic.LoadInt32(&client.matches); matches != 1 {
+		t.Errorf("expected the host to be classified once, got=%d matches", matches)
+	}
+	expRequests(0)
+
+	// Missing images are not failures of the host.
+	for i := 0; i < 2; i++ {
+		if _, err := handler.Tags(context.TODO(), registryHost+"/jetstack/missing"); err == nil || clienterrors.IsUnsupportedHost(err) {
+			t.Errorf("expected missing image error, got=%v", err)
+		}
+	}
+	expRequests(2)
+
+	// Unsupported hosts are detected on each lookup if disabled.
+	handler = newHandler(0)
+	for i := 0; i < 2; i++ {
+		if _, err := handler.Tags(context.TODO(), webImage); !clienterrors.IsDecode(err) || clienterrors.IsUnsupportedHost(err) {
+			t.Errorf("expected decode error, got=%v", err)
+		}
+	}
+	expRequests(2)
 }
@@ -7,26 +7,40 @@ import (
 	"fmt"
 	"io/ioutil"
 	"net/http"
+	"net/url"
+	"strconv"
 	"strings"
 	"time"
 
 	"github.com/jetstack/version-checker/pkg/api"
+	clienterrors "github.com/jetstack/version-checker/pkg/client/errors"
+	"github.com/jetstack/version-checker/pkg/client/util"
 )
 
 const (
 	loginURL  = "https://hub.docker.com/v2/users/login/"
 	lookupURL = "https://registry.hub.docker.com/v2/repositories/%s/%s/tags"
+
+	// maxPageSize is the maximum number of tags Docker Hub returns per page,
+	// which is also requested by default.
+	maxPageSize = 100
 )
 
 type Options struct {
 	Username string
 	Password string
 	Token    string
+
+	// PageSize is the number of tags requested per page when listing tags.
+	// Defaults to, and is capped at, 100.
+	PageSize int
 }
 
 type Client struct {
 	*http.Client
 	Options
+
+	lookupURL string
 }
 
 type AuthResponse struct {
@@ -52,7 +66,8 @@ type Image struct {
 
 func New(ctx context.Context, opts Options) (*Client, error) {
 	client := &http.Client{
-		Timeout: time.Second * 5,
+		Timeout:       time.Second * 5,
+		CheckRedirect: util.CheckRedirect,
 	}
 
 	// Setup Auth if username and password used.
@@ -69,8 +84,9 @@ func New(ctx context.Context, opts Options) (*Client, error) {
 	}
 
 	return &Client{
-		Options: opts,
-		Client:  client,
+		Options:   opts,
+		Client:    client,
+		lookupURL: lookupURL,
 	}, nil
 }
 
@@ -78,16 +94,44 @@ func (c *Client) Name() string {
 	return "dockerhub"
 }
 
-func (c *Client) Tags(ctx context.Context, _, repo, image string) ([]api.ImageTag, error) {
-	url := fmt.Sprintf(lookupURL, repo, image)
-
+func (c *Client) Tags(ctx context.Context, host, repo, image string) ([]api.ImageTag, error) {
 	var tags []api.ImageTag
+	err := c.TagPages(ctx, host, repo, image, func(page []api.ImageTag) error {
+		tags = append(tags, page...)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return tags, nil
+}
+
+// TagPages will call page with the tags of each page of results listed from
+// Docker Hub, until page returns an error or all pages have been listed. Tags
+// are filtered server side by the tag prefix carried by the context, if any,
+// where Docker Hub lists the tags containing the prefix.
+// The timestamp of each tag is Docker Hub's last updated time of the tag
+// itself, which is shared by the images of all of its platforms. Manifests and
+// image configs are never fetched, so unlike the selfhosted client, the
+// timestamp of multi-arch images does not depend on which platform is chosen.
+func (c *Client) TagPages(ctx context.Context, _, repo, image string, page func([]api.ImageTag) error) error {
+	var filter string
+	if prefix, ok := api.TagPrefixFromContext(ctx); ok {
+		filter = "&name=" + url.QueryEscape(prefix)
+	}
+
+	// Subsequent pages retain the page size and filter in their next URL.
+	url := fmt.Sprintf(c.lookupURL, url.PathEscape(repo), url.PathEscape(image)) + "?page_size=" +
+		strconv.Itoa(util.PageSize(c.PageSize, maxPageSize, maxPageSize)) + filter
+
 	for url != "" {
 		response, err := c.doRequest(ctx, url)
 		if err != nil {
-			return nil, err
+			return err
 		}
 
+		var tags []api.ImageTag
 		for _, result := range response.Results {
 			// No images in this result, so continue early
 			if len(result.Images) == 0 {
@@ -96,7 +140,7 @@ func (c *Client) Tags(ctx context.Context, _, repo, image string) ([]api.ImageTa
 
 			timestamp, err := time.Parse(time.RFC3339Nano, result.Timestamp)
 			if err != nil {
-				return nil, fmt.Errorf("failed to parse image timestamp: %s", err)
+				return fmt.Errorf("failed to parse image timestamp: %s", err)
 			}
 
 			for _, image := range result.Images {
@@ -115,10 +159,14 @@ func (c *Client) Tags(ctx context.Context, _, repo, image string) ([]api.ImageTa
 			}
 		}
 
+		if err := page(tags); err != nil {
+			return err
+		}
+
 		url = response.Next
 	}
 
-	return tags, nil
+	return nil
 }
 
 func (c *Client) doRequest(ctx context.Context, url string) (*TagResponse, error) {
@@ -129,13 +177,18 @@ func (c *Client) doRequest(ctx context.Context, url string) (*TagResponse, error
 
 	req.URL.Scheme = "https"
 	req = req.WithContext(ctx)
-	if len(c.Token) > 0 {
-		req.Header.Add("Authorization", "Token "+c.Token)
+
+	token := c.Token
+	if creds, ok := api.CredentialsFromContext(ctx); ok {
+		token = creds.Token
+	}
+	if len(token) > 0 {
+		req.Header.Add("Authorization", "Token "+token)
 	}
 
 	resp, err := c.Do(req)
 	if err != nil {
-		return nil, fmt.Errorf("failed to get docker image: %s", err)
+		return nil, fmt.Errorf("failed to get docker image: %w", err)
 	}
 
 	body, err := ioutil.ReadAll(resp.Body)
@@ -145,7 +198,7 @@ func (c *Client) doRequest(ctx context.Context, url string) (*TagResponse, error
 
 	response := new(TagResponse)
 	if err := json.Unmarshal(body, response); err != nil {
-		return nil, fmt.Errorf("unexpected image tags response: %s", body)
+		return nil, clienterrors.NewErrorDecode(req.URL.Host, resp.StatusCode, body, err)
 	}
 
 	return response, nil
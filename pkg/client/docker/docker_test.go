@@ -0,0 +1,312 @@
+package docker
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/jetstack/version-checker/pkg/api"
+	clienterrors "github.com/jetstack/version-checker/pkg/client/errors"
+)
+
+func TestTagsPageSize(t *testing.T) {
+	tests := map[string]struct {
+		pageSize    int
+		expPageSize string
+	}{
+		"unset should request the maximum": {
+			pageSize:    0,
+			expPageSize: "100",
+		},
+		"within the maximum should be requested": {
+			pageSize:    50,
+			expPageSize: "50",
+		},
+		"above the maximum should be capped": {
+			pageSize:    1000,
+			expPageSize: "100",
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			var pageSizes []string
+
+			server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				pageSizes = append(pageSizes, r.URL.Query().Get("page_size"))
+
+				if r.URL.Query().Get("page") == "2" {
+					fmt.Fprint(w, `{"results": []}`)
+					return
+				}
+
+				fmt.Fprintf(w, `{"next": "https://%s%s?page=2&page_size=%s", "results": []}`,
+					r.Host, r.URL.Path, r.URL.Query().Get("page_size"))
+			}))
+			defer server.Close()
+
+			client, err := New(context.TODO(), Options{PageSize: test.pageSize})
+			if err != nil {
+				t.Fatal(err)
+			}
+			client.Client = server.Client()
+			client.lookupURL = server.URL + "/v2/repositories/%s/%s/tags"
+
+			if _, err := client.Tags(context.TODO(), "", "jetstack", "version-checker"); err != nil {
+				t.Fatal(err)
+			}
+
+			if len(pageSizes) != 2 {
+				t.Fatalf("expected both pages to be requested, got=%d requests", len(pageSizes))
+			}
+			for _, pageSize := range pageSizes {
+				if pageSize != test.expPageSize {
+					t.Errorf("unexpected page size, exp=%s got=%s", test.expPageSize, pageSize)
+				}
+			}
+		})
+	}
+}
+
+func TestTagsRepoPath(t *testing.T) {
+	tests := map[string]struct {
+		path    string
+		expPath string
+	}{
+		"single image should use the library namespace": {
+			path:    "nginx",
+			expPath: "/v2/repositories/library/nginx/tags",
+		},
+		"explicit library namespace should be kept": {
+			path:    "library/nginx",
+			expPath: "/v2/repositories/library/nginx/tags",
+		},
+		"namespace with underscores should be kept": {
+			path:    "my_org/app",
+			expPath: "/v2/repositories/my_org/app/tags",
+		},
+		"image with dots should be kept": {
+			path:    "org/sub.app",
+			expPath: "/v2/repositories/org/sub.app/tags",
+		},
+		"namespace with dots and image with underscores should be kept": {
+			path:    "my.org/my_app",
+			expPath: "/v2/repositories/my.org/my_app/tags",
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			var paths []string
+
+			server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				paths = append(paths, r.URL.EscapedPath())
+				fmt.Fprint(w, `{"results": [{"name": "v0.1.0", "last_updated": "2020-06-01T00:00:00Z", "images": [{"digest": "sha256:aaa"}]}]}`)
+			}))
+			defer server.Close()
+
+			client, err := New(context.TODO(), Options{})
+			if err != nil {
+				t.Fatal(err)
+			}
+			client.Client = server.Client()
+			client.lookupURL = server.URL + "/v2/repositories/%s/%s/tags"
+
+			repo, image := client.RepoImageFromPath(test.path)
+			tags, err := client.Tags(context.TODO(), "", repo, image)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if len(paths) != 1 || paths[0] != test.expPath {
+				t.Errorf("unexpected request paths, exp=[%s] got=%v", test.expPath, paths)
+			}
+			if len(tags) != 1 || tags[0].Tag != "v0.1.0" {
+				t.Errorf("unexpected tags, exp=[v0.1.0] got=%v", tags)
+			}
+		})
+	}
+}
+
+func TestTagsMultiArchTimestamp(t *testing.T) {
+	server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, `{"results": [{"name": "v0.1.0", "last_updated": "2020-06-01T00:00:00Z", "images": [
+  {"digest": "sha256:bbb", "os": "linux", "architecture": "arm64"},
+  {"digest": "sha256:aaa", "os": "linux", "architecture": "amd64"}
+]}]}`)
+	}))
+	defer server.Close()
+
+	client, err := New(context.TODO(), Options{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	client.Client = server.Client()
+	client.lookupURL = server.URL + "/v2/repositories/%s/%s/tags"
+
+	tags, err := client.Tags(context.TODO(), "", "jetstack", "version-checker")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	exp := []api.ImageTag{
+		{Tag: "v0.1.0", SHA: "sha256:bbb", Timestamp: time.Date(2020, 6, 1, 0, 0, 0, 0, time.UTC), OS: "linux", Architecture: "arm64"},
+		{Tag: "v0.1.0", SHA: "sha256:aaa", Timestamp: time.Date(2020, 6, 1, 0, 0, 0, 0, time.UTC), OS: "linux", Architecture: "amd64"},
+	}
+	if !reflect.DeepEqual(tags, exp) {
+		t.Errorf("unexpected tags, exp=%+v got=%+v", exp, tags)
+	}
+}
+
+func TestTagPages(t *testing.T) {
+	stop := errors.New("stop")
+
+	tests := map[string]struct {
+		stopAfter    int
+		failPage     string
+		expPages     [][]string
+		expRequests  int
+		expErr       error
+		expDecodeErr bool
+	}{
+		"all pages should be listed in order": {
+			expPages:    [][]string{{"v0.1.0", "v0.2.0"}, {"v0.3.0"}, {}},
+			expRequests: 3,
+		},
+		"listing should stop at the first error of page": {
+			stopAfter:   1,
+			expPages:    [][]string{{"v0.1.0", "v0.2.0"}},
+			expRequests: 1,
+			expErr:      stop,
+		},
+		"listing should fail on a later page, after listing earlier pages": {
+			failPage:     "3",
+			expPages:     [][]string{{"v0.1.0", "v0.2.0"}, {"v0.3.0"}},
+			expRequests:  3,
+			expDecodeErr: true,
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			var requests int
+
+			server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				requests++
+
+				next := fmt.Sprintf("https://%s%s?page=%d", r.Host, r.URL.Path, requests+1)
+				if page := r.URL.Query().Get("page"); len(page) > 0 && page == test.failPage {
+					w.WriteHeader(http.StatusBadGateway)
+					fmt.Fprint(w, "<html>502 Bad Gateway</html>")
+					return
+				}
+
+				switch r.URL.Query().Get("page") {
+				case "":
+					fmt.Fprintf(w, `{"next": %q, "results": [
+						{"name": "v0.1.0", "last_updated": "2020-06-01T00:00:00Z", "images": [{"digest": "sha256:aaa"}]},
+						{"name": "v0.2.0", "last_updated": "2020-06-02T00:00:00Z", "images": [{"digest": "sha256:bbb"}]}
+					]}`, next)
+				case "2":
+					fmt.Fprintf(w, `{"next": %q, "results": [
+						{"name": "v0.3.0", "last_updated": "2020-06-03T00:00:00Z", "images": [{"digest": "sha256:ccc"}]},
+						{"name": "empty", "last_updated": "2020-06-03T00:00:00Z", "images": []}
+					]}`, next)
+				default:
+					fmt.Fprint(w, `{"results": []}`)
+				}
+			}))
+			defer server.Close()
+
+			client, err := New(context.TODO(), Options{})
+			if err != nil {
+				t.Fatal(err)
+			}
+			client.Client = server.Client()
+			client.lookupURL = server.URL + "/v2/repositories/%s/%s/tags"
+
+			var pages [][]string
+			err = client.TagPages(context.TODO(), "", "jetstack", "version-checker", func(page []api.ImageTag) error {
+				tags := []string{}
+				for _, tag := range page {
+					tags = append(tags, tag.Tag)
+				}
+				pages = append(pages, tags)
+
+				if len(pages) == test.stopAfter {
+					return stop
+				}
+				return nil
+			})
+			if test.expDecodeErr {
+				if !clienterrors.IsDecode(err) {
+					t.Fatalf("expected decode error, got=%v", err)
+				}
+			} else if err != test.expErr {
+				t.Fatalf("unexpected error, exp=%v got=%v", test.expErr, err)
+			}
+
+			if !reflect.DeepEqual(pages, test.expPages) {
+				t.Errorf("unexpected pages, exp=%v got=%v", test.expPages, pages)
+			}
+			if requests != test.expRequests {
+				t.Errorf("unexpected requests, exp=%d got=%d", test.expRequests, requests)
+			}
+		})
+	}
+}
+
+func TestTagsPrefixFilter(t *testing.T) {
+	tests := map[string]struct {
+		ctx     context.Context
+		expName []string
+	}{
+		"tag prefix should be pushed down on every page": {
+			ctx:     api.ContextWithTagPrefix(context.TODO(), "v1.2."),
+			expName: []string{"v1.2.", "v1.2."},
+		},
+		"no tag prefix should not filter": {
+			ctx:     context.TODO(),
+			expName: []string{"", ""},
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			var names []string
+
+			server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				names = append(names, r.URL.Query().Get("name"))
+
+				if r.URL.Query().Get("page") == "2" {
+					fmt.Fprint(w, `{"results": []}`)
+					return
+				}
+
+				fmt.Fprintf(w, `{"next": "https://%s%s?page=2&%s", "results": []}`,
+					r.Host, r.URL.Path, r.URL.RawQuery)
+			}))
+			defer server.Close()
+
+			client, err := New(context.TODO(), Options{})
+			if err != nil {
+				t.Fatal(err)
+			}
+			client.Client = server.Client()
+			client.lookupURL = server.URL + "/v2/repositories/%s/%s/tags"
+
+			if _, err := client.Tags(test.ctx, "", "jetstack", "version-checker"); err != nil {
+				t.Fatal(err)
+			}
+
+			if !reflect.DeepEqual(names, test.expName) {
+				t.Errorf("unexpected name filters, exp=%q got=%q", test.expName, names)
+			}
+		})
+	}
+}
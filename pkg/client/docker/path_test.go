@@ -31,6 +31,14 @@ func TestIsHost(t *testing.T) {
 			host:  "docker.io",
 			expIs: true,
 		},
+		"docker.io in mixed case should be true": {
+			host:  "Docker.IO",
+			expIs: true,
+		},
+		"docker.io with a port should be true": {
+			host:  "registry-1.docker.io:443",
+			expIs: true,
+		},
 		"just docker.com should be true": {
 			host:  "docker.com",
 			expIs: true,
@@ -87,6 +95,11 @@ func TestRepoImage(t *testing.T) {
 			expRepo:  "joshvanl",
 			expImage: "version-checker",
 		},
+		"segments with underscores and dots should be kept": {
+			path:     "my_org/my.app",
+			expRepo:  "my_org",
+			expImage: "my.app",
+		},
 		"multiple segments to path should return last two": {
 			path:     "registry/joshvanl/version-checker",
 			expRepo:  "joshvanl",
@@ -98,7 +111,7 @@ func TestRepoImage(t *testing.T) {
 	for name, test := range tests {
 		t.Run(name, func(t *testing.T) {
 			repo, image := handler.RepoImageFromPath(test.path)
-			if repo != test.expRepo && image != test.expImage {
+			if repo != test.expRepo || image != test.expImage {
 				t.Errorf("%s: unexpected repo/image, exp=%s/%s got=%s/%s",
 					test.path, test.expRepo, test.expImage, repo, image)
 			}
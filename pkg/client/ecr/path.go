@@ -3,6 +3,8 @@ package ecr
 import (
 	"regexp"
 	"strings"
+
+	"github.com/jetstack/version-checker/pkg/client/util"
 )
 
 var (
@@ -10,7 +12,17 @@ var (
 )
 
 func (c *Client) IsHost(host string) bool {
-	return ecrPattern.MatchString(host)
+	return ecrPattern.MatchString(util.NormalizeHost(host))
+}
+
+// DisplayName returns the human readable name of the registry.
+func (c *Client) DisplayName() string {
+	return "Amazon Elastic Container Registry"
+}
+
+// HostPatterns returns example patterns of the hosts matched by IsHost.
+func (c *Client) HostPatterns() []string {
+	return []string{"<account>.dkr.ecr.<region>.amazonaws.com", "<account>.dkr.ecr-fips.<region>.amazonaws.com", "<account>.dkr.ecr.<region>.amazonaws.com.cn"}
 }
 
 func (c *Client) RepoImageFromPath(path string) (string, string) {
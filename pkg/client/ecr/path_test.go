@@ -35,6 +35,10 @@ func TestIsHost(t *testing.T) {
 			host:  "hello123.dkr.ecr.foo.amazonaws.com",
 			expIs: true,
 		},
+		"hello123.dkr.ecr.foo.amazonaws.com in mixed case with a port true": {
+			host:  "Hello123.DKR.ecr.foo.amazonaws.com:443",
+			expIs: true,
+		},
 		"123hello.dkr.ecr.foo.amazonaws.com true": {
 			host:  "123hello.dkr.ecr.foo.amazonaws.com",
 			expIs: true,
@@ -0,0 +1,358 @@
+package errors
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"strings"
+	"syscall"
+)
+
+// ErrorNoClientMatch is returned when no registry client explicitly matched
+// the host of an image URL.
+type ErrorNoClientMatch struct {
+	Host string
+}
+
+func NewErrorNoClientMatch(host string) *ErrorNoClientMatch {
+	return &ErrorNoClientMatch{Host: host}
+}
+
+func (e *ErrorNoClientMatch) Error() string {
+	return fmt.Sprintf("no registry client matched image host %q", e.Host)
+}
+
+func IsNoClientMatch(err error) bool {
+	var noMatch *ErrorNoClientMatch
+	return errors.As(err, &noMatch)
+}
+
+// ErrorImageNotFound is returned when a registry reports that an image does
+// not exist.
+type ErrorImageNotFound struct {
+	Host  string
+	Image string
+}
+
+func NewErrorImageNotFound(host, image string) *ErrorImageNotFound {
+	return &ErrorImageNotFound{Host: host, Image: image}
+}
+
+func (e *ErrorImageNotFound) Error() string {
+	return fmt.Sprintf("%s: image %q not found", e.Host, e.Image)
+}
+
+func IsImageNotFound(err error) bool {
+	var notFound *ErrorImageNotFound
+	return errors.As(err, &notFound)
+}
+
+// ErrorUnauthorized is returned when a registry rejects the credentials of a
+// request for an image, or requires credentials which were not given.
+type ErrorUnauthorized struct {
+	Host       string
+	Image      string
+	StatusCode int
+}
+
+func NewErrorUnauthorized(host, image string, statusCode int) *ErrorUnauthorized {
+	return &ErrorUnauthorized{Host: host, Image: image, StatusCode: statusCode}
+}
+
+func (e *ErrorUnauthorized) Error() string {
+	return fmt.Sprintf("%s: unauthorized to access image %q (%d)", e.Host, e.Image, e.StatusCode)
+}
+
+func IsUnauthorized(err error) bool {
+	var unauthorized *ErrorUnauthorized
+	return errors.As(err, &unauthorized)
+}
+
+// ErrorUnsupportedHost is returned when a registry host, not explicitly
+// matched by any registry client, was found not to serve the registry API,
+// such as a web server or a host which does not exist, so that lookups of the
+// host fail fast.
+type ErrorUnsupportedHost struct {
+	Host string
+	Err  error
+}
+
+func NewErrorUnsupportedHost(host string, err error) *ErrorUnsupportedHost {
+	return &ErrorUnsupportedHost{Host: host, Err: err}
+}
+
+func (e *ErrorUnsupportedHost) Error() string {
+	return fmt.Sprintf("%s: host does not serve a supported registry API: %s", e.Host, e.Err)
+}
+
+func (e *ErrorUnsupportedHost) Unwrap() error {
+	return e.Err
+}
+
+func IsUnsupportedHost(err error) bool {
+	var unsupported *ErrorUnsupportedHost
+	return errors.As(err, &unsupported)
+}
+
+// maxSnippetLength is the maximum length of a response body included in an
+// ErrorDecode.
+const maxSnippetLength = 256
+
+// ErrorDecode is returned when a registry response body fails to be decoded.
+type ErrorDecode struct {
+	Host       string
+	StatusCode int
+
+	// Snippet is the start of the response body, as valid UTF-8.
+	Snippet string
+
+	Err error
+
+	// truncated is whether the body ended before decoding completed.
+	truncated bool
+}
+
+// NewErrorDecode returns a new ErrorDecode for the given registry host,
+// response status code and body, and decoding error.
+func NewErrorDecode(host string, statusCode int, body []byte, err error) *ErrorDecode {
+	truncated := errors.Is(err, io.ErrUnexpectedEOF)
+
+	var syntaxErr *json.SyntaxError
+	if errors.As(err, &syntaxErr) && len(body) > 0 && syntaxErr.Offset >= int64(len(body)) {
+		truncated = true
+	}
+
+	snippet := body
+	if len(snippet) > maxSnippetLength {
+		snippet = snippet[:maxSnippetLength]
+	}
+
+	return &ErrorDecode{
+		Host:       host,
+		StatusCode: statusCode,
+		Snippet:    strings.ToValidUTF8(string(snippet), "�"),
+		Err:        err,
+		truncated:  truncated,
+	}
+}
+
+func (e *ErrorDecode) Error() string {
+	return fmt.Sprintf("%s: failed to decode response (%d): %s: %q",
+		e.Host, e.StatusCode, e.Err, e.Snippet)
+}
+
+func (e *ErrorDecode) Unwrap() error {
+	return e.Err
+}
+
+// Retryable returns whether the request may succeed if retried. This is the
+// case for server errors, rate limiting, and truncated response bodies.
+func (e *ErrorDecode) Retryable() bool {
+	return e.truncated ||
+		e.StatusCode >= http.StatusInternalServerError ||
+		e.StatusCode == http.StatusTooManyRequests
+}
+
+// HTTPStatusCode returns the status code of the response.
+func (e *ErrorDecode) HTTPStatusCode() int {
+	return e.StatusCode
+}
+
+func IsDecode(err error) bool {
+	var decode *ErrorDecode
+	return errors.As(err, &decode)
+}
+
+// ErrorResponseTooLarge is returned when a registry response body is larger
+// than the maximum size permitted.
+type ErrorResponseTooLarge struct {
+	Host string
+
+	// MaxBytes is the maximum size in bytes of response bodies.
+	MaxBytes int64
+}
+
+func NewErrorResponseTooLarge(host string, maxBytes int64) *ErrorResponseTooLarge {
+	return &ErrorResponseTooLarge{Host: host, MaxBytes: maxBytes}
+}
+
+func (e *ErrorResponseTooLarge) Error() string {
+	return fmt.Sprintf("%s: response body exceeds maximum size of %d bytes", e.Host, e.MaxBytes)
+}
+
+func IsResponseTooLarge(err error) bool {
+	var tooLarge *ErrorResponseTooLarge
+	return errors.As(err, &tooLarge)
+}
+
+// ErrorRedirect is returned when a registry redirects a request in a loop, or
+// more times than permitted.
+type ErrorRedirect struct {
+	Host string
+
+	// URL is the redirect which was not followed.
+	URL string
+
+	// Redirects is the number of redirects followed.
+	Redirects int
+
+	// Loop is whether the redirect revisits a URL of the request.
+	Loop bool
+}
+
+// NewErrorRedirect returns a new ErrorRedirect of the given registry host,
+// redirect URL which was not followed, number of redirects followed, and
+// whether the redirect is a loop.
+func NewErrorRedirect(host, url string, redirects int, loop bool) *ErrorRedirect {
+	return &ErrorRedirect{Host: host, URL: url, Redirects: redirects, Loop: loop}
+}
+
+func (e *ErrorRedirect) Error() string {
+	if e.Loop {
+		return fmt.Sprintf("%s: redirect loop after %d redirects, to %q", e.Host, e.Redirects, e.URL)
+	}
+
+	return fmt.Sprintf("%s: stopped after %d redirects, to %q", e.Host, e.Redirects, e.URL)
+}
+
+func IsRedirect(err error) bool {
+	var redirect *ErrorRedirect
+	return errors.As(err, &redirect)
+}
+
+// NetworkErrorKind is the kind of failure of an ErrorNetwork.
+type NetworkErrorKind string
+
+const (
+	// NetworkErrorTimeout is a timeout dialing or reading from the registry.
+	NetworkErrorTimeout NetworkErrorKind = "timeout"
+	// NetworkErrorDNSNotFound is a registry host which does not exist.
+	NetworkErrorDNSNotFound NetworkErrorKind = "dns_not_found"
+	// NetworkErrorDNSTemporary is a failure to resolve the registry host,
+	// other than the host not existing.
+	NetworkErrorDNSTemporary NetworkErrorKind = "dns_temporary"
+	// NetworkErrorConnectionRefused is a registry refusing the connection.
+	NetworkErrorConnectionRefused NetworkErrorKind = "connection_refused"
+	// NetworkErrorConnectionReset is a connection reset by the registry.
+	NetworkErrorConnectionReset NetworkErrorKind = "connection_reset"
+)
+
+// ErrorNetwork is returned when a request to a registry fails to be made, or
+// its connection fails.
+type ErrorNetwork struct {
+	Host string
+	Kind NetworkErrorKind
+	Err  error
+}
+
+// ClassifyNetworkError returns the given error of a request to the given
+// registry host as an ErrorNetwork, if it is a network failure of a known
+// kind. Otherwise, the error is returned unchanged.
+func ClassifyNetworkError(host string, err error) error {
+	kind, ok := networkErrorKind(err)
+	if !ok {
+		return err
+	}
+
+	return &ErrorNetwork{Host: host, Kind: kind, Err: err}
+}
+
+// networkErrorKind returns the kind of network failure of the given error,
+// if any.
+func networkErrorKind(err error) (NetworkErrorKind, bool) {
+	if err == nil {
+		return "", false
+	}
+
+	var dnsErr *net.DNSError
+	if errors.As(err, &dnsErr) {
+		switch {
+		case dnsErr.IsNotFound:
+			return NetworkErrorDNSNotFound, true
+		case dnsErr.IsTimeout:
+			return NetworkErrorTimeout, true
+		default:
+			return NetworkErrorDNSTemporary, true
+		}
+	}
+
+	switch {
+	case errors.Is(err, syscall.ECONNREFUSED):
+		return NetworkErrorConnectionRefused, true
+	case errors.Is(err, syscall.ECONNRESET):
+		return NetworkErrorConnectionReset, true
+	}
+
+	var netErr net.Error
+	if errors.As(err, &netErr) && netErr.Timeout() {
+		return NetworkErrorTimeout, true
+	}
+
+	return "", false
+}
+
+func (e *ErrorNetwork) Error() string {
+	return fmt.Sprintf("%s: network error (%s): %s", e.Host, e.Kind, e.Err)
+}
+
+func (e *ErrorNetwork) Unwrap() error {
+	return e.Err
+}
+
+// Retryable returns whether the request may succeed if retried. This is the
+// case for all network errors, other than a registry host which does not
+// exist.
+func (e *ErrorNetwork) Retryable() bool {
+	return e.Kind != NetworkErrorDNSNotFound
+}
+
+func IsNetwork(err error) bool {
+	var network *ErrorNetwork
+	return errors.As(err, &network)
+}
+
+// StatusCoder is implemented by errors of registry responses, returning the
+// status code of the response.
+type StatusCoder interface {
+	HTTPStatusCode() int
+}
+
+// IsRegistryFailure returns whether the given error is a failure of the
+// registry itself, rather than of the request, such as a missing image or
+// rejected credentials. This is the case for network errors, timeouts, and
+// responses of server errors or rate limiting, so that only these count
+// against the health of a registry host.
+func IsRegistryFailure(err error) bool {
+	if err == nil {
+		return false
+	}
+
+	var network *ErrorNetwork
+	if errors.As(err, &network) || errors.Is(err, context.DeadlineExceeded) || IsRetryable(err) {
+		return true
+	}
+
+	var status StatusCoder
+	if errors.As(err, &status) {
+		code := status.HTTPStatusCode()
+		return code >= http.StatusInternalServerError || code == http.StatusTooManyRequests
+	}
+
+	return false
+}
+
+// IsRetryable returns whether the given error is a decode or network error
+// that may succeed if retried.
+func IsRetryable(err error) bool {
+	var decode *ErrorDecode
+	if errors.As(err, &decode) {
+		return decode.Retryable()
+	}
+
+	var network *ErrorNetwork
+	return errors.As(err, &network) && network.Retryable()
+}
@@ -0,0 +1,224 @@
+package errors
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"os"
+	"strings"
+	"syscall"
+	"testing"
+)
+
+func TestErrorDecode(t *testing.T) {
+	tests := map[string]struct {
+		statusCode   int
+		body         string
+		expRetryable bool
+		expSnippet   string
+	}{
+		"garbage body with 200 should not be retryable": {
+			statusCode:   200,
+			body:         "<html>hello</html>",
+			expRetryable: false,
+			expSnippet:   "<html>hello</html>",
+		},
+		"garbage body with 502 should be retryable": {
+			statusCode:   502,
+			body:         "<html>bad gateway</html>",
+			expRetryable: true,
+			expSnippet:   "<html>bad gateway</html>",
+		},
+		"garbage body with 429 should be retryable": {
+			statusCode:   429,
+			body:         "slow down",
+			expRetryable: true,
+			expSnippet:   "slow down",
+		},
+		"truncated body with 200 should be retryable": {
+			statusCode:   200,
+			body:         `{"tags": ["v0.1.0", "v0.2`,
+			expRetryable: true,
+			expSnippet:   `{"tags": ["v0.1.0", "v0.2`,
+		},
+		"non utf8 body should have a valid utf8 snippet": {
+			statusCode:   200,
+			body:         "\xff\xfe{",
+			expRetryable: false,
+			expSnippet:   "�{",
+		},
+		"long body should be truncated in snippet": {
+			statusCode:   200,
+			body:         "x" + strings.Repeat("a", 1000),
+			expRetryable: false,
+			expSnippet:   "x" + strings.Repeat("a", maxSnippetLength-1),
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			var obj map[string]interface{}
+			decodeErr := json.Unmarshal([]byte(test.body), &obj)
+			if decodeErr == nil {
+				t.Fatal("expected decode error, got none")
+			}
+
+			err := fmt.Errorf("wrapped: %w",
+				NewErrorDecode("quay.io", test.statusCode, []byte(test.body), decodeErr))
+
+			if !IsDecode(err) {
+				t.Errorf("expected decode error, got: %v", err)
+			}
+			if retryable := IsRetryable(err); retryable != test.expRetryable {
+				t.Errorf("unexpected retryable, exp=%t got=%t", test.expRetryable, retryable)
+			}
+
+			var decode *ErrorDecode
+			if ok := errors.As(err, &decode); !ok || decode.Snippet != test.expSnippet {
+				t.Errorf("unexpected snippet, exp=%q got=%q", test.expSnippet, decode.Snippet)
+			}
+			if !strings.Contains(err.Error(), "quay.io") ||
+				!strings.Contains(err.Error(), fmt.Sprintf("(%d)", test.statusCode)) {
+				t.Errorf("expected host and status in error, got: %s", err)
+			}
+		})
+	}
+}
+
+func TestErrorNetwork(t *testing.T) {
+	tests := map[string]struct {
+		err          error
+		expNetwork   bool
+		expKind      NetworkErrorKind
+		expRetryable bool
+	}{
+		"dial timeout should be retryable": {
+			err:          &net.OpError{Op: "dial", Net: "tcp", Err: &timeoutError{}},
+			expNetwork:   true,
+			expKind:      NetworkErrorTimeout,
+			expRetryable: true,
+		},
+		"dns timeout should be retryable": {
+			err:          &net.DNSError{Err: "i/o timeout", Name: "quay.io", IsTimeout: true},
+			expNetwork:   true,
+			expKind:      NetworkErrorTimeout,
+			expRetryable: true,
+		},
+		"dns not found should not be retryable": {
+			err:          &net.OpError{Op: "dial", Net: "tcp", Err: &net.DNSError{Err: "no such host", Name: "quay.io", IsNotFound: true}},
+			expNetwork:   true,
+			expKind:      NetworkErrorDNSNotFound,
+			expRetryable: false,
+		},
+		"temporary dns failure should be retryable": {
+			err:          &net.DNSError{Err: "server misbehaving", Name: "quay.io", IsTemporary: true},
+			expNetwork:   true,
+			expKind:      NetworkErrorDNSTemporary,
+			expRetryable: true,
+		},
+		"connection refused should be retryable": {
+			err:          &net.OpError{Op: "dial", Net: "tcp", Err: os.NewSyscallError("connect", syscall.ECONNREFUSED)},
+			expNetwork:   true,
+			expKind:      NetworkErrorConnectionRefused,
+			expRetryable: true,
+		},
+		"connection reset should be retryable": {
+			err:          &net.OpError{Op: "read", Net: "tcp", Err: os.NewSyscallError("read", syscall.ECONNRESET)},
+			expNetwork:   true,
+			expKind:      NetworkErrorConnectionReset,
+			expRetryable: true,
+		},
+		"other error should be unchanged": {
+			err:          errors.New("unsupported protocol scheme"),
+			expNetwork:   false,
+			expRetryable: false,
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			err := fmt.Errorf("wrapped: %w", ClassifyNetworkError("quay.io", test.err))
+
+			if network := IsNetwork(err); network != test.expNetwork {
+				t.Fatalf("unexpected network error, exp=%t got=%v", test.expNetwork, err)
+			}
+			if retryable := IsRetryable(err); retryable != test.expRetryable {
+				t.Errorf("unexpected retryable, exp=%t got=%t", test.expRetryable, retryable)
+			}
+			if !errors.Is(err, test.err) {
+				t.Errorf("expected error to wrap %v, got: %v", test.err, err)
+			}
+			if !test.expNetwork {
+				return
+			}
+
+			var network *ErrorNetwork
+			if ok := errors.As(err, &network); !ok || network.Kind != test.expKind || network.Host != "quay.io" {
+				t.Errorf("unexpected network error, exp=%s from %q got=%s from %q",
+					test.expKind, "quay.io", network.Kind, network.Host)
+			}
+		})
+	}
+}
+
+// timeoutError is a net.Error which has timed out.
+type timeoutError struct{}
+
+func (*timeoutError) Error() string   { return "i/o timeout" }
+func (*timeoutError) Timeout() bool   { return true }
+func (*timeoutError) Temporary() bool { return true }
+
+func TestIsRegistryFailure(t *testing.T) {
+	tests := map[string]struct {
+		err error
+		exp bool
+	}{
+		"server error should be a registry failure": {
+			err: &statusError{code: http.StatusBadGateway},
+			exp: true,
+		},
+		"rate limiting should be a registry failure": {
+			err: NewErrorDecode("quay.io", http.StatusTooManyRequests, nil, errors.New("invalid character")),
+			exp: true,
+		},
+		"network error should be a registry failure": {
+			err: ClassifyNetworkError("quay.io", &net.OpError{Op: "dial", Net: "tcp",
+				Err: os.NewSyscallError("connect", syscall.ECONNREFUSED)}),
+			exp: true,
+		},
+		"timeout should be a registry failure": {
+			err: fmt.Errorf("failed to get tags: %w", context.DeadlineExceeded),
+			exp: true,
+		},
+		"not found should not be a registry failure": {
+			err: &statusError{code: http.StatusNotFound},
+		},
+		"unauthorized should not be a registry failure": {
+			err: NewErrorUnauthorized("quay.io", "jetstack/version-checker", http.StatusUnauthorized),
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			if got := IsRegistryFailure(test.err); got != test.exp {
+				t.Errorf("unexpected registry failure, exp=%t got=%t", test.exp, got)
+			}
+		})
+	}
+}
+
+// statusError is an error of a registry response with a status code.
+type statusError struct {
+	code int
+}
+
+func (s *statusError) Error() string {
+	return http.StatusText(s.code)
+}
+
+func (s *statusError) HTTPStatusCode() int {
+	return s.code
+}
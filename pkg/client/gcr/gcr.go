@@ -10,6 +10,8 @@ import (
 	"time"
 
 	"github.com/jetstack/version-checker/pkg/api"
+	clienterrors "github.com/jetstack/version-checker/pkg/client/errors"
+	"github.com/jetstack/version-checker/pkg/client/util"
 )
 
 const (
@@ -38,7 +40,8 @@ func New(opts Options) *Client {
 	return &Client{
 		Options: opts,
 		Client: &http.Client{
-			Timeout: time.Second * 5,
+			Timeout:       time.Second * 5,
+			CheckRedirect: util.CheckRedirect,
 		},
 	}
 }
@@ -59,15 +62,19 @@ func (c *Client) Tags(ctx context.Context, host, repo, image string) ([]api.Imag
 		return nil, err
 	}
 
-	if len(c.Token) > 0 {
-		req.SetBasicAuth("oauth2accesstoken", c.Token)
+	token := c.Token
+	if creds, ok := api.CredentialsFromContext(ctx); ok {
+		token = creds.Token
+	}
+	if len(token) > 0 {
+		req.SetBasicAuth("oauth2accesstoken", token)
 	}
 
 	req = req.WithContext(ctx)
 
 	resp, err := c.Do(req)
 	if err != nil {
-		return nil, fmt.Errorf("failed to get docker image: %s", err)
+		return nil, fmt.Errorf("failed to get docker image: %w", err)
 	}
 
 	body, err := ioutil.ReadAll(resp.Body)
@@ -77,7 +84,7 @@ func (c *Client) Tags(ctx context.Context, host, repo, image string) ([]api.Imag
 
 	var response Response
 	if err := json.Unmarshal(body, &response); err != nil {
-		return nil, err
+		return nil, clienterrors.NewErrorDecode(host, resp.StatusCode, body, err)
 	}
 
 	var tags []api.ImageTag
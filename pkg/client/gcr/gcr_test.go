@@ -0,0 +1,64 @@
+package gcr
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	clienterrors "github.com/jetstack/version-checker/pkg/client/errors"
+)
+
+func TestTagsMalformedResponse(t *testing.T) {
+	tests := map[string]struct {
+		statusCode   int
+		body         string
+		expRetryable bool
+	}{
+		"garbage body with 200 should not be retryable": {
+			statusCode:   http.StatusOK,
+			body:         "<html>not json</html>",
+			expRetryable: false,
+		},
+		"garbage body with 502 should be retryable": {
+			statusCode:   http.StatusBadGateway,
+			body:         "<html>bad gateway</html>",
+			expRetryable: true,
+		},
+		"truncated body with 200 should be retryable": {
+			statusCode:   http.StatusOK,
+			body:         `{"manifest": {"sha256:aaa": {"tag": ["v0.1.0"`,
+			expRetryable: true,
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.WriteHeader(test.statusCode)
+				w.Write([]byte(test.body))
+			}))
+			defer server.Close()
+
+			client := New(Options{})
+			client.Client = server.Client()
+
+			host := strings.TrimPrefix(server.URL, "https://")
+			_, err := client.Tags(context.TODO(), host, "jetstack-cre", "version-checker")
+			if !clienterrors.IsDecode(err) {
+				t.Fatalf("expected decode error, got: %v", err)
+			}
+
+			if retryable := clienterrors.IsRetryable(err); retryable != test.expRetryable {
+				t.Errorf("unexpected retryable, exp=%t got=%t", test.expRetryable, retryable)
+			}
+
+			var decodeErr *clienterrors.ErrorDecode
+			if !errors.As(err, &decodeErr) || decodeErr.Host != host || decodeErr.Snippet != test.body {
+				t.Errorf("expected host and body snippet in error, got: %s", err)
+			}
+		})
+	}
+}
@@ -27,6 +27,14 @@ func TestIsHost(t *testing.T) {
 			host:  "k8s.gcr.io",
 			expIs: true,
 		},
+		"gcr.io with a port should be true": {
+			host:  "gcr.io:443",
+			expIs: true,
+		},
+		"gcr.io in mixed case should be true": {
+			host:  "EU.GCR.io",
+			expIs: true,
+		},
 		"foodgcr.io should be false": {
 			host:  "foogcr.io",
 			expIs: false,
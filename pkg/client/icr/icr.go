@@ -0,0 +1,199 @@
+package icr
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/jetstack/version-checker/pkg/api"
+	clienterrors "github.com/jetstack/version-checker/pkg/client/errors"
+	"github.com/jetstack/version-checker/pkg/client/util"
+)
+
+const (
+	iamTokenURL = "https://iam.cloud.ibm.com/identity/token"
+	// {host}/api/v1/images?repository={repo/image}
+	lookupURL = "%s://%s/api/v1/images?includeIBM=false&repository=%s"
+
+	apiKeyGrantType = "urn:ibm:params:oauth:grant-type:apikey"
+)
+
+type Options struct {
+	APIKey  string
+	Account string
+}
+
+type Client struct {
+	*http.Client
+	Options
+
+	tokenURL   string
+	httpScheme string
+
+	tokens *util.TokenCache
+}
+
+type TokenResponse struct {
+	AccessToken string `json:"access_token"`
+	Expiration  int64  `json:"expiration"`
+}
+
+type Image struct {
+	ID          string   `json:"Id"`
+	Created     int64    `json:"Created"`
+	RepoTags    []string `json:"RepoTags"`
+	RepoDigests []string `json:"RepoDigests"`
+}
+
+func New(opts Options) *Client {
+	return &Client{
+		Options: opts,
+		Client: &http.Client{
+			Timeout:       time.Second * 5,
+			CheckRedirect: util.CheckRedirect,
+		},
+		tokenURL:   iamTokenURL,
+		httpScheme: "https",
+		tokens:     util.NewTokenCache(),
+	}
+}
+
+func (c *Client) Name() string {
+	return "icr"
+}
+
+func (c *Client) Tags(ctx context.Context, host, repo, image string) ([]api.ImageTag, error) {
+	path := util.JoinRepoImage(repo, image)
+	lookup := fmt.Sprintf(lookupURL, c.httpScheme, host, url.QueryEscape(path))
+
+	req, err := http.NewRequest(http.MethodGet, lookup, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	if creds, ok := api.CredentialsFromContext(ctx); ok {
+		// Context credentials are an IAM access token.
+		req.Header.Add("Authorization", "Bearer "+creds.Token)
+	} else if len(c.APIKey) > 0 {
+		token, err := c.getToken(ctx)
+		if err != nil {
+			return nil, fmt.Errorf("%s: failed to get iam token: %s", host, err)
+		}
+
+		req.Header.Add("Authorization", "Bearer "+token)
+	}
+	if len(c.Account) > 0 {
+		req.Header.Add("Account", c.Account)
+	}
+
+	req = req.WithContext(ctx)
+
+	resp, err := c.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get icr image: %w", err)
+	}
+
+	body, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("bad request for image host %s (%d): %s",
+			host, resp.StatusCode, body)
+	}
+
+	var images []Image
+	if err := json.Unmarshal(body, &images); err != nil {
+		return nil, clienterrors.NewErrorDecode(host, resp.StatusCode, body, err)
+	}
+
+	var tags []api.ImageTag
+	for _, img := range images {
+		sha := img.ID
+		if len(img.RepoDigests) > 0 {
+			if split := strings.SplitN(img.RepoDigests[0], "@", 2); len(split) == 2 {
+				sha = split[1]
+			}
+		}
+
+		timestamp := time.Unix(img.Created, 0)
+
+		// Continue early if no tags available
+		if len(img.RepoTags) == 0 {
+			tags = append(tags, api.ImageTag{
+				SHA:       sha,
+				Timestamp: timestamp,
+			})
+
+			continue
+		}
+
+		for _, repoTag := range img.RepoTags {
+			// Tags are returned as the full image reference,
+			// {host}/{repo}/{image}:{tag}
+			lastColonIndex := strings.LastIndex(repoTag, ":")
+			if lastColonIndex == -1 || strings.LastIndex(repoTag, "/") > lastColonIndex {
+				continue
+			}
+
+			tags = append(tags, api.ImageTag{
+				Tag:       repoTag[lastColonIndex+1:],
+				SHA:       sha,
+				Timestamp: timestamp,
+			})
+		}
+	}
+
+	return tags, nil
+}
+
+// getToken will return a valid IAM access token, exchanging the API key for a
+// new token if the cached token is not set or is due to expire.
+func (c *Client) getToken(ctx context.Context) (string, error) {
+	return c.tokens.Get(ctx, c.tokenURL, apiKeyGrantType, c.requestToken)
+}
+
+// requestToken will exchange the API key for a new IAM access token.
+func (c *Client) requestToken(ctx context.Context) (string, time.Time, error) {
+	form := url.Values{
+		"grant_type": []string{apiKeyGrantType},
+		"apikey":     []string{c.APIKey},
+	}
+
+	req, err := http.NewRequest(http.MethodPost, c.tokenURL, strings.NewReader(form.Encode()))
+	if err != nil {
+		return "", time.Time{}, err
+	}
+
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Set("Accept", "application/json")
+	req = req.WithContext(ctx)
+
+	resp, err := c.Do(req)
+	if err != nil {
+		return "", time.Time{}, err
+	}
+
+	body, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return "", time.Time{}, err
+	}
+
+	if resp.StatusCode != http.StatusOK {
+		return "", time.Time{}, fmt.Errorf("unexpected token response (%d): %s",
+			resp.StatusCode, body)
+	}
+
+	response := new(TokenResponse)
+	if err := json.Unmarshal(body, response); err != nil {
+		return "", time.Time{}, err
+	}
+
+	return response.AccessToken, time.Unix(response.Expiration, 0), nil
+}
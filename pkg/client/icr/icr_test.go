@@ -0,0 +1,152 @@
+package icr
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/jetstack/version-checker/pkg/api"
+)
+
+func TestTags(t *testing.T) {
+	var tokenRequests int
+
+	tokenServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		tokenRequests++
+
+		if err := r.ParseForm(); err != nil {
+			t.Fatal(err)
+		}
+		if r.Form.Get("grant_type") != apiKeyGrantType || r.Form.Get("apikey") != "my-api-key" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+
+		fmt.Fprintf(w, `{"access_token": "my-iam-token", "expiration": %d}`,
+			time.Now().Add(time.Hour).Unix())
+	}))
+	defer tokenServer.Close()
+
+	registryServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer my-iam-token" ||
+			r.Header.Get("Account") != "my-account" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+
+		if r.URL.Path != "/api/v1/images" || r.URL.Query().Get("repository") != "namespace/image" {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+
+		host := r.Host
+		fmt.Fprintf(w, `[
+{"Id": "sha256:111", "Created": 1600000000, "RepoTags": ["%s/namespace/image:v0.1.0", "%s/namespace/image:latest"], "RepoDigests": ["%s/namespace/image@sha256:aaa"]},
+{"Id": "sha256:222", "Created": 1500000000, "RepoTags": [], "RepoDigests": []}
+]`, host, host, host)
+	}))
+	defer registryServer.Close()
+
+	client := New(Options{
+		APIKey:  "my-api-key",
+		Account: "my-account",
+	})
+	client.tokenURL = tokenServer.URL
+	client.httpScheme = "http"
+
+	host := strings.TrimPrefix(registryServer.URL, "http://")
+
+	for i := 0; i < 2; i++ {
+		tags, err := client.Tags(context.TODO(), host, "namespace", "image")
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		expTags := []api.ImageTag{
+			{Tag: "v0.1.0", SHA: "sha256:aaa", Timestamp: time.Unix(1600000000, 0)},
+			{Tag: "latest", SHA: "sha256:aaa", Timestamp: time.Unix(1600000000, 0)},
+			{SHA: "sha256:222", Timestamp: time.Unix(1500000000, 0)},
+		}
+		if !reflect.DeepEqual(tags, expTags) {
+			t.Errorf("unexpected tags, exp=%+v got=%+v", expTags, tags)
+		}
+	}
+
+	if tokenRequests != 1 {
+		t.Errorf("expected iam token to be cached, exp=1 got=%d requests", tokenRequests)
+	}
+}
+
+func TestTagsConcurrentTokenFetch(t *testing.T) {
+	var tokenRequests int32
+
+	tokenServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&tokenRequests, 1)
+
+		// Hold the token request open so that all Tags calls wait on it.
+		time.Sleep(time.Millisecond * 50)
+
+		fmt.Fprintf(w, `{"access_token": "my-iam-token", "expiration": %d}`,
+			time.Now().Add(time.Hour).Unix())
+	}))
+	defer tokenServer.Close()
+
+	registryServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer my-iam-token" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+
+		fmt.Fprint(w, `[]`)
+	}))
+	defer registryServer.Close()
+
+	client := New(Options{APIKey: "my-api-key"})
+	client.tokenURL = tokenServer.URL
+	client.httpScheme = "http"
+
+	host := strings.TrimPrefix(registryServer.URL, "http://")
+
+	var wg sync.WaitGroup
+	errs := make(chan error, 20)
+	for i := 0; i < 20; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if _, err := client.Tags(context.TODO(), host, "namespace", "image"); err != nil {
+				errs <- err
+			}
+		}()
+	}
+	wg.Wait()
+	close(errs)
+
+	for err := range errs {
+		t.Error(err)
+	}
+
+	if n := atomic.LoadInt32(&tokenRequests); n != 1 {
+		t.Errorf("expected a single token fetch to serve all calls, exp=1 got=%d requests", n)
+	}
+}
+
+func TestTagsBadAPIKey(t *testing.T) {
+	tokenServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusBadRequest)
+	}))
+	defer tokenServer.Close()
+
+	client := New(Options{APIKey: "bad-key"})
+	client.tokenURL = tokenServer.URL
+
+	if _, err := client.Tags(context.TODO(), "us.icr.io", "namespace", "image"); err == nil {
+		t.Error("expected error from bad api key, got none")
+	}
+}
@@ -0,0 +1,38 @@
+package icr
+
+import (
+	"regexp"
+	"strings"
+
+	"github.com/jetstack/version-checker/pkg/client/util"
+)
+
+var (
+	// Matches the global and regional hosts, e.g. icr.io, us.icr.io,
+	// private.de.icr.io
+	reg = regexp.MustCompile(`(^(.*\.)?icr.io$)`)
+)
+
+func (c *Client) IsHost(host string) bool {
+	return reg.MatchString(util.NormalizeHost(host))
+}
+
+// DisplayName returns the human readable name of the registry.
+func (c *Client) DisplayName() string {
+	return "IBM Cloud Container Registry"
+}
+
+// HostPatterns returns example patterns of the hosts matched by IsHost.
+func (c *Client) HostPatterns() []string {
+	return []string{"icr.io", "*.icr.io"}
+}
+
+func (c *Client) RepoImageFromPath(path string) (string, string) {
+	lastIndex := strings.LastIndex(path, "/")
+
+	if lastIndex == -1 {
+		return path, ""
+	}
+
+	return path[:lastIndex], path[lastIndex+1:]
+}
@@ -0,0 +1,91 @@
+package icr
+
+import "testing"
+
+func TestIsHost(t *testing.T) {
+	tests := map[string]struct {
+		host  string
+		expIs bool
+	}{
+		"an empty host should be false": {
+			host:  "",
+			expIs: false,
+		},
+		"random string should be false": {
+			host:  "foobar",
+			expIs: false,
+		},
+		"random string with dots should be false": {
+			host:  "foobar.foo",
+			expIs: false,
+		},
+		"just icr.io should be true": {
+			host:  "icr.io",
+			expIs: true,
+		},
+		"regional host should be true": {
+			host:  "us.icr.io",
+			expIs: true,
+		},
+		"regional host in mixed case with a port should be true": {
+			host:  "US.icr.io:443",
+			expIs: true,
+		},
+		"private regional host should be true": {
+			host:  "private.jp2.icr.io",
+			expIs: true,
+		},
+		"fooicr.io should be false": {
+			host:  "fooicr.io",
+			expIs: false,
+		},
+		"icr.iofoo should be false": {
+			host:  "icr.iofoo",
+			expIs: false,
+		},
+	}
+
+	handler := new(Client)
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			if isHost := handler.IsHost(test.host); isHost != test.expIs {
+				t.Errorf("%s: unexpected IsHost, exp=%t got=%t",
+					test.host, test.expIs, isHost)
+			}
+		})
+	}
+}
+
+func TestRepoImage(t *testing.T) {
+	tests := map[string]struct {
+		path              string
+		expRepo, expImage string
+	}{
+		"single image should return empty image": {
+			path:     "version-checker",
+			expRepo:  "version-checker",
+			expImage: "",
+		},
+		"two segments to path should return both": {
+			path:     "jetstack/version-checker",
+			expRepo:  "jetstack",
+			expImage: "version-checker",
+		},
+		"multiple segments to path should return all in repo, last segment image": {
+			path:     "namespace/team/version-checker",
+			expRepo:  "namespace/team",
+			expImage: "version-checker",
+		},
+	}
+
+	handler := new(Client)
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			repo, image := handler.RepoImageFromPath(test.path)
+			if repo != test.expRepo || image != test.expImage {
+				t.Errorf("%s: unexpected repo/image, exp=%s/%s got=%s/%s",
+					test.path, test.expRepo, test.expImage, repo, image)
+			}
+		})
+	}
+}
@@ -0,0 +1,94 @@
+package client
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/jetstack/version-checker/pkg/client/util"
+)
+
+const (
+	// dockerHubHost is the host of image URLs without a host.
+	dockerHubHost = "docker.io"
+)
+
+// mirror is a rule which rewrites image URLs of a registry host onto a
+// pull-through mirror, where the mirror's path is prefixed to the repository.
+type mirror struct {
+	host       string
+	mirrorHost string
+	mirrorPath string
+}
+
+// mirrors are the mirror rules of a Client, ordered by descending mirror
+// prefix length so that the most specific prefix is matched first.
+type mirrors []mirror
+
+// newMirrors returns the mirror rules of the given registry hosts to mirror
+// prefixes, in the form host[/path].
+func newMirrors(rules map[string]string) (mirrors, error) {
+	var m mirrors
+	for host, prefix := range rules {
+		prefix = strings.Trim(prefix, "/")
+		if len(host) == 0 || len(prefix) == 0 {
+			return nil, fmt.Errorf("invalid registry mirror %q=%q: host and mirror must not be empty",
+				host, prefix)
+		}
+		if strings.Contains(prefix, "://") {
+			return nil, fmt.Errorf("invalid registry mirror %q=%q: mirror must not contain a scheme",
+				host, prefix)
+		}
+
+		split := strings.SplitN(prefix, "/", 2)
+		rule := mirror{host: host, mirrorHost: split[0]}
+		if len(split) == 2 {
+			rule.mirrorPath = split[1]
+		}
+
+		m = append(m, rule)
+	}
+
+	sort.Slice(m, func(i, j int) bool {
+		if len(m[i].prefix()) != len(m[j].prefix()) {
+			return len(m[i].prefix()) > len(m[j].prefix())
+		}
+		return m[i].host < m[j].host
+	})
+
+	return m, nil
+}
+
+// prefix returns the image URL prefix of the mirror.
+func (m mirror) prefix() string {
+	return util.JoinRepoImage(m.mirrorHost, m.mirrorPath)
+}
+
+// forHost returns the mirror rule of the given registry host, if any. Image
+// URLs without a host use the rule of Docker Hub.
+func (m mirrors) forHost(host string) (mirror, bool) {
+	if len(host) == 0 {
+		host = dockerHubHost
+	}
+
+	for _, rule := range m {
+		if rule.host == host {
+			return rule, true
+		}
+	}
+
+	return mirror{}, false
+}
+
+// original will return the logical image URL of an image URL referencing a
+// mirror, or the image URL unchanged if it does not reference a mirror.
+// e.g. mirror.internal/docker.io/library/nginx -> docker.io/library/nginx
+func (m mirrors) original(imageURL string) string {
+	for _, rule := range m {
+		if path := strings.TrimPrefix(imageURL, rule.prefix()+"/"); path != imageURL {
+			return rule.host + "/" + path
+		}
+	}
+
+	return imageURL
+}
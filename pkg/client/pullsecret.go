@@ -0,0 +1,130 @@
+package client
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/jetstack/version-checker/pkg/api"
+)
+
+// dockerHubAliases are the hosts of Docker Hub used by docker config files,
+// which are registered as credentials of dockerHubHost.
+var dockerHubAliases = map[string]bool{
+	"index.docker.io":         true,
+	"registry-1.docker.io":    true,
+	"registry.hub.docker.com": true,
+}
+
+// dockerConfigJSON is the content of a kubernetes.io/dockerconfigjson Secret.
+type dockerConfigJSON struct {
+	Auths map[string]dockerConfigAuth `json:"auths"`
+}
+
+// dockerConfigAuth are the credentials of a single registry of a docker
+// config file.
+type dockerConfigAuth struct {
+	Username      string `json:"username"`
+	Password      string `json:"password"`
+	Auth          string `json:"auth"`
+	RegistryToken string `json:"registrytoken"`
+}
+
+// ParseDockerConfigJSON will return the registry credentials of the given
+// decoded .dockerconfigjson data of a kubernetes.io/dockerconfigjson Secret,
+// keyed by registry host. Registry keys may be URLs, where the scheme and path
+// are removed, and Docker Hub's hosts are keyed by "docker.io". Credentials of
+// the auth field, a base64 encoded username:password, are used if no username
+// and password are set. Identity tokens are not supported, so are ignored.
+func ParseDockerConfigJSON(data []byte) (map[string]*api.Credentials, error) {
+	var config dockerConfigJSON
+	if err := json.Unmarshal(data, &config); err != nil {
+		return nil, fmt.Errorf("failed to decode docker config: %s", err)
+	}
+
+	creds := make(map[string]*api.Credentials, len(config.Auths))
+	for registry, auth := range config.Auths {
+		if len(registry) == 0 {
+			return nil, errors.New("invalid docker config: empty registry")
+		}
+		host := credentialsHost(registry)
+
+		username, password := auth.Username, auth.Password
+		if len(username) == 0 && len(password) == 0 && len(auth.Auth) > 0 {
+			decoded, err := base64.StdEncoding.DecodeString(auth.Auth)
+			if err != nil {
+				return nil, fmt.Errorf("failed to decode auth of docker config registry %q: %s", registry, err)
+			}
+
+			split := strings.SplitN(string(decoded), ":", 2)
+			if len(split) != 2 {
+				return nil, fmt.Errorf("invalid auth of docker config registry %q: expected username:password", registry)
+			}
+			username, password = split[0], split[1]
+		}
+
+		creds[host] = &api.Credentials{
+			Username: username,
+			Password: password,
+			Token:    auth.RegistryToken,
+		}
+	}
+
+	return creds, nil
+}
+
+// AddDockerConfigJSON will register the registry credentials of the given
+// decoded .dockerconfigjson data of a kubernetes.io/dockerconfigjson Secret
+// as the host credentials of the options, replacing the credentials of any
+// host already registered. See ParseDockerConfigJSON.
+func (o *Options) AddDockerConfigJSON(data []byte) error {
+	creds, err := ParseDockerConfigJSON(data)
+	if err != nil {
+		return err
+	}
+
+	if o.HostCredentials == nil {
+		o.HostCredentials = make(map[string]*api.Credentials, len(creds))
+	}
+	for host, c := range creds {
+		o.HostCredentials[host] = c
+	}
+
+	return nil
+}
+
+// credentialsHost returns the host the credentials of the given registry are
+// registered by, being lower case, without any scheme or path.
+// e.g. https://index.docker.io/v1/ -> docker.io
+func credentialsHost(registry string) string {
+	host := strings.ToLower(registry)
+	if i := strings.Index(host, "://"); i >= 0 {
+		host = host[i+3:]
+	}
+	if i := strings.Index(host, "/"); i >= 0 {
+		host = host[:i]
+	}
+
+	if len(host) == 0 || dockerHubAliases[host] {
+		return dockerHubHost
+	}
+
+	return host
+}
+
+// withCredentials returns the given context carrying the host credentials of
+// the given registry host, unless it already carries credentials.
+func (c *Client) withCredentials(ctx context.Context, host string) context.Context {
+	if _, ok := api.CredentialsFromContext(ctx); ok {
+		return ctx
+	}
+
+	if creds, ok := c.credentials[credentialsHost(host)]; ok {
+		return api.ContextWithCredentials(ctx, creds)
+	}
+
+	return ctx
+}
@@ -3,6 +3,8 @@ package quay
 import (
 	"regexp"
 	"strings"
+
+	"github.com/jetstack/version-checker/pkg/client/util"
 )
 
 var (
@@ -10,7 +12,17 @@ var (
 )
 
 func (c *Client) IsHost(host string) bool {
-	return reg.MatchString(host)
+	return reg.MatchString(util.NormalizeHost(host))
+}
+
+// DisplayName returns the human readable name of the registry.
+func (c *Client) DisplayName() string {
+	return "Quay"
+}
+
+// HostPatterns returns example patterns of the hosts matched by IsHost.
+func (c *Client) HostPatterns() []string {
+	return []string{"quay.io", "*.quay.io"}
 }
 
 func (c *Client) RepoImageFromPath(path string) (string, string) {
@@ -27,6 +27,14 @@ func TestIsHost(t *testing.T) {
 			host:  "k8s.quay.io",
 			expIs: true,
 		},
+		"quay.io in mixed case should be true": {
+			host:  "Quay.IO",
+			expIs: true,
+		},
+		"quay.io with a port should be true": {
+			host:  "quay.io:443",
+			expIs: true,
+		},
 		"foodquay.io should be false": {
 			host:  "fooquay.io",
 			expIs: false,
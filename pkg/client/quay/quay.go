@@ -6,13 +6,18 @@ import (
 	"fmt"
 	"io/ioutil"
 	"net/http"
+	"net/url"
+	"strings"
 	"time"
 
 	"github.com/jetstack/version-checker/pkg/api"
+	clienterrors "github.com/jetstack/version-checker/pkg/client/errors"
+	"github.com/jetstack/version-checker/pkg/client/util"
 )
 
 const (
-	lookupURL = "https://quay.io/api/v1/repository/%s/%s/tag/"
+	baseURL   = "https://quay.io"
+	lookupURL = "%s/api/v1/repository/%s/tag/"
 )
 
 type Options struct {
@@ -22,6 +27,9 @@ type Options struct {
 type Client struct {
 	*http.Client
 	Options
+
+	// baseURL is the Quay API endpoint, which may be overridden in tests.
+	baseURL string
 }
 
 type Response struct {
@@ -38,8 +46,10 @@ func New(opts Options) *Client {
 	return &Client{
 		Options: opts,
 		Client: &http.Client{
-			Timeout: time.Second * 5,
+			Timeout:       time.Second * 5,
+			CheckRedirect: util.CheckRedirect,
 		},
+		baseURL: baseURL,
 	}
 }
 
@@ -47,24 +57,33 @@ func (c *Client) Name() string {
 	return "quay"
 }
 
+// Tags will list the tags of the given repo and image. Tags are filtered
+// server side by the tag prefix carried by the context, if any, where Quay
+// lists the tags whose name is like the prefix.
 func (c *Client) Tags(ctx context.Context, _, repo, image string) ([]api.ImageTag, error) {
-	url := fmt.Sprintf(lookupURL, repo, image)
+	lookup := fmt.Sprintf(lookupURL, c.baseURL, repositoryPath(repo, image))
+	if prefix, ok := api.TagPrefixFromContext(ctx); ok {
+		lookup += "?filter_tag_name=" + url.QueryEscape("like:"+prefix)
+	}
 
-	req, err := http.NewRequest(http.MethodGet, url, nil)
+	req, err := http.NewRequest(http.MethodGet, lookup, nil)
 	if err != nil {
 		return nil, err
 	}
 
-	if len(c.Token) > 0 {
-		req.Header.Add("Authorization", "Bearer "+c.Token)
+	token := c.Token
+	if creds, ok := api.CredentialsFromContext(ctx); ok {
+		token = creds.Token
+	}
+	if len(token) > 0 {
+		req.Header.Add("Authorization", "Bearer "+token)
 	}
 
-	req.URL.Scheme = "https"
 	req = req.WithContext(ctx)
 
 	resp, err := c.Do(req)
 	if err != nil {
-		return nil, fmt.Errorf("failed to get quay image: %s", err)
+		return nil, fmt.Errorf("failed to get quay image: %w", err)
 	}
 
 	body, err := ioutil.ReadAll(resp.Body)
@@ -72,9 +91,14 @@ func (c *Client) Tags(ctx context.Context, _, repo, image string) ([]api.ImageTa
 		return nil, err
 	}
 
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("bad request for image %s (%d): %s",
+			util.JoinRepoImage(repo, image), resp.StatusCode, body)
+	}
+
 	var response Response
 	if err := json.Unmarshal(body, &response); err != nil {
-		return nil, err
+		return nil, clienterrors.NewErrorDecode(req.URL.Host, resp.StatusCode, body, err)
 	}
 
 	var tags []api.ImageTag
@@ -93,3 +117,18 @@ func (c *Client) Tags(ctx context.Context, _, repo, image string) ([]api.ImageTa
 
 	return tags, nil
 }
+
+// repositoryPath returns the repository path of the API for the given repo and
+// image. Quay repositories are always of the form {namespace}/{repository},
+// where nested repositories must have the remaining path segments encoded
+// into the repository name.
+func repositoryPath(repo, image string) string {
+	path := util.JoinRepoImage(repo, image)
+
+	split := strings.SplitN(path, "/", 2)
+	if len(split) == 1 {
+		return url.PathEscape(path)
+	}
+
+	return url.PathEscape(split[0]) + "/" + url.PathEscape(split[1])
+}
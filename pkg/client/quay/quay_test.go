@@ -0,0 +1,152 @@
+package quay
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/jetstack/version-checker/pkg/api"
+)
+
+func TestRepositoryPath(t *testing.T) {
+	tests := map[string]struct {
+		repo, image string
+		expPath     string
+	}{
+		"single segment should return as is": {
+			repo:    "version-checker",
+			image:   "",
+			expPath: "version-checker",
+		},
+		"two segments should return namespace and repository": {
+			repo:    "jetstack",
+			image:   "version-checker",
+			expPath: "jetstack/version-checker",
+		},
+		"multiple segments should encode the nested repository": {
+			repo:    "jetstack/team",
+			image:   "version-checker",
+			expPath: "jetstack/team%2Fversion-checker",
+		},
+		"deeply nested segments should encode all into the repository": {
+			repo:    "jetstack/team/apps",
+			image:   "version-checker",
+			expPath: "jetstack/team%2Fapps%2Fversion-checker",
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			if path := repositoryPath(test.repo, test.image); path != test.expPath {
+				t.Errorf("unexpected repository path, exp=%q got=%q", test.expPath, path)
+			}
+		})
+	}
+}
+
+func TestTags(t *testing.T) {
+	timestamp := time.Date(2020, 9, 1, 12, 0, 0, 0, time.UTC)
+
+	responses := map[string]string{
+		"/api/v1/repository/jetstack/version-checker/tag/": `{"tags": [
+      {"name": "v0.1.0", "manifest_digest": "sha256:aaa", "last_modified": "Tue, 01 Sep 2020 12:00:00 -0000"}
+    ]}`,
+		"/api/v1/repository/jetstack/team%2Fversion-checker/tag/": `{"tags": [
+      {"name": "v0.2.0", "manifest_digest": "sha256:bbb", "last_modified": "Tue, 01 Sep 2020 12:00:00 -0000"}
+    ]}`,
+	}
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		response, ok := responses[r.URL.EscapedPath()]
+		if !ok {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		w.Write([]byte(response))
+	}))
+	defer server.Close()
+
+	client := New(Options{})
+	client.baseURL = server.URL
+
+	tests := map[string]struct {
+		path    string
+		expTags []api.ImageTag
+		expErr  bool
+	}{
+		"single segment repository should return tags": {
+			path: "jetstack/version-checker",
+			expTags: []api.ImageTag{
+				{Tag: "v0.1.0", SHA: "sha256:aaa", Timestamp: timestamp},
+			},
+		},
+		"multi segment repository should return tags": {
+			path: "jetstack/team/version-checker",
+			expTags: []api.ImageTag{
+				{Tag: "v0.2.0", SHA: "sha256:bbb", Timestamp: timestamp},
+			},
+		},
+		"unknown repository should error": {
+			path:   "jetstack/foo",
+			expErr: true,
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			repo, image := client.RepoImageFromPath(test.path)
+			tags, err := client.Tags(context.TODO(), "quay.io", repo, image)
+			if (err != nil) != test.expErr {
+				t.Fatalf("unexpected error, exp=%t got=%v", test.expErr, err)
+			}
+
+			for i := range tags {
+				tags[i].Timestamp = tags[i].Timestamp.UTC()
+			}
+			if !reflect.DeepEqual(tags, test.expTags) {
+				t.Errorf("unexpected tags, exp=%+v got=%+v", test.expTags, tags)
+			}
+		})
+	}
+}
+
+func TestTagsPrefixFilter(t *testing.T) {
+	tests := map[string]struct {
+		ctx       context.Context
+		expFilter string
+	}{
+		"tag prefix should be pushed down": {
+			ctx:       api.ContextWithTagPrefix(context.TODO(), "v1.2."),
+			expFilter: "like:v1.2.",
+		},
+		"no tag prefix should not filter": {
+			ctx: context.TODO(),
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			var filters []string
+
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				filters = append(filters, r.URL.Query().Get("filter_tag_name"))
+				w.Write([]byte(`{"tags": []}`))
+			}))
+			defer server.Close()
+
+			client := New(Options{})
+			client.baseURL = server.URL
+
+			if _, err := client.Tags(test.ctx, "quay.io", "jetstack", "version-checker"); err != nil {
+				t.Fatal(err)
+			}
+
+			if len(filters) != 1 || filters[0] != test.expFilter {
+				t.Errorf("unexpected tag filters, exp=[%s] got=%q", test.expFilter, filters)
+			}
+		})
+	}
+}
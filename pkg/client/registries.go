@@ -0,0 +1,72 @@
+package client
+
+// DescribedClient is an ImageClient which is also able to describe the
+// registry it supports, such as for display.
+type DescribedClient interface {
+	ImageClient
+
+	// DisplayName returns the human readable name of the registry.
+	DisplayName() string
+
+	// HostPatterns returns example patterns of the hosts the client is
+	// appropriate for, where "*" matches any subdomain.
+	HostPatterns() []string
+}
+
+// Registry describes a registry supported by a registry client.
+type Registry struct {
+	// Name is the name of the registry client, as returned by ClientName.
+	Name string `json:"name"`
+
+	// DisplayName is the human readable name of the registry. The same as
+	// Name for clients which do not describe their registry.
+	DisplayName string `json:"displayName"`
+
+	// HostPatterns are example patterns of the hosts the client is
+	// appropriate for, if described.
+	HostPatterns []string `json:"hostPatterns,omitempty"`
+
+	// Fallback is whether the client is used for hosts which are not
+	// matched by any other client.
+	Fallback bool `json:"fallback,omitempty"`
+}
+
+// Registries returns the registries supported by the registered registry
+// clients, in the order hosts are matched, including the configured
+// selfhosted registries. The fallback client is last, unless a client match
+// is required.
+func (c *Client) Registries() []Registry {
+	// Clients registered after New replace the slice, so a copy of the slice
+	// is safe to range over once unlocked.
+	c.mu.RLock()
+	clients := c.clients
+	c.mu.RUnlock()
+
+	registries := make([]Registry, 0, len(clients)+1)
+	for _, client := range clients {
+		registries = append(registries, describeClient(client))
+	}
+
+	if !c.requireClientMatch {
+		fallback := describeClient(c.fallbackClient)
+		fallback.Fallback = true
+		registries = append(registries, fallback)
+	}
+
+	return registries
+}
+
+// describeClient returns the registry of the given client.
+func describeClient(client ImageClient) Registry {
+	registry := Registry{
+		Name:        client.Name(),
+		DisplayName: client.Name(),
+	}
+
+	if described, ok := client.(DescribedClient); ok {
+		registry.DisplayName = described.DisplayName()
+		registry.HostPatterns = described.HostPatterns()
+	}
+
+	return registry
+}
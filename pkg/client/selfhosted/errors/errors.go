@@ -18,6 +18,11 @@ func (h *HTTPError) Error() string {
 	return fmt.Sprintf("%s", h.Body)
 }
 
+// HTTPStatusCode returns the status code of the response.
+func (h *HTTPError) HTTPStatusCode() int {
+	return h.StatusCode
+}
+
 func IsHTTPError(err error) (*HTTPError, bool) {
 	httpError, ok := err.(*HTTPError)
 	return httpError, ok
@@ -12,8 +12,36 @@ const (
 	hostRegTemplate = `^.*%s$`
 )
 
+// IsHost will return true if the given host is the configured host. Hosts
+// are matched in lower case, including the port if configured.
 func (c *Client) IsHost(host string) bool {
-	return c.hostRegex.MatchString(host)
+	return c.hostRegex.MatchString(strings.ToLower(host))
+}
+
+// DisplayName returns the human readable name of the registry, being the
+// configured host, or the generic Docker V2 API if no host is configured.
+func (c *Client) DisplayName() string {
+	if len(c.Host) == 0 {
+		return "Docker Registry HTTP API V2"
+	}
+
+	return "Self-hosted registry " + c.Host
+}
+
+// HostPatterns returns example patterns of the hosts matched by IsHost, being
+// the configured host and its subdomains, or any host if no host is
+// configured.
+func (c *Client) HostPatterns() []string {
+	if len(c.Host) == 0 {
+		return []string{"*"}
+	}
+
+	host := strings.ToLower(c.Host)
+	if parsed, err := url.Parse(host); err == nil && len(parsed.Host) > 0 {
+		host = parsed.Host
+	}
+
+	return []string{host, "*." + host}
 }
 
 func (c *Client) RepoImageFromPath(path string) (string, string) {
@@ -31,18 +59,65 @@ func (c *Client) RepoImageFromPath(path string) (string, string) {
 	return path, ""
 }
 
-func parseURL(rawurl string) (*regexp.Regexp, string, error) {
+// parseURL returns the regex matching the host of the given URL, along with
+// the parsed URL.
+func parseURL(rawurl string) (*regexp.Regexp, *url.URL, error) {
 	parsedURL, err := url.Parse(rawurl)
 	if err != nil {
-		return nil, "", fmt.Errorf("failed parsing host %q: %s", rawurl, err)
+		return nil, nil, fmt.Errorf("failed parsing host %q: %s", rawurl, err)
 	}
 
-	hostRegTemplate := fmt.Sprintf(hostRegTemplate, parsedURL.Host)
+	hostRegTemplate := fmt.Sprintf(hostRegTemplate, strings.ToLower(parsedURL.Host))
 	hostRegex, err := regexp.Compile(hostRegTemplate)
 	if err != nil {
-		return nil, "", fmt.Errorf("failed to parse regex: %s for host %q: %s",
+		return nil, nil, fmt.Errorf("failed to parse regex: %s for host %q: %s",
 			hostRegTemplate, parsedURL.Host, err)
 	}
 
-	return hostRegex, parsedURL.Scheme, nil
+	return hostRegex, parsedURL, nil
+}
+
+// baseURL returns the given host joined with the base path of its registry
+// API, without a trailing slash. The base path of the host is used if
+// configured, then BasePath, then the path of Host.
+func (c *Client) baseURL(host string) string {
+	basePath, ok := c.HostBasePaths[strings.ToLower(host)]
+	if !ok {
+		basePath = c.BasePath
+		if len(basePath) == 0 {
+			basePath = c.basePath
+		}
+	}
+
+	host = strings.TrimRight(host, "/")
+	if basePath = cleanPath(basePath); len(basePath) > 0 {
+		return host + "/" + basePath
+	}
+
+	return host
+}
+
+// cleanPath returns the given URL path with empty segments removed, so that
+// leading, trailing or repeated slashes never produce request paths such as
+// /v2//tags/list, which some registries and proxies reject.
+func cleanPath(path string) string {
+	var segments []string
+	for _, segment := range strings.Split(path, "/") {
+		if len(segment) > 0 {
+			segments = append(segments, segment)
+		}
+	}
+
+	return strings.Join(segments, "/")
+}
+
+// referenceImage returns the given image path referenced by the given tag or
+// digest.
+// e.g. jetstack/version-checker:v0.1.0, jetstack/version-checker@sha256:aaa
+func referenceImage(path, reference string) string {
+	if strings.Contains(reference, ":") {
+		return path + "@" + reference
+	}
+
+	return path + ":" + reference
 }
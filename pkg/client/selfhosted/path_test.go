@@ -64,6 +64,14 @@ func TestIsHost(t *testing.T) {
 			host:  "foo.docker.repositories.yourdomain.ext",
 			expIs: true,
 		},
+		"docker.repositories.yourdomain.ext in mixed case should be true": {
+			host:  "Docker.Repositories.YourDomain.EXT",
+			expIs: true,
+		},
+		"docker.repositories.yourdomain.ext with a port should be false": {
+			host:  "docker.repositories.yourdomain.ext:5000",
+			expIs: false,
+		},
 	}
 
 	options := &Options{
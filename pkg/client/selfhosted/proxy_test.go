@@ -0,0 +1,151 @@
+package selfhosted
+
+import (
+	"context"
+	"encoding/base64"
+	"net/http"
+	"net/http/httptest"
+	"net/http/httputil"
+	"net/url"
+	"strings"
+	"sync/atomic"
+	"testing"
+
+	"github.com/sirupsen/logrus"
+
+	selfhostederrors "github.com/jetstack/version-checker/pkg/client/selfhosted/errors"
+)
+
+func TestTagsProxyAuth(t *testing.T) {
+	// leaked counts requests past the proxy which carried proxy credentials.
+	var leaked int32
+
+	tokenServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if len(r.Header.Get("Proxy-Authorization")) > 0 {
+			atomic.AddInt32(&leaked, 1)
+		}
+
+		// Anonymous tokens are issued, which the registry rejects.
+		user, pass, ok := r.BasicAuth()
+		if !ok {
+			w.Write([]byte(`{"access_token": "anonymous-token", "expires_in": 300}`))
+			return
+		}
+		if user != "joshvanl" || pass != "password" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+
+		w.Write([]byte(`{"access_token": "registry-token", "expires_in": 300}`))
+	}))
+	defer tokenServer.Close()
+
+	registry := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if len(r.Header.Get("Proxy-Authorization")) > 0 {
+			atomic.AddInt32(&leaked, 1)
+		}
+
+		if r.Header.Get("Authorization") != "Bearer registry-token" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+
+		if strings.HasSuffix(r.URL.Path, "/tags/list") {
+			w.Write([]byte(`{"tags": ["v0.1.0"]}`))
+			return
+		}
+
+		w.Header().Set("Docker-Content-Digest", "sha256:aaa")
+		w.Write([]byte(`{}`))
+	}))
+	defer registry.Close()
+
+	registryURL, err := url.Parse(registry.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// The proxy requires basic auth of its own, which it consumes, passing
+	// the registry's Authorization header through.
+	proxyAuth := "Basic " + base64.StdEncoding.EncodeToString([]byte("proxy-user:proxy-password"))
+	reverseProxy := httputil.NewSingleHostReverseProxy(registryURL)
+	proxy := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Proxy-Authorization") != proxyAuth {
+			w.WriteHeader(http.StatusProxyAuthRequired)
+			return
+		}
+
+		r.Header.Del("Proxy-Authorization")
+		reverseProxy.ServeHTTP(w, r)
+	}))
+	defer proxy.Close()
+
+	host := strings.TrimPrefix(proxy.URL, "http://")
+
+	tests := map[string]struct {
+		proxyUsername, proxyPassword string
+		username, password           string
+		expStatusCode                int
+	}{
+		"proxy and registry auth should list tags": {
+			proxyUsername: "proxy-user",
+			proxyPassword: "proxy-password",
+			username:      "joshvanl",
+			password:      "password",
+		},
+		"registry auth without proxy auth should be rejected by the proxy": {
+			username:      "joshvanl",
+			password:      "password",
+			expStatusCode: http.StatusProxyAuthRequired,
+		},
+		"wrong proxy auth should be rejected by the proxy": {
+			proxyUsername: "proxy-user",
+			proxyPassword: "wrong",
+			username:      "joshvanl",
+			password:      "password",
+			expStatusCode: http.StatusProxyAuthRequired,
+		},
+		"proxy auth without registry auth should be rejected by the registry": {
+			proxyUsername: "proxy-user",
+			proxyPassword: "proxy-password",
+			expStatusCode: http.StatusUnauthorized,
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			atomic.StoreInt32(&leaked, 0)
+
+			client, err := New(context.TODO(), logrus.NewEntry(logrus.New()), &Options{
+				Host:          proxy.URL,
+				Username:      test.username,
+				Password:      test.password,
+				TokenURL:      tokenServer.URL,
+				ProxyUsername: test.proxyUsername,
+				ProxyPassword: test.proxyPassword,
+			})
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			tags, err := client.Tags(context.TODO(), host, "jetstack", "version-checker")
+			if test.expStatusCode == 0 {
+				if err != nil {
+					t.Fatalf("unexpected error: %s", err)
+				}
+				if len(tags) != 1 || tags[0].SHA != "sha256:aaa" {
+					t.Errorf("unexpected tags, exp=[v0.1.0@sha256:aaa] got=%+v", tags)
+				}
+			} else {
+				httpErr, ok := selfhostederrors.IsHTTPError(err)
+				if !ok || httpErr.StatusCode != test.expStatusCode {
+					t.Fatalf("unexpected error, exp=%d got=%v", test.expStatusCode, err)
+				}
+			}
+
+			if n := atomic.LoadInt32(&leaked); n > 0 {
+				t.Errorf("unexpected proxy credentials sent past the proxy, got %d requests", n)
+			}
+		})
+	}
+}
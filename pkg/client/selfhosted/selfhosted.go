@@ -2,10 +2,13 @@ package selfhosted
 
 import (
 	"context"
+	"crypto/sha256"
+	"encoding/hex"
 	"encoding/json"
 	"errors"
 	"fmt"
 	"io/ioutil"
+	"mime"
 	"net/http"
 	"regexp"
 	"strings"
@@ -14,21 +17,58 @@ import (
 	"github.com/sirupsen/logrus"
 
 	"github.com/jetstack/version-checker/pkg/api"
+	clienterrors "github.com/jetstack/version-checker/pkg/client/errors"
 	selfhostederrors "github.com/jetstack/version-checker/pkg/client/selfhosted/errors"
 	"github.com/jetstack/version-checker/pkg/client/util"
 )
 
 const (
-	// {host}/v2/{repo/image}/tags/list?n=500
-	tagsPath = "%s/v2/%s/tags/list?n=500"
-	// /v2/{repo/image}/manifests/{tag}
+	// {host}{base path}/v2/{repo/image}/tags/list?n={page size}
+	tagsPath = "%s/v2/%s/tags/list?n=%d"
+	// {host}{base path}/v2/{repo/image}/manifests/{tag}
 	manifestPath = "%s/v2/%s/manifests/%s"
+	// {host}{base path}/v2/{repo/image}/blobs/{digest}
+	blobPath = "%s/v2/%s/blobs/%s"
 	// Token endpoint
 	tokenPath = "/v2/token"
 
+	// defaultPageSize is the number of tags requested if not configured.
+	defaultPageSize = 500
+	// maxPageSize caps the number of tags requested, to guard against
+	// unbounded responses.
+	maxPageSize = 10000
+
+	// OCI annotation holding the creation time of the image
+	createdAnnotation = "org.opencontainers.image.created"
+
 	// HTTP headers to request API version
 	dockerAPIv1Header = "application/vnd.docker.distribution.manifest.v1+json"
 	dockerAPIv2Header = "application/vnd.docker.distribution.manifest.v2+json"
+
+	// HTTP headers to request manifest lists and OCI manifests
+	dockerManifestListHeader = "application/vnd.docker.distribution.manifest.list.v2+json"
+	ociManifestHeader        = "application/vnd.oci.image.manifest.v1+json"
+	ociIndexHeader           = "application/vnd.oci.image.index.v1+json"
+)
+
+var (
+	// manifestAcceptHeader accepts all current manifest media types.
+	manifestAcceptHeader = strings.Join([]string{
+		ociIndexHeader,
+		dockerManifestListHeader,
+		ociManifestHeader,
+		dockerAPIv2Header,
+	}, ", ")
+
+	// manifestMediaTypes are the media types of manifests which may be
+	// returned by the registry.
+	manifestMediaTypes = map[string]bool{
+		ociIndexHeader:           true,
+		dockerManifestListHeader: true,
+		ociManifestHeader:        true,
+		dockerAPIv2Header:        true,
+		dockerAPIv1Header:        true,
+	}
 )
 
 type Options struct {
@@ -36,6 +76,43 @@ type Options struct {
 	Username string
 	Password string
 	Bearer   string
+
+	// ProxyUsername and ProxyPassword are the basic auth credentials of an
+	// authenticating proxy in front of the registry, set as the
+	// Proxy-Authorization header of each request of Host, in addition to any
+	// registry auth set as the Authorization header, such as the Bearer or
+	// tokens of the Username and Password. Requests of other hosts, such as
+	// token services, are made without them.
+	ProxyUsername string
+	ProxyPassword string
+
+	// TokenURL is the URL of the registry's token service, for registries
+	// where it cannot be discovered. If set, a bearer token is requested from
+	// this URL for each repository, using the username and password if set.
+	TokenURL string
+
+	// TokenScope is the scope template requested from the TokenURL, where
+	// "{repository}" is replaced with the image repository. Defaults to
+	// "repository:{repository}:pull".
+	TokenScope string
+
+	// PageSize is the number of tags requested when listing tags. Defaults to
+	// 500, and is capped at 10000.
+	PageSize int
+
+	// Transport is used to make requests to the registry, such as to trust a
+	// custom CA. Defaults to http.DefaultTransport if nil.
+	Transport http.RoundTripper
+
+	// BasePath is the path the registry's API is mounted under, such as by a
+	// proxy, where "/registry" requests tags from
+	// {host}/registry/v2/{repo/image}/tags/list. Defaults to the path of Host.
+	BasePath string
+
+	// HostBasePaths are base paths keyed by lower case registry host,
+	// including the port if any, used in place of BasePath for requests of
+	// each host, such as for the generic client of any host.
+	HostBasePaths map[string]string
 }
 
 type Client struct {
@@ -46,10 +123,17 @@ type Client struct {
 
 	hostRegex  *regexp.Regexp
 	httpScheme string
+	// basePath is the base path of the registry's API when not configured,
+	// taken from the path of Host.
+	basePath string
+
+	tokens *util.TokenCache
 }
 
 type AuthResponse struct {
-	Token string `json:"token"`
+	Token       string `json:"token"`
+	AccessToken string `json:"access_token"`
+	ExpiresIn   int    `json:"expires_in"`
 }
 
 type TagResponse struct {
@@ -70,26 +154,65 @@ type V1Compatibility struct {
 	Created time.Time `json:"created,omitempty"`
 }
 
+// ImageManifest is a Docker V2 schema 2 or OCI manifest, or a list of them.
+type ImageManifest struct {
+	MediaType   string            `json:"mediaType"`
+	Config      Descriptor        `json:"config"`
+	Layers      []Descriptor      `json:"layers"`
+	Manifests   []Descriptor      `json:"manifests"`
+	Annotations map[string]string `json:"annotations"`
+}
+
+// ImageConfig is the config blob of an image.
+type ImageConfig struct {
+	Created time.Time `json:"created,omitempty"`
+	Config  struct {
+		Labels map[string]string `json:"Labels"`
+	} `json:"config"`
+}
+
+type Descriptor struct {
+	MediaType   string            `json:"mediaType"`
+	Digest      string            `json:"digest"`
+	Size        int64             `json:"size"`
+	Platform    *api.Platform     `json:"platform,omitempty"`
+	Annotations map[string]string `json:"annotations,omitempty"`
+}
+
 func New(ctx context.Context, log *logrus.Entry, opts *Options) (*Client, error) {
 	client := &Client{
 		Client: &http.Client{
-			Timeout: time.Second * 10,
+			Timeout:       time.Second * 10,
+			Transport:     opts.Transport,
+			CheckRedirect: util.CheckRedirect,
 		},
 		Options: opts,
 		log:     log.WithField("client", opts.Host),
+		tokens:  util.NewTokenCache(),
 	}
 
 	// Set up client with host matching if set
 	if opts.Host != "" {
-		hostRegex, scheme, err := parseURL(opts.Host)
+		hostRegex, hostURL, err := parseURL(opts.Host)
 		if err != nil {
 			return nil, fmt.Errorf("failed parsing url: %s", err)
 		}
 		client.hostRegex = hostRegex
-		client.httpScheme = scheme
+		client.httpScheme = hostURL.Scheme
+		if len(opts.BasePath) == 0 {
+			client.basePath = hostURL.Path
+		}
 
-		// Setup Auth if username and password used.
-		if len(opts.Username) > 0 || len(opts.Password) > 0 {
+		// Proxy auth is set before any token auth is setup, which is also
+		// requested of the host.
+		if len(opts.ProxyUsername) > 0 || len(opts.ProxyPassword) > 0 {
+			client.Client.Transport = util.NewProxyAuthTransport(client.Client.Transport, hostURL.Host,
+				opts.ProxyUsername, opts.ProxyPassword)
+		}
+
+		// Setup Auth if username and password used. When a token URL is set,
+		// tokens are instead requested per repository.
+		if len(opts.TokenURL) == 0 && (len(opts.Username) > 0 || len(opts.Password) > 0) {
 			if len(opts.Bearer) > 0 {
 				return nil, errors.New("cannot specify Bearer token as well as username/password")
 			}
@@ -97,7 +220,7 @@ func New(ctx context.Context, log *logrus.Entry, opts *Options) (*Client, error)
 			token, err := client.setupBasicAuth(ctx, opts.Host)
 			if httpErr, ok := selfhostederrors.IsHTTPError(err); ok {
 				return nil, fmt.Errorf("failed to setup token auth (%d): %s",
-					httpErr.StatusCode, httpErr.Body)
+					httpErr.StatusCode, client.redact(ctx, string(httpErr.Body)))
 			}
 
 			if err != nil {
@@ -126,29 +249,43 @@ func (c *Client) Name() string {
 
 // Tags will fetch the image tags from a given image URL. It must first query
 // the tags that are available, then query the 2.1 and 2.2 API endpoints to
-// gather the image digest and created time.
+// gather the image digest and created time. The created time is taken from
+// the creation annotation of the tag's manifest, or index for multi-arch
+// images, falling back to the created time of the image config reported by
+// the 2.1 API. Image config blobs are not fetched, so tags of registries
+// without the 2.1 API have no timestamp unless annotated. OCI artifacts, such
+// as Helm charts, have no 2.1 manifest, so tags are not skipped if the 2.1 API
+// fails.
 func (c *Client) Tags(ctx context.Context, host, repo, image string) ([]api.ImageTag, error) {
-	path := util.JoinRepoImage(repo, image)
-	tagURL := fmt.Sprintf(tagsPath, host, path)
+	path := cleanPath(util.JoinRepoImage(repo, image))
+	tagURL := fmt.Sprintf(tagsPath, c.baseURL(host), path, util.PageSize(c.PageSize, defaultPageSize, maxPageSize))
+
+	token, err := c.repositoryToken(ctx, path)
+	if err != nil {
+		return nil, err
+	}
 
 	var tagResponse TagResponse
-	if _, err := c.doRequest(ctx, tagURL, "", &tagResponse); err != nil {
+	if _, err := c.doRequest(ctx, tagURL, "", token, &tagResponse); err != nil {
 		return nil, err
 	}
 
 	var tags []api.ImageTag
 	for _, tag := range tagResponse.Tags {
-		manifestURL := fmt.Sprintf(manifestPath, host, path, tag)
+		manifestURL := fmt.Sprintf(manifestPath, c.baseURL(host), path, tag)
 
 		var manifestResponse ManifestResponse
-		_, err := c.doRequest(ctx, manifestURL, dockerAPIv1Header, &manifestResponse)
+		_, err := c.doRequest(ctx, manifestURL, dockerAPIv1Header, token, &manifestResponse)
 
 		if httpErr, ok := selfhostederrors.IsHTTPError(err); ok {
-			c.log.Errorf("%s: failed to get manifest response for tag, skipping (%d): %s",
-				manifestURL, httpErr.StatusCode, httpErr.Body)
-			continue
-		}
-		if err != nil {
+			c.log.Debugf("%s: failed to get 2.1 manifest response for tag (%d): %s",
+				manifestURL, httpErr.StatusCode, c.redact(ctx, string(httpErr.Body)))
+		} else if clienterrors.IsDecode(err) {
+			// Registries without the 2.1 API may respond with a manifest of
+			// another schema.
+			c.log.Debugf("%s: failed to decode 2.1 manifest response for tag: %s",
+				manifestURL, c.redact(ctx, err.Error()))
+		} else if err != nil {
 			return nil, err
 		}
 
@@ -167,37 +304,317 @@ func (c *Client) Tags(ctx context.Context, host, repo, image string) ([]api.Imag
 			}
 		}
 
-		header, err := c.doRequest(ctx, manifestURL, dockerAPIv2Header, new(ManifestResponse))
+		manifest, _, digest, err := c.getManifest(ctx, manifestURL, tag, token)
 		if httpErr, ok := selfhostederrors.IsHTTPError(err); ok {
 			c.log.Errorf("%s: failed to get manifest sha response for tag, skipping (%d): %s",
-				manifestURL, httpErr.StatusCode, httpErr.Body)
+				manifestURL, httpErr.StatusCode, c.redact(ctx, string(httpErr.Body)))
 			continue
 		}
 		if err != nil {
 			return nil, err
 		}
 
+		// The annotation of an index is preferred over the per platform created
+		// time of the 2.1 API, so that multi-arch images compare consistently.
+		// Tags without either are left without a timestamp, rather than fetching
+		// the config of every tag, see the UseConfigTimestamp option.
+		if created, ok := c.createdAnnotation(manifestURL, manifest.Annotations); ok {
+			timestamp = created
+		}
+
 		tags = append(tags, api.ImageTag{
-			Tag:          tag,
-			SHA:          header.Get("Docker-Content-Digest"),
-			Timestamp:    timestamp,
-			Architecture: manifestResponse.Architecture,
+			Tag:             tag,
+			SHA:             digest,
+			Timestamp:       timestamp,
+			Architecture:    manifestResponse.Architecture,
+			ConfigMediaType: manifest.Config.MediaType,
 		})
 	}
 
 	return tags, nil
 }
 
-func (c *Client) doRequest(ctx context.Context, url, header string, obj interface{}) (http.Header, error) {
+// Manifest will fetch the manifest of the given image reference, which is
+// either a tag or digest. The manifest's timestamp is taken from its creation
+// annotation, which for multi-arch images is that of the index itself, falling
+// back to the created time of the image config. For indexes without the
+// annotation, the config of the image chosen by platformManifest, preferring
+// linux/amd64, is used.
+// Error responses of the manifest are returned as a
+// clienterrors.ErrorUnauthorized, or clienterrors.ErrorImageNotFound if the
+// reference does not exist, as with Validate.
+func (c *Client) Manifest(ctx context.Context, host, repo, image, reference string) (*api.ImageManifest, error) {
+	path := cleanPath(util.JoinRepoImage(repo, image))
+	manifestURL := fmt.Sprintf(manifestPath, c.baseURL(host), path, reference)
+
+	token, err := c.repositoryToken(ctx, path)
+	if err != nil {
+		return nil, err
+	}
+
+	manifest, mediaType, digest, err := c.getManifest(ctx, manifestURL, reference, token)
+	if err != nil {
+		return nil, statusError(host, referenceImage(path, reference), err)
+	}
+
+	result := &api.ImageManifest{
+		Digest:      digest,
+		MediaType:   mediaType,
+		Annotations: manifest.Annotations,
+	}
+
+	if created, ok := c.createdAnnotation(manifestURL, manifest.Annotations); ok {
+		result.Timestamp = created
+	} else {
+		created, err := c.configCreated(ctx, host, path, token, manifest)
+		if err != nil {
+			c.log.Debugf("%s: failed to get created time from image config: %s",
+				manifestURL, c.redact(ctx, err.Error()))
+		} else {
+			result.Timestamp = created
+		}
+	}
+
+	// Manifest lists have no size of their own, only per platform.
+	if len(manifest.Manifests) > 0 {
+		for _, m := range manifest.Manifests {
+			if m.Platform != nil {
+				result.Platforms = append(result.Platforms, *m.Platform)
+			}
+		}
+
+		return result, nil
+	}
+
+	result.Size = manifest.Config.Size
+	for _, layer := range manifest.Layers {
+		result.Size += layer.Size
+	}
+
+	return result, nil
+}
+
+// Config will return the image config of the given host, repo, image and
+// reference, which is either a tag or digest. For manifest lists and indexes,
+// the config of the image chosen by platformManifest is returned.
+func (c *Client) Config(ctx context.Context, host, repo, image, reference string) (*api.ImageConfig, error) {
+	path := cleanPath(util.JoinRepoImage(repo, image))
+	manifestURL := fmt.Sprintf(manifestPath, c.baseURL(host), path, reference)
+
+	token, err := c.repositoryToken(ctx, path)
+	if err != nil {
+		return nil, err
+	}
+
+	manifest, _, _, err := c.getManifest(ctx, manifestURL, reference, token)
+	if err != nil {
+		return nil, err
+	}
+
+	config, digest, err := c.imageConfig(ctx, host, path, token, manifest)
+	if err != nil {
+		return nil, fmt.Errorf("%s: failed to get image config: %w", manifestURL, err)
+	}
+
+	return &api.ImageConfig{
+		Digest:  digest,
+		Created: config.Created,
+		Labels:  config.Config.Labels,
+	}, nil
+}
+
+// createdAnnotation will return the creation time held in the given manifest
+// annotations, if present and valid.
+func (c *Client) createdAnnotation(manifestURL string, annotations map[string]string) (time.Time, bool) {
+	created, ok := annotations[createdAnnotation]
+	if !ok {
+		return time.Time{}, false
+	}
+
+	timestamp, err := time.Parse(time.RFC3339, created)
+	if err != nil {
+		c.log.Debugf("%s: failed to parse %q annotation: %s", manifestURL, createdAnnotation, err)
+		return time.Time{}, false
+	}
+
+	return timestamp, true
+}
+
+// configCreated will return the created time of the image config of the given
+// manifest. For manifest lists and indexes, the config of the image chosen by
+// platformManifest is used.
+func (c *Client) configCreated(ctx context.Context, host, path, token string, manifest *ImageManifest) (time.Time, error) {
+	config, _, err := c.imageConfig(ctx, host, path, token, manifest)
+	if err != nil {
+		return time.Time{}, err
+	}
+
+	return config.Created, nil
+}
+
+// imageConfig will return the image config of the given manifest, along with
+// its digest. For manifest lists and indexes, the config of the image chosen
+// by platformManifest is used.
+func (c *Client) imageConfig(ctx context.Context, host, path, token string, manifest *ImageManifest) (*ImageConfig, string, error) {
+	if len(manifest.Manifests) > 0 {
+		digest := platformManifest(manifest.Manifests).Digest
+		manifestURL := fmt.Sprintf(manifestPath, c.baseURL(host), path, digest)
+
+		var err error
+		manifest, _, _, err = c.getManifest(ctx, manifestURL, digest, token)
+		if err != nil {
+			return nil, "", err
+		}
+	}
+
+	if len(manifest.Config.Digest) == 0 {
+		return nil, "", errors.New("manifest has no config")
+	}
+
+	config := new(ImageConfig)
+	blobURL := fmt.Sprintf(blobPath, c.baseURL(host), path, manifest.Config.Digest)
+	if _, err := c.doRequest(ctx, blobURL, "", token, config); err != nil {
+		return nil, "", err
+	}
+
+	return config, manifest.Config.Digest, nil
+}
+
+// platformManifest returns the image of the given manifest list or index
+// whose config stands for the list, regardless of the order registries list
+// platforms in. The linux/amd64 image is preferred, being the platform built
+// for by almost all images, otherwise the image of the first platform sorted
+// by OS, architecture and variant. Images without a platform, or of an
+// unknown platform such as attestation manifests, are only used if there are
+// no others, taking the first listed.
+func platformManifest(manifests []Descriptor) Descriptor {
+	chosen := manifests[0]
+	for _, m := range manifests[1:] {
+		if platformLess(m.Platform, chosen.Platform) {
+			chosen = m
+		}
+	}
+
+	return chosen
+}
+
+// platformLess returns whether platform a is preferred over b, as by
+// platformManifest.
+func platformLess(a, b *api.Platform) bool {
+	rankA, rankB := platformRank(a), platformRank(b)
+	if rankA != rankB {
+		return rankA < rankB
+	}
+	if a == nil || b == nil || rankA == platformRankUnknown {
+		return false
+	}
+
+	if a.OS != b.OS {
+		return a.OS < b.OS
+	}
+	if a.Architecture != b.Architecture {
+		return a.Architecture < b.Architecture
+	}
+	return a.Variant < b.Variant
+}
+
+const (
+	platformRankPreferred = iota
+	platformRankKnown
+	platformRankUnknown
+)
+
+// platformRank returns the rank of the given platform, where lower ranks are
+// preferred.
+func platformRank(platform *api.Platform) int {
+	switch {
+	case platform == nil, platform.OS == "unknown", platform.Architecture == "unknown":
+		return platformRankUnknown
+	case platform.OS == "linux" && platform.Architecture == "amd64":
+		return platformRankPreferred
+	default:
+		return platformRankKnown
+	}
+}
+
+// getManifest will fetch the manifest of the given URL, accepting all current
+// manifest media types, returning it along with its media type and digest.
+// See manifestMediaType and manifestDigest.
+func (c *Client) getManifest(ctx context.Context, manifestURL, reference, token string) (*ImageManifest, string, string, error) {
+	manifest := new(ImageManifest)
+	header, body, err := c.do(ctx, manifestURL, manifestAcceptHeader, token, manifest)
+	if err != nil {
+		return nil, "", "", err
+	}
+
+	return manifest, manifestMediaType(manifest, header), manifestDigest(header, reference, body), nil
+}
+
+// manifestMediaType returns the media type of the given manifest, taken from
+// the manifest, or the Content-Type of the response if unset. Registries may
+// omit both, or respond with a generic Content-Type, in which case the media
+// type is identified by the manifest's content, where manifests of schema
+// version 2 without a media type are OCI indexes or manifests.
+func manifestMediaType(manifest *ImageManifest, header http.Header) string {
+	if len(manifest.MediaType) > 0 {
+		return manifest.MediaType
+	}
+
+	contentType := header.Get("Content-Type")
+	if mediaType, _, err := mime.ParseMediaType(contentType); err == nil && manifestMediaTypes[mediaType] {
+		return mediaType
+	}
+
+	switch {
+	case len(manifest.Manifests) > 0:
+		return ociIndexHeader
+	case len(manifest.Config.Digest) > 0:
+		return ociManifestHeader
+	default:
+		return contentType
+	}
+}
+
+// manifestDigest returns the digest of the manifest of the given response,
+// taken from the Docker-Content-Digest header, or the reference if requested
+// by digest. Registries are not required to set the header, so the digest is
+// otherwise the sha256 digest of the body.
+func manifestDigest(header http.Header, reference string, body []byte) string {
+	if digest := header.Get("Docker-Content-Digest"); len(digest) > 0 {
+		return digest
+	}
+
+	if strings.Contains(reference, ":") {
+		return reference
+	}
+
+	sum := sha256.Sum256(body)
+	return "sha256:" + hex.EncodeToString(sum[:])
+}
+
+func (c *Client) doRequest(ctx context.Context, url, header, token string, obj interface{}) (http.Header, error) {
+	respHeader, _, err := c.do(ctx, url, header, token, obj)
+	return respHeader, err
+}
+
+// do will make a GET request to the given URL, decoding the response body
+// into obj, returning the response headers and body.
+func (c *Client) do(ctx context.Context, url, header, token string, obj interface{}) (http.Header, []byte, error) {
 	url = fmt.Sprintf("%s://%s", c.httpScheme, url)
 	req, err := http.NewRequest(http.MethodGet, url, nil)
 	if err != nil {
-		return nil, err
+		return nil, nil, err
 	}
 
 	req = req.WithContext(ctx)
-	if len(c.Bearer) > 0 {
-		req.Header.Add("Authorization", "Bearer "+c.Bearer)
+	if creds, ok := api.CredentialsFromContext(ctx); ok {
+		if len(creds.Token) > 0 {
+			req.Header.Add("Authorization", "Bearer "+creds.Token)
+		} else {
+			req.SetBasicAuth(creds.Username, creds.Password)
+		}
+	} else if len(token) > 0 {
+		req.Header.Add("Authorization", "Bearer "+token)
 	}
 	if len(header) > 0 {
 		req.Header.Set("Accept", header)
@@ -205,23 +622,35 @@ func (c *Client) doRequest(ctx context.Context, url, header string, obj interfac
 
 	resp, err := c.Do(req)
 	if err != nil {
-		return nil, fmt.Errorf("failed to get docker image: %s", err)
+		return nil, nil, fmt.Errorf("failed to get docker image: %w", err)
 	}
+	defer resp.Body.Close()
 
 	body, err := ioutil.ReadAll(resp.Body)
 	if err != nil {
-		return nil, err
+		return nil, nil, err
 	}
 
 	if resp.StatusCode != http.StatusOK {
-		return nil, selfhostederrors.NewHTTPError(resp.StatusCode, body)
+		return nil, nil, selfhostederrors.NewHTTPError(resp.StatusCode, []byte(c.redact(ctx, string(body))))
 	}
 
 	if err := json.Unmarshal(body, obj); err != nil {
-		return nil, fmt.Errorf("unexpected %s response: %s", url, body)
+		return nil, nil, clienterrors.NewErrorDecode(req.URL.Host, resp.StatusCode, []byte(c.redact(ctx, string(body))), err)
+	}
+
+	return resp.Header, body, nil
+}
+
+// redact returns the given string with the client's credentials, and those
+// of the context, redacted.
+func (c *Client) redact(ctx context.Context, s string) string {
+	secrets := append([]string{c.Password, c.Bearer, c.ProxyPassword}, c.tokens.Tokens()...)
+	if creds, ok := api.CredentialsFromContext(ctx); ok {
+		secrets = append(secrets, creds.Password, creds.Token)
 	}
 
-	return resp.Header, nil
+	return util.Redact(s, secrets...)
 }
 
 func (c *Client) setupBasicAuth(ctx context.Context, url string) (string, error) {
@@ -246,6 +675,7 @@ func (c *Client) setupBasicAuth(ctx context.Context, url string) (string, error)
 		return "", fmt.Errorf("failed to send basic auth request %q: %s",
 			req.URL, err)
 	}
+	defer resp.Body.Close()
 
 	body, err := ioutil.ReadAll(resp.Body)
 	if err != nil {
@@ -0,0 +1,949 @@
+package selfhosted
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/sirupsen/logrus"
+
+	"github.com/jetstack/version-checker/pkg/api"
+	clienterrors "github.com/jetstack/version-checker/pkg/client/errors"
+)
+
+// newTestClient returns a selfhosted client, and its host, for the given stub
+// registry handler.
+func newTestClient(t *testing.T, handler http.Handler) (*Client, string, func()) {
+	server := httptest.NewServer(handler)
+
+	client, err := New(context.TODO(), logrus.NewEntry(logrus.New()), &Options{
+		Host: server.URL,
+	})
+	if err != nil {
+		server.Close()
+		t.Fatal(err)
+	}
+
+	return client, strings.TrimPrefix(server.URL, "http://"), server.Close
+}
+
+func TestManifest(t *testing.T) {
+	manifests := map[string]struct {
+		digest    string
+		mediaType string
+		body      string
+	}{
+		"/v2/jetstack/version-checker/manifests/v0.1.0": {
+			digest:    "sha256:fff",
+			mediaType: ociIndexHeader,
+			body: `{
+  "schemaVersion": 2,
+  "mediaType": "application/vnd.oci.image.index.v1+json",
+  "manifests": [
+    {"mediaType": "application/vnd.oci.image.manifest.v1+json", "digest": "sha256:aaa", "size": 100,
+     "platform": {"architecture": "amd64", "os": "linux"}},
+    {"mediaType": "application/vnd.oci.image.manifest.v1+json", "digest": "sha256:bbb", "size": 100,
+     "platform": {"architecture": "arm64", "os": "linux", "variant": "v8"}}
+  ],
+  "annotations": {
+    "org.opencontainers.image.source": "https://github.com/jetstack/version-checker",
+    "org.opencontainers.image.created": "2020-09-01T12:00:00Z"
+  }
+}`,
+		},
+		"/v2/jetstack/version-checker/manifests/sha256:aaa": {
+			digest:    "sha256:aaa",
+			mediaType: ociManifestHeader,
+			body: `{
+  "schemaVersion": 2,
+  "mediaType": "application/vnd.oci.image.manifest.v1+json",
+  "config": {"mediaType": "application/vnd.oci.image.config.v1+json", "digest": "sha256:ccc", "size": 10},
+  "layers": [
+    {"mediaType": "application/vnd.oci.image.layer.v1.tar+gzip", "digest": "sha256:ddd", "size": 1000},
+    {"mediaType": "application/vnd.oci.image.layer.v1.tar+gzip", "digest": "sha256:eee", "size": 2000}
+  ]
+}`,
+		},
+	}
+
+	client, host, closer := newTestClient(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		manifest, ok := manifests[r.URL.Path]
+		if !ok {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+
+		if !strings.Contains(r.Header.Get("Accept"), manifest.mediaType) {
+			w.WriteHeader(http.StatusNotAcceptable)
+			return
+		}
+
+		w.Header().Set("Content-Type", manifest.mediaType)
+		w.Header().Set("Docker-Content-Digest", manifest.digest)
+		w.Write([]byte(manifest.body))
+	}))
+	defer closer()
+
+	tests := map[string]struct {
+		reference   string
+		expManifest *api.ImageManifest
+		expErr      bool
+	}{
+		"multi-arch index with annotations": {
+			reference: "v0.1.0",
+			expManifest: &api.ImageManifest{
+				Digest:    "sha256:fff",
+				MediaType: ociIndexHeader,
+				Timestamp: time.Date(2020, 9, 1, 12, 0, 0, 0, time.UTC),
+				Platforms: []api.Platform{
+					{OS: "linux", Architecture: "amd64"},
+					{OS: "linux", Architecture: "arm64", Variant: "v8"},
+				},
+				Annotations: map[string]string{
+					"org.opencontainers.image.source":  "https://github.com/jetstack/version-checker",
+					"org.opencontainers.image.created": "2020-09-01T12:00:00Z",
+				},
+			},
+		},
+		"single manifest by digest should sum size": {
+			reference: "sha256:aaa",
+			expManifest: &api.ImageManifest{
+				Digest:    "sha256:aaa",
+				MediaType: ociManifestHeader,
+				Size:      3010,
+			},
+		},
+		"unknown reference should error": {
+			reference: "v0.2.0",
+			expErr:    true,
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			manifest, err := client.Manifest(context.TODO(), host, "jetstack", "version-checker", test.reference)
+			if (err != nil) != test.expErr {
+				t.Fatalf("unexpected error, exp=%t got=%v", test.expErr, err)
+			}
+
+			if !reflect.DeepEqual(manifest, test.expManifest) {
+				t.Errorf("unexpected manifest, exp=%+v got=%+v", test.expManifest, manifest)
+			}
+		})
+	}
+}
+
+func TestConfig(t *testing.T) {
+	const prefix = "/v2/jetstack/version-checker/"
+
+	manifests := map[string]string{
+		"v0.1.0": `{
+  "schemaVersion": 2,
+  "mediaType": "application/vnd.oci.image.index.v1+json",
+  "manifests": [
+    {"mediaType": "application/vnd.oci.image.manifest.v1+json", "digest": "sha256:bbb", "size": 100,
+     "platform": {"architecture": "arm64", "os": "linux"}},
+    {"mediaType": "application/vnd.oci.image.manifest.v1+json", "digest": "sha256:aaa", "size": 100,
+     "platform": {"architecture": "amd64", "os": "linux"}}
+  ]
+}`,
+		"sha256:bbb": `{
+  "schemaVersion": 2,
+  "mediaType": "application/vnd.oci.image.manifest.v1+json",
+  "config": {"mediaType": "application/vnd.oci.image.config.v1+json", "digest": "sha256:eee", "size": 10}
+}`,
+		"sha256:aaa": `{
+  "schemaVersion": 2,
+  "mediaType": "application/vnd.oci.image.manifest.v1+json",
+  "config": {"mediaType": "application/vnd.oci.image.config.v1+json", "digest": "sha256:ccc", "size": 10}
+}`,
+		"v0.2.0": `{
+  "schemaVersion": 2,
+  "mediaType": "application/vnd.oci.image.manifest.v1+json",
+  "config": {"mediaType": "application/vnd.oci.image.config.v1+json", "digest": "sha256:ddd", "size": 10}
+}`,
+	}
+	blobs := map[string]string{
+		"sha256:ccc": `{"created": "2020-09-01T12:00:00Z", "config": {"Labels": {"quality": "ga"}}}`,
+		"sha256:ddd": `{"created": "2020-10-01T12:00:00Z", "config": {}}`,
+		"sha256:eee": `{"created": "2020-09-02T12:00:00Z", "config": {}}`,
+	}
+
+	client, host, closer := newTestClient(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if blob, ok := blobs[strings.TrimPrefix(r.URL.Path, prefix+"blobs/")]; ok {
+			w.Write([]byte(blob))
+			return
+		}
+		if manifest, ok := manifests[strings.TrimPrefix(r.URL.Path, prefix+"manifests/")]; ok {
+			w.Write([]byte(manifest))
+			return
+		}
+
+		w.WriteHeader(http.StatusNotFound)
+	}))
+	defer closer()
+
+	tests := map[string]struct {
+		reference string
+		expConfig *api.ImageConfig
+		expErr    bool
+	}{
+		"index should return the config of the linux/amd64 image": {
+			reference: "v0.1.0",
+			expConfig: &api.ImageConfig{
+				Digest:  "sha256:ccc",
+				Created: time.Date(2020, 9, 1, 12, 0, 0, 0, time.UTC),
+				Labels:  map[string]string{"quality": "ga"},
+			},
+		},
+		"config without labels should return no labels": {
+			reference: "v0.2.0",
+			expConfig: &api.ImageConfig{
+				Digest:  "sha256:ddd",
+				Created: time.Date(2020, 10, 1, 12, 0, 0, 0, time.UTC),
+			},
+		},
+		"unknown reference should error": {
+			reference: "v0.3.0",
+			expErr:    true,
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			config, err := client.Config(context.TODO(), host, "jetstack", "version-checker", test.reference)
+			if (err != nil) != test.expErr {
+				t.Fatalf("unexpected error, exp=%t got=%v", test.expErr, err)
+			}
+
+			if !reflect.DeepEqual(config, test.expConfig) {
+				t.Errorf("unexpected config, exp=%+v got=%+v", test.expConfig, config)
+			}
+		})
+	}
+}
+
+func TestPlatformManifest(t *testing.T) {
+	var (
+		amd64   = Descriptor{Digest: "amd64", Platform: &api.Platform{OS: "linux", Architecture: "amd64"}}
+		amd64v3 = Descriptor{Digest: "amd64v3", Platform: &api.Platform{OS: "linux", Architecture: "amd64", Variant: "v3"}}
+		arm64   = Descriptor{Digest: "arm64", Platform: &api.Platform{OS: "linux", Architecture: "arm64"}}
+		armv7   = Descriptor{Digest: "armv7", Platform: &api.Platform{OS: "linux", Architecture: "arm", Variant: "v7"}}
+		windows = Descriptor{Digest: "windows", Platform: &api.Platform{OS: "windows", Architecture: "amd64"}}
+		unknown = Descriptor{Digest: "unknown", Platform: &api.Platform{OS: "unknown", Architecture: "unknown"}}
+		none    = Descriptor{Digest: "none"}
+	)
+
+	tests := map[string]struct {
+		manifests []Descriptor
+		expDigest string
+	}{
+		"linux/amd64 should be preferred wherever it is listed": {
+			manifests: []Descriptor{unknown, arm64, windows, amd64},
+			expDigest: "amd64",
+		},
+		"linux/amd64 without a variant should be preferred": {
+			manifests: []Descriptor{amd64v3, amd64},
+			expDigest: "amd64",
+		},
+		"without linux/amd64, the first sorted platform should be used": {
+			manifests: []Descriptor{windows, arm64, armv7},
+			expDigest: "armv7",
+		},
+		"unknown platforms should only be used if there are no others": {
+			manifests: []Descriptor{unknown, none, windows},
+			expDigest: "windows",
+		},
+		"without known platforms, the first listed should be used": {
+			manifests: []Descriptor{none, unknown},
+			expDigest: "none",
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			if digest := platformManifest(test.manifests).Digest; digest != test.expDigest {
+				t.Errorf("unexpected platform manifest, exp=%s got=%s", test.expDigest, digest)
+			}
+		})
+	}
+}
+
+func TestTagsContextCredentials(t *testing.T) {
+	tenantTags := map[string]string{
+		"Bearer tenant-a-token": `{"tags": ["v0.1.0"]}`,
+		"Bearer tenant-b-token": `{"tags": ["v0.2.0"]}`,
+	}
+
+	client, host, closer := newTestClient(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		tags, ok := tenantTags[r.Header.Get("Authorization")]
+		if !ok {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+
+		if strings.HasSuffix(r.URL.Path, "/tags/list") {
+			w.Write([]byte(tags))
+			return
+		}
+
+		w.Header().Set("Docker-Content-Digest", "sha256:aaa")
+		w.Write([]byte(`{}`))
+	}))
+	defer closer()
+
+	tests := map[string]struct {
+		creds   *api.Credentials
+		expTags []string
+		expErr  bool
+	}{
+		"tenant a should see its tags": {
+			creds:   &api.Credentials{Token: "tenant-a-token"},
+			expTags: []string{"v0.1.0"},
+		},
+		"tenant b should see its tags": {
+			creds:   &api.Credentials{Token: "tenant-b-token"},
+			expTags: []string{"v0.2.0"},
+		},
+		"no credentials should be unauthorized": {
+			expErr: true,
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			ctx := context.TODO()
+			if test.creds != nil {
+				ctx = api.ContextWithCredentials(ctx, test.creds)
+			}
+
+			tags, err := client.Tags(ctx, host, "jetstack", "version-checker")
+			if (err != nil) != test.expErr {
+				t.Fatalf("unexpected error, exp=%t got=%v", test.expErr, err)
+			}
+
+			var gotTags []string
+			for _, tag := range tags {
+				gotTags = append(gotTags, tag.Tag)
+			}
+			if !reflect.DeepEqual(gotTags, test.expTags) {
+				t.Errorf("unexpected tags, exp=%v got=%v", test.expTags, gotTags)
+			}
+		})
+	}
+}
+
+type captureHook struct {
+	lines []string
+}
+
+func (c *captureHook) Levels() []logrus.Level {
+	return logrus.AllLevels
+}
+
+func (c *captureHook) Fire(entry *logrus.Entry) error {
+	line, err := entry.String()
+	if err != nil {
+		return err
+	}
+	c.lines = append(c.lines, line)
+	return nil
+}
+
+// closeCountTransport is a RoundTripper counting the response bodies it
+// returns, and those which have been closed.
+type closeCountTransport struct {
+	opened, closed int32
+}
+
+type closeCountBody struct {
+	io.ReadCloser
+	closed *int32
+}
+
+func (c *closeCountBody) Close() error {
+	atomic.AddInt32(c.closed, 1)
+	return c.ReadCloser.Close()
+}
+
+func (c *closeCountTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	resp, err := http.DefaultTransport.RoundTrip(req)
+	if err != nil {
+		return nil, err
+	}
+
+	atomic.AddInt32(&c.opened, 1)
+	resp.Body = &closeCountBody{ReadCloser: resp.Body, closed: &c.closed}
+	return resp, nil
+}
+
+func TestResponseBodiesClosed(t *testing.T) {
+	const prefix = "/v2/jetstack/version-checker/"
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case prefix + "tags/list":
+			w.Write([]byte(`{"tags": ["v0.1.0"]}`))
+		case prefix + "manifests/v0.1.0":
+			w.Header().Set("Content-Type", "application/vnd.docker.distribution.manifest.v2+json")
+			w.Header().Set("Docker-Content-Digest", "sha256:aaa")
+			w.Write([]byte(`{"schemaVersion": 2, "mediaType": "application/vnd.docker.distribution.manifest.v2+json",
+  "config": {"mediaType": "application/vnd.docker.container.image.v1+json", "digest": "sha256:ccc", "size": 10}}`))
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer server.Close()
+
+	transport := new(closeCountTransport)
+	client, err := New(context.TODO(), logrus.NewEntry(logrus.New()), &Options{
+		Host:      server.URL,
+		Transport: transport,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	host := strings.TrimPrefix(server.URL, "http://")
+
+	if _, err := client.Tags(context.TODO(), host, "jetstack", "version-checker"); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if _, err := client.Manifest(context.TODO(), host, "jetstack", "version-checker", "v0.2.0"); err == nil {
+		t.Fatal("expected error getting unknown manifest")
+	}
+
+	opened, closed := atomic.LoadInt32(&transport.opened), atomic.LoadInt32(&transport.closed)
+	if opened == 0 || opened != closed {
+		t.Errorf("unexpected closed response bodies, exp=%d got=%d", opened, closed)
+	}
+}
+
+func TestTagsRedactsCredentials(t *testing.T) {
+	const token = "my-secret-token"
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer "+token {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+
+		if strings.HasSuffix(r.URL.Path, "/tags/list") {
+			w.Write([]byte(`{"tags": ["v0.1.0"]}`))
+			return
+		}
+
+		// Misbehaving registry which echoes the request credentials.
+		w.WriteHeader(http.StatusInternalServerError)
+		w.Write([]byte("bad request with Authorization: " + r.Header.Get("Authorization")))
+	}))
+	defer server.Close()
+
+	capture := new(captureHook)
+	log := logrus.New()
+	log.SetOutput(new(strings.Builder))
+	log.SetLevel(logrus.DebugLevel)
+	log.AddHook(capture)
+
+	client, err := New(context.TODO(), logrus.NewEntry(log), &Options{
+		Host:   server.URL,
+		Bearer: token,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	host := strings.TrimPrefix(server.URL, "http://")
+	if _, err := client.Tags(context.TODO(), host, "jetstack", "version-checker"); err != nil {
+		t.Fatal(err)
+	}
+
+	if len(capture.lines) == 0 {
+		t.Fatal("expected log lines for failed manifest requests, got none")
+	}
+	for _, line := range capture.lines {
+		if strings.Contains(line, token) {
+			t.Errorf("secret found in log line: %s", line)
+		}
+	}
+}
+
+func TestManifestMediaTypes(t *testing.T) {
+	const (
+		schema2 = `{
+  "schemaVersion": 2,
+  "mediaType": "application/vnd.docker.distribution.manifest.v2+json",
+  "config": {"mediaType": "application/vnd.docker.container.image.v1+json", "digest": "sha256:ccc", "size": 10},
+  "layers": [
+    {"mediaType": "application/vnd.docker.image.rootfs.diff.tar.gzip", "digest": "sha256:ddd", "size": 1000}
+  ]
+}`
+		ociManifest = `{
+  "schemaVersion": 2,
+  "config": {"mediaType": "application/vnd.oci.image.config.v1+json", "digest": "sha256:ccc", "size": 10},
+  "layers": [
+    {"mediaType": "application/vnd.oci.image.layer.v1.tar+gzip", "digest": "sha256:ddd", "size": 2000}
+  ]
+}`
+		ociIndex = `{
+  "schemaVersion": 2,
+  "manifests": [
+    {"mediaType": "application/vnd.oci.image.manifest.v1+json", "digest": "sha256:aaa", "size": 100,
+     "platform": {"architecture": "amd64", "os": "linux"}}
+  ]
+}`
+		manifestList = `{
+  "schemaVersion": 2,
+  "manifests": [
+    {"mediaType": "application/vnd.docker.distribution.manifest.v2+json", "digest": "sha256:aaa", "size": 100,
+     "platform": {"architecture": "arm64", "os": "linux"}}
+  ]
+}`
+	)
+
+	responses := map[string]struct {
+		contentType string
+		digest      string
+		body        string
+	}{
+		"schema2":       {contentType: dockerAPIv2Header, digest: "sha256:fff", body: schema2},
+		"oci-manifest":  {contentType: "application/json", body: ociManifest},
+		"oci-index":     {contentType: ociIndexHeader + "; charset=utf-8", digest: "sha256:fff", body: ociIndex},
+		"manifest-list": {contentType: dockerManifestListHeader, digest: "sha256:fff", body: manifestList},
+		"sha256:aaa":    {contentType: dockerAPIv2Header, digest: "sha256:aaa", body: schema2},
+	}
+
+	var configCalls int32
+	client, host, closer := newTestClient(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		const prefix = "/v2/jetstack/version-checker/"
+
+		switch r.URL.Path {
+		case prefix + "tags/list":
+			w.Write([]byte(`{"tags": ["schema2", "oci-manifest", "oci-index", "manifest-list"]}`))
+			return
+		case prefix + "blobs/sha256:ccc":
+			atomic.AddInt32(&configCalls, 1)
+			w.Write([]byte(`{"created": "2020-08-01T12:00:00Z"}`))
+			return
+		}
+
+		response, ok := responses[strings.TrimPrefix(r.URL.Path, prefix+"manifests/")]
+		if !ok {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+
+		// No 2.1 API, so timestamps are taken from the image config.
+		if r.Header.Get("Accept") == dockerAPIv1Header {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		for _, mediaType := range []string{ociIndexHeader, dockerManifestListHeader, ociManifestHeader, dockerAPIv2Header} {
+			if !strings.Contains(r.Header.Get("Accept"), mediaType) {
+				w.WriteHeader(http.StatusNotAcceptable)
+				return
+			}
+		}
+
+		w.Header().Set("Content-Type", response.contentType)
+		if len(response.digest) > 0 {
+			w.Header().Set("Docker-Content-Digest", response.digest)
+		}
+		w.Write([]byte(response.body))
+	}))
+	defer closer()
+
+	configTime := time.Date(2020, 8, 1, 12, 0, 0, 0, time.UTC)
+	ociManifestSum := sha256.Sum256([]byte(ociManifest))
+	ociManifestDigest := "sha256:" + hex.EncodeToString(ociManifestSum[:])
+
+	tests := map[string]struct {
+		expManifest *api.ImageManifest
+	}{
+		"schema2": {
+			expManifest: &api.ImageManifest{
+				Digest:    "sha256:fff",
+				MediaType: dockerAPIv2Header,
+				Timestamp: configTime,
+				Size:      1010,
+			},
+		},
+		"oci-manifest": {
+			expManifest: &api.ImageManifest{
+				Digest:    ociManifestDigest,
+				MediaType: ociManifestHeader,
+				Timestamp: configTime,
+				Size:      2010,
+			},
+		},
+		"oci-index": {
+			expManifest: &api.ImageManifest{
+				Digest:    "sha256:fff",
+				MediaType: ociIndexHeader,
+				Timestamp: configTime,
+				Platforms: []api.Platform{{OS: "linux", Architecture: "amd64"}},
+			},
+		},
+		"manifest-list": {
+			expManifest: &api.ImageManifest{
+				Digest:    "sha256:fff",
+				MediaType: dockerManifestListHeader,
+				Timestamp: configTime,
+				Platforms: []api.Platform{{OS: "linux", Architecture: "arm64"}},
+			},
+		},
+	}
+
+	for reference, test := range tests {
+		t.Run(reference, func(t *testing.T) {
+			manifest, err := client.Manifest(context.TODO(), host, "jetstack", "version-checker", reference)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if !reflect.DeepEqual(manifest, test.expManifest) {
+				t.Errorf("unexpected manifest, exp=%+v got=%+v", test.expManifest, manifest)
+			}
+		})
+	}
+
+	atomic.StoreInt32(&configCalls, 0)
+	tags, err := client.Tags(context.TODO(), host, "jetstack", "version-checker")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if calls := atomic.LoadInt32(&configCalls); calls != 0 {
+		t.Errorf("expected listing tags not to fetch image configs, got %d calls", calls)
+	}
+
+	if len(tags) != len(tests) {
+		t.Fatalf("unexpected number of tags, exp=%d got=%d", len(tests), len(tags))
+	}
+	for _, tag := range tags {
+		exp := tests[tag.Tag].expManifest
+		if tag.SHA != exp.Digest {
+			t.Errorf("%s: unexpected tag digest, exp=%s got=%s", tag.Tag, exp.Digest, tag.SHA)
+		}
+		// Listing tags does not fetch image configs, so tags without a 2.1
+		// or annotated created time have no timestamp.
+		if !tag.Timestamp.IsZero() {
+			t.Errorf("%s: expected tag without timestamp, got=%s", tag.Tag, tag.Timestamp)
+		}
+	}
+}
+
+func TestTimestampPrecedence(t *testing.T) {
+	const (
+		annotated = `{
+  "schemaVersion": 2,
+  "mediaType": "application/vnd.oci.image.index.v1+json",
+  "manifests": [
+    {"mediaType": "application/vnd.oci.image.manifest.v1+json", "digest": "sha256:aaa", "size": 100,
+     "platform": {"architecture": "amd64", "os": "linux"}}
+  ],
+  "annotations": {"org.opencontainers.image.created": "2020-09-01T12:00:00Z"}
+}`
+		unannotated = `{
+  "schemaVersion": 2,
+  "mediaType": "application/vnd.oci.image.index.v1+json",
+  "manifests": [
+    {"mediaType": "application/vnd.oci.image.manifest.v1+json", "digest": "sha256:aaa", "size": 100,
+     "platform": {"architecture": "amd64", "os": "linux"}}
+  ]
+}`
+		image = `{
+  "schemaVersion": 2,
+  "mediaType": "application/vnd.oci.image.manifest.v1+json",
+  "config": {"mediaType": "application/vnd.oci.image.config.v1+json", "digest": "sha256:ccc", "size": 10}
+}`
+		v1 = `{"architecture": "amd64", "history": [{"v1Compatibility": "{\"created\": \"2020-07-01T12:00:00Z\"}"}]}`
+	)
+
+	client, host, closer := newTestClient(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		const prefix = "/v2/jetstack/version-checker/"
+
+		var body string
+		switch r.URL.Path {
+		case prefix + "tags/list":
+			body = `{"tags": ["annotated", "unannotated"]}`
+		case prefix + "blobs/sha256:ccc":
+			body = `{"created": "2020-08-01T12:00:00Z", "architecture": "amd64", "os": "linux"}`
+		case prefix + "manifests/sha256:aaa":
+			body = image
+		case prefix + "manifests/annotated", prefix + "manifests/unannotated":
+			if r.Header.Get("Accept") == dockerAPIv1Header {
+				body = v1
+			} else if strings.HasSuffix(r.URL.Path, "/annotated") {
+				body = annotated
+			} else {
+				body = unannotated
+			}
+		default:
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+
+		w.Header().Set("Docker-Content-Digest", "sha256:fff")
+		w.Write([]byte(body))
+	}))
+	defer closer()
+
+	var (
+		annotationTime = time.Date(2020, 9, 1, 12, 0, 0, 0, time.UTC)
+		configTime     = time.Date(2020, 8, 1, 12, 0, 0, 0, time.UTC)
+		v1Time         = time.Date(2020, 7, 1, 12, 0, 0, 0, time.UTC)
+	)
+
+	tags, err := client.Tags(context.TODO(), host, "jetstack", "version-checker")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expTimestamps := map[string]time.Time{
+		"annotated":   annotationTime,
+		"unannotated": v1Time,
+	}
+	if len(tags) != len(expTimestamps) {
+		t.Fatalf("unexpected number of tags, exp=%d got=%d", len(expTimestamps), len(tags))
+	}
+	for _, tag := range tags {
+		if exp := expTimestamps[tag.Tag]; !tag.Timestamp.Equal(exp) {
+			t.Errorf("%s: unexpected tag timestamp, exp=%s got=%s", tag.Tag, exp, tag.Timestamp)
+		}
+	}
+
+	expTimestamps = map[string]time.Time{
+		"annotated":   annotationTime,
+		"unannotated": configTime,
+	}
+	for reference, exp := range expTimestamps {
+		manifest, err := client.Manifest(context.TODO(), host, "jetstack", "version-checker", reference)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if !manifest.Timestamp.Equal(exp) {
+			t.Errorf("%s: unexpected manifest timestamp, exp=%s got=%s", reference, exp, manifest.Timestamp)
+		}
+	}
+}
+
+func TestTagsHelmCharts(t *testing.T) {
+	const chart = `{
+  "schemaVersion": 2,
+  "config": {"mediaType": "application/vnd.cncf.helm.config.v1+json", "digest": "sha256:ccc", "size": 10},
+  "layers": [
+    {"mediaType": "application/vnd.cncf.helm.chart.content.v1.tar+gzip", "digest": "sha256:ddd", "size": 100}
+  ],
+  "annotations": {"org.opencontainers.image.created": "2023-0%s-01T12:00:00Z"}
+}`
+
+	client, host, closer := newTestClient(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		const prefix = "/v2/charts/cert-manager/"
+
+		if r.URL.Path == prefix+"tags/list" {
+			w.Write([]byte(`{"tags": ["v1.1.0", "v1.2.0"]}`))
+			return
+		}
+
+		tag := strings.TrimPrefix(r.URL.Path, prefix+"manifests/")
+		if tag != "v1.1.0" && tag != "v1.2.0" {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+
+		// Charts have no 2.1 manifest.
+		if r.Header.Get("Accept") == dockerAPIv1Header {
+			w.WriteHeader(http.StatusNotFound)
+			w.Write([]byte(`{"errors": [{"code": "MANIFEST_UNKNOWN"}]}`))
+			return
+		}
+
+		w.Header().Set("Content-Type", ociManifestHeader)
+		w.Header().Set("Docker-Content-Digest", "sha256:"+tag)
+		w.Write([]byte(fmt.Sprintf(chart, tag[3:4])))
+	}))
+	defer closer()
+
+	tags, err := client.Tags(context.TODO(), host, "charts", "cert-manager")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expTags := []api.ImageTag{
+		{
+			Tag:             "v1.1.0",
+			SHA:             "sha256:v1.1.0",
+			Timestamp:       time.Date(2023, 1, 1, 12, 0, 0, 0, time.UTC),
+			ConfigMediaType: api.HelmChartConfigMediaType,
+		},
+		{
+			Tag:             "v1.2.0",
+			SHA:             "sha256:v1.2.0",
+			Timestamp:       time.Date(2023, 2, 1, 12, 0, 0, 0, time.UTC),
+			ConfigMediaType: api.HelmChartConfigMediaType,
+		},
+	}
+	if !reflect.DeepEqual(tags, expTags) {
+		t.Errorf("unexpected tags, exp=%+v got=%+v", expTags, tags)
+	}
+}
+
+func TestTagsPageSize(t *testing.T) {
+	tests := map[string]struct {
+		pageSize int
+		expN     string
+	}{
+		"unset should request the default": {
+			pageSize: 0,
+			expN:     "500",
+		},
+		"within the maximum should be requested": {
+			pageSize: 2000,
+			expN:     "2000",
+		},
+		"above the maximum should be capped": {
+			pageSize: 50000,
+			expN:     "10000",
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			var n string
+
+			client, host, closer := newTestClient(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				n = r.URL.Query().Get("n")
+				w.Write([]byte(`{"tags": []}`))
+			}))
+			defer closer()
+			client.PageSize = test.pageSize
+
+			if _, err := client.Tags(context.TODO(), host, "jetstack", "version-checker"); err != nil {
+				t.Fatal(err)
+			}
+
+			if n != test.expN {
+				t.Errorf("unexpected n parameter, exp=%s got=%s", test.expN, n)
+			}
+		})
+	}
+}
+
+func TestTagsRedirectLoop(t *testing.T) {
+	client, host, closeServer := newTestClient(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Redirect(w, r, r.URL.String(), http.StatusFound)
+	}))
+	defer closeServer()
+
+	_, err := client.Tags(context.TODO(), host, "jetstack", "version-checker")
+	if !clienterrors.IsRedirect(err) {
+		t.Errorf("expected redirect error, got: %v", err)
+	}
+}
+
+// subPathRegistry returns a stub registry handler mounted under the given
+// path, responding with a single tag, which rejects requests of any other
+// path, or with empty path segments.
+func subPathRegistry(t *testing.T, basePath string) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if strings.Contains(r.URL.Path, "//") || !strings.HasPrefix(r.URL.Path, basePath+"/v2/") {
+			t.Errorf("unexpected request path %q", r.URL.Path)
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+
+		switch strings.TrimPrefix(r.URL.Path, basePath) {
+		case "/v2/jetstack/version-checker/tags/list":
+			w.Write([]byte(`{"tags": ["v0.1.0"]}`))
+		case "/v2/jetstack/version-checker/manifests/v0.1.0":
+			w.Header().Set("Docker-Content-Digest", "sha256:aaa")
+			w.Write([]byte(`{"schemaVersion": 2}`))
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	})
+}
+
+func TestTagsBasePath(t *testing.T) {
+	tests := map[string]struct {
+		mountPath string
+		hostPath  string
+		opts      Options
+		repo      string
+		image     string
+	}{
+		"no base path should request the root": {
+			repo:  "jetstack",
+			image: "version-checker",
+		},
+		"path of the host should be the base path": {
+			mountPath: "/registry",
+			hostPath:  "/registry",
+			repo:      "jetstack",
+			image:     "version-checker",
+		},
+		"configured base path should be used over the path of the host": {
+			mountPath: "/proxy/registry",
+			hostPath:  "/registry",
+			opts:      Options{BasePath: "/proxy/registry"},
+			repo:      "jetstack",
+			image:     "version-checker",
+		},
+		"base path of the host should be used over the configured base path": {
+			mountPath: "/mirror",
+			opts:      Options{BasePath: "/registry", HostBasePaths: map[string]string{"{host}": "mirror"}},
+			repo:      "jetstack",
+			image:     "version-checker",
+		},
+		"empty base path of the host should request the root": {
+			opts:  Options{BasePath: "/registry", HostBasePaths: map[string]string{"{host}": ""}},
+			repo:  "jetstack",
+			image: "version-checker",
+		},
+		"slashes of the base path and repository should not produce empty path segments": {
+			mountPath: "/registry/v1",
+			hostPath:  "/",
+			opts:      Options{BasePath: "//registry//v1/"},
+			repo:      "/jetstack/",
+			image:     "/version-checker",
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			server := httptest.NewServer(subPathRegistry(t, test.mountPath))
+			defer server.Close()
+			host := strings.TrimPrefix(server.URL, "http://")
+
+			opts := test.opts
+			opts.Host = server.URL + test.hostPath
+			if basePath, ok := opts.HostBasePaths["{host}"]; ok {
+				opts.HostBasePaths = map[string]string{host: basePath}
+			}
+
+			client, err := New(context.TODO(), logrus.NewEntry(logrus.New()), &opts)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			tags, err := client.Tags(context.TODO(), host, test.repo, test.image)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			expTags := []api.ImageTag{{Tag: "v0.1.0", SHA: "sha256:aaa"}}
+			if !reflect.DeepEqual(tags, expTags) {
+				t.Errorf("unexpected tags, exp=%+v got=%+v", expTags, tags)
+			}
+		})
+	}
+}
@@ -0,0 +1,108 @@
+package selfhosted
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	selfhostederrors "github.com/jetstack/version-checker/pkg/client/selfhosted/errors"
+)
+
+const (
+	// defaultTokenScope is the scope requested from the token URL if none is
+	// configured.
+	defaultTokenScope = "repository:{repository}:pull"
+
+	// defaultTokenExpiry is the lifetime of tokens whose response doesn't
+	// specify one, as defined by the distribution token specification.
+	defaultTokenExpiry = time.Minute
+)
+
+// repositoryToken will return the bearer token to use for requests to the
+// given repository path. If a token URL is configured, a token is requested
+// for the repository's scope and cached until it expires. Otherwise, the
+// configured bearer token is returned.
+func (c *Client) repositoryToken(ctx context.Context, path string) (string, error) {
+	if len(c.TokenURL) == 0 {
+		return c.Bearer, nil
+	}
+
+	scope := c.TokenScope
+	if len(scope) == 0 {
+		scope = defaultTokenScope
+	}
+	scope = strings.ReplaceAll(scope, "{repository}", path)
+
+	token, err := c.tokens.Get(ctx, c.TokenURL, scope, func(ctx context.Context) (string, time.Time, error) {
+		return c.requestToken(ctx, scope)
+	})
+	if err != nil {
+		return "", fmt.Errorf("failed to get token from %q for scope %q: %w",
+			c.TokenURL, scope, err)
+	}
+
+	return token, nil
+}
+
+// requestToken will request a bearer token for the given scope from the
+// token URL.
+func (c *Client) requestToken(ctx context.Context, scope string) (string, time.Time, error) {
+	tokenURL, err := url.Parse(c.TokenURL)
+	if err != nil {
+		return "", time.Time{}, fmt.Errorf("failed to parse token url: %s", err)
+	}
+
+	query := tokenURL.Query()
+	query.Set("scope", scope)
+	tokenURL.RawQuery = query.Encode()
+
+	req, err := http.NewRequest(http.MethodGet, tokenURL.String(), nil)
+	if err != nil {
+		return "", time.Time{}, err
+	}
+
+	req = req.WithContext(ctx)
+	if len(c.Username) > 0 || len(c.Password) > 0 {
+		req.SetBasicAuth(c.Username, c.Password)
+	}
+
+	resp, err := c.Do(req)
+	if err != nil {
+		return "", time.Time{}, err
+	}
+	defer resp.Body.Close()
+
+	body, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return "", time.Time{}, err
+	}
+
+	if resp.StatusCode != http.StatusOK {
+		return "", time.Time{}, selfhostederrors.NewHTTPError(resp.StatusCode, body)
+	}
+
+	response := new(AuthResponse)
+	if err := json.Unmarshal(body, response); err != nil {
+		return "", time.Time{}, fmt.Errorf("unexpected token response: %s", err)
+	}
+
+	token := response.Token
+	if len(token) == 0 {
+		token = response.AccessToken
+	}
+	if len(token) == 0 {
+		return "", time.Time{}, fmt.Errorf("no token in response")
+	}
+
+	expiry := defaultTokenExpiry
+	if response.ExpiresIn > 0 {
+		expiry = time.Duration(response.ExpiresIn) * time.Second
+	}
+
+	return token, time.Now().Add(expiry), nil
+}
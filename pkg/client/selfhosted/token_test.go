@@ -0,0 +1,94 @@
+package selfhosted
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/sirupsen/logrus"
+)
+
+func TestTagsTokenURL(t *testing.T) {
+	var (
+		mu            sync.Mutex
+		tokenRequests int
+	)
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/custom/auth":
+			user, pass, ok := r.BasicAuth()
+			if !ok || user != "joshvanl" || pass != "password" ||
+				r.URL.Query().Get("scope") != "repository:jetstack/version-checker:pull" ||
+				r.URL.Query().Get("service") != "registry" {
+				w.WriteHeader(http.StatusUnauthorized)
+				return
+			}
+
+			mu.Lock()
+			tokenRequests++
+			mu.Unlock()
+
+			w.Write([]byte(`{"access_token": "scoped-token", "expires_in": 300}`))
+			return
+
+		case "/v2/token":
+			// The discoverable token endpoint is not served.
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+
+		if r.Header.Get("Authorization") != "Bearer scoped-token" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+
+		if strings.HasSuffix(r.URL.Path, "/tags/list") {
+			w.Write([]byte(`{"tags": ["v0.1.0", "v0.2.0"]}`))
+			return
+		}
+
+		w.Header().Set("Docker-Content-Digest", "sha256:aaa")
+		w.Write([]byte(`{}`))
+	}))
+	defer server.Close()
+
+	newClient := func(tokenURL string) *Client {
+		client, err := New(context.TODO(), logrus.NewEntry(logrus.New()), &Options{
+			Host:     server.URL,
+			Username: "joshvanl",
+			Password: "password",
+			TokenURL: tokenURL,
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		return client
+	}
+
+	host := strings.TrimPrefix(server.URL, "http://")
+
+	client := newClient(server.URL + "/custom/auth?service=registry")
+	for i := 0; i < 2; i++ {
+		tags, err := client.Tags(context.TODO(), host, "jetstack", "version-checker")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(tags) != 2 {
+			t.Errorf("unexpected number of tags, exp=2 got=%d", len(tags))
+		}
+	}
+
+	if tokenRequests != 1 {
+		t.Errorf("expected token to be cached, got %d token requests", tokenRequests)
+	}
+
+	// A token URL which rejects the scope should error.
+	client = newClient(server.URL + "/custom/auth")
+	if _, err := client.Tags(context.TODO(), host, "jetstack", "version-checker"); err == nil {
+		t.Error("expected error with rejected token scope, got none")
+	}
+}
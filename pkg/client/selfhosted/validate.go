@@ -0,0 +1,53 @@
+package selfhosted
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+
+	clienterrors "github.com/jetstack/version-checker/pkg/client/errors"
+	selfhostederrors "github.com/jetstack/version-checker/pkg/client/selfhosted/errors"
+	"github.com/jetstack/version-checker/pkg/client/util"
+)
+
+// Validate will check that the tags of the given host, repo and image may be
+// listed, by requesting a single tag, without listing all tags or fetching
+// any manifests. Returns a clienterrors.ErrorUnauthorized if the registry, or
+// its token service, rejects the request's credentials, and a
+// clienterrors.ErrorImageNotFound if the image does not exist. Registries
+// which cannot be reached return a clienterrors.ErrorNetwork.
+func (c *Client) Validate(ctx context.Context, host, repo, image string) error {
+	path := cleanPath(util.JoinRepoImage(repo, image))
+	tagURL := fmt.Sprintf(tagsPath, c.baseURL(host), path, 1)
+
+	token, err := c.repositoryToken(ctx, path)
+	if err != nil {
+		return statusError(host, path, err)
+	}
+
+	var tagResponse TagResponse
+	if _, err := c.doRequest(ctx, tagURL, "", token, &tagResponse); err != nil {
+		return statusError(host, path, err)
+	}
+
+	return nil
+}
+
+// statusError returns the given error of a request for the given image as a
+// typed error, if it is an error response of a known status.
+func statusError(host, image string, err error) error {
+	var httpErr *selfhostederrors.HTTPError
+	if !errors.As(err, &httpErr) {
+		return err
+	}
+
+	switch httpErr.StatusCode {
+	case http.StatusUnauthorized, http.StatusForbidden:
+		return clienterrors.NewErrorUnauthorized(host, image, httpErr.StatusCode)
+	case http.StatusNotFound:
+		return clienterrors.NewErrorImageNotFound(host, image)
+	default:
+		return err
+	}
+}
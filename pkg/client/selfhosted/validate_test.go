@@ -0,0 +1,160 @@
+package selfhosted
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/sirupsen/logrus"
+
+	clienterrors "github.com/jetstack/version-checker/pkg/client/errors"
+	selfhostederrors "github.com/jetstack/version-checker/pkg/client/selfhosted/errors"
+	"github.com/jetstack/version-checker/pkg/client/util"
+)
+
+func TestValidate(t *testing.T) {
+	var (
+		mu       sync.Mutex
+		requests []string
+	)
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		requests = append(requests, r.URL.RequestURI())
+		mu.Unlock()
+
+		switch r.URL.Path {
+		case "/v2/jetstack/version-checker/tags/list":
+			w.Write([]byte(`{"tags": ["v0.1.0"]}`))
+		case "/v2/jetstack/private/tags/list":
+			if r.Header.Get("Authorization") != "Bearer token" {
+				w.WriteHeader(http.StatusUnauthorized)
+				return
+			}
+			w.Write([]byte(`{"tags": ["v0.1.0"]}`))
+		case "/v2/jetstack/forbidden/tags/list":
+			w.WriteHeader(http.StatusForbidden)
+		case "/v2/jetstack/unavailable/tags/list":
+			w.WriteHeader(http.StatusServiceUnavailable)
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer server.Close()
+	host := strings.TrimPrefix(server.URL, "http://")
+
+	// A closed listener's address refuses connections.
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	unreachableHost := listener.Addr().String()
+	listener.Close()
+
+	tests := map[string]struct {
+		host, image string
+		bearer      string
+		expErr      func(error) bool
+	}{
+		"reachable image should not error": {
+			host:  host,
+			image: "version-checker",
+		},
+		"private image with credentials should not error": {
+			host:   host,
+			image:  "private",
+			bearer: "token",
+		},
+		"private image without credentials should be unauthorized": {
+			host:   host,
+			image:  "private",
+			expErr: clienterrors.IsUnauthorized,
+		},
+		"forbidden image should be unauthorized": {
+			host:   host,
+			image:  "forbidden",
+			expErr: clienterrors.IsUnauthorized,
+		},
+		"missing image should be not found": {
+			host:   host,
+			image:  "missing",
+			expErr: clienterrors.IsImageNotFound,
+		},
+		"other error responses should be returned": {
+			host:  host,
+			image: "unavailable",
+			expErr: func(err error) bool {
+				httpErr, ok := selfhostederrors.IsHTTPError(err)
+				return ok && httpErr.StatusCode == http.StatusServiceUnavailable
+			},
+		},
+		"unreachable registry should be a network error": {
+			host:   unreachableHost,
+			image:  "version-checker",
+			expErr: clienterrors.IsNetwork,
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			mu.Lock()
+			requests = nil
+			mu.Unlock()
+
+			client, err := New(context.TODO(), logrus.NewEntry(logrus.New()), &Options{
+				Host:      "http://" + test.host,
+				Bearer:    test.bearer,
+				Transport: util.NewNetworkErrorTransport(nil),
+			})
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			err = client.Validate(context.TODO(), test.host, "jetstack", test.image)
+			if test.expErr == nil {
+				if err != nil {
+					t.Fatalf("unexpected error: %s", err)
+				}
+			} else if err == nil || !test.expErr(err) {
+				t.Fatalf("unexpected error, got=%v", err)
+			}
+
+			if test.host != host {
+				return
+			}
+
+			mu.Lock()
+			defer mu.Unlock()
+
+			// Only a single tag is requested, without any manifests.
+			exp := "/v2/jetstack/" + test.image + "/tags/list?n=1"
+			if len(requests) != 1 || requests[0] != exp {
+				t.Errorf("unexpected requests, exp=[%s] got=%v", exp, requests)
+			}
+		})
+	}
+}
+
+func TestManifestNotFound(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNotFound)
+	}))
+	defer server.Close()
+	host := strings.TrimPrefix(server.URL, "http://")
+
+	client, err := New(context.TODO(), logrus.NewEntry(logrus.New()), &Options{
+		Host: "http://" + host,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, err = client.Manifest(context.TODO(), host, "jetstack", "version-checker", "sha256:aaa")
+	if !clienterrors.IsImageNotFound(err) {
+		t.Fatalf("unexpected error, exp=image not found got=%v", err)
+	}
+}
@@ -0,0 +1,83 @@
+package client
+
+import (
+	"errors"
+	"time"
+
+	clienterrors "github.com/jetstack/version-checker/pkg/client/errors"
+)
+
+// hostClassification is the registry client of a host, and whether the client
+// explicitly matched the host, rather than being the fallback client.
+type hostClassification struct {
+	client  ImageClient
+	matched bool
+}
+
+// unsupportedHost is the failure of a host which the fallback client found
+// not to serve the registry API, returned by lookups of the host until it
+// expires.
+type unsupportedHost struct {
+	err     *clienterrors.ErrorUnsupportedHost
+	expires time.Time
+}
+
+// RegisterClient will register the given registry client, which is matched
+// before all other clients. The classifications of hosts, and any hosts found
+// to be unsupported, are reset so that hosts of the client are matched by it.
+func (c *Client) RegisterClient(client ImageClient) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.clients = append([]ImageClient{client}, c.clients...)
+	c.classifications = make(map[string]hostClassification)
+	c.unsupported = make(map[string]unsupportedHost)
+}
+
+// unsupportedHostErr returns the failure of the given host, if it has been
+// found to be unsupported and the failure has not expired.
+func (c *Client) unsupportedHostErr(host string) error {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	if unsupported, ok := c.unsupported[host]; ok && c.now().Before(unsupported.expires) {
+		return unsupported.err
+	}
+
+	return nil
+}
+
+// checkUnsupported will return the given error of a request to the given host
+// with the given client, such as of listing its tags. If the client is the fallback client, and
+// the error shows that the host does not serve the registry API, the host is
+// recorded as unsupported for the unsupported host TTL, and the error is
+// returned as a clienterrors.ErrorUnsupportedHost.
+func (c *Client) checkUnsupported(client ImageClient, host string, err error) error {
+	if err == nil || client != c.fallbackClient || c.unsupportedTTL <= 0 || !isUnsupportedHostErr(err) {
+		return err
+	}
+
+	unsupported := clienterrors.NewErrorUnsupportedHost(host, err)
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.unsupported[host] = unsupportedHost{
+		err:     unsupported,
+		expires: c.now().Add(c.unsupportedTTL),
+	}
+
+	return unsupported
+}
+
+// isUnsupportedHostErr returns whether the given error shows that a host does
+// not serve the registry API, being a host which does not exist, or a
+// response which is not of the registry API and would not succeed if retried.
+// Other failures, such as those of a missing image or of auth, are not.
+func isUnsupportedHostErr(err error) bool {
+	var network *clienterrors.ErrorNetwork
+	if errors.As(err, &network) {
+		return network.Kind == clienterrors.NetworkErrorDNSNotFound
+	}
+
+	return clienterrors.IsDecode(err) && !clienterrors.IsRetryable(err)
+}
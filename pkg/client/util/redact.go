@@ -0,0 +1,119 @@
+package util
+
+import (
+	"errors"
+	"net/url"
+	"strings"
+
+	"github.com/sirupsen/logrus"
+)
+
+const (
+	// Redacted is the replacement of secrets which have been redacted.
+	Redacted = "<redacted>"
+)
+
+var (
+	// sensitiveQueryParams are query parameter keys whose values are always
+	// redacted from URLs.
+	sensitiveQueryParams = []string{
+		"token", "access_token", "refresh_token", "password", "secret",
+		"apikey", "api_key", "key", "signature", "x-amz-signature",
+		"x-amz-security-token",
+	}
+)
+
+// Redact returns the given string with all occurrences of the given secrets
+// replaced. Empty secrets are ignored.
+func Redact(s string, secrets ...string) string {
+	for _, secret := range secrets {
+		if len(secret) == 0 {
+			continue
+		}
+		s = strings.ReplaceAll(s, secret, Redacted)
+	}
+
+	return s
+}
+
+// RedactURL returns the given URL with any user info password, and the
+// values of sensitive query parameters, redacted. Strings which fail to parse
+// as a URL are returned unchanged.
+func RedactURL(rawURL string) string {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return rawURL
+	}
+
+	if u.User != nil {
+		if _, ok := u.User.Password(); ok {
+			u.User = url.UserPassword(u.User.Username(), Redacted)
+		}
+	}
+
+	if len(u.RawQuery) > 0 {
+		query := u.Query()
+		for key := range query {
+			for _, sensitive := range sensitiveQueryParams {
+				if strings.EqualFold(key, sensitive) {
+					query.Set(key, Redacted)
+					break
+				}
+			}
+		}
+		u.RawQuery = query.Encode()
+	}
+
+	// Encode escapes the redacted marker, so restore it to keep it readable.
+	return strings.ReplaceAll(u.String(), url.QueryEscape(Redacted), Redacted)
+}
+
+// RedactHook is a logrus hook which redacts the given secrets from the message
+// and fields of every log entry.
+type RedactHook struct {
+	secrets []string
+}
+
+// NewRedactHook returns a new logrus hook which will redact the given
+// secrets. Empty secrets are ignored.
+func NewRedactHook(secrets ...string) *RedactHook {
+	hook := new(RedactHook)
+	for _, secret := range secrets {
+		if len(secret) > 0 {
+			hook.secrets = append(hook.secrets, secret)
+		}
+	}
+
+	return hook
+}
+
+// Levels returns all log levels, so that every entry is redacted.
+func (r *RedactHook) Levels() []logrus.Level {
+	return logrus.AllLevels
+}
+
+// Fire redacts the secrets from the log entry message and fields.
+func (r *RedactHook) Fire(entry *logrus.Entry) error {
+	if len(r.secrets) == 0 {
+		return nil
+	}
+
+	entry.Message = Redact(entry.Message, r.secrets...)
+
+	// The data map may be shared with the parent entry, so build a new one
+	// rather than modifying it in place.
+	data := make(logrus.Fields, len(entry.Data))
+	for k, v := range entry.Data {
+		switch value := v.(type) {
+		case string:
+			data[k] = Redact(value, r.secrets...)
+		case error:
+			data[k] = errors.New(Redact(value.Error(), r.secrets...))
+		default:
+			data[k] = v
+		}
+	}
+	entry.Data = data
+
+	return nil
+}
@@ -0,0 +1,42 @@
+package util
+
+import (
+	"net/http"
+	"strings"
+
+	clienterrors "github.com/jetstack/version-checker/pkg/client/errors"
+)
+
+const (
+	// MaxRedirects is the maximum number of redirects followed by a request
+	// to a registry.
+	MaxRedirects = 5
+)
+
+// CheckRedirect is the redirect policy of requests to registries, for use as
+// an http.Client's CheckRedirect. Redirects are followed up to MaxRedirects,
+// and the Authorization header is only kept when redirected to the same host
+// and port as the original request, without downgrading from HTTPS to HTTP,
+// so that credentials are never sent to another host. Returns an
+// ErrorRedirect if a redirect revisits a URL of the request, or exceeds
+// MaxRedirects.
+func CheckRedirect(req *http.Request, via []*http.Request) error {
+	original := via[0].URL
+
+	for _, prev := range via {
+		if prev.URL.String() == req.URL.String() {
+			return clienterrors.NewErrorRedirect(original.Host, RedactURL(req.URL.String()), len(via), true)
+		}
+	}
+
+	if len(via) >= MaxRedirects {
+		return clienterrors.NewErrorRedirect(original.Host, RedactURL(req.URL.String()), len(via), false)
+	}
+
+	downgraded := original.Scheme == "https" && req.URL.Scheme != "https"
+	if downgraded || !strings.EqualFold(original.Host, req.URL.Host) {
+		req.Header.Del("Authorization")
+	}
+
+	return nil
+}
@@ -0,0 +1,149 @@
+package util
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+
+	clienterrors "github.com/jetstack/version-checker/pkg/client/errors"
+)
+
+func TestCheckRedirect(t *testing.T) {
+	var (
+		mu             sync.Mutex
+		authorizations = make(map[string]string)
+	)
+
+	record := func(name string, r *http.Request) {
+		mu.Lock()
+		defer mu.Unlock()
+		authorizations[name] = r.Header.Get("Authorization")
+	}
+
+	other := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		record("other", r)
+	}))
+	defer other.Close()
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch {
+		case r.URL.Path == "/v2/":
+			http.Redirect(w, r, "/v2/", http.StatusFound)
+		case r.URL.Path == "/ping":
+			http.Redirect(w, r, "/pong", http.StatusFound)
+		case r.URL.Path == "/pong":
+			http.Redirect(w, r, "/ping", http.StatusFound)
+		case strings.HasPrefix(r.URL.Path, "/chain/"):
+			n, _ := strconv.Atoi(strings.TrimPrefix(r.URL.Path, "/chain/"))
+			if n >= 3 && r.URL.Query().Get("endless") != "true" {
+				record("chain", r)
+				return
+			}
+			http.Redirect(w, r, fmt.Sprintf("/chain/%d?%s", n+1, r.URL.RawQuery), http.StatusFound)
+		case r.URL.Path == "/cross":
+			http.Redirect(w, r, other.URL+"/v2/", http.StatusTemporaryRedirect)
+		}
+	}))
+	defer server.Close()
+
+	tests := map[string]struct {
+		path             string
+		expLoop          bool
+		expTooMany       bool
+		expRecorded      string
+		expAuthorization string
+	}{
+		"redirect to itself should be a loop": {
+			path:    "/v2/",
+			expLoop: true,
+		},
+		"redirects between urls should be a loop": {
+			path:    "/ping",
+			expLoop: true,
+		},
+		"endless redirects should be capped": {
+			path:       "/chain/0?endless=true",
+			expTooMany: true,
+		},
+		"redirects on the same host should keep authorization": {
+			path:             "/chain/0",
+			expRecorded:      "chain",
+			expAuthorization: "Bearer my-token",
+		},
+		"redirect to another host should drop authorization": {
+			path:             "/cross",
+			expRecorded:      "other",
+			expAuthorization: "",
+		},
+	}
+
+	client := &http.Client{CheckRedirect: CheckRedirect}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			req, err := http.NewRequest(http.MethodGet, server.URL+test.path, nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+			req.Header.Set("Authorization", "Bearer my-token")
+
+			resp, err := client.Do(req)
+			if err == nil {
+				resp.Body.Close()
+			}
+
+			var redirect *clienterrors.ErrorRedirect
+			isRedirect := errors.As(err, &redirect)
+			if isRedirect != (test.expLoop || test.expTooMany) {
+				t.Fatalf("unexpected redirect error, exp=%t got=%v", test.expLoop || test.expTooMany, err)
+			}
+			if isRedirect {
+				if redirect.Loop != test.expLoop {
+					t.Errorf("unexpected loop, exp=%t got=%t", test.expLoop, redirect.Loop)
+				}
+				if test.expTooMany && redirect.Redirects != MaxRedirects {
+					t.Errorf("unexpected redirects, exp=%d got=%d", MaxRedirects, redirect.Redirects)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			mu.Lock()
+			defer mu.Unlock()
+			authorization, ok := authorizations[test.expRecorded]
+			if !ok {
+				t.Fatalf("expected request to %q, got none", test.expRecorded)
+			}
+			if authorization != test.expAuthorization {
+				t.Errorf("unexpected authorization, exp=%q got=%q", test.expAuthorization, authorization)
+			}
+		})
+	}
+}
+
+func TestCheckRedirectDowngrade(t *testing.T) {
+	via, err := http.NewRequest(http.MethodGet, "https://registry.example.com/v2/", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	req, err := http.NewRequest(http.MethodGet, "http://registry.example.com/v2/", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	req.Header.Set("Authorization", "Bearer my-token")
+
+	if err := CheckRedirect(req, []*http.Request{via}); err != nil {
+		t.Fatal(err)
+	}
+	if authorization := req.Header.Get("Authorization"); len(authorization) > 0 {
+		t.Errorf("expected authorization to be dropped on downgrade, got %q", authorization)
+	}
+}
@@ -0,0 +1,122 @@
+package util
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+const (
+	// maxTokenRefreshMargin is the maximum duration before a token's expiry
+	// at which it is refreshed.
+	maxTokenRefreshMargin = time.Minute
+)
+
+// TokenFetcher fetches a new token, returning the token and the time it
+// expires.
+type TokenFetcher func(ctx context.Context) (string, time.Time, error)
+
+// TokenCache is a cache of registry tokens, keyed by host and scope. Tokens
+// are refreshed shortly before they expire. Concurrent requests for a token
+// which needs refreshing are coalesced into a single fetch, so that the
+// registry's auth endpoint is not called once per request.
+type TokenCache struct {
+	mu       sync.Mutex
+	tokens   map[string]*cachedToken
+	inflight map[string]*tokenCall
+
+	now func() time.Time
+}
+
+// cachedToken is a token held in the TokenCache.
+type cachedToken struct {
+	token     string
+	refreshAt time.Time
+}
+
+// tokenCall is an in-flight fetch of a token, which waiting callers share.
+type tokenCall struct {
+	done  chan struct{}
+	token string
+	err   error
+}
+
+// NewTokenCache returns a new, empty TokenCache.
+func NewTokenCache() *TokenCache {
+	return &TokenCache{
+		tokens:   make(map[string]*cachedToken),
+		inflight: make(map[string]*tokenCall),
+		now:      time.Now,
+	}
+}
+
+// Get returns the cached token of the given host and scope, using fetch to
+// get a new token if none is cached, or the cached token is due to expire.
+// If a fetch is already in flight for the host and scope, Get waits for its
+// result rather than fetching again.
+func (t *TokenCache) Get(ctx context.Context, host, scope string, fetch TokenFetcher) (string, error) {
+	key := host + "|" + scope
+
+	t.mu.Lock()
+	if cached, ok := t.tokens[key]; ok && t.now().Before(cached.refreshAt) {
+		t.mu.Unlock()
+		return cached.token, nil
+	}
+
+	call, ok := t.inflight[key]
+	if !ok {
+		call = &tokenCall{done: make(chan struct{})}
+		t.inflight[key] = call
+		go t.fetch(ctx, key, call, fetch)
+	}
+	t.mu.Unlock()
+
+	select {
+	case <-call.done:
+		return call.token, call.err
+	case <-ctx.Done():
+		return "", ctx.Err()
+	}
+}
+
+// fetch will fetch a token for the given call, and cache it on success.
+func (t *TokenCache) fetch(ctx context.Context, key string, call *tokenCall, fetch TokenFetcher) {
+	token, expiry, err := fetch(ctx)
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	delete(t.inflight, key)
+	call.token, call.err = token, err
+	defer close(call.done)
+
+	if err != nil {
+		return
+	}
+
+	// Refresh the token before it expires, by a tenth of its lifetime up to
+	// the maximum margin.
+	margin := expiry.Sub(t.now()) / 10
+	if margin > maxTokenRefreshMargin {
+		margin = maxTokenRefreshMargin
+	}
+
+	t.tokens[key] = &cachedToken{
+		token:     token,
+		refreshAt: expiry.Add(-margin),
+	}
+}
+
+// Tokens returns all tokens currently held in the cache, so that they may be
+// redacted from logs.
+func (t *TokenCache) Tokens() []string {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	var tokens []string
+	for _, cached := range t.tokens {
+		tokens = append(tokens, cached.token)
+	}
+
+	return tokens
+}
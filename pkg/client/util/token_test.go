@@ -0,0 +1,106 @@
+package util
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestTokenCacheGet(t *testing.T) {
+	now := time.Unix(1600000000, 0)
+
+	cache := NewTokenCache()
+	cache.now = func() time.Time { return now }
+
+	var fetches int
+	fetch := func(context.Context) (string, time.Time, error) {
+		fetches++
+		return "token", now.Add(time.Hour), nil
+	}
+
+	for i := 0; i < 2; i++ {
+		token, err := cache.Get(context.TODO(), "host", "scope", fetch)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if token != "token" {
+			t.Errorf("unexpected token, exp=%q got=%q", "token", token)
+		}
+	}
+	if fetches != 1 {
+		t.Errorf("expected token to be cached, exp=1 got=%d fetches", fetches)
+	}
+
+	// A different scope of the same host should be fetched separately.
+	if _, err := cache.Get(context.TODO(), "host", "other-scope", fetch); err != nil {
+		t.Fatal(err)
+	}
+	if fetches != 2 {
+		t.Errorf("expected token of new scope to be fetched, exp=2 got=%d fetches", fetches)
+	}
+
+	// Tokens should be refreshed within the margin before they expire.
+	now = now.Add(time.Hour - time.Second*30)
+	if _, err := cache.Get(context.TODO(), "host", "scope", fetch); err != nil {
+		t.Fatal(err)
+	}
+	if fetches != 3 {
+		t.Errorf("expected expiring token to be refreshed, exp=3 got=%d fetches", fetches)
+	}
+}
+
+func TestTokenCacheGetError(t *testing.T) {
+	cache := NewTokenCache()
+
+	var fetches int
+	fetch := func(context.Context) (string, time.Time, error) {
+		fetches++
+		return "", time.Time{}, errors.New("fetch failed")
+	}
+
+	for i := 0; i < 2; i++ {
+		if _, err := cache.Get(context.TODO(), "host", "scope", fetch); err == nil {
+			t.Error("expected error, got none")
+		}
+	}
+	if fetches != 2 {
+		t.Errorf("expected failed fetches not to be cached, exp=2 got=%d fetches", fetches)
+	}
+	if tokens := cache.Tokens(); len(tokens) != 0 {
+		t.Errorf("expected no cached tokens, got=%q", tokens)
+	}
+}
+
+func TestTokenCacheGetConcurrent(t *testing.T) {
+	cache := NewTokenCache()
+
+	var fetches int32
+	release := make(chan struct{})
+	fetch := func(context.Context) (string, time.Time, error) {
+		atomic.AddInt32(&fetches, 1)
+		<-release
+		return "token", time.Now().Add(time.Hour), nil
+	}
+
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if token, err := cache.Get(context.TODO(), "host", "scope", fetch); err != nil || token != "token" {
+				t.Errorf("unexpected result, token=%q err=%v", token, err)
+			}
+		}()
+	}
+
+	time.Sleep(time.Millisecond * 20)
+	close(release)
+	wg.Wait()
+
+	if n := atomic.LoadInt32(&fetches); n != 1 {
+		t.Errorf("expected concurrent gets to share a fetch, exp=1 got=%d fetches", n)
+	}
+}
@@ -0,0 +1,470 @@
+package util
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/base64"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"time"
+
+	clienterrors "github.com/jetstack/version-checker/pkg/client/errors"
+)
+
+// CATransport is an http.RoundTripper which trusts a distinct CA bundle per
+// registry host. Requests to hosts without a configured bundle are made with
+// the base transport, which trusts the system pool.
+type CATransport struct {
+	base       http.RoundTripper
+	transports map[string]http.RoundTripper
+}
+
+// NewCATransport returns a CATransport trusting the given PEM encoded CA
+// bundles, keyed by host. Hosts may include a port, in which case the bundle
+// is only used for that port. Returns an error if any bundle contains no
+// certificates.
+func NewCATransport(bundles map[string][]byte) (*CATransport, error) {
+	t := &CATransport{
+		base:       http.DefaultTransport,
+		transports: make(map[string]http.RoundTripper, len(bundles)),
+	}
+
+	for host, bundle := range bundles {
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(bundle) {
+			return nil, fmt.Errorf("no certificates found in CA bundle for host %q", host)
+		}
+
+		transport := http.DefaultTransport.(*http.Transport).Clone()
+		transport.TLSClientConfig = &tls.Config{RootCAs: pool}
+
+		t.transports[host] = transport
+	}
+
+	return t, nil
+}
+
+// RoundTrip will make the request using the transport of the request's host,
+// preferring a bundle configured for the host and port over the host alone.
+func (t *CATransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	if transport, ok := t.transports[req.URL.Host]; ok {
+		return transport.RoundTrip(req)
+	}
+	if transport, ok := t.transports[req.URL.Hostname()]; ok {
+		return transport.RoundTrip(req)
+	}
+
+	return t.base.RoundTrip(req)
+}
+
+// NetworkErrorTransport is an http.RoundTripper which classifies the network
+// errors of requests made with the base transport, so that it is known
+// whether they may succeed if retried.
+type NetworkErrorTransport struct {
+	base http.RoundTripper
+}
+
+// NewNetworkErrorTransport returns a NetworkErrorTransport of the given base
+// transport. Defaults to http.DefaultTransport if nil.
+func NewNetworkErrorTransport(base http.RoundTripper) *NetworkErrorTransport {
+	if base == nil {
+		base = http.DefaultTransport
+	}
+
+	return &NetworkErrorTransport{base: base}
+}
+
+// RoundTrip will make the request using the base transport, returning any
+// network error as a clienterrors.ErrorNetwork of the request's host.
+func (t *NetworkErrorTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	resp, err := t.base.RoundTrip(req)
+	if err != nil {
+		return nil, clienterrors.ClassifyNetworkError(req.URL.Host, err)
+	}
+
+	return resp, nil
+}
+
+// MaxBodyTransport is an http.RoundTripper which limits the size of the
+// response bodies of requests made with the base transport, so that huge
+// responses of broken or malicious registries cannot exhaust memory.
+type MaxBodyTransport struct {
+	base     http.RoundTripper
+	maxBytes int64
+}
+
+// NewMaxBodyTransport returns a MaxBodyTransport of the given base transport,
+// limiting response bodies to the given number of bytes. Defaults to
+// http.DefaultTransport if nil.
+func NewMaxBodyTransport(base http.RoundTripper, maxBytes int64) *MaxBodyTransport {
+	if base == nil {
+		base = http.DefaultTransport
+	}
+
+	return &MaxBodyTransport{base: base, maxBytes: maxBytes}
+}
+
+// RoundTrip will make the request using the base transport, returning a
+// clienterrors.ErrorResponseTooLarge if the response's content length exceeds
+// the maximum. Otherwise, reading more than the maximum from the body returns
+// the error.
+func (t *MaxBodyTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	resp, err := t.base.RoundTrip(req)
+	if err != nil {
+		return nil, err
+	}
+
+	if resp.ContentLength > t.maxBytes {
+		resp.Body.Close()
+		return nil, clienterrors.NewErrorResponseTooLarge(req.URL.Host, t.maxBytes)
+	}
+
+	resp.Body = &maxBodyReader{
+		ReadCloser: resp.Body,
+		host:       req.URL.Host,
+		maxBytes:   t.maxBytes,
+		remaining:  t.maxBytes,
+	}
+
+	return resp, nil
+}
+
+// maxBodyReader is a response body returning an ErrorResponseTooLarge once
+// more than the maximum bytes are read.
+type maxBodyReader struct {
+	io.ReadCloser
+
+	host      string
+	maxBytes  int64
+	remaining int64
+}
+
+func (r *maxBodyReader) Read(p []byte) (int, error) {
+	if r.remaining < 0 {
+		return 0, clienterrors.NewErrorResponseTooLarge(r.host, r.maxBytes)
+	}
+
+	// Read one byte more than remains, to detect bodies exceeding the maximum.
+	if int64(len(p)) > r.remaining+1 {
+		p = p[:r.remaining+1]
+	}
+
+	n, err := r.ReadCloser.Read(p)
+	if int64(n) > r.remaining {
+		n, r.remaining = int(r.remaining), -1
+		return n, clienterrors.NewErrorResponseTooLarge(r.host, r.maxBytes)
+	}
+	r.remaining -= int64(n)
+
+	return n, err
+}
+
+// TimeoutFunc returns the timeout of the given registry request. No timeout
+// is applied if zero.
+type TimeoutFunc func(req *http.Request) time.Duration
+
+// TimeoutTransport is an http.RoundTripper which bounds each request made with
+// the base transport, including reading its response body, by the timeout of
+// the request, in place of the timeout of the http.Client, so that timeouts
+// may differ per registry host.
+type TimeoutTransport struct {
+	base        http.RoundTripper
+	timeoutFunc TimeoutFunc
+}
+
+// NewTimeoutTransport returns a TimeoutTransport of the given base transport,
+// bounding requests by the timeouts returned by the given func. Defaults to
+// http.DefaultTransport if nil.
+func NewTimeoutTransport(base http.RoundTripper, timeoutFunc TimeoutFunc) *TimeoutTransport {
+	if base == nil {
+		base = http.DefaultTransport
+	}
+
+	return &TimeoutTransport{base: base, timeoutFunc: timeoutFunc}
+}
+
+// RoundTrip will make the request using the base transport, cancelling the
+// request once its timeout has elapsed, or its response body is closed.
+func (t *TimeoutTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	timeout := t.timeoutFunc(req)
+	if timeout <= 0 {
+		return t.base.RoundTrip(req)
+	}
+
+	ctx, cancel := context.WithTimeout(req.Context(), timeout)
+	resp, err := t.base.RoundTrip(req.WithContext(ctx))
+	if err != nil {
+		cancel()
+		return nil, err
+	}
+
+	resp.Body = &cancelBody{ReadCloser: resp.Body, cancel: cancel}
+
+	return resp, nil
+}
+
+// cancelBody is a response body which cancels the context of its request once
+// closed.
+type cancelBody struct {
+	io.ReadCloser
+	cancel context.CancelFunc
+}
+
+func (b *cancelBody) Close() error {
+	err := b.ReadCloser.Close()
+	b.cancel()
+	return err
+}
+
+// StatusFunc is called with the host and status code of a registry response.
+type StatusFunc func(host string, statusCode int)
+
+// StatusTransport is an http.RoundTripper which reports the status code of
+// each error response, with a 4xx or 5xx status, of requests made with the
+// base transport, such as to count the responses of each registry.
+type StatusTransport struct {
+	base       http.RoundTripper
+	statusFunc StatusFunc
+}
+
+// NewStatusTransport returns a StatusTransport of the given base transport,
+// reporting error responses to the given func. Defaults to
+// http.DefaultTransport if nil.
+func NewStatusTransport(base http.RoundTripper, statusFunc StatusFunc) *StatusTransport {
+	if base == nil {
+		base = http.DefaultTransport
+	}
+
+	return &StatusTransport{base: base, statusFunc: statusFunc}
+}
+
+// RoundTrip will make the request using the base transport, reporting the
+// status code of the response if it is an error.
+func (t *StatusTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	resp, err := t.base.RoundTrip(req)
+	if err == nil && resp.StatusCode >= http.StatusBadRequest {
+		t.statusFunc(req.URL.Host, resp.StatusCode)
+	}
+
+	return resp, err
+}
+
+// DefaultRequestIDHeader is the header the request ID is set as by a
+// RequestIDTransport, if none is configured.
+const DefaultRequestIDHeader = "X-Request-ID"
+
+// RequestIDTransport is an http.RoundTripper which sets the request ID carried
+// by the context of each request as a header, such as to correlate registry
+// requests with the requests which triggered them.
+type RequestIDTransport struct {
+	base   http.RoundTripper
+	key    interface{}
+	header string
+}
+
+// NewRequestIDTransport returns a RequestIDTransport of the given base
+// transport, setting the string value of the given context key as the given
+// header. The header defaults to DefaultRequestIDHeader if empty, and the base
+// transport to http.DefaultTransport if nil.
+func NewRequestIDTransport(base http.RoundTripper, key interface{}, header string) *RequestIDTransport {
+	if base == nil {
+		base = http.DefaultTransport
+	}
+	if len(header) == 0 {
+		header = DefaultRequestIDHeader
+	}
+
+	return &RequestIDTransport{base: base, key: key, header: header}
+}
+
+// RoundTrip will make the request using the base transport, with the request
+// ID header set if the request's context carries a non-empty request ID.
+// Requests are cloned before setting the header, so the given request is not
+// modified.
+func (t *RequestIDTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	if id, ok := req.Context().Value(t.key).(string); ok && len(id) > 0 {
+		req = req.Clone(req.Context())
+		req.Header.Set(t.header, id)
+	}
+
+	return t.base.RoundTrip(req)
+}
+
+// ProxyAuthTransport is an http.RoundTripper which sets the basic auth
+// credentials of a proxy as the Proxy-Authorization header of each request
+// made to its host with the base transport, such as for authenticating reverse
+// proxies in front of a registry, leaving the Authorization header to the
+// registry.
+type ProxyAuthTransport struct {
+	base               http.RoundTripper
+	host               string
+	username, password string
+}
+
+// NewProxyAuthTransport returns a ProxyAuthTransport of the given base
+// transport, authenticating requests of the given host, including the port if
+// any, with the given username and password. Defaults to
+// http.DefaultTransport if nil.
+func NewProxyAuthTransport(base http.RoundTripper, host, username, password string) *ProxyAuthTransport {
+	if base == nil {
+		base = http.DefaultTransport
+	}
+
+	return &ProxyAuthTransport{
+		base:     base,
+		host:     strings.ToLower(host),
+		username: username,
+		password: password,
+	}
+}
+
+// RoundTrip will make the request using the base transport, with the
+// Proxy-Authorization header set if the request is of the proxy's host.
+// Requests of other hosts, such as token services, are made unmodified so
+// that the credentials are not sent elsewhere. Requests are cloned before
+// setting the header, so the given request is not modified.
+func (t *ProxyAuthTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	if strings.ToLower(req.URL.Host) == t.host {
+		auth := base64.StdEncoding.EncodeToString([]byte(t.username + ":" + t.password))
+
+		req = req.Clone(req.Context())
+		req.Header.Set("Proxy-Authorization", "Basic "+auth)
+	}
+
+	return t.base.RoundTrip(req)
+}
+
+const (
+	// defaultRetryBackoff is the backoff before the first retry of a
+	// RetryTransport, if none is configured.
+	defaultRetryBackoff = time.Millisecond * 200
+
+	// maxRetryBackoff is the maximum backoff between retries.
+	maxRetryBackoff = time.Second * 5
+)
+
+// RetryPredicate returns whether a request which resulted in the given
+// response or error should be retried. The response is nil if the error is
+// not.
+type RetryPredicate func(resp *http.Response, err error) bool
+
+// DefaultRetryPredicate is the built-in RetryPredicate, which retries errors
+// that clienterrors.IsRetryable classifies as retryable, such as transient
+// network errors, and responses of server errors or rate limiting.
+func DefaultRetryPredicate(resp *http.Response, err error) bool {
+	if err != nil {
+		return clienterrors.IsRetryable(err)
+	}
+
+	return resp.StatusCode >= http.StatusInternalServerError ||
+		resp.StatusCode == http.StatusTooManyRequests
+}
+
+// RetryTransport is an http.RoundTripper which retries requests made with the
+// base transport, as decided by its predicate, with an exponential backoff.
+// Requests with a body which cannot be replayed are never retried.
+type RetryTransport struct {
+	base       http.RoundTripper
+	predicate  RetryPredicate
+	maxRetries int
+	backoff    time.Duration
+	sleep      func(ctx context.Context, d time.Duration) error
+}
+
+// NewRetryTransport returns a RetryTransport of the given base transport
+// which retries each request up to the given max retries, waiting the given
+// backoff before the first retry and doubling it for each retry after.
+// Defaults to http.DefaultTransport, DefaultRetryPredicate, and a backoff of
+// 200ms, if nil or zero.
+func NewRetryTransport(base http.RoundTripper, predicate RetryPredicate, maxRetries int, backoff time.Duration) *RetryTransport {
+	if base == nil {
+		base = http.DefaultTransport
+	}
+	if predicate == nil {
+		predicate = DefaultRetryPredicate
+	}
+	if backoff <= 0 {
+		backoff = defaultRetryBackoff
+	}
+
+	return &RetryTransport{
+		base:       base,
+		predicate:  predicate,
+		maxRetries: maxRetries,
+		backoff:    backoff,
+		sleep:      sleepContext,
+	}
+}
+
+// RoundTrip will make the request using the base transport, retrying it
+// whilst the predicate decides so and retries remain. The response or error
+// of the last attempt is returned.
+func (t *RetryTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	backoff := t.backoff
+
+	for attempt := 0; ; attempt++ {
+		resp, err := t.base.RoundTrip(req)
+		if attempt >= t.maxRetries || !t.predicate(resp, err) {
+			return resp, err
+		}
+
+		retryReq, ok := replayRequest(req)
+		if !ok {
+			return resp, err
+		}
+
+		if resp != nil {
+			// Drain the body so that the connection may be reused.
+			io.Copy(ioutil.Discard, resp.Body)
+			resp.Body.Close()
+		}
+
+		if err := t.sleep(req.Context(), backoff); err != nil {
+			return nil, err
+		}
+
+		req = retryReq
+		if backoff *= 2; backoff > maxRetryBackoff {
+			backoff = maxRetryBackoff
+		}
+	}
+}
+
+// replayRequest returns a copy of the given request to be made again, with a
+// new body if it has one. Returns false if the body cannot be replayed.
+func replayRequest(req *http.Request) (*http.Request, bool) {
+	if req.Body == nil || req.Body == http.NoBody {
+		return req, true
+	}
+	if req.GetBody == nil {
+		return nil, false
+	}
+
+	body, err := req.GetBody()
+	if err != nil {
+		return nil, false
+	}
+
+	replay := req.Clone(req.Context())
+	replay.Body = body
+
+	return replay, true
+}
+
+// sleepContext will sleep for the given duration, returning early with the
+// context's error if it is done.
+func sleepContext(ctx context.Context, d time.Duration) error {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+}
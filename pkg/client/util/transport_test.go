@@ -0,0 +1,377 @@
+package util
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"errors"
+	"io/ioutil"
+	"math/big"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	clienterrors "github.com/jetstack/version-checker/pkg/client/errors"
+)
+
+// newTestCAServer returns a TLS server whose certificate is signed by a new
+// CA, along with the PEM encoded CA certificate.
+func newTestCAServer(t *testing.T, name string) (*httptest.Server, []byte) {
+	caKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	caTemplate := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: name + " CA"},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		IsCA:                  true,
+		KeyUsage:              x509.KeyUsageCertSign,
+		BasicConstraintsValid: true,
+	}
+	caDER, err := x509.CreateCertificate(rand.Reader, caTemplate, caTemplate, &caKey.PublicKey, caKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	caCert, err := x509.ParseCertificate(caDER)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	leafKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	leafTemplate := &x509.Certificate{
+		SerialNumber: big.NewInt(2),
+		Subject:      pkix.Name{CommonName: name},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+		IPAddresses:  []net.IP{net.ParseIP("127.0.0.1")},
+	}
+	leafDER, err := x509.CreateCertificate(rand.Reader, leafTemplate, caCert, &leafKey.PublicKey, caKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	server := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(name))
+	}))
+	server.TLS = &tls.Config{
+		Certificates: []tls.Certificate{{
+			Certificate: [][]byte{leafDER},
+			PrivateKey:  leafKey,
+		}},
+	}
+	server.StartTLS()
+
+	return server, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: caDER})
+}
+
+func TestCATransport(t *testing.T) {
+	serverA, caA := newTestCAServer(t, "registry-a")
+	defer serverA.Close()
+	serverB, caB := newTestCAServer(t, "registry-b")
+	defer serverB.Close()
+
+	hostA := strings.TrimPrefix(serverA.URL, "https://")
+	hostB := strings.TrimPrefix(serverB.URL, "https://")
+
+	tests := map[string]struct {
+		bundles map[string][]byte
+		url     string
+		expErr  bool
+	}{
+		"host should trust its own CA": {
+			bundles: map[string][]byte{hostA: caA, hostB: caB},
+			url:     serverA.URL,
+		},
+		"other host should trust its own CA": {
+			bundles: map[string][]byte{hostA: caA, hostB: caB},
+			url:     serverB.URL,
+		},
+		"host should not trust another host's CA": {
+			bundles: map[string][]byte{hostA: caB, hostB: caA},
+			url:     serverA.URL,
+			expErr:  true,
+		},
+		"unconfigured host should use the system pool": {
+			bundles: map[string][]byte{hostA: caA},
+			url:     serverB.URL,
+			expErr:  true,
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			transport, err := NewCATransport(test.bundles)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			resp, err := (&http.Client{Transport: transport}).Get(test.url)
+			if (err != nil) != test.expErr {
+				t.Fatalf("unexpected error, exp=%t got=%v", test.expErr, err)
+			}
+			if err == nil {
+				resp.Body.Close()
+			}
+		})
+	}
+
+	if _, err := NewCATransport(map[string][]byte{hostA: []byte("not a certificate")}); err == nil {
+		t.Error("expected error for bundle without certificates, got none")
+	}
+}
+
+// statusServer returns a server responding with each of the given statuses
+// in turn, then 200, along with the number of requests it received.
+func statusServer(statuses ...int) (*httptest.Server, *int32) {
+	var requests int32
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		i := int(atomic.AddInt32(&requests, 1)) - 1
+		if i < len(statuses) {
+			w.WriteHeader(statuses[i])
+			return
+		}
+
+		w.Write([]byte("ok"))
+	}))
+
+	return server, &requests
+}
+
+func TestRetryTransport(t *testing.T) {
+	const statusEnhanceYourCalm = 420
+
+	retryCalm := func(resp *http.Response, err error) bool {
+		return err == nil && resp.StatusCode == statusEnhanceYourCalm
+	}
+
+	tests := map[string]struct {
+		statuses    []int
+		predicate   RetryPredicate
+		maxRetries  int
+		expStatus   int
+		expRequests int32
+		expBackoffs []time.Duration
+	}{
+		"custom predicate should retry custom status": {
+			statuses:    []int{statusEnhanceYourCalm, statusEnhanceYourCalm},
+			predicate:   retryCalm,
+			maxRetries:  3,
+			expStatus:   http.StatusOK,
+			expRequests: 3,
+			expBackoffs: []time.Duration{time.Millisecond * 100, time.Millisecond * 200},
+		},
+		"custom predicate should not retry other statuses": {
+			statuses:    []int{http.StatusServiceUnavailable},
+			predicate:   retryCalm,
+			maxRetries:  3,
+			expStatus:   http.StatusServiceUnavailable,
+			expRequests: 1,
+		},
+		"default predicate should not retry custom status": {
+			statuses:    []int{statusEnhanceYourCalm},
+			maxRetries:  3,
+			expStatus:   statusEnhanceYourCalm,
+			expRequests: 1,
+		},
+		"default predicate should retry server errors and rate limiting": {
+			statuses:    []int{http.StatusBadGateway, http.StatusTooManyRequests},
+			maxRetries:  3,
+			expStatus:   http.StatusOK,
+			expRequests: 3,
+			expBackoffs: []time.Duration{time.Millisecond * 100, time.Millisecond * 200},
+		},
+		"exhausted retries should return last response": {
+			statuses:    []int{statusEnhanceYourCalm, statusEnhanceYourCalm, statusEnhanceYourCalm},
+			predicate:   retryCalm,
+			maxRetries:  2,
+			expStatus:   statusEnhanceYourCalm,
+			expRequests: 3,
+			expBackoffs: []time.Duration{time.Millisecond * 100, time.Millisecond * 200},
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			server, requests := statusServer(test.statuses...)
+			defer server.Close()
+
+			var backoffs []time.Duration
+			transport := NewRetryTransport(nil, test.predicate, test.maxRetries, time.Millisecond*100)
+			transport.sleep = func(_ context.Context, d time.Duration) error {
+				backoffs = append(backoffs, d)
+				return nil
+			}
+
+			resp, err := (&http.Client{Transport: transport}).Get(server.URL)
+			if err != nil {
+				t.Fatal(err)
+			}
+			resp.Body.Close()
+
+			if resp.StatusCode != test.expStatus {
+				t.Errorf("unexpected status, exp=%d got=%d", test.expStatus, resp.StatusCode)
+			}
+			if got := atomic.LoadInt32(requests); got != test.expRequests {
+				t.Errorf("unexpected requests, exp=%d got=%d", test.expRequests, got)
+			}
+			if !reflect.DeepEqual(backoffs, test.expBackoffs) {
+				t.Errorf("unexpected backoffs, exp=%v got=%v", test.expBackoffs, backoffs)
+			}
+		})
+	}
+}
+
+func TestRetryTransportContextCancelled(t *testing.T) {
+	server, requests := statusServer(http.StatusServiceUnavailable)
+	defer server.Close()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	req, err := http.NewRequest(http.MethodGet, server.URL, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	transport := NewRetryTransport(nil, nil, 3, time.Hour)
+	if _, err := transport.RoundTrip(req.WithContext(ctx)); !errors.Is(err, context.Canceled) {
+		t.Errorf("unexpected error, exp=%v got=%v", context.Canceled, err)
+	}
+	if got := atomic.LoadInt32(requests); got > 1 {
+		t.Errorf("expected no retries after the context is cancelled, got %d requests", got)
+	}
+}
+
+func TestMaxBodyTransport(t *testing.T) {
+	tests := map[string]struct {
+		body          string
+		chunked       bool
+		expBody       string
+		expTooLarge   bool
+		expReadFailed bool
+	}{
+		"body under the maximum should be read": {
+			body:    "1234",
+			expBody: "1234",
+		},
+		"body of the maximum should be read": {
+			body:    "12345678",
+			expBody: "12345678",
+		},
+		"content length over the maximum should fail the request": {
+			body:        "123456789",
+			expTooLarge: true,
+		},
+		"chunked body under the maximum should be read": {
+			body:    "1234",
+			chunked: true,
+			expBody: "1234",
+		},
+		"chunked body over the maximum should fail reading": {
+			body:          strings.Repeat("1234", 10),
+			chunked:       true,
+			expReadFailed: true,
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				if test.chunked {
+					w.(http.Flusher).Flush()
+				}
+				w.Write([]byte(test.body))
+			}))
+			defer server.Close()
+
+			transport := NewMaxBodyTransport(nil, 8)
+			resp, err := (&http.Client{Transport: transport}).Get(server.URL)
+			if clienterrors.IsResponseTooLarge(err) != test.expTooLarge {
+				t.Fatalf("unexpected response too large error, exp=%t got=%v", test.expTooLarge, err)
+			}
+			if test.expTooLarge {
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer resp.Body.Close()
+
+			body, err := ioutil.ReadAll(resp.Body)
+			if clienterrors.IsResponseTooLarge(err) != test.expReadFailed {
+				t.Fatalf("unexpected response too large error, exp=%t got=%v", test.expReadFailed, err)
+			}
+			if test.expReadFailed {
+				if len(body) != 8 {
+					t.Errorf("unexpected body read, exp=8 bytes got=%d", len(body))
+				}
+				return
+			}
+
+			if string(body) != test.expBody {
+				t.Errorf("unexpected body, exp=%q got=%q", test.expBody, body)
+			}
+		})
+	}
+}
+
+func TestTimeoutTransport(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/slow" {
+			select {
+			case <-r.Context().Done():
+			case <-time.After(time.Second * 5):
+			}
+		}
+		w.Write([]byte("ok"))
+	}))
+	defer server.Close()
+
+	transport := NewTimeoutTransport(nil, func(req *http.Request) time.Duration {
+		if req.URL.Path == "/slow" {
+			return time.Millisecond * 50
+		}
+		return time.Second * 5
+	})
+	client := &http.Client{Transport: transport}
+
+	// Requests within their timeout should succeed, and be readable.
+	resp, err := client.Get(server.URL + "/fast")
+	if err != nil {
+		t.Fatal(err)
+	}
+	body, err := ioutil.ReadAll(resp.Body)
+	resp.Body.Close()
+	if err != nil || string(body) != "ok" {
+		t.Errorf("unexpected body, exp=%q got=%q (%v)", "ok", body, err)
+	}
+
+	// Requests exceeding their timeout should fail.
+	start := time.Now()
+	if _, err := client.Get(server.URL + "/slow"); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("unexpected error, exp=%v got=%v", context.DeadlineExceeded, err)
+	}
+	if elapsed := time.Since(start); elapsed > time.Second*2 {
+		t.Errorf("expected request to be cancelled at its timeout, took %s", elapsed)
+	}
+}
@@ -1,5 +1,10 @@
 package util
 
+import (
+	"net"
+	"strings"
+)
+
 func JoinRepoImage(repo, image string) string {
 	if len(repo) == 0 {
 		return image
@@ -10,3 +15,28 @@ func JoinRepoImage(repo, image string) string {
 
 	return repo + "/" + image
 }
+
+// PageSize returns the requested page size, or the default page size if not
+// set, capped at the maximum page size of the registry.
+func PageSize(requested, def, max int) int {
+	if requested <= 0 {
+		requested = def
+	}
+	if requested > max {
+		return max
+	}
+
+	return requested
+}
+
+// NormalizeHost returns the given registry host in lower case and without a
+// port, so that it may be matched against a registry's well known hosts.
+// e.g. Quay.IO -> quay.io, gcr.io:443 -> gcr.io
+func NormalizeHost(host string) string {
+	host = strings.ToLower(host)
+	if hostname, _, err := net.SplitHostPort(host); err == nil {
+		return hostname
+	}
+
+	return host
+}
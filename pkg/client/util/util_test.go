@@ -45,3 +45,71 @@ func TestJoinRepoImage(t *testing.T) {
 		})
 	}
 }
+
+func TestPageSize(t *testing.T) {
+	tests := map[string]struct {
+		requested   int
+		expPageSize int
+	}{
+		"unset should use the default": {
+			requested:   0,
+			expPageSize: 50,
+		},
+		"within the maximum should be used": {
+			requested:   80,
+			expPageSize: 80,
+		},
+		"above the maximum should be capped": {
+			requested:   500,
+			expPageSize: 100,
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			if pageSize := PageSize(test.requested, 50, 100); pageSize != test.expPageSize {
+				t.Errorf("unexpected page size, exp=%d got=%d", test.expPageSize, pageSize)
+			}
+		})
+	}
+}
+
+func TestNormalizeHost(t *testing.T) {
+	tests := map[string]struct {
+		host    string
+		expHost string
+	}{
+		"empty host should be empty": {
+			host:    "",
+			expHost: "",
+		},
+		"lower case host should be unchanged": {
+			host:    "quay.io",
+			expHost: "quay.io",
+		},
+		"mixed case host should be lower case": {
+			host:    "Quay.IO",
+			expHost: "quay.io",
+		},
+		"port should be removed": {
+			host:    "gcr.io:443",
+			expHost: "gcr.io",
+		},
+		"mixed case host with port should be lower case without port": {
+			host:    "Docker.IO:5000",
+			expHost: "docker.io",
+		},
+		"IPv6 host with port should be removed of brackets and port": {
+			host:    "[::1]:5000",
+			expHost: "::1",
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			if host := NormalizeHost(test.host); host != test.expHost {
+				t.Errorf("unexpected host, exp=%q got=%q", test.expHost, host)
+			}
+		})
+	}
+}
@@ -67,7 +67,7 @@ func (c *Checker) Container(ctx context.Context, log *logrus.Entry,
 		return result, nil
 	}
 
-	currentImage := semver.Parse(currentTag)
+	currentImage := parseSemver(currentTag, opts)
 	latestImage, isLatest, err := c.isLatestSemver(ctx, imageURL, statusSHA, currentImage, opts)
 	if err != nil {
 		return nil, err
@@ -124,7 +124,7 @@ func (c *Checker) isLatestSemver(ctx context.Context, imageURL, currentSHA strin
 		return nil, false, err
 	}
 
-	latestImageV := semver.Parse(latestImage.Tag)
+	latestImageV := parseSemver(latestImage.Tag, opts)
 
 	var isLatest bool
 
@@ -143,6 +143,38 @@ func (c *Checker) isLatestSemver(ctx context.Context, imageURL, currentSHA strin
 	return latestImage, isLatest, nil
 }
 
+// UpgradeAvailable will return the latest image of the given image URL, and
+// whether it is a newer version than the given current version. The current
+// version is sanitized the same as upstream tags. Returns an error if the
+// current version is not a valid version, or when comparing by SHA.
+func (c *Checker) UpgradeAvailable(ctx context.Context, imageURL, currentVersion string, opts *api.Options) (*api.ImageTag, bool, error) {
+	if opts != nil && opts.UseSHA {
+		return nil, false, fmt.Errorf("%s: cannot compare versions when using sha", imageURL)
+	}
+
+	currentImage := parseSemver(currentVersion, opts)
+	if !currentImage.IsValid() {
+		return nil, false, fmt.Errorf("%s: current version %q is not a valid version", imageURL, currentVersion)
+	}
+
+	latestImage, err := c.search.LatestImage(ctx, imageURL, opts)
+	if err != nil {
+		return nil, false, err
+	}
+
+	return latestImage, currentImage.LessThan(parseSemver(latestImage.Tag, opts)), nil
+}
+
+// parseSemver will parse the given tag, sanitizing it with the rule of the
+// given options, if set
+func parseSemver(tag string, opts *api.Options) *semver.SemVer {
+	if opts == nil {
+		return semver.Parse(tag)
+	}
+
+	return semver.Parse(semver.Sanitize(tag, semver.SanitizeRule(opts.SanitizeRule)))
+}
+
 // isLatestSHA will return the the result of whether the given image is the latest, according to image SHA
 func (c *Checker) isLatestSHA(ctx context.Context, imageURL, currentSHA string, opts *api.Options) (*Result, error) {
 	latestImage, err := c.search.LatestImage(ctx, imageURL, opts)
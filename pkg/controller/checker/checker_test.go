@@ -478,6 +478,72 @@ func TestIsLatestSemver(t *testing.T) {
 	}
 }
 
+func TestUpgradeAvailable(t *testing.T) {
+	tests := map[string]struct {
+		currentVersion string
+		opts           *api.Options
+		searchResp     *api.ImageTag
+		expAvailable   bool
+		expErr         bool
+	}{
+		"if current version is latest, then no upgrade": {
+			currentVersion: "v1.2.4",
+			searchResp:     &api.ImageTag{Tag: "v1.2.4", SHA: "456"},
+			expAvailable:   false,
+		},
+		"if current version is newer than latest, then no upgrade": {
+			currentVersion: "v1.2.5",
+			searchResp:     &api.ImageTag{Tag: "v1.2.4", SHA: "456"},
+			expAvailable:   false,
+		},
+		"if current version is behind latest, then upgrade": {
+			currentVersion: "v1.2.3",
+			searchResp:     &api.ImageTag{Tag: "v1.2.4", SHA: "456"},
+			expAvailable:   true,
+		},
+		"current version should be sanitized": {
+			currentVersion: "1_2_3",
+			opts:           &api.Options{SanitizeRule: string(semver.SanitizeBuild)},
+			searchResp:     &api.ImageTag{Tag: "1.2.4", SHA: "456"},
+			expAvailable:   true,
+		},
+		"invalid current version should error": {
+			currentVersion: "latest",
+			searchResp:     &api.ImageTag{Tag: "v1.2.4", SHA: "456"},
+			expErr:         true,
+		},
+		"using sha should error": {
+			currentVersion: "v1.2.3",
+			opts:           &api.Options{UseSHA: true},
+			searchResp:     &api.ImageTag{Tag: "v1.2.4", SHA: "456"},
+			expErr:         true,
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			checker := New(search.New().With(test.searchResp, nil))
+			latestImage, available, err := checker.UpgradeAvailable(context.TODO(), "docker.io", test.currentVersion, test.opts)
+			if (err != nil) != test.expErr {
+				t.Fatalf("unexpected error, exp=%t got=%v", test.expErr, err)
+			}
+			if test.expErr {
+				return
+			}
+
+			if !reflect.DeepEqual(latestImage, test.searchResp) {
+				t.Errorf("got unexpected latest image, exp=%v got=%v",
+					test.searchResp, latestImage)
+			}
+
+			if available != test.expAvailable {
+				t.Errorf("got unexpected upgrade available, exp=%t got=%t",
+					test.expAvailable, available)
+			}
+		})
+	}
+}
+
 func TestIsLatestSHA(t *testing.T) {
 	tests := map[string]struct {
 		imageURL, currentSHA string
@@ -51,12 +51,13 @@ func New(
 	kubeClient kubernetes.Interface,
 	log *logrus.Entry,
 	defaultTestAll bool,
+	versionOpts version.Options,
 ) *Controller {
 	workqueue := workqueue.NewRateLimitingQueue(workqueue.DefaultControllerRateLimiter())
 	scheduledWorkQueue := scheduler.NewScheduledWorkQueue(clock.RealClock{}, workqueue.Add)
 
 	log = log.WithField("module", "controller")
-	versionGetter := version.New(log, imageClient, cacheTimeout)
+	versionGetter := version.New(log, imageClient, cacheTimeout, versionOpts)
 	search := search.New(log, cacheTimeout, versionGetter)
 
 	c := &Controller{
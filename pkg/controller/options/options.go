@@ -8,6 +8,7 @@ import (
 	"strings"
 
 	"github.com/jetstack/version-checker/pkg/api"
+	"github.com/jetstack/version-checker/pkg/version/semver"
 )
 
 // Builder is a struct for building container search options
@@ -93,6 +94,11 @@ func (b *Builder) Options(name string) (*api.Options, error) {
 				b.index(name, api.PinMajorAnnotationKey)))
 		} else {
 
+			if strings.HasPrefix(pinPatch, ">=") {
+				opts.PinPatchMinimum = true
+				pinPatch = strings.TrimSpace(strings.TrimPrefix(pinPatch, ">="))
+			}
+
 			pa, err := strconv.ParseInt(pinPatch, 10, 64)
 			if err != nil {
 				errs = append(errs, fmt.Sprintf("failed to parse %s: %s",
@@ -103,10 +109,66 @@ func (b *Builder) Options(name string) (*api.Options, error) {
 		}
 	}
 
+	if sanitize, ok := b.ans[b.index(name, api.SanitizeAnnotationKey)]; ok {
+		setNonSha = true
+
+		rule, err := semver.ParseSanitizeRule(sanitize)
+		if err != nil {
+			errs = append(errs, fmt.Sprintf("failed to parse %s: %s",
+				b.index(name, api.SanitizeAnnotationKey), err))
+		} else {
+			opts.SanitizeRule = string(rule)
+		}
+	}
+
+	if requireSignature, ok := b.ans[b.index(name, api.RequireSignatureAnnotationKey)]; ok && requireSignature == "true" {
+		opts.RequireSignature = true
+	}
+
+	if requireLabels, ok := b.ans[b.index(name, api.RequireConfigLabelsAnnotationKey)]; ok {
+		opts.RequireConfigLabels = make(map[string]string)
+		for _, label := range strings.Split(requireLabels, ",") {
+			split := strings.SplitN(strings.TrimSpace(label), "=", 2)
+			if len(split) != 2 || len(split[0]) == 0 {
+				errs = append(errs, fmt.Sprintf("failed to parse %s: invalid label %q, expected key=value",
+					b.index(name, api.RequireConfigLabelsAnnotationKey), label))
+				continue
+			}
+			opts.RequireConfigLabels[split[0]] = split[1]
+		}
+	}
+
+	if mediaTypes, ok := b.ans[b.index(name, api.RequireManifestMediaTypesAnnotationKey)]; ok {
+		for _, mediaType := range strings.Split(mediaTypes, ",") {
+			switch mediaType = strings.TrimSpace(mediaType); mediaType {
+			case "":
+				errs = append(errs, fmt.Sprintf("failed to parse %s: empty media type",
+					b.index(name, api.RequireManifestMediaTypesAnnotationKey)))
+			case "oci":
+				opts.RequireManifestMediaTypes = append(opts.RequireManifestMediaTypes,
+					api.OCIImageIndexMediaType, api.OCIImageManifestMediaType)
+			default:
+				opts.RequireManifestMediaTypes = append(opts.RequireManifestMediaTypes, mediaType)
+			}
+		}
+	}
+
+	if useConfigTimestamp, ok := b.ans[b.index(name, api.UseConfigTimestampAnnotationKey)]; ok && useConfigTimestamp == "true" {
+		opts.UseConfigTimestamp = true
+	}
+
+	if requireTimestamp, ok := b.ans[b.index(name, api.RequireTimestampAnnotationKey)]; ok && requireTimestamp == "true" {
+		opts.RequireTimestamp = true
+	}
+
 	if overrideURL, ok := b.ans[b.index(name, api.OverrideURLAnnotationKey)]; ok {
 		opts.OverrideURL = &overrideURL
 	}
 
+	if promotionURL, ok := b.ans[b.index(name, api.PromotionURLAnnotationKey)]; ok {
+		opts.PromotionURL = &promotionURL
+	}
+
 	if opts.UseSHA && setNonSha {
 		errs = append(errs, fmt.Sprintf("cannot define %q with any semver otions",
 			b.index(name, api.UseSHAAnnotationKey)))
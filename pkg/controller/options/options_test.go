@@ -101,6 +101,41 @@ func TestBuild(t *testing.T) {
 			},
 			expErr: "",
 		},
+		"output options for promotion url": {
+			containerName: "test-name",
+			annotations: map[string]string{
+				api.PromotionURLAnnotationKey + "/test-name": "prod.example.com/foo",
+			},
+			expOptions: &api.Options{
+				PromotionURL: stringp("prod.example.com/foo"),
+			},
+			expErr: "",
+		},
+		"output options for minimum patch pin": {
+			containerName: "test-name",
+			annotations: map[string]string{
+				api.PinMajorAnnotationKey + "/test-name": "1",
+				api.PinMinorAnnotationKey + "/test-name": "2",
+				api.PinPatchAnnotationKey + "/test-name": ">=3",
+			},
+			expOptions: &api.Options{
+				PinMajor:        int64p(1.0),
+				PinMinor:        int64p(2.0),
+				PinPatch:        int64p(3.0),
+				PinPatchMinimum: true,
+			},
+			expErr: "",
+		},
+		"output options for sanitize": {
+			containerName: "test-name",
+			annotations: map[string]string{
+				api.SanitizeAnnotationKey + "/test-name": "build",
+			},
+			expOptions: &api.Options{
+				SanitizeRule: "build",
+			},
+			expErr: "",
+		},
 		"output options for sha": {
 			containerName: "test-name",
 			annotations: map[string]string{
@@ -111,6 +146,72 @@ func TestBuild(t *testing.T) {
 			},
 			expErr: "",
 		},
+		"output options for required signature with sha": {
+			containerName: "test-name",
+			annotations: map[string]string{
+				api.UseSHAAnnotationKey + "/test-name":           "true",
+				api.RequireSignatureAnnotationKey + "/test-name": "true",
+			},
+			expOptions: &api.Options{
+				UseSHA:           true,
+				RequireSignature: true,
+			},
+			expErr: "",
+		},
+		"output options for config timestamps with sha": {
+			containerName: "test-name",
+			annotations: map[string]string{
+				api.UseSHAAnnotationKey + "/test-name":             "true",
+				api.UseConfigTimestampAnnotationKey + "/test-name": "true",
+				api.RequireTimestampAnnotationKey + "/test-name":   "true",
+			},
+			expOptions: &api.Options{
+				UseSHA:             true,
+				UseConfigTimestamp: true,
+				RequireTimestamp:   true,
+			},
+			expErr: "",
+		},
+		"should parse required config labels": {
+			containerName: "test-name",
+			annotations: map[string]string{
+				api.RequireConfigLabelsAnnotationKey + "/test-name": "quality=ga, team=platform",
+			},
+			expOptions: &api.Options{
+				RequireConfigLabels: map[string]string{"quality": "ga", "team": "platform"},
+			},
+			expErr: "",
+		},
+		"should not parse required config labels without a value": {
+			containerName: "test-name",
+			annotations: map[string]string{
+				api.RequireConfigLabelsAnnotationKey + "/test-name": "quality",
+			},
+			expOptions: nil,
+			expErr:     `failed to parse require-config-labels.version-checker.io/test-name: invalid label "quality", expected key=value`,
+		},
+		"should parse required manifest media types, expanding oci": {
+			containerName: "test-name",
+			annotations: map[string]string{
+				api.RequireManifestMediaTypesAnnotationKey + "/test-name": "oci, application/vnd.docker.distribution.manifest.list.v2+json",
+			},
+			expOptions: &api.Options{
+				RequireManifestMediaTypes: []string{
+					api.OCIImageIndexMediaType,
+					api.OCIImageManifestMediaType,
+					"application/vnd.docker.distribution.manifest.list.v2+json",
+				},
+			},
+			expErr: "",
+		},
+		"should not parse empty required manifest media types": {
+			containerName: "test-name",
+			annotations: map[string]string{
+				api.RequireManifestMediaTypesAnnotationKey + "/test-name": "oci,",
+			},
+			expOptions: nil,
+			expErr:     `failed to parse require-manifest-media-types.version-checker.io/test-name: empty media type`,
+		},
 		"bool options that don't have 'true' and nothing": {
 			containerName: "test-name",
 			annotations: map[string]string{
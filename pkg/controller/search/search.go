@@ -2,9 +2,6 @@ package search
 
 import (
 	"context"
-	"encoding/json"
-	"fmt"
-	"hash/fnv"
 	"time"
 
 	"github.com/sirupsen/logrus"
@@ -35,7 +32,7 @@ func New(log *logrus.Entry, cacheTimeout time.Duration, versionGetter *version.V
 		versionGetter: versionGetter,
 	}
 
-	s.searchCache = cache.New(s.log, cacheTimeout, s)
+	s.searchCache = cache.New(s.log, cacheTimeout, s, cache.Options{})
 
 	return s
 }
@@ -51,9 +48,10 @@ func (s *Search) Fetch(ctx context.Context, imageURL string, opts *api.Options)
 
 // LatestImage will get the latestImage image given an image URL and
 // options. If not found in the cache, or is too old, then will do a fresh
-// lookup and commit to the cache.
+// lookup and commit to the cache. Searches are cached per identity of the
+// registry credentials carried by the context.
 func (s *Search) LatestImage(ctx context.Context, imageURL string, opts *api.Options) (*api.ImageTag, error) {
-	hashIndex, err := calculateHashIndex(imageURL, opts)
+	hashIndex, err := version.CalculateHashIndex(version.ScopedImageIndex(ctx, imageURL), opts)
 	if err != nil {
 		return nil, err
 	}
@@ -71,18 +69,3 @@ func (s *Search) Run(refreshRate time.Duration) {
 	go s.versionGetter.Run(refreshRate)
 	s.searchCache.StartGarbageCollector(refreshRate)
 }
-
-// calculateHashIndex returns a hash index given an imageURL and options.
-func calculateHashIndex(imageURL string, opts *api.Options) (string, error) {
-	optsJSON, err := json.Marshal(opts)
-	if err != nil {
-		return "", fmt.Errorf("failed to marshal options: %s", err)
-	}
-
-	hash := fnv.New32()
-	if _, err := hash.Write(append(optsJSON, []byte(imageURL)...)); err != nil {
-		return "", fmt.Errorf("failed to calculate search hash: %s", err)
-	}
-
-	return fmt.Sprintf("%d", hash.Sum32()), nil
-}
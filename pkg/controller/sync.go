@@ -9,6 +9,7 @@ import (
 	corev1 "k8s.io/api/core/v1"
 
 	"github.com/jetstack/version-checker/pkg/api"
+	clienterrors "github.com/jetstack/version-checker/pkg/client/errors"
 	"github.com/jetstack/version-checker/pkg/controller/options"
 	versionerrors "github.com/jetstack/version-checker/pkg/version/errors"
 )
@@ -58,6 +59,12 @@ func (c *Controller) syncContainer(ctx context.Context, log *logrus.Entry, build
 		log.Error(err.Error())
 		return nil
 	}
+	// Don't re-sync early, if the registry failed in a way that retrying
+	// won't resolve, such as its host not existing
+	if clienterrors.IsNetwork(err) && !clienterrors.IsRetryable(err) {
+		log.Error(err.Error())
+		return nil
+	}
 	if err != nil {
 		return fmt.Errorf("failed to check container image %q: %s",
 			container.Name, err)
@@ -92,5 +99,29 @@ func (c *Controller) checkContainer(ctx context.Context, log *logrus.Entry, pod
 		container.Name, result.ImageURL, result.IsLatest,
 		result.CurrentVersion, result.LatestVersion)
 
+	// The container is only not latest when the latest version is newer than
+	// the current, or the same version has been re-pushed upstream, so an
+	// upgrade is available.
+	c.metrics.SetUpgradeAvailable(result.ImageURL, !result.IsLatest, result.LatestVersion)
+
 	return nil
 }
+
+// CheckUpgradeAvailable will resolve the latest version of the given image
+// URL, compared to the given current version, and set the image's upgrade
+// available metric according to the result. Returns whether an upgrade is
+// available.
+func (c *Controller) CheckUpgradeAvailable(ctx context.Context, imageURL, currentVersion string, opts *api.Options) (bool, error) {
+	latestImage, available, err := c.checker.UpgradeAvailable(ctx, imageURL, currentVersion, opts)
+	if err != nil {
+		return false, err
+	}
+
+	if available {
+		c.log.Debugf("upgrade available %s: %s -> %s", imageURL, currentVersion, latestImage.Tag)
+	}
+
+	c.metrics.SetUpgradeAvailable(imageURL, available, latestImage.Tag)
+
+	return available, nil
+}
@@ -0,0 +1,111 @@
+package controller
+
+import (
+	"context"
+	"reflect"
+	"testing"
+
+	"github.com/sirupsen/logrus"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	"github.com/jetstack/version-checker/pkg/api"
+	"github.com/jetstack/version-checker/pkg/controller/checker"
+	"github.com/jetstack/version-checker/pkg/controller/internal/fake/search"
+	"github.com/jetstack/version-checker/pkg/metrics"
+)
+
+// fakeBackend is a metrics Backend recording the upgrade available gauges
+// set, by image and available version.
+type fakeBackend struct {
+	upgrades map[string]float64
+}
+
+func (f *fakeBackend) AddCounter(string, map[string]string, float64)       {}
+func (f *fakeBackend) ObserveHistogram(string, map[string]string, float64) {}
+
+func (f *fakeBackend) SetGauge(name string, labels map[string]string, value float64) {
+	if name == metrics.UpgradeAvailableName {
+		f.upgrades[labels["image"]+":"+labels["available_version"]] = value
+	}
+}
+
+func (f *fakeBackend) DeleteGauge(name string, labels map[string]string) {
+	if name == metrics.UpgradeAvailableName {
+		delete(f.upgrades, labels["image"]+":"+labels["available_version"])
+	}
+}
+
+func TestSyncUpgradeAvailable(t *testing.T) {
+	tests := map[string]struct {
+		image       string
+		searchResp  *api.ImageTag
+		expUpgrades map[string]float64
+	}{
+		"if current version is latest, then no upgrade": {
+			image:      "localhost:5000/version-checker:v0.2.0",
+			searchResp: &api.ImageTag{Tag: "v0.2.0", SHA: "sha:123"},
+			expUpgrades: map[string]float64{
+				"localhost:5000/version-checker:v0.2.0": 0,
+			},
+		},
+		"if current version is newer than latest, then no upgrade": {
+			image:      "localhost:5000/version-checker:v0.3.0",
+			searchResp: &api.ImageTag{Tag: "v0.2.0", SHA: "sha:456"},
+			expUpgrades: map[string]float64{
+				"localhost:5000/version-checker:v0.2.0": 0,
+			},
+		},
+		"if current version is behind latest, then upgrade": {
+			image:      "localhost:5000/version-checker:v0.1.0",
+			searchResp: &api.ImageTag{Tag: "v0.2.0", SHA: "sha:456"},
+			expUpgrades: map[string]float64{
+				"localhost:5000/version-checker:v0.2.0": 1,
+			},
+		},
+		"if current version is latest, but different sha, then upgrade": {
+			image:      "localhost:5000/version-checker:v0.2.0",
+			searchResp: &api.ImageTag{Tag: "v0.2.0", SHA: "sha:456"},
+			expUpgrades: map[string]float64{
+				"localhost:5000/version-checker:v0.2.0@sha:456": 1,
+			},
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			backend := &fakeBackend{upgrades: make(map[string]float64)}
+			log := logrus.NewEntry(logrus.New())
+
+			c := &Controller{
+				log:            log,
+				metrics:        metrics.New(log, backend),
+				checker:        checker.New(search.New().With(test.searchResp, nil)),
+				defaultTestAll: true,
+			}
+
+			pod := &corev1.Pod{
+				ObjectMeta: metav1.ObjectMeta{Name: "test", Namespace: "default"},
+				Spec: corev1.PodSpec{
+					Containers: []corev1.Container{
+						{Name: "version-checker", Image: test.image},
+					},
+				},
+				Status: corev1.PodStatus{
+					ContainerStatuses: []corev1.ContainerStatus{
+						{Name: "version-checker", ImageID: "localhost:5000/version-checker@sha:123"},
+					},
+				},
+			}
+
+			if err := c.sync(context.TODO(), pod); err != nil {
+				t.Fatalf("unexpected error: %s", err)
+			}
+
+			if !reflect.DeepEqual(backend.upgrades, test.expUpgrades) {
+				t.Errorf("unexpected upgrade available gauges, exp=%v got=%v",
+					test.expUpgrades, backend.upgrades)
+			}
+		})
+	}
+}
@@ -0,0 +1,100 @@
+package metrics
+
+// Namespace is the namespace, or prefix, of the names of all instruments.
+const Namespace = "version_checker"
+
+// The names of the instruments recorded by Metrics, without the namespace.
+const (
+	IsLatestVersionName      = "is_latest_version"
+	UpgradeAvailableName     = "upgrade_available"
+	ImageCacheAgeSecondsName = "image_cache_age_seconds"
+	RegistryErrorsName       = "registry_error_responses_total"
+	ImageCacheStampedesName  = "image_cache_stampedes_total"
+)
+
+// InstrumentKind is the kind of an instrument.
+type InstrumentKind int
+
+const (
+	// Counter is a monotonically increasing value.
+	Counter InstrumentKind = iota
+	// Histogram is a distribution of observed values.
+	Histogram
+	// Gauge is a value which may be set, and removed.
+	Gauge
+)
+
+// Instrument describes an instrument recorded by Metrics, so that backends
+// may register it before use.
+type Instrument struct {
+	Name   string
+	Kind   InstrumentKind
+	Help   string
+	Labels []string
+
+	// Buckets are the bucket upper bounds of histograms, where supported by
+	// the backend.
+	Buckets []float64
+}
+
+// Instruments are all the instruments recorded by Metrics.
+var Instruments = []Instrument{
+	{
+		Name:   IsLatestVersionName,
+		Kind:   Gauge,
+		Help:   "Where the container in use is using the latest upstream registry version",
+		Labels: []string{"namespace", "pod", "container", "image", "current_version", "latest_version"},
+	},
+	{
+		Name:   UpgradeAvailableName,
+		Kind:   Gauge,
+		Help:   "Whether a newer upstream registry version of the image is available than the current version",
+		Labels: []string{"image", "available_version"},
+	},
+	{
+		Name:    ImageCacheAgeSecondsName,
+		Kind:    Histogram,
+		Help:    "The age of image tags when served from the image cache, rather than fetched from the upstream registry",
+		Labels:  []string{"registry"},
+		Buckets: []float64{1, 5, 15, 30, 60, 120, 300, 600, 1800, 3600, 7200},
+	},
+	{
+		Name:   RegistryErrorsName,
+		Kind:   Counter,
+		Help:   "The number of error responses from upstream registries, by host and status code",
+		Labels: []string{"host", "status_code"},
+	},
+	{
+		Name:   ImageCacheStampedesName,
+		Kind:   Counter,
+		Help:   "The number of fetches of image tags started whilst a fetch of the same image was already in flight",
+		Labels: []string{"registry"},
+	},
+}
+
+// Backend records the instruments of Metrics, such as to Prometheus or
+// OpenTelemetry. Instruments are referenced by the name of one of the
+// Instruments, where unknown names are ignored. Labels are always those of the
+// instrument. A Backend which also implements http.Handler is served by Run.
+type Backend interface {
+	// AddCounter adds the given value to the counter of the given labels.
+	AddCounter(name string, labels map[string]string, value float64)
+
+	// ObserveHistogram records the given value in the histogram of the given
+	// labels.
+	ObserveHistogram(name string, labels map[string]string, value float64)
+
+	// SetGauge sets the gauge of the given labels to the given value.
+	SetGauge(name string, labels map[string]string, value float64)
+
+	// DeleteGauge removes the gauge of the given labels, if set.
+	DeleteGauge(name string, labels map[string]string)
+}
+
+// noopBackend is a Backend which records nothing.
+type noopBackend struct{}
+
+func (noopBackend) AddCounter(string, map[string]string, float64)       {}
+func (noopBackend) ObserveHistogram(string, map[string]string, float64) {}
+func (noopBackend) SetGauge(string, map[string]string, float64)         {}
+func (noopBackend) DeleteGauge(string, map[string]string)               {}
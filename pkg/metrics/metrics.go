@@ -5,29 +5,30 @@ import (
 	"fmt"
 	"net"
 	"net/http"
+	"strconv"
 	"strings"
 	"sync"
 	"time"
 
 	"github.com/sirupsen/logrus"
-
-	"github.com/prometheus/client_golang/prometheus"
-	"github.com/prometheus/client_golang/prometheus/promhttp"
 )
 
-// Metrics is used to expose container image version checks as prometheus
-// metrics.
+// Metrics is used to expose container image version checks as metrics,
+// recorded by a Backend.
 type Metrics struct {
 	*http.Server
 
-	registry              *prometheus.Registry
-	containerImageVersion *prometheus.GaugeVec
-	log                   *logrus.Entry
+	backend Backend
+	log     *logrus.Entry
 
 	// container cache stores a cache of a container's current image, version,
 	// and the latest
 	containerCache map[string]cacheItem
-	mu             sync.Mutex
+
+	// upgrade cache stores the available version of each image with an
+	// upgrade available gauge
+	upgradeCache map[string]string
+	mu           sync.Mutex
 }
 
 type cacheItem struct {
@@ -36,33 +37,33 @@ type cacheItem struct {
 	latestVersion  string
 }
 
-func New(log *logrus.Entry) *Metrics {
-	containerImageVersion := prometheus.NewGaugeVec(
-		prometheus.GaugeOpts{
-			Namespace: "version_checker",
-			Name:      "is_latest_version",
-			Help:      "Where the container in use is using the latest upstream registry version",
-		},
-		[]string{
-			"namespace", "pod", "container", "image", "current_version", "latest_version",
-		},
-	)
-
-	registry := prometheus.NewRegistry()
-	registry.MustRegister(containerImageVersion)
+// New returns Metrics recorded by the given backend, such as that of the
+// prometheus package. Metrics are not recorded if the backend is nil.
+func New(log *logrus.Entry, backend Backend) *Metrics {
+	if backend == nil {
+		backend = noopBackend{}
+	}
 
 	return &Metrics{
-		log:                   log.WithField("module", "metrics"),
-		registry:              registry,
-		containerImageVersion: containerImageVersion,
-		containerCache:        make(map[string]cacheItem),
+		log:            log.WithField("module", "metrics"),
+		backend:        backend,
+		containerCache: make(map[string]cacheItem),
+		upgradeCache:   make(map[string]string),
 	}
 }
 
-// Run will run the metrics server
+// Run will run the metrics server. No server is run if the backend does not
+// serve its metrics over HTTP, such as the noop backend, or a backend
+// exporting its metrics itself.
 func (m *Metrics) Run(servingAddress string) error {
+	handler, ok := m.backend.(http.Handler)
+	if !ok {
+		m.log.Debug("metrics backend does not serve metrics over http, not serving /metrics")
+		return nil
+	}
+
 	router := http.NewServeMux()
-	router.Handle("/metrics", promhttp.HandlerFor(m.registry, promhttp.HandlerOpts{}))
+	router.Handle("/metrics", handler)
 
 	ln, err := net.Listen("tcp", servingAddress)
 	if err != nil {
@@ -81,7 +82,7 @@ func (m *Metrics) Run(servingAddress string) error {
 		m.log.Infof("serving metrics on %s/metrics", ln.Addr())
 
 		if err := m.Serve(ln); err != nil {
-			m.log.Errorf("failed to serve prometheus metrics: %s", err)
+			m.log.Errorf("failed to serve metrics: %s", err)
 			return
 		}
 	}()
@@ -101,9 +102,10 @@ func (m *Metrics) AddImage(namespace, pod, container, imageURL string, isLatest
 		isLatestF = 1.0
 	}
 
-	m.containerImageVersion.With(
+	m.backend.SetGauge(IsLatestVersionName,
 		m.buildLabels(namespace, pod, container, imageURL, currentVersion, latestVersion),
-	).Set(isLatestF)
+		isLatestF,
+	)
 
 	index := m.latestImageIndex(namespace, pod, container)
 	m.containerCache[index] = cacheItem{
@@ -123,18 +125,83 @@ func (m *Metrics) RemoveImage(namespace, pod, container string) {
 		return
 	}
 
-	m.containerImageVersion.Delete(
+	m.backend.DeleteGauge(IsLatestVersionName,
 		m.buildLabels(namespace, pod, container, item.image, item.currentVersion, item.latestVersion),
 	)
 	delete(m.containerCache, index)
 }
 
+// SetUpgradeAvailable will set the upgrade available gauge of the given image
+// to 1 if an upgrade is available, else 0, labelled with the latest available
+// version. Any gauge previously set for the image is replaced.
+func (m *Metrics) SetUpgradeAvailable(imageURL string, available bool, availableVersion string) {
+	m.RemoveUpgradeAvailable(imageURL)
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	availableF := 0.0
+	if available {
+		availableF = 1.0
+	}
+
+	m.backend.SetGauge(UpgradeAvailableName, map[string]string{
+		"image":             imageURL,
+		"available_version": availableVersion,
+	}, availableF)
+
+	m.upgradeCache[imageURL] = availableVersion
+}
+
+// RemoveUpgradeAvailable will remove the upgrade available gauge of the given
+// image, if set.
+func (m *Metrics) RemoveUpgradeAvailable(imageURL string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	availableVersion, ok := m.upgradeCache[imageURL]
+	if !ok {
+		return
+	}
+
+	m.backend.DeleteGauge(UpgradeAvailableName, map[string]string{
+		"image":             imageURL,
+		"available_version": availableVersion,
+	})
+	delete(m.upgradeCache, imageURL)
+}
+
+// ObserveImageCacheAge will record the age of image tags of the given registry
+// served from the image cache.
+func (m *Metrics) ObserveImageCacheAge(registry string, age time.Duration) {
+	m.backend.ObserveHistogram(ImageCacheAgeSecondsName, map[string]string{
+		"registry": registry,
+	}, age.Seconds())
+}
+
+// IncRegistryErrorResponse will count an error response of the given registry
+// host with the given status code.
+func (m *Metrics) IncRegistryErrorResponse(host string, statusCode int) {
+	m.backend.AddCounter(RegistryErrorsName, map[string]string{
+		"host":        host,
+		"status_code": strconv.Itoa(statusCode),
+	}, 1)
+}
+
+// IncImageCacheStampede will count a fetch of image tags of the given
+// registry started whilst a fetch of the same image was already in flight.
+func (m *Metrics) IncImageCacheStampede(registry string) {
+	m.backend.AddCounter(ImageCacheStampedesName, map[string]string{
+		"registry": registry,
+	}, 1)
+}
+
 func (m *Metrics) latestImageIndex(namespace, pod, container string) string {
 	return strings.Join([]string{namespace, pod, container}, "")
 }
 
-func (m *Metrics) buildLabels(namespace, pod, container, imageURL, currentVersion, latestVersion string) prometheus.Labels {
-	return prometheus.Labels{
+func (m *Metrics) buildLabels(namespace, pod, container, imageURL, currentVersion, latestVersion string) map[string]string {
+	return map[string]string{
 		"namespace":       namespace,
 		"pod":             pod,
 		"container":       container,
@@ -150,16 +217,16 @@ func (m *Metrics) Shutdown() error {
 		return nil
 	}
 
-	m.log.Info("shutting down prometheus metrics server...")
+	m.log.Info("shutting down metrics server...")
 
 	ctx, cancel := context.WithTimeout(context.Background(), time.Second*5)
 	defer cancel()
 
 	if err := m.Server.Shutdown(ctx); err != nil {
-		return fmt.Errorf("prometheus metrics server shutdown failed: %s", err)
+		return fmt.Errorf("metrics server shutdown failed: %s", err)
 	}
 
-	m.log.Info("prometheus metrics server gracefully stopped")
+	m.log.Info("metrics server gracefully stopped")
 
 	return nil
 }
@@ -0,0 +1,176 @@
+package metrics
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+// fakeBackend is a Backend recording the instruments fired.
+type fakeBackend struct {
+	counters   map[string]float64
+	histograms map[string][]float64
+	gauges     map[string]float64
+}
+
+func newFakeBackend() *fakeBackend {
+	return &fakeBackend{
+		counters:   make(map[string]float64),
+		histograms: make(map[string][]float64),
+		gauges:     make(map[string]float64),
+	}
+}
+
+// key returns the key of the given instrument name and labels.
+func (f *fakeBackend) key(name string, labels map[string]string) string {
+	return name + fmtLabels(labels)
+}
+
+func (f *fakeBackend) AddCounter(name string, labels map[string]string, value float64) {
+	f.counters[f.key(name, labels)] += value
+}
+
+func (f *fakeBackend) ObserveHistogram(name string, labels map[string]string, value float64) {
+	key := f.key(name, labels)
+	f.histograms[key] = append(f.histograms[key], value)
+}
+
+func (f *fakeBackend) SetGauge(name string, labels map[string]string, value float64) {
+	f.gauges[f.key(name, labels)] = value
+}
+
+func (f *fakeBackend) DeleteGauge(name string, labels map[string]string) {
+	delete(f.gauges, f.key(name, labels))
+}
+
+// fmtLabels formats the given labels in the order of the names of all
+// instruments' labels.
+func fmtLabels(labels map[string]string) string {
+	s, seen := "{", make(map[string]bool)
+	for _, instrument := range Instruments {
+		for _, label := range instrument.Labels {
+			if v, ok := labels[label]; ok && !seen[label] {
+				s += label + "=" + v + ","
+				seen[label] = true
+			}
+		}
+	}
+	return s + "}"
+}
+
+func TestAddRemoveImage(t *testing.T) {
+	b := newFakeBackend()
+	m := New(logrus.NewEntry(logrus.New()), b)
+
+	m.AddImage("default", "pod", "app", "quay.io/jetstack/version-checker", false, "v0.1.0", "v0.2.0")
+	m.AddImage("default", "pod", "app", "quay.io/jetstack/version-checker", true, "v0.2.0", "v0.2.0")
+
+	exp := map[string]float64{
+		"is_latest_version{namespace=default,pod=pod,container=app,image=quay.io/jetstack/version-checker,current_version=v0.2.0,latest_version=v0.2.0,}": 1,
+	}
+	if !reflect.DeepEqual(b.gauges, exp) {
+		t.Errorf("unexpected gauges, exp=%v got=%v", exp, b.gauges)
+	}
+
+	m.RemoveImage("default", "pod", "app")
+	if len(b.gauges) != 0 {
+		t.Errorf("expected gauges to be removed, exp=0 got=%d", len(b.gauges))
+	}
+}
+
+func TestUpgradeAvailable(t *testing.T) {
+	b := newFakeBackend()
+	m := New(logrus.NewEntry(logrus.New()), b)
+
+	m.SetUpgradeAvailable("quay.io/jetstack/version-checker", false, "v0.2.0")
+	m.SetUpgradeAvailable("quay.io/jetstack/version-checker", true, "v0.3.0")
+	m.SetUpgradeAvailable("quay.io/jetstack/cert-manager", false, "v1.0.0")
+
+	exp := map[string]float64{
+		"upgrade_available{image=quay.io/jetstack/version-checker,available_version=v0.3.0,}": 1,
+		"upgrade_available{image=quay.io/jetstack/cert-manager,available_version=v1.0.0,}":    0,
+	}
+	if !reflect.DeepEqual(b.gauges, exp) {
+		t.Errorf("unexpected gauges, exp=%v got=%v", exp, b.gauges)
+	}
+
+	m.RemoveUpgradeAvailable("quay.io/jetstack/version-checker")
+	m.RemoveUpgradeAvailable("quay.io/jetstack/cert-manager")
+	if len(b.gauges) != 0 {
+		t.Errorf("expected gauges to be removed, exp=0 got=%d", len(b.gauges))
+	}
+}
+
+func TestObserveImageCacheAge(t *testing.T) {
+	b := newFakeBackend()
+	m := New(logrus.NewEntry(logrus.New()), b)
+
+	m.ObserveImageCacheAge("quay", time.Second*10)
+	m.ObserveImageCacheAge("quay", time.Minute*20)
+	m.ObserveImageCacheAge("dockerhub", time.Second*2)
+
+	exp := map[string][]float64{
+		"image_cache_age_seconds{registry=quay,}":      {10, 1200},
+		"image_cache_age_seconds{registry=dockerhub,}": {2},
+	}
+	if !reflect.DeepEqual(b.histograms, exp) {
+		t.Errorf("unexpected histograms, exp=%v got=%v", exp, b.histograms)
+	}
+}
+
+func TestIncRegistryErrorResponse(t *testing.T) {
+	b := newFakeBackend()
+	m := New(logrus.NewEntry(logrus.New()), b)
+
+	m.IncRegistryErrorResponse("quay.io", 429)
+	m.IncRegistryErrorResponse("quay.io", 429)
+	m.IncRegistryErrorResponse("gcr.io", 503)
+
+	exp := map[string]float64{
+		"registry_error_responses_total{host=quay.io,status_code=429,}": 2,
+		"registry_error_responses_total{host=gcr.io,status_code=503,}":  1,
+	}
+	if !reflect.DeepEqual(b.counters, exp) {
+		t.Errorf("unexpected counters, exp=%v got=%v", exp, b.counters)
+	}
+}
+
+func TestIncImageCacheStampede(t *testing.T) {
+	b := newFakeBackend()
+	m := New(logrus.NewEntry(logrus.New()), b)
+
+	m.IncImageCacheStampede("quay")
+	m.IncImageCacheStampede("quay")
+	m.IncImageCacheStampede("dockerhub")
+
+	exp := map[string]float64{
+		"image_cache_stampedes_total{registry=quay,}":      2,
+		"image_cache_stampedes_total{registry=dockerhub,}": 1,
+	}
+	if !reflect.DeepEqual(b.counters, exp) {
+		t.Errorf("unexpected counters, exp=%v got=%v", exp, b.counters)
+	}
+}
+
+func TestNoopBackend(t *testing.T) {
+	m := New(logrus.NewEntry(logrus.New()), nil)
+
+	m.AddImage("default", "pod", "app", "quay.io/jetstack/version-checker", true, "v0.2.0", "v0.2.0")
+	m.RemoveImage("default", "pod", "app")
+	m.SetUpgradeAvailable("quay.io/jetstack/version-checker", true, "v0.3.0")
+	m.ObserveImageCacheAge("quay", time.Second)
+	m.IncRegistryErrorResponse("quay.io", 429)
+	m.IncImageCacheStampede("quay")
+
+	if err := m.Run("127.0.0.1:0"); err != nil {
+		t.Fatalf("unexpected error running metrics of a backend which cannot be served: %s", err)
+	}
+	if m.Server != nil {
+		t.Error("expected no metrics server to be run for a backend which cannot be served")
+	}
+	if err := m.Shutdown(); err != nil {
+		t.Errorf("unexpected error shutting down metrics: %s", err)
+	}
+}
@@ -0,0 +1,17 @@
+module github.com/jetstack/version-checker/pkg/metrics/otel
+
+go 1.19
+
+require (
+	github.com/jetstack/version-checker v0.0.0
+	github.com/sirupsen/logrus v1.6.0
+	go.opentelemetry.io/otel v1.16.0
+	go.opentelemetry.io/otel/metric v1.16.0
+)
+
+require (
+	github.com/konsorten/go-windows-terminal-sequences v1.0.3 // indirect
+	golang.org/x/sys v0.0.0-20200622214017-ed371f2e16b4 // indirect
+)
+
+replace github.com/jetstack/version-checker => ../../..
@@ -0,0 +1,135 @@
+// Package otel is a metrics Backend recording instruments through the
+// OpenTelemetry metric API.
+//
+// It is a separate module, since the OpenTelemetry metric API requires a
+// newer go-logr than the klog version pinned by the Kubernetes dependencies
+// of version-checker.
+package otel
+
+import (
+	"context"
+	"fmt"
+	"sync"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/metric"
+
+	"github.com/jetstack/version-checker/pkg/metrics"
+)
+
+var _ metrics.Backend = &Backend{}
+
+// Backend is a metrics Backend recording instruments with an OpenTelemetry
+// Meter. Gauges are recorded as observable gauges, reporting the last set
+// value of each set of labels until deleted. Histogram buckets are left to
+// the views of the MeterProvider.
+type Backend struct {
+	counters   map[string]metric.Float64Counter
+	histograms map[string]metric.Float64Histogram
+
+	mu     sync.Mutex
+	gauges map[string]map[attribute.Distinct]gaugeValue
+}
+
+// gaugeValue is the last set value of a gauge for a set of labels.
+type gaugeValue struct {
+	attrs attribute.Set
+	value float64
+}
+
+// New returns a Backend with all instruments of metrics created with the
+// given Meter.
+func New(meter metric.Meter) (*Backend, error) {
+	b := &Backend{
+		counters:   make(map[string]metric.Float64Counter),
+		histograms: make(map[string]metric.Float64Histogram),
+		gauges:     make(map[string]map[attribute.Distinct]gaugeValue),
+	}
+
+	for _, instrument := range metrics.Instruments {
+		name := metrics.Namespace + "_" + instrument.Name
+
+		switch instrument.Kind {
+		case metrics.Counter:
+			counter, err := meter.Float64Counter(name, metric.WithDescription(instrument.Help))
+			if err != nil {
+				return nil, fmt.Errorf("failed to create counter %q: %w", name, err)
+			}
+			b.counters[instrument.Name] = counter
+
+		case metrics.Histogram:
+			histogram, err := meter.Float64Histogram(name, metric.WithDescription(instrument.Help))
+			if err != nil {
+				return nil, fmt.Errorf("failed to create histogram %q: %w", name, err)
+			}
+			b.histograms[instrument.Name] = histogram
+
+		case metrics.Gauge:
+			b.gauges[instrument.Name] = make(map[attribute.Distinct]gaugeValue)
+			_, err := meter.Float64ObservableGauge(name,
+				metric.WithDescription(instrument.Help),
+				metric.WithFloat64Callback(b.observeGauge(instrument.Name)),
+			)
+			if err != nil {
+				return nil, fmt.Errorf("failed to create gauge %q: %w", name, err)
+			}
+		}
+	}
+
+	return b, nil
+}
+
+func (b *Backend) AddCounter(name string, labels map[string]string, value float64) {
+	if counter, ok := b.counters[name]; ok {
+		counter.Add(context.Background(), value, metric.WithAttributeSet(attributeSet(labels)))
+	}
+}
+
+func (b *Backend) ObserveHistogram(name string, labels map[string]string, value float64) {
+	if histogram, ok := b.histograms[name]; ok {
+		histogram.Record(context.Background(), value, metric.WithAttributeSet(attributeSet(labels)))
+	}
+}
+
+func (b *Backend) SetGauge(name string, labels map[string]string, value float64) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if values, ok := b.gauges[name]; ok {
+		attrs := attributeSet(labels)
+		values[attrs.Equivalent()] = gaugeValue{attrs: attrs, value: value}
+	}
+}
+
+func (b *Backend) DeleteGauge(name string, labels map[string]string) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if values, ok := b.gauges[name]; ok {
+		attrs := attributeSet(labels)
+		delete(values, attrs.Equivalent())
+	}
+}
+
+// observeGauge returns a callback observing the set values of the named
+// gauge.
+func (b *Backend) observeGauge(name string) metric.Float64Callback {
+	return func(_ context.Context, observer metric.Float64Observer) error {
+		b.mu.Lock()
+		defer b.mu.Unlock()
+
+		for _, v := range b.gauges[name] {
+			observer.Observe(v.value, metric.WithAttributeSet(v.attrs))
+		}
+		return nil
+	}
+}
+
+// attributeSet returns the labels as an attribute set.
+func attributeSet(labels map[string]string) attribute.Set {
+	kvs := make([]attribute.KeyValue, 0, len(labels))
+	for k, v := range labels {
+		kvs = append(kvs, attribute.String(k, v))
+	}
+	return attribute.NewSet(kvs...)
+}
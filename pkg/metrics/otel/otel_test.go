@@ -0,0 +1,179 @@
+package otel
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/metric"
+	"go.opentelemetry.io/otel/metric/noop"
+
+	"github.com/jetstack/version-checker/pkg/metrics"
+)
+
+// fakeMeter is a Meter recording the measurements of its instruments, keyed
+// by instrument name and encoded attributes.
+type fakeMeter struct {
+	noop.Meter
+
+	values    map[string]map[string]float64
+	callbacks map[string][]metric.Float64Callback
+}
+
+type fakeCounter struct {
+	noop.Float64Counter
+	values map[string]float64
+}
+
+type fakeHistogram struct {
+	noop.Float64Histogram
+	values map[string]float64
+}
+
+type fakeObserver struct {
+	noop.Float64Observer
+	values map[string]float64
+}
+
+func newFakeMeter() *fakeMeter {
+	return &fakeMeter{
+		values:    make(map[string]map[string]float64),
+		callbacks: make(map[string][]metric.Float64Callback),
+	}
+}
+
+func (f *fakeMeter) Float64Counter(name string, _ ...metric.Float64CounterOption) (metric.Float64Counter, error) {
+	f.values[name] = make(map[string]float64)
+	return &fakeCounter{values: f.values[name]}, nil
+}
+
+func (f *fakeMeter) Float64Histogram(name string, _ ...metric.Float64HistogramOption) (metric.Float64Histogram, error) {
+	f.values[name] = make(map[string]float64)
+	return &fakeHistogram{values: f.values[name]}, nil
+}
+
+func (f *fakeMeter) Float64ObservableGauge(name string, opts ...metric.Float64ObservableGaugeOption) (metric.Float64ObservableGauge, error) {
+	f.callbacks[name] = metric.NewFloat64ObservableGaugeConfig(opts...).Callbacks()
+	return noop.Float64ObservableGauge{}, nil
+}
+
+// observe returns the values observed by the callbacks of the named gauge.
+func (f *fakeMeter) observe(t *testing.T, name string) map[string]float64 {
+	observer := &fakeObserver{values: make(map[string]float64)}
+	for _, callback := range f.callbacks[name] {
+		if err := callback(context.Background(), observer); err != nil {
+			t.Fatalf("unexpected callback error: %s", err)
+		}
+	}
+	return observer.values
+}
+
+func (f *fakeCounter) Add(_ context.Context, value float64, opts ...metric.AddOption) {
+	f.values[encode(metric.NewAddConfig(opts).Attributes())] += value
+}
+
+// Record sums the recorded values, being enough to tell observations apart.
+func (f *fakeHistogram) Record(_ context.Context, value float64, opts ...metric.RecordOption) {
+	f.values[encode(metric.NewRecordConfig(opts).Attributes())] += value
+}
+
+func (f *fakeObserver) Observe(value float64, opts ...metric.ObserveOption) {
+	f.values[encode(metric.NewObserveConfig(opts).Attributes())] = value
+}
+
+func encode(attrs attribute.Set) string {
+	return attrs.Encoded(attribute.DefaultEncoder())
+}
+
+func newTestBackend(t *testing.T) (*fakeMeter, *metrics.Metrics) {
+	meter := newFakeMeter()
+	b, err := New(meter)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	return meter, metrics.New(logrus.NewEntry(logrus.New()), b)
+}
+
+func TestInstruments(t *testing.T) {
+	meter := newFakeMeter()
+	if _, err := New(meter); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	for _, instrument := range metrics.Instruments {
+		name := metrics.Namespace + "_" + instrument.Name
+		_, recorded := meter.values[name]
+		_, observed := meter.callbacks[name]
+		if !recorded && !observed {
+			t.Errorf("expected instrument to be created: %q", name)
+		}
+	}
+}
+
+func TestUpgradeAvailable(t *testing.T) {
+	const (
+		imageURL = "quay.io/jetstack/version-checker"
+		name     = metrics.Namespace + "_" + metrics.UpgradeAvailableName
+	)
+
+	meter, m := newTestBackend(t)
+	m.SetUpgradeAvailable(imageURL, false, "v0.2.0")
+	m.SetUpgradeAvailable(imageURL, true, "v0.3.0")
+	m.SetUpgradeAvailable("quay.io/jetstack/cert-manager", true, "v1.0.0")
+
+	values := meter.observe(t, name)
+	if len(values) != 2 {
+		t.Errorf("expected one gauge per image, exp=2 got=%d", len(values))
+	}
+
+	key := encode(attribute.NewSet(
+		attribute.String("image", imageURL),
+		attribute.String("available_version", "v0.3.0"),
+	))
+	if value, ok := values[key]; !ok || value != 1 {
+		t.Errorf("unexpected gauge value, exp=1 got=%v (%t)", value, ok)
+	}
+
+	m.RemoveUpgradeAvailable(imageURL)
+	m.RemoveUpgradeAvailable("quay.io/jetstack/cert-manager")
+	if values := meter.observe(t, name); len(values) != 0 {
+		t.Errorf("expected gauges to be removed, exp=0 got=%d", len(values))
+	}
+}
+
+func TestCounterAndHistogram(t *testing.T) {
+	meter, m := newTestBackend(t)
+
+	m.IncRegistryErrorResponse("quay.io", 503)
+	m.IncRegistryErrorResponse("quay.io", 503)
+	m.ObserveImageCacheAge("quay.io", 30*time.Second)
+
+	key := encode(attribute.NewSet(
+		attribute.String("host", "quay.io"),
+		attribute.String("status_code", "503"),
+	))
+	if value := meter.values[metrics.Namespace+"_"+metrics.RegistryErrorsName][key]; value != 2 {
+		t.Errorf("unexpected counter value, exp=2 got=%v", value)
+	}
+
+	histogram := meter.values[metrics.Namespace+"_"+metrics.ImageCacheAgeSecondsName]
+	if len(histogram) != 1 {
+		t.Errorf("unexpected number of histogram series, exp=1 got=%d", len(histogram))
+	}
+}
+
+func TestRun(t *testing.T) {
+	_, m := newTestBackend(t)
+
+	if err := m.Run("127.0.0.1:0"); err != nil {
+		t.Fatalf("unexpected error running metrics: %s", err)
+	}
+	if m.Server != nil {
+		t.Error("expected no metrics server to be run for an otel backend")
+	}
+	if err := m.Shutdown(); err != nil {
+		t.Errorf("unexpected error shutting down metrics: %s", err)
+	}
+}
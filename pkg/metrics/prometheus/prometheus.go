@@ -0,0 +1,97 @@
+package prometheus
+
+import (
+	"net/http"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+
+	"github.com/jetstack/version-checker/pkg/metrics"
+)
+
+var _ metrics.Backend = &Backend{}
+
+// Backend is a metrics Backend recording instruments as Prometheus metrics,
+// served in the Prometheus exposition format.
+type Backend struct {
+	registry   *prometheus.Registry
+	handler    http.Handler
+	counters   map[string]*prometheus.CounterVec
+	histograms map[string]*prometheus.HistogramVec
+	gauges     map[string]*prometheus.GaugeVec
+}
+
+// New returns a Backend with all instruments of metrics registered to a new
+// Prometheus registry.
+func New() *Backend {
+	b := &Backend{
+		registry:   prometheus.NewRegistry(),
+		counters:   make(map[string]*prometheus.CounterVec),
+		histograms: make(map[string]*prometheus.HistogramVec),
+		gauges:     make(map[string]*prometheus.GaugeVec),
+	}
+	b.handler = promhttp.HandlerFor(b.registry, promhttp.HandlerOpts{})
+
+	for _, instrument := range metrics.Instruments {
+		switch instrument.Kind {
+		case metrics.Counter:
+			counter := prometheus.NewCounterVec(prometheus.CounterOpts{
+				Namespace: metrics.Namespace,
+				Name:      instrument.Name,
+				Help:      instrument.Help,
+			}, instrument.Labels)
+			b.registry.MustRegister(counter)
+			b.counters[instrument.Name] = counter
+
+		case metrics.Histogram:
+			histogram := prometheus.NewHistogramVec(prometheus.HistogramOpts{
+				Namespace: metrics.Namespace,
+				Name:      instrument.Name,
+				Help:      instrument.Help,
+				Buckets:   instrument.Buckets,
+			}, instrument.Labels)
+			b.registry.MustRegister(histogram)
+			b.histograms[instrument.Name] = histogram
+
+		case metrics.Gauge:
+			gauge := prometheus.NewGaugeVec(prometheus.GaugeOpts{
+				Namespace: metrics.Namespace,
+				Name:      instrument.Name,
+				Help:      instrument.Help,
+			}, instrument.Labels)
+			b.registry.MustRegister(gauge)
+			b.gauges[instrument.Name] = gauge
+		}
+	}
+
+	return b
+}
+
+// ServeHTTP serves the metrics of the registry.
+func (b *Backend) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	b.handler.ServeHTTP(w, r)
+}
+
+func (b *Backend) AddCounter(name string, labels map[string]string, value float64) {
+	if counter, ok := b.counters[name]; ok {
+		counter.With(labels).Add(value)
+	}
+}
+
+func (b *Backend) ObserveHistogram(name string, labels map[string]string, value float64) {
+	if histogram, ok := b.histograms[name]; ok {
+		histogram.With(labels).Observe(value)
+	}
+}
+
+func (b *Backend) SetGauge(name string, labels map[string]string, value float64) {
+	if gauge, ok := b.gauges[name]; ok {
+		gauge.With(labels).Set(value)
+	}
+}
+
+func (b *Backend) DeleteGauge(name string, labels map[string]string) {
+	if gauge, ok := b.gauges[name]; ok {
+		gauge.Delete(labels)
+	}
+}
@@ -0,0 +1,144 @@
+package prometheus
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/sirupsen/logrus"
+
+	"github.com/jetstack/version-checker/pkg/metrics"
+)
+
+func TestUpgradeAvailable(t *testing.T) {
+	const imageURL = "quay.io/jetstack/version-checker"
+
+	tests := map[string]struct {
+		available        bool
+		availableVersion string
+		expValue         float64
+	}{
+		"up to date image should be 0": {
+			available:        false,
+			availableVersion: "v0.2.0",
+			expValue:         0,
+		},
+		"behind image should be 1": {
+			available:        true,
+			availableVersion: "v0.3.0",
+			expValue:         1,
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			b := New()
+			m := metrics.New(logrus.NewEntry(logrus.New()), b)
+			m.SetUpgradeAvailable(imageURL, test.available, test.availableVersion)
+
+			gauge := b.gauges[metrics.UpgradeAvailableName].With(prometheus.Labels{
+				"image":             imageURL,
+				"available_version": test.availableVersion,
+			})
+			if value := testutil.ToFloat64(gauge); value != test.expValue {
+				t.Errorf("unexpected gauge value, exp=%v got=%v", test.expValue, value)
+			}
+		})
+	}
+}
+
+func TestUpgradeAvailableReplaced(t *testing.T) {
+	const imageURL = "quay.io/jetstack/version-checker"
+
+	b := New()
+	m := metrics.New(logrus.NewEntry(logrus.New()), b)
+	m.SetUpgradeAvailable(imageURL, false, "v0.2.0")
+	m.SetUpgradeAvailable(imageURL, true, "v0.3.0")
+	m.SetUpgradeAvailable("quay.io/jetstack/cert-manager", true, "v1.0.0")
+
+	if count := testutil.CollectAndCount(b.gauges[metrics.UpgradeAvailableName]); count != 2 {
+		t.Errorf("expected one gauge per image, exp=2 got=%d", count)
+	}
+
+	gauge := b.gauges[metrics.UpgradeAvailableName].With(prometheus.Labels{
+		"image":             imageURL,
+		"available_version": "v0.3.0",
+	})
+	if value := testutil.ToFloat64(gauge); value != 1 {
+		t.Errorf("unexpected gauge value, exp=1 got=%v", value)
+	}
+
+	m.RemoveUpgradeAvailable(imageURL)
+	m.RemoveUpgradeAvailable("quay.io/jetstack/cert-manager")
+	if count := testutil.CollectAndCount(b.gauges[metrics.UpgradeAvailableName]); count != 0 {
+		t.Errorf("expected gauges to be removed, exp=0 got=%d", count)
+	}
+}
+
+func TestObserveImageCacheAge(t *testing.T) {
+	b := New()
+	m := metrics.New(logrus.NewEntry(logrus.New()), b)
+	m.ObserveImageCacheAge("quay", time.Second*10)
+	m.ObserveImageCacheAge("quay", time.Minute*20)
+	m.ObserveImageCacheAge("dockerhub", time.Second*2)
+
+	expected := `
+# HELP version_checker_image_cache_age_seconds The age of image tags when served from the image cache, rather than fetched from the upstream registry
+# TYPE version_checker_image_cache_age_seconds histogram
+version_checker_image_cache_age_seconds_bucket{registry="dockerhub",le="1"} 0
+version_checker_image_cache_age_seconds_bucket{registry="dockerhub",le="5"} 1
+version_checker_image_cache_age_seconds_bucket{registry="dockerhub",le="15"} 1
+version_checker_image_cache_age_seconds_bucket{registry="dockerhub",le="30"} 1
+version_checker_image_cache_age_seconds_bucket{registry="dockerhub",le="60"} 1
+version_checker_image_cache_age_seconds_bucket{registry="dockerhub",le="120"} 1
+version_checker_image_cache_age_seconds_bucket{registry="dockerhub",le="300"} 1
+version_checker_image_cache_age_seconds_bucket{registry="dockerhub",le="600"} 1
+version_checker_image_cache_age_seconds_bucket{registry="dockerhub",le="1800"} 1
+version_checker_image_cache_age_seconds_bucket{registry="dockerhub",le="3600"} 1
+version_checker_image_cache_age_seconds_bucket{registry="dockerhub",le="7200"} 1
+version_checker_image_cache_age_seconds_bucket{registry="dockerhub",le="+Inf"} 1
+version_checker_image_cache_age_seconds_sum{registry="dockerhub"} 2
+version_checker_image_cache_age_seconds_count{registry="dockerhub"} 1
+version_checker_image_cache_age_seconds_bucket{registry="quay",le="1"} 0
+version_checker_image_cache_age_seconds_bucket{registry="quay",le="5"} 0
+version_checker_image_cache_age_seconds_bucket{registry="quay",le="15"} 1
+version_checker_image_cache_age_seconds_bucket{registry="quay",le="30"} 1
+version_checker_image_cache_age_seconds_bucket{registry="quay",le="60"} 1
+version_checker_image_cache_age_seconds_bucket{registry="quay",le="120"} 1
+version_checker_image_cache_age_seconds_bucket{registry="quay",le="300"} 1
+version_checker_image_cache_age_seconds_bucket{registry="quay",le="600"} 1
+version_checker_image_cache_age_seconds_bucket{registry="quay",le="1800"} 2
+version_checker_image_cache_age_seconds_bucket{registry="quay",le="3600"} 2
+version_checker_image_cache_age_seconds_bucket{registry="quay",le="7200"} 2
+version_checker_image_cache_age_seconds_bucket{registry="quay",le="+Inf"} 2
+version_checker_image_cache_age_seconds_sum{registry="quay"} 1210
+version_checker_image_cache_age_seconds_count{registry="quay"} 2
+`
+
+	if err := testutil.CollectAndCompare(b.histograms[metrics.ImageCacheAgeSecondsName], strings.NewReader(expected)); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestIncRegistryErrorResponse(t *testing.T) {
+	b := New()
+	m := metrics.New(logrus.NewEntry(logrus.New()), b)
+	m.IncRegistryErrorResponse("quay.io", 401)
+	m.IncRegistryErrorResponse("quay.io", 429)
+	m.IncRegistryErrorResponse("quay.io", 429)
+	m.IncRegistryErrorResponse("gcr.io", 503)
+
+	expected := `
+# HELP version_checker_registry_error_responses_total The number of error responses from upstream registries, by host and status code
+# TYPE version_checker_registry_error_responses_total counter
+version_checker_registry_error_responses_total{host="gcr.io",status_code="503"} 1
+version_checker_registry_error_responses_total{host="quay.io",status_code="401"} 1
+version_checker_registry_error_responses_total{host="quay.io",status_code="429"} 2
+`
+
+	if err := testutil.CollectAndCompare(b.counters[metrics.RegistryErrorsName], strings.NewReader(expected)); err != nil {
+		t.Error(err)
+	}
+}
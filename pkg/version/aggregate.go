@@ -0,0 +1,95 @@
+package version
+
+import (
+	"context"
+	"errors"
+	"strings"
+
+	"github.com/jetstack/version-checker/pkg/api"
+	versionerrors "github.com/jetstack/version-checker/pkg/version/errors"
+)
+
+// LatestAcrossImages will return the latest tag across the given image URLs,
+// such as the same image published to several registries with different
+// tags. The latest tag of each image URL is resolved with the given options,
+// and the latest of these is selected the same way, by version, or by
+// timestamp if UseSHA is set. Tags resolved without a version, by
+// FallbackToSHA, are only selected if no image URL resolved a version. The
+// returned tag's Source is the image URL it was resolved from. Image URLs
+// without a matching tag are ignored, where an ErrorNoVersionFound is
+// returned if none match. Any other error resolving an image URL is returned.
+// ChannelPaths are ignored.
+func (v *Version) LatestAcrossImages(ctx context.Context, opts *api.Options, imageURLs []string) (*api.ImageTag, error) {
+	return v.latestAcrossImages(ctx, v.lookupOptions(opts), imageURLs)
+}
+
+// latestAcrossImages will return the latest tag across the given image URLs,
+// the same as LatestAcrossImages, where the default options have already been
+// applied.
+func (v *Version) latestAcrossImages(ctx context.Context, opts *api.Options, imageURLs []string) (*api.ImageTag, error) {
+	if opts.FloatingTag != nil {
+		return nil, errors.New("cannot resolve the latest tag across images of a floating tag")
+	}
+
+	var (
+		tags    []api.ImageTag
+		sources []string
+	)
+
+	for _, imageURL := range imageURLs {
+		tag, err := v.latestTagFromImage(ctx, imageURL, opts)
+		if versionerrors.IsNoVersionFound(err) {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		tags = append(tags, *tag)
+		sources = append(sources, imageURL)
+	}
+
+	latest, err := latestAcross(opts, tags)
+	if err != nil {
+		return nil, err
+	}
+
+	if latest == nil {
+		return nil, versionerrors.NewVersionErrorNotFound("%s: no tags found with these option constraints",
+			strings.Join(imageURLs, ", "))
+	}
+
+	for i := range tags {
+		if &tags[i] == latest {
+			latest.Source = sources[i]
+			break
+		}
+	}
+
+	return latest, nil
+}
+
+// latestAcross will return the latest of the given tags, which are each the
+// latest tag of an image. Returns nil if no tags are given.
+func latestAcross(opts *api.Options, tags []api.ImageTag) (*api.ImageTag, error) {
+	if opts.UseSHA {
+		return latestSHA(tags)
+	}
+	if opts.TagMapper != nil {
+		return latestMapped(opts, tags), nil
+	}
+
+	// Whether a minor has a stable version is only known per image, which
+	// has already been applied.
+	aggregateOpts := *opts
+	aggregateOpts.SkipPreReleaseMinors = false
+
+	latest, err := latestSemver(&aggregateOpts, tags)
+	if err != nil || latest != nil {
+		return latest, err
+	}
+
+	// No image resolved a version, so all were resolved by falling back to
+	// their timestamp.
+	return latestSHA(tags)
+}
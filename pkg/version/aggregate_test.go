@@ -0,0 +1,165 @@
+package version
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/jetstack/version-checker/pkg/api"
+	versionerrors "github.com/jetstack/version-checker/pkg/version/errors"
+)
+
+// imagesClient is a registryClient which returns the tags of each image URL.
+type imagesClient struct {
+	fakeClient
+
+	images map[string][]api.ImageTag
+	errs   map[string]error
+}
+
+func (i *imagesClient) Tags(_ context.Context, imageURL string) ([]api.ImageTag, error) {
+	if err := i.errs[imageURL]; err != nil {
+		return nil, err
+	}
+
+	return i.images[imageURL], nil
+}
+
+func TestLatestAcrossImages(t *testing.T) {
+	const (
+		staging = "staging.example.com/jetstack/version-checker"
+		prod    = "prod.example.com/jetstack/version-checker"
+		mirror  = "mirror.example.com/jetstack/version-checker"
+	)
+
+	client := &imagesClient{
+		images: map[string][]api.ImageTag{
+			staging: {
+				{Tag: "v1.2.0", SHA: "sha256:aaa", Timestamp: time.Unix(100, 0)},
+				{Tag: "v1.3.0-rc.1", SHA: "sha256:bbb", Timestamp: time.Unix(400, 0)},
+				{Tag: "v2.0.0", SHA: "sha256:ccc", Timestamp: time.Unix(500, 0)},
+				{Tag: "main", SHA: "sha256:ddd", Timestamp: time.Unix(600, 0)},
+			},
+			prod: {
+				{Tag: "v1.1.0", SHA: "sha256:eee", Timestamp: time.Unix(50, 0)},
+				{Tag: "v1.2.1", SHA: "sha256:fff", Timestamp: time.Unix(300, 0)},
+				{Tag: "stable", SHA: "sha256:ggg", Timestamp: time.Unix(700, 0)},
+			},
+			mirror: {
+				{Tag: "v1.2.1", SHA: "sha256:fff", Timestamp: time.Unix(350, 0)},
+			},
+		},
+	}
+
+	tests := map[string]struct {
+		opts        *api.Options
+		urls        []string
+		expTag      string
+		expSource   string
+		expNotFound bool
+	}{
+		"should select the latest version across registries": {
+			opts:      &api.Options{PinMajor: int64p(1)},
+			urls:      []string{staging, prod},
+			expTag:    "v1.2.1",
+			expSource: prod,
+		},
+		"should select the latest unpinned version across registries": {
+			opts:      new(api.Options),
+			urls:      []string{prod, staging},
+			expTag:    "v2.0.0",
+			expSource: staging,
+		},
+		"same version should select the newest image": {
+			opts:      &api.Options{PinMajor: int64p(1)},
+			urls:      []string{prod, mirror},
+			expTag:    "v1.2.1",
+			expSource: mirror,
+		},
+		"newer pre-release should be selected across registries": {
+			opts:      &api.Options{PinMajor: int64p(1), UseNewerPreRelease: true},
+			urls:      []string{prod, staging},
+			expTag:    "v1.3.0-rc.1",
+			expSource: staging,
+		},
+		"sha should select the newest image across registries": {
+			opts:      &api.Options{UseSHA: true},
+			urls:      []string{staging, prod},
+			expTag:    "stable",
+			expSource: prod,
+		},
+		"registries without a matching tag should be ignored": {
+			opts:      &api.Options{PinMajor: int64p(2)},
+			urls:      []string{prod, staging},
+			expTag:    "v2.0.0",
+			expSource: staging,
+		},
+		"no registry with a matching tag should not be found": {
+			opts:        &api.Options{PinMajor: int64p(3)},
+			urls:        []string{prod, staging},
+			expNotFound: true,
+		},
+		"fallback should only be selected without a version": {
+			opts:      &api.Options{PinMajor: int64p(2), FallbackToSHA: true},
+			urls:      []string{prod, staging},
+			expTag:    "v2.0.0",
+			expSource: staging,
+		},
+		"fallbacks should select the newest image": {
+			opts:      &api.Options{PinMajor: int64p(3), FallbackToSHA: true},
+			urls:      []string{prod, staging},
+			expTag:    "stable",
+			expSource: prod,
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			v := newTestVersion(client, time.Hour, Options{})
+
+			tag, err := v.LatestAcrossImages(context.TODO(), test.opts, test.urls)
+			if versionerrors.IsNoVersionFound(err) != test.expNotFound {
+				t.Fatalf("unexpected not found error, exp=%t got=%v", test.expNotFound, err)
+			}
+			if test.expNotFound {
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if tag.Tag != test.expTag || tag.Source != test.expSource {
+				t.Errorf("unexpected tag, exp=%q from %q got=%q from %q",
+					test.expTag, test.expSource, tag.Tag, tag.Source)
+			}
+		})
+	}
+}
+
+func TestLatestAcrossImagesErrors(t *testing.T) {
+	registryErr := errors.New("registry unavailable")
+
+	client := &imagesClient{
+		images: map[string][]api.ImageTag{
+			"prod.example.com/app": {{Tag: "v1.0.0"}},
+		},
+		errs: map[string]error{
+			"staging.example.com/app": registryErr,
+		},
+	}
+
+	v := newTestVersion(client, time.Hour, Options{})
+
+	_, err := v.LatestAcrossImages(context.TODO(), new(api.Options),
+		[]string{"prod.example.com/app", "staging.example.com/app"})
+	if !errors.Is(err, registryErr) {
+		t.Errorf("unexpected error, exp=%v got=%v", registryErr, err)
+	}
+
+	floating := "stable"
+	if _, err := v.LatestAcrossImages(context.TODO(), &api.Options{FloatingTag: &floating},
+		[]string{"prod.example.com/app"}); err == nil {
+		t.Error("expected error for floating tag, got none")
+	}
+}
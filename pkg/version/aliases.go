@@ -0,0 +1,57 @@
+package version
+
+import (
+	"context"
+
+	"github.com/jetstack/version-checker/pkg/api"
+)
+
+// LatestTagWithAliases will return the latest tag of the given image URL, the
+// same as LatestTagFromImage, along with its aliases, being the names of the
+// other tags of the repository whose digest is the same as the latest tag's,
+// e.g. 1.2 and latest of 1.2.3. Aliases are returned in the order listed by
+// the registry, and are empty if the latest tag has no digest. Aliases of a
+// tag resolved across several image URLs are those of its Source.
+func (v *Version) LatestTagWithAliases(ctx context.Context, imageURL string, opts *api.Options) (*api.ImageTag, []string, error) {
+	opts = v.lookupOptions(opts)
+
+	tag, err := v.LatestTagFromImage(ctx, imageURL, opts)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	if len(tag.SHA) == 0 {
+		return tag, nil, nil
+	}
+
+	aliasURL := lookupURL(imageURL, opts)
+	if len(tag.Source) > 0 {
+		aliasURL = tag.Source
+	}
+
+	tagsI, err := v.imageCache.Get(ctx, ScopedImageIndex(ctx, aliasURL), aliasURL, nil)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return tag, tagAliases(tag, tagsI.([]api.ImageTag)), nil
+}
+
+// tagAliases will return the names of the given tags whose digest is the same
+// as the given tag's, other than the tag itself. Tags listed for multiple
+// platforms are only returned once.
+func tagAliases(tag *api.ImageTag, tags []api.ImageTag) []string {
+	var aliases []string
+	seen := map[string]bool{tag.Tag: true}
+
+	for _, t := range tags {
+		if t.SHA != tag.SHA || seen[t.Tag] {
+			continue
+		}
+
+		seen[t.Tag] = true
+		aliases = append(aliases, t.Tag)
+	}
+
+	return aliases
+}
@@ -0,0 +1,79 @@
+package version
+
+import (
+	"context"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/jetstack/version-checker/pkg/api"
+)
+
+func TestLatestTagWithAliases(t *testing.T) {
+	tests := map[string]struct {
+		tags       []api.ImageTag
+		opts       *api.Options
+		expTag     string
+		expAliases []string
+	}{
+		"latest tag should return all tags sharing its digest": {
+			tags: []api.ImageTag{
+				{Tag: "1.2.2", SHA: "sha256:aaa", Timestamp: time.Unix(100, 0)},
+				{Tag: "1.2.3", SHA: "sha256:bbb", Timestamp: time.Unix(200, 0)},
+				{Tag: "1.2", SHA: "sha256:bbb", Timestamp: time.Unix(200, 0)},
+				{Tag: "1.1", SHA: "sha256:ccc", Timestamp: time.Unix(50, 0)},
+				{Tag: "latest", SHA: "sha256:bbb", Timestamp: time.Unix(200, 0)},
+				{Tag: "edge", SHA: "sha256:ddd", Timestamp: time.Unix(300, 0)},
+			},
+			opts:       new(api.Options),
+			expTag:     "1.2.3",
+			expAliases: []string{"1.2", "latest"},
+		},
+		"latest tag without aliases should return none": {
+			tags: []api.ImageTag{
+				{Tag: "1.2.2", SHA: "sha256:aaa", Timestamp: time.Unix(100, 0)},
+				{Tag: "1.2.3", SHA: "sha256:bbb", Timestamp: time.Unix(200, 0)},
+				{Tag: "latest", SHA: "sha256:aaa", Timestamp: time.Unix(100, 0)},
+			},
+			opts:   new(api.Options),
+			expTag: "1.2.3",
+		},
+		"aliases of multiple platforms should be returned once": {
+			tags: []api.ImageTag{
+				{Tag: "1.2.3", SHA: "sha256:bbb", Timestamp: time.Unix(200, 0), Architecture: "amd64"},
+				{Tag: "1.2.3", SHA: "sha256:ccc", Timestamp: time.Unix(200, 0), Architecture: "arm64"},
+				{Tag: "latest", SHA: "sha256:bbb", Timestamp: time.Unix(200, 0), Architecture: "amd64"},
+				{Tag: "latest", SHA: "sha256:ccc", Timestamp: time.Unix(200, 0), Architecture: "arm64"},
+			},
+			opts:       &api.Options{Architecture: "arm64"},
+			expTag:     "1.2.3",
+			expAliases: []string{"latest"},
+		},
+		"latest tag without a digest should have no aliases": {
+			tags: []api.ImageTag{
+				{Tag: "1.2.3", Timestamp: time.Unix(200, 0)},
+				{Tag: "latest", Timestamp: time.Unix(200, 0)},
+			},
+			opts:   new(api.Options),
+			expTag: "1.2.3",
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			v := newTestVersion(&fakeClient{tags: test.tags}, time.Hour, Options{})
+
+			tag, aliases, err := v.LatestTagWithAliases(context.TODO(), "quay.io/jetstack/version-checker", test.opts)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if tag.Tag != test.expTag {
+				t.Errorf("unexpected tag, exp=%s got=%s", test.expTag, tag.Tag)
+			}
+			if !reflect.DeepEqual(aliases, test.expAliases) {
+				t.Errorf("unexpected aliases, exp=%v got=%v", test.expAliases, aliases)
+			}
+		})
+	}
+}
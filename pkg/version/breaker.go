@@ -0,0 +1,131 @@
+package version
+
+import (
+	"sync"
+	"time"
+
+	clienterrors "github.com/jetstack/version-checker/pkg/client/errors"
+	versionerrors "github.com/jetstack/version-checker/pkg/version/errors"
+)
+
+type circuitState int
+
+const (
+	// circuitClosed allows all requests.
+	circuitClosed circuitState = iota
+	// circuitOpen rejects all requests until the cooldown has elapsed.
+	circuitOpen
+	// circuitHalfOpen allows a single probe request, which will close the
+	// circuit on success, or re-open it on failure.
+	circuitHalfOpen
+)
+
+// circuitBreaker short-circuits requests to registry hosts which have failed
+// too many consecutive times.
+type circuitBreaker struct {
+	mu sync.Mutex
+
+	threshold int
+	cooldown  time.Duration
+	now       func() time.Time
+
+	circuits map[string]*circuit
+}
+
+// circuit is the state of a single registry host.
+type circuit struct {
+	state    circuitState
+	failures int
+	openedAt time.Time
+	probing  bool
+}
+
+func newCircuitBreaker(threshold int, cooldown time.Duration) *circuitBreaker {
+	return &circuitBreaker{
+		threshold: threshold,
+		cooldown:  cooldown,
+		now:       time.Now,
+		circuits:  make(map[string]*circuit),
+	}
+}
+
+// allow returns an error if requests to the given host should not be made.
+func (b *circuitBreaker) allow(host string) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	c, ok := b.circuits[host]
+	if !ok {
+		return nil
+	}
+
+	switch c.state {
+	case circuitOpen:
+		if b.now().Before(c.openedAt.Add(b.cooldown)) {
+			return versionerrors.NewErrorCircuitOpen(host)
+		}
+
+		// Cooldown has elapsed, so allow a probe request.
+		c.state = circuitHalfOpen
+		c.probing = true
+		return nil
+
+	case circuitHalfOpen:
+		// Only a single probe request is allowed at once.
+		if c.probing {
+			return versionerrors.NewErrorCircuitOpen(host)
+		}
+
+		c.probing = true
+		return nil
+
+	default:
+		return nil
+	}
+}
+
+// record will record the result of a request to the given host.
+func (b *circuitBreaker) record(host string, success bool) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if success {
+		delete(b.circuits, host)
+		return
+	}
+
+	c, ok := b.circuits[host]
+	if !ok {
+		c = new(circuit)
+		b.circuits[host] = c
+	}
+
+	c.failures++
+	c.probing = false
+
+	// A failed probe, or too many consecutive failures, opens the circuit.
+	if c.state == circuitHalfOpen || c.failures >= b.threshold {
+		c.state = circuitOpen
+		c.openedAt = b.now()
+	}
+}
+
+// state returns the current state of the given host's circuit.
+func (b *circuitBreaker) state(host string) circuitState {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if c, ok := b.circuits[host]; ok {
+		return c.state
+	}
+
+	return circuitClosed
+}
+
+// hostFailure returns whether the given error of a fetch is a failure of the
+// registry host, used to mark the host unhealthy in the image cache. Requests
+// rejected by an open circuit are failures, as the host is known to be
+// failing.
+func hostFailure(err error) bool {
+	return clienterrors.IsRegistryFailure(err) || versionerrors.IsCircuitOpen(err)
+}
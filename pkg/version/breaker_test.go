@@ -0,0 +1,175 @@
+package version
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/jetstack/version-checker/pkg/api"
+	clienterrors "github.com/jetstack/version-checker/pkg/client/errors"
+	selfhostederrors "github.com/jetstack/version-checker/pkg/client/selfhosted/errors"
+	versionerrors "github.com/jetstack/version-checker/pkg/version/errors"
+)
+
+func TestCircuitBreaker(t *testing.T) {
+	now := time.Now()
+	breaker := newCircuitBreaker(3, time.Minute)
+	breaker.now = func() time.Time { return now }
+
+	expState := func(exp circuitState) {
+		t.Helper()
+		if state := breaker.state("quay.io"); state != exp {
+			t.Errorf("unexpected circuit state, exp=%d got=%d", exp, state)
+		}
+	}
+
+	// Closed: failures below the threshold are allowed.
+	for i := 0; i < 2; i++ {
+		if err := breaker.allow("quay.io"); err != nil {
+			t.Fatalf("expected closed circuit to allow request, got: %s", err)
+		}
+		breaker.record("quay.io", false)
+	}
+	expState(circuitClosed)
+
+	// Open: threshold reached.
+	breaker.record("quay.io", false)
+	expState(circuitOpen)
+	if err := breaker.allow("quay.io"); !versionerrors.IsCircuitOpen(err) {
+		t.Errorf("expected circuit open error, got: %v", err)
+	}
+
+	// Other hosts are unaffected.
+	if err := breaker.allow("gcr.io"); err != nil {
+		t.Errorf("expected other host to be allowed, got: %s", err)
+	}
+
+	// Half-open: after cooldown, a single probe is allowed.
+	now = now.Add(time.Minute)
+	if err := breaker.allow("quay.io"); err != nil {
+		t.Fatalf("expected probe to be allowed after cooldown, got: %s", err)
+	}
+	expState(circuitHalfOpen)
+	if err := breaker.allow("quay.io"); !versionerrors.IsCircuitOpen(err) {
+		t.Errorf("expected concurrent probe to be rejected, got: %v", err)
+	}
+
+	// A failed probe re-opens the circuit.
+	breaker.record("quay.io", false)
+	expState(circuitOpen)
+	if err := breaker.allow("quay.io"); !versionerrors.IsCircuitOpen(err) {
+		t.Errorf("expected circuit open error after failed probe, got: %v", err)
+	}
+
+	// A successful probe closes the circuit.
+	now = now.Add(time.Minute)
+	if err := breaker.allow("quay.io"); err != nil {
+		t.Fatalf("expected probe to be allowed after cooldown, got: %s", err)
+	}
+	breaker.record("quay.io", true)
+	expState(circuitClosed)
+	if err := breaker.allow("quay.io"); err != nil {
+		t.Errorf("expected closed circuit to allow request, got: %s", err)
+	}
+}
+
+func TestFetchCircuitBreaker(t *testing.T) {
+	client := &fakeClient{err: clienterrors.NewErrorDecode("quay.io", http.StatusServiceUnavailable, nil, errors.New("registry unavailable"))}
+
+	v := newTestVersion(client, 0, Options{
+		CircuitBreakerThreshold: 2,
+		CircuitBreakerCooldown:  time.Hour,
+	})
+
+	for i := 0; i < 2; i++ {
+		_, err := v.LatestTagFromImage(context.TODO(), "quay.io/jetstack/version-checker", new(api.Options))
+		if err == nil || versionerrors.IsCircuitOpen(err) {
+			t.Fatalf("expected registry error, got: %v", err)
+		}
+	}
+
+	_, err := v.LatestTagFromImage(context.TODO(), "quay.io/jetstack/version-checker", new(api.Options))
+	if !versionerrors.IsCircuitOpen(err) {
+		t.Errorf("expected circuit open error, got: %v", err)
+	}
+
+	if client.calls != 2 {
+		t.Errorf("expected open circuit to not call registry, exp=2 got=%d calls", client.calls)
+	}
+}
+
+func TestFetchCircuitBreakerRequestErrors(t *testing.T) {
+	tests := map[string]error{
+		"not found":    selfhostederrors.NewHTTPError(http.StatusNotFound, []byte("not found")),
+		"unauthorized": selfhostederrors.NewHTTPError(http.StatusUnauthorized, []byte("unauthorized")),
+		"forbidden":    clienterrors.NewErrorUnauthorized("quay.io", "jetstack/version-checker", http.StatusForbidden),
+		"decoded not found": clienterrors.NewErrorDecode("quay.io", http.StatusNotFound, []byte("<html>"),
+			errors.New("invalid character")),
+		"missing image": clienterrors.NewErrorImageNotFound("quay.io", "jetstack/version-checker"),
+	}
+
+	for name, clientErr := range tests {
+		t.Run(name, func(t *testing.T) {
+			client := &fakeClient{err: clientErr}
+			v := newTestVersion(client, 0, Options{
+				CircuitBreakerThreshold: 2,
+				CircuitBreakerCooldown:  time.Hour,
+			})
+
+			// Errors of the request, rather than the registry, never open the
+			// circuit of the host.
+			for i := 0; i < 5; i++ {
+				_, err := v.LatestTagFromImage(context.TODO(), "quay.io/jetstack/version-checker", new(api.Options))
+				if err == nil || versionerrors.IsCircuitOpen(err) {
+					t.Fatalf("expected request error, got: %v", err)
+				}
+			}
+
+			if state := v.breaker.state("quay.io"); state != circuitClosed {
+				t.Errorf("unexpected circuit state, exp=%d got=%d", circuitClosed, state)
+			}
+			if client.calls != 5 {
+				t.Errorf("expected every lookup to call the registry, exp=5 got=%d calls", client.calls)
+			}
+		})
+	}
+}
+
+func TestHostFailure(t *testing.T) {
+	tests := map[string]struct {
+		err    error
+		expErr bool
+	}{
+		"network error should be a failure": {
+			err:    &clienterrors.ErrorNetwork{Host: "quay.io", Kind: clienterrors.NetworkErrorConnectionRefused, Err: errors.New("connection refused")},
+			expErr: true,
+		},
+		"server error should be a failure": {
+			err:    &selfhostederrors.HTTPError{StatusCode: http.StatusServiceUnavailable},
+			expErr: true,
+		},
+		"open circuit should be a failure": {
+			err:    fmt.Errorf("lookup failed: %w", versionerrors.NewErrorCircuitOpen("quay.io")),
+			expErr: true,
+		},
+		"missing image should not be a failure": {
+			err:    clienterrors.NewErrorImageNotFound("quay.io", "jetstack/foo"),
+			expErr: false,
+		},
+		"unauthorized should not be a failure": {
+			err:    &selfhostederrors.HTTPError{StatusCode: http.StatusUnauthorized},
+			expErr: false,
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			if failure := hostFailure(test.err); failure != test.expErr {
+				t.Errorf("unexpected host failure, exp=%t got=%t", test.expErr, failure)
+			}
+		})
+	}
+}
@@ -0,0 +1,43 @@
+package version
+
+import (
+	"context"
+	"strings"
+
+	"github.com/jetstack/version-checker/pkg/api"
+)
+
+// latestAcrossChannels will return the latest tag across the channel paths of
+// the options, being repositories under the given image URL, or under the
+// override URL if set. The returned tag's Source is the repository of the
+// channel it was resolved from.
+func (v *Version) latestAcrossChannels(ctx context.Context, imageURL string, opts *api.Options) (*api.ImageTag, error) {
+	channelURLs := channelURLs(lookupURL(imageURL, opts), opts.ChannelPaths)
+
+	// The channel repositories have been resolved from the override URL.
+	channelOpts := opts.DeepCopy()
+	channelOpts.OverrideURL = nil
+
+	return v.latestAcrossImages(ctx, channelOpts, channelURLs)
+}
+
+// channelURLs returns the repositories of the given channel paths under the
+// given image URL. If the image URL is itself the repository of a channel, the
+// channels are resolved from its parent.
+// e.g. myapp/stable with stable, beta -> myapp/stable, myapp/beta
+func channelURLs(imageURL string, paths []string) []string {
+	base := imageURL
+	for _, path := range paths {
+		if strings.HasSuffix(imageURL, "/"+path) {
+			base = strings.TrimSuffix(imageURL, "/"+path)
+			break
+		}
+	}
+
+	urls := make([]string, len(paths))
+	for i, path := range paths {
+		urls[i] = base + "/" + path
+	}
+
+	return urls
+}
@@ -0,0 +1,163 @@
+package version
+
+import (
+	"context"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/jetstack/version-checker/pkg/api"
+	versionerrors "github.com/jetstack/version-checker/pkg/version/errors"
+)
+
+func TestChannelPaths(t *testing.T) {
+	const (
+		image  = "quay.io/jetstack/myapp"
+		stable = image + "/stable"
+		beta   = image + "/beta"
+		mirror = "mirror.example.com/jetstack/myapp"
+	)
+
+	client := &imagesClient{
+		images: map[string][]api.ImageTag{
+			stable: {
+				{Tag: "v1.1.0", SHA: "sha256:aaa", Timestamp: time.Unix(100, 0)},
+				{Tag: "v1.2.0", SHA: "sha256:bbb", Timestamp: time.Unix(200, 0)},
+			},
+			beta: {
+				{Tag: "v1.2.0", SHA: "sha256:ccc", Timestamp: time.Unix(200, 0)},
+				{Tag: "v1.3.0", SHA: "sha256:ddd", Timestamp: time.Unix(300, 0)},
+			},
+			mirror + "/stable": {
+				{Tag: "v1.0.0", SHA: "sha256:eee", Timestamp: time.Unix(50, 0)},
+			},
+		},
+	}
+
+	stringp := func(s string) *string { return &s }
+
+	tests := map[string]struct {
+		imageURL    string
+		opts        *api.Options
+		expTag      string
+		expSource   string
+		expNotFound bool
+	}{
+		"stable path should select only from the stable channel": {
+			imageURL:  image,
+			opts:      &api.Options{ChannelPaths: []string{"stable"}},
+			expTag:    "v1.2.0",
+			expSource: stable,
+		},
+		"beta path should select only from the beta channel": {
+			imageURL:  image,
+			opts:      &api.Options{ChannelPaths: []string{"beta"}},
+			expTag:    "v1.3.0",
+			expSource: beta,
+		},
+		"stable and beta paths should select the latest across both": {
+			imageURL:  image,
+			opts:      &api.Options{ChannelPaths: []string{"stable", "beta"}},
+			expTag:    "v1.3.0",
+			expSource: beta,
+		},
+		"image of a channel should resolve channels from its parent": {
+			imageURL:  stable,
+			opts:      &api.Options{ChannelPaths: []string{"stable", "beta"}},
+			expTag:    "v1.3.0",
+			expSource: beta,
+		},
+		"options should apply to every channel": {
+			imageURL:  image,
+			opts:      &api.Options{ChannelPaths: []string{"stable", "beta"}, PinMinor: int64p(2), PinMajor: int64p(1)},
+			expTag:    "v1.2.0",
+			expSource: stable,
+		},
+		"channels should be resolved under the override URL": {
+			imageURL:  image,
+			opts:      &api.Options{ChannelPaths: []string{"stable"}, OverrideURL: stringp(mirror)},
+			expTag:    "v1.0.0",
+			expSource: mirror + "/stable",
+		},
+		"channel without tags should not be found": {
+			imageURL:    image,
+			opts:        &api.Options{ChannelPaths: []string{"nightly"}},
+			expNotFound: true,
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			v := newTestVersion(client, time.Hour, Options{})
+
+			tag, err := v.LatestTagFromImage(context.TODO(), test.imageURL, test.opts)
+			if versionerrors.IsNoVersionFound(err) != test.expNotFound {
+				t.Fatalf("unexpected not found error, exp=%t got=%v", test.expNotFound, err)
+			}
+			if test.expNotFound {
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if tag.Tag != test.expTag || tag.Source != test.expSource {
+				t.Errorf("unexpected tag, exp=%s (%s) got=%s (%s)", test.expTag, test.expSource, tag.Tag, tag.Source)
+			}
+		})
+	}
+}
+
+func TestChannelPathsDefaultOptions(t *testing.T) {
+	client := &imagesClient{
+		images: map[string][]api.ImageTag{
+			"quay.io/jetstack/myapp/stable": {
+				{Tag: "v1.2.0", SHA: "sha256:aaa", Timestamp: time.Unix(100, 0)},
+			},
+		},
+	}
+
+	v := newTestVersion(client, time.Hour, Options{
+		DefaultOptions: &api.Options{ChannelPaths: []string{"stable"}},
+	})
+
+	tag, err := v.LatestTagFromImage(context.TODO(), "quay.io/jetstack/myapp", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if tag.Tag != "v1.2.0" {
+		t.Errorf("unexpected tag, exp=%s got=%s", "v1.2.0", tag.Tag)
+	}
+}
+
+func TestChannelURLs(t *testing.T) {
+	tests := map[string]struct {
+		imageURL string
+		paths    []string
+		expURLs  []string
+	}{
+		"image should have channels appended": {
+			imageURL: "quay.io/myapp",
+			paths:    []string{"stable", "beta"},
+			expURLs:  []string{"quay.io/myapp/stable", "quay.io/myapp/beta"},
+		},
+		"channel image should have channels resolved from its parent": {
+			imageURL: "quay.io/myapp/beta",
+			paths:    []string{"stable", "beta"},
+			expURLs:  []string{"quay.io/myapp/stable", "quay.io/myapp/beta"},
+		},
+		"image only ending in a channel name should not be its parent": {
+			imageURL: "quay.io/myapp-stable",
+			paths:    []string{"stable"},
+			expURLs:  []string{"quay.io/myapp-stable/stable"},
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			if urls := channelURLs(test.imageURL, test.paths); !reflect.DeepEqual(urls, test.expURLs) {
+				t.Errorf("unexpected urls, exp=%v got=%v", test.expURLs, urls)
+			}
+		})
+	}
+}
@@ -0,0 +1,99 @@
+package version
+
+import (
+	"context"
+	"errors"
+
+	"github.com/jetstack/version-checker/pkg/api"
+)
+
+// Compare will resolve the latest tag of each of the given image URLs with the
+// given options, the same as LatestTagFromImage, and return -1, 0 or 1 if the
+// latest of image A is older than, the same as, or newer than that of image B.
+// Tags are compared the same way as the latest is selected, by version, by
+// timestamp if UseSHA is set, or by the tag mapper if set. Tags resolved
+// without a version, by FallbackToSHA, are older than any version, and are
+// compared by timestamp. Returns an error if either image fails to resolve,
+// or if pinned to a floating tag.
+func (v *Version) Compare(ctx context.Context, opts *api.Options, imageA, imageB string) (int, error) {
+	opts = v.lookupOptions(opts)
+	if opts.FloatingTag != nil {
+		return 0, errors.New("cannot compare the latest tags of images of a floating tag")
+	}
+
+	a, err := v.LatestTagFromImage(ctx, imageA, opts)
+	if err != nil {
+		return 0, err
+	}
+
+	b, err := v.LatestTagFromImage(ctx, imageB, opts)
+	if err != nil {
+		return 0, err
+	}
+
+	return compareTags(opts, a, b)
+}
+
+// compareTags returns -1, 0 or 1 if the given tag is older than, the same as,
+// or newer than the other tag, by the order the latest tag is selected in.
+func compareTags(opts *api.Options, a, b *api.ImageTag) (int, error) {
+	if opts.UseSHA {
+		return compareTimestamps(a, b), nil
+	}
+
+	if opts.TagMapper != nil {
+		aValue, aOK := opts.TagMapper(a.Tag)
+		bValue, bOK := opts.TagMapper(b.Tag)
+		switch {
+		case aOK && bOK && aValue.Less(bValue):
+			return -1, nil
+		case aOK && bOK && bValue.Less(aValue):
+			return 1, nil
+		}
+
+		return compareTimestamps(a, b), nil
+	}
+
+	versionIndex, err := versionExtractorIndex(opts)
+	if err != nil {
+		return 0, err
+	}
+
+	aV, _ := parseTag(opts, versionIndex, a.Tag)
+	bV, _ := parseTag(opts, versionIndex, b.Tag)
+	aValid, bValid := aV != nil && aV.IsValid(), bV != nil && bV.IsValid()
+
+	switch {
+	// Tags resolved without a version are older than any version.
+	case aValid != bValid:
+		if aValid {
+			return 1, nil
+		}
+		return -1, nil
+
+	case !aValid:
+		return compareTimestamps(a, b), nil
+
+	case versionLessThan(opts, aV, bV):
+		return -1, nil
+
+	case versionLessThan(opts, bV, aV):
+		return 1, nil
+
+	default:
+		return 0, nil
+	}
+}
+
+// compareTimestamps returns -1, 0 or 1 if the timestamp of the given tag is
+// before, the same as, or after that of the other tag.
+func compareTimestamps(a, b *api.ImageTag) int {
+	switch {
+	case a.Timestamp.Before(b.Timestamp):
+		return -1
+	case a.Timestamp.After(b.Timestamp):
+		return 1
+	default:
+		return 0
+	}
+}
@@ -0,0 +1,132 @@
+package version
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/jetstack/version-checker/pkg/api"
+	versionerrors "github.com/jetstack/version-checker/pkg/version/errors"
+)
+
+func TestCompare(t *testing.T) {
+	const (
+		cainjector = "quay.io/jetstack/cert-manager-cainjector"
+		controller = "quay.io/jetstack/cert-manager-controller"
+		gcr        = "gcr.io/jetstack/cert-manager-controller"
+		nightly    = "quay.io/jetstack/cert-manager-nightly"
+		empty      = "quay.io/jetstack/empty"
+	)
+
+	client := &imagesClient{
+		images: map[string][]api.ImageTag{
+			cainjector: {
+				{Tag: "v1.2.0", SHA: "sha256:aaa", Timestamp: time.Unix(100, 0)},
+				{Tag: "v1.3.0", SHA: "sha256:bbb", Timestamp: time.Unix(500, 0)},
+			},
+			controller: {
+				{Tag: "v1.2.0", SHA: "sha256:ccc", Timestamp: time.Unix(200, 0)},
+				{Tag: "v1.3.1", SHA: "sha256:ddd", Timestamp: time.Unix(400, 0)},
+			},
+			gcr: {
+				{Tag: "v1.3.1", SHA: "sha256:ddd", Timestamp: time.Unix(450, 0)},
+			},
+			nightly: {
+				{Tag: "main-abc", SHA: "sha256:eee", Timestamp: time.Unix(900, 0)},
+			},
+			empty: {
+				{Tag: "latest", SHA: "sha256:fff", Timestamp: time.Unix(100, 0)},
+			},
+		},
+	}
+
+	tests := map[string]struct {
+		opts        *api.Options
+		imageA      string
+		imageB      string
+		expCmp      int
+		expNotFound bool
+	}{
+		"same registry newer version should be newer": {
+			opts:   new(api.Options),
+			imageA: controller,
+			imageB: cainjector,
+			expCmp: 1,
+		},
+		"same registry older version should be older": {
+			opts:   new(api.Options),
+			imageA: cainjector,
+			imageB: controller,
+			expCmp: -1,
+		},
+		"same registry pinned versions should be the same": {
+			opts:   &api.Options{PinMajor: int64p(1), PinMinor: int64p(2)},
+			imageA: cainjector,
+			imageB: controller,
+			expCmp: 0,
+		},
+		"cross registry same version should be the same": {
+			opts:   new(api.Options),
+			imageA: gcr,
+			imageB: controller,
+			expCmp: 0,
+		},
+		"cross registry newer version should be newer": {
+			opts:   new(api.Options),
+			imageA: gcr,
+			imageB: cainjector,
+			expCmp: 1,
+		},
+		"sha should compare by timestamp": {
+			opts:   &api.Options{UseSHA: true},
+			imageA: controller,
+			imageB: cainjector,
+			expCmp: -1,
+		},
+		"sha cross registry should compare by timestamp": {
+			opts:   &api.Options{UseSHA: true},
+			imageA: gcr,
+			imageB: controller,
+			expCmp: 1,
+		},
+		"fallback to sha should be older than any version": {
+			opts:   &api.Options{FallbackToSHA: true},
+			imageA: nightly,
+			imageB: cainjector,
+			expCmp: -1,
+		},
+		"image without a version should not be found": {
+			opts:        new(api.Options),
+			imageA:      controller,
+			imageB:      empty,
+			expNotFound: true,
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			v := newTestVersion(client, time.Hour, Options{})
+
+			cmp, err := v.Compare(context.TODO(), test.opts, test.imageA, test.imageB)
+			if versionerrors.IsNoVersionFound(err) != test.expNotFound {
+				t.Fatalf("unexpected not found error, exp=%t got=%v", test.expNotFound, err)
+			}
+			if test.expNotFound {
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if cmp != test.expCmp {
+				t.Errorf("unexpected comparison, exp=%d got=%d", test.expCmp, cmp)
+			}
+		})
+	}
+
+	floating := "latest"
+	v := newTestVersion(client, time.Hour, Options{})
+	if _, err := v.Compare(context.TODO(), &api.Options{FloatingTag: &floating}, controller, empty); err == nil {
+		t.Error("expected error comparing images of a floating tag")
+	}
+}
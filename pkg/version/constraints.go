@@ -0,0 +1,52 @@
+package version
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/jetstack/version-checker/pkg/api"
+	"github.com/jetstack/version-checker/pkg/version/semver"
+)
+
+const (
+	// defaultConstraintCacheTimeout is the duration the result of the
+	// ConstraintFunc is cached for, if no timeout is configured.
+	defaultConstraintCacheTimeout = time.Second * 30
+)
+
+// constraintFetcher is the cache handler for the version constraints of image
+// URLs, returned by the ConstraintFunc.
+type constraintFetcher struct {
+	v *Version
+}
+
+// Fetch will return the version constraints of the given image URL.
+func (c *constraintFetcher) Fetch(_ context.Context, imageURL string, _ *api.Options) (interface{}, error) {
+	return c.v.opts.ConstraintFunc(imageURL)
+}
+
+// withConstraints will return the given options with the version constraints
+// of the given image URL set, if a ConstraintFunc is configured. Constraints
+// already set on the options, such as from annotations, are intersected with
+// those of the ConstraintFunc, so that a tag must satisfy both. The options
+// are modified, so must already be a copy of those of the lookup.
+func (v *Version) withConstraints(ctx context.Context, imageURL string, opts *api.Options) (*api.Options, error) {
+	if v.opts.ConstraintFunc == nil {
+		return opts, nil
+	}
+
+	constraints, err := v.constraintCache.Get(ctx, imageURL, imageURL, nil)
+	if err != nil {
+		return nil, fmt.Errorf("%s: failed to get version constraints: %w", imageURL, err)
+	}
+
+	if constraints := constraints.(*semver.Constraints); constraints != nil {
+		if opts.VersionConstraints != nil {
+			constraints = opts.VersionConstraints.Intersect(constraints)
+		}
+		opts.VersionConstraints = constraints
+	}
+
+	return opts, nil
+}
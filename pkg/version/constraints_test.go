@@ -0,0 +1,269 @@
+package version
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/jetstack/version-checker/pkg/api"
+	versionerrors "github.com/jetstack/version-checker/pkg/version/errors"
+	"github.com/jetstack/version-checker/pkg/version/semver"
+)
+
+// stubPolicy is a ConstraintFunc returning the constraints of each image URL.
+type stubPolicy struct {
+	ranges map[string]string
+	err    error
+	calls  map[string]int
+}
+
+func (s *stubPolicy) constraints(imageURL string) (*semver.Constraints, error) {
+	s.calls[imageURL]++
+	if s.err != nil {
+		return nil, s.err
+	}
+
+	r, ok := s.ranges[imageURL]
+	if !ok {
+		return nil, nil
+	}
+
+	return semver.ParseConstraints(r)
+}
+
+func TestConstraintFunc(t *testing.T) {
+	tags := []api.ImageTag{
+		{Tag: "v1.1.0"},
+		{Tag: "v1.2.0"},
+		{Tag: "v1.2.5"},
+		{Tag: "v2.0.0"},
+		{Tag: "v2.1.0"},
+		{Tag: "v3.0.0-rc.1"},
+	}
+
+	client := &imagesClient{
+		images: map[string][]api.ImageTag{
+			"jetstack/frontend":  tags,
+			"jetstack/backend":   tags,
+			"jetstack/database":  tags,
+			"jetstack/unmanaged": tags,
+			"jetstack/retired":   tags,
+		},
+	}
+
+	policy := &stubPolicy{
+		ranges: map[string]string{
+			"jetstack/frontend": "^1.1",
+			"jetstack/backend":  ">=1.2.0, <2.1.0",
+			"jetstack/database": "~1.2.0 || >=2.1",
+			"jetstack/retired":  "<1.0.0",
+		},
+		calls: make(map[string]int),
+	}
+
+	tests := map[string]struct {
+		imageURL    string
+		opts        *api.Options
+		expTag      string
+		expNotFound bool
+	}{
+		"caret range should select latest of major": {
+			imageURL: "jetstack/frontend",
+			opts:     new(api.Options),
+			expTag:   "v1.2.5",
+		},
+		"bounded range should select latest within": {
+			imageURL: "jetstack/backend",
+			opts:     new(api.Options),
+			expTag:   "v2.0.0",
+		},
+		"either range should select latest of both": {
+			imageURL: "jetstack/database",
+			opts:     new(api.Options),
+			expTag:   "v2.1.0",
+		},
+		"range should be combined with pins": {
+			imageURL: "jetstack/database",
+			opts:     &api.Options{PinMajor: int64p(1)},
+			expTag:   "v1.2.5",
+		},
+		"no range should be unconstrained": {
+			imageURL: "jetstack/unmanaged",
+			opts:     new(api.Options),
+			expTag:   "v2.1.0",
+		},
+		"range without a matching tag should not be found": {
+			imageURL:    "jetstack/retired",
+			opts:        new(api.Options),
+			expNotFound: true,
+		},
+	}
+
+	v := newTestVersion(client, time.Hour, Options{
+		CacheResults:   true,
+		ConstraintFunc: policy.constraints,
+	})
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			tag, err := v.LatestTagFromImage(context.TODO(), test.imageURL, test.opts)
+			if versionerrors.IsNoVersionFound(err) != test.expNotFound {
+				t.Fatalf("unexpected not found error, exp=%t got=%v", test.expNotFound, err)
+			}
+			if test.expNotFound {
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if tag.Tag != test.expTag {
+				t.Errorf("unexpected tag, exp=%q got=%q", test.expTag, tag.Tag)
+			}
+			if test.opts.VersionConstraints != nil {
+				t.Errorf("expected lookup options not to be modified, got constraints %q",
+					test.opts.VersionConstraints)
+			}
+		})
+	}
+
+	if calls := policy.calls["jetstack/database"]; calls != 1 {
+		t.Errorf("expected constraints to be cached, got %d calls", calls)
+	}
+}
+
+func TestConstraintFuncIntersect(t *testing.T) {
+	client := &fakeClient{
+		tags: []api.ImageTag{
+			{Tag: "v1.1.0"},
+			{Tag: "v1.2.5"},
+			{Tag: "v2.0.0"},
+			{Tag: "v2.1.0"},
+			{Tag: "v3.0.0"},
+		},
+	}
+
+	policy := &stubPolicy{
+		ranges: map[string]string{"jetstack/version-checker": "~1.2.0 || >=2.1"},
+		calls:  make(map[string]int),
+	}
+
+	tests := map[string]struct {
+		constraints string
+		expTag      string
+		expNotFound bool
+	}{
+		"lookup constraints should narrow the range": {
+			constraints: "<3",
+			expTag:      "v2.1.0",
+		},
+		"each group should be intersected": {
+			constraints: "<2 || >=3",
+			expTag:      "v3.0.0",
+		},
+		"range should narrow the lookup constraints": {
+			constraints: ">=1.0.0, <2.1.0",
+			expTag:      "v1.2.5",
+		},
+		"disjoint constraints should not be found": {
+			constraints: "<1.2",
+			expNotFound: true,
+		},
+	}
+
+	v := newTestVersion(client, time.Hour, Options{ConstraintFunc: policy.constraints})
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			constraints, err := semver.ParseConstraints(test.constraints)
+			if err != nil {
+				t.Fatal(err)
+			}
+			opts := &api.Options{VersionConstraints: constraints}
+
+			tag, err := v.LatestTagFromImage(context.TODO(), "jetstack/version-checker", opts)
+			if versionerrors.IsNoVersionFound(err) != test.expNotFound {
+				t.Fatalf("unexpected not found error, exp=%t got=%v", test.expNotFound, err)
+			}
+			if test.expNotFound {
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if tag.Tag != test.expTag {
+				t.Errorf("unexpected tag, exp=%q got=%q", test.expTag, tag.Tag)
+			}
+			if opts.VersionConstraints != constraints {
+				t.Errorf("expected lookup options not to be modified, got constraints %q",
+					opts.VersionConstraints)
+			}
+		})
+	}
+}
+
+func TestConstraintFuncCacheTimeout(t *testing.T) {
+	client := &fakeClient{
+		tags: []api.ImageTag{{Tag: "v1.0.0"}, {Tag: "v2.0.0"}},
+	}
+
+	policy := &stubPolicy{
+		ranges: map[string]string{"jetstack/version-checker": "<2"},
+		calls:  make(map[string]int),
+	}
+
+	clock := &fakeClock{now: time.Unix(1600000000, 0)}
+	v := newTestVersion(client, time.Hour, Options{
+		Clock:                  clock,
+		ConstraintFunc:         policy.constraints,
+		ConstraintCacheTimeout: time.Minute,
+	})
+
+	for _, test := range []struct {
+		advance  time.Duration
+		rng      string
+		expTag   string
+		expCalls int
+	}{
+		{0, "<2", "v1.0.0", 1},
+		{time.Second * 30, ">=2", "v1.0.0", 1},
+		{time.Second * 31, ">=2", "v2.0.0", 2},
+	} {
+		clock.now = clock.now.Add(test.advance)
+		policy.ranges["jetstack/version-checker"] = test.rng
+
+		tag, err := v.LatestTagFromImage(context.TODO(), "jetstack/version-checker", new(api.Options))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if tag.Tag != test.expTag {
+			t.Errorf("unexpected tag after %s, exp=%q got=%q", test.advance, test.expTag, tag.Tag)
+		}
+		if calls := policy.calls["jetstack/version-checker"]; calls != test.expCalls {
+			t.Errorf("unexpected constraint calls after %s, exp=%d got=%d", test.advance, test.expCalls, calls)
+		}
+	}
+}
+
+func TestConstraintFuncError(t *testing.T) {
+	client := &fakeClient{
+		tags: []api.ImageTag{{Tag: "v1.0.0"}},
+	}
+
+	policyErr := errors.New("policy service unavailable")
+	policy := &stubPolicy{err: policyErr, calls: make(map[string]int)}
+
+	v := newTestVersion(client, time.Hour, Options{ConstraintFunc: policy.constraints})
+
+	if _, err := v.LatestTagFromImage(context.TODO(), "jetstack/version-checker", new(api.Options)); !errors.Is(err, policyErr) {
+		t.Errorf("unexpected error, exp=%v got=%v", policyErr, err)
+	}
+	if _, err := v.LatestTagFromImage(context.TODO(), "jetstack/version-checker", new(api.Options)); err == nil {
+		t.Error("expected error not to be cached, got none")
+	}
+	if calls := policy.calls["jetstack/version-checker"]; calls != 2 {
+		t.Errorf("expected failed constraints not to be cached, got %d calls", calls)
+	}
+}
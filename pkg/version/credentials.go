@@ -0,0 +1,91 @@
+package version
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"strings"
+
+	"github.com/jetstack/version-checker/pkg/api"
+)
+
+// ScopedImageIndex returns the cache index of the given image URL, scoped to
+// the identity of the registry credentials carried by the given context, if
+// any. Lookups made with different credentials may see different tags of the
+// same image, so they never share cached tags or results. Likewise, the index
+// is scoped to the tag prefix carried by the context, as only the tags
+// matching the prefix may be listed. The image URL is returned unchanged if
+// the context carries neither. This should be used in place of the image URL
+// when calculating a hash index of a lookup.
+// e.g. quay.io/jetstack/version-checker#3f2a...
+// e.g. quay.io/jetstack/version-checker?prefix=v1.2.
+func ScopedImageIndex(ctx context.Context, imageURL string) string {
+	index := imageURL
+	if creds, ok := api.CredentialsFromContext(ctx); ok {
+		index += "#" + credentialsIdentity(creds)
+	}
+	if prefix, ok := api.TagPrefixFromContext(ctx); ok {
+		index += "?prefix=" + prefix
+	}
+
+	return index
+}
+
+// redactedImageIndex returns the given index, as returned by
+// ScopedImageIndex, without the identity of its credentials, along with
+// whether it was scoped by credentials.
+func redactedImageIndex(index string) (string, bool) {
+	i := strings.Index(index, "#")
+	if i < 0 {
+		return index, false
+	}
+
+	redacted := index[:i]
+	if j := strings.Index(index[i:], "?"); j >= 0 {
+		redacted += index[i+j:]
+	}
+
+	return redacted, true
+}
+
+// unscopedImageURL returns the image URL of the given index, as returned by
+// ScopedImageIndex.
+func unscopedImageURL(index string) string {
+	if i := strings.IndexAny(index, "#?"); i >= 0 {
+		return index[:i]
+	}
+
+	return index
+}
+
+// credentialsKey is the key of the HMAC of credentials identities, generated
+// once per process, so that identities seen in logs or cache statistics
+// cannot be used to brute-force weak credentials offline.
+var credentialsKey = newCredentialsKey()
+
+// newCredentialsKey returns a new random key for credentials identities.
+func newCredentialsKey() []byte {
+	key := make([]byte, sha256.Size)
+	if _, err := rand.Read(key); err != nil {
+		panic("failed to generate credentials identity key: " + err.Error())
+	}
+
+	return key
+}
+
+// credentialsIdentity returns an identity of the given credentials, being a
+// keyed digest so that the credentials cannot be recovered from cache indexes
+// or logs, and different credentials never share an identity. Identities are
+// only stable for the lifetime of the process.
+func credentialsIdentity(creds *api.Credentials) string {
+	hash := hmac.New(sha256.New, credentialsKey)
+	for _, field := range []string{creds.Username, creds.Password, creds.Token} {
+		// Separate fields so that their boundaries are unambiguous.
+		hash.Write([]byte(field))
+		hash.Write([]byte{0})
+	}
+
+	return hex.EncodeToString(hash.Sum(nil))
+}
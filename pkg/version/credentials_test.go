@@ -0,0 +1,185 @@
+package version
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/jetstack/version-checker/pkg/api"
+)
+
+// tenantClient is a registryClient which returns the tags visible to the
+// token of the credentials carried by the context.
+type tenantClient struct {
+	fakeClient
+
+	tenant map[string][]api.ImageTag
+	calls  map[string]int
+}
+
+func (c *tenantClient) Tags(ctx context.Context, _ string) ([]api.ImageTag, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	var token string
+	if creds, ok := api.CredentialsFromContext(ctx); ok {
+		token = creds.Token
+	}
+	c.calls[token]++
+
+	return append([]api.ImageTag(nil), c.tenant[token]...), nil
+}
+
+func TestScopedImageIndex(t *testing.T) {
+	const imageURL = "localhost:5000/version-checker"
+
+	var (
+		tenantA = api.ContextWithCredentials(context.TODO(), &api.Credentials{Token: "tenant-a"})
+		tenantB = api.ContextWithCredentials(context.TODO(), &api.Credentials{Token: "tenant-b"})
+	)
+
+	if index := ScopedImageIndex(context.TODO(), imageURL); index != imageURL {
+		t.Errorf("unexpected index without credentials, exp=%s got=%s", imageURL, index)
+	}
+
+	indexA, indexB := ScopedImageIndex(tenantA, imageURL), ScopedImageIndex(tenantB, imageURL)
+	if indexA == indexB {
+		t.Errorf("expected different indexes per credentials, got=%s", indexA)
+	}
+	if !strings.HasPrefix(indexA, imageURL+"#") {
+		t.Errorf("unexpected index, exp prefix=%s# got=%s", imageURL, indexA)
+	}
+	if strings.Contains(indexA, "tenant-a") {
+		t.Errorf("index must not contain the credentials, got=%s", indexA)
+	}
+
+	if again := ScopedImageIndex(api.ContextWithCredentials(context.TODO(), &api.Credentials{Token: "tenant-a"}), imageURL); again != indexA {
+		t.Errorf("expected the same index for the same credentials, exp=%s got=%s", indexA, again)
+	}
+
+	// Field boundaries are part of the identity.
+	ab := ScopedImageIndex(api.ContextWithCredentials(context.TODO(), &api.Credentials{Username: "ab", Password: "c"}), imageURL)
+	a := ScopedImageIndex(api.ContextWithCredentials(context.TODO(), &api.Credentials{Username: "a", Password: "bc"}), imageURL)
+	if ab == a {
+		t.Errorf("expected different indexes for different credentials, got=%s", a)
+	}
+}
+
+func TestCredentialsIdentityKeyed(t *testing.T) {
+	creds := &api.Credentials{Username: "tenant", Password: "hunter2"}
+
+	// The identity must not be an unkeyed digest of the credentials.
+	unkeyed := sha256.New()
+	for _, field := range []string{creds.Username, creds.Password, creds.Token} {
+		unkeyed.Write([]byte(field))
+		unkeyed.Write([]byte{0})
+	}
+	if identity := credentialsIdentity(creds); identity == hex.EncodeToString(unkeyed.Sum(nil)) {
+		t.Errorf("expected credentials identity to be keyed, got=%s", identity)
+	}
+
+	// Identities are only stable for the key of the process.
+	key := credentialsKey
+	defer func() { credentialsKey = key }()
+
+	identity := credentialsIdentity(creds)
+	credentialsKey = newCredentialsKey()
+	if again := credentialsIdentity(creds); again == identity {
+		t.Errorf("expected identities of different keys to differ, got=%s", again)
+	}
+}
+
+func TestRedactedImageIndex(t *testing.T) {
+	tests := map[string]struct {
+		index     string
+		expIndex  string
+		expScoped bool
+	}{
+		"unscoped index should be unchanged": {
+			index:    "quay.io/jetstack/foo",
+			expIndex: "quay.io/jetstack/foo",
+		},
+		"credentials should be removed": {
+			index:     "quay.io/jetstack/foo#3f2a",
+			expIndex:  "quay.io/jetstack/foo",
+			expScoped: true,
+		},
+		"tag prefix should be kept": {
+			index:     "quay.io/jetstack/foo#3f2a?prefix=v1.2.",
+			expIndex:  "quay.io/jetstack/foo?prefix=v1.2.",
+			expScoped: true,
+		},
+		"tag prefix without credentials should be kept": {
+			index:    "quay.io/jetstack/foo?prefix=v1.2.",
+			expIndex: "quay.io/jetstack/foo?prefix=v1.2.",
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			index, scoped := redactedImageIndex(test.index)
+			if index != test.expIndex || scoped != test.expScoped {
+				t.Errorf("unexpected index, exp=%s (scoped=%t) got=%s (scoped=%t)",
+					test.expIndex, test.expScoped, index, scoped)
+			}
+		})
+	}
+}
+
+func TestCacheScopedByCredentials(t *testing.T) {
+	const imageURL = "localhost:5000/version-checker"
+
+	client := &tenantClient{
+		tenant: map[string][]api.ImageTag{
+			"tenant-a": {{Tag: "v0.1.0"}},
+			"tenant-b": {{Tag: "v0.1.0"}, {Tag: "v0.2.0"}},
+			"":         {{Tag: "v0.0.1"}},
+		},
+		calls: make(map[string]int),
+	}
+	v := newTestVersion(client, time.Hour, Options{CacheResults: true})
+
+	var (
+		tenantA = api.ContextWithCredentials(context.TODO(), &api.Credentials{Token: "tenant-a"})
+		tenantB = api.ContextWithCredentials(context.TODO(), &api.Credentials{Token: "tenant-b"})
+	)
+
+	tests := []struct {
+		ctx    context.Context
+		expTag string
+	}{
+		{ctx: tenantA, expTag: "v0.1.0"},
+		{ctx: tenantB, expTag: "v0.2.0"},
+		{ctx: context.TODO(), expTag: "v0.0.1"},
+		// Cached lookups must still be scoped.
+		{ctx: tenantA, expTag: "v0.1.0"},
+		{ctx: tenantB, expTag: "v0.2.0"},
+		{ctx: context.TODO(), expTag: "v0.0.1"},
+	}
+
+	for i, test := range tests {
+		tag, err := v.LatestTagFromImage(test.ctx, imageURL, new(api.Options))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if tag.Tag != test.expTag {
+			t.Errorf("%d: unexpected tag, exp=%s got=%s", i, test.expTag, tag.Tag)
+		}
+	}
+
+	for token, calls := range client.calls {
+		if calls != 1 {
+			t.Errorf("%q: expected tags to be cached per credentials, exp=1 got=%d calls", token, calls)
+		}
+	}
+	if len(client.calls) != 3 {
+		t.Errorf("unexpected number of credentials listing tags, exp=3 got=%d", len(client.calls))
+	}
+
+	if stats := v.imageCache.Stats(); stats.Items != 3 {
+		t.Errorf("unexpected number of image cache entries, exp=3 got=%d", stats.Items)
+	}
+}
@@ -0,0 +1,60 @@
+package version
+
+import (
+	"strings"
+
+	"github.com/jetstack/version-checker/pkg/api"
+)
+
+// tagKey identifies the entries of a tag which are duplicates, being those of
+// the same case insensitive name and platform.
+type tagKey struct {
+	tag, architecture, os string
+}
+
+// dedupeTags will return the given tags with duplicate entries collapsed,
+// keeping the entry with the most metadata, or the first listed if equal.
+// Tags are returned in the order their first entry is listed.
+func dedupeTags(tags []api.ImageTag) []api.ImageTag {
+	var (
+		deduped []api.ImageTag
+		indexes = make(map[tagKey]int, len(tags))
+	)
+
+	for _, tag := range tags {
+		key := tagKey{
+			tag:          strings.ToLower(tag.Tag),
+			architecture: tag.Architecture,
+			os:           tag.OS,
+		}
+
+		i, ok := indexes[key]
+		if !ok {
+			indexes[key] = len(deduped)
+			deduped = append(deduped, tag)
+			continue
+		}
+
+		if tagMetadata(tag) > tagMetadata(deduped[i]) {
+			deduped[i] = tag
+		}
+	}
+
+	return deduped
+}
+
+// tagMetadata returns the number of metadata fields set of the given tag.
+func tagMetadata(tag api.ImageTag) int {
+	var n int
+	for _, set := range []bool{
+		len(tag.SHA) > 0,
+		!tag.Timestamp.IsZero(),
+		len(tag.ConfigMediaType) > 0,
+	} {
+		if set {
+			n++
+		}
+	}
+
+	return n
+}
@@ -0,0 +1,107 @@
+package version
+
+import (
+	"context"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/jetstack/version-checker/pkg/api"
+)
+
+func TestDedupeTags(t *testing.T) {
+	tests := map[string]struct {
+		tags    []api.ImageTag
+		expTags []api.ImageTag
+	}{
+		"exact duplicates should be collapsed": {
+			tags: []api.ImageTag{
+				{Tag: "v1.0.0", SHA: "sha256:aaa"},
+				{Tag: "v1.1.0", SHA: "sha256:bbb"},
+				{Tag: "v1.0.0", SHA: "sha256:aaa"},
+			},
+			expTags: []api.ImageTag{
+				{Tag: "v1.0.0", SHA: "sha256:aaa"},
+				{Tag: "v1.1.0", SHA: "sha256:bbb"},
+			},
+		},
+		"case insensitive duplicates should be collapsed": {
+			tags: []api.ImageTag{
+				{Tag: "Latest", SHA: "sha256:aaa"},
+				{Tag: "latest", SHA: "sha256:aaa"},
+				{Tag: "v1.0.0-RC.1"},
+				{Tag: "v1.0.0-rc.1"},
+			},
+			expTags: []api.ImageTag{
+				{Tag: "Latest", SHA: "sha256:aaa"},
+				{Tag: "v1.0.0-RC.1"},
+			},
+		},
+		"duplicate with the most metadata should be kept": {
+			tags: []api.ImageTag{
+				{Tag: "v1.0.0"},
+				{Tag: "V1.0.0", SHA: "sha256:aaa", Timestamp: time.Unix(100, 0)},
+				{Tag: "v1.0.0", SHA: "sha256:aaa"},
+			},
+			expTags: []api.ImageTag{
+				{Tag: "V1.0.0", SHA: "sha256:aaa", Timestamp: time.Unix(100, 0)},
+			},
+		},
+		"entries of different platforms should not be collapsed": {
+			tags: []api.ImageTag{
+				{Tag: "v1.0.0", SHA: "sha256:aaa", Architecture: "amd64", OS: "linux"},
+				{Tag: "v1.0.0", SHA: "sha256:bbb", Architecture: "arm64", OS: "linux"},
+				{Tag: "v1.0.0", SHA: "sha256:bbb", Architecture: "arm64", OS: "linux"},
+			},
+			expTags: []api.ImageTag{
+				{Tag: "v1.0.0", SHA: "sha256:aaa", Architecture: "amd64", OS: "linux"},
+				{Tag: "v1.0.0", SHA: "sha256:bbb", Architecture: "arm64", OS: "linux"},
+			},
+		},
+		"no duplicates should be unchanged": {
+			tags: []api.ImageTag{
+				{Tag: "v1.0.0"},
+				{Tag: "v1.1.0"},
+			},
+			expTags: []api.ImageTag{
+				{Tag: "v1.0.0"},
+				{Tag: "v1.1.0"},
+			},
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			if tags := dedupeTags(test.tags); !reflect.DeepEqual(tags, test.expTags) {
+				t.Errorf("unexpected tags, exp=%+v got=%+v", test.expTags, tags)
+			}
+		})
+	}
+}
+
+func TestDedupeTagsOption(t *testing.T) {
+	client := &fakeClient{
+		tags: []api.ImageTag{
+			{Tag: "v1.0.0", SHA: "sha256:aaa"},
+			{Tag: "v1.0.0", SHA: "sha256:aaa", Timestamp: time.Unix(100, 0)},
+			{Tag: "V1.0.0"},
+		},
+	}
+
+	for _, dedupe := range []bool{false, true} {
+		v := newTestVersion(client, time.Hour, Options{DedupeTags: dedupe})
+
+		tagsI, err := v.Fetch(context.TODO(), "quay.io/jetstack/version-checker", nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		expLen := len(client.tags)
+		if dedupe {
+			expLen = 1
+		}
+		if tags := tagsI.([]api.ImageTag); len(tags) != expLen {
+			t.Errorf("unexpected tags with dedupe=%t, exp=%d got=%d", dedupe, expLen, len(tags))
+		}
+	}
+}
@@ -0,0 +1,128 @@
+package version
+
+import (
+	"context"
+	"fmt"
+	"sync"
+
+	"github.com/jetstack/version-checker/pkg/api"
+)
+
+// manifests will return the manifest of each of the given image tags, in the
+// same order, using the manifest cache. Manifests are fetched by a pool of
+// workers bounded by the ManifestConcurrency option, so that the number of
+// parallel requests to the registry is limited. The first error cancels all
+// outstanding fetches and is returned.
+func (v *Version) manifests(ctx context.Context, imageURL string, tags []api.ImageTag) ([]*api.ImageManifest, error) {
+	workers := v.opts.ManifestConcurrency
+	if workers < 1 {
+		workers = 1
+	}
+	if workers > len(tags) {
+		workers = len(tags)
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	var (
+		wg       sync.WaitGroup
+		errOnce  sync.Once
+		firstErr error
+
+		manifests = make([]*api.ImageManifest, len(tags))
+		jobs      = make(chan int)
+	)
+
+	for w := 0; w < workers; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+
+			for i := range jobs {
+				manifest, err := v.manifest(ctx, imageURL, tags[i])
+				if err != nil {
+					errOnce.Do(func() {
+						firstErr = fmt.Errorf("%s: failed to get manifest for tag %q: %w",
+							imageURL, tags[i].Tag, err)
+						cancel()
+					})
+					continue
+				}
+
+				manifests[i] = manifest
+			}
+		}()
+	}
+
+	var sent int
+	for i := range tags {
+		select {
+		case jobs <- i:
+			sent++
+			continue
+		case <-ctx.Done():
+		}
+		break
+	}
+	close(jobs)
+
+	wg.Wait()
+
+	if firstErr != nil {
+		return nil, firstErr
+	}
+
+	// The parent context may have been cancelled before all jobs were sent.
+	if sent < len(tags) {
+		return nil, ctx.Err()
+	}
+
+	return manifests, nil
+}
+
+// configTimestamps will return a copy of the given tags, with the timestamp of
+// each replaced by the creation time of its image manifest, if UseConfigTimestamp
+// is set. The manifest of each image is fetched once, using the manifest cache,
+// and tags whose creation time is unknown keep their timestamp.
+func (v *Version) configTimestamps(ctx context.Context, imageURL string, opts *api.Options, tags []api.ImageTag) ([]api.ImageTag, error) {
+	if !opts.UseConfigTimestamp || len(tags) == 0 {
+		return tags, nil
+	}
+
+	var (
+		unique  []api.ImageTag
+		indexes = make(map[string]int)
+		refs    = make([]int, len(tags))
+	)
+
+	for i, tag := range tags {
+		reference := tag.SHA
+		if len(reference) == 0 {
+			reference = tag.Tag
+		}
+
+		index, ok := indexes[reference]
+		if !ok {
+			index = len(unique)
+			indexes[reference] = index
+			unique = append(unique, tag)
+		}
+		refs[i] = index
+	}
+
+	manifests, err := v.manifests(ctx, imageURL, unique)
+	if err != nil {
+		return nil, err
+	}
+
+	enriched := make([]api.ImageTag, len(tags))
+	copy(enriched, tags)
+	for i := range enriched {
+		if created := manifests[refs[i]].Timestamp; !created.IsZero() {
+			enriched[i].Timestamp = created
+		}
+	}
+
+	return enriched, nil
+}
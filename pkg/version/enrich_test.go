@@ -0,0 +1,244 @@
+package version
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/jetstack/version-checker/pkg/api"
+)
+
+// newEnrichClient returns a fakeClient with the given number of tags, each
+// with a distinct manifest.
+func newEnrichClient(numTags int, delay time.Duration) *fakeClient {
+	client := &fakeClient{
+		manifests:     make(map[string]*api.ImageManifest),
+		manifestDelay: delay,
+	}
+
+	for i := 0; i < numTags; i++ {
+		sha := fmt.Sprintf("sha256:%03d", i)
+		client.tags = append(client.tags, api.ImageTag{
+			Tag: fmt.Sprintf("v0.%d.0", i),
+			SHA: sha,
+		})
+		client.manifests[sha] = &api.ImageManifest{Digest: sha, Size: int64(i)}
+	}
+
+	return client
+}
+
+func TestTagsWithMetadataConcurrency(t *testing.T) {
+	const (
+		numTags     = 50
+		concurrency = 8
+	)
+
+	client := newEnrichClient(numTags, time.Millisecond)
+	v := newTestVersion(client, time.Hour, Options{ManifestConcurrency: concurrency})
+
+	tags, err := v.TagsWithMetadata(context.TODO(), "localhost:5000/version-checker")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(tags) != numTags {
+		t.Fatalf("unexpected number of tags, exp=%d got=%d", numTags, len(tags))
+	}
+
+	// Results must be in the same order as the listed tags.
+	for i, tag := range tags {
+		if tag.Tag != client.tags[i].Tag || tag.Size != int64(i) {
+			t.Errorf("unexpected tag at %d, exp=%s/%d got=%s/%d",
+				i, client.tags[i].Tag, i, tag.Tag, tag.Size)
+		}
+	}
+
+	if client.manifestCalls != numTags {
+		t.Errorf("unexpected number of manifest calls, exp=%d got=%d", numTags, client.manifestCalls)
+	}
+	if client.maxInFlight > concurrency {
+		t.Errorf("exceeded concurrency limit, exp<=%d got=%d", concurrency, client.maxInFlight)
+	}
+	if client.maxInFlight < 2 {
+		t.Errorf("expected manifests to be fetched concurrently, got max in flight=%d", client.maxInFlight)
+	}
+}
+
+func TestTagsWithMetadataConcurrencyError(t *testing.T) {
+	client := newEnrichClient(20, time.Millisecond)
+	delete(client.manifests, "sha256:010")
+
+	v := newTestVersion(client, time.Hour, Options{ManifestConcurrency: 4})
+
+	if _, err := v.TagsWithMetadata(context.TODO(), "localhost:5000/version-checker"); err == nil {
+		t.Error("expected error for missing manifest, got none")
+	}
+}
+
+func BenchmarkTagsWithMetadata(b *testing.B) {
+	for _, concurrency := range []int{1, 8} {
+		b.Run(fmt.Sprintf("concurrency-%d", concurrency), func(b *testing.B) {
+			for i := 0; i < b.N; i++ {
+				b.StopTimer()
+				client := newEnrichClient(32, time.Millisecond)
+				v := newTestVersion(client, time.Hour, Options{ManifestConcurrency: concurrency})
+				b.StartTimer()
+
+				if _, err := v.TagsWithMetadata(context.TODO(), "localhost:5000/version-checker"); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
+
+func TestConfigTimestamps(t *testing.T) {
+	var (
+		listed  = time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)
+		created = func(day int) time.Time { return time.Date(2020, 6, day, 0, 0, 0, 0, time.UTC) }
+	)
+
+	// The listing timestamps favour "stale", whereas the image creation times
+	// favour "fresh". "unknown" has no creation time, "v1.0.0" shares its
+	// image with "fresh", and only "v2.0.0" is not a candidate of the
+	// fallback.
+	newClient := func() *fakeClient {
+		return &fakeClient{
+			tags: []api.ImageTag{
+				{Tag: "stale", SHA: "sha256:aaa", Timestamp: listed.Add(2 * time.Hour)},
+				{Tag: "fresh", SHA: "sha256:bbb", Timestamp: listed},
+				{Tag: "v1.0.0", SHA: "sha256:bbb", Timestamp: listed},
+				{Tag: "unknown", SHA: "sha256:ccc", Timestamp: listed.Add(time.Hour)},
+				{Tag: "v2.0.0", SHA: "sha256:ddd", Timestamp: listed},
+			},
+			manifests: map[string]*api.ImageManifest{
+				"sha256:aaa": {Digest: "sha256:aaa", Timestamp: created(1)},
+				"sha256:bbb": {Digest: "sha256:bbb", Timestamp: created(2)},
+				"sha256:ccc": {Digest: "sha256:ccc"},
+				"sha256:ddd": {Digest: "sha256:ddd", Timestamp: created(0)},
+			},
+		}
+	}
+
+	tests := map[string]struct {
+		opts             *api.Options
+		expTag           string
+		expTimestamp     time.Time
+		expManifestCalls int
+	}{
+		"listing timestamps are used if not set": {
+			opts:             &api.Options{UseSHA: true},
+			expTag:           "stale",
+			expTimestamp:     listed.Add(2 * time.Hour),
+			expManifestCalls: 0,
+		},
+		"creation times are used if set, fetching each image once": {
+			opts:             &api.Options{UseSHA: true, UseConfigTimestamp: true},
+			expTag:           "fresh",
+			expTimestamp:     created(2),
+			expManifestCalls: 4,
+		},
+		"tags with an unknown creation time keep their listing timestamp": {
+			opts: &api.Options{
+				UseSHA:             true,
+				UseConfigTimestamp: true,
+				BeforeTime:         &listed,
+			},
+			// Only "unknown" is before the time, but its listing timestamp is
+			// the hour after.
+			expTag: "",
+			// All images are fetched, as before times are compared to the
+			// creation times.
+			expManifestCalls: 4,
+		},
+		"only the candidates of the fallback are fetched": {
+			opts: &api.Options{
+				FallbackToSHA:      true,
+				UseConfigTimestamp: true,
+				PinMajor:           int64p(3),
+			},
+			expTag:           "fresh",
+			expTimestamp:     created(2),
+			expManifestCalls: 3,
+		},
+		"the fallback candidates are restricted to the candidate tags": {
+			opts: &api.Options{
+				FallbackToSHA:      true,
+				UseConfigTimestamp: true,
+				PinMajor:           int64p(3),
+				CandidateTags:      []string{"stale", "unknown"},
+			},
+			expTag:           "stale",
+			expTimestamp:     created(1),
+			expManifestCalls: 2,
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			client := newClient()
+			v := newTestVersion(client, time.Hour, Options{ManifestConcurrency: 2})
+
+			tag, err := v.LatestTagFromImage(context.TODO(), "localhost:5000/version-checker", test.opts)
+			if len(test.expTag) == 0 {
+				if err == nil {
+					t.Errorf("expected error, got tag %q", tag.Tag)
+				}
+			} else {
+				if err != nil {
+					t.Fatal(err)
+				}
+
+				if tag.Tag != test.expTag || !tag.Timestamp.Equal(test.expTimestamp) {
+					t.Errorf("unexpected tag, exp=%s/%s got=%s/%s",
+						test.expTag, test.expTimestamp, tag.Tag, tag.Timestamp)
+				}
+			}
+
+			if client.manifestCalls != test.expManifestCalls {
+				t.Errorf("unexpected number of manifest calls, exp=%d got=%d",
+					test.expManifestCalls, client.manifestCalls)
+			}
+		})
+	}
+}
+
+func TestConfigTimestampsCached(t *testing.T) {
+	client := &fakeClient{
+		tags: []api.ImageTag{
+			{Tag: "a", SHA: "sha256:aaa", Timestamp: time.Unix(200, 0)},
+			{Tag: "b", SHA: "sha256:bbb", Timestamp: time.Unix(100, 0)},
+		},
+		manifests: map[string]*api.ImageManifest{
+			"sha256:aaa": {Digest: "sha256:aaa", Timestamp: time.Unix(300, 0)},
+			"sha256:bbb": {Digest: "sha256:bbb", Timestamp: time.Unix(400, 0)},
+		},
+	}
+	v := newTestVersion(client, time.Hour, Options{})
+
+	for i := 0; i < 2; i++ {
+		tag, err := v.LatestTagFromImage(context.TODO(), "localhost:5000/version-checker",
+			&api.Options{UseSHA: true, UseConfigTimestamp: true})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if tag.Tag != "b" {
+			t.Errorf("unexpected tag, exp=b got=%s", tag.Tag)
+		}
+	}
+
+	if client.manifestCalls != 2 {
+		t.Errorf("expected manifests to be cached, exp=2 got=%d calls", client.manifestCalls)
+	}
+
+	// The cached listing must not have been modified.
+	tag, err := v.LatestTagFromImage(context.TODO(), "localhost:5000/version-checker", &api.Options{UseSHA: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if tag.Tag != "a" || !tag.Timestamp.Equal(time.Unix(200, 0)) {
+		t.Errorf("unexpected tag, exp=a/%s got=%s/%s", time.Unix(200, 0), tag.Tag, tag.Timestamp)
+	}
+}
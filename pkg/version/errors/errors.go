@@ -17,7 +17,107 @@ func NewVersionErrorNotFound(format string, a ...interface{}) *ErrorVersionNotFo
 	return &ErrorVersionNotFound{fmt.Errorf(format, a...)}
 }
 
+// IsNoVersionFound returns whether no version was found, including where the
+// repository has no tags, no tag is a valid version, or no version matches
+// the options.
 func IsNoVersionFound(err error) bool {
 	var notFound *ErrorVersionNotFound
-	return errors.As(err, &notFound)
+	return errors.As(err, &notFound) || IsEmptyRepository(err) || IsNoSemverTags(err) || IsNoMatchingVersion(err)
+}
+
+// ErrorEmptyRepository is returned when the registry successfully lists the
+// tags of an image, but the repository has none, such as a newly created
+// repository. Failures to list tags are returned as their own error.
+type ErrorEmptyRepository struct {
+	ImageURL string
+}
+
+func NewErrorEmptyRepository(imageURL string) *ErrorEmptyRepository {
+	return &ErrorEmptyRepository{ImageURL: imageURL}
+}
+
+func (e *ErrorEmptyRepository) Error() string {
+	return fmt.Sprintf("no tags found for given image URL: %q", e.ImageURL)
+}
+
+func IsEmptyRepository(err error) bool {
+	var empty *ErrorEmptyRepository
+	return errors.As(err, &empty)
+}
+
+// ErrorNoSemverTags is returned when selecting by version, and no tag of the
+// image is a valid version.
+type ErrorNoSemverTags struct {
+	ImageURL string
+}
+
+func NewErrorNoSemverTags(imageURL string) *ErrorNoSemverTags {
+	return &ErrorNoSemverTags{ImageURL: imageURL}
+}
+
+func (e *ErrorNoSemverTags) Error() string {
+	return fmt.Sprintf("%s: no tags found which are a valid version", e.ImageURL)
+}
+
+func IsNoSemverTags(err error) bool {
+	var noSemver *ErrorNoSemverTags
+	return errors.As(err, &noSemver)
+}
+
+// ErrorNoMatchingVersion is returned when selecting by version, and tags are
+// valid versions, but none pass the option constraints.
+type ErrorNoMatchingVersion struct {
+	error
+}
+
+func NewErrorNoMatchingVersion(format string, a ...interface{}) *ErrorNoMatchingVersion {
+	if len(a) == 0 {
+		return &ErrorNoMatchingVersion{errors.New(format)}
+	}
+
+	return &ErrorNoMatchingVersion{fmt.Errorf(format, a...)}
+}
+
+func IsNoMatchingVersion(err error) bool {
+	var noMatch *ErrorNoMatchingVersion
+	return errors.As(err, &noMatch)
+}
+
+// ErrorCircuitOpen is returned when requests to a registry host are being
+// short-circuited, after too many consecutive failures.
+type ErrorCircuitOpen struct {
+	Host string
+}
+
+func NewErrorCircuitOpen(host string) *ErrorCircuitOpen {
+	return &ErrorCircuitOpen{Host: host}
+}
+
+func (e *ErrorCircuitOpen) Error() string {
+	return fmt.Sprintf("circuit open for registry host %q after consecutive failures", e.Host)
+}
+
+func IsCircuitOpen(err error) bool {
+	var circuitOpen *ErrorCircuitOpen
+	return errors.As(err, &circuitOpen)
+}
+
+// ErrorDowngrade is returned when the selected latest tag is a lower version
+// than the current tag, such as after a registry rollback.
+type ErrorDowngrade struct {
+	Current string
+	Latest  string
+}
+
+func NewErrorDowngrade(current, latest string) *ErrorDowngrade {
+	return &ErrorDowngrade{Current: current, Latest: latest}
+}
+
+func (e *ErrorDowngrade) Error() string {
+	return fmt.Sprintf("selected latest tag %q is a downgrade from current tag %q", e.Latest, e.Current)
+}
+
+func IsDowngrade(err error) bool {
+	var downgrade *ErrorDowngrade
+	return errors.As(err, &downgrade)
 }
@@ -0,0 +1,123 @@
+package version
+
+import (
+	"encoding/json"
+	"net/http"
+	"time"
+
+	"github.com/jetstack/version-checker/pkg/cache"
+)
+
+// cacheStatsResponse is the JSON response of a CacheStatsHandler.
+type cacheStatsResponse struct {
+	Images       cacheStatsJSON               `json:"images"`
+	Manifests    cacheStatsJSON               `json:"manifests"`
+	Registries   map[string]registryStatsJSON `json:"registries"`
+	CachedImages []cachedImageJSON            `json:"cachedImages"`
+}
+
+type cacheStatsJSON struct {
+	Items       int     `json:"items"`
+	Hits        uint64  `json:"hits"`
+	Misses      uint64  `json:"misses"`
+	HitRatio    float64 `json:"hitRatio"`
+	FetchErrors uint64  `json:"fetchErrors"`
+	StaleServed uint64  `json:"staleServed"`
+	Stampedes   uint64  `json:"stampedes"`
+}
+
+type registryStatsJSON struct {
+	Images      int     `json:"images"`
+	Hits        uint64  `json:"hits"`
+	Misses      uint64  `json:"misses"`
+	HitRatio    float64 `json:"hitRatio"`
+	FetchErrors uint64  `json:"fetchErrors"`
+}
+
+// cachedImageJSON is a cached image, where the index is without the identity
+// of the credentials of the image, if scoped by credentials.
+type cachedImageJSON struct {
+	Index                string    `json:"index"`
+	CredentialsScoped    bool      `json:"credentialsScoped,omitempty"`
+	Registry             string    `json:"registry"`
+	Tags                 int       `json:"tags"`
+	CachedAt             time.Time `json:"cachedAt"`
+	ExpiresAt            time.Time `json:"expiresAt"`
+	FetchDurationSeconds float64   `json:"fetchDurationSeconds"`
+	Hits                 uint64    `json:"hits"`
+	Misses               uint64    `json:"misses"`
+	FetchErrors          uint64    `json:"fetchErrors"`
+}
+
+// CacheStatsHandler returns an http.Handler which renders the current image
+// and manifest cache statistics, the image cache counters of each registry
+// client, and the images held in the image cache, as JSON, so that operators
+// may mount it on an admin mux for a quick view of the cache. Only GET and
+// HEAD requests are served.
+func (v *Version) CacheStatsHandler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet && r.Method != http.MethodHead {
+			w.Header().Set("Allow", "GET, HEAD")
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		if err := json.NewEncoder(w).Encode(v.cacheStatsResponse()); err != nil {
+			v.log.Errorf("failed to write cache stats response: %s", err)
+		}
+	})
+}
+
+// cacheStatsResponse returns a snapshot of the caches as a JSON response.
+func (v *Version) cacheStatsResponse() cacheStatsResponse {
+	stats := v.CacheStats()
+	images, registries := v.dumpImageCache()
+
+	resp := cacheStatsResponse{
+		Images:       newCacheStatsJSON(stats.Images),
+		Manifests:    newCacheStatsJSON(stats.Manifests),
+		Registries:   make(map[string]registryStatsJSON, len(registries)),
+		CachedImages: make([]cachedImageJSON, 0, len(images)),
+	}
+
+	for name, registry := range registries {
+		resp.Registries[name] = registryStatsJSON{
+			Images:      registry.Images,
+			Hits:        registry.Hits,
+			Misses:      registry.Misses,
+			HitRatio:    hitRatio(registry.Hits, registry.Misses),
+			FetchErrors: registry.FetchErrors,
+		}
+	}
+
+	for _, image := range images {
+		index, scoped := redactedImageIndex(image.Index)
+		resp.CachedImages = append(resp.CachedImages, cachedImageJSON{
+			Index:                index,
+			CredentialsScoped:    scoped,
+			Registry:             image.Registry,
+			Tags:                 image.Tags,
+			CachedAt:             image.CachedAt,
+			ExpiresAt:            image.ExpiresAt,
+			FetchDurationSeconds: image.FetchDuration.Seconds(),
+			Hits:                 image.Hits,
+			Misses:               image.Misses,
+			FetchErrors:          image.FetchErrors,
+		})
+	}
+
+	return resp
+}
+
+func newCacheStatsJSON(stats cache.Stats) cacheStatsJSON {
+	return cacheStatsJSON{
+		Items:       stats.Items,
+		Hits:        stats.Hits,
+		Misses:      stats.Misses,
+		HitRatio:    hitRatio(stats.Hits, stats.Misses),
+		FetchErrors: stats.FetchErrors,
+		StaleServed: stats.StaleServed,
+		Stampedes:   stats.Stampedes,
+	}
+}
@@ -0,0 +1,174 @@
+package version
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/jetstack/version-checker/pkg/api"
+)
+
+func TestCacheStatsHandler(t *testing.T) {
+	client := &fakeClient{
+		tags: []api.ImageTag{{Tag: "v0.1.0"}, {Tag: "v0.2.0"}},
+	}
+	clock := &fakeClock{now: time.Unix(1600000000, 0)}
+	v := newTestVersion(client, time.Hour, Options{Clock: clock})
+
+	// One miss and one hit of the first image, and one miss of the second.
+	for _, imageURL := range []string{"quay.io/jetstack/foo", "quay.io/jetstack/foo", "quay.io/jetstack/bar"} {
+		if _, err := v.LatestTagFromImage(context.TODO(), imageURL, new(api.Options)); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// Images whose tags failed to be fetched are counted, but not listed.
+	client.err = errors.New("registry unavailable")
+	if _, err := v.LatestTagFromImage(context.TODO(), "quay.io/jetstack/baz", new(api.Options)); err == nil {
+		t.Fatal("expected lookup error, got none")
+	}
+
+	rec := httptest.NewRecorder()
+	v.CacheStatsHandler().ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/debug/cache", nil))
+
+	if rec.Code != http.StatusOK {
+		t.Fatalf("unexpected status code, exp=%d got=%d", http.StatusOK, rec.Code)
+	}
+	if ct := rec.Header().Get("Content-Type"); ct != "application/json" {
+		t.Errorf("unexpected content type, exp=application/json got=%q", ct)
+	}
+
+	// The top level and nested keys of the response.
+	var shape map[string]json.RawMessage
+	if err := json.Unmarshal(rec.Body.Bytes(), &shape); err != nil {
+		t.Fatal(err)
+	}
+	if keys := jsonKeys(t, rec.Body.Bytes()); !reflect.DeepEqual(keys, []string{"cachedImages", "images", "manifests", "registries"}) {
+		t.Errorf("unexpected response keys, got=%v", keys)
+	}
+	if keys := jsonKeys(t, shape["images"]); !reflect.DeepEqual(keys, []string{
+		"fetchErrors", "hitRatio", "hits", "items", "misses", "staleServed", "stampedes",
+	}) {
+		t.Errorf("unexpected image stats keys, got=%v", keys)
+	}
+
+	var resp cacheStatsResponse
+	if err := json.Unmarshal(rec.Body.Bytes(), &resp); err != nil {
+		t.Fatal(err)
+	}
+
+	expImages := cacheStatsJSON{Items: 3, Hits: 1, Misses: 3, HitRatio: 0.25, FetchErrors: 1}
+	if resp.Images != expImages {
+		t.Errorf("unexpected image stats, exp=%+v got=%+v", expImages, resp.Images)
+	}
+
+	expRegistries := map[string]registryStatsJSON{
+		"fake": {Images: 3, Hits: 1, Misses: 3, HitRatio: 0.25, FetchErrors: 1},
+	}
+	if !reflect.DeepEqual(resp.Registries, expRegistries) {
+		t.Errorf("unexpected registry stats, exp=%+v got=%+v", expRegistries, resp.Registries)
+	}
+
+	cachedAt := clock.Now().UTC()
+	expCached := []cachedImageJSON{
+		{
+			Index: "quay.io/jetstack/bar", Registry: "fake", Tags: 2,
+			CachedAt: cachedAt, ExpiresAt: cachedAt.Add(time.Hour),
+			Misses: 1,
+		},
+		{
+			Index: "quay.io/jetstack/foo", Registry: "fake", Tags: 2,
+			CachedAt: cachedAt, ExpiresAt: cachedAt.Add(time.Hour),
+			Hits: 1, Misses: 1,
+		},
+	}
+	for i := range resp.CachedImages {
+		resp.CachedImages[i].CachedAt = resp.CachedImages[i].CachedAt.UTC()
+		resp.CachedImages[i].ExpiresAt = resp.CachedImages[i].ExpiresAt.UTC()
+	}
+	if !reflect.DeepEqual(resp.CachedImages, expCached) {
+		t.Errorf("unexpected cached images, exp=%+v got=%+v", expCached, resp.CachedImages)
+	}
+
+	// Requests other than reads are rejected.
+	rec = httptest.NewRecorder()
+	v.CacheStatsHandler().ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/debug/cache", nil))
+	if rec.Code != http.StatusMethodNotAllowed {
+		t.Errorf("unexpected status code, exp=%d got=%d", http.StatusMethodNotAllowed, rec.Code)
+	}
+}
+
+func TestCacheStatsHandlerCredentials(t *testing.T) {
+	client := &fakeClient{tags: []api.ImageTag{{Tag: "v0.1.0"}}}
+	v := newTestVersion(client, time.Hour, Options{})
+
+	creds := &api.Credentials{Username: "tenant", Password: "hunter2"}
+	ctx := api.ContextWithCredentials(context.TODO(), creds)
+	if _, err := v.LatestTagFromImage(ctx, "quay.io/jetstack/foo", new(api.Options)); err != nil {
+		t.Fatal(err)
+	}
+
+	rec := httptest.NewRecorder()
+	v.CacheStatsHandler().ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/debug/cache", nil))
+
+	// The identity of the credentials must not be served.
+	if identity := credentialsIdentity(creds); strings.Contains(rec.Body.String(), identity) {
+		t.Errorf("expected credentials identity not to be served, got=%s", rec.Body.String())
+	}
+
+	var resp cacheStatsResponse
+	if err := json.Unmarshal(rec.Body.Bytes(), &resp); err != nil {
+		t.Fatal(err)
+	}
+	if len(resp.CachedImages) != 1 {
+		t.Fatalf("unexpected number of cached images, exp=1 got=%d", len(resp.CachedImages))
+	}
+	if image := resp.CachedImages[0]; image.Index != "quay.io/jetstack/foo" || !image.CredentialsScoped {
+		t.Errorf("unexpected cached image, exp=quay.io/jetstack/foo (scoped=true) got=%s (scoped=%t)",
+			image.Index, image.CredentialsScoped)
+	}
+}
+
+func TestCacheStatsHandlerEmpty(t *testing.T) {
+	v := newTestVersion(new(fakeClient), time.Hour, Options{})
+
+	rec := httptest.NewRecorder()
+	v.CacheStatsHandler().ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/debug/cache", nil))
+
+	// Empty caches render empty collections rather than null.
+	var resp map[string]json.RawMessage
+	if err := json.Unmarshal(rec.Body.Bytes(), &resp); err != nil {
+		t.Fatal(err)
+	}
+	if got := string(resp["registries"]); got != "{}" {
+		t.Errorf("unexpected registries, exp={} got=%s", got)
+	}
+	if got := string(resp["cachedImages"]); got != "[]" {
+		t.Errorf("unexpected cached images, exp=[] got=%s", got)
+	}
+}
+
+// jsonKeys returns the sorted keys of the given JSON object.
+func jsonKeys(t *testing.T, data []byte) []string {
+	t.Helper()
+
+	var object map[string]json.RawMessage
+	if err := json.Unmarshal(data, &object); err != nil {
+		t.Fatal(err)
+	}
+
+	var keys []string
+	for key := range object {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	return keys
+}
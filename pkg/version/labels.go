@@ -0,0 +1,67 @@
+package version
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/jetstack/version-checker/pkg/api"
+)
+
+// configFetcher is the cache handler for fetching image configs.
+type configFetcher struct {
+	v *Version
+}
+
+// Fetch returns the image config for a given image URL and reference index,
+// in the form {imageURL}@{reference}.
+func (c *configFetcher) Fetch(ctx context.Context, index string, _ *api.Options) (interface{}, error) {
+	lastAtIndex := strings.LastIndex(index, "@")
+	if lastAtIndex == -1 {
+		return nil, fmt.Errorf("invalid config index: %q", index)
+	}
+
+	return c.v.client.Config(ctx, index[:lastAtIndex], index[lastAtIndex+1:])
+}
+
+// hasConfigLabels returns whether the image config of the given tag has all
+// of the labels required by the options, using the config cache. Tags are
+// referenced by their digest where available.
+func (v *Version) hasConfigLabels(ctx context.Context, imageURL string, opts *api.Options, tag *api.ImageTag) (bool, error) {
+	reference := tag.SHA
+	if len(reference) == 0 {
+		reference = tag.Tag
+	}
+
+	index := imageURL + "@" + reference
+	config, err := v.configCache.Get(ctx, index, index, nil)
+	if err != nil {
+		return false, fmt.Errorf("%s: failed to get image config of %q: %w", imageURL, tag.Tag, err)
+	}
+
+	labels := config.(*api.ImageConfig).Labels
+	for k, val := range opts.RequireConfigLabels {
+		if got, ok := labels[k]; !ok || got != val {
+			return false, nil
+		}
+	}
+
+	return true, nil
+}
+
+// withoutReference will return the given tags without those referencing the
+// same image as the given tag, being those of the same digest, or of the same
+// tag if the tag has no digest.
+func withoutReference(tags []api.ImageTag, tag *api.ImageTag) []api.ImageTag {
+	sha, name := tag.SHA, tag.Tag
+
+	var remaining []api.ImageTag
+	for _, t := range tags {
+		if len(sha) > 0 && t.SHA == sha || len(sha) == 0 && t.Tag == name {
+			continue
+		}
+		remaining = append(remaining, t)
+	}
+
+	return remaining
+}
@@ -0,0 +1,116 @@
+package version
+
+import (
+	"context"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/jetstack/version-checker/pkg/api"
+	versionerrors "github.com/jetstack/version-checker/pkg/version/errors"
+)
+
+func TestRequireConfigLabels(t *testing.T) {
+	newClient := func() *fakeClient {
+		return &fakeClient{
+			tags: []api.ImageTag{
+				{Tag: "v1.0.0", SHA: "sha256:aaa", Timestamp: time.Unix(100, 0)},
+				{Tag: "v1.1.0", SHA: "sha256:bbb", Timestamp: time.Unix(200, 0)},
+				{Tag: "v1.2.0", SHA: "sha256:ccc", Timestamp: time.Unix(300, 0)},
+				{Tag: "v1.2", SHA: "sha256:ccc", Timestamp: time.Unix(300, 0)},
+			},
+			configs: map[string]*api.ImageConfig{
+				"sha256:aaa": {Labels: map[string]string{"quality": "ga", "team": "platform"}},
+				"sha256:bbb": {Labels: map[string]string{"quality": "beta", "team": "platform"}},
+				"sha256:ccc": {},
+			},
+		}
+	}
+
+	tests := map[string]struct {
+		opts        *api.Options
+		expTag      string
+		expNotFound bool
+		expCalls    map[string]int
+	}{
+		"labels not required should not fetch configs": {
+			opts:   new(api.Options),
+			expTag: "v1.2.0",
+		},
+		"required label should skip tags lacking it": {
+			opts:     &api.Options{RequireConfigLabels: map[string]string{"quality": "ga"}},
+			expTag:   "v1.0.0",
+			expCalls: map[string]int{"sha256:ccc": 1, "sha256:bbb": 1, "sha256:aaa": 1},
+		},
+		"required label of another value should skip tags": {
+			opts:     &api.Options{RequireConfigLabels: map[string]string{"quality": "beta"}},
+			expTag:   "v1.1.0",
+			expCalls: map[string]int{"sha256:ccc": 1, "sha256:bbb": 1},
+		},
+		"all required labels should be present": {
+			opts:     &api.Options{RequireConfigLabels: map[string]string{"quality": "beta", "team": "platform"}},
+			expTag:   "v1.1.0",
+			expCalls: map[string]int{"sha256:ccc": 1, "sha256:bbb": 1},
+		},
+		"no tags with the labels should not be found": {
+			opts:        &api.Options{RequireConfigLabels: map[string]string{"quality": "rc"}},
+			expNotFound: true,
+			expCalls:    map[string]int{"sha256:ccc": 1, "sha256:bbb": 1, "sha256:aaa": 1},
+		},
+		"sha should select the latest image with the labels": {
+			opts:     &api.Options{UseSHA: true, RequireConfigLabels: map[string]string{"team": "platform"}},
+			expTag:   "v1.1.0",
+			expCalls: map[string]int{"sha256:ccc": 1, "sha256:bbb": 1},
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			client := newClient()
+			v := newTestVersion(client, time.Hour, Options{})
+
+			tag, err := v.LatestTagFromImage(context.TODO(), "localhost:5000/version-checker", test.opts)
+			if versionerrors.IsNoVersionFound(err) != test.expNotFound {
+				t.Fatalf("unexpected not found error, exp=%t got=%v", test.expNotFound, err)
+			}
+			if !test.expNotFound {
+				if err != nil {
+					t.Fatal(err)
+				}
+				if tag.Tag != test.expTag {
+					t.Errorf("unexpected tag, exp=%q got=%q", test.expTag, tag.Tag)
+				}
+			}
+
+			if !reflect.DeepEqual(client.configCalls, test.expCalls) {
+				t.Errorf("unexpected config calls, exp=%v got=%v", test.expCalls, client.configCalls)
+			}
+		})
+	}
+}
+
+func TestRequireConfigLabelsCached(t *testing.T) {
+	client := &fakeClient{
+		tags: []api.ImageTag{
+			{Tag: "v1.0.0", SHA: "sha256:aaa"},
+			{Tag: "v1.1.0", SHA: "sha256:bbb"},
+		},
+		configs: map[string]*api.ImageConfig{
+			"sha256:aaa": {Labels: map[string]string{"quality": "ga"}},
+			"sha256:bbb": {Labels: map[string]string{"quality": "beta"}},
+		},
+	}
+	v := newTestVersion(client, time.Hour, Options{})
+
+	for _, quality := range []string{"ga", "beta", "ga"} {
+		opts := &api.Options{RequireConfigLabels: map[string]string{"quality": quality}}
+		if _, err := v.LatestTagFromImage(context.TODO(), "localhost:5000/version-checker", opts); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	expCalls := map[string]int{"sha256:aaa": 1, "sha256:bbb": 1}
+	if !reflect.DeepEqual(client.configCalls, expCalls) {
+		t.Errorf("expected configs to be fetched once per digest, exp=%v got=%v", expCalls, client.configCalls)
+	}
+}
@@ -0,0 +1,63 @@
+package version
+
+import (
+	"github.com/sirupsen/logrus"
+
+	"github.com/jetstack/version-checker/pkg/api"
+)
+
+// decision is how the latest tag of an image was selected, as logged.
+type decision string
+
+const (
+	// decisionFloatingTag is the current image of a floating tag.
+	decisionFloatingTag decision = "floating_tag"
+	// decisionPointerTag is the version sharing the digest of a pointer tag.
+	decisionPointerTag decision = "pointer_tag"
+	// decisionSHA is the latest image by timestamp.
+	decisionSHA decision = "sha"
+	// decisionSemver is the latest version.
+	decisionSemver decision = "semver"
+	// decisionSortedSemver is the latest version of a sorted tag listing.
+	decisionSortedSemver decision = "sorted_semver"
+	// decisionTagMapper is the greatest tag mapped by the tag mapper.
+	decisionTagMapper decision = "tag_mapper"
+	// decisionFallbackSHA is the latest image by timestamp of tags without a
+	// version, as no version matched.
+	decisionFallbackSHA decision = "fallback_sha"
+	// decisionCachedResult is a previously selected latest tag.
+	decisionCachedResult decision = "cached_result"
+)
+
+// jsonLogEntry returns a log entry with the same fields, output, level and
+// hooks as the given entry, which formats its logs as JSON.
+func jsonLogEntry(log *logrus.Entry) *logrus.Entry {
+	logger := logrus.New()
+	logger.SetOutput(log.Logger.Out)
+	logger.SetLevel(log.Logger.GetLevel())
+	logger.SetReportCaller(log.Logger.ReportCaller)
+	logger.SetFormatter(new(logrus.JSONFormatter))
+
+	hooks := make(logrus.LevelHooks, len(log.Logger.Hooks))
+	for level, levelHooks := range log.Logger.Hooks {
+		hooks[level] = append([]logrus.Hook(nil), levelHooks...)
+	}
+	logger.ReplaceHooks(hooks)
+
+	return logger.WithFields(log.Data)
+}
+
+// logDecision will log the latest tag selected for the given image URL at
+// debug level.
+func (v *Version) logDecision(imageURL string, d decision, tag *api.ImageTag) {
+	if !v.log.Logger.IsLevelEnabled(logrus.DebugLevel) {
+		return
+	}
+
+	v.log.WithFields(logrus.Fields{
+		"image":    imageURL,
+		"registry": v.client.ClientName(imageURL),
+		"decision": string(d),
+		"tag":      tag.Tag,
+	}).Debug("selected latest tag")
+}
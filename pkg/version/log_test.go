@@ -0,0 +1,69 @@
+package version
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/sirupsen/logrus"
+
+	"github.com/jetstack/version-checker/pkg/api"
+)
+
+func TestJSONLogs(t *testing.T) {
+	var buf bytes.Buffer
+
+	parent := logrus.New()
+	parent.SetOutput(&buf)
+	parent.SetLevel(logrus.DebugLevel)
+	parent.SetFormatter(&logrus.TextFormatter{DisableColors: true})
+
+	v := New(parent.WithField("component", "test"), nil, time.Hour, Options{JSONLogs: true})
+	v.client = &fakeClient{
+		tags: []api.ImageTag{{Tag: "1.2.3"}, {Tag: "1.3.0"}},
+	}
+
+	if _, err := v.LatestTagFromImage(context.TODO(), "localhost:5000/version-checker", new(api.Options)); err != nil {
+		t.Fatal(err)
+	}
+
+	var decision map[string]interface{}
+	for _, line := range strings.Split(strings.TrimSpace(buf.String()), "\n") {
+		var record map[string]interface{}
+		if err := json.Unmarshal([]byte(line), &record); err != nil {
+			t.Fatalf("expected JSON log record, got=%q: %s", line, err)
+		}
+
+		if record["msg"] == "selected latest tag" {
+			decision = record
+		}
+	}
+
+	if decision == nil {
+		t.Fatalf("expected decision log record, got=%q", buf.String())
+	}
+
+	for field, exp := range map[string]string{
+		"component": "test",
+		"module":    "version_getter",
+		"image":     "localhost:5000/version-checker",
+		"registry":  "fake",
+		"decision":  "semver",
+		"tag":       "1.3.0",
+		"level":     "debug",
+	} {
+		if got := decision[field]; got != exp {
+			t.Errorf("unexpected log field %q, exp=%q got=%v", field, exp, got)
+		}
+	}
+
+	// The parent logger's formatter should be unchanged.
+	buf.Reset()
+	parent.Info("parent")
+	if strings.HasPrefix(buf.String(), "{") {
+		t.Errorf("expected parent logger to keep its formatter, got=%q", buf.String())
+	}
+}
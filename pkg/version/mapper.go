@@ -0,0 +1,44 @@
+package version
+
+import (
+	"github.com/jetstack/version-checker/pkg/api"
+)
+
+// latestMapped will return the latest of the given tags by the value of the
+// options tag mapper, restricted to the tags matching the regex, candidate
+// tags, Helm charts, and tags before the before time, if set. Tags of equal
+// value are ordered by timestamp. Returns nil if no tag is mapped.
+func latestMapped(opts *api.Options, tags []api.ImageTag) *api.ImageTag {
+	if len(opts.CandidateTags) > 0 {
+		tags = candidateTags(opts, tags)
+	}
+	if opts.HelmChartsOnly {
+		tags = helmChartTags(tags)
+	}
+	tags = tagsBefore(opts, tags)
+
+	var (
+		latestTag   *api.ImageTag
+		latestValue api.Comparable
+	)
+
+	for i := range tags {
+		if opts.RegexMatcher != nil && !opts.RegexMatcher.MatchString(tags[i].Tag) {
+			continue
+		}
+
+		value, ok := opts.TagMapper(tags[i].Tag)
+		if !ok {
+			continue
+		}
+
+		if latestTag == nil ||
+			latestValue.Less(value) ||
+			(!value.Less(latestValue) && tags[i].Timestamp.After(latestTag.Timestamp)) {
+			latestTag = &tags[i]
+			latestValue = value
+		}
+	}
+
+	return latestTag
+}
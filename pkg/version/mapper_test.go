@@ -0,0 +1,165 @@
+package version
+
+import (
+	"context"
+	"regexp"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/jetstack/version-checker/pkg/api"
+	versionerrors "github.com/jetstack/version-checker/pkg/version/errors"
+)
+
+// dateBuild is the date and build number of a YYYYMMDD-buildN-gitsha tag.
+type dateBuild struct {
+	date, build int64
+}
+
+func (d dateBuild) Less(other api.Comparable) bool {
+	o := other.(dateBuild)
+	if d.date != o.date {
+		return d.date < o.date
+	}
+
+	return d.build < o.build
+}
+
+var dateBuildRegex = regexp.MustCompile(`^(\d{8})-build(\d+)-[0-9a-f]+$`)
+
+// dateBuildMapper maps YYYYMMDD-buildN-gitsha tags onto their date and build
+// number.
+func dateBuildMapper(tag string) (api.Comparable, bool) {
+	match := dateBuildRegex.FindStringSubmatch(tag)
+	if match == nil {
+		return nil, false
+	}
+
+	date, _ := strconv.ParseInt(match[1], 10, 64)
+	build, _ := strconv.ParseInt(match[2], 10, 64)
+
+	return dateBuild{date: date, build: build}, true
+}
+
+func TestTagMapper(t *testing.T) {
+	tags := []api.ImageTag{
+		{Tag: "20240101-build9-abc123", SHA: "sha256:aaa", Timestamp: time.Unix(100, 0)},
+		{Tag: "20240102-build2-def456", SHA: "sha256:bbb", Timestamp: time.Unix(200, 0)},
+		{Tag: "20240102-build10-0a1b2c", SHA: "sha256:ccc", Timestamp: time.Unix(300, 0)},
+		{Tag: "20231231-build99-fedcba", SHA: "sha256:ddd", Timestamp: time.Unix(400, 0)},
+		{Tag: "v9.9.9", SHA: "sha256:eee", Timestamp: time.Unix(500, 0)},
+		{Tag: "latest", SHA: "sha256:ccc", Timestamp: time.Unix(300, 0)},
+	}
+
+	timep := func(sec int64) *time.Time {
+		t := time.Unix(sec, 0)
+		return &t
+	}
+
+	tests := map[string]struct {
+		opts        *api.Options
+		expTag      string
+		expNotFound bool
+	}{
+		"greatest date and build should be selected over semver": {
+			opts:   &api.Options{TagMapper: dateBuildMapper},
+			expTag: "20240102-build10-0a1b2c",
+		},
+		"regex should restrict mapped tags": {
+			opts:   &api.Options{TagMapper: dateBuildMapper, RegexMatcher: regexp.MustCompile(`-build[0-9]-`)},
+			expTag: "20240102-build2-def456",
+		},
+		"candidate tags should restrict mapped tags": {
+			opts:   &api.Options{TagMapper: dateBuildMapper, CandidateTags: []string{"20240101-build9-abc123", "20231231-build99-fedcba"}},
+			expTag: "20240101-build9-abc123",
+		},
+		"before time should restrict mapped tags": {
+			opts:   &api.Options{TagMapper: dateBuildMapper, BeforeTime: timep(250)},
+			expTag: "20240102-build2-def456",
+		},
+		"sha should take precedence over the mapper": {
+			opts:   &api.Options{TagMapper: dateBuildMapper, UseSHA: true},
+			expTag: "v9.9.9",
+		},
+		"no mapped tags should not be found": {
+			opts: &api.Options{TagMapper: func(string) (api.Comparable, bool) {
+				return nil, false
+			}},
+			expNotFound: true,
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			v := newTestVersion(&fakeClient{tags: tags}, time.Hour, Options{})
+
+			tag, err := v.LatestTagFromImage(context.TODO(), "localhost:5000/version-checker", test.opts)
+			if versionerrors.IsNoVersionFound(err) != test.expNotFound {
+				t.Fatalf("unexpected not found error, exp=%t got=%v", test.expNotFound, err)
+			}
+			if test.expNotFound {
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if tag.Tag != test.expTag {
+				t.Errorf("unexpected tag, exp=%q got=%q", test.expTag, tag.Tag)
+			}
+		})
+	}
+}
+
+func TestTagMapperEqualValues(t *testing.T) {
+	// Tags of the same date and build are ordered by timestamp.
+	tags := []api.ImageTag{
+		{Tag: "20240101-build1-aaa", Timestamp: time.Unix(100, 0)},
+		{Tag: "20240101-build1-bbb", Timestamp: time.Unix(300, 0)},
+		{Tag: "20240101-build1-ccc", Timestamp: time.Unix(200, 0)},
+	}
+
+	tag := latestMapped(&api.Options{TagMapper: dateBuildMapper}, tags)
+	if tag == nil || tag.Tag != "20240101-build1-bbb" {
+		t.Errorf("unexpected tag, exp=20240101-build1-bbb got=%v", tag)
+	}
+}
+
+func TestTagMapperResultsNotCached(t *testing.T) {
+	client := &fakeClient{
+		tags: []api.ImageTag{
+			{Tag: "20240101-build1-abc"},
+			{Tag: "20240102-build1-def"},
+		},
+	}
+
+	v := newTestVersion(client, time.Hour, Options{CacheResults: true})
+
+	// Mappers which differ only in behaviour must not share results.
+	earliest := func(tag string) (api.Comparable, bool) {
+		value, ok := dateBuildMapper(tag)
+		if !ok {
+			return nil, false
+		}
+
+		d := value.(dateBuild)
+		return dateBuild{date: -d.date, build: -d.build}, true
+	}
+
+	for mapper, exp := range map[string]struct {
+		mapper api.TagMapper
+		expTag string
+	}{
+		"latest":   {mapper: dateBuildMapper, expTag: "20240102-build1-def"},
+		"earliest": {mapper: earliest, expTag: "20240101-build1-abc"},
+	} {
+		tag, err := v.LatestTagFromImage(context.TODO(), "localhost:5000/version-checker", &api.Options{TagMapper: exp.mapper})
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if tag.Tag != exp.expTag {
+			t.Errorf("%s: unexpected tag, exp=%q got=%q", mapper, exp.expTag, tag.Tag)
+		}
+	}
+}
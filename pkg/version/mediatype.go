@@ -0,0 +1,46 @@
+package version
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/jetstack/version-checker/pkg/api"
+	clienterrors "github.com/jetstack/version-checker/pkg/client/errors"
+)
+
+// manifestExists returns whether the manifest of the given tag can be
+// fetched, using the manifest cache. Errors other than the manifest not being
+// found are returned.
+func (v *Version) manifestExists(ctx context.Context, imageURL string, tag *api.ImageTag) (bool, error) {
+	_, err := v.manifest(ctx, imageURL, *tag)
+	if clienterrors.IsImageNotFound(err) {
+		return false, nil
+	}
+	if err != nil {
+		return false, fmt.Errorf("%s: failed to get manifest of %q: %w", imageURL, tag.Tag, err)
+	}
+
+	return true, nil
+}
+
+// manifestMediaType returns the media type of the manifest of the given tag,
+// using the manifest cache.
+func (v *Version) manifestMediaType(ctx context.Context, imageURL string, tag *api.ImageTag) (string, error) {
+	manifest, err := v.manifest(ctx, imageURL, *tag)
+	if err != nil {
+		return "", fmt.Errorf("%s: failed to get manifest of %q: %w", imageURL, tag.Tag, err)
+	}
+
+	return manifest.MediaType, nil
+}
+
+// containsString returns whether the given strings contain s.
+func containsString(strs []string, s string) bool {
+	for _, str := range strs {
+		if str == s {
+			return true
+		}
+	}
+
+	return false
+}
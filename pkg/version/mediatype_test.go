@@ -0,0 +1,204 @@
+package version
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/jetstack/version-checker/pkg/api"
+	versionerrors "github.com/jetstack/version-checker/pkg/version/errors"
+)
+
+const (
+	dockerSchema1MediaType = "application/vnd.docker.distribution.manifest.v1+prettyjws"
+	dockerSchema2MediaType = "application/vnd.docker.distribution.manifest.v2+json"
+)
+
+func TestRequireManifestMediaTypes(t *testing.T) {
+	oci := []string{api.OCIImageIndexMediaType, api.OCIImageManifestMediaType}
+
+	newClient := func() *fakeClient {
+		return &fakeClient{
+			tags: []api.ImageTag{
+				{Tag: "v1.0.0", SHA: "sha256:aaa", Timestamp: time.Unix(100, 0)},
+				{Tag: "v1.1.0", SHA: "sha256:bbb", Timestamp: time.Unix(200, 0)},
+				{Tag: "v1.2.0", SHA: "sha256:ccc", Timestamp: time.Unix(300, 0)},
+				{Tag: "v1.3.0", SHA: "sha256:ddd", Timestamp: time.Unix(400, 0)},
+				{Tag: "v1.3", SHA: "sha256:ddd", Timestamp: time.Unix(400, 0)},
+			},
+			manifests: map[string]*api.ImageManifest{
+				"sha256:aaa": {Digest: "sha256:aaa", MediaType: api.OCIImageIndexMediaType},
+				"sha256:bbb": {Digest: "sha256:bbb", MediaType: api.OCIImageManifestMediaType},
+				"sha256:ccc": {Digest: "sha256:ccc", MediaType: dockerSchema2MediaType},
+				"sha256:ddd": {Digest: "sha256:ddd", MediaType: dockerSchema1MediaType},
+			},
+		}
+	}
+
+	tests := map[string]struct {
+		opts             *api.Options
+		expTag           string
+		expNotFound      bool
+		expManifestCalls int
+	}{
+		"media types not required should not fetch manifests": {
+			opts:   new(api.Options),
+			expTag: "v1.3.0",
+		},
+		"oci should skip legacy docker manifests": {
+			opts:             &api.Options{RequireManifestMediaTypes: oci},
+			expTag:           "v1.1.0",
+			expManifestCalls: 3,
+		},
+		"oci index should skip oci manifests": {
+			opts:             &api.Options{RequireManifestMediaTypes: []string{api.OCIImageIndexMediaType}},
+			expTag:           "v1.0.0",
+			expManifestCalls: 4,
+		},
+		"docker schema 2 should skip schema 1": {
+			opts:             &api.Options{RequireManifestMediaTypes: []string{dockerSchema2MediaType}},
+			expTag:           "v1.2.0",
+			expManifestCalls: 2,
+		},
+		"no tags of the media type should not be found": {
+			opts:             &api.Options{RequireManifestMediaTypes: []string{"application/vnd.unknown"}},
+			expNotFound:      true,
+			expManifestCalls: 4,
+		},
+		"sha should select the latest image of the media type": {
+			opts:             &api.Options{UseSHA: true, RequireManifestMediaTypes: oci},
+			expTag:           "v1.1.0",
+			expManifestCalls: 3,
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			client := newClient()
+			v := newTestVersion(client, time.Hour, Options{})
+
+			tag, err := v.LatestTagFromImage(context.TODO(), "localhost:5000/version-checker", test.opts)
+			if versionerrors.IsNoVersionFound(err) != test.expNotFound {
+				t.Fatalf("unexpected not found error, exp=%t got=%v", test.expNotFound, err)
+			}
+			if !test.expNotFound {
+				if err != nil {
+					t.Fatal(err)
+				}
+				if tag.Tag != test.expTag {
+					t.Errorf("unexpected tag, exp=%q got=%q", test.expTag, tag.Tag)
+				}
+			}
+
+			if client.manifestCalls != test.expManifestCalls {
+				t.Errorf("unexpected manifest calls, exp=%d got=%d", test.expManifestCalls, client.manifestCalls)
+			}
+		})
+	}
+}
+
+func TestRequireManifestMediaTypesTagList(t *testing.T) {
+	v := newTestVersion(new(fakeClient), time.Hour, Options{})
+
+	opts := &api.Options{RequireManifestMediaTypes: []string{api.OCIImageIndexMediaType}}
+	if _, err := v.LatestTagFromTags(opts, []api.ImageTag{{Tag: "v1.0.0"}}); err == nil {
+		t.Error("expected error requiring manifest media types of a tag list")
+	}
+}
+
+func TestVerifyManifest(t *testing.T) {
+	newClient := func() *fakeClient {
+		return &fakeClient{
+			tags: []api.ImageTag{
+				{Tag: "v1.0.0", SHA: "sha256:aaa", Timestamp: time.Unix(100, 0)},
+				{Tag: "v1.1.0", SHA: "sha256:bbb", Timestamp: time.Unix(200, 0)},
+				{Tag: "v1.2.0", SHA: "sha256:ccc", Timestamp: time.Unix(300, 0)},
+			},
+			// The manifest of v1.2.0 has been deleted.
+			manifests: map[string]*api.ImageManifest{
+				"sha256:aaa": {Digest: "sha256:aaa"},
+				"sha256:bbb": {Digest: "sha256:bbb"},
+			},
+		}
+	}
+
+	tests := map[string]struct {
+		opts             *api.Options
+		manifests        map[string]*api.ImageManifest
+		expTag           string
+		expNotFound      bool
+		expManifestCalls int
+	}{
+		"unverified manifests should not be fetched": {
+			opts:   new(api.Options),
+			expTag: "v1.2.0",
+		},
+		"latest version whose manifest is not found should fall back to the next": {
+			opts:             &api.Options{VerifyManifest: true},
+			expTag:           "v1.1.0",
+			expManifestCalls: 2,
+		},
+		"latest sha whose manifest is not found should fall back to the next": {
+			opts:             &api.Options{UseSHA: true, VerifyManifest: true},
+			expTag:           "v1.1.0",
+			expManifestCalls: 2,
+		},
+		"no tags with a manifest should not be found": {
+			opts:             &api.Options{VerifyManifest: true},
+			manifests:        map[string]*api.ImageManifest{},
+			expNotFound:      true,
+			expManifestCalls: 3,
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			client := newClient()
+			if test.manifests != nil {
+				client.manifests = test.manifests
+			}
+			v := newTestVersion(client, time.Hour, Options{})
+
+			tag, err := v.LatestTagFromImage(context.TODO(), "localhost:5000/version-checker", test.opts)
+			if versionerrors.IsNoVersionFound(err) != test.expNotFound {
+				t.Fatalf("unexpected not found error, exp=%t got=%v", test.expNotFound, err)
+			}
+			if !test.expNotFound {
+				if err != nil {
+					t.Fatal(err)
+				}
+				if tag.Tag != test.expTag {
+					t.Errorf("unexpected tag, exp=%q got=%q", test.expTag, tag.Tag)
+				}
+			}
+
+			if client.manifestCalls != test.expManifestCalls {
+				t.Errorf("unexpected manifest calls, exp=%d got=%d", test.expManifestCalls, client.manifestCalls)
+			}
+		})
+	}
+}
+
+func TestVerifyManifestError(t *testing.T) {
+	client := &fakeClient{
+		tags:      []api.ImageTag{{Tag: "v1.0.0", SHA: "sha256:aaa"}},
+		manifests: map[string]*api.ImageManifest{},
+	}
+	v := newTestVersion(&erroringManifestClient{client}, time.Hour, Options{})
+
+	// Failures other than the manifest not being found fail the lookup.
+	_, err := v.LatestTagFromImage(context.TODO(), "localhost:5000/version-checker", &api.Options{VerifyManifest: true})
+	if err == nil || versionerrors.IsNoVersionFound(err) {
+		t.Errorf("expected manifest error, got=%v", err)
+	}
+}
+
+// erroringManifestClient is a fakeClient whose manifest requests fail.
+type erroringManifestClient struct {
+	*fakeClient
+}
+
+func (*erroringManifestClient) Manifest(context.Context, string, string) (*api.ImageManifest, error) {
+	return nil, errors.New("registry unavailable")
+}
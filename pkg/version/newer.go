@@ -0,0 +1,111 @@
+package version
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/jetstack/version-checker/pkg/api"
+	"github.com/jetstack/version-checker/pkg/version/semver"
+)
+
+// LatestAndNewerCount will return the latest tag of the given image URL by
+// version, the same as LatestTagFromImage, along with the number of distinct
+// versions newer than the given current version, up to and including the
+// latest, such as to report how many versions an image is behind. Newer
+// versions are counted from the tags passing the options, where tags of the
+// same version are counted once, and the latest is always counted if newer,
+// even if it is a pre-release selected by UseNewerPreRelease or
+// FallbackToPreRelease. The count is zero if the current version is the
+// latest or newer. Returns an error if the current version is not a valid
+// version, or if selecting by SHA, floating tag, pointer tag or tag mapper,
+// as these have no versions to count.
+func (v *Version) LatestAndNewerCount(ctx context.Context, opts *api.Options, imageURL, current string) (*api.ImageTag, int, error) {
+	opts, err := v.withConstraints(ctx, imageURL, v.lookupOptions(opts))
+	if err != nil {
+		return nil, 0, err
+	}
+	if opts.UseSHA || opts.FloatingTag != nil || opts.PointerTag != nil || opts.TagMapper != nil {
+		return nil, 0, errors.New("cannot count newer versions when selecting by SHA, floating tag, pointer tag or tag mapper")
+	}
+
+	versionIndex, err := versionExtractorIndex(opts)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	// Whether the current version passes the options does not matter, only its
+	// version.
+	currentV, _ := parseTag(opts, versionIndex, current)
+	if currentV == nil || !currentV.IsValid() {
+		return nil, 0, fmt.Errorf("current version %q is not a valid version", current)
+	}
+
+	imageURL, tags, stale, err := v.imageTags(ctx, imageURL, opts)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	latest, err := v.latestVerifiedTag(ctx, imageURL, opts, tags, func(tags []api.ImageTag) (*api.ImageTag, error) {
+		return latestCandidateSemver(opts, tags)
+	})
+	if err != nil {
+		return nil, 0, err
+	}
+	if latest == nil {
+		return nil, 0, noVersionFound(imageURL, opts, tags)
+	}
+
+	count := newerVersionCount(opts, versionIndex, tags, currentV, latest)
+
+	if opts.StripBuildMetadata {
+		latest = stripBuildMetadata(latest)
+	}
+
+	return markStale(latest, stale), count, nil
+}
+
+// newerVersionCount will return the number of distinct versions of the given
+// tags passing the options which are newer than the given current version,
+// and not newer than the latest tag, along with the latest itself.
+func newerVersionCount(opts *api.Options, versionIndex int, tags []api.ImageTag, currentV *semver.SemVer, latest *api.ImageTag) int {
+	latestV, _ := parseTag(opts, versionIndex, latest.Tag)
+	if latestV == nil || !newerVersion(opts, currentV, latestV) {
+		return 0
+	}
+
+	// The latest is counted first, as a pre-release selected as the latest
+	// may not itself pass the options.
+	newer := []*semver.SemVer{latestV}
+	for _, tag := range semverTags(opts, versionIndex, semverCandidates(opts, tags)) {
+		v, ok := parseTag(opts, versionIndex, tag.Tag)
+		if !ok || !newerVersion(opts, currentV, v) || newerVersion(opts, latestV, v) {
+			continue
+		}
+
+		if !containsVersion(opts, newer, v) {
+			newer = append(newer, v)
+		}
+	}
+
+	return len(newer)
+}
+
+// containsVersion returns whether the given versions contain a version equal
+// to the given version, being neither newer than the other.
+func containsVersion(opts *api.Options, versions []*semver.SemVer, v *semver.SemVer) bool {
+	for _, other := range versions {
+		if !newerVersion(opts, other, v) && !newerVersion(opts, v, other) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// newerVersion returns whether version b is newer than version a. Versions
+// with a higher major, minor or patch version are always newer, as versions
+// without metadata are otherwise never less than those with.
+func newerVersion(opts *api.Options, a, b *semver.SemVer) bool {
+	return a.CoreLessThan(b) || versionLessThan(opts, a, b)
+}
@@ -0,0 +1,148 @@
+package version
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/jetstack/version-checker/pkg/api"
+	versionerrors "github.com/jetstack/version-checker/pkg/version/errors"
+)
+
+func TestLatestAndNewerCount(t *testing.T) {
+	tests := map[string]struct {
+		tags        []string
+		opts        *api.Options
+		current     string
+		expLatest   string
+		expCount    int
+		expErr      bool
+		expNotFound bool
+	}{
+		"current latest should have none newer": {
+			tags:      []string{"v0.1.0", "v0.2.0", "latest"},
+			opts:      new(api.Options),
+			current:   "v0.2.0",
+			expLatest: "v0.2.0",
+		},
+		"current newer than the latest should have none newer": {
+			tags:      []string{"v0.1.0", "v0.2.0"},
+			opts:      new(api.Options),
+			current:   "v0.3.0",
+			expLatest: "v0.2.0",
+		},
+		"newer versions should be counted": {
+			tags:      []string{"v0.1.0", "v0.2.0", "v0.9.0", "v1.0.0", "v1.10.0", "latest"},
+			opts:      new(api.Options),
+			current:   "v0.2.0",
+			expLatest: "v1.10.0",
+			expCount:  3,
+		},
+		"current not listed should count versions above it": {
+			tags:      []string{"v0.1.0", "v0.3.0", "v0.4.0"},
+			opts:      new(api.Options),
+			current:   "v0.2.0",
+			expLatest: "v0.4.0",
+			expCount:  2,
+		},
+		"tags of the same version should be counted once": {
+			tags:      []string{"v1.0.0", "1.1.0", "v1.1.0", "v1.2.0"},
+			opts:      new(api.Options),
+			current:   "v1.0.0",
+			expLatest: "v1.2.0",
+			expCount:  2,
+		},
+		"versions filtered by options should not be counted": {
+			tags:      []string{"v0.1.0", "v0.2.0", "v0.3.0", "v1.0.0"},
+			opts:      &api.Options{PinMajor: int64p(0)},
+			current:   "v0.1.0",
+			expLatest: "v0.3.0",
+			expCount:  2,
+		},
+		"pre-releases should not be counted": {
+			tags:      []string{"v1.0.0", "v1.1.0-rc.1", "v1.1.0", "v1.2.0-rc.1"},
+			opts:      new(api.Options),
+			current:   "v1.0.0",
+			expLatest: "v1.1.0",
+			expCount:  1,
+		},
+		"pre-releases should be counted with metadata": {
+			tags:      []string{"v1.0.0-rc.1", "v1.1.0-rc.1", "v1.1.0-rc.2"},
+			opts:      &api.Options{UseMetaData: true},
+			current:   "v1.0.0-rc.1",
+			expLatest: "v1.1.0-rc.2",
+			expCount:  2,
+		},
+		"newer pre-release selected as the latest should be counted": {
+			tags:      []string{"v1.0.0", "v1.1.0", "v1.2.0-rc.0", "v1.2.0-rc.1"},
+			opts:      &api.Options{UseNewerPreRelease: true},
+			current:   "v1.0.0",
+			expLatest: "v1.2.0-rc.1",
+			expCount:  2,
+		},
+		"current pre-release should count its stable version": {
+			tags:      []string{"v1.0.0", "v1.1.0-rc.1", "v1.1.0"},
+			opts:      new(api.Options),
+			current:   "v1.1.0-rc.1",
+			expLatest: "v1.1.0",
+			expCount:  1,
+		},
+		"fallback pre-release should be counted": {
+			tags:      []string{"v1.0.0-rc.1", "v1.0.0-rc.2"},
+			opts:      &api.Options{FallbackToPreRelease: true},
+			current:   "v1.0.0-rc.1",
+			expLatest: "v1.0.0-rc.2",
+			expCount:  1,
+		},
+		"invalid current version should error": {
+			tags:    []string{"v0.1.0"},
+			opts:    new(api.Options),
+			current: "latest",
+			expErr:  true,
+		},
+		"selecting by SHA should error": {
+			tags:    []string{"v0.1.0"},
+			opts:    &api.Options{UseSHA: true},
+			current: "v0.1.0",
+			expErr:  true,
+		},
+		"no candidates should not be found": {
+			tags:        []string{"v0.1.0", "latest"},
+			opts:        &api.Options{PinMajor: int64p(2)},
+			current:     "v0.1.0",
+			expNotFound: true,
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			var tags []api.ImageTag
+			for _, tag := range test.tags {
+				tags = append(tags, api.ImageTag{Tag: tag})
+			}
+
+			v := newTestVersion(&fakeClient{tags: tags}, time.Hour, Options{})
+
+			latest, count, err := v.LatestAndNewerCount(context.TODO(), test.opts, "jetstack/version-checker", test.current)
+			if versionerrors.IsNoVersionFound(err) != test.expNotFound {
+				t.Fatalf("unexpected not found error, exp=%t got=%v", test.expNotFound, err)
+			}
+			if test.expNotFound {
+				return
+			}
+			if (err != nil) != test.expErr {
+				t.Fatalf("unexpected error, exp=%t got=%v", test.expErr, err)
+			}
+			if test.expErr {
+				return
+			}
+
+			if latest.Tag != test.expLatest {
+				t.Errorf("unexpected latest tag, exp=%q got=%q", test.expLatest, latest.Tag)
+			}
+			if count != test.expCount {
+				t.Errorf("unexpected newer count, exp=%d got=%d", test.expCount, count)
+			}
+		})
+	}
+}
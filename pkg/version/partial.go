@@ -0,0 +1,37 @@
+package version
+
+import (
+	"context"
+
+	"github.com/jetstack/version-checker/pkg/api"
+)
+
+// listTags will list the tags of the given image URL. If PartialTagPages is
+// enabled, tags are listed page by page, where the tags of the pages listed
+// before a page fails are returned with Truncated set, along with the error
+// of the failed page. Otherwise, the tags are listed in full, or fail.
+func (v *Version) listTags(ctx context.Context, imageURL string) (tags []api.ImageTag, pageErr, err error) {
+	if !v.opts.PartialTagPages {
+		tags, err = v.client.Tags(ctx, imageURL)
+		return tags, nil, err
+	}
+
+	var pages int
+	err = v.client.TagPages(ctx, imageURL, func(page []api.ImageTag) error {
+		tags = append(tags, page...)
+		pages++
+		return nil
+	})
+	if err == nil {
+		return tags, nil, nil
+	}
+	if pages == 0 {
+		return nil, nil, err
+	}
+
+	for i := range tags {
+		tags[i].Truncated = true
+	}
+
+	return tags, err, nil
+}
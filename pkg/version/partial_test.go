@@ -0,0 +1,103 @@
+package version
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/jetstack/version-checker/pkg/api"
+	clienterrors "github.com/jetstack/version-checker/pkg/client/errors"
+	versionerrors "github.com/jetstack/version-checker/pkg/version/errors"
+)
+
+func TestPartialTagPages(t *testing.T) {
+	pageErr := errors.New("page 3 failed")
+
+	pages := [][]api.ImageTag{
+		{{Tag: "v1.0.0"}, {Tag: "v1.1.0"}},
+		{{Tag: "v1.2.0"}},
+	}
+
+	tests := map[string]struct {
+		partial      bool
+		pages        [][]api.ImageTag
+		pagesErr     error
+		expTag       string
+		expTruncated bool
+		expErr       error
+	}{
+		"strict listing should fail on a later page": {
+			pages:    pages,
+			pagesErr: pageErr,
+			expErr:   pageErr,
+		},
+		"partial listing should select from the pages listed before a page failed": {
+			partial:      true,
+			pages:        pages,
+			pagesErr:     pageErr,
+			expTag:       "v1.2.0",
+			expTruncated: true,
+		},
+		"partial listing should fail on the first page": {
+			partial: true,
+			expErr:  pageErr,
+		},
+		"partial listing of all pages should not be truncated": {
+			partial: true,
+			pages:   pages,
+			expTag:  "v1.2.0",
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			client := &fakeClient{pages: test.pages, pagesErr: test.pagesErr}
+			if test.pages == nil {
+				// The first page fails.
+				client.err = pageErr
+			}
+			v := newTestVersion(client, time.Hour, Options{PartialTagPages: test.partial})
+
+			tag, err := v.LatestTagFromImage(context.TODO(), "localhost:5000/version-checker", nil)
+			if test.expErr != nil {
+				if !errors.Is(err, test.expErr) {
+					t.Fatalf("unexpected error, exp=%v got=%v", test.expErr, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if tag.Tag != test.expTag || tag.Truncated != test.expTruncated {
+				t.Errorf("unexpected tag, exp=%s (truncated=%t) got=%s (truncated=%t)",
+					test.expTag, test.expTruncated, tag.Tag, tag.Truncated)
+			}
+		})
+	}
+}
+
+func TestPartialTagPagesCircuitBreaker(t *testing.T) {
+	client := &fakeClient{
+		pages: [][]api.ImageTag{{{Tag: "v1.0.0"}}},
+		pagesErr: clienterrors.NewErrorDecode("localhost:5000", http.StatusBadGateway, []byte("<html>"),
+			errors.New("page 2 failed")),
+	}
+	v := newTestVersion(client, time.Hour, Options{
+		PartialTagPages:         true,
+		CircuitBreakerThreshold: 1,
+		CircuitBreakerCooldown:  time.Hour,
+	})
+
+	if _, err := v.LatestTagFromImage(context.TODO(), "localhost:5000/version-checker", nil); err != nil {
+		t.Fatal(err)
+	}
+
+	// The page failed by the registry counts as a failure of the host.
+	_, err := v.LatestTagFromImage(context.TODO(), "localhost:5000/other", nil)
+	if !versionerrors.IsCircuitOpen(err) {
+		t.Errorf("expected circuit open error, got=%v", err)
+	}
+}
@@ -0,0 +1,34 @@
+package version
+
+import (
+	"github.com/jetstack/version-checker/pkg/api"
+)
+
+// platformTags will return the given tags of images of the platform of the
+// options. Tags are listed once per platform image, so tags of other
+// platforms are removed. Tags without a platform are kept if no listing of
+// the same tag has a platform, as the platforms of the tag are unknown.
+func platformTags(opts *api.Options, tags []api.ImageTag) []api.ImageTag {
+	hasPlatform := make(map[string]bool)
+	for _, tag := range tags {
+		if len(tag.Architecture) > 0 || len(tag.OS) > 0 {
+			hasPlatform[tag.Tag] = true
+		}
+	}
+
+	var matched []api.ImageTag
+	for _, tag := range tags {
+		if !hasPlatform[tag.Tag] || platformMatches(opts, tag) {
+			matched = append(matched, tag)
+		}
+	}
+
+	return matched
+}
+
+// platformMatches returns whether the platform of the given tag is that of
+// the options, where an unset architecture or OS matches any.
+func platformMatches(opts *api.Options, tag api.ImageTag) bool {
+	return (len(opts.Architecture) == 0 || tag.Architecture == opts.Architecture) &&
+		(len(opts.OS) == 0 || tag.OS == opts.OS)
+}
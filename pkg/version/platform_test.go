@@ -0,0 +1,94 @@
+package version
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/jetstack/version-checker/pkg/api"
+	versionerrors "github.com/jetstack/version-checker/pkg/version/errors"
+)
+
+func TestPlatformTags(t *testing.T) {
+	tags := []api.ImageTag{
+		{Tag: "v1.1.0", SHA: "sha256:110-amd64", OS: "linux", Architecture: "amd64"},
+		{Tag: "v1.1.0", SHA: "sha256:110-arm64", OS: "linux", Architecture: "arm64"},
+		{Tag: "v1.2.0", SHA: "sha256:120-amd64", OS: "linux", Architecture: "amd64"},
+		{Tag: "v1.2.0", SHA: "sha256:120-arm64", OS: "linux", Architecture: "arm64"},
+		{Tag: "v1.3.0", SHA: "sha256:130-amd64", OS: "linux", Architecture: "amd64"},
+		{Tag: "v1.3.0", SHA: "sha256:130-windows", OS: "windows", Architecture: "amd64"},
+	}
+
+	tests := map[string]struct {
+		tags        []api.ImageTag
+		opts        *api.Options
+		expTag      string
+		expSHA      string
+		expNotFound bool
+	}{
+		"no platform should select the latest version": {
+			tags:   tags,
+			opts:   new(api.Options),
+			expTag: "v1.3.0",
+			expSHA: "sha256:130-amd64",
+		},
+		"architecture dropped by the latest version should select the older version": {
+			tags:   tags,
+			opts:   &api.Options{Architecture: "arm64"},
+			expTag: "v1.2.0",
+			expSHA: "sha256:120-arm64",
+		},
+		"architecture and os should select the image of the platform": {
+			tags:   tags,
+			opts:   &api.Options{Architecture: "arm64", OS: "linux"},
+			expTag: "v1.2.0",
+			expSHA: "sha256:120-arm64",
+		},
+		"os should select the image of the os": {
+			tags:   tags,
+			opts:   &api.Options{OS: "windows"},
+			expTag: "v1.3.0",
+			expSHA: "sha256:130-windows",
+		},
+		"platform of no tag should not be found": {
+			tags:        tags,
+			opts:        &api.Options{Architecture: "s390x"},
+			expNotFound: true,
+		},
+		"tags without a platform should not be skipped": {
+			tags: append([]api.ImageTag{
+				{Tag: "v1.4.0", SHA: "sha256:140"},
+			}, tags...),
+			opts:   &api.Options{Architecture: "arm64"},
+			expTag: "v1.4.0",
+			expSHA: "sha256:140",
+		},
+		"pins should select the latest pinned version of the platform": {
+			tags:   tags,
+			opts:   &api.Options{Architecture: "arm64", PinMinor: int64p(1), PinMajor: int64p(1)},
+			expTag: "v1.1.0",
+			expSHA: "sha256:110-arm64",
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			v := newTestVersion(&fakeClient{tags: test.tags}, time.Hour, Options{})
+
+			tag, err := v.LatestTagFromImage(context.TODO(), "localhost:5000/version-checker", test.opts)
+			if versionerrors.IsNoVersionFound(err) != test.expNotFound {
+				t.Fatalf("unexpected not found error, exp=%t got=%v", test.expNotFound, err)
+			}
+			if test.expNotFound {
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if tag.Tag != test.expTag || tag.SHA != test.expSHA {
+				t.Errorf("unexpected tag, exp=%s@%s got=%s@%s", test.expTag, test.expSHA, tag.Tag, tag.SHA)
+			}
+		})
+	}
+}
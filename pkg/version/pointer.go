@@ -0,0 +1,54 @@
+package version
+
+import (
+	"context"
+
+	"github.com/jetstack/version-checker/pkg/api"
+	versionerrors "github.com/jetstack/version-checker/pkg/version/errors"
+)
+
+// ResolveTagDigest will return the image of the given tag of the image URL,
+// such as a pointer tag, e.g. current, in order to resolve its digest. If the
+// tag is listed for multiple platforms, the most recent is returned. Returns
+// an ErrorVersionNotFound if the tag does not exist.
+func (v *Version) ResolveTagDigest(ctx context.Context, imageURL, tag string) (*api.ImageTag, error) {
+	tagsI, err := v.imageCache.Get(ctx, ScopedImageIndex(ctx, imageURL), imageURL, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	imageTag := namedTag(tagsI.([]api.ImageTag), tag)
+	if imageTag == nil {
+		return nil, versionerrors.NewVersionErrorNotFound("%s: failed to find tag %q", imageURL, tag)
+	}
+
+	// Copy so the cached tags cannot be modified.
+	resolved := *imageTag
+	return &resolved, nil
+}
+
+// pointerTag will return the latest version of the given tags sharing a
+// digest with the pointer tag of the options, on any platform. The pointer
+// tag itself is never returned. Returns nil if the pointer tag does not
+// exist, or no version passing the options shares its digest.
+func pointerTag(opts *api.Options, tags []api.ImageTag) (*api.ImageTag, error) {
+	digests := make(map[string]bool)
+	for _, tag := range tags {
+		if tag.Tag == *opts.PointerTag && len(tag.SHA) > 0 {
+			digests[tag.SHA] = true
+		}
+	}
+
+	if len(digests) == 0 {
+		return nil, nil
+	}
+
+	var aliases []api.ImageTag
+	for _, tag := range tags {
+		if tag.Tag != *opts.PointerTag && digests[tag.SHA] {
+			aliases = append(aliases, tag)
+		}
+	}
+
+	return latestSemver(opts, aliases)
+}
@@ -0,0 +1,109 @@
+package version
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/jetstack/version-checker/pkg/api"
+	versionerrors "github.com/jetstack/version-checker/pkg/version/errors"
+)
+
+func TestPointerTag(t *testing.T) {
+	client := &fakeClient{
+		tags: []api.ImageTag{
+			{Tag: "v1.1.0", SHA: "sha256:aaa", Timestamp: time.Unix(100, 0)},
+			{Tag: "v1.2.0", SHA: "sha256:bbb", Timestamp: time.Unix(200, 0)},
+			{Tag: "1.2", SHA: "sha256:bbb", Timestamp: time.Unix(200, 0)},
+			{Tag: "v1.2.0-rc.1", SHA: "sha256:bbb", Timestamp: time.Unix(150, 0)},
+			{Tag: "current", SHA: "sha256:bbb", Timestamp: time.Unix(250, 0)},
+			{Tag: "v1.3.0", SHA: "sha256:ccc", Timestamp: time.Unix(300, 0)},
+			{Tag: "edge", SHA: "sha256:ddd", Timestamp: time.Unix(400, 0)},
+			{Tag: "unversioned", SHA: "sha256:eee", Timestamp: time.Unix(500, 0)},
+			{Tag: "nightly", SHA: "sha256:eee", Timestamp: time.Unix(500, 0)},
+		},
+	}
+
+	v := newTestVersion(client, time.Hour, Options{CacheResults: true})
+
+	tests := map[string]struct {
+		pointerTag  string
+		opts        api.Options
+		expTag      string
+		expNotFound bool
+	}{
+		"pointer tag should resolve the version sharing its digest": {
+			pointerTag: "current",
+			expTag:     "v1.2.0",
+		},
+		"other options should filter the versions sharing the digest": {
+			pointerTag: "current",
+			opts:       api.Options{PreReleaseChannel: "rc"},
+			expTag:     "v1.2.0-rc.1",
+		},
+		"pointer tag should never select itself": {
+			pointerTag:  "v1.3.0",
+			expNotFound: true,
+		},
+		"pointer tag without a version sharing its digest should not be found": {
+			pointerTag:  "edge",
+			expNotFound: true,
+		},
+		"pointer tag sharing its digest with only unversioned tags should not be found": {
+			pointerTag:  "nightly",
+			expNotFound: true,
+		},
+		"missing pointer tag should not be found": {
+			pointerTag:  "stable",
+			expNotFound: true,
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			opts := test.opts
+			pointerTag := test.pointerTag
+			opts.PointerTag = &pointerTag
+
+			tag, err := v.LatestTagFromImage(context.TODO(), "localhost:5000/version-checker", &opts)
+			if versionerrors.IsNoVersionFound(err) != test.expNotFound {
+				t.Fatalf("unexpected not found error, exp=%t got=%v", test.expNotFound, err)
+			}
+			if test.expNotFound {
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if tag.Tag != test.expTag {
+				t.Errorf("unexpected tag, exp=%s got=%s", test.expTag, tag.Tag)
+			}
+		})
+	}
+}
+
+func TestResolveTagDigest(t *testing.T) {
+	client := &fakeClient{
+		tags: []api.ImageTag{
+			{Tag: "v1.2.0", SHA: "sha256:bbb", Timestamp: time.Unix(200, 0)},
+			{Tag: "current", SHA: "sha256:aaa", Timestamp: time.Unix(100, 0), Architecture: "amd64"},
+			{Tag: "current", SHA: "sha256:bbb", Timestamp: time.Unix(200, 0), Architecture: "arm64"},
+		},
+	}
+
+	v := newTestVersion(client, time.Hour, Options{})
+
+	tag, err := v.ResolveTagDigest(context.TODO(), "localhost:5000/version-checker", "current")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if tag.Tag != "current" || tag.SHA != "sha256:bbb" {
+		t.Errorf("unexpected tag, exp=current@sha256:bbb got=%s@%s", tag.Tag, tag.SHA)
+	}
+
+	_, err = v.ResolveTagDigest(context.TODO(), "localhost:5000/version-checker", "stable")
+	if !versionerrors.IsNoVersionFound(err) {
+		t.Errorf("expected no version found error, got: %v", err)
+	}
+}
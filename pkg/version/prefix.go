@@ -0,0 +1,50 @@
+package version
+
+import (
+	"context"
+	"regexp"
+	"regexp/syntax"
+
+	"github.com/jetstack/version-checker/pkg/api"
+)
+
+// withTagPrefix returns the given context carrying the literal prefix of the
+// options regex, if the options select the latest tag from only the tags
+// matching the regex. Selecting by SHA, floating tag, pointer tag and the
+// fallback to SHA consider tags without matching the regex, so never carry a
+// prefix.
+func withTagPrefix(ctx context.Context, opts *api.Options) context.Context {
+	if opts.RegexMatcher == nil || opts.UseSHA || opts.FloatingTag != nil || opts.PointerTag != nil || opts.FallbackToSHA {
+		return ctx
+	}
+
+	if prefix, ok := regexPrefix(opts.RegexMatcher); ok {
+		return api.ContextWithTagPrefix(ctx, prefix)
+	}
+
+	return ctx
+}
+
+// regexPrefix returns the literal prefix which every match of the given regex
+// begins with, if the regex is anchored to the start of the text and begins
+// with a case sensitive literal.
+// e.g. ^v1\.2\.\d+$ -> v1.2.
+func regexPrefix(re *regexp.Regexp) (string, bool) {
+	parsed, err := syntax.Parse(re.String(), syntax.Perl)
+	if err != nil {
+		return "", false
+	}
+	parsed = parsed.Simplify()
+
+	if parsed.Op != syntax.OpConcat || len(parsed.Sub) < 2 {
+		return "", false
+	}
+
+	begin, literal := parsed.Sub[0], parsed.Sub[1]
+	if begin.Op != syntax.OpBeginText || literal.Op != syntax.OpLiteral ||
+		literal.Flags&syntax.FoldCase != 0 {
+		return "", false
+	}
+
+	return string(literal.Rune), true
+}
@@ -0,0 +1,151 @@
+package version
+
+import (
+	"context"
+	"regexp"
+	"testing"
+	"time"
+
+	"github.com/jetstack/version-checker/pkg/api"
+)
+
+// prefixClient is a registryClient which records the tag prefix carried by
+// the context of each tags request.
+type prefixClient struct {
+	fakeClient
+
+	prefixes []string
+}
+
+func (p *prefixClient) Tags(ctx context.Context, imageURL string) ([]api.ImageTag, error) {
+	prefix, _ := api.TagPrefixFromContext(ctx)
+	p.prefixes = append(p.prefixes, prefix)
+
+	return p.fakeClient.Tags(ctx, imageURL)
+}
+
+func TestRegexPrefix(t *testing.T) {
+	tests := map[string]struct {
+		regex     string
+		expPrefix string
+		expOK     bool
+	}{
+		"anchored literal should be a prefix": {
+			regex:     `^v1\.2\.`,
+			expPrefix: "v1.2.",
+			expOK:     true,
+		},
+		"anchored literal followed by a pattern should be a prefix": {
+			regex:     `^v1\.2\.\d+$`,
+			expPrefix: "v1.2.",
+			expOK:     true,
+		},
+		"unanchored literal should not be a prefix": {
+			regex: `v1\.2\.`,
+		},
+		"case insensitive literal should not be a prefix": {
+			regex: `(?i)^v1\.2\.`,
+		},
+		"alternation should not be a prefix": {
+			regex: `^v1\.|^v2\.`,
+		},
+		"anchored group should not be a prefix": {
+			regex: `^(v1|v2)\.`,
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			prefix, ok := regexPrefix(regexp.MustCompile(test.regex))
+			if prefix != test.expPrefix || ok != test.expOK {
+				t.Errorf("unexpected prefix, exp=%q,%t got=%q,%t", test.expPrefix, test.expOK, prefix, ok)
+			}
+		})
+	}
+}
+
+func TestTagPrefixFiltering(t *testing.T) {
+	tests := map[string]struct {
+		disabled  bool
+		opts      *api.Options
+		expPrefix string
+		expTag    string
+	}{
+		"prefix regex should be pushed down": {
+			opts:      &api.Options{RegexMatcher: regexp.MustCompile(`^v1\.2\.`)},
+			expPrefix: "v1.2.",
+			expTag:    "v1.2.1",
+		},
+		"non-prefix regex should not be pushed down": {
+			opts:   &api.Options{RegexMatcher: regexp.MustCompile(`\.2\.`)},
+			expTag: "v1.2.1",
+		},
+		"no regex should not be pushed down": {
+			opts:   new(api.Options),
+			expTag: "v1.3.0",
+		},
+		"sha should not be pushed down": {
+			opts:   &api.Options{RegexMatcher: regexp.MustCompile(`^v1\.2\.`), UseSHA: true},
+			expTag: "v1.3.0",
+		},
+		"disabled filtering should not be pushed down": {
+			disabled: true,
+			opts:     &api.Options{RegexMatcher: regexp.MustCompile(`^v1\.2\.`)},
+			expTag:   "v1.2.1",
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			// The tags are not filtered by the registry, so the regex must
+			// still be applied.
+			client := &prefixClient{
+				fakeClient: fakeClient{
+					tags: []api.ImageTag{
+						{Tag: "v1.2.0", Timestamp: time.Unix(100, 0)},
+						{Tag: "v1.2.1", Timestamp: time.Unix(200, 0)},
+						{Tag: "v1.3.0", Timestamp: time.Unix(300, 0)},
+					},
+				},
+			}
+			v := newTestVersion(client, time.Hour, Options{TagPrefixFiltering: !test.disabled})
+
+			tag, err := v.LatestTagFromImage(context.TODO(), "localhost:5000/version-checker", test.opts)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if tag.Tag != test.expTag {
+				t.Errorf("unexpected tag, exp=%q got=%q", test.expTag, tag.Tag)
+			}
+			if len(client.prefixes) != 1 || client.prefixes[0] != test.expPrefix {
+				t.Errorf("unexpected tag prefixes, exp=[%s] got=%q", test.expPrefix, client.prefixes)
+			}
+		})
+	}
+}
+
+func TestTagPrefixFilteringCache(t *testing.T) {
+	client := &prefixClient{
+		fakeClient: fakeClient{
+			tags: []api.ImageTag{{Tag: "v1.2.0"}, {Tag: "v1.3.0"}},
+		},
+	}
+	v := newTestVersion(client, time.Hour, Options{TagPrefixFiltering: true})
+
+	// Tags listed with a prefix must not be served to lookups without.
+	for _, opts := range []*api.Options{
+		{RegexMatcher: regexp.MustCompile(`^v1\.2\.`)},
+		new(api.Options),
+		{RegexMatcher: regexp.MustCompile(`^v1\.2\.`)},
+	} {
+		if _, err := v.LatestTagFromImage(context.TODO(), "localhost:5000/version-checker", opts); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if exp := []string{"v1.2.", ""}; len(client.prefixes) != len(exp) ||
+		client.prefixes[0] != exp[0] || client.prefixes[1] != exp[1] {
+		t.Errorf("unexpected tag prefixes, exp=%q got=%q", exp, client.prefixes)
+	}
+}
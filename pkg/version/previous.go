@@ -0,0 +1,84 @@
+package version
+
+import (
+	"context"
+	"errors"
+
+	"github.com/jetstack/version-checker/pkg/api"
+)
+
+// LatestAndPrevious will return the latest tag of the given image URL by
+// version, the same as LatestTagFromImage, along with the previous tag, being
+// the latest of the tags passing the same options whose version is lower than
+// the latest. Tags of the same version as the latest are never the previous.
+// The previous tag is nil if no other version passes the options. Returns an
+// error if selecting by SHA, floating tag, pointer tag or tag mapper, as these
+// have no previous version, and FallbackToSHA is not used.
+func (v *Version) LatestAndPrevious(ctx context.Context, opts *api.Options, imageURL string) (*api.ImageTag, *api.ImageTag, error) {
+	opts, err := v.withConstraints(ctx, imageURL, v.lookupOptions(opts))
+	if err != nil {
+		return nil, nil, err
+	}
+	if opts.UseSHA || opts.FloatingTag != nil || opts.PointerTag != nil || opts.TagMapper != nil {
+		return nil, nil, errors.New("cannot select the previous version when selecting by SHA, floating tag, pointer tag or tag mapper")
+	}
+
+	imageURL, tags, stale, err := v.imageTags(ctx, imageURL, opts)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	selectTag := func(tags []api.ImageTag) (*api.ImageTag, error) {
+		return latestCandidateSemver(opts, tags)
+	}
+
+	latest, err := v.latestVerifiedTag(ctx, imageURL, opts, tags, selectTag)
+	if err != nil {
+		return nil, nil, err
+	}
+	if latest == nil {
+		return nil, nil, noVersionFound(imageURL, opts, tags)
+	}
+
+	lower, err := lowerVersionTags(opts, tags, latest)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	previous, err := v.latestVerifiedTag(ctx, imageURL, opts, lower, selectTag)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	if opts.StripBuildMetadata {
+		latest = stripBuildMetadata(latest)
+		if previous != nil {
+			previous = stripBuildMetadata(previous)
+		}
+	}
+
+	return markStale(latest, stale), markStale(previous, stale), nil
+}
+
+// lowerVersionTags will return the given tags whose version is lower than the
+// version of the given tag. Versions with a lower major, minor or patch
+// version are always lower, as versions without metadata are otherwise never
+// less than those with.
+func lowerVersionTags(opts *api.Options, tags []api.ImageTag, tag *api.ImageTag) ([]api.ImageTag, error) {
+	versionIndex, err := versionExtractorIndex(opts)
+	if err != nil {
+		return nil, err
+	}
+
+	tagV, _ := parseTag(opts, versionIndex, tag.Tag)
+
+	var lower []api.ImageTag
+	for _, t := range tags {
+		v, _ := parseTag(opts, versionIndex, t.Tag)
+		if v != nil && (v.CoreLessThan(tagV) || versionLessThan(opts, v, tagV)) {
+			lower = append(lower, t)
+		}
+	}
+
+	return lower, nil
+}
@@ -0,0 +1,131 @@
+package version
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/jetstack/version-checker/pkg/api"
+	versionerrors "github.com/jetstack/version-checker/pkg/version/errors"
+)
+
+func TestLatestAndPrevious(t *testing.T) {
+	tests := map[string]struct {
+		tags        []string
+		opts        *api.Options
+		expLatest   string
+		expPrevious string
+		expNotFound bool
+	}{
+		"one candidate should have no previous": {
+			tags:      []string{"v0.1.0", "latest"},
+			opts:      new(api.Options),
+			expLatest: "v0.1.0",
+		},
+		"two candidates should return both": {
+			tags:        []string{"v0.2.0", "v0.1.0"},
+			opts:        new(api.Options),
+			expLatest:   "v0.2.0",
+			expPrevious: "v0.1.0",
+		},
+		"many candidates should return the top two": {
+			tags:        []string{"v0.1.0", "v1.2.0", "v0.9.0", "v1.10.0", "v1.3.0-rc.1", "latest"},
+			opts:        new(api.Options),
+			expLatest:   "v1.10.0",
+			expPrevious: "v1.2.0",
+		},
+		"previous should be filtered by options": {
+			tags:        []string{"v0.1.0", "v1.2.0", "v0.9.0", "v1.10.0"},
+			opts:        &api.Options{PinMajor: int64p(0)},
+			expLatest:   "v0.9.0",
+			expPrevious: "v0.1.0",
+		},
+		"same version as latest should not be previous": {
+			tags:        []string{"1.2.0", "v1.2.0", "v1.1.0"},
+			opts:        new(api.Options),
+			expLatest:   "1.2.0",
+			expPrevious: "v1.1.0",
+		},
+		"only the same version should have no previous": {
+			tags:      []string{"1.2.0", "v1.2.0"},
+			opts:      new(api.Options),
+			expLatest: "1.2.0",
+		},
+		"newer pre-release should have the stable version as previous": {
+			tags:        []string{"v1.1.0", "v1.2.0", "v1.3.0-rc.1"},
+			opts:        &api.Options{UseNewerPreRelease: true},
+			expLatest:   "v1.3.0-rc.1",
+			expPrevious: "v1.2.0",
+		},
+		"newer pre-releases should return the top two pre-releases": {
+			tags:        []string{"v1.2.0", "v1.3.0-rc.0", "v1.3.0-rc.1"},
+			opts:        &api.Options{UseNewerPreRelease: true},
+			expLatest:   "v1.3.0-rc.1",
+			expPrevious: "v1.3.0-rc.0",
+		},
+		"no candidates should not be found": {
+			tags:        []string{"v0.1.0", "latest"},
+			opts:        &api.Options{PinMajor: int64p(2)},
+			expNotFound: true,
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			var tags []api.ImageTag
+			for _, tag := range test.tags {
+				tags = append(tags, api.ImageTag{Tag: tag})
+			}
+
+			v := newTestVersion(&fakeClient{tags: tags}, time.Hour, Options{})
+
+			latest, previous, err := v.LatestAndPrevious(context.TODO(), test.opts, "jetstack/version-checker")
+			if versionerrors.IsNoVersionFound(err) != test.expNotFound {
+				t.Fatalf("unexpected not found error, exp=%t got=%v", test.expNotFound, err)
+			}
+			if test.expNotFound {
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if latest.Tag != test.expLatest {
+				t.Errorf("unexpected latest tag, exp=%q got=%q", test.expLatest, latest.Tag)
+			}
+
+			var gotPrevious string
+			if previous != nil {
+				gotPrevious = previous.Tag
+			}
+			if gotPrevious != test.expPrevious {
+				t.Errorf("unexpected previous tag, exp=%q got=%q", test.expPrevious, gotPrevious)
+			}
+		})
+	}
+}
+
+func TestLatestAndPreviousMatchesLatest(t *testing.T) {
+	client := &fakeClient{
+		tags: []api.ImageTag{{Tag: "v0.1.0"}, {Tag: "v0.3.0"}, {Tag: "v0.2.0"}},
+	}
+
+	v := newTestVersion(client, time.Hour, Options{})
+
+	latest, _, err := v.LatestAndPrevious(context.TODO(), new(api.Options), "jetstack/version-checker")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tag, err := v.LatestTagFromImage(context.TODO(), "jetstack/version-checker", new(api.Options))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if latest.Tag != tag.Tag {
+		t.Errorf("unexpected latest tag compared to LatestTagFromImage, exp=%q got=%q", tag.Tag, latest.Tag)
+	}
+
+	if _, _, err := v.LatestAndPrevious(context.TODO(), &api.Options{UseSHA: true}, "jetstack/version-checker"); err == nil {
+		t.Error("expected error selecting by SHA, got none")
+	}
+}
@@ -0,0 +1,32 @@
+package version
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/jetstack/version-checker/pkg/api"
+)
+
+// promotedTags will return the given tags whose tag also exists in the given
+// promotion image URL, using the image cache. Returns an error if the tags of
+// the promotion image URL could not be fetched.
+func (v *Version) promotedTags(ctx context.Context, promotionURL string, tags []api.ImageTag) ([]api.ImageTag, error) {
+	promotedI, err := v.imageCache.Get(ctx, ScopedImageIndex(ctx, promotionURL), promotionURL, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get tags of promotion image %q: %w", promotionURL, err)
+	}
+
+	promoted := make(map[string]bool)
+	for _, tag := range promotedI.([]api.ImageTag) {
+		promoted[tag.Tag] = true
+	}
+
+	var result []api.ImageTag
+	for _, tag := range tags {
+		if promoted[tag.Tag] {
+			result = append(result, tag)
+		}
+	}
+
+	return result, nil
+}
@@ -0,0 +1,100 @@
+package version
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/jetstack/version-checker/pkg/api"
+	versionerrors "github.com/jetstack/version-checker/pkg/version/errors"
+)
+
+func TestPromotionURL(t *testing.T) {
+	const (
+		staging = "staging.example.com/jetstack/version-checker"
+		prod    = "prod.example.com/jetstack/version-checker"
+		failing = "failing.example.com/jetstack/version-checker"
+		empty   = "empty.example.com/jetstack/version-checker"
+	)
+
+	registryErr := errors.New("connection refused")
+	client := &imagesClient{
+		images: map[string][]api.ImageTag{
+			staging: {
+				{Tag: "v1.0.0", SHA: "sha256:aaa", Timestamp: time.Unix(100, 0)},
+				{Tag: "v1.1.0", SHA: "sha256:bbb", Timestamp: time.Unix(200, 0)},
+				{Tag: "v1.2.0", SHA: "sha256:ccc", Timestamp: time.Unix(300, 0)},
+				{Tag: "v2.0.0-rc.1", SHA: "sha256:ddd", Timestamp: time.Unix(400, 0)},
+			},
+			prod: {
+				{Tag: "v1.0.0", SHA: "sha256:eee", Timestamp: time.Unix(150, 0)},
+				{Tag: "v1.1.0", SHA: "sha256:fff", Timestamp: time.Unix(250, 0)},
+				{Tag: "v2.0.0-rc.1", SHA: "sha256:ggg", Timestamp: time.Unix(450, 0)},
+			},
+			empty: {},
+		},
+		errs: map[string]error{failing: registryErr},
+	}
+
+	stringp := func(s string) *string { return &s }
+
+	tests := map[string]struct {
+		opts        *api.Options
+		expTag      string
+		expSHA      string
+		expNotFound bool
+		expErr      error
+	}{
+		"no promotion should select the latest version": {
+			opts:   new(api.Options),
+			expTag: "v1.2.0",
+			expSHA: "sha256:ccc",
+		},
+		"promotion lagging by a version should select the promoted version": {
+			opts:   &api.Options{PromotionURL: stringp(prod)},
+			expTag: "v1.1.0",
+			expSHA: "sha256:bbb",
+		},
+		"sha should select the latest promoted image": {
+			opts:   &api.Options{PromotionURL: stringp(prod), UseSHA: true},
+			expTag: "v2.0.0-rc.1",
+			expSHA: "sha256:ddd",
+		},
+		"empty promotion image should not be found": {
+			opts:        &api.Options{PromotionURL: stringp(empty)},
+			expNotFound: true,
+		},
+		"failing promotion image should error": {
+			opts:   &api.Options{PromotionURL: stringp(failing)},
+			expErr: registryErr,
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			v := newTestVersion(client, time.Hour, Options{})
+
+			tag, err := v.LatestTagFromImage(context.TODO(), staging, test.opts)
+			if test.expErr != nil {
+				if !errors.Is(err, test.expErr) {
+					t.Errorf("unexpected error, exp=%v got=%v", test.expErr, err)
+				}
+				return
+			}
+			if versionerrors.IsNoVersionFound(err) != test.expNotFound {
+				t.Fatalf("unexpected not found error, exp=%t got=%v", test.expNotFound, err)
+			}
+			if test.expNotFound {
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if tag.Tag != test.expTag || tag.SHA != test.expSHA {
+				t.Errorf("unexpected tag, exp=%s@%s got=%s@%s", test.expTag, test.expSHA, tag.Tag, tag.SHA)
+			}
+		})
+	}
+}
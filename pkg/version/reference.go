@@ -0,0 +1,119 @@
+package version
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/jetstack/version-checker/pkg/api"
+)
+
+var (
+	// domainRegex matches the registry host of a reference, optionally with a
+	// port.
+	domainRegex = regexp.MustCompile(`^(?:[a-zA-Z0-9](?:[a-zA-Z0-9-]*[a-zA-Z0-9])?)(?:\.[a-zA-Z0-9](?:[a-zA-Z0-9-]*[a-zA-Z0-9])?)*(?::[0-9]+)?$`)
+
+	// pathComponentRegex matches a single path component of a repository.
+	pathComponentRegex = regexp.MustCompile(`^[a-z0-9]+(?:(?:[._]|__|-+)[a-z0-9]+)*$`)
+
+	// tagRegex matches the tag of a reference.
+	tagRegex = regexp.MustCompile(`^[a-zA-Z0-9_][a-zA-Z0-9_.-]{0,127}$`)
+
+	// digestRegex matches the digest of a reference.
+	digestRegex = regexp.MustCompile(`^[a-z0-9]+(?:[.+_-][a-z0-9]+)*:[a-zA-Z0-9=_-]{32,}$`)
+)
+
+// Reference is an image reference, split into its repository, and the tag
+// and digest it references.
+// e.g. quay.io/jetstack/version-checker:v0.2.0@sha256:abc...
+type Reference struct {
+	// Repository is the image URL of the reference, without a tag or digest.
+	Repository string
+
+	// Tag is the tag of the reference, if any.
+	Tag string
+
+	// Digest is the digest of the reference, if any.
+	Digest string
+}
+
+// ParseReference will parse the given image reference, in the form
+// repository[:tag][@digest], where the repository may be prefixed with a
+// registry host and port. Returns an error if the reference is malformed.
+func ParseReference(image string) (*Reference, error) {
+	ref := &Reference{Repository: image}
+
+	if i := strings.Index(ref.Repository, "@"); i >= 0 {
+		ref.Repository, ref.Digest = ref.Repository[:i], ref.Repository[i+1:]
+		if !digestRegex.MatchString(ref.Digest) {
+			return nil, fmt.Errorf("invalid image reference %q: invalid digest %q", image, ref.Digest)
+		}
+	}
+
+	// Colons of the host's port are followed by a path.
+	if i := strings.LastIndex(ref.Repository, ":"); i > strings.LastIndex(ref.Repository, "/") {
+		ref.Repository, ref.Tag = ref.Repository[:i], ref.Repository[i+1:]
+		if !tagRegex.MatchString(ref.Tag) {
+			return nil, fmt.Errorf("invalid image reference %q: invalid tag %q", image, ref.Tag)
+		}
+	}
+
+	if err := validateRepository(ref.Repository); err != nil {
+		return nil, fmt.Errorf("invalid image reference %q: %s", image, err)
+	}
+
+	return ref, nil
+}
+
+// validateRepository returns an error if the given repository is not valid.
+// The first path component is the registry host if it contains a "." or ":",
+// or is localhost.
+func validateRepository(repository string) error {
+	if len(repository) == 0 {
+		return fmt.Errorf("empty repository")
+	}
+
+	components := strings.Split(repository, "/")
+	if first := components[0]; len(components) > 1 &&
+		(strings.ContainsAny(first, ".:") || first == "localhost") {
+		if !domainRegex.MatchString(first) {
+			return fmt.Errorf("invalid registry host %q", first)
+		}
+		components = components[1:]
+	}
+
+	for _, component := range components {
+		if !pathComponentRegex.MatchString(component) {
+			return fmt.Errorf("invalid repository path component %q", component)
+		}
+	}
+
+	return nil
+}
+
+// LatestTagFromReference will return the latest tag of the repository of the
+// given image reference, the same as LatestTagFromImage, along with the
+// parsed reference. Only the repository is used to list tags. If the
+// reference has a tag, it is used as the current tag, the same as
+// LatestTagFromCurrent, so that downgrades are rejected if set in the
+// options. Returns an error if the reference is malformed.
+// e.g. nginx:1.2 resolves the latest tag of nginx, against current 1.2
+func (v *Version) LatestTagFromReference(ctx context.Context, image string, opts *api.Options) (*api.ImageTag, *Reference, error) {
+	ref, err := ParseReference(image)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	var tag *api.ImageTag
+	if len(ref.Tag) > 0 {
+		tag, err = v.LatestTagFromCurrent(ctx, ref.Repository, ref.Tag, opts)
+	} else {
+		tag, err = v.LatestTagFromImage(ctx, ref.Repository, opts)
+	}
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return tag, ref, nil
+}
@@ -0,0 +1,175 @@
+package version
+
+import (
+	"context"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/jetstack/version-checker/pkg/api"
+	versionerrors "github.com/jetstack/version-checker/pkg/version/errors"
+)
+
+func TestParseReference(t *testing.T) {
+	digest := "sha256:" + strings.Repeat("a", 64)
+
+	tests := map[string]struct {
+		image  string
+		expRef *Reference
+		expErr string
+	}{
+		"repository only": {
+			image:  "nginx",
+			expRef: &Reference{Repository: "nginx"},
+		},
+		"repository with tag": {
+			image:  "nginx:1.2",
+			expRef: &Reference{Repository: "nginx", Tag: "1.2"},
+		},
+		"repository with digest": {
+			image:  "nginx@" + digest,
+			expRef: &Reference{Repository: "nginx", Digest: digest},
+		},
+		"repository with tag and digest": {
+			image:  "quay.io/jetstack/version-checker:v0.2.0@" + digest,
+			expRef: &Reference{Repository: "quay.io/jetstack/version-checker", Tag: "v0.2.0", Digest: digest},
+		},
+		"host with port and no tag": {
+			image:  "localhost:5000/jetstack/version-checker",
+			expRef: &Reference{Repository: "localhost:5000/jetstack/version-checker"},
+		},
+		"host with port and tag": {
+			image:  "localhost:5000/jetstack/version-checker:v1.0.0-rc.1",
+			expRef: &Reference{Repository: "localhost:5000/jetstack/version-checker", Tag: "v1.0.0-rc.1"},
+		},
+		"empty reference should error": {
+			image:  "",
+			expErr: `invalid image reference "": empty repository`,
+		},
+		"empty tag should error": {
+			image:  "nginx:",
+			expErr: `invalid image reference "nginx:": invalid tag ""`,
+		},
+		"invalid tag should error": {
+			image:  "nginx:-1.2",
+			expErr: `invalid image reference "nginx:-1.2": invalid tag "-1.2"`,
+		},
+		"tag before a path should error": {
+			image:  "nginx:1.2/3",
+			expErr: `invalid image reference "nginx:1.2/3": invalid registry host "nginx:1.2"`,
+		},
+		"invalid digest should error": {
+			image:  "nginx@sha256:abc",
+			expErr: `invalid image reference "nginx@sha256:abc": invalid digest "sha256:abc"`,
+		},
+		"upper case repository should error": {
+			image:  "quay.io/Jetstack/version-checker",
+			expErr: `invalid image reference "quay.io/Jetstack/version-checker": invalid repository path component "Jetstack"`,
+		},
+		"invalid host should error": {
+			image:  "quay..io/jetstack/version-checker",
+			expErr: `invalid image reference "quay..io/jetstack/version-checker": invalid registry host "quay..io"`,
+		},
+		"empty path component should error": {
+			image:  "quay.io//version-checker",
+			expErr: `invalid image reference "quay.io//version-checker": invalid repository path component ""`,
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			ref, err := ParseReference(test.image)
+			if len(test.expErr) > 0 {
+				if err == nil || err.Error() != test.expErr {
+					t.Errorf("unexpected error, exp=%q got=%v", test.expErr, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if !reflect.DeepEqual(ref, test.expRef) {
+				t.Errorf("unexpected reference, exp=%+v got=%+v", test.expRef, ref)
+			}
+		})
+	}
+}
+
+func TestLatestTagFromReference(t *testing.T) {
+	digest := "sha256:" + strings.Repeat("b", 64)
+	client := &imagesClient{
+		images: map[string][]api.ImageTag{
+			"nginx": {
+				{Tag: "1.1", SHA: "sha256:aaa", Timestamp: time.Unix(100, 0)},
+				{Tag: "1.2", SHA: digest, Timestamp: time.Unix(200, 0)},
+			},
+		},
+	}
+
+	tests := map[string]struct {
+		image        string
+		opts         *api.Options
+		expTag       string
+		expRef       *Reference
+		expDowngrade bool
+		expErr       bool
+	}{
+		"repository only should resolve the latest tag": {
+			image:  "nginx",
+			opts:   new(api.Options),
+			expTag: "1.2",
+			expRef: &Reference{Repository: "nginx"},
+		},
+		"tag should only list the repository": {
+			image:  "nginx:1.1",
+			opts:   new(api.Options),
+			expTag: "1.2",
+			expRef: &Reference{Repository: "nginx", Tag: "1.1"},
+		},
+		"digest should only list the repository": {
+			image:  "nginx@" + digest,
+			opts:   new(api.Options),
+			expTag: "1.2",
+			expRef: &Reference{Repository: "nginx", Digest: digest},
+		},
+		"tag should be the current tag when rejecting downgrades": {
+			image:        "nginx:1.3",
+			opts:         &api.Options{RejectDowngrade: true},
+			expDowngrade: true,
+		},
+		"malformed reference should error": {
+			image:  "nginx:",
+			opts:   new(api.Options),
+			expErr: true,
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			v := newTestVersion(client, time.Hour, Options{})
+
+			tag, ref, err := v.LatestTagFromReference(context.TODO(), test.image, test.opts)
+			if versionerrors.IsDowngrade(err) != test.expDowngrade {
+				t.Fatalf("unexpected downgrade error, exp=%t got=%v", test.expDowngrade, err)
+			}
+			if test.expDowngrade {
+				return
+			}
+			if (err != nil) != test.expErr {
+				t.Fatalf("unexpected error, exp=%t got=%v", test.expErr, err)
+			}
+			if test.expErr {
+				return
+			}
+
+			if tag.Tag != test.expTag {
+				t.Errorf("unexpected tag, exp=%s got=%s", test.expTag, tag.Tag)
+			}
+			if !reflect.DeepEqual(ref, test.expRef) {
+				t.Errorf("unexpected reference, exp=%+v got=%+v", test.expRef, ref)
+			}
+		})
+	}
+}
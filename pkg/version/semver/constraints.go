@@ -0,0 +1,137 @@
+package semver
+
+import (
+	"fmt"
+	"strings"
+)
+
+// constraintOperators are the supported operators of a constraint, ordered
+// so that longer operators are matched first.
+var constraintOperators = []string{"!=", ">=", "<=", "=", ">", "<", "~", "^"}
+
+// Constraints are a set of version ranges, where a version satisfies the
+// constraints if it satisfies every constraint of any group. Constraints
+// compare the major, minor and patch version only, ignoring metadata.
+// e.g. ">=1.2.0, <2.0.0 || ^3.1" permits 1.2.0 to 1.x.x, and 3.1.0 to 3.x.x
+type Constraints struct {
+	groups   [][]constraint
+	original string
+}
+
+// constraint is a single comparison of a version.
+type constraint struct {
+	operator string
+	version  *SemVer
+}
+
+// ParseConstraints will parse the given constraints. Groups are separated by
+// "||", and the constraints of a group by ",". Each constraint is a version
+// prefixed with one of the operators =, !=, >, >=, <, <=, ~ or ^, where no
+// operator is the same as =. The ~ operator permits patch versions of the
+// given minor version, and ^ permits minor and patch versions of the given
+// major version.
+func ParseConstraints(s string) (*Constraints, error) {
+	c := &Constraints{original: s}
+
+	for _, group := range strings.Split(s, "||") {
+		var constraints []constraint
+		for _, field := range strings.Split(group, ",") {
+			field = strings.TrimSpace(field)
+			if len(field) == 0 {
+				return nil, fmt.Errorf("invalid constraints %q: empty constraint", s)
+			}
+
+			operator := "="
+			for _, op := range constraintOperators {
+				if strings.HasPrefix(field, op) {
+					operator, field = op, strings.TrimSpace(field[len(op):])
+					break
+				}
+			}
+
+			version := Parse(field)
+			if !version.IsValid() || version.HasMetaData() {
+				return nil, fmt.Errorf("invalid constraints %q: invalid version %q", s, field)
+			}
+
+			constraints = append(constraints, constraint{operator: operator, version: version})
+		}
+
+		c.groups = append(c.groups, constraints)
+	}
+
+	return c, nil
+}
+
+// Check returns whether the given version satisfies the constraints. Versions
+// which are not valid never satisfy the constraints.
+func (c *Constraints) Check(v *SemVer) bool {
+	if !v.IsValid() {
+		return false
+	}
+
+	for _, group := range c.groups {
+		satisfied := true
+		for _, constraint := range group {
+			if !constraint.check(v) {
+				satisfied = false
+				break
+			}
+		}
+
+		if satisfied {
+			return true
+		}
+	}
+
+	return false
+}
+
+// check returns whether the given version satisfies the constraint.
+func (c constraint) check(v *SemVer) bool {
+	switch c.operator {
+	case "!=":
+		return v.CoreLessThan(c.version) || c.version.CoreLessThan(v)
+	case ">":
+		return c.version.CoreLessThan(v)
+	case ">=":
+		return !v.CoreLessThan(c.version)
+	case "<":
+		return v.CoreLessThan(c.version)
+	case "<=":
+		return !c.version.CoreLessThan(v)
+	case "~":
+		return !v.CoreLessThan(c.version) &&
+			v.Major() == c.version.Major() && v.Minor() == c.version.Minor()
+	case "^":
+		return !v.CoreLessThan(c.version) && v.Major() == c.version.Major()
+	default:
+		return !v.CoreLessThan(c.version) && !c.version.CoreLessThan(v)
+	}
+}
+
+// Intersect returns the constraints satisfied by versions which satisfy both
+// the constraints and the given constraints, where each group is a group of
+// the constraints combined with a group of the given constraints.
+func (c *Constraints) Intersect(o *Constraints) *Constraints {
+	cGroups, oGroups := strings.Split(c.original, "||"), strings.Split(o.original, "||")
+
+	intersection := new(Constraints)
+	var originals []string
+	for i, cGroup := range c.groups {
+		for j, oGroup := range o.groups {
+			group := make([]constraint, 0, len(cGroup)+len(oGroup))
+			group = append(append(group, cGroup...), oGroup...)
+			intersection.groups = append(intersection.groups, group)
+
+			originals = append(originals, strings.TrimSpace(cGroups[i])+", "+strings.TrimSpace(oGroups[j]))
+		}
+	}
+	intersection.original = strings.Join(originals, " || ")
+
+	return intersection
+}
+
+func (c *Constraints) String() string {
+	return c.original
+}
@@ -0,0 +1,68 @@
+package semver
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// SanitizeRule defines how tags with malformed versions are mapped onto a
+// semantic version before parsing.
+type SanitizeRule string
+
+const (
+	// SanitizeBuild maps extra version components onto build metadata.
+	// e.g. 1.2.3.4 -> 1.2.3+4, 1.2.3_1 -> 1.2.3+1
+	SanitizeBuild SanitizeRule = "build"
+
+	// SanitizePreRelease maps extra version components onto a pre-release.
+	// e.g. 1.2.3.4 -> 1.2.3-4, 1.2.3_1 -> 1.2.3-1
+	SanitizePreRelease SanitizeRule = "prerelease"
+)
+
+var (
+	// sanitizeRegex matches versions whose major, minor and patch are
+	// separated by '.' or '_', optionally followed by extra components.
+	sanitizeRegex = regexp.MustCompile(`^(v?)([0-9]+)[._]([0-9]+)[._]([0-9]+)(?:[._]([0-9A-Za-z][0-9A-Za-z._]*))?$`)
+)
+
+// ParseSanitizeRule returns the SanitizeRule of the given string, or an error
+// if it is not a known rule.
+func ParseSanitizeRule(rule string) (SanitizeRule, error) {
+	switch r := SanitizeRule(rule); r {
+	case SanitizeBuild, SanitizePreRelease:
+		return r, nil
+	default:
+		return "", fmt.Errorf("unknown sanitize rule %q, must be one of %q or %q",
+			rule, SanitizeBuild, SanitizePreRelease)
+	}
+}
+
+// Sanitize returns the given tag with common version malformations mapped
+// onto a semantic version using the given rule. Separators of the major,
+// minor and patch version are normalized to '.', and extra components are
+// mapped to build metadata or a pre-release. Tags which do not match a
+// malformation are returned unchanged.
+func Sanitize(tag string, rule SanitizeRule) string {
+	var separator string
+	switch rule {
+	case SanitizeBuild:
+		separator = "+"
+	case SanitizePreRelease:
+		separator = "-"
+	default:
+		return tag
+	}
+
+	match := sanitizeRegex.FindStringSubmatch(tag)
+	if len(match) == 0 {
+		return tag
+	}
+
+	version := match[1] + match[2] + "." + match[3] + "." + match[4]
+	if len(match[5]) > 0 {
+		version += separator + strings.ReplaceAll(match[5], "_", ".")
+	}
+
+	return version
+}
@@ -21,6 +21,9 @@ type SemVer struct {
 
 	// original holds the origin string of the tag
 	original string
+
+	// valid is whether the tag was able to be parsed as a version
+	valid bool
 }
 
 func Parse(tag string) *SemVer {
@@ -41,6 +44,7 @@ func Parse(tag string) *SemVer {
 		}
 	}
 	s.metadata = match[4]
+	s.valid = true
 
 	return s
 }
@@ -90,6 +94,18 @@ func (s *SemVer) LessThan(other *SemVer) bool {
 	return false
 }
 
+// CoreLessThan will return true if the major, minor and patch version of the
+// calling SemVer is less than the given semver, ignoring metadata.
+func (s *SemVer) CoreLessThan(other *SemVer) bool {
+	for i := 0; i < 3; i++ {
+		if s.version[i] != other.version[i] {
+			return s.version[i] < other.version[i]
+		}
+	}
+
+	return false
+}
+
 // Equal will return true if the given semver is equal.
 func (s *SemVer) Equal(other *SemVer) bool {
 	return s.original == other.original
@@ -102,6 +118,72 @@ func (s *SemVer) HasMetaData() bool {
 	return len(s.metadata) > 0
 }
 
+// MetaData returns the metadata of this SemVer, which is the string suffixed
+// from the patch digit.
+// e.g. v1.0.1-gke.3 -> -gke.3
+func (s *SemVer) MetaData() string {
+	return s.metadata
+}
+
+// HasOnlyBuildMetaData returns whether this SemVer's metadata is solely build
+// metadata, which is defined as metadata prefixed with '+'.
+// e.g. v1.0.1+4, v1.0.1+build.3
+func (s *SemVer) HasOnlyBuildMetaData() bool {
+	return strings.HasPrefix(s.metadata, "+")
+}
+
+// PreReleaseIdentifier returns the numeric components of the identifier
+// following the given pre-release channel, and whether this SemVer is a
+// pre-release of the channel. The identifier must only contain digits and the
+// separators '.', '-' and '_'.
+// e.g. given nightly, v1.0.1-nightly.20240101 -> [20240101], and
+// v1.0.1-nightly.2024-01-01 -> [2024 1 1]
+func (s *SemVer) PreReleaseIdentifier(channel string) ([]int64, bool) {
+	prefix := "-" + channel
+	if !s.valid || len(channel) == 0 || !strings.HasPrefix(s.metadata, prefix) {
+		return nil, false
+	}
+
+	identifier := s.metadata[len(prefix):]
+	if len(identifier) < 2 || !isIdentifierSeparator(rune(identifier[0])) {
+		return nil, false
+	}
+
+	var components []int64
+	for _, field := range strings.FieldsFunc(identifier, isIdentifierSeparator) {
+		component, err := strconv.ParseInt(field, 10, 64)
+		if err != nil {
+			return nil, false
+		}
+		components = append(components, component)
+	}
+
+	return components, len(components) > 0
+}
+
+// isIdentifierSeparator returns whether the given rune separates the numeric
+// components of a pre-release identifier.
+func isIdentifierSeparator(r rune) bool {
+	return r == '.' || r == '-' || r == '_'
+}
+
+// StripBuildMetaData returns the given version with any build metadata, which
+// is everything from the first '+', removed.
+// e.g. v1.0.1+build.3 -> v1.0.1, v1.0.1-rc.1+4 -> v1.0.1-rc.1
+func StripBuildMetaData(version string) string {
+	if i := strings.Index(version, "+"); i >= 0 {
+		return version[:i]
+	}
+
+	return version
+}
+
+// IsValid returns whether the tag was able to be parsed as a version. Tags
+// which are not valid versions hold the tag as their metadata.
+func (s *SemVer) IsValid() bool {
+	return s.valid
+}
+
 // Major returns the major version of this SemVer.
 func (s *SemVer) Major() int64 {
 	return s.version[0]
@@ -220,3 +220,269 @@ func TestLessThan(t *testing.T) {
 		})
 	}
 }
+
+func TestIsValid(t *testing.T) {
+	tests := map[string]bool{
+		"":             false,
+		"v":            false,
+		"latest":       false,
+		"hello-1.2.3":  false,
+		"1":            true,
+		"v1.2.3":       true,
+		"1.2.3-rc.1":   true,
+		"1.2.3+build1": true,
+	}
+
+	for input, expValid := range tests {
+		t.Run(input, func(t *testing.T) {
+			if valid := Parse(input).IsValid(); valid != expValid {
+				t.Errorf("unexpected valid, exp=%t got=%t", expValid, valid)
+			}
+		})
+	}
+}
+
+func TestCoreLessThan(t *testing.T) {
+	tests := map[string]struct {
+		a, b    string
+		expLess bool
+	}{
+		"same core should be false":    {"1.2.3", "1.2.3-rc.1", false},
+		"smaller patch should be true": {"1.2.3", "1.2.4-rc.1", true},
+		"smaller minor should be true": {"1.2.0", "1.3.0-rc.1", true},
+		"bigger major should be false": {"2.0.0", "1.3.0-rc.1", false},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			if less := Parse(test.a).CoreLessThan(Parse(test.b)); less != test.expLess {
+				t.Errorf("unexpected core less than, exp=%t got=%t", test.expLess, less)
+			}
+		})
+	}
+}
+
+func TestPreReleaseIdentifier(t *testing.T) {
+	tests := map[string]struct {
+		version string
+		expID   []int64
+		expOK   bool
+	}{
+		"single date identifier": {
+			version: "1.2.3-nightly.20240101",
+			expID:   []int64{20240101},
+			expOK:   true,
+		},
+		"separated date identifier": {
+			version: "v1.2.3-nightly-2024-01-05",
+			expID:   []int64{2024, 1, 5},
+			expOK:   true,
+		},
+		"other channel should not match": {
+			version: "1.2.3-rc.1",
+			expOK:   false,
+		},
+		"channel prefix of another channel should not match": {
+			version: "1.2.3-nightlyx.20240101",
+			expOK:   false,
+		},
+		"non-numeric identifier should not match": {
+			version: "1.2.3-nightly.abc",
+			expOK:   false,
+		},
+		"no identifier should not match": {
+			version: "1.2.3-nightly",
+			expOK:   false,
+		},
+		"stable version should not match": {
+			version: "1.2.3",
+			expOK:   false,
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			id, ok := Parse(test.version).PreReleaseIdentifier("nightly")
+			if ok != test.expOK || !reflect.DeepEqual(id, test.expID) {
+				t.Errorf("unexpected identifier, exp=%v (%t) got=%v (%t)", test.expID, test.expOK, id, ok)
+			}
+		})
+	}
+}
+
+func TestStripBuildMetaData(t *testing.T) {
+	tests := map[string]struct {
+		version, exp string
+	}{
+		"no metadata should be unchanged":                         {"v1.2.3", "v1.2.3"},
+		"pre-release should be unchanged":                         {"1.2.3-rc.1", "1.2.3-rc.1"},
+		"build metadata should be removed":                        {"1.2.3+build.1", "1.2.3"},
+		"pre-release with build metadata should keep pre-release": {"1.2.3-rc.1+4", "1.2.3-rc.1"},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			if got := StripBuildMetaData(test.version); got != test.exp {
+				t.Errorf("unexpected stripped version, exp=%q got=%q", test.exp, got)
+			}
+		})
+	}
+}
+
+func TestSanitize(t *testing.T) {
+	tests := map[string]struct {
+		tag    string
+		rule   SanitizeRule
+		expTag string
+	}{
+		"no rule should return unchanged": {
+			tag:    "1.2.3.4",
+			rule:   "",
+			expTag: "1.2.3.4",
+		},
+		"unknown rule should return unchanged": {
+			tag:    "1.2.3.4",
+			rule:   "foo",
+			expTag: "1.2.3.4",
+		},
+		"valid semver should return unchanged": {
+			tag:    "v1.2.3-rc.1",
+			rule:   SanitizeBuild,
+			expTag: "v1.2.3-rc.1",
+		},
+		"non version should return unchanged": {
+			tag:    "latest",
+			rule:   SanitizeBuild,
+			expTag: "latest",
+		},
+		"four components should map to build metadata": {
+			tag:    "1.2.3.4",
+			rule:   SanitizeBuild,
+			expTag: "1.2.3+4",
+		},
+		"four components should map to pre-release": {
+			tag:    "v1.2.3.4",
+			rule:   SanitizePreRelease,
+			expTag: "v1.2.3-4",
+		},
+		"underscore suffix should map to build metadata": {
+			tag:    "1.2.3_1",
+			rule:   SanitizeBuild,
+			expTag: "1.2.3+1",
+		},
+		"underscore suffix should map to pre-release": {
+			tag:    "1.2.3_1",
+			rule:   SanitizePreRelease,
+			expTag: "1.2.3-1",
+		},
+		"underscore separated core should be normalized": {
+			tag:    "1_2_3",
+			rule:   SanitizeBuild,
+			expTag: "1.2.3",
+		},
+		"multiple extra components should have separators normalized": {
+			tag:    "1_2_3_4_5",
+			rule:   SanitizePreRelease,
+			expTag: "1.2.3-4.5",
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			if tag := Sanitize(test.tag, test.rule); tag != test.expTag {
+				t.Errorf("unexpected sanitized tag, exp=%q got=%q", test.expTag, tag)
+			}
+		})
+	}
+}
+
+func TestParseSanitizeRule(t *testing.T) {
+	for _, rule := range []string{"build", "prerelease"} {
+		if _, err := ParseSanitizeRule(rule); err != nil {
+			t.Errorf("unexpected error parsing %q: %s", rule, err)
+		}
+	}
+
+	if _, err := ParseSanitizeRule("foo"); err == nil {
+		t.Error("expected error parsing unknown rule, got none")
+	}
+}
+
+func TestConstraintsCheck(t *testing.T) {
+	tests := map[string]struct {
+		constraints string
+		version     string
+		expCheck    bool
+	}{
+		"equal should permit same core":           {"1.2.3", "v1.2.3-rc.1", true},
+		"equal should not permit other patch":     {"=1.2.3", "1.2.4", false},
+		"not equal should permit other patch":     {"!=1.2.3", "1.2.4", true},
+		"not equal should not permit same":        {"!= 1.2.3", "1.2.3", false},
+		"greater should not permit same":          {">1.2.3", "1.2.3", false},
+		"greater or equal should permit same":     {">=1.2.3", "1.2.3", true},
+		"less should permit smaller minor":        {"<1.3", "1.2.9", true},
+		"less or equal should not permit bigger":  {"<=1.2.3", "1.2.4", false},
+		"tilde should permit bigger patch":        {"~1.2.3", "1.2.9", true},
+		"tilde should not permit bigger minor":    {"~1.2.3", "1.3.0", false},
+		"caret should permit bigger minor":        {"^1.2.3", "1.9.0", true},
+		"caret should not permit smaller patch":   {"^1.2.3", "1.2.2", false},
+		"caret should not permit bigger major":    {"^1.2.3", "2.0.0", false},
+		"range should permit version within":      {">=1.2.0, <2.0.0", "1.5.0", true},
+		"range should not permit version above":   {">=1.2.0, <2.0.0", "2.0.0", false},
+		"either group should permit version":      {">=1.2.0, <2.0.0 || ^3.1", "3.4.0", true},
+		"no group should not permit version":      {">=1.2.0, <2.0.0 || ^3.1", "3.0.0", false},
+		"invalid version should not be permitted": {">=1.2.0", "latest", false},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			c, err := ParseConstraints(test.constraints)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if check := c.Check(Parse(test.version)); check != test.expCheck {
+				t.Errorf("unexpected check of %q, exp=%t got=%t", test.version, test.expCheck, check)
+			}
+		})
+	}
+}
+
+func TestConstraintsIntersect(t *testing.T) {
+	a, err := ParseConstraints(">=1.2.0, <2.0.0 || ^3.1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	b, err := ParseConstraints("<1.5 || >=3.4")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	c := a.Intersect(b)
+
+	const expString = ">=1.2.0, <2.0.0, <1.5 || >=1.2.0, <2.0.0, >=3.4 || ^3.1, <1.5 || ^3.1, >=3.4"
+	if c.String() != expString {
+		t.Errorf("unexpected string, exp=%q got=%q", expString, c.String())
+	}
+
+	for version, expCheck := range map[string]bool{
+		"1.1.0": false,
+		"1.4.9": true,
+		"1.5.0": false,
+		"3.2.0": false,
+		"3.4.0": true,
+		"4.0.0": false,
+	} {
+		if check := c.Check(Parse(version)); check != expCheck {
+			t.Errorf("unexpected check of %q, exp=%t got=%t", version, expCheck, check)
+		}
+	}
+}
+
+func TestParseConstraintsInvalid(t *testing.T) {
+	for _, constraints := range []string{"", ">=1.2.0,", "|| 1.2", ">=latest", "1.2.3-rc.1"} {
+		if _, err := ParseConstraints(constraints); err == nil {
+			t.Errorf("expected error parsing %q, got none", constraints)
+		}
+	}
+}
@@ -0,0 +1,138 @@
+package semver
+
+import (
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+var (
+	// strictRegex matches SemVer 2.0.0 versions, optionally prefixed with
+	// "v", as is common of tags.
+	strictRegex = regexp.MustCompile(`^v?(0|[1-9][0-9]*)\.(0|[1-9][0-9]*)\.(0|[1-9][0-9]*)` +
+		`(-(0|[1-9][0-9]*|[0-9]*[a-zA-Z-][0-9a-zA-Z-]*)(\.(0|[1-9][0-9]*|[0-9]*[a-zA-Z-][0-9a-zA-Z-]*))*)?` +
+		`(\+[0-9a-zA-Z-]+(\.[0-9a-zA-Z-]+)*)?$`)
+)
+
+// Versioner parses and orders the versions of tags, so that versioning rules
+// other than the default may be used.
+type Versioner interface {
+	// Name returns the name of the versioner's rules, which identifies
+	// lookups using them.
+	Name() string
+
+	// Parse returns the version of the given tag, which is not valid if the
+	// tag is not a version under the versioner's rules.
+	Parse(tag string) *SemVer
+
+	// LessThan returns whether version a is ordered before version b.
+	LessThan(a, b *SemVer) bool
+}
+
+// Default is the Versioner used if none is configured, parsing tags with
+// Parse and ordering them by SemVer.LessThan. Tags need not be strict
+// semantic versions, where missing minor and patch versions are zero, and
+// any suffix of the patch version is metadata.
+// e.g. v1.2, 1.2.3.4 and 1.2.3-gke.1 are versions
+var Default Versioner = defaultVersioner{}
+
+// Strict is a Versioner of SemVer 2.0.0, where only tags which are semantic
+// versions, optionally prefixed with "v", are valid. Versions are ordered by
+// the precedence of the specification, where pre-releases are lower than
+// their stable version, and build metadata is ignored.
+// e.g. 1.0.0-alpha < 1.0.0-alpha.1 < 1.0.0-beta.11 < 1.0.0 < 2.0.0-rc.1
+var Strict Versioner = strictVersioner{}
+
+type defaultVersioner struct{}
+
+func (defaultVersioner) Name() string {
+	return "default"
+}
+
+func (defaultVersioner) Parse(tag string) *SemVer {
+	return Parse(tag)
+}
+
+func (defaultVersioner) LessThan(a, b *SemVer) bool {
+	return a.LessThan(b)
+}
+
+type strictVersioner struct{}
+
+func (strictVersioner) Name() string {
+	return "strict"
+}
+
+func (strictVersioner) Parse(tag string) *SemVer {
+	if !strictRegex.MatchString(tag) {
+		return &SemVer{original: tag, metadata: tag}
+	}
+
+	return Parse(tag)
+}
+
+func (strictVersioner) LessThan(a, b *SemVer) bool {
+	if a.valid != b.valid {
+		return !a.valid
+	}
+
+	for i := 0; i < 3; i++ {
+		if a.version[i] != b.version[i] {
+			return a.version[i] < b.version[i]
+		}
+	}
+
+	aPre, bPre := a.preRelease(), b.preRelease()
+	switch {
+	case len(aPre) == 0:
+		return false
+	case len(bPre) == 0:
+		return true
+	}
+
+	aIDs, bIDs := strings.Split(aPre, "."), strings.Split(bPre, ".")
+	for i := 0; i < len(aIDs) && i < len(bIDs); i++ {
+		if cmp := comparePreReleaseIdentifiers(aIDs[i], bIDs[i]); cmp != 0 {
+			return cmp < 0
+		}
+	}
+
+	return len(aIDs) < len(bIDs)
+}
+
+// preRelease returns the pre-release of this SemVer, without its leading '-'
+// or any build metadata. Empty if not a pre-release.
+// e.g. v1.0.1-rc.1+build.3 -> rc.1
+func (s *SemVer) preRelease() string {
+	if !strings.HasPrefix(s.metadata, "-") {
+		return ""
+	}
+
+	return StripBuildMetaData(s.metadata[1:])
+}
+
+// comparePreReleaseIdentifiers compares two pre-release identifiers by the
+// precedence of SemVer 2.0.0, returning -1, 0 or 1 if a is lower than, equal
+// to, or higher than b. Numeric identifiers are compared numerically, and are
+// lower than alphanumeric identifiers, which are compared in ASCII order.
+func comparePreReleaseIdentifiers(a, b string) int {
+	aNum, aErr := strconv.ParseUint(a, 10, 64)
+	bNum, bErr := strconv.ParseUint(b, 10, 64)
+
+	switch {
+	case aErr == nil && bErr == nil:
+		switch {
+		case aNum < bNum:
+			return -1
+		case aNum > bNum:
+			return 1
+		}
+		return 0
+	case aErr == nil:
+		return -1
+	case bErr == nil:
+		return 1
+	}
+
+	return strings.Compare(a, b)
+}
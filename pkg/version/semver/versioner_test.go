@@ -0,0 +1,107 @@
+package semver
+
+import (
+	"testing"
+)
+
+func TestVersionerParse(t *testing.T) {
+	tests := map[string]struct {
+		expDefault, expStrict bool
+	}{
+		"1.2.3":              {true, true},
+		"v1.2.3":             {true, true},
+		"1.2.3-rc.1":         {true, true},
+		"1.2.3-rc.1+build":   {true, true},
+		"1.2.3+build.01":     {true, true},
+		"1.2":                {true, false},
+		"1":                  {true, false},
+		"1.2.3.4":            {true, false},
+		"01.2.3":             {true, false},
+		"1.2.3-rc.01":        {true, false},
+		"1.2.3-":             {true, false},
+		"1.2.3_1":            {true, false},
+		"latest":             {false, false},
+		"hello-1.2.3":        {false, false},
+		"1.2.3-alpha.beta":   {true, true},
+		"1.2.3-0.3.7":        {true, true},
+		"1.2.3-x-y-z.--":     {true, true},
+		"1.2.3+exp.sha.5114": {true, true},
+	}
+
+	for tag, test := range tests {
+		t.Run(tag, func(t *testing.T) {
+			if valid := Default.Parse(tag).IsValid(); valid != test.expDefault {
+				t.Errorf("unexpected default valid, exp=%t got=%t", test.expDefault, valid)
+			}
+
+			v := Strict.Parse(tag)
+			if v.IsValid() != test.expStrict {
+				t.Errorf("unexpected strict valid, exp=%t got=%t", test.expStrict, v.IsValid())
+			}
+			if v.String() != tag {
+				t.Errorf("unexpected strict string, exp=%q got=%q", tag, v.String())
+			}
+		})
+	}
+}
+
+func TestVersionerLessThan(t *testing.T) {
+	tests := map[string]struct {
+		a, b                  string
+		expDefault, expStrict bool
+	}{
+		// Both versioners agree on standard versions.
+		"lower patch should be less": {
+			"1.2.3", "1.2.4", true, true,
+		},
+		"lower minor should be less": {
+			"v1.2.9", "v1.10.0", true, true,
+		},
+		"higher major should not be less": {
+			"2.0.0", "1.9.9", false, false,
+		},
+		"equal versions should not be less": {
+			"1.2.3", "1.2.3", false, false,
+		},
+		"pre-release should be less than its stable version": {
+			"1.2.3-rc.1", "1.2.3", true, true,
+		},
+		"stable version should not be less than its pre-release": {
+			"1.2.3", "1.2.3-rc.1", false, false,
+		},
+		"numeric pre-release identifiers should be compared numerically": {
+			"1.2.3-rc.2", "1.2.3-rc.11", true, true,
+		},
+		"alphanumeric pre-release identifiers should be compared in ascii order": {
+			"1.2.3-alpha.1", "1.2.3-beta.1", true, true,
+		},
+		"fewer pre-release identifiers should be less": {
+			"1.2.3-alpha", "1.2.3-alpha.1", true, true,
+		},
+		"numeric pre-release identifiers should be less than alphanumeric": {
+			"1.2.3-1", "1.2.3-alpha", true, true,
+		},
+
+		// The versioners differ where the default is lenient.
+		"stable version should be less than a pre-release of a higher version, by strict": {
+			"1.2.3", "2.0.0-rc.1", false, true,
+		},
+		"build metadata of a pre-release should be ignored, by strict": {
+			"1.2.3-rc.1+build.1", "1.2.3-rc.1", true, false,
+		},
+		"build metadata should be ignored, by strict": {
+			"1.2.3+build.1", "1.2.3+build.2", true, false,
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			if less := Default.LessThan(Default.Parse(test.a), Default.Parse(test.b)); less != test.expDefault {
+				t.Errorf("unexpected default less than, exp=%t got=%t", test.expDefault, less)
+			}
+			if less := Strict.LessThan(Strict.Parse(test.a), Strict.Parse(test.b)); less != test.expStrict {
+				t.Errorf("unexpected strict less than, exp=%t got=%t", test.expStrict, less)
+			}
+		})
+	}
+}
@@ -0,0 +1,222 @@
+package version
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"os/exec"
+	"strings"
+
+	"github.com/jetstack/version-checker/pkg/api"
+)
+
+const (
+	// defaultCosignPath is the cosign binary used if no path is configured.
+	defaultCosignPath = "cosign"
+)
+
+// SignatureVerifier verifies that images are signed by a trusted identity.
+type SignatureVerifier interface {
+	// Verify returns whether the image of the given image URL and digest is
+	// signed by a trusted identity. An error is returned if verification
+	// could not be performed.
+	Verify(ctx context.Context, imageURL, digest string) (bool, error)
+}
+
+// signatureFetcher is the cache handler for image signature verification.
+type signatureFetcher struct {
+	v *Version
+}
+
+// Fetch will verify the signature of the image of the given index, in the
+// form {image}@{digest}.
+func (s *signatureFetcher) Fetch(ctx context.Context, index string, _ *api.Options) (interface{}, error) {
+	lastAtIndex := strings.LastIndex(index, "@")
+	return s.v.opts.SignatureVerifier.Verify(ctx, index[:lastAtIndex], index[lastAtIndex+1:])
+}
+
+// latestVerifiedTag will return the latest tag chosen by selectTag, which has
+// an image signed by a trusted identity if the options require signatures,
+// an image config with the required labels if any, and a manifest of a
+// required media type if any, which exists if the manifest must be verified.
+// If the chosen tag fails any, it is removed along with all tags of the same
+// image, and the latest is chosen again.
+// Verification results, configs and manifests are cached per digest. Returns
+// nil if selectTag returns nil.
+func (v *Version) latestVerifiedTag(ctx context.Context, imageURL string, opts *api.Options, tags []api.ImageTag,
+	selectTag func([]api.ImageTag) (*api.ImageTag, error)) (*api.ImageTag, error) {
+	if !opts.RequireSignature && len(opts.RequireConfigLabels) == 0 &&
+		len(opts.RequireManifestMediaTypes) == 0 && !opts.VerifyManifest {
+		return selectTag(tags)
+	}
+
+	if opts.RequireSignature && v.opts.SignatureVerifier == nil {
+		return nil, fmt.Errorf("%s: signature required but no signature verifier configured", imageURL)
+	}
+
+	for {
+		tag, err := selectTag(tags)
+		if err != nil || tag == nil {
+			return tag, err
+		}
+
+		if opts.RequireSignature {
+			signed, err := v.signedImage(ctx, imageURL, opts, tag)
+			if err != nil {
+				return nil, err
+			}
+			if !signed {
+				v.log.Debugf("%s: skipping tag %q whose image %q is not signed by a trusted identity",
+					imageURL, tag.Tag, tag.SHA)
+				tags = withoutImage(tags, tag.SHA)
+				continue
+			}
+		}
+
+		if len(opts.RequireConfigLabels) > 0 {
+			labelled, err := v.hasConfigLabels(ctx, imageURL, opts, tag)
+			if err != nil {
+				return nil, err
+			}
+			if !labelled {
+				v.log.Debugf("%s: skipping tag %q whose image config does not have the required labels",
+					imageURL, tag.Tag)
+				tags = withoutReference(tags, tag)
+				continue
+			}
+		}
+
+		if opts.VerifyManifest {
+			found, err := v.manifestExists(ctx, imageURL, tag)
+			if err != nil {
+				return nil, err
+			}
+			if !found {
+				v.log.Debugf("%s: skipping tag %q whose manifest was not found",
+					imageURL, tag.Tag)
+				tags = withoutReference(tags, tag)
+				continue
+			}
+		}
+
+		if len(opts.RequireManifestMediaTypes) > 0 {
+			mediaType, err := v.manifestMediaType(ctx, imageURL, tag)
+			if err != nil {
+				return nil, err
+			}
+			if !containsString(opts.RequireManifestMediaTypes, mediaType) {
+				v.log.Debugf("%s: skipping tag %q whose manifest media type %q is not required",
+					imageURL, tag.Tag, mediaType)
+				tags = withoutReference(tags, tag)
+				continue
+			}
+		}
+
+		return tag, nil
+	}
+}
+
+// signedImage returns whether the image of the given tag is signed by a
+// trusted identity, using the signature cache. Tags without a digest are never
+// signed, as their image cannot be verified.
+func (v *Version) signedImage(ctx context.Context, imageURL string, opts *api.Options, tag *api.ImageTag) (bool, error) {
+	if len(tag.SHA) == 0 {
+		return false, nil
+	}
+
+	verified, err := v.signatureCache.Get(ctx, imageURL+"@"+tag.SHA, imageURL+"@"+tag.SHA, opts)
+	if err != nil {
+		return false, fmt.Errorf("%s: failed to verify signature of %q: %w", imageURL, tag.Tag, err)
+	}
+
+	return verified.(bool), nil
+}
+
+// withoutImage will return the given tags without those of the given digest.
+// Tags without a digest are always removed, as their image cannot be
+// verified.
+func withoutImage(tags []api.ImageTag, digest string) []api.ImageTag {
+	var remaining []api.ImageTag
+	for _, tag := range tags {
+		if len(tag.SHA) > 0 && tag.SHA != digest {
+			remaining = append(remaining, tag)
+		}
+	}
+
+	return remaining
+}
+
+// CosignOptions configure the trusted identity of a CosignVerifier. Either
+// the Key, or the CertificateIdentity and CertificateOIDCIssuer for keyless
+// signatures, must be set.
+type CosignOptions struct {
+	// Path is the path of the cosign binary. Defaults to "cosign" on the PATH.
+	Path string
+
+	// Key is the path or URL of the public key which signatures must be
+	// signed by.
+	Key string
+
+	// CertificateIdentity and CertificateOIDCIssuer are the identity and OIDC
+	// issuer of the certificate which keyless signatures must be signed by.
+	CertificateIdentity   string
+	CertificateOIDCIssuer string
+}
+
+// CosignVerifier is a SignatureVerifier which verifies signatures using the
+// cosign binary.
+type CosignVerifier struct {
+	opts CosignOptions
+}
+
+// NewCosignVerifier returns a new CosignVerifier trusting the identity of the
+// given options. Returns an error if no identity is configured.
+func NewCosignVerifier(opts CosignOptions) (*CosignVerifier, error) {
+	if len(opts.Key) == 0 && (len(opts.CertificateIdentity) == 0 || len(opts.CertificateOIDCIssuer) == 0) {
+		return nil, errors.New("cosign verification requires a key, or a certificate identity and OIDC issuer")
+	}
+
+	if len(opts.Path) == 0 {
+		opts.Path = defaultCosignPath
+	}
+
+	return &CosignVerifier{opts: opts}, nil
+}
+
+// Verify returns whether cosign successfully verifies the image of the given
+// image URL and digest. Failed verifications are reported as unsigned, where
+// an error is only returned if cosign could not be run.
+func (c *CosignVerifier) Verify(ctx context.Context, imageURL, digest string) (bool, error) {
+	var stderr bytes.Buffer
+
+	cmd := exec.CommandContext(ctx, c.opts.Path, c.args(imageURL, digest)...)
+	cmd.Stderr = &stderr
+
+	err := cmd.Run()
+
+	var exitErr *exec.ExitError
+	if errors.As(err, &exitErr) && ctx.Err() == nil {
+		return false, nil
+	}
+	if err != nil {
+		return false, fmt.Errorf("failed to run cosign: %w: %s", err, strings.TrimSpace(stderr.String()))
+	}
+
+	return true, nil
+}
+
+// args returns the arguments of cosign to verify the given image.
+func (c *CosignVerifier) args(imageURL, digest string) []string {
+	args := []string{"verify"}
+	if len(c.opts.Key) > 0 {
+		args = append(args, "--key", c.opts.Key)
+	} else {
+		args = append(args,
+			"--certificate-identity", c.opts.CertificateIdentity,
+			"--certificate-oidc-issuer", c.opts.CertificateOIDCIssuer,
+		)
+	}
+
+	return append(args, imageURL+"@"+digest)
+}
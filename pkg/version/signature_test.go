@@ -0,0 +1,185 @@
+package version
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/jetstack/version-checker/pkg/api"
+	versionerrors "github.com/jetstack/version-checker/pkg/version/errors"
+)
+
+// fakeVerifier is a SignatureVerifier which trusts a fixed set of digests,
+// and counts the verifications made per digest.
+type fakeVerifier struct {
+	mu      sync.Mutex
+	trusted map[string]bool
+	err     error
+	calls   map[string]int
+}
+
+func (f *fakeVerifier) Verify(_ context.Context, _, digest string) (bool, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if f.calls == nil {
+		f.calls = make(map[string]int)
+	}
+	f.calls[digest]++
+
+	if f.err != nil {
+		return false, f.err
+	}
+
+	return f.trusted[digest], nil
+}
+
+func TestRequireSignature(t *testing.T) {
+	client := &fakeClient{
+		tags: []api.ImageTag{
+			{Tag: "v1.0.0", SHA: "sha256:aaa", Timestamp: time.Unix(100, 0)},
+			{Tag: "v1.1.0", SHA: "sha256:bbb", Timestamp: time.Unix(200, 0)},
+			{Tag: "v1.2.0", SHA: "sha256:ccc", Timestamp: time.Unix(300, 0)},
+			{Tag: "v1.2", SHA: "sha256:ccc", Timestamp: time.Unix(300, 0)},
+			{Tag: "v1.3.0", SHA: "", Timestamp: time.Unix(400, 0)},
+		},
+	}
+
+	tests := map[string]struct {
+		opts        *api.Options
+		trusted     map[string]bool
+		expTag      string
+		expNotFound bool
+		expCalls    map[string]int
+	}{
+		"signatures not required should not verify": {
+			opts:     new(api.Options),
+			trusted:  nil,
+			expTag:   "v1.3.0",
+			expCalls: nil,
+		},
+		"signed latest should be selected": {
+			opts:     &api.Options{RequireSignature: true, PinMinor: int64p(2), PinMajor: int64p(1)},
+			trusted:  map[string]bool{"sha256:ccc": true},
+			expTag:   "v1.2.0",
+			expCalls: map[string]int{"sha256:ccc": 1},
+		},
+		"unsigned and undigested tags should be skipped": {
+			opts:     &api.Options{RequireSignature: true},
+			trusted:  map[string]bool{"sha256:aaa": true, "sha256:bbb": true},
+			expTag:   "v1.1.0",
+			expCalls: map[string]int{"sha256:ccc": 1, "sha256:bbb": 1},
+		},
+		"no signed tags should not be found": {
+			opts:        &api.Options{RequireSignature: true},
+			trusted:     nil,
+			expNotFound: true,
+			expCalls:    map[string]int{"sha256:aaa": 1, "sha256:bbb": 1, "sha256:ccc": 1},
+		},
+		"sha should select the latest signed image": {
+			opts:     &api.Options{RequireSignature: true, UseSHA: true},
+			trusted:  map[string]bool{"sha256:bbb": true},
+			expTag:   "v1.1.0",
+			expCalls: map[string]int{"sha256:ccc": 1, "sha256:bbb": 1},
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			verifier := &fakeVerifier{trusted: test.trusted}
+			v := newTestVersion(client, time.Hour, Options{SignatureVerifier: verifier})
+
+			// Lookup twice, so that the second is served from the cache.
+			for i := 0; i < 2; i++ {
+				tag, err := v.LatestTagFromImage(context.TODO(), "localhost:5000/version-checker", test.opts)
+				if versionerrors.IsNoVersionFound(err) != test.expNotFound {
+					t.Fatalf("unexpected not found error, exp=%t got=%v", test.expNotFound, err)
+				}
+				if test.expNotFound {
+					continue
+				}
+				if err != nil {
+					t.Fatal(err)
+				}
+				if tag.Tag != test.expTag {
+					t.Errorf("unexpected tag, exp=%q got=%q", test.expTag, tag.Tag)
+				}
+			}
+
+			if !reflect.DeepEqual(verifier.calls, test.expCalls) {
+				t.Errorf("unexpected verifications, exp=%v got=%v", test.expCalls, verifier.calls)
+			}
+		})
+	}
+}
+
+func TestRequireSignatureErrors(t *testing.T) {
+	client := &fakeClient{
+		tags: []api.ImageTag{{Tag: "v1.0.0", SHA: "sha256:aaa"}},
+	}
+	opts := &api.Options{RequireSignature: true}
+
+	v := newTestVersion(client, time.Hour, Options{})
+	if _, err := v.LatestTagFromImage(context.TODO(), "localhost:5000/version-checker", opts); err == nil {
+		t.Error("expected error without a signature verifier, got none")
+	}
+
+	verifyErr := errors.New("transparency log unavailable")
+	v = newTestVersion(client, time.Hour, Options{SignatureVerifier: &fakeVerifier{err: verifyErr}})
+	if _, err := v.LatestTagFromImage(context.TODO(), "localhost:5000/version-checker", opts); !errors.Is(err, verifyErr) {
+		t.Errorf("unexpected error, exp=%v got=%v", verifyErr, err)
+	}
+}
+
+func TestCosignVerifierArgs(t *testing.T) {
+	tests := map[string]struct {
+		opts    CosignOptions
+		expArgs []string
+		expErr  bool
+	}{
+		"key should be verified by key": {
+			opts:    CosignOptions{Key: "cosign.pub"},
+			expArgs: []string{"verify", "--key", "cosign.pub", "quay.io/jetstack/version-checker@sha256:aaa"},
+		},
+		"keyless should be verified by certificate identity": {
+			opts: CosignOptions{
+				CertificateIdentity:   "release@jetstack.io",
+				CertificateOIDCIssuer: "https://accounts.google.com",
+			},
+			expArgs: []string{
+				"verify",
+				"--certificate-identity", "release@jetstack.io",
+				"--certificate-oidc-issuer", "https://accounts.google.com",
+				"quay.io/jetstack/version-checker@sha256:aaa",
+			},
+		},
+		"keyless without issuer should error": {
+			opts:   CosignOptions{CertificateIdentity: "release@jetstack.io"},
+			expErr: true,
+		},
+		"no identity should error": {
+			opts:   CosignOptions{},
+			expErr: true,
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			verifier, err := NewCosignVerifier(test.opts)
+			if (err != nil) != test.expErr {
+				t.Fatalf("unexpected error, exp=%t got=%v", test.expErr, err)
+			}
+			if test.expErr {
+				return
+			}
+
+			args := verifier.args("quay.io/jetstack/version-checker", "sha256:aaa")
+			if !reflect.DeepEqual(args, test.expArgs) {
+				t.Errorf("unexpected args, exp=%v got=%v", test.expArgs, args)
+			}
+		})
+	}
+}
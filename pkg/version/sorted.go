@@ -0,0 +1,100 @@
+package version
+
+import (
+	"context"
+
+	"github.com/jetstack/version-checker/pkg/api"
+	"github.com/jetstack/version-checker/pkg/client"
+	"github.com/jetstack/version-checker/pkg/version/semver"
+)
+
+// sortedListingSupported returns whether the latest tag for the given options
+// can be selected from tags sorted by descending version. Options which order
+// or parse tags differently from the registry, or which may skip the latest
+// version, require all tags to be listed.
+func sortedListingSupported(opts *api.Options) bool {
+	return !opts.UseSHA &&
+		!opts.FallbackToSHA &&
+		!opts.FallbackToPreRelease &&
+		!opts.RequireSignature &&
+		len(opts.RequireConfigLabels) == 0 &&
+		len(opts.RequireManifestMediaTypes) == 0 &&
+		!opts.VerifyManifest &&
+		opts.FloatingTag == nil &&
+		opts.PointerTag == nil &&
+		opts.PromotionURL == nil &&
+		opts.TagMapper == nil &&
+		opts.Versioner == nil &&
+		opts.VersionExtractor == nil &&
+		len(opts.PreReleaseChannel) == 0 &&
+		len(opts.SanitizeRule) == 0
+}
+
+// latestSortedSemver will select the latest tag of the given image URL by
+// listing its tags in pages sorted by descending version. Listing stops once
+// a latest tag has been selected, and the last tag of a page has a lower
+// major, minor and patch version, since no later tag can then be greater.
+// Returns false if the registry does not support sorted listing, or listing
+// failed, in which case all tags should be listed instead.
+func (v *Version) latestSortedSemver(ctx context.Context, imageURL string, opts *api.Options) (*api.ImageTag, bool, error) {
+	// Hosts which are failing are left to the circuit breaker of the full
+	// listing, so that probes of the host are only made once.
+	host := client.HostFromImageURL(imageURL)
+	if v.breaker != nil && v.breaker.state(host) != circuitClosed {
+		return nil, false, nil
+	}
+
+	var (
+		tags      []api.ImageTag
+		latest    *api.ImageTag
+		latestErr error
+	)
+
+	supported, err := v.client.SortedTags(ctx, imageURL, func(page []api.ImageTag) bool {
+		if len(page) == 0 {
+			return true
+		}
+
+		tags = append(tags, page...)
+
+		latest, latestErr = latestCandidateSemver(opts, tags)
+		if latestErr != nil {
+			return false
+		}
+
+		return !canStopListing(latest, page[len(page)-1])
+	})
+	if !supported {
+		// No request was made to the registry, so nothing to record.
+		return nil, false, nil
+	}
+
+	if v.breaker != nil {
+		v.breaker.record(host, err == nil)
+	}
+	if err != nil {
+		return nil, false, err
+	}
+	if latestErr != nil {
+		return nil, false, latestErr
+	}
+
+	// All candidate tags have been considered, so not finding a tag is final.
+	tag, err := selectLatestSemver(imageURL, opts, tags)
+	return tag, true, err
+}
+
+// canStopListing returns whether listing tags sorted by descending version
+// can stop, given the currently selected latest tag and the last tag listed.
+func canStopListing(latest *api.ImageTag, last api.ImageTag) bool {
+	if latest == nil {
+		return false
+	}
+
+	lastV := semver.Parse(last.Tag)
+	if !lastV.IsValid() {
+		return false
+	}
+
+	return lastV.CoreLessThan(semver.Parse(latest.Tag))
+}
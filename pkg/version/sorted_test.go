@@ -0,0 +1,159 @@
+package version
+
+import (
+	"context"
+	"errors"
+	"regexp"
+	"testing"
+	"time"
+
+	"github.com/jetstack/version-checker/pkg/api"
+	versionerrors "github.com/jetstack/version-checker/pkg/version/errors"
+)
+
+// sortedPages returns the given tags as pages of the given size.
+func sortedPages(size int, tags ...string) [][]api.ImageTag {
+	var pages [][]api.ImageTag
+	for i := 0; i < len(tags); i += size {
+		end := i + size
+		if end > len(tags) {
+			end = len(tags)
+		}
+
+		var page []api.ImageTag
+		for _, tag := range tags[i:end] {
+			page = append(page, api.ImageTag{Tag: tag})
+		}
+		pages = append(pages, page)
+	}
+
+	return pages
+}
+
+func TestLatestSortedSemver(t *testing.T) {
+	pages := sortedPages(2,
+		"v2.0.0-rc.1", "v1.3.0-rc.2",
+		"v1.2.1", "v1.2.1-rc.1",
+		"v1.2.0", "v1.1.0",
+		"v1.0.0", "v0.1.0",
+	)
+
+	tests := map[string]struct {
+		opts         *api.Options
+		sortedPages  [][]api.ImageTag
+		expTag       string
+		expPageCalls int
+		expTagsCalls int
+		expNotFound  bool
+	}{
+		"should stop once no later page can be greater": {
+			opts:         new(api.Options),
+			sortedPages:  pages,
+			expTag:       "v1.2.1",
+			expPageCalls: 3,
+		},
+		"metadata should stop on the first page": {
+			opts:         &api.Options{UseMetaData: true},
+			sortedPages:  pages,
+			expTag:       "v2.0.0-rc.1",
+			expPageCalls: 1,
+		},
+		"pins should respect filters across pages": {
+			opts:         &api.Options{PinMajor: int64p(1), PinMinor: int64p(1)},
+			sortedPages:  pages,
+			expTag:       "v1.1.0",
+			expPageCalls: 4,
+		},
+		"regex should respect filters across pages": {
+			opts:         &api.Options{RegexMatcher: regexp.MustCompile(`^v1\.0\.`)},
+			sortedPages:  pages,
+			expTag:       "v1.0.0",
+			expPageCalls: 4,
+		},
+		"no matching tag should scan all pages and not be found": {
+			opts:         &api.Options{PinMajor: int64p(3)},
+			sortedPages:  pages,
+			expPageCalls: 4,
+			expNotFound:  true,
+		},
+		"unsupported registry should list all tags": {
+			opts:         new(api.Options),
+			sortedPages:  nil,
+			expTag:       "v1.2.1",
+			expTagsCalls: 1,
+		},
+		"fallback to pre-release should list all tags": {
+			opts:         &api.Options{FallbackToPreRelease: true},
+			sortedPages:  pages,
+			expTag:       "v1.2.1",
+			expTagsCalls: 1,
+		},
+		"unsupported options should list all tags": {
+			opts:         &api.Options{PreReleaseChannel: "rc"},
+			sortedPages:  pages,
+			expTag:       "v2.0.0-rc.1",
+			expTagsCalls: 1,
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			client := &fakeClient{
+				tags: []api.ImageTag{
+					{Tag: "v2.0.0-rc.1"}, {Tag: "v1.2.1"}, {Tag: "v1.2.0"}, {Tag: "v0.1.0"},
+				},
+				sortedPages: test.sortedPages,
+			}
+
+			v := newTestVersion(client, time.Hour, Options{})
+
+			tag, err := v.LatestTagFromImage(context.TODO(), "jetstack/version-checker", test.opts)
+			if versionerrors.IsNoVersionFound(err) != test.expNotFound {
+				t.Fatalf("unexpected not found error, exp=%t got=%v", test.expNotFound, err)
+			}
+			if !test.expNotFound {
+				if err != nil {
+					t.Fatal(err)
+				}
+				if tag.Tag != test.expTag {
+					t.Errorf("unexpected tag, exp=%q got=%q", test.expTag, tag.Tag)
+				}
+			}
+
+			if client.pageCalls != test.expPageCalls || client.calls != test.expTagsCalls {
+				t.Errorf("unexpected registry calls, exp=%d pages, %d listings got=%d pages, %d listings",
+					test.expPageCalls, test.expTagsCalls, client.pageCalls, client.calls)
+			}
+		})
+	}
+}
+
+func TestLatestSortedSemverFallback(t *testing.T) {
+	client := &fakeClient{
+		tags:        []api.ImageTag{{Tag: "v0.1.0"}},
+		sortedPages: sortedPages(1, "v0.1.0"),
+	}
+
+	v := newTestVersion(client, time.Hour, Options{})
+
+	// Once tags are cached, sorted listing should not be used.
+	if _, err := v.LatestTagFromImage(context.TODO(), "jetstack/version-checker", &api.Options{UseSHA: true}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := v.LatestTagFromImage(context.TODO(), "jetstack/version-checker", new(api.Options)); err != nil {
+		t.Fatal(err)
+	}
+	if client.pageCalls != 0 || client.calls != 1 {
+		t.Errorf("expected cached tags to be used, got=%d pages, %d listings", client.pageCalls, client.calls)
+	}
+
+	// Failed sorted listings should fall back to listing all tags, which also
+	// fails here.
+	client.err = errors.New("registry unavailable")
+	if _, err := v.LatestTagFromImage(context.TODO(), "jetstack/other", new(api.Options)); err == nil {
+		t.Fatal("expected error, got none")
+	}
+	if client.pageCalls != 1 || client.calls != 2 {
+		t.Errorf("expected fallback to listing all tags, got=%d pages, %d listings", client.pageCalls, client.calls)
+	}
+}
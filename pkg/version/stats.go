@@ -0,0 +1,181 @@
+package version
+
+import (
+	"time"
+
+	"github.com/sirupsen/logrus"
+
+	"github.com/jetstack/version-checker/pkg/api"
+	"github.com/jetstack/version-checker/pkg/cache"
+)
+
+// CacheStats is a snapshot of the statistics of the image and manifest
+// caches.
+type CacheStats struct {
+	Images    cache.Stats
+	Manifests cache.Stats
+
+	// ImageFetchDurations is the duration of the last tags request of each
+	// cached image, keyed by image cache index, being the image URL, scoped
+	// by credentials if any. See ScopedImageIndex.
+	ImageFetchDurations map[string]time.Duration
+}
+
+// CachedImage is an image whose tags are held in the image cache.
+type CachedImage struct {
+	// Index is the image cache index, being the image URL, scoped by
+	// credentials if any. See ScopedImageIndex.
+	Index string
+
+	// Registry is the name of the registry client of the image.
+	Registry string
+
+	// Tags is the number of cached tags of the image.
+	Tags int
+
+	// CachedAt is the time the tags were last fetched, and ExpiresAt the time
+	// after which they are fetched again.
+	CachedAt  time.Time
+	ExpiresAt time.Time
+
+	// FetchDuration is the duration of the last tags request of the image.
+	FetchDuration time.Duration
+
+	// Hits, Misses and FetchErrors are the image cache counters of the image.
+	Hits, Misses, FetchErrors uint64
+}
+
+// RegistryCacheStats are the image cache counters of the images of a
+// registry client currently held in the image cache.
+type RegistryCacheStats struct {
+	// Images is the number of images of the registry held in the image cache,
+	// including those whose tags failed to be fetched.
+	Images int
+
+	Hits, Misses, FetchErrors uint64
+}
+
+// ticker is a source of periodic ticks, replaceable in tests.
+type ticker interface {
+	C() <-chan time.Time
+	Stop()
+}
+
+// timeTicker is a ticker backed by a time.Ticker.
+type timeTicker struct {
+	*time.Ticker
+}
+
+func (t *timeTicker) C() <-chan time.Time {
+	return t.Ticker.C
+}
+
+func newTimeTicker(d time.Duration) ticker {
+	return &timeTicker{time.NewTicker(d)}
+}
+
+// CacheStats returns a snapshot of the image and manifest cache statistics.
+func (v *Version) CacheStats() CacheStats {
+	return CacheStats{
+		Images:              v.imageCache.Stats(),
+		Manifests:           v.manifestCache.Stats(),
+		ImageFetchDurations: v.imageCache.FetchDurations(),
+	}
+}
+
+// DumpCache returns the images whose tags are held in the image cache, sorted
+// by index. Images whose tags failed to be fetched are omitted.
+func (v *Version) DumpCache() []CachedImage {
+	images, _ := v.dumpImageCache()
+	return images
+}
+
+// dumpImageCache returns the images whose tags are held in the image cache,
+// along with the counters of the images of each registry client, including
+// those whose tags failed to be fetched, keyed by client name.
+func (v *Version) dumpImageCache() ([]CachedImage, map[string]RegistryCacheStats) {
+	var (
+		images     []CachedImage
+		registries = make(map[string]RegistryCacheStats)
+	)
+
+	for _, entry := range v.imageCache.Entries() {
+		registry := v.client.ClientName(entry.Index)
+
+		stats := registries[registry]
+		stats.Images++
+		stats.Hits += entry.Hits
+		stats.Misses += entry.Misses
+		stats.FetchErrors += entry.FetchErrors
+		registries[registry] = stats
+
+		tags, ok := entry.Value.([]api.ImageTag)
+		if !ok {
+			continue
+		}
+
+		images = append(images, CachedImage{
+			Index:         entry.Index,
+			Registry:      registry,
+			Tags:          len(tags),
+			CachedAt:      entry.Committed,
+			ExpiresAt:     entry.Expires,
+			FetchDuration: entry.FetchDuration,
+			Hits:          entry.Hits,
+			Misses:        entry.Misses,
+			FetchErrors:   entry.FetchErrors,
+		})
+	}
+
+	return images, registries
+}
+
+// logStats is a blocking func that will log a snapshot of the cache
+// statistics every interval, along with the hit ratio and registry call rate
+// over that interval.
+func (v *Version) logStats(interval time.Duration) {
+	last := v.CacheStats()
+
+	t := v.newTicker(interval)
+	defer t.Stop()
+
+	for range t.C() {
+		stats := v.CacheStats()
+
+		imageHits := stats.Images.Hits - last.Images.Hits
+		imageMisses := stats.Images.Misses - last.Images.Misses
+		manifestHits := stats.Manifests.Hits - last.Manifests.Hits
+		manifestMisses := stats.Manifests.Misses - last.Manifests.Misses
+		registryCalls := imageMisses + manifestMisses
+
+		v.log.WithFields(logrus.Fields{
+			"interval":                  interval.String(),
+			"image_cache_items":         stats.Images.Items,
+			"image_cache_hits":          imageHits,
+			"image_cache_misses":        imageMisses,
+			"image_cache_hit_ratio":     hitRatio(imageHits, imageMisses),
+			"image_cache_stale_served":  stats.Images.StaleServed - last.Images.StaleServed,
+			"image_cache_stampedes":     stats.Images.Stampedes - last.Images.Stampedes,
+			"manifest_cache_items":      stats.Manifests.Items,
+			"manifest_cache_hits":       manifestHits,
+			"manifest_cache_misses":     manifestMisses,
+			"manifest_cache_hit_ratio":  hitRatio(manifestHits, manifestMisses),
+			"registry_calls":            registryCalls,
+			"registry_calls_per_second": float64(registryCalls) / interval.Seconds(),
+			"registry_errors": (stats.Images.FetchErrors - last.Images.FetchErrors) +
+				(stats.Manifests.FetchErrors - last.Manifests.FetchErrors),
+		}).Info("cache stats")
+
+		last = stats
+	}
+}
+
+// hitRatio returns the ratio of hits to all lookups, or zero if there were
+// no lookups.
+func hitRatio(hits, misses uint64) float64 {
+	if hits+misses == 0 {
+		return 0
+	}
+
+	return float64(hits) / float64(hits+misses)
+}
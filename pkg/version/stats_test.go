@@ -0,0 +1,140 @@
+package version
+
+import (
+	"context"
+	"io/ioutil"
+	"testing"
+	"time"
+
+	"github.com/sirupsen/logrus"
+
+	"github.com/jetstack/version-checker/pkg/api"
+)
+
+// fakeTicker is a ticker whose ticks are sent by the test.
+type fakeTicker struct {
+	ch chan time.Time
+}
+
+func (f *fakeTicker) C() <-chan time.Time {
+	return f.ch
+}
+
+func (f *fakeTicker) Stop() {}
+
+// entryHook sends every fired log entry to a channel.
+type entryHook struct {
+	entries chan *logrus.Entry
+}
+
+func (e *entryHook) Levels() []logrus.Level {
+	return logrus.AllLevels
+}
+
+func (e *entryHook) Fire(entry *logrus.Entry) error {
+	e.entries <- entry
+	return nil
+}
+
+func TestLogStats(t *testing.T) {
+	client := &fakeClient{
+		tags: []api.ImageTag{{Tag: "v0.1.0"}},
+	}
+
+	v := newTestVersion(client, time.Hour, Options{StatsInterval: time.Minute})
+
+	hook := &entryHook{entries: make(chan *logrus.Entry, 10)}
+	log := logrus.New()
+	log.SetOutput(ioutil.Discard)
+	log.AddHook(hook)
+	v.log = logrus.NewEntry(log)
+
+	fake := &fakeTicker{ch: make(chan time.Time)}
+	var (
+		interval time.Duration
+		started  = make(chan struct{})
+	)
+	v.newTicker = func(d time.Duration) ticker {
+		interval = d
+		close(started)
+		return fake
+	}
+
+	go v.logStats(v.opts.StatsInterval)
+	<-started
+
+	// One miss, followed by two hits.
+	for i := 0; i < 3; i++ {
+		if _, err := v.LatestTagFromImage(context.TODO(), "localhost:5000/version-checker", new(api.Options)); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	expectSnapshot := func(expHits, expMisses uint64, expRatio float64) {
+		t.Helper()
+
+		fake.ch <- time.Now()
+
+		select {
+		case entry := <-hook.entries:
+			if entry.Message != "cache stats" {
+				t.Fatalf("unexpected log message: %q", entry.Message)
+			}
+			if hits := entry.Data["image_cache_hits"]; hits != expHits {
+				t.Errorf("unexpected hits, exp=%d got=%v", expHits, hits)
+			}
+			if misses := entry.Data["image_cache_misses"]; misses != expMisses {
+				t.Errorf("unexpected misses, exp=%d got=%v", expMisses, misses)
+			}
+			if ratio := entry.Data["image_cache_hit_ratio"]; ratio != expRatio {
+				t.Errorf("unexpected hit ratio, exp=%v got=%v", expRatio, ratio)
+			}
+			if calls := entry.Data["registry_calls"]; calls != expMisses {
+				t.Errorf("unexpected registry calls, exp=%d got=%v", expMisses, calls)
+			}
+		case <-time.After(time.Second):
+			t.Fatal("expected stats snapshot to be logged on tick")
+		}
+	}
+
+	expectSnapshot(2, 1, 2.0/3.0)
+
+	// Snapshots only cover their own interval.
+	expectSnapshot(0, 0, 0)
+
+	if interval != time.Minute {
+		t.Errorf("unexpected ticker interval, exp=%s got=%s", time.Minute, interval)
+	}
+
+	// No snapshot should be logged without a tick.
+	select {
+	case entry := <-hook.entries:
+		t.Errorf("unexpected log entry without tick: %q", entry.Message)
+	case <-time.After(10 * time.Millisecond):
+	}
+}
+
+func TestCacheStatsFetchDurations(t *testing.T) {
+	clock := &fakeClock{now: time.Unix(1600000000, 0)}
+	client := &fakeClient{
+		tags: []api.ImageTag{{Tag: "v0.1.0"}},
+		onTags: func() {
+			clock.now = clock.now.Add(time.Second * 2)
+		},
+	}
+
+	v := newTestVersion(client, time.Hour, Options{Clock: clock})
+
+	if durations := v.CacheStats().ImageFetchDurations; len(durations) != 0 {
+		t.Errorf("unexpected durations before fetching, exp=map[] got=%v", durations)
+	}
+
+	if _, err := v.LatestTagFromImage(context.TODO(), "jetstack/version-checker", new(api.Options)); err != nil {
+		t.Fatal(err)
+	}
+
+	durations := v.CacheStats().ImageFetchDurations
+	if len(durations) != 1 || durations["jetstack/version-checker"] != time.Second*2 {
+		t.Errorf("unexpected durations, exp=map[jetstack/version-checker:2s] got=%v", durations)
+	}
+}
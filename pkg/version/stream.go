@@ -0,0 +1,64 @@
+package version
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/jetstack/version-checker/pkg/api"
+	"github.com/jetstack/version-checker/pkg/client"
+)
+
+// StreamTags will call fn with each tag of the given image URL, as the pages
+// of tags are listed from the registry, so that all tags need not be held in
+// memory at once. Tags are served from the image cache if cached, otherwise
+// the streamed tags are not cached. Streaming stops at the first error of fn,
+// which is returned.
+func (v *Version) StreamTags(ctx context.Context, imageURL string, fn func(api.ImageTag) error) error {
+	if index := ScopedImageIndex(ctx, imageURL); v.imageCache.Has(index) {
+		tagsI, err := v.imageCache.Get(ctx, index, imageURL, nil)
+		if err != nil {
+			return err
+		}
+
+		return streamPage(tagsI.([]api.ImageTag), fn)
+	}
+
+	host := client.HostFromImageURL(imageURL)
+	if v.breaker != nil {
+		if err := v.breaker.allow(host); err != nil {
+			return err
+		}
+	}
+
+	var fnErr error
+	err := v.client.TagPages(ctx, imageURL, func(page []api.ImageTag) error {
+		fnErr = streamPage(page, fn)
+		return fnErr
+	})
+
+	// Errors of fn are not failures of the registry.
+	if err != nil && err == fnErr {
+		err = nil
+	}
+	if v.breaker != nil {
+		v.breaker.record(host, err == nil)
+	}
+	if err != nil {
+		return fmt.Errorf("failed to get tags from remote registry for %q: %w",
+			imageURL, err)
+	}
+
+	return fnErr
+}
+
+// streamPage will call fn with each of the given tags, stopping at the first
+// error, which is returned.
+func streamPage(tags []api.ImageTag, fn func(api.ImageTag) error) error {
+	for _, tag := range tags {
+		if err := fn(tag); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
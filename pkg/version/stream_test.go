@@ -0,0 +1,109 @@
+package version
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/jetstack/version-checker/pkg/api"
+)
+
+func TestStreamTags(t *testing.T) {
+	stop := errors.New("stop")
+	pages := [][]api.ImageTag{
+		{{Tag: "v0.1.0"}, {Tag: "v0.2.0"}},
+		{},
+		{{Tag: "v0.3.0"}, {Tag: "v0.4.0"}},
+		{{Tag: "v0.5.0"}},
+	}
+
+	tests := map[string]struct {
+		err       error
+		stopAt    string
+		expTags   []string
+		expPages  int
+		expErr    error
+		expAnyErr bool
+	}{
+		"all tags of all pages should be streamed in order": {
+			expTags:  []string{"v0.1.0", "v0.2.0", "v0.3.0", "v0.4.0", "v0.5.0"},
+			expPages: 4,
+		},
+		"streaming should stop at the first error of fn": {
+			stopAt:   "v0.3.0",
+			expTags:  []string{"v0.1.0", "v0.2.0", "v0.3.0"},
+			expPages: 3,
+			expErr:   stop,
+		},
+		"registry error should be returned": {
+			err:       errors.New("registry unavailable"),
+			expPages:  1,
+			expAnyErr: true,
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			client := &fakeClient{pages: pages, err: test.err}
+			v := newTestVersion(client, time.Hour, Options{})
+
+			var tags []string
+			err := v.StreamTags(context.TODO(), "localhost:5000/app", func(tag api.ImageTag) error {
+				tags = append(tags, tag.Tag)
+				if tag.Tag == test.stopAt {
+					return stop
+				}
+				return nil
+			})
+			if test.expAnyErr {
+				if err == nil {
+					t.Fatal("expected error, got=nil")
+				}
+			} else if err != test.expErr {
+				t.Fatalf("unexpected error, exp=%v got=%v", test.expErr, err)
+			}
+
+			if !reflect.DeepEqual(tags, test.expTags) {
+				t.Errorf("unexpected tags, exp=%v got=%v", test.expTags, tags)
+			}
+			if client.pageCalls != test.expPages {
+				t.Errorf("unexpected pages listed, exp=%d got=%d", test.expPages, client.pageCalls)
+			}
+			if client.calls != 0 {
+				t.Errorf("unexpected full tag listings, exp=0 got=%d", client.calls)
+			}
+		})
+	}
+}
+
+func TestStreamTagsCached(t *testing.T) {
+	client := &fakeClient{
+		tags: []api.ImageTag{{Tag: "v0.1.0"}, {Tag: "v0.2.0"}},
+		pages: [][]api.ImageTag{
+			{{Tag: "v0.3.0"}},
+		},
+	}
+	v := newTestVersion(client, time.Hour, Options{})
+
+	if _, err := v.LatestTagFromImage(context.TODO(), "localhost:5000/app", new(api.Options)); err != nil {
+		t.Fatal(err)
+	}
+
+	var tags []string
+	err := v.StreamTags(context.TODO(), "localhost:5000/app", func(tag api.ImageTag) error {
+		tags = append(tags, tag.Tag)
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if exp := []string{"v0.1.0", "v0.2.0"}; !reflect.DeepEqual(tags, exp) {
+		t.Errorf("unexpected tags, exp=%v got=%v", exp, tags)
+	}
+	if client.pageCalls != 0 {
+		t.Errorf("unexpected pages listed, exp=0 got=%d", client.pageCalls)
+	}
+}
@@ -0,0 +1,19 @@
+package version
+
+import (
+	"context"
+	"strings"
+)
+
+// Validate will check that the given image URL is resolvable, being matched
+// by a registry client whose tags of the image may be listed, without listing
+// or caching them, such as for admission checks. Any digest of the image URL
+// is ignored. Errors are typed by the registry client where known, see
+// client.ValidatingClient. Lookups of the image are unaffected.
+func (v *Version) Validate(ctx context.Context, imageURL string) error {
+	if split := strings.SplitN(imageURL, "@", 2); len(split) == 2 {
+		imageURL = split[0]
+	}
+
+	return v.client.Validate(ctx, imageURL)
+}
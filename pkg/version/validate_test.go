@@ -0,0 +1,28 @@
+package version
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	clienterrors "github.com/jetstack/version-checker/pkg/client/errors"
+)
+
+func TestValidate(t *testing.T) {
+	client := new(fakeClient)
+	v := newTestVersion(client, time.Hour, Options{})
+
+	if err := v.Validate(context.TODO(), "quay.io/jetstack/version-checker@sha256:aaa"); err != nil {
+		t.Errorf("unexpected error: %s", err)
+	}
+
+	client.err = clienterrors.NewErrorImageNotFound("quay.io", "jetstack/version-checker")
+	if err := v.Validate(context.TODO(), "quay.io/jetstack/version-checker"); !clienterrors.IsImageNotFound(err) {
+		t.Errorf("expected image not found error, got=%v", err)
+	}
+
+	// Validating does not list or cache the image's tags.
+	if client.calls != 0 {
+		t.Errorf("unexpected tag listings, exp=0 got=%d", client.calls)
+	}
+}
@@ -3,168 +3,1546 @@ package version
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"hash/fnv"
+	"strings"
+	"sync"
 	"time"
 
 	"github.com/sirupsen/logrus"
 
 	"github.com/jetstack/version-checker/pkg/api"
 	"github.com/jetstack/version-checker/pkg/client"
+	clienterrors "github.com/jetstack/version-checker/pkg/client/errors"
 
 	"github.com/jetstack/version-checker/pkg/cache"
 	versionerrors "github.com/jetstack/version-checker/pkg/version/errors"
 	"github.com/jetstack/version-checker/pkg/version/semver"
 )
 
+// Options are used to configure the behaviour of the version getter.
+type Options struct {
+	// CacheResults will cache the resolved latest tag for each image URL and
+	// options pair. Cached results are invalidated whenever the image's tags
+	// are refreshed from the remote registry.
+	CacheResults bool
+
+	// ServeStale will continue to serve cached image tags past the cache
+	// timeout if refreshing them fails with a registry failure, whilst the
+	// image's registry host is unhealthy, for at most MaxStale past the
+	// timeout. Tags selected from stale image tags are marked as Stale. Other
+	// errors, such as missing images or rejected credentials, are returned.
+	// MaxStale has no limit if zero.
+	ServeStale bool
+	MaxStale   time.Duration
+
+	// CircuitBreakerThreshold is the number of consecutive failures to a
+	// registry host after which requests to that host are short-circuited for
+	// the CircuitBreakerCooldown, returning ErrorCircuitOpen. After the
+	// cooldown, a single request is allowed to probe whether the host has
+	// recovered. Only failures of the registry count, being network errors,
+	// timeouts, and server error or rate limiting responses, so that missing
+	// images or rejected credentials never open the circuit. See
+	// clienterrors.IsRegistryFailure. Disabled if zero.
+	CircuitBreakerThreshold int
+	CircuitBreakerCooldown  time.Duration
+
+	// TagPrefixFiltering will push the regex of lookups down to the registry
+	// as a server side tag filter, for registries which support it, when the
+	// regex only matches tags beginning with a literal prefix, such as
+	// ^v1\.2\.. The regex is still applied to the listed tags. Tags listed
+	// with a filter are cached separately from the unfiltered tags of the
+	// same image.
+	TagPrefixFiltering bool
+
+	// DedupeTags will collapse duplicate entries of the same tag listed by
+	// registries, including tags differing only by case, keeping the entry
+	// with the most metadata, before the tags are cached. Entries of the same
+	// tag for different platforms are not duplicates.
+	DedupeTags bool
+
+	// PartialTagPages will list the tags of images page by page, where a
+	// page failing after earlier pages were listed returns the tags listed so
+	// far, each with Truncated set, rather than failing the lookup, so that
+	// the latest tag may be selected best effort. Partial listings are cached
+	// as any other, and count as failures of the registry host. Failures of
+	// the first page still fail the lookup. Disabled by default, so that
+	// lookups fail strictly.
+	PartialTagPages bool
+
+	// ManifestConcurrency is the maximum number of manifests fetched in
+	// parallel for a single image, when enriching tags with their manifest
+	// metadata. Defaults to fetching serially if less than one.
+	ManifestConcurrency int
+
+	// ImageCacheAgeFunc is called with the registry client name, as logged
+	// with each decision, and the age of an image's tags each time they are
+	// served from the image cache rather than fetched, so that the freshness
+	// of served tags may be observed, such as by a metrics histogram.
+	// Disabled if nil.
+	ImageCacheAgeFunc func(registry string, age time.Duration)
+
+	// ImageCacheStampedeFunc is called with the registry client name each
+	// time the tags of an image are fetched whilst a fetch of the same image
+	// is already in flight, such as by lookups with different credentials,
+	// so that stampedes on registries may be observed, such as by a metrics
+	// counter. Disabled if nil.
+	ImageCacheStampedeFunc func(registry string)
+
+	// StatsInterval is the interval at which a snapshot of the cache
+	// statistics is logged, for clusters without metrics collection.
+	// Disabled if zero.
+	StatsInterval time.Duration
+
+	// Clock is the source of the current time used by the caches and circuit
+	// breaker. Defaults to the real time if nil.
+	Clock cache.Clock
+
+	// DefaultOptions are merged with the options of each lookup, where options
+	// set by the lookup take precedence. See api.Options.WithDefaults for the
+	// merge semantics of each field.
+	DefaultOptions *api.Options
+
+	// SignatureVerifier verifies the signatures of images, for lookups whose
+	// options require signatures. Verification results are cached per image
+	// digest. Lookups requiring signatures error if nil.
+	SignatureVerifier SignatureVerifier
+
+	// JSONLogs will format the version getter's logs as JSON, regardless of
+	// the formatter of the given log entry's logger, so that operators may
+	// filter on their fields. The logger's output, level and hooks are kept.
+	// Each latest tag selected is logged at debug level with the fields
+	// image, registry, decision and tag.
+	JSONLogs bool
+
+	// ConstraintFunc returns the version constraints of the given image URL,
+	// such as those decided by a policy service, which are applied when
+	// selecting the latest tag of the image by version, intersected with any
+	// VersionConstraints of the lookup options. Images whose returned
+	// constraints are nil are unconstrained, and errors fail the lookup.
+	// Results are cached per image URL for the ConstraintCacheTimeout,
+	// defaulting to 30 seconds if zero. Disabled if nil.
+	ConstraintFunc         func(imageURL string) (*semver.Constraints, error)
+	ConstraintCacheTimeout time.Duration
+}
+
 type Version struct {
 	log *logrus.Entry
 
-	client     *client.Client
-	imageCache *cache.Cache
+	client          registryClient
+	imageCache      *cache.Cache
+	manifestCache   *cache.Cache
+	signatureCache  *cache.Cache
+	configCache     *cache.Cache
+	constraintCache *cache.Cache
+
+	opts    Options
+	breaker *circuitBreaker
+
+	// newTicker is used for periodic stats logging.
+	newTicker func(time.Duration) ticker
+
+	resultsMu sync.Mutex
+	results   map[string]map[string]*resultItem
+}
+
+// registryClient is used to list the tags, and fetch manifests and configs,
+// of an image URL. Implemented by *client.Client.
+type registryClient interface {
+	Tags(ctx context.Context, imageURL string) ([]api.ImageTag, error)
+	Manifest(ctx context.Context, imageURL, reference string) (*api.ImageManifest, error)
+	Config(ctx context.Context, imageURL, reference string) (*api.ImageConfig, error)
+	ClientName(imageURL string) string
+	SortedTags(ctx context.Context, imageURL string, page func([]api.ImageTag) bool) (bool, error)
+	TagPages(ctx context.Context, imageURL string, page func([]api.ImageTag) error) error
+	Validate(ctx context.Context, imageURL string) error
+}
+
+// manifestFetcher is the cache handler for fetching image manifests.
+type manifestFetcher struct {
+	v *Version
+}
+
+// ParsedTag is an image tag, parsed as a version.
+type ParsedTag struct {
+	// Tag is the original image tag.
+	Tag string
+
+	// Version is the parsed version of the tag, or nil if the tag is not a
+	// valid version.
+	Version *semver.SemVer
+
+	// Matched is whether the tag passes the option filters.
+	Matched bool
+}
+
+// resultItem is a resolved latest tag, along with the list of tags it was
+// resolved from.
+type resultItem struct {
+	tags []api.ImageTag
+	tag  *api.ImageTag
 }
 
-func New(log *logrus.Entry, client *client.Client, cacheTimeout time.Duration) *Version {
+func New(log *logrus.Entry, imageClient *client.Client, cacheTimeout time.Duration, opts Options) *Version {
+	if opts.JSONLogs {
+		log = jsonLogEntry(log)
+	}
 	log = log.WithField("module", "version_getter")
 
+	if opts.Clock == nil {
+		opts.Clock = cache.RealClock()
+	}
+
 	v := &Version{
-		log:    log,
-		client: client,
+		log:       log,
+		client:    imageClient,
+		opts:      opts,
+		results:   make(map[string]map[string]*resultItem),
+		newTicker: newTimeTicker,
 	}
 
-	v.imageCache = cache.New(log, cacheTimeout, v)
+	if opts.CircuitBreakerThreshold > 0 {
+		v.breaker = newCircuitBreaker(opts.CircuitBreakerThreshold, opts.CircuitBreakerCooldown)
+		v.breaker.now = opts.Clock.Now
+	}
+
+	var imageCacheAgeFunc func(string, time.Duration)
+	if opts.ImageCacheAgeFunc != nil {
+		imageCacheAgeFunc = func(index string, age time.Duration) {
+			opts.ImageCacheAgeFunc(v.client.ClientName(index), age)
+		}
+	}
+
+	var imageCacheStampedeFunc func(string)
+	if opts.ImageCacheStampedeFunc != nil {
+		imageCacheStampedeFunc = func(fetchIndex string) {
+			opts.ImageCacheStampedeFunc(v.client.ClientName(fetchIndex))
+		}
+	}
+
+	var imageCacheEvictFunc func(string)
+	if opts.CacheResults {
+		imageCacheEvictFunc = v.evictResults
+	}
+
+	v.imageCache = cache.New(log, cacheTimeout, v, cache.Options{
+		ServeStale:      opts.ServeStale,
+		MaxStale:        opts.MaxStale,
+		HostFunc:        client.HostFromImageURL,
+		HostFailureFunc: hostFailure,
+		AgeFunc:         imageCacheAgeFunc,
+		StampedeFunc:    imageCacheStampedeFunc,
+		EvictFunc:       imageCacheEvictFunc,
+		Clock:           opts.Clock,
+	})
+	v.manifestCache = cache.New(log.WithField("cache", "manifest"), cacheTimeout, &manifestFetcher{v}, cache.Options{
+		Clock: opts.Clock,
+	})
+	v.signatureCache = cache.New(log.WithField("cache", "signature"), cacheTimeout, &signatureFetcher{v}, cache.Options{
+		Clock: opts.Clock,
+	})
+	v.configCache = cache.New(log.WithField("cache", "config"), cacheTimeout, &configFetcher{v}, cache.Options{
+		Clock: opts.Clock,
+	})
+
+	constraintCacheTimeout := opts.ConstraintCacheTimeout
+	if constraintCacheTimeout == 0 {
+		constraintCacheTimeout = defaultConstraintCacheTimeout
+	}
+	v.constraintCache = cache.New(log.WithField("cache", "constraint"), constraintCacheTimeout, &constraintFetcher{v}, cache.Options{
+		Clock: opts.Clock,
+	})
 
 	return v
 }
 
-// Run is a blocking func that will start the image cache garbage collector.
+// Run is a blocking func that will start the image, manifest, signature,
+// config and constraint cache garbage collectors, and the cache stats logger
+// if enabled.
 func (v *Version) Run(refreshRate time.Duration) {
+	if v.opts.StatsInterval > 0 {
+		go v.logStats(v.opts.StatsInterval)
+	}
+
+	go v.manifestCache.StartGarbageCollector(refreshRate)
+	go v.signatureCache.StartGarbageCollector(refreshRate)
+	go v.configCache.StartGarbageCollector(refreshRate)
+	go v.constraintCache.StartGarbageCollector(refreshRate)
 	v.imageCache.StartGarbageCollector(refreshRate)
 }
 
 // LatestTagFromImage will return the latest tag given an imageURL, according
-// to the given options.
+// to the given options. Registry credentials carried by the context, using
+// api.ContextWithCredentials, are used in place of the client's configured
+// credentials. Cached tags and results are scoped to the identity of these
+// credentials, so lookups with different credentials are never shared.
 func (v *Version) LatestTagFromImage(ctx context.Context, imageURL string, opts *api.Options) (*api.ImageTag, error) {
-	if override := opts.OverrideURL; override != nil && len(*override) > 0 {
-		v.log.Debugf("overriding image lookup %s -> %s", imageURL, *override)
-		imageURL = *override
+	opts = v.lookupOptions(opts)
+	if len(opts.ChannelPaths) > 0 {
+		return v.latestAcrossChannels(ctx, imageURL, opts)
+	}
+
+	return v.latestTagFromImage(ctx, imageURL, opts)
+}
+
+// latestTagFromImage will return the latest tag of the given imageURL, the
+// same as LatestTagFromImage, where the default options have already been
+// applied. Channel paths are ignored.
+func (v *Version) latestTagFromImage(ctx context.Context, imageURL string, opts *api.Options) (*api.ImageTag, error) {
+	opts, err := v.withConstraints(ctx, imageURL, opts)
+	if err != nil {
+		return nil, err
+	}
+
+	if v.opts.TagPrefixFiltering {
+		ctx = withTagPrefix(ctx, opts)
+	}
+
+	// If the image's tags are not cached, attempt to select the latest tag
+	// without listing all tags.
+	if lookupURL := lookupURL(imageURL, opts); !v.imageCache.Has(ScopedImageIndex(ctx, lookupURL)) && sortedListingSupported(opts) {
+		tag, ok, err := v.latestSortedSemver(ctx, lookupURL, opts)
+		if ok {
+			if err == nil {
+				v.logDecision(lookupURL, decisionSortedSemver, tag)
+			}
+			return tag, err
+		}
+		if err != nil {
+			v.log.Debugf("%s: failed to list sorted tags, listing all tags: %s", lookupURL, err)
+		}
 	}
-	tagsI, err := v.imageCache.Get(ctx, imageURL, imageURL, nil)
+
+	imageURL, tags, stale, err := v.imageTags(ctx, imageURL, opts)
 	if err != nil {
 		return nil, err
 	}
-	tags := tagsI.([]api.ImageTag)
 
-	var tag *api.ImageTag
+	// Tag mappers cannot be compared, so their results are never cached.
+	cacheResults := v.opts.CacheResults && opts.TagMapper == nil
 
-	// If UseSHA then return early
-	if opts.UseSHA {
-		tag, err = latestSHA(tags)
+	var hashIndex string
+	if cacheResults {
+		hashIndex, err = CalculateHashIndex(ScopedImageIndex(ctx, imageURL), opts)
 		if err != nil {
 			return nil, err
 		}
 
+		if tag, ok := v.cachedResult(imageURL, hashIndex, tags); ok {
+			v.logDecision(imageURL, decisionCachedResult, tag)
+			return markStale(tag, stale), nil
+		}
+	}
+
+	tag, d, err := v.selectLatest(ctx, imageURL, opts, tags)
+	if err != nil {
+		return nil, err
+	}
+
+	if cacheResults {
+		v.commitResult(imageURL, hashIndex, tags, tag)
+	}
+
+	v.logDecision(imageURL, d, tag)
+
+	return markStale(tag, stale), err
+}
+
+// selectLatest will return the latest of the given tags of the image URL
+// according to the given options, along with how it was selected. Tags are
+// only verified to be signed if the options require signatures.
+func (v *Version) selectLatest(ctx context.Context, imageURL string, opts *api.Options, tags []api.ImageTag) (*api.ImageTag, decision, error) {
+	switch {
+	// If pinned to a floating tag, only detect drift
+	case opts.FloatingTag != nil:
+		tag := floatingTag(opts, tags)
+		if tag == nil {
+			return nil, "", versionerrors.NewVersionErrorNotFound("%s: failed to find floating tag %q",
+				imageURL, *opts.FloatingTag)
+		}
+
+		return tag, decisionFloatingTag, nil
+
+	// If following a pointer tag, select the version it points to
+	case opts.PointerTag != nil:
+		tag, err := pointerTag(opts, tags)
+		if err != nil {
+			return nil, "", err
+		}
+
+		if tag == nil {
+			return nil, "", versionerrors.NewVersionErrorNotFound("%s: failed to find a version sharing the digest of pointer tag %q",
+				imageURL, *opts.PointerTag)
+		}
+
+		return tag, decisionPointerTag, nil
+
+	// If UseSHA then return early
+	case opts.UseSHA:
+		tags, err := v.configTimestamps(ctx, imageURL, opts, tags)
+		if err != nil {
+			return nil, "", err
+		}
+
+		tag, err := v.latestVerifiedTag(ctx, imageURL, opts, tags, func(tags []api.ImageTag) (*api.ImageTag, error) {
+			return latestSHA(tagsBefore(opts, tags))
+		})
+		if err != nil {
+			return nil, "", err
+		}
+
 		if tag == nil {
-			return nil, versionerrors.NewVersionErrorNotFound("%s: failed to find latest image based on SHA",
+			return nil, "", versionerrors.NewVersionErrorNotFound("%s: failed to find latest image based on SHA",
+				imageURL)
+		}
+
+		if opts.RequireTimestamp && tag.Timestamp.IsZero() {
+			return nil, "", versionerrors.NewVersionErrorNotFound("%s: failed to find latest image based on SHA: no tags have a timestamp",
 				imageURL)
 		}
 
-	} else {
-		tag, err = latestSemver(opts, tags)
+		return tag, decisionSHA, nil
+
+	case opts.TagMapper != nil:
+		tag, err := v.latestVerifiedTag(ctx, imageURL, opts, tags, func(tags []api.ImageTag) (*api.ImageTag, error) {
+			return latestMapped(opts, tags), nil
+		})
 		if err != nil {
-			return nil, err
+			return nil, "", err
 		}
 
 		if tag == nil {
-			optsBytes, _ := json.Marshal(opts)
-			return nil, versionerrors.NewVersionErrorNotFound("%s: no tags found with these option constraints: %s",
-				imageURL, optsBytes)
+			return nil, "", versionerrors.NewVersionErrorNotFound("%s: no tags found mapped by the tag mapper",
+				imageURL)
+		}
+
+		return tag, decisionTagMapper, nil
+
+	default:
+		d := decisionSemver
+		tag, err := v.latestVerifiedTag(ctx, imageURL, opts, tags, func(tags []api.ImageTag) (*api.ImageTag, error) {
+			return selectLatestSemver(imageURL, opts, tags)
+		})
+		if versionerrors.IsNoVersionFound(err) && opts.FallbackToSHA {
+			d = decisionFallbackSHA
+
+			var candidates []api.ImageTag
+			candidates, err = v.configTimestamps(ctx, imageURL, opts, nonSemverCandidates(opts, tags))
+			if err == nil {
+				tag, err = v.latestVerifiedTag(ctx, imageURL, opts, candidates, func(tags []api.ImageTag) (*api.ImageTag, error) {
+					return latestNonSemverSHA(imageURL, opts, tags)
+				})
+			}
+		}
+		if err != nil {
+			return nil, "", err
 		}
+
+		return tag, d, nil
+	}
+}
+
+// LatestTagFromTags will return the latest of the given tags according to the
+// given options, the same as LatestTagFromImage, without making any registry
+// requests or using any cache. This allows the latest tag to be resolved
+// offline from a previously captured list of tags. The returned tag is a copy.
+// Lookups requiring signatures, manifest media types or verified manifests
+// return an error, as images cannot be verified offline, and the
+// ConstraintFunc is not consulted as the tags have no image.
+// UseConfigTimestamp is ignored, so the given timestamps are used.
+func (v *Version) LatestTagFromTags(opts *api.Options, tags []api.ImageTag) (*api.ImageTag, error) {
+	opts = v.lookupOptions(opts)
+	if opts.RequireSignature {
+		return nil, errors.New("cannot verify signatures of a tag list")
+	}
+	if len(opts.RequireManifestMediaTypes) > 0 || opts.VerifyManifest {
+		return nil, errors.New("cannot fetch the manifests of a tag list")
 	}
+	// The timestamps of the tag list are used as given.
+	opts.UseConfigTimestamp = false
 
-	return tag, err
+	tag, _, err := v.selectLatest(context.Background(), "tag list", opts, tags)
+	if err != nil {
+		return nil, err
+	}
+
+	latest := *tag
+	return &latest, nil
 }
 
-// Fetch returns the given image tags for a given image URL.
-func (v *Version) Fetch(ctx context.Context, imageURL string, _ *api.Options) (interface{}, error) {
-	// fetch tags from image URL
-	tags, err := v.client.Tags(ctx, imageURL)
+// lookupOptions returns a copy of the given options merged with the default
+// options, so that mutations by the caller during the lookup have no effect.
+func (v *Version) lookupOptions(opts *api.Options) *api.Options {
+	return opts.WithDefaults(v.opts.DefaultOptions)
+}
+
+// LatestTagFromCurrent will return the latest tag given an imageURL, the same
+// as LatestTagFromImage. If RejectDowngrade is set in the options, and the
+// selected tag is a lower version than the given current tag, an
+// ErrorDowngrade is returned. Downgrades are only detected when selecting by
+// version, not by SHA or floating tag.
+func (v *Version) LatestTagFromCurrent(ctx context.Context, imageURL, current string, opts *api.Options) (*api.ImageTag, error) {
+	opts = v.lookupOptions(opts)
+
+	tag, err := v.LatestTagFromImage(ctx, imageURL, opts)
 	if err != nil {
-		return nil, fmt.Errorf("failed to get tags from remote registry for %q: %s",
-			imageURL, err)
+		return nil, err
 	}
 
-	// respond with no version found if no manifests were found to prevent
-	// needlessly querying a bad URL.
-	if len(tags) == 0 {
-		return nil, versionerrors.NewVersionErrorNotFound("no tags found for given image URL: %q", imageURL)
+	if !opts.RejectDowngrade || opts.UseSHA || opts.FloatingTag != nil {
+		return tag, nil
 	}
 
-	return tags, nil
+	versionIndex, err := versionExtractorIndex(opts)
+	if err != nil {
+		return nil, err
+	}
+
+	currentV, _ := parseTag(opts, versionIndex, current)
+	latestV, _ := parseTag(opts, versionIndex, tag.Tag)
+	if currentV == nil || latestV == nil || !currentV.IsValid() || !latestV.IsValid() {
+		return tag, nil
+	}
+
+	if versionLessThan(opts, latestV, currentV) {
+		return nil, versionerrors.NewErrorDowngrade(current, tag.Tag)
+	}
+
+	return tag, nil
 }
 
-// latestSemver will return the latest ImageTag based on the given options
-// restriction, using semver. This should not be used is UseSHA has been
-// enabled.
-// TODO: add tests..
-func latestSemver(opts *api.Options, tags []api.ImageTag) (*api.ImageTag, error) {
-	var (
-		latestImageTag *api.ImageTag
-		latestV        *semver.SemVer
-	)
+// ParseTags will return every tag of the given image URL parsed as a version,
+// along with whether each tag passes the given options, without selecting the
+// latest.
+func (v *Version) ParseTags(ctx context.Context, opts *api.Options, imageURL string) ([]ParsedTag, error) {
+	opts = v.lookupOptions(opts)
 
-	for i := range tags {
-		v := semver.Parse(tags[i].Tag)
+	_, tags, _, err := v.imageTags(ctx, imageURL, opts)
+	if err != nil {
+		return nil, err
+	}
 
-		// If regex enabled continue here.
-		// If we match, and is less than, update latest.
-		if opts.RegexMatcher != nil {
-			if opts.RegexMatcher.MatchString(tags[i].Tag) &&
-				(latestV == nil || latestV.LessThan(v)) {
-				latestV = v
-				latestImageTag = &tags[i]
-			}
+	versionIndex, err := versionExtractorIndex(opts)
+	if err != nil {
+		return nil, err
+	}
 
-			continue
+	parsed := make([]ParsedTag, len(tags))
+	for i, tag := range tags {
+		version, matched := parseTag(opts, versionIndex, tag.Tag)
+		if version != nil && !version.IsValid() {
+			version = nil
 		}
 
-		// If we have declared we wont use metadata but version has it, continue.
-		if !opts.UseMetaData && v.HasMetaData() {
-			continue
+		parsed[i] = ParsedTag{
+			Tag:     tag.Tag,
+			Version: version,
+			Matched: matched,
 		}
+	}
 
-		if opts.PinMajor != nil && *opts.PinMajor != v.Major() {
-			continue
-		}
-		if opts.PinMinor != nil && *opts.PinMinor != v.Minor() {
-			continue
-		}
-		if opts.PinPatch != nil && *opts.PinPatch != v.Patch() {
-			continue
+	return parsed, nil
+}
+
+// RefreshImage will fetch fresh tags of the given image URL from the
+// registry, bypassing the image cache. The image cache is only updated if the
+// fetch succeeds, so the previously cached tags are kept on failure.
+func (v *Version) RefreshImage(ctx context.Context, imageURL string) ([]api.ImageTag, error) {
+	tagsI, err := v.imageCache.Refresh(ctx, ScopedImageIndex(ctx, imageURL), imageURL, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	return tagsI.([]api.ImageTag), nil
+}
+
+// ClientNameFromImage returns the name of the registry client which would
+// handle the given image URL, e.g. quay, gcr, dockerhub. No requests are made
+// to the registry.
+func (v *Version) ClientNameFromImage(imageURL string) string {
+	return v.client.ClientName(imageURL)
+}
+
+// imageTags will return the tags of the given image URL, using the image
+// cache, restricted to those promoted to the promotion URL if set. Returns
+// the image URL used for the lookup, which may be overridden by the options,
+// and whether the tags are stale, see ServeStale.
+func (v *Version) imageTags(ctx context.Context, imageURL string, opts *api.Options) (string, []api.ImageTag, bool, error) {
+	if lookup := lookupURL(imageURL, opts); lookup != imageURL {
+		v.log.Debugf("overriding image lookup %s -> %s", imageURL, lookup)
+		imageURL = lookup
+	}
+
+	tagsI, stale, err := v.imageCache.GetWithStale(ctx, ScopedImageIndex(ctx, imageURL), imageURL, nil)
+	if err != nil {
+		return imageURL, nil, false, err
+	}
+
+	tags := tagsI.([]api.ImageTag)
+	if promotion := opts.PromotionURL; promotion != nil && len(*promotion) > 0 {
+		tags, err = v.promotedTags(ctx, *promotion, tags)
+		if err != nil {
+			return imageURL, nil, false, err
 		}
+	}
 
-		// If no latest yet set
-		if latestV == nil ||
-			// If the latest set is less than
-			latestV.LessThan(v) ||
-			// If the latest is the same tag, but smaller timestamp
-			(latestV.Equal(v) && tags[i].Timestamp.After(latestImageTag.Timestamp)) {
-			latestV = v
-			latestImageTag = &tags[i]
+	return imageURL, tags, stale, nil
+}
+
+// markStale will return a copy of the given tag marked as Stale, if selected
+// from stale image tags. Tags are copied so that the cached tags and results
+// are never marked.
+func markStale(tag *api.ImageTag, stale bool) *api.ImageTag {
+	if !stale || tag == nil {
+		return tag
+	}
+
+	marked := *tag
+	marked.Stale = true
+	return &marked
+}
+
+// lookupURL returns the image URL used to look up the tags of the given image
+// URL, which may be overridden by the options.
+func lookupURL(imageURL string, opts *api.Options) string {
+	if override := opts.OverrideURL; override != nil && len(*override) > 0 {
+		return *override
+	}
+
+	return imageURL
+}
+
+// cachedResult will return the cached result for the given image URL and
+// hash index, if it was resolved from the given tags.
+func (v *Version) cachedResult(imageURL, hashIndex string, tags []api.ImageTag) (*api.ImageTag, bool) {
+	v.resultsMu.Lock()
+	defer v.resultsMu.Unlock()
+
+	item, ok := v.results[imageURL][hashIndex]
+	if !ok || !sameTags(item.tags, tags) {
+		return nil, false
+	}
+
+	return item.tag, true
+}
+
+// commitResult will store the resolved tag for the given image URL and hash
+// index.
+func (v *Version) commitResult(imageURL, hashIndex string, tags []api.ImageTag, tag *api.ImageTag) {
+	v.resultsMu.Lock()
+	defer v.resultsMu.Unlock()
+
+	if v.results[imageURL] == nil {
+		v.results[imageURL] = make(map[string]*resultItem)
+	}
+
+	v.results[imageURL][hashIndex] = &resultItem{tags: tags, tag: tag}
+}
+
+// invalidateResults will remove all cached results for the given image URL.
+func (v *Version) invalidateResults(imageURL string) {
+	v.resultsMu.Lock()
+	defer v.resultsMu.Unlock()
+
+	delete(v.results, imageURL)
+}
+
+// evictResults will remove the cached results of the image of the given image
+// cache index, once the image is garbage collected from the image cache.
+// Results of the image under other scopes are also removed, so are resolved
+// again on their next lookup.
+func (v *Version) evictResults(index string) {
+	v.invalidateResults(unscopedImageURL(index))
+}
+
+// PurgePrefix will remove all cached images whose URL starts with the given
+// prefix, such as a registry host or repository, so that their tags are
+// fetched again on next lookup. Cached manifests, configs and results of the
+// purged images are also removed. Returns the number of images purged.
+// e.g. quay.io/ or quay.io/jetstack/
+func (v *Version) PurgePrefix(prefix string) int {
+	purged := v.imageCache.PurgePrefix(prefix)
+	v.manifestCache.PurgePrefix(prefix)
+	v.configCache.PurgePrefix(prefix)
+
+	v.resultsMu.Lock()
+	defer v.resultsMu.Unlock()
+
+	for imageURL := range v.results {
+		if strings.HasPrefix(imageURL, prefix) {
+			delete(v.results, imageURL)
 		}
 	}
 
-	return latestImageTag, nil
+	return purged
 }
 
-// latestSHA will return the latest ImageTag based on image timestamps.
-func latestSHA(tags []api.ImageTag) (*api.ImageTag, error) {
-	var latestTag *api.ImageTag
+// sameTags returns true if both tag lists are backed by the same array, i.e.
+// they come from the same cache commit.
+func sameTags(a, b []api.ImageTag) bool {
+	if len(a) != len(b) {
+		return false
+	}
 
-	for i := range tags {
-		if latestTag == nil || tags[i].Timestamp.After(latestTag.Timestamp) {
-			latestTag = &tags[i]
+	return len(a) == 0 || &a[0] == &b[0]
+}
+
+// Fetch returns the given image tags for a given image URL. If the image URL
+// is a digest reference, in the form {image}@{digest}, and the image has no
+// tags, a single untagged image is returned built from the digest's manifest.
+func (v *Version) Fetch(ctx context.Context, imageURL string, _ *api.Options) (interface{}, error) {
+	var digest string
+	if split := strings.SplitN(imageURL, "@", 2); len(split) == 2 {
+		imageURL, digest = split[0], split[1]
+	}
+
+	host := client.HostFromImageURL(imageURL)
+	if v.breaker != nil {
+		if err := v.breaker.allow(host); err != nil {
+			return nil, err
 		}
 	}
 
-	return latestTag, nil
+	// fetch tags from image URL
+	tags, pageErr, err := v.listTags(ctx, imageURL)
+	if v.breaker != nil {
+		// Only failures of the registry itself count against the host, so that
+		// missing images or rejected credentials do not open its circuit. Other
+		// errors show the registry responded, so count as successes.
+		v.breaker.record(host, !clienterrors.IsRegistryFailure(err) && !clienterrors.IsRegistryFailure(pageErr))
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get tags from remote registry for %q: %w",
+			imageURL, err)
+	}
+	if pageErr != nil {
+		v.log.Warnf("%s: using %d tags listed before a page of tags failed: %s",
+			imageURL, len(tags), pageErr)
+	}
+
+	if len(tags) == 0 && len(digest) > 0 {
+		return v.digestTags(ctx, imageURL, digest)
+	}
+
+	// respond with no version found if no manifests were found to prevent
+	// needlessly querying a bad URL. The registry listed the tags without
+	// error, so the repository exists but is empty.
+	if len(tags) == 0 {
+		return nil, versionerrors.NewErrorEmptyRepository(imageURL)
+	}
+
+	if v.opts.DedupeTags {
+		tags = dedupeTags(tags)
+	}
+
+	// The tags for this image have been refreshed, so previously resolved
+	// results are no longer valid.
+	if v.opts.CacheResults {
+		v.invalidateResults(imageURL)
+	}
+
+	return tags, nil
+}
+
+// digestTags returns a single untagged image, built from the manifest of the
+// given digest.
+func (v *Version) digestTags(ctx context.Context, imageURL, digest string) ([]api.ImageTag, error) {
+	manifest, err := v.client.Manifest(ctx, imageURL, digest)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get manifest from remote registry for %q: %w",
+			imageURL+"@"+digest, err)
+	}
+
+	sha := manifest.Digest
+	if len(sha) == 0 {
+		sha = digest
+	}
+
+	return []api.ImageTag{
+		{
+			SHA:       sha,
+			Timestamp: manifest.Timestamp,
+		},
+	}, nil
+}
+
+// CalculateHashIndex returns a hash index given an imageURL and options.
+func CalculateHashIndex(imageURL string, opts *api.Options) (string, error) {
+	optsJSON, err := json.Marshal(opts)
+	if err != nil {
+		return "", fmt.Errorf("failed to marshal options: %s", err)
+	}
+
+	// Regex, constraint, ceiling and band options are not marshalled, so
+	// include their expressions, along with the name of the versioner. Each
+	// is length prefixed, or "-" when unset, so that the expressions of
+	// different options cannot run into one another.
+	var extractor, constraints, maxVersion, band, versioner *string
+	if opts != nil {
+		if opts.VersionExtractor != nil {
+			extractor = stringPtr(opts.VersionExtractor.String())
+		}
+		if opts.VersionConstraints != nil {
+			constraints = stringPtr(opts.VersionConstraints.String())
+		}
+		if opts.MaxVersion != nil {
+			maxVersion = stringPtr(opts.MaxVersion.String())
+		}
+		if opts.VersionBand != nil {
+			band = stringPtr(opts.VersionBand.String())
+		}
+		if opts.Versioner != nil {
+			versioner = stringPtr(opts.Versioner.Name())
+		}
+	}
+	for _, field := range []*string{extractor, constraints, maxVersion, band, versioner} {
+		if field == nil {
+			optsJSON = append(optsJSON, '-')
+			continue
+		}
+		optsJSON = append(optsJSON, []byte(fmt.Sprintf("%d:%s", len(*field), *field))...)
+	}
+
+	hash := fnv.New32()
+	if _, err := hash.Write(append(optsJSON, []byte(imageURL)...)); err != nil {
+		return "", fmt.Errorf("failed to calculate search hash: %s", err)
+	}
+
+	return fmt.Sprintf("%d", hash.Sum32()), nil
+}
+
+// CalculateResultHash returns a hash of the given imageURL and options, the
+// same inputs as CalculateHashIndex, along with the tag and digest of the
+// resolved result, so that changes to the answer of a check may be detected,
+// such as a floating tag being moved to a new digest. A nil result is hashed
+// as having no tag or digest.
+func CalculateResultHash(imageURL string, opts *api.Options, result *api.ImageTag) (string, error) {
+	hashIndex, err := CalculateHashIndex(imageURL, opts)
+	if err != nil {
+		return "", err
+	}
+
+	var tag, sha string
+	if result != nil {
+		tag, sha = result.Tag, result.SHA
+	}
+
+	hash := fnv.New32()
+	if _, err := hash.Write([]byte(hashIndex + "\x00" + tag + "\x00" + sha)); err != nil {
+		return "", fmt.Errorf("failed to calculate result hash: %s", err)
+	}
+
+	return fmt.Sprintf("%d", hash.Sum32()), nil
+}
+
+// TagsWithMetadata will return the tags of the given image URL, enriched with
+// the metadata of each tag's manifest. This is considerably heavier than
+// listing tags, as the manifest of every tag is fetched from the registry.
+// Manifests are cached by digest, and fetched in parallel up to the
+// ManifestConcurrency option.
+func (v *Version) TagsWithMetadata(ctx context.Context, imageURL string) ([]api.TagMetadata, error) {
+	tagsI, err := v.imageCache.Get(ctx, ScopedImageIndex(ctx, imageURL), imageURL, nil)
+	if err != nil {
+		return nil, err
+	}
+	tags := tagsI.([]api.ImageTag)
+
+	var (
+		unique []api.ImageTag
+		seen   = make(map[string]bool)
+	)
+
+	for _, tag := range tags {
+		// Skip untagged images, as well as tags that have already been seen for
+		// other platforms.
+		if len(tag.Tag) == 0 || seen[tag.Tag] {
+			continue
+		}
+		seen[tag.Tag] = true
+		unique = append(unique, tag)
+	}
+
+	manifests, err := v.manifests(ctx, imageURL, unique)
+	if err != nil {
+		return nil, err
+	}
+
+	var result []api.TagMetadata
+	for i, tag := range unique {
+		manifest := manifests[i]
+		if len(tag.SHA) == 0 {
+			tag.SHA = manifest.Digest
+		}
+
+		result = append(result, api.TagMetadata{
+			ImageTag:    tag,
+			Size:        manifest.Size,
+			Platforms:   manifest.Platforms,
+			Annotations: manifest.Annotations,
+		})
+	}
+
+	return result, nil
+}
+
+// DigestsWithMetadata will return the digests of the given image URL, along
+// with the tags that alias each digest, enriched with the metadata of the
+// digest's manifest. Digests are ordered by their first tag, as listed by the
+// registry. As with TagsWithMetadata, the manifest of every tag is fetched.
+func (v *Version) DigestsWithMetadata(ctx context.Context, imageURL string) ([]api.DigestMetadata, error) {
+	tags, err := v.TagsWithMetadata(ctx, imageURL)
+	if err != nil {
+		return nil, err
+	}
+
+	var (
+		digests []api.DigestMetadata
+		indexes = make(map[string]int)
+	)
+
+	for _, tag := range tags {
+		if i, ok := indexes[tag.SHA]; ok {
+			digests[i].Tags = append(digests[i].Tags, tag.Tag)
+
+			// Use the most recent timestamp of all aliases.
+			if tag.Timestamp.After(digests[i].Timestamp) {
+				digests[i].Timestamp = tag.Timestamp
+			}
+
+			continue
+		}
+
+		indexes[tag.SHA] = len(digests)
+		digests = append(digests, api.DigestMetadata{
+			SHA:         tag.SHA,
+			Tags:        []string{tag.Tag},
+			Timestamp:   tag.Timestamp,
+			Size:        tag.Size,
+			Platforms:   tag.Platforms,
+			Annotations: tag.Annotations,
+		})
+	}
+
+	return digests, nil
+}
+
+// manifest will return the manifest of the given image tag, using the
+// manifest cache. Tags are referenced by their digest where available.
+func (v *Version) manifest(ctx context.Context, imageURL string, tag api.ImageTag) (*api.ImageManifest, error) {
+	reference := tag.SHA
+	if len(reference) == 0 {
+		reference = tag.Tag
+	}
+
+	index := imageURL + "@" + reference
+	manifest, err := v.manifestCache.Get(ctx, index, index, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	return manifest.(*api.ImageManifest), nil
+}
+
+// Fetch returns the image manifest for a given image URL and reference index,
+// in the form {imageURL}@{reference}.
+func (m *manifestFetcher) Fetch(ctx context.Context, index string, _ *api.Options) (interface{}, error) {
+	lastAtIndex := strings.LastIndex(index, "@")
+	if lastAtIndex == -1 {
+		return nil, fmt.Errorf("invalid manifest index: %q", index)
+	}
+
+	return m.v.client.Manifest(ctx, index[:lastAtIndex], index[lastAtIndex+1:])
+}
+
+// latestSemver will return the latest ImageTag based on the given options
+// restriction, using semver. This should not be used is UseSHA has been
+// enabled.
+func latestSemver(opts *api.Options, tags []api.ImageTag) (*api.ImageTag, error) {
+	var (
+		latestImageTag *api.ImageTag
+		latestV        *semver.SemVer
+	)
+
+	versionIndex, err := versionExtractorIndex(opts)
+	if err != nil {
+		return nil, err
+	}
+
+	tags = semverTags(opts, versionIndex, tags)
+
+	for i := range tags {
+		v, ok := parseTag(opts, versionIndex, tags[i].Tag)
+		if !ok {
+			continue
+		}
+
+		// If regex enabled continue here.
+		// If is less than, update latest.
+		if opts.RegexMatcher != nil {
+			if latestV == nil || versionLessThan(opts, latestV, v) {
+				latestV = v
+				latestImageTag = &tags[i]
+			}
+
+			continue
+		}
+
+		// If no latest yet set
+		if latestV == nil ||
+			// If the latest set is less than
+			versionLessThan(opts, latestV, v) ||
+			// If the latest is the same tag, but smaller timestamp
+			(latestV.Equal(v) && tags[i].Timestamp.After(latestImageTag.Timestamp)) {
+			latestV = v
+			latestImageTag = &tags[i]
+		}
+	}
+
+	if opts.UseNewerPreRelease && !opts.UseMetaData && latestV != nil {
+		if preRelease := newerPreRelease(opts, versionIndex, latestV, tags); preRelease != nil {
+			return preRelease, nil
+		}
+	}
+
+	// No stable version passes the options, so fall back to the pre-releases.
+	if opts.FallbackToPreRelease && !opts.UseMetaData && len(opts.PreReleaseChannel) == 0 && latestV == nil {
+		preOpts := *opts
+		preOpts.UseMetaData = true
+		return latestSemver(&preOpts, tags)
+	}
+
+	return latestImageTag, nil
+}
+
+// semverTags will return the given tags which may be selected by version,
+// restricted to the platform and without pre-releases of minors with no
+// stable version, if set in the options. Whether each tag passes the options
+// is left to the caller.
+func semverTags(opts *api.Options, versionIndex int, tags []api.ImageTag) []api.ImageTag {
+	if len(opts.Architecture) > 0 || len(opts.OS) > 0 {
+		tags = platformTags(opts, tags)
+	}
+	if opts.SkipPreReleaseMinors && opts.RegexMatcher == nil && len(opts.PreReleaseChannel) == 0 {
+		tags = withoutPreReleaseMinors(opts, versionIndex, tags)
+	}
+
+	return tags
+}
+
+// minorVersion is the major and minor version of a SemVer.
+type minorVersion struct {
+	major, minor int64
+}
+
+// withoutPreReleaseMinors will return the given tags without the pre-releases
+// of major and minor versions which have no stable version.
+func withoutPreReleaseMinors(opts *api.Options, versionIndex int, tags []api.ImageTag) []api.ImageTag {
+	versions := make([]*semver.SemVer, len(tags))
+	stableMinors := make(map[minorVersion]bool)
+
+	for i := range tags {
+		// Whether the tag passes the options does not matter, only whether a
+		// stable version of its minor exists.
+		v, _ := parseTag(opts, versionIndex, tags[i].Tag)
+		if v == nil || !v.IsValid() {
+			continue
+		}
+
+		versions[i] = v
+		if !isPreRelease(v) {
+			stableMinors[minorVersion{v.Major(), v.Minor()}] = true
+		}
+	}
+
+	var filtered []api.ImageTag
+	for i, v := range versions {
+		if v != nil && isPreRelease(v) && !stableMinors[minorVersion{v.Major(), v.Minor()}] {
+			continue
+		}
+
+		filtered = append(filtered, tags[i])
+	}
+
+	return filtered
+}
+
+// isPreRelease returns whether the given version is a pre-release, where build
+// metadata alone does not make a pre-release.
+func isPreRelease(v *semver.SemVer) bool {
+	return v.HasMetaData() && !v.HasOnlyBuildMetaData()
+}
+
+// newerPreRelease will return the latest pre-release whose major, minor, and
+// patch version is greater than the given stable version. Returns nil if no
+// such pre-release exists.
+func newerPreRelease(opts *api.Options, versionIndex int, stable *semver.SemVer, tags []api.ImageTag) *api.ImageTag {
+	var (
+		latestImageTag *api.ImageTag
+		latestV        *semver.SemVer
+	)
+
+	preOpts := *opts
+	preOpts.UseMetaData = true
+
+	for i := range tags {
+		v, ok := parseTag(&preOpts, versionIndex, tags[i].Tag)
+		if !ok || !v.HasMetaData() || !stable.CoreLessThan(v) {
+			continue
+		}
+
+		if latestV == nil || versioner(opts).LessThan(latestV, v) {
+			latestV = v
+			latestImageTag = &tags[i]
+		}
+	}
+
+	return latestImageTag
+}
+
+// versionExtractorIndex returns the index of the "version" capture group of
+// the options version extractor, or -1 if not set.
+func versionExtractorIndex(opts *api.Options) (int, error) {
+	if opts.VersionExtractor == nil {
+		return -1, nil
+	}
+
+	versionIndex := opts.VersionExtractor.SubexpIndex("version")
+	if versionIndex == -1 {
+		return -1, fmt.Errorf("version extractor %q has no named capture group \"version\"",
+			opts.VersionExtractor)
+	}
+
+	return versionIndex, nil
+}
+
+// parseTag will parse the version of the given tag, and return whether it
+// passes the option filters. Returns nil if no version could be extracted
+// from the tag. A tag must pass every filter which is set, which are applied
+// in order of precedence: denied versions, the regex, the version
+// constraints, the pins, and then the pre-release channel or metadata. Tags
+// matching the regex are permitted to have metadata without UseMetaData, as
+// the regex decides which metadata is accepted.
+func parseTag(opts *api.Options, versionIndex int, tag string) (*semver.SemVer, bool) {
+	version := tag
+
+	// If extracting the version, only parse the captured version.
+	if versionIndex > -1 {
+		match := opts.VersionExtractor.FindStringSubmatch(version)
+		if len(match) == 0 || len(match[versionIndex]) == 0 {
+			return nil, false
+		}
+
+		version = match[versionIndex]
+	}
+
+	v := versioner(opts).Parse(semver.Sanitize(version, semver.SanitizeRule(opts.SanitizeRule)))
+
+	// Denied versions are never selected, even if matched by regex.
+	if deniedVersion(opts, v) {
+		return v, false
+	}
+
+	if opts.RegexMatcher != nil && !opts.RegexMatcher.MatchString(tag) {
+		return v, false
+	}
+
+	if opts.VersionConstraints != nil && !opts.VersionConstraints.Check(v) {
+		return v, false
+	}
+
+	if opts.MaxVersion != nil && aboveMaxVersion(opts, v) {
+		return v, false
+	}
+
+	if opts.VersionBand != nil && !opts.VersionBand.Contains(v) {
+		return v, false
+	}
+
+	if opts.PinMajor != nil && *opts.PinMajor != v.Major() {
+		return v, false
+	}
+	if opts.PinMinor != nil && *opts.PinMinor != v.Minor() {
+		return v, false
+	}
+	if opts.PinPatch != nil {
+		if opts.PinPatchMinimum {
+			if v.Patch() < *opts.PinPatch {
+				return v, false
+			}
+		} else if *opts.PinPatch != v.Patch() {
+			return v, false
+		}
+	}
+
+	// If pinned to a pre-release channel, only its pre-releases are permitted.
+	// Otherwise, if we have declared we wont use metadata but version has it,
+	// continue. Versions sanitized to build metadata are not considered
+	// pre-releases, and versions with the preferred suffix, or matching the
+	// regex, are always permitted.
+	isBuild := semver.SanitizeRule(opts.SanitizeRule) == semver.SanitizeBuild && v.HasOnlyBuildMetaData()
+	if len(opts.PreReleaseChannel) > 0 {
+		if _, ok := v.PreReleaseIdentifier(opts.PreReleaseChannel); !ok {
+			return v, false
+		}
+	} else if !opts.UseMetaData && v.HasMetaData() && !isBuild && !hasPreferredSuffix(opts, v) && opts.RegexMatcher == nil {
+		return v, false
+	}
+
+	return v, true
+}
+
+// deniedVersion returns whether the given version is one of the deny versions
+// of the options, having the same major, minor and patch version, and metadata.
+func deniedVersion(opts *api.Options, v *semver.SemVer) bool {
+	if !v.IsValid() {
+		return false
+	}
+
+	for _, deny := range opts.DenyVersions {
+		d := semver.Parse(deny)
+		if d.IsValid() && !d.CoreLessThan(v) && !v.CoreLessThan(d) && d.MetaData() == v.MetaData() {
+			return true
+		}
+	}
+
+	return false
+}
+
+// aboveMaxVersion returns whether the given version is strictly greater than
+// the MaxVersion of the options. Major, minor and patch versions are compared
+// first, as a stable version is never less than a pre-release by the default
+// versioner.
+func aboveMaxVersion(opts *api.Options, v *semver.SemVer) bool {
+	max := opts.MaxVersion
+	if max.CoreLessThan(v) {
+		return true
+	}
+	if v.CoreLessThan(max) {
+		return false
+	}
+
+	return versioner(opts).LessThan(max, v)
+}
+
+// versionLessThan will return true if version a is less than version b. If
+// both have the same major, minor and patch version, versions with the
+// preferred suffix are greater than those without. Pre-releases of the pinned
+// channel are ordered by their identifier first.
+func versionLessThan(opts *api.Options, a, b *semver.SemVer) bool {
+	if len(opts.PreReleaseChannel) > 0 {
+		aID, aOK := a.PreReleaseIdentifier(opts.PreReleaseChannel)
+		bID, bOK := b.PreReleaseIdentifier(opts.PreReleaseChannel)
+		if aOK && bOK {
+			if cmp := compareIdentifiers(aID, bID); cmp != 0 {
+				return cmp < 0
+			}
+		}
+	}
+
+	if len(opts.PreferSuffix) > 0 && !a.CoreLessThan(b) && !b.CoreLessThan(a) {
+		if aPreferred, bPreferred := hasPreferredSuffix(opts, a), hasPreferredSuffix(opts, b); aPreferred != bPreferred {
+			return bPreferred
+		}
+	}
+
+	return versioner(opts).LessThan(a, b)
+}
+
+// versioner returns the Versioner of the given options, or the default
+// versioner if unset.
+func versioner(opts *api.Options) semver.Versioner {
+	if opts.Versioner != nil {
+		return opts.Versioner
+	}
+
+	return semver.Default
+}
+
+// compareIdentifiers will compare the numeric components of two pre-release
+// identifiers in order, returning -1, 0 or 1 if a is less than, equal to, or
+// greater than b. Where all shared components are equal, the identifier with
+// fewer components is less.
+func compareIdentifiers(a, b []int64) int {
+	for i := 0; i < len(a) && i < len(b); i++ {
+		if a[i] != b[i] {
+			if a[i] < b[i] {
+				return -1
+			}
+			return 1
+		}
+	}
+
+	switch {
+	case len(a) < len(b):
+		return -1
+	case len(a) > len(b):
+		return 1
+	default:
+		return 0
+	}
+}
+
+// hasPreferredSuffix returns whether the given version has the preferred
+// suffix of the options.
+func hasPreferredSuffix(opts *api.Options, v *semver.SemVer) bool {
+	return len(opts.PreferSuffix) > 0 && v.MetaData() == opts.PreferSuffix
+}
+
+// floatingTag will return the image of the floating tag set in the options,
+// marked as drifted if its digest differs from the previously observed
+// digest. If the tag is listed for multiple platforms, the most recent is
+// used. Returns nil if the tag does not exist.
+func floatingTag(opts *api.Options, tags []api.ImageTag) *api.ImageTag {
+	latestTag := namedTag(tags, *opts.FloatingTag)
+	if latestTag == nil {
+		return nil
+	}
+
+	// Copy so the drift of this lookup is not written to the cached tags.
+	tag := *latestTag
+	tag.Drifted = len(opts.FloatingTagDigest) > 0 && tag.SHA != opts.FloatingTagDigest
+
+	return &tag
+}
+
+// namedTag will return the given tag of the given name. If the tag is listed
+// for multiple platforms, the most recent is returned. Returns nil if the tag
+// does not exist.
+func namedTag(tags []api.ImageTag, name string) *api.ImageTag {
+	var latestTag *api.ImageTag
+
+	for i := range tags {
+		if tags[i].Tag != name {
+			continue
+		}
+
+		if latestTag == nil || tags[i].Timestamp.After(latestTag.Timestamp) {
+			latestTag = &tags[i]
+		}
+	}
+
+	return latestTag
+}
+
+// selectLatestSemver will return the latest of the given tags by version,
+// restricted to the candidate tags if set. Returns a not found error if no tag
+// passes the options.
+func selectLatestSemver(imageURL string, opts *api.Options, tags []api.ImageTag) (*api.ImageTag, error) {
+	tag, err := latestCandidateSemver(opts, tags)
+	if err != nil {
+		return nil, err
+	}
+
+	if tag == nil {
+		return nil, noVersionFound(imageURL, opts, tags)
+	}
+
+	if preRelease := excludedPreRelease(opts, tag, tags); preRelease != nil {
+		// Copy so the pre-release is not written to the cached tags.
+		withPreRelease := *tag
+		withPreRelease.NewerPreRelease = preRelease
+		tag = &withPreRelease
+	}
+
+	if opts.StripBuildMetadata {
+		tag = stripBuildMetadata(tag)
+	}
+
+	return tag, nil
+}
+
+// excludedPreRelease will return a copy of the latest of the given tags which
+// is a pre-release of a greater major, minor or patch version than the
+// selected tag, and so was excluded from selection by not using metadata.
+// Returns nil if metadata is used, or no such pre-release exists.
+func excludedPreRelease(opts *api.Options, selected *api.ImageTag, tags []api.ImageTag) *api.ImageTag {
+	if opts.UseMetaData || len(opts.PreReleaseChannel) > 0 {
+		return nil
+	}
+
+	versionIndex, err := versionExtractorIndex(opts)
+	if err != nil {
+		return nil
+	}
+
+	selectedV, ok := parseTag(opts, versionIndex, selected.Tag)
+	if !ok {
+		return nil
+	}
+
+	preRelease := newerPreRelease(opts, versionIndex, selectedV, tags)
+	if preRelease == nil {
+		return nil
+	}
+
+	excluded := *preRelease
+	return &excluded
+}
+
+// noVersionFound returns the error of no latest version being found in the
+// given tags, being an ErrorNoSemverTags if no tag is a valid version, or an
+// ErrorNoMatchingVersion if no version passes the options.
+func noVersionFound(imageURL string, opts *api.Options, tags []api.ImageTag) error {
+	if !hasSemverTag(opts, tags) {
+		return versionerrors.NewErrorNoSemverTags(imageURL)
+	}
+
+	optsBytes, _ := json.Marshal(opts)
+	return versionerrors.NewErrorNoMatchingVersion("%s: no tags found with these option constraints: %s",
+		imageURL, optsBytes)
+}
+
+// hasSemverTag returns whether any of the given tags has a valid version,
+// regardless of whether it passes the options.
+func hasSemverTag(opts *api.Options, tags []api.ImageTag) bool {
+	versionIndex, err := versionExtractorIndex(opts)
+	if err != nil {
+		return false
+	}
+
+	for _, tag := range tags {
+		if v, _ := parseTag(opts, versionIndex, tag.Tag); v != nil && v.IsValid() {
+			return true
+		}
+	}
+
+	return false
+}
+
+// latestCandidateSemver will return the latest of the given tags by version,
+// restricted to the candidate tags, Helm charts, and tags before the before
+// time, if set.
+func latestCandidateSemver(opts *api.Options, tags []api.ImageTag) (*api.ImageTag, error) {
+	return latestSemver(opts, semverCandidates(opts, tags))
+}
+
+// semverCandidates will return the given tags restricted to the candidate
+// tags, Helm charts, and tags before the before time, if set.
+func semverCandidates(opts *api.Options, tags []api.ImageTag) []api.ImageTag {
+	if len(opts.CandidateTags) > 0 {
+		tags = candidateTags(opts, tags)
+	}
+	if opts.HelmChartsOnly {
+		tags = helmChartTags(tags)
+	}
+
+	return tagsBefore(opts, tags)
+}
+
+// candidateTags will return the given tags which are listed in the candidate
+// tags of the options.
+func candidateTags(opts *api.Options, tags []api.ImageTag) []api.ImageTag {
+	allowed := make(map[string]bool, len(opts.CandidateTags))
+	for _, tag := range opts.CandidateTags {
+		allowed[tag] = true
+	}
+
+	var candidates []api.ImageTag
+	for _, tag := range tags {
+		if allowed[tag.Tag] {
+			candidates = append(candidates, tag)
+		}
+	}
+
+	return candidates
+}
+
+// latestNonSemverSHA will return the latest of the given tags which are not
+// valid versions, based on image timestamps, restricted to the candidate tags,
+// Helm charts, and tags before the before time, if set.
+func latestNonSemverSHA(imageURL string, opts *api.Options, tags []api.ImageTag) (*api.ImageTag, error) {
+	tag, err := latestSHA(tagsBefore(opts, nonSemverCandidates(opts, tags)))
+	if err != nil {
+		return nil, err
+	}
+
+	if tag == nil {
+		optsBytes, _ := json.Marshal(opts)
+		return nil, versionerrors.NewVersionErrorNotFound("%s: no tags found with these option constraints, or without a version: %s",
+			imageURL, optsBytes)
+	}
+
+	return tag, nil
+}
+
+// nonSemverCandidates will return the given tags which are not valid versions,
+// restricted to the candidate tags and Helm charts if set in the options.
+func nonSemverCandidates(opts *api.Options, tags []api.ImageTag) []api.ImageTag {
+	if len(opts.CandidateTags) > 0 {
+		tags = candidateTags(opts, tags)
+	}
+	if opts.HelmChartsOnly {
+		tags = helmChartTags(tags)
+	}
+
+	var nonSemver []api.ImageTag
+	for _, tag := range tags {
+		if !versioner(opts).Parse(tag.Tag).IsValid() {
+			nonSemver = append(nonSemver, tag)
+		}
+	}
+
+	return nonSemver
+}
+
+// tagsBefore will return the given tags whose timestamp is at or before the
+// before time of the options, or all tags if not set. Tags without a timestamp
+// are never returned if set.
+func tagsBefore(opts *api.Options, tags []api.ImageTag) []api.ImageTag {
+	if opts.BeforeTime == nil {
+		return tags
+	}
+
+	var before []api.ImageTag
+	for _, tag := range tags {
+		if !tag.Timestamp.IsZero() && !tag.Timestamp.After(*opts.BeforeTime) {
+			before = append(before, tag)
+		}
+	}
+
+	return before
+}
+
+// helmChartTags will return the given tags which are Helm charts.
+func helmChartTags(tags []api.ImageTag) []api.ImageTag {
+	var charts []api.ImageTag
+	for _, tag := range tags {
+		if tag.ConfigMediaType == api.HelmChartConfigMediaType {
+			charts = append(charts, tag)
+		}
+	}
+
+	return charts
+}
+
+// stripBuildMetadata will return a copy of the given tag, with build metadata
+// removed from its version.
+func stripBuildMetadata(tag *api.ImageTag) *api.ImageTag {
+	stripped := *tag
+	stripped.Tag = semver.StripBuildMetaData(tag.Tag)
+	return &stripped
+}
+
+// latestSHA will return the latest ImageTag based on image timestamps. Tags
+// without a timestamp are only returned if no tag has one, in which case the
+// first tag is returned.
+func latestSHA(tags []api.ImageTag) (*api.ImageTag, error) {
+	var latestTag *api.ImageTag
+
+	for i := range tags {
+		if tags[i].Timestamp.IsZero() && latestTag != nil {
+			continue
+		}
+
+		if latestTag == nil || tags[i].Timestamp.After(latestTag.Timestamp) {
+			latestTag = &tags[i]
+		}
+	}
+
+	return latestTag, nil
+}
+
+func stringPtr(s string) *string {
+	return &s
 }
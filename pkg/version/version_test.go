@@ -0,0 +1,2173 @@
+package version
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"reflect"
+	"regexp"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/sirupsen/logrus"
+
+	"github.com/jetstack/version-checker/pkg/api"
+	"github.com/jetstack/version-checker/pkg/client"
+	clienterrors "github.com/jetstack/version-checker/pkg/client/errors"
+	selfhostederrors "github.com/jetstack/version-checker/pkg/client/selfhosted/errors"
+	versionerrors "github.com/jetstack/version-checker/pkg/version/errors"
+	"github.com/jetstack/version-checker/pkg/version/semver"
+)
+
+// fakeClient is a registryClient which returns a fixed list of tags and
+// manifests, and counts the number of calls made.
+type fakeClient struct {
+	mu    sync.Mutex
+	calls int
+	tags  []api.ImageTag
+	err   error
+
+	manifestCalls int
+	manifests     map[string]*api.ImageManifest
+
+	// manifestDelay is the latency of each manifest request.
+	manifestDelay time.Duration
+
+	// inFlight and maxInFlight track parallel manifest requests.
+	inFlight, maxInFlight int
+
+	configCalls map[string]int
+	configs     map[string]*api.ImageConfig
+
+	// sortedPages are the pages of tags sorted by descending version. Sorted
+	// listing is unsupported if nil.
+	sortedPages [][]api.ImageTag
+	pageCalls   int
+
+	// pages are the pages of tags listed by TagPages. The tags are listed as
+	// a single page if nil.
+	pages [][]api.ImageTag
+
+	// pagesErr, if set, is returned by TagPages once all pages are listed,
+	// as a page failing after the listed pages.
+	pagesErr error
+
+	// onTags, if set, is called before each tags request is served.
+	onTags func()
+}
+
+func (f *fakeClient) Tags(context.Context, string) ([]api.ImageTag, error) {
+	if f.onTags != nil {
+		f.onTags()
+	}
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	f.calls++
+
+	if f.err != nil {
+		return nil, f.err
+	}
+	// Listing all tags fails if any page fails.
+	if f.pagesErr != nil {
+		return nil, f.pagesErr
+	}
+
+	// Return a copy so each fetch is a distinct cache commit.
+	return append([]api.ImageTag(nil), f.tags...), nil
+}
+
+func (f *fakeClient) SortedTags(_ context.Context, _ string, page func([]api.ImageTag) bool) (bool, error) {
+	if f.sortedPages == nil {
+		return false, nil
+	}
+
+	for _, tags := range f.sortedPages {
+		f.mu.Lock()
+		f.pageCalls++
+		err := f.err
+		f.mu.Unlock()
+
+		if err != nil {
+			return true, err
+		}
+
+		if !page(tags) {
+			break
+		}
+	}
+
+	return true, nil
+}
+
+func (f *fakeClient) TagPages(_ context.Context, _ string, page func([]api.ImageTag) error) error {
+	f.mu.Lock()
+	pages, err := f.pages, f.err
+	if pages == nil {
+		pages = [][]api.ImageTag{f.tags}
+	}
+	f.mu.Unlock()
+
+	for _, tags := range pages {
+		f.mu.Lock()
+		f.pageCalls++
+		f.mu.Unlock()
+
+		if err != nil {
+			return err
+		}
+
+		if err := page(tags); err != nil {
+			return err
+		}
+	}
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	return f.pagesErr
+}
+
+func (f *fakeClient) Validate(context.Context, string) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	return f.err
+}
+
+func (f *fakeClient) ClientName(string) string {
+	return "fake"
+}
+
+func (f *fakeClient) Manifest(_ context.Context, _, reference string) (*api.ImageManifest, error) {
+	f.mu.Lock()
+	f.inFlight++
+	if f.inFlight > f.maxInFlight {
+		f.maxInFlight = f.inFlight
+	}
+	f.mu.Unlock()
+
+	time.Sleep(f.manifestDelay)
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	f.inFlight--
+	f.manifestCalls++
+
+	manifest, ok := f.manifests[reference]
+	if !ok {
+		return nil, clienterrors.NewErrorImageNotFound("fake", reference)
+	}
+
+	return manifest, nil
+}
+
+func (f *fakeClient) Config(_ context.Context, _, reference string) (*api.ImageConfig, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if f.configCalls == nil {
+		f.configCalls = make(map[string]int)
+	}
+	f.configCalls[reference]++
+
+	config, ok := f.configs[reference]
+	if !ok {
+		return nil, fmt.Errorf("config not found: %s", reference)
+	}
+
+	return config, nil
+}
+
+func newTestVersion(client registryClient, cacheTimeout time.Duration, opts Options) *Version {
+	log := logrus.New()
+	log.SetLevel(logrus.PanicLevel)
+
+	v := New(logrus.NewEntry(log), nil, cacheTimeout, opts)
+	v.client = client
+
+	return v
+}
+
+func TestCacheResults(t *testing.T) {
+	client := &fakeClient{
+		tags: []api.ImageTag{
+			{Tag: "v0.1.0"},
+			{Tag: "v0.2.0"},
+		},
+	}
+
+	v := newTestVersion(client, time.Hour, Options{CacheResults: true})
+	opts := new(api.Options)
+
+	tag, err := v.LatestTagFromImage(context.TODO(), "jetstack/version-checker", opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if tag.Tag != "v0.2.0" {
+		t.Errorf("unexpected tag, exp=%s got=%s", "v0.2.0", tag.Tag)
+	}
+
+	hashIndex, err := CalculateHashIndex("jetstack/version-checker", opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := v.results["jetstack/version-checker"][hashIndex]; !ok {
+		t.Error("expected result to be cached")
+	}
+
+	again, err := v.LatestTagFromImage(context.TODO(), "jetstack/version-checker", opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if again != tag {
+		t.Error("expected cached result to be returned")
+	}
+
+	// Refresh the tags in the cache, which should invalidate the result.
+	client.tags = append(client.tags, api.ImageTag{Tag: "v0.3.0"})
+	if _, err := v.Fetch(context.TODO(), "jetstack/version-checker", nil); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := v.results["jetstack/version-checker"]; ok {
+		t.Error("expected cached results to be invalidated after fetch")
+	}
+}
+
+func TestCacheResultsEvicted(t *testing.T) {
+	client := &fakeClient{
+		tags: []api.ImageTag{{Tag: "v0.1.0"}},
+	}
+
+	v := newTestVersion(client, time.Hour, Options{CacheResults: true})
+	tenant := api.ContextWithCredentials(context.TODO(), &api.Credentials{Token: "tenant"})
+
+	for _, imageURL := range []string{"jetstack/version-checker", "jetstack/cert-manager"} {
+		if _, err := v.LatestTagFromImage(tenant, imageURL, new(api.Options)); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// Evicting the scoped image cache index should remove the image's results.
+	v.evictResults(ScopedImageIndex(tenant, "jetstack/version-checker"))
+	if _, ok := v.results["jetstack/version-checker"]; ok {
+		t.Error("expected cached results to be removed after eviction")
+	}
+	if _, ok := v.results["jetstack/cert-manager"]; !ok {
+		t.Error("expected cached results of other images to be retained")
+	}
+}
+
+func TestCacheResultsInvalidatedOnRefresh(t *testing.T) {
+	client := &fakeClient{
+		tags: []api.ImageTag{{Tag: "v0.1.0"}},
+	}
+
+	// A zero cache timeout means every lookup will refresh the tags.
+	v := newTestVersion(client, 0, Options{CacheResults: true})
+
+	tag, err := v.LatestTagFromImage(context.TODO(), "jetstack/version-checker", new(api.Options))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if tag.Tag != "v0.1.0" {
+		t.Errorf("unexpected tag, exp=%s got=%s", "v0.1.0", tag.Tag)
+	}
+
+	client.tags = append(client.tags, api.ImageTag{Tag: "v0.2.0"})
+
+	tag, err = v.LatestTagFromImage(context.TODO(), "jetstack/version-checker", new(api.Options))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if tag.Tag != "v0.2.0" {
+		t.Errorf("expected refreshed result, exp=%s got=%s", "v0.2.0", tag.Tag)
+	}
+}
+
+func benchmarkLatestTagFromImage(b *testing.B, opts Options) {
+	var tags []api.ImageTag
+	for i := 0; i < 5000; i++ {
+		tags = append(tags, api.ImageTag{Tag: fmt.Sprintf("v1.%d.%d", i/100, i%100)})
+	}
+
+	v := newTestVersion(&fakeClient{tags: tags}, time.Hour, opts)
+	apiOpts := new(api.Options)
+
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if _, err := v.LatestTagFromImage(context.TODO(), "jetstack/version-checker", apiOpts); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkLatestTagFromImage(b *testing.B) {
+	benchmarkLatestTagFromImage(b, Options{})
+}
+
+func BenchmarkLatestTagFromImageCacheResults(b *testing.B) {
+	benchmarkLatestTagFromImage(b, Options{CacheResults: true})
+}
+
+func TestCalculateResultHash(t *testing.T) {
+	const imageURL = "quay.io/jetstack/version-checker"
+	opts := &api.Options{UseMetaData: true}
+	result := &api.ImageTag{Tag: "v0.2.0", SHA: "sha256:aaa"}
+
+	baseline, err := CalculateResultHash(imageURL, opts, result)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := map[string]struct {
+		imageURL string
+		opts     *api.Options
+		result   *api.ImageTag
+		expSame  bool
+	}{
+		"same inputs and result should have the same hash": {
+			imageURL: imageURL,
+			opts:     &api.Options{UseMetaData: true},
+			result:   &api.ImageTag{Tag: "v0.2.0", SHA: "sha256:aaa", Timestamp: time.Unix(100, 0)},
+			expSame:  true,
+		},
+		"different digest should change the hash": {
+			imageURL: imageURL,
+			opts:     opts,
+			result:   &api.ImageTag{Tag: "v0.2.0", SHA: "sha256:bbb"},
+		},
+		"different tag should change the hash": {
+			imageURL: imageURL,
+			opts:     opts,
+			result:   &api.ImageTag{Tag: "v0.3.0", SHA: "sha256:aaa"},
+		},
+		"no result should change the hash": {
+			imageURL: imageURL,
+			opts:     opts,
+			result:   nil,
+		},
+		"different options should change the hash": {
+			imageURL: imageURL,
+			opts:     new(api.Options),
+			result:   result,
+		},
+		"different image URL should change the hash": {
+			imageURL: "gcr.io/jetstack/version-checker",
+			opts:     opts,
+			result:   result,
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			hash, err := CalculateResultHash(test.imageURL, test.opts, test.result)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if same := hash == baseline; same != test.expSame {
+				t.Errorf("unexpected hash equality, exp=%t got=%t (%s, %s)", test.expSame, same, baseline, hash)
+			}
+		})
+	}
+}
+
+func TestTagsWithMetadata(t *testing.T) {
+	platforms := []api.Platform{
+		{OS: "linux", Architecture: "amd64"},
+		{OS: "linux", Architecture: "arm64", Variant: "v8"},
+	}
+
+	client := &fakeClient{
+		tags: []api.ImageTag{
+			{Tag: "v0.1.0", SHA: "sha256:111"},
+			{Tag: "v0.2.0", SHA: "sha256:222"},
+			{Tag: "latest", SHA: "sha256:222"},
+			{SHA: "sha256:333"},
+		},
+		manifests: map[string]*api.ImageManifest{
+			"sha256:111": {
+				Digest: "sha256:111",
+				Size:   1024,
+			},
+			"sha256:222": {
+				Digest:      "sha256:222",
+				Platforms:   platforms,
+				Annotations: map[string]string{"org.opencontainers.image.version": "v0.2.0"},
+			},
+		},
+	}
+
+	v := newTestVersion(client, time.Hour, Options{})
+
+	for i := 0; i < 2; i++ {
+		tags, err := v.TagsWithMetadata(context.TODO(), "jetstack/version-checker")
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		expTags := []api.TagMetadata{
+			{
+				ImageTag: api.ImageTag{Tag: "v0.1.0", SHA: "sha256:111"},
+				Size:     1024,
+			},
+			{
+				ImageTag:    api.ImageTag{Tag: "v0.2.0", SHA: "sha256:222"},
+				Platforms:   platforms,
+				Annotations: map[string]string{"org.opencontainers.image.version": "v0.2.0"},
+			},
+			{
+				ImageTag:    api.ImageTag{Tag: "latest", SHA: "sha256:222"},
+				Platforms:   platforms,
+				Annotations: map[string]string{"org.opencontainers.image.version": "v0.2.0"},
+			},
+		}
+		if !reflect.DeepEqual(tags, expTags) {
+			t.Errorf("unexpected tags, exp=%+v got=%+v", expTags, tags)
+		}
+	}
+
+	// Manifests should only be fetched once per digest.
+	if client.manifestCalls != 2 {
+		t.Errorf("unexpected number of manifest fetches, exp=2 got=%d", client.manifestCalls)
+	}
+}
+
+func TestLatestSemver(t *testing.T) {
+	tests := map[string]struct {
+		opts   *api.Options
+		tags   []string
+		expTag string
+		expErr bool
+	}{
+		"no options should return latest version without metadata": {
+			opts:   new(api.Options),
+			tags:   []string{"v0.1.0", "v0.3.0-rc.1", "v0.2.0", "latest"},
+			expTag: "v0.2.0",
+		},
+		"no tags should return nil": {
+			opts:   new(api.Options),
+			tags:   nil,
+			expTag: "",
+		},
+		"all pre-releases without fallback should return nil": {
+			opts:   new(api.Options),
+			tags:   []string{"v0.1.0-rc.1", "v0.1.0-rc.2", "v0.2.0-alpha.1"},
+			expTag: "",
+		},
+		"all pre-releases with fallback should return latest pre-release": {
+			opts:   &api.Options{FallbackToPreRelease: true},
+			tags:   []string{"v0.1.0-rc.1", "v0.2.0-alpha.1", "v0.1.0-rc.2"},
+			expTag: "v0.2.0-alpha.1",
+		},
+		"mixed repo with fallback should return latest stable version": {
+			opts:   &api.Options{FallbackToPreRelease: true},
+			tags:   []string{"v0.1.0-rc.1", "v0.1.0", "v0.2.0-rc.1"},
+			expTag: "v0.1.0",
+		},
+		"fallback should respect pins": {
+			opts:   &api.Options{FallbackToPreRelease: true, PinMajor: int64p(1)},
+			tags:   []string{"v0.1.0", "v1.0.0-rc.1", "v1.0.0-rc.2", "v2.0.0-rc.1"},
+			expTag: "v1.0.0-rc.2",
+		},
+		"fallback with stable version of pinned major should return stable": {
+			opts:   &api.Options{FallbackToPreRelease: true, PinMajor: int64p(0)},
+			tags:   []string{"v0.1.0", "v0.2.0-rc.1", "v1.0.0-rc.1"},
+			expTag: "v0.1.0",
+		},
+		"fallback with no matching pre-release should return nil": {
+			opts:   &api.Options{FallbackToPreRelease: true, PinMajor: int64p(3)},
+			tags:   []string{"v0.1.0", "v1.0.0-rc.1"},
+			expTag: "",
+		},
+		"denied latest version should select the next best version": {
+			opts:   &api.Options{DenyVersions: []string{"1.2.3"}},
+			tags:   []string{"1.2.1", "v1.2.3", "1.2.2", "1.2.3-rc.1"},
+			expTag: "1.2.2",
+		},
+		"denied versions should be skipped until a version is allowed": {
+			opts:   &api.Options{DenyVersions: []string{"v1.3.0", "1.2.2"}},
+			tags:   []string{"1.2.1", "1.3.0", "1.2.2"},
+			expTag: "1.2.1",
+		},
+		"denied versions should match metadata exactly": {
+			opts:   &api.Options{UseMetaData: true, DenyVersions: []string{"1.2.3-rc.2"}},
+			tags:   []string{"1.2.3-rc.1", "1.2.3-rc.2"},
+			expTag: "1.2.3-rc.1",
+		},
+		"denied versions should not deny other metadata of the version": {
+			opts:   &api.Options{UseMetaData: true, DenyVersions: []string{"1.2.3", "1.2.3-rc.1"}},
+			tags:   []string{"1.2.3-rc.1", "1.2.3-rc.2", "1.2.3-rc.0"},
+			expTag: "1.2.3-rc.2",
+		},
+		"denied versions should be skipped when matching by regex": {
+			opts:   &api.Options{RegexMatcher: regexp.MustCompile(`^1\.`), DenyVersions: []string{"1.3.0"}},
+			tags:   []string{"1.2.0", "1.3.0", "2.0.0"},
+			expTag: "1.2.0",
+		},
+		"regex should not require metadata to be enabled": {
+			opts:   &api.Options{RegexMatcher: regexp.MustCompile(`-prod$`)},
+			tags:   []string{"1.2.0-prod", "1.3.0-dev", "1.3.0"},
+			expTag: "1.2.0-prod",
+		},
+		"regex and constraints should both be passed": {
+			opts: &api.Options{
+				RegexMatcher:       regexp.MustCompile(`-prod$`),
+				VersionConstraints: mustConstraints("^1.2"),
+			},
+			tags:   []string{"1.1.0-prod", "1.2.3-prod", "1.2.4-dev", "1.3.0-prod", "2.0.0-prod"},
+			expTag: "1.3.0-prod",
+		},
+		"regex, constraints and pins should all be passed": {
+			opts: &api.Options{
+				RegexMatcher:       regexp.MustCompile(`-prod$`),
+				VersionConstraints: mustConstraints("^1.2"),
+				PinMajor:           int64p(1),
+				PinMinor:           int64p(2),
+			},
+			tags:   []string{"1.2.3-prod", "1.2.4-dev", "1.3.0-prod", "2.2.9-prod"},
+			expTag: "1.2.3-prod",
+		},
+		"max version should ignore versions above the ceiling": {
+			opts:   &api.Options{MaxVersion: semver.Parse("2.5.99")},
+			tags:   []string{"2.4.0", "2.5.3", "2.6.0", "3.0.0"},
+			expTag: "2.5.3",
+		},
+		"max version should select the ceiling itself": {
+			opts:   &api.Options{MaxVersion: semver.Parse("v2.5.0")},
+			tags:   []string{"v2.4.0", "v2.5.0", "v2.5.1"},
+			expTag: "v2.5.0",
+		},
+		"max version should select below the ceiling across majors": {
+			opts:   &api.Options{MaxVersion: semver.Parse("2.5.0")},
+			tags:   []string{"1.9.0", "2.0.0", "2.6.0"},
+			expTag: "2.0.0",
+		},
+		"max version should permit pre-releases of the ceiling": {
+			opts:   &api.Options{MaxVersion: semver.Parse("2.5.0"), UseMetaData: true},
+			tags:   []string{"2.5.0-rc.1", "2.5.1-rc.1"},
+			expTag: "2.5.0-rc.1",
+		},
+		"max version below all versions should return nil": {
+			opts:   &api.Options{MaxVersion: semver.Parse("1.0.0")},
+			tags:   []string{"2.4.0", "2.5.0"},
+			expTag: "",
+		},
+		"max version with pins should pass both": {
+			opts:   &api.Options{MaxVersion: semver.Parse("2.5.0"), PinMajor: int64p(1)},
+			tags:   []string{"1.2.0", "1.3.0", "2.4.0"},
+			expTag: "1.3.0",
+		},
+		"version band should select the latest within the band": {
+			opts:   &api.Options{VersionBand: band("1.4.0", "1.6.0")},
+			tags:   []string{"1.3.9", "1.4.0", "1.5.9", "1.6.0", "2.0.0"},
+			expTag: "1.5.9",
+		},
+		"version band minimum should be inclusive": {
+			opts:   &api.Options{VersionBand: band("1.4.0", "1.6.0")},
+			tags:   []string{"1.3.9", "1.4.0"},
+			expTag: "1.4.0",
+		},
+		"version band maximum should be exclusive": {
+			opts:   &api.Options{VersionBand: band("1.4.0", "1.6.0")},
+			tags:   []string{"1.3.9", "1.6.0"},
+			expTag: "",
+		},
+		"version band should exclude pre-releases of the maximum": {
+			opts:   &api.Options{VersionBand: band("1.4.0", "1.6.0"), UseMetaData: true},
+			tags:   []string{"1.6.0-rc.1", "1.6.0-rc.2"},
+			expTag: "",
+		},
+		"version band should include pre-releases of the minimum": {
+			opts:   &api.Options{VersionBand: band("1.4.0", "1.6.0"), UseMetaData: true},
+			tags:   []string{"1.3.9-rc.1", "1.4.0-rc.1"},
+			expTag: "1.4.0-rc.1",
+		},
+		"version band without a minimum should be unbounded below": {
+			opts:   &api.Options{VersionBand: band("", "1.6.0")},
+			tags:   []string{"0.1.0", "1.6.0"},
+			expTag: "0.1.0",
+		},
+		"version band without a maximum should be unbounded above": {
+			opts:   &api.Options{VersionBand: band("1.4.0", "")},
+			tags:   []string{"1.3.0", "9.0.0"},
+			expTag: "9.0.0",
+		},
+		"version band should compose with the max version": {
+			opts:   &api.Options{VersionBand: band("1.4.0", "1.6.0"), MaxVersion: semver.Parse("1.5.0")},
+			tags:   []string{"1.4.5", "1.5.0", "1.5.5"},
+			expTag: "1.5.0",
+		},
+		"version band should compose with constraints": {
+			opts:   &api.Options{VersionBand: band("1.4.0", "1.6.0"), VersionConstraints: mustConstraints("!=1.5.9")},
+			tags:   []string{"1.4.0", "1.5.8", "1.5.9", "1.6.0"},
+			expTag: "1.5.8",
+		},
+		"version band disjoint from constraints should select nothing": {
+			opts:   &api.Options{VersionBand: band("1.4.0", "1.6.0"), VersionConstraints: mustConstraints(">=1.6.0")},
+			tags:   []string{"1.4.0", "1.6.0"},
+			expTag: "",
+		},
+		"regex with pins should not select tags outside the pins": {
+			opts: &api.Options{
+				RegexMatcher: regexp.MustCompile(`-prod$`),
+				PinMajor:     int64p(3),
+			},
+			tags:   []string{"1.2.3-prod", "2.0.0-prod"},
+			expTag: "",
+		},
+		"regex with a pre-release channel should only select the channel": {
+			opts: &api.Options{
+				RegexMatcher:      regexp.MustCompile(`^1\.`),
+				PreReleaseChannel: "rc",
+			},
+			tags:   []string{"1.2.0", "1.3.0-rc.1", "1.3.0-beta.2", "2.0.0-rc.1"},
+			expTag: "1.3.0-rc.1",
+		},
+		"invalid denied versions should be ignored": {
+			opts:   &api.Options{DenyVersions: []string{"latest", ""}},
+			tags:   []string{"1.2.0", "latest"},
+			expTag: "1.2.0",
+		},
+		"all versions denied should return nil": {
+			opts:   &api.Options{DenyVersions: []string{"1.2.0"}},
+			tags:   []string{"1.2.0"},
+			expTag: "",
+		},
+		"prefer suffix should select the suffixed tag of the same version": {
+			opts:   &api.Options{PreferSuffix: "-slim"},
+			tags:   []string{"1.2.2-slim", "1.2.3", "1.2.3-slim", "1.2.3-alpine"},
+			expTag: "1.2.3-slim",
+		},
+		"prefer suffix should select the suffixed tag regardless of order": {
+			opts:   &api.Options{PreferSuffix: "-slim"},
+			tags:   []string{"1.2.3-slim", "1.2.3", "1.2.2"},
+			expTag: "1.2.3-slim",
+		},
+		"prefer suffix should fall back to the plain version if no suffixed tag": {
+			opts:   &api.Options{PreferSuffix: "-slim"},
+			tags:   []string{"1.2.2-slim", "1.2.3", "1.2.3-alpine"},
+			expTag: "1.2.3",
+		},
+		"prefer suffix should not prefer an older suffixed version": {
+			opts:   &api.Options{PreferSuffix: "-slim"},
+			tags:   []string{"1.2.3-slim", "1.2.4", "1.2.3"},
+			expTag: "1.2.4",
+		},
+		"prefer suffix with metadata should not select other suffixes of the same version": {
+			opts:   &api.Options{PreferSuffix: "-slim", UseMetaData: true},
+			tags:   []string{"1.2.3-alpine", "1.2.3-slim", "1.2.3-zzz"},
+			expTag: "1.2.3-slim",
+		},
+		"prerelease channel should order by date across a month boundary": {
+			opts:   &api.Options{PreReleaseChannel: "nightly"},
+			tags:   []string{"1.2.3-nightly.20240131", "1.2.3-nightly.20240201", "1.2.3-nightly.20240115"},
+			expTag: "1.2.3-nightly.20240201",
+		},
+		"prerelease channel should order by date across a year boundary": {
+			opts:   &api.Options{PreReleaseChannel: "nightly"},
+			tags:   []string{"1.2.3-nightly.2023-12-31", "1.2.3-nightly.2024-01-01", "1.2.3-nightly.2023-12-30"},
+			expTag: "1.2.3-nightly.2024-01-01",
+		},
+		"prerelease channel should order unpadded dates numerically": {
+			opts:   &api.Options{PreReleaseChannel: "nightly"},
+			tags:   []string{"1.2.3-nightly.2023.12.31", "1.2.3-nightly.2024.1.2", "1.2.3-nightly.2024.1.10"},
+			expTag: "1.2.3-nightly.2024.1.10",
+		},
+		"prerelease channel should order by date before version": {
+			opts:   &api.Options{PreReleaseChannel: "nightly"},
+			tags:   []string{"1.2.4-nightly.20231231", "1.2.3-nightly.20240101"},
+			expTag: "1.2.3-nightly.20240101",
+		},
+		"prerelease channel should order the same date by version": {
+			opts:   &api.Options{PreReleaseChannel: "nightly"},
+			tags:   []string{"1.2.4-nightly.20240101", "1.2.3-nightly.20240101"},
+			expTag: "1.2.4-nightly.20240101",
+		},
+		"prerelease channel should ignore other channels and stable versions": {
+			opts:   &api.Options{PreReleaseChannel: "nightly"},
+			tags:   []string{"1.2.3-nightly.20240101", "1.3.0", "1.3.0-rc.20240301", "1.3.0-nightlyx.20240301"},
+			expTag: "1.2.3-nightly.20240101",
+		},
+		"exact patch pin should select the pinned patch": {
+			opts:   &api.Options{PinMajor: int64p(1), PinMinor: int64p(2), PinPatch: int64p(3)},
+			tags:   []string{"1.2.2", "1.2.3", "1.2.5", "1.3.0"},
+			expTag: "1.2.3",
+		},
+		"minimum patch pin should select the latest patch": {
+			opts:   &api.Options{PinMajor: int64p(1), PinMinor: int64p(2), PinPatch: int64p(3), PinPatchMinimum: true},
+			tags:   []string{"1.2.2", "1.2.3", "1.2.5", "1.3.0"},
+			expTag: "1.2.5",
+		},
+		"minimum patch pin should ignore lower patches": {
+			opts:   &api.Options{PinMajor: int64p(1), PinMinor: int64p(2), PinPatch: int64p(6), PinPatchMinimum: true},
+			tags:   []string{"1.2.2", "1.2.3", "1.2.5", "1.3.0"},
+			expTag: "",
+		},
+		"minimum patch pin combined with metadata should select the latest patch": {
+			opts:   &api.Options{PinMajor: int64p(1), PinMinor: int64p(2), PinPatch: int64p(3), PinPatchMinimum: true, UseMetaData: true},
+			tags:   []string{"1.2.2", "1.2.4-rc.1", "1.2.3", "1.3.0"},
+			expTag: "1.2.4-rc.1",
+		},
+		"four component versions should be ignored without sanitize": {
+			opts:   new(api.Options),
+			tags:   []string{"1.2.3.4", "1.2.3.10", "1.2.2"},
+			expTag: "1.2.2",
+		},
+		"sanitize build should compare four component versions": {
+			opts:   &api.Options{SanitizeRule: "build"},
+			tags:   []string{"1.2.3.4", "1.2.3.10", "1.2.2"},
+			expTag: "1.2.3.10",
+		},
+		"sanitize build should compare underscore separated versions": {
+			opts:   &api.Options{SanitizeRule: "build"},
+			tags:   []string{"1.2.3_1", "1.2.3_2", "1.2.2", "1.2.3-rc.1"},
+			expTag: "1.2.3_2",
+		},
+		"sanitize prerelease should ignore four component versions without metadata": {
+			opts:   &api.Options{SanitizeRule: "prerelease"},
+			tags:   []string{"1.2.3.4", "1.2.3.10", "1.2.2"},
+			expTag: "1.2.2",
+		},
+		"sanitize prerelease should compare four component versions with metadata": {
+			opts:   &api.Options{SanitizeRule: "prerelease", UseMetaData: true},
+			tags:   []string{"1.2.3.4", "1.2.3.10", "1.2.2-rc.1"},
+			expTag: "1.2.3.10",
+		},
+		"version extractor should compare the embedded version": {
+			opts: &api.Options{
+				VersionExtractor: regexp.MustCompile(`^myapp-(?P<version>\d+\.\d+\.\d+)-linux-amd64$`),
+			},
+			tags:   []string{"myapp-1.2.3-linux-amd64", "myapp-1.10.0-linux-amd64", "myapp-2.0.0-linux-arm64", "1.11.0"},
+			expTag: "myapp-1.10.0-linux-amd64",
+		},
+		"version extractor should handle versions with a prefix and suffix": {
+			opts: &api.Options{
+				VersionExtractor: regexp.MustCompile(`^(?:build|release)_(?P<version>v?\d+(\.\d+)*)(_.*)?$`),
+			},
+			tags:   []string{"build_v1.2.3_abcdef", "release_1.3", "release_1.2.9_final", "nightly_2.0.0"},
+			expTag: "release_1.3",
+		},
+		"version extractor with metadata in the captured version": {
+			opts: &api.Options{
+				UseMetaData:      true,
+				VersionExtractor: regexp.MustCompile(`^img-(?P<version>.*)$`),
+			},
+			tags:   []string{"img-1.2.4-rc.1", "img-1.2.4-rc.2", "1.3.0-rc.1"},
+			expTag: "img-1.2.4-rc.2",
+		},
+		"version extractor with pins": {
+			opts: &api.Options{
+				PinMajor:         int64p(1),
+				VersionExtractor: regexp.MustCompile(`-(?P<version>\d+\.\d+\.\d+)$`),
+			},
+			tags:   []string{"app-1.2.3", "app-1.4.0", "app-2.0.0"},
+			expTag: "app-1.4.0",
+		},
+		"newer pre-release with greater core than stable should be selected": {
+			opts:   &api.Options{UseNewerPreRelease: true},
+			tags:   []string{"1.1.0", "1.2.0", "1.3.0-rc.1", "1.3.0-rc.0", "1.1.1-rc.1"},
+			expTag: "1.3.0-rc.1",
+		},
+		"pre-release of the same core as stable should not be selected": {
+			opts:   &api.Options{UseNewerPreRelease: true},
+			tags:   []string{"1.2.0-rc.1", "1.2.0", "1.1.0"},
+			expTag: "1.2.0",
+		},
+		"pre-release older than stable should not be selected": {
+			opts:   &api.Options{UseNewerPreRelease: true},
+			tags:   []string{"1.2.0", "1.3.0-rc.1", "1.3.0"},
+			expTag: "1.3.0",
+		},
+		"newer pre-release should respect pins": {
+			opts:   &api.Options{UseNewerPreRelease: true, PinMajor: int64p(1)},
+			tags:   []string{"1.2.0", "1.3.0-rc.1", "2.0.0-rc.1"},
+			expTag: "1.3.0-rc.1",
+		},
+		"newer pre-release should not be selected without a stable version": {
+			opts:   &api.Options{UseNewerPreRelease: true},
+			tags:   []string{"1.3.0-rc.1"},
+			expTag: "",
+		},
+		"newer pre-release should select a newer pre-release only minor": {
+			opts:   &api.Options{UseNewerPreRelease: true, PinMajor: int64p(1)},
+			tags:   []string{"1.5.0", "1.6.0-rc.1"},
+			expTag: "1.6.0-rc.1",
+		},
+		"skip pre-release minors should not select a newer pre-release only minor": {
+			opts:   &api.Options{UseNewerPreRelease: true, PinMajor: int64p(1), SkipPreReleaseMinors: true},
+			tags:   []string{"1.5.0", "1.6.0-rc.1", "2.0.0"},
+			expTag: "1.5.0",
+		},
+		"skip pre-release minors should select pre-releases of a stable minor": {
+			opts:   &api.Options{UseNewerPreRelease: true, SkipPreReleaseMinors: true},
+			tags:   []string{"1.5.0", "1.5.1-rc.1", "1.6.0-rc.1"},
+			expTag: "1.5.1-rc.1",
+		},
+		"skip pre-release minors should select pre-releases once the minor is stable": {
+			opts:   &api.Options{UseNewerPreRelease: true, SkipPreReleaseMinors: true},
+			tags:   []string{"1.5.0", "1.6.0", "1.6.1-rc.1"},
+			expTag: "1.6.1-rc.1",
+		},
+		"skip pre-release minors should not count build metadata as a pre-release": {
+			opts:   &api.Options{UseNewerPreRelease: true, SkipPreReleaseMinors: true},
+			tags:   []string{"1.5.0", "1.6.0+build.1", "1.6.1-rc.1"},
+			expTag: "1.6.1-rc.1",
+		},
+		"skip pre-release minors with metadata should ignore pre-release only minors": {
+			opts:   &api.Options{UseMetaData: true, SkipPreReleaseMinors: true},
+			tags:   []string{"1.5.0-rc.1", "1.6.0-rc.1"},
+			expTag: "",
+		},
+		"skip pre-release minors without pre-releases should have no effect": {
+			opts:   &api.Options{SkipPreReleaseMinors: true},
+			tags:   []string{"1.5.0", "1.6.0-rc.1"},
+			expTag: "1.5.0",
+		},
+		"version extractor without a version group should error": {
+			opts: &api.Options{
+				VersionExtractor: regexp.MustCompile(`^app-(\d+\.\d+\.\d+)$`),
+			},
+			tags:   []string{"app-1.2.3"},
+			expErr: true,
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			var tags []api.ImageTag
+			for _, tag := range test.tags {
+				tags = append(tags, api.ImageTag{Tag: tag})
+			}
+
+			tag, err := latestSemver(test.opts, tags)
+			if (err != nil) != test.expErr {
+				t.Fatalf("unexpected error, exp=%t got=%v", test.expErr, err)
+			}
+
+			var gotTag string
+			if tag != nil {
+				gotTag = tag.Tag
+			}
+			if gotTag != test.expTag {
+				t.Errorf("unexpected tag, exp=%q got=%q", test.expTag, gotTag)
+			}
+		})
+	}
+}
+
+func int64p(i int64) *int64 {
+	return &i
+}
+
+// band returns the version band of the given bounds, where empty bounds are
+// unbounded.
+func band(min, max string) *api.VersionBand {
+	b := new(api.VersionBand)
+	if len(min) > 0 {
+		b.Min = semver.Parse(min)
+	}
+	if len(max) > 0 {
+		b.Max = semver.Parse(max)
+	}
+
+	return b
+}
+
+func mustConstraints(s string) *semver.Constraints {
+	c, err := semver.ParseConstraints(s)
+	if err != nil {
+		panic(err)
+	}
+
+	return c
+}
+
+func TestNewerPreRelease(t *testing.T) {
+	tests := map[string]struct {
+		opts          *api.Options
+		tags          []string
+		expTag        string
+		expPreRelease string
+	}{
+		"newer pre-release should be reported without changing selection": {
+			opts:          new(api.Options),
+			tags:          []string{"v1.1.0", "v1.2.0", "v1.3.0-rc.1", "v1.3.0-rc.2"},
+			expTag:        "v1.2.0",
+			expPreRelease: "v1.3.0-rc.2",
+		},
+		"pre-releases of the selected version should not be reported": {
+			opts:   new(api.Options),
+			tags:   []string{"v1.1.0", "v1.2.0-rc.1", "v1.2.0"},
+			expTag: "v1.2.0",
+		},
+		"no pre-releases should not be reported": {
+			opts:   new(api.Options),
+			tags:   []string{"v1.1.0", "v1.2.0"},
+			expTag: "v1.2.0",
+		},
+		"pre-releases outside the pins should not be reported": {
+			opts:   &api.Options{PinMajor: int64p(1)},
+			tags:   []string{"v1.2.0", "v2.0.0-rc.1"},
+			expTag: "v1.2.0",
+		},
+		"pre-releases should not be reported when using metadata": {
+			opts:   &api.Options{UseMetaData: true},
+			tags:   []string{"v1.2.0-rc.1", "v1.3.0-rc.1"},
+			expTag: "v1.3.0-rc.1",
+		},
+		"selected newer pre-release should not be reported": {
+			opts:   &api.Options{UseNewerPreRelease: true},
+			tags:   []string{"v1.2.0", "v1.3.0-rc.1"},
+			expTag: "v1.3.0-rc.1",
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			client := new(fakeClient)
+			for i, tag := range test.tags {
+				client.tags = append(client.tags, api.ImageTag{Tag: tag, Timestamp: time.Unix(int64(i), 0)})
+			}
+			v := newTestVersion(client, time.Hour, Options{CacheResults: true})
+
+			tag, err := v.LatestTagFromImage(context.TODO(), "quay.io/jetstack/version-checker", test.opts)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			var preRelease string
+			if tag.NewerPreRelease != nil {
+				preRelease = tag.NewerPreRelease.Tag
+			}
+			if tag.Tag != test.expTag || preRelease != test.expPreRelease {
+				t.Errorf("unexpected tag, exp=%s (newer=%q) got=%s (newer=%q)", test.expTag, test.expPreRelease, tag.Tag, preRelease)
+			}
+
+			// The cached tags should not be modified.
+			for _, cached := range client.tags {
+				if cached.NewerPreRelease != nil {
+					t.Errorf("unexpected newer pre-release of cached tag %q", cached.Tag)
+				}
+			}
+		})
+	}
+}
+
+func TestDigestsWithMetadata(t *testing.T) {
+	client := &fakeClient{
+		tags: []api.ImageTag{
+			{Tag: "1.2.3", SHA: "sha256:111", Timestamp: time.Unix(100, 0)},
+			{Tag: "1.2", SHA: "sha256:111", Timestamp: time.Unix(200, 0)},
+			{Tag: "1.1.0", SHA: "sha256:222", Timestamp: time.Unix(50, 0)},
+			{Tag: "latest", SHA: "sha256:111", Timestamp: time.Unix(150, 0)},
+		},
+		manifests: map[string]*api.ImageManifest{
+			"sha256:111": {Digest: "sha256:111", Size: 100},
+			"sha256:222": {Digest: "sha256:222", Size: 200},
+		},
+	}
+
+	v := newTestVersion(client, time.Hour, Options{})
+
+	digests, err := v.DigestsWithMetadata(context.TODO(), "jetstack/version-checker")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expDigests := []api.DigestMetadata{
+		{
+			SHA:       "sha256:111",
+			Tags:      []string{"1.2.3", "1.2", "latest"},
+			Timestamp: time.Unix(200, 0),
+			Size:      100,
+		},
+		{
+			SHA:       "sha256:222",
+			Tags:      []string{"1.1.0"},
+			Timestamp: time.Unix(50, 0),
+			Size:      200,
+		},
+	}
+	if !reflect.DeepEqual(digests, expDigests) {
+		t.Errorf("unexpected digests, exp=%+v got=%+v", expDigests, digests)
+	}
+}
+
+func TestParseTags(t *testing.T) {
+	client := &fakeClient{
+		tags: []api.ImageTag{
+			{Tag: "v0.1.0"},
+			{Tag: "v0.2.0-rc.1"},
+			{Tag: "v1.0.0"},
+			{Tag: "latest"},
+		},
+	}
+
+	v := newTestVersion(client, time.Hour, Options{})
+
+	parsed, err := v.ParseTags(context.TODO(), &api.Options{PinMajor: int64p(0)}, "jetstack/version-checker")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expParsed := []struct {
+		tag     string
+		valid   bool
+		matched bool
+	}{
+		{tag: "v0.1.0", valid: true, matched: true},
+		{tag: "v0.2.0-rc.1", valid: true, matched: false},
+		{tag: "v1.0.0", valid: true, matched: false},
+		{tag: "latest", valid: false, matched: false},
+	}
+
+	if len(parsed) != len(expParsed) {
+		t.Fatalf("unexpected number of parsed tags, exp=%d got=%d", len(expParsed), len(parsed))
+	}
+
+	for i, exp := range expParsed {
+		if parsed[i].Tag != exp.tag {
+			t.Errorf("unexpected tag, exp=%s got=%s", exp.tag, parsed[i].Tag)
+		}
+		if valid := parsed[i].Version != nil; valid != exp.valid {
+			t.Errorf("%s: unexpected valid version, exp=%t got=%t", exp.tag, exp.valid, valid)
+		}
+		if parsed[i].Version != nil && parsed[i].Version.String() != exp.tag {
+			t.Errorf("%s: unexpected version, got=%s", exp.tag, parsed[i].Version)
+		}
+		if parsed[i].Matched != exp.matched {
+			t.Errorf("%s: unexpected matched, exp=%t got=%t", exp.tag, exp.matched, parsed[i].Matched)
+		}
+	}
+}
+
+func TestDigestReferenceWithNoTags(t *testing.T) {
+	timestamp := time.Unix(1600000000, 0)
+
+	client := &fakeClient{
+		manifests: map[string]*api.ImageManifest{
+			"sha256:aaa": {Digest: "sha256:aaa", Timestamp: timestamp, Size: 100},
+		},
+	}
+
+	v := newTestVersion(client, time.Hour, Options{})
+
+	tag, err := v.LatestTagFromImage(context.TODO(), "localhost:5000/jetstack/version-checker@sha256:aaa",
+		&api.Options{UseSHA: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expTag := &api.ImageTag{SHA: "sha256:aaa", Timestamp: timestamp}
+	if !reflect.DeepEqual(tag, expTag) {
+		t.Errorf("unexpected tag, exp=%+v got=%+v", expTag, tag)
+	}
+
+	// Without a digest, no tags should still error.
+	_, err = v.LatestTagFromImage(context.TODO(), "localhost:5000/jetstack/version-checker", &api.Options{UseSHA: true})
+	if !versionerrors.IsNoVersionFound(err) {
+		t.Errorf("expected no version found error, got: %v", err)
+	}
+
+	// An unknown digest should error.
+	_, err = v.LatestTagFromImage(context.TODO(), "localhost:5000/jetstack/version-checker@sha256:bbb", &api.Options{UseSHA: true})
+	if err == nil {
+		t.Error("expected error for unknown digest, got none")
+	}
+}
+
+func TestClientNameFromImage(t *testing.T) {
+	imageClient, err := client.New(context.TODO(), logrus.NewEntry(logrus.New()), client.Options{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	v := newTestVersion(imageClient, time.Hour, Options{})
+
+	for imageURL, expName := range map[string]string{
+		"quay.io/jetstack/version-checker":     "quay",
+		"gcr.io/jetstack-cre/version-checker":  "gcr",
+		"jetstack/version-checker":             "dockerhub",
+		"registry.example.com/version-checker": "dockerapi",
+	} {
+		if name := v.ClientNameFromImage(imageURL); name != expName {
+			t.Errorf("%s: unexpected client name, exp=%q got=%q", imageURL, expName, name)
+		}
+	}
+}
+
+func TestFloatingTag(t *testing.T) {
+	client := &fakeClient{
+		tags: []api.ImageTag{
+			{Tag: "v0.1.0", SHA: "sha256:aaa", Timestamp: time.Unix(100, 0)},
+			{Tag: "stable", SHA: "sha256:bbb", Timestamp: time.Unix(200, 0)},
+			{Tag: "v0.2.0", SHA: "sha256:ccc", Timestamp: time.Unix(300, 0)},
+		},
+	}
+
+	v := newTestVersion(client, time.Hour, Options{CacheResults: true})
+
+	tests := map[string]struct {
+		floatingTag    string
+		previousDigest string
+		expSHA         string
+		expDrifted     bool
+		expErr         bool
+	}{
+		"same digest should not have drifted": {
+			floatingTag:    "stable",
+			previousDigest: "sha256:bbb",
+			expSHA:         "sha256:bbb",
+			expDrifted:     false,
+		},
+		"different digest should have drifted": {
+			floatingTag:    "stable",
+			previousDigest: "sha256:aaa",
+			expSHA:         "sha256:bbb",
+			expDrifted:     true,
+		},
+		"no previous digest should not have drifted": {
+			floatingTag: "stable",
+			expSHA:      "sha256:bbb",
+			expDrifted:  false,
+		},
+		"missing floating tag should error": {
+			floatingTag: "edge",
+			expErr:      true,
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			floatingTag := test.floatingTag
+			tag, err := v.LatestTagFromImage(context.TODO(), "localhost:5000/version-checker", &api.Options{
+				FloatingTag:       &floatingTag,
+				FloatingTagDigest: test.previousDigest,
+			})
+			if (err != nil) != test.expErr {
+				t.Fatalf("unexpected error, exp=%t got=%v", test.expErr, err)
+			}
+			if test.expErr {
+				return
+			}
+
+			if tag.Tag != test.floatingTag || tag.SHA != test.expSHA || tag.Drifted != test.expDrifted {
+				t.Errorf("unexpected tag, exp=%s/%s/%t got=%s/%s/%t",
+					test.floatingTag, test.expSHA, test.expDrifted, tag.Tag, tag.SHA, tag.Drifted)
+			}
+		})
+	}
+
+	// Drift must not be written to the cached tags.
+	_, cachedTags, _, err := v.imageTags(context.TODO(), "localhost:5000/version-checker", new(api.Options))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, tag := range cachedTags {
+		if tag.Drifted {
+			t.Errorf("cached tag %q unexpectedly marked as drifted", tag.Tag)
+		}
+	}
+}
+
+func TestStripBuildMetadata(t *testing.T) {
+	client := &fakeClient{
+		tags: []api.ImageTag{
+			{Tag: "1.2.3+build.1", SHA: "sha256:bbb", Timestamp: time.Unix(200, 0)},
+			{Tag: "1.2.3+build.2", SHA: "sha256:ccc", Timestamp: time.Unix(300, 0)},
+		},
+	}
+
+	v := newTestVersion(client, time.Hour, Options{CacheResults: true})
+
+	tests := map[string]struct {
+		strip  bool
+		expTag string
+	}{
+		"build metadata should be stripped when enabled": {
+			strip:  true,
+			expTag: "1.2.3",
+		},
+		"build metadata should be kept when disabled": {
+			strip:  false,
+			expTag: "1.2.3+build.2",
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			tag, err := v.LatestTagFromImage(context.TODO(), "localhost:5000/version-checker", &api.Options{
+				UseMetaData:        true,
+				StripBuildMetadata: test.strip,
+			})
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			// Selection should still see the full version, so the newest build
+			// is selected.
+			if tag.Tag != test.expTag || tag.SHA != "sha256:ccc" {
+				t.Errorf("unexpected tag, exp=%s/%s got=%s/%s",
+					test.expTag, "sha256:ccc", tag.Tag, tag.SHA)
+			}
+		})
+	}
+}
+
+func TestCandidateTags(t *testing.T) {
+	client := &fakeClient{
+		tags: []api.ImageTag{
+			{Tag: "1.2.3", SHA: "sha256:aaa", Timestamp: time.Unix(100, 0)},
+			{Tag: "1.3.0", SHA: "sha256:bbb", Timestamp: time.Unix(200, 0)},
+			{Tag: "1.4.0", SHA: "sha256:ccc", Timestamp: time.Unix(300, 0)},
+			{Tag: "2.0.0", SHA: "sha256:ddd", Timestamp: time.Unix(400, 0)},
+		},
+	}
+
+	v := newTestVersion(client, time.Hour, Options{})
+
+	tests := map[string]struct {
+		candidates []string
+		expTag     string
+		expErr     bool
+	}{
+		"no candidates should select from all tags": {
+			candidates: nil,
+			expTag:     "2.0.0",
+		},
+		"should select the latest of the candidates": {
+			candidates: []string{"1.2.3", "1.4.0"},
+			expTag:     "1.4.0",
+		},
+		"candidates missing upstream should be ignored": {
+			candidates: []string{"1.2.3", "1.3.0", "1.5.0"},
+			expTag:     "1.3.0",
+		},
+		"no candidates upstream should error": {
+			candidates: []string{"1.5.0", "3.0.0"},
+			expErr:     true,
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			tag, err := v.LatestTagFromImage(context.TODO(), "localhost:5000/version-checker", &api.Options{
+				CandidateTags: test.candidates,
+			})
+			if (err != nil) != test.expErr {
+				t.Fatalf("unexpected error, exp=%t got=%v", test.expErr, err)
+			}
+			if test.expErr {
+				return
+			}
+
+			if tag.Tag != test.expTag {
+				t.Errorf("unexpected tag, exp=%q got=%q", test.expTag, tag.Tag)
+			}
+		})
+	}
+}
+
+func TestFallbackToSHA(t *testing.T) {
+	nonSemver := []api.ImageTag{
+		{Tag: "main", SHA: "sha256:aaa", Timestamp: time.Unix(300, 0)},
+		{Tag: "latest", SHA: "sha256:bbb", Timestamp: time.Unix(200, 0)},
+		{Tag: "feature-x", SHA: "sha256:ccc", Timestamp: time.Unix(100, 0)},
+	}
+	mixed := append([]api.ImageTag{
+		{Tag: "1.2.3", SHA: "sha256:ddd", Timestamp: time.Unix(50, 0)},
+	}, nonSemver...)
+
+	tests := map[string]struct {
+		tags        []api.ImageTag
+		opts        *api.Options
+		expTag      string
+		expNotFound bool
+	}{
+		"non-semver tags without fallback should not be found": {
+			tags:        nonSemver,
+			opts:        new(api.Options),
+			expNotFound: true,
+		},
+		"non-semver tags should fall back to the newest": {
+			tags:   nonSemver,
+			opts:   &api.Options{FallbackToSHA: true},
+			expTag: "main",
+		},
+		"matching version should take precedence over fallback": {
+			tags:   mixed,
+			opts:   &api.Options{FallbackToSHA: true},
+			expTag: "1.2.3",
+		},
+		"no matching version should fall back to non-semver tags only": {
+			tags:   mixed,
+			opts:   &api.Options{FallbackToSHA: true, PinMajor: int64p(2)},
+			expTag: "main",
+		},
+		"fallback should respect candidate tags": {
+			tags:   nonSemver,
+			opts:   &api.Options{FallbackToSHA: true, CandidateTags: []string{"latest", "feature-x"}},
+			expTag: "latest",
+		},
+		"only versions should not be found on fallback": {
+			tags:        mixed[:1],
+			opts:        &api.Options{FallbackToSHA: true, PinMajor: int64p(2)},
+			expNotFound: true,
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			v := newTestVersion(&fakeClient{tags: test.tags}, time.Hour, Options{})
+
+			tag, err := v.LatestTagFromImage(context.TODO(), "localhost:5000/version-checker", test.opts)
+			if versionerrors.IsNoVersionFound(err) != test.expNotFound {
+				t.Fatalf("unexpected not found error, exp=%t got=%v", test.expNotFound, err)
+			}
+			if test.expNotFound {
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if tag.Tag != test.expTag {
+				t.Errorf("unexpected tag, exp=%q got=%q", test.expTag, tag.Tag)
+			}
+		})
+	}
+}
+
+func TestSharedOptionsMutation(t *testing.T) {
+	started, release := make(chan struct{}), make(chan struct{})
+
+	client := &fakeClient{
+		tags: []api.ImageTag{
+			{Tag: "1.2.3"}, {Tag: "1.3.0"}, {Tag: "2.0.0"}, {Tag: "2.1.0"},
+		},
+		onTags: func() {
+			started <- struct{}{}
+			<-release
+		},
+	}
+
+	v := newTestVersion(client, time.Hour, Options{CacheResults: true})
+
+	opts := &api.Options{
+		PinMajor:      int64p(1),
+		CandidateTags: []string{"1.2.3", "1.3.0"},
+	}
+
+	type result struct {
+		tag *api.ImageTag
+		err error
+	}
+	resultCh := make(chan result)
+	go func() {
+		tag, err := v.LatestTagFromImage(context.TODO(), "localhost:5000/version-checker", opts)
+		resultCh <- result{tag, err}
+	}()
+
+	// Mutate the shared options while the lookup is in flight. The lookup
+	// should use the options as they were when it started.
+	<-started
+	*opts.PinMajor = 2
+	opts.CandidateTags[0] = "2.0.0"
+	opts.CandidateTags[1] = "2.1.0"
+	opts.UseSHA = true
+	close(release)
+
+	res := <-resultCh
+	if res.err != nil {
+		t.Fatal(res.err)
+	}
+	if res.tag.Tag != "1.3.0" {
+		t.Errorf("unexpected tag, exp=%q got=%q", "1.3.0", res.tag.Tag)
+	}
+}
+
+func TestHelmChartsOnly(t *testing.T) {
+	client := &fakeClient{
+		tags: []api.ImageTag{
+			{Tag: "v1.2.0", ConfigMediaType: api.HelmChartConfigMediaType},
+			{Tag: "v1.10.0", ConfigMediaType: api.HelmChartConfigMediaType},
+			{Tag: "v1.11.0", ConfigMediaType: "application/vnd.oci.image.config.v1+json"},
+			{Tag: "v1.12.0"},
+		},
+	}
+
+	v := newTestVersion(client, time.Hour, Options{})
+
+	tests := map[string]struct {
+		opts   *api.Options
+		expTag string
+	}{
+		"all artifact types should be selected from without the option": {
+			opts:   new(api.Options),
+			expTag: "v1.12.0",
+		},
+		"only charts should be selected from with the option": {
+			opts:   &api.Options{HelmChartsOnly: true},
+			expTag: "v1.10.0",
+		},
+		"charts should respect pins": {
+			opts:   &api.Options{HelmChartsOnly: true, PinMinor: int64p(2), PinMajor: int64p(1)},
+			expTag: "v1.2.0",
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			tag, err := v.LatestTagFromImage(context.TODO(), "localhost:5000/charts/cert-manager", test.opts)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if tag.Tag != test.expTag {
+				t.Errorf("unexpected tag, exp=%q got=%q", test.expTag, tag.Tag)
+			}
+		})
+	}
+}
+
+func TestPurgePrefix(t *testing.T) {
+	client := &fakeClient{
+		tags: []api.ImageTag{{Tag: "v0.1.0"}},
+	}
+
+	v := newTestVersion(client, time.Hour, Options{CacheResults: true})
+
+	images := []string{
+		"quay.io/jetstack/cert-manager",
+		"quay.io/jetstack/version-checker",
+		"quay.io/prometheus/prometheus",
+		"gcr.io/jetstack/version-checker",
+	}
+	lookup := func() {
+		t.Helper()
+		client.calls = 0
+		for _, image := range images {
+			if _, err := v.LatestTagFromImage(context.TODO(), image, new(api.Options)); err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+	lookup()
+
+	if purged := v.PurgePrefix("quay.io/jetstack/"); purged != 2 {
+		t.Errorf("unexpected number of images purged by repo, exp=2 got=%d", purged)
+	}
+	lookup()
+	if client.calls != 2 {
+		t.Errorf("expected purged images to be fetched, exp=2 calls got=%d", client.calls)
+	}
+
+	if purged := v.PurgePrefix("quay.io/"); purged != 3 {
+		t.Errorf("unexpected number of images purged by host, exp=3 got=%d", purged)
+	}
+	lookup()
+	if client.calls != 3 {
+		t.Errorf("expected purged images to be fetched, exp=3 calls got=%d", client.calls)
+	}
+}
+
+func TestDefaultOptions(t *testing.T) {
+	client := &fakeClient{
+		tags: []api.ImageTag{
+			{Tag: "1.2.3"}, {Tag: "1.3.0"}, {Tag: "1.4.0-rc.1"}, {Tag: "2.0.0"}, {Tag: "2.1.0"},
+		},
+	}
+
+	tests := map[string]struct {
+		defaults *api.Options
+		opts     *api.Options
+		expTag   string
+	}{
+		"no defaults should use the lookup options": {
+			defaults: nil,
+			opts:     &api.Options{PinMajor: int64p(1)},
+			expTag:   "1.3.0",
+		},
+		"unset pointer should use the default": {
+			defaults: &api.Options{PinMajor: int64p(1)},
+			opts:     new(api.Options),
+			expTag:   "1.3.0",
+		},
+		"nil options should use the defaults": {
+			defaults: &api.Options{PinMajor: int64p(1)},
+			opts:     nil,
+			expTag:   "1.3.0",
+		},
+		"set pointer should take precedence over the default": {
+			defaults: &api.Options{PinMajor: int64p(1)},
+			opts:     &api.Options{PinMajor: int64p(2)},
+			expTag:   "2.1.0",
+		},
+		"set regex should take precedence over the default": {
+			defaults: &api.Options{RegexMatcher: regexp.MustCompile(`^1\.`)},
+			opts:     &api.Options{RegexMatcher: regexp.MustCompile(`^2\.0`)},
+			expTag:   "2.0.0",
+		},
+		"set slice should take precedence over the default": {
+			defaults: &api.Options{CandidateTags: []string{"1.2.3"}},
+			opts:     &api.Options{CandidateTags: []string{"2.0.0"}},
+			expTag:   "2.0.0",
+		},
+		"unset value should use the default": {
+			defaults: &api.Options{UseNewerPreRelease: true},
+			opts:     &api.Options{PinMajor: int64p(1)},
+			expTag:   "1.4.0-rc.1",
+		},
+		"fields should be merged individually": {
+			defaults: &api.Options{PinMajor: int64p(1), CandidateTags: []string{"1.2.3", "2.0.0"}},
+			opts:     &api.Options{PinMajor: int64p(2)},
+			expTag:   "2.0.0",
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			v := newTestVersion(client, time.Hour, Options{DefaultOptions: test.defaults})
+
+			tag, err := v.LatestTagFromImage(context.TODO(), "localhost:5000/version-checker", test.opts)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if tag.Tag != test.expTag {
+				t.Errorf("unexpected tag, exp=%q got=%q", test.expTag, tag.Tag)
+			}
+		})
+	}
+
+	// Merged options should share no state with the defaults.
+	defaults := &api.Options{PinMajor: int64p(1), CandidateTags: []string{"1.2.3"}}
+	merged := new(api.Options).WithDefaults(defaults)
+	*merged.PinMajor = 2
+	merged.CandidateTags[0] = "2.0.0"
+	if *defaults.PinMajor != 1 || defaults.CandidateTags[0] != "1.2.3" {
+		t.Errorf("expected defaults to be unchanged, got=%d %v", *defaults.PinMajor, defaults.CandidateTags)
+	}
+}
+
+func TestBeforeTime(t *testing.T) {
+	client := &fakeClient{
+		tags: []api.ImageTag{
+			{Tag: "v1.0.0", SHA: "sha256:aaa", Timestamp: time.Unix(100, 0)},
+			{Tag: "v1.1.0", SHA: "sha256:bbb", Timestamp: time.Unix(200, 0)},
+			{Tag: "v1.0.1", SHA: "sha256:ccc", Timestamp: time.Unix(300, 0)},
+			{Tag: "v2.0.0", SHA: "sha256:ddd", Timestamp: time.Unix(400, 0)},
+			{Tag: "v3.0.0", SHA: "sha256:eee"},
+		},
+	}
+
+	v := newTestVersion(client, time.Hour, Options{})
+
+	timep := func(sec int64) *time.Time {
+		t := time.Unix(sec, 0)
+		return &t
+	}
+
+	tests := map[string]struct {
+		opts        *api.Options
+		expTag      string
+		expNotFound bool
+	}{
+		"no before time should select from all tags": {
+			opts:   new(api.Options),
+			expTag: "v3.0.0",
+		},
+		"before time should ignore tags without a timestamp": {
+			opts:   &api.Options{BeforeTime: timep(1000)},
+			expTag: "v2.0.0",
+		},
+		"before time should include tags at the time": {
+			opts:   &api.Options{BeforeTime: timep(200)},
+			expTag: "v1.1.0",
+		},
+		"before time should exclude tags after the time": {
+			opts:   &api.Options{BeforeTime: timep(199)},
+			expTag: "v1.0.0",
+		},
+		"before time with pins should select the latest pinned at the time": {
+			opts:   &api.Options{BeforeTime: timep(350), PinMajor: int64p(1), PinMinor: int64p(0)},
+			expTag: "v1.0.1",
+		},
+		"sha should select the latest image at the time": {
+			opts:   &api.Options{BeforeTime: timep(350), UseSHA: true},
+			expTag: "v1.0.1",
+		},
+		"before time before all tags should not be found": {
+			opts:        &api.Options{BeforeTime: timep(50)},
+			expNotFound: true,
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			tag, err := v.LatestTagFromImage(context.TODO(), "localhost:5000/version-checker", test.opts)
+			if versionerrors.IsNoVersionFound(err) != test.expNotFound {
+				t.Fatalf("unexpected not found error, exp=%t got=%v", test.expNotFound, err)
+			}
+			if test.expNotFound {
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if tag.Tag != test.expTag {
+				t.Errorf("unexpected tag, exp=%q got=%q", test.expTag, tag.Tag)
+			}
+		})
+	}
+}
+
+func TestZeroTimestamps(t *testing.T) {
+	tests := map[string]struct {
+		tags        []api.ImageTag
+		opts        *api.Options
+		expTag      string
+		expNotFound bool
+	}{
+		"zero timestamp first should not be selected over a timestamp": {
+			tags: []api.ImageTag{
+				{Tag: "v2.0.0", SHA: "sha256:aaa"},
+				{Tag: "v1.0.0", SHA: "sha256:bbb", Timestamp: time.Unix(100, 0)},
+			},
+			opts:   &api.Options{UseSHA: true},
+			expTag: "v1.0.0",
+		},
+		"zero timestamps should not be selected over the latest timestamp": {
+			tags: []api.ImageTag{
+				{Tag: "v1.0.0", SHA: "sha256:aaa", Timestamp: time.Unix(100, 0)},
+				{Tag: "v3.0.0", SHA: "sha256:bbb"},
+				{Tag: "v1.1.0", SHA: "sha256:ccc", Timestamp: time.Unix(200, 0)},
+				{Tag: "v2.0.0", SHA: "sha256:ddd"},
+			},
+			opts:   &api.Options{UseSHA: true},
+			expTag: "v1.1.0",
+		},
+		"only zero timestamps should select the first tag": {
+			tags: []api.ImageTag{
+				{Tag: "v1.0.0", SHA: "sha256:aaa"},
+				{Tag: "v2.0.0", SHA: "sha256:bbb"},
+			},
+			opts:   &api.Options{UseSHA: true},
+			expTag: "v1.0.0",
+		},
+		"require timestamp should select a tag with a timestamp": {
+			tags: []api.ImageTag{
+				{Tag: "v2.0.0", SHA: "sha256:aaa"},
+				{Tag: "v1.0.0", SHA: "sha256:bbb", Timestamp: time.Unix(100, 0)},
+			},
+			opts:   &api.Options{UseSHA: true, RequireTimestamp: true},
+			expTag: "v1.0.0",
+		},
+		"require timestamp with only zero timestamps should not be found": {
+			tags: []api.ImageTag{
+				{Tag: "v1.0.0", SHA: "sha256:aaa"},
+				{Tag: "v2.0.0", SHA: "sha256:bbb"},
+			},
+			opts:        &api.Options{UseSHA: true, RequireTimestamp: true},
+			expNotFound: true,
+		},
+		"require timestamp should have no effect selecting by version": {
+			tags: []api.ImageTag{
+				{Tag: "v1.0.0", SHA: "sha256:aaa"},
+				{Tag: "v2.0.0", SHA: "sha256:bbb"},
+			},
+			opts:   &api.Options{RequireTimestamp: true},
+			expTag: "v2.0.0",
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			v := newTestVersion(&fakeClient{tags: test.tags}, time.Hour, Options{})
+
+			tag, err := v.LatestTagFromImage(context.TODO(), "localhost:5000/version-checker", test.opts)
+			if versionerrors.IsNoVersionFound(err) != test.expNotFound {
+				t.Fatalf("unexpected not found error, exp=%t got=%v", test.expNotFound, err)
+			}
+			if test.expNotFound {
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if tag.Tag != test.expTag {
+				t.Errorf("unexpected tag, exp=%q got=%q", test.expTag, tag.Tag)
+			}
+		})
+	}
+}
+
+func TestNoVersionFoundErrors(t *testing.T) {
+	tests := map[string]struct {
+		tags          []api.ImageTag
+		opts          *api.Options
+		expNoSemver   bool
+		expNoMatching bool
+	}{
+		"no tags which are versions should be no semver tags": {
+			tags:        []api.ImageTag{{Tag: "latest"}, {Tag: "stable"}},
+			opts:        new(api.Options),
+			expNoSemver: true,
+		},
+		"versions filtered by the options should be no matching version": {
+			tags:          []api.ImageTag{{Tag: "latest"}, {Tag: "v1.0.0"}, {Tag: "v1.1.0"}},
+			opts:          &api.Options{PinMajor: int64p(2)},
+			expNoMatching: true,
+		},
+		"only pre-release versions should be no matching version": {
+			tags:          []api.ImageTag{{Tag: "v1.0.0-rc.1"}},
+			opts:          new(api.Options),
+			expNoMatching: true,
+		},
+		"no tags with a version extracted should be no semver tags": {
+			tags:        []api.ImageTag{{Tag: "v1.0.0"}, {Tag: "v1.1.0"}},
+			opts:        &api.Options{VersionExtractor: regexp.MustCompile(`^app-(?P<version>.*)$`)},
+			expNoSemver: true,
+		},
+		"matching version should be found": {
+			tags: []api.ImageTag{{Tag: "latest"}, {Tag: "v1.0.0"}},
+			opts: new(api.Options),
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			v := newTestVersion(&fakeClient{tags: test.tags}, time.Hour, Options{})
+
+			_, err := v.LatestTagFromImage(context.TODO(), "localhost:5000/version-checker", test.opts)
+			if versionerrors.IsNoSemverTags(err) != test.expNoSemver {
+				t.Errorf("unexpected no semver tags error, exp=%t got=%v", test.expNoSemver, err)
+			}
+			if versionerrors.IsNoMatchingVersion(err) != test.expNoMatching {
+				t.Errorf("unexpected no matching version error, exp=%t got=%v", test.expNoMatching, err)
+			}
+			if exp := test.expNoSemver || test.expNoMatching; versionerrors.IsNoVersionFound(err) != exp {
+				t.Errorf("unexpected not found error, exp=%t got=%v", exp, err)
+			}
+		})
+	}
+}
+
+func TestEmptyRepository(t *testing.T) {
+	registryErr := errors.New("connection reset")
+
+	tests := map[string]struct {
+		client   *fakeClient
+		expEmpty bool
+	}{
+		"empty tag list should be an empty repository": {
+			client:   &fakeClient{tags: []api.ImageTag{}},
+			expEmpty: true,
+		},
+		"failure listing tags should not be an empty repository": {
+			client: &fakeClient{err: registryErr},
+		},
+		"tags without a version should not be an empty repository": {
+			client: &fakeClient{tags: []api.ImageTag{{Tag: "latest"}}},
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			v := newTestVersion(test.client, time.Hour, Options{})
+
+			_, err := v.LatestTagFromImage(context.TODO(), "localhost:5000/version-checker", new(api.Options))
+			if err == nil {
+				t.Fatal("expected error, got none")
+			}
+			if versionerrors.IsEmptyRepository(err) != test.expEmpty {
+				t.Errorf("unexpected empty repository error, exp=%t got=%v", test.expEmpty, err)
+			}
+			if test.expEmpty && !versionerrors.IsNoVersionFound(err) {
+				t.Errorf("expected empty repository to be no version found, got=%v", err)
+			}
+		})
+	}
+}
+
+func TestRefreshImage(t *testing.T) {
+	client := &fakeClient{
+		tags: []api.ImageTag{
+			{Tag: "v0.1.0", SHA: "sha256:aaa", Timestamp: time.Unix(100, 0)},
+		},
+	}
+
+	v := newTestVersion(client, time.Hour, Options{})
+
+	tag, err := v.LatestTagFromImage(context.TODO(), "jetstack/version-checker", new(api.Options))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if tag.Tag != "v0.1.0" {
+		t.Fatalf("unexpected tag, exp=%q got=%q", "v0.1.0", tag.Tag)
+	}
+
+	// Refresh should bypass the cache and commit the fresh tags.
+	client.tags = append(client.tags, api.ImageTag{Tag: "v0.2.0", SHA: "sha256:bbb", Timestamp: time.Unix(200, 0)})
+	tags, err := v.RefreshImage(context.TODO(), "jetstack/version-checker")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(tags) != 2 {
+		t.Errorf("expected fresh tags from refresh, exp=2 got=%d tags", len(tags))
+	}
+
+	// A failed refresh should preserve the cached tags.
+	client.err = errors.New("registry unavailable")
+	if _, err := v.RefreshImage(context.TODO(), "jetstack/version-checker"); err == nil {
+		t.Fatal("expected error from failed refresh, got none")
+	}
+
+	tag, err = v.LatestTagFromImage(context.TODO(), "jetstack/version-checker", new(api.Options))
+	if err != nil {
+		t.Fatalf("expected cached tags to be preserved after failed refresh, got error: %s", err)
+	}
+	if tag.Tag != "v0.2.0" {
+		t.Errorf("unexpected tag, exp=%q got=%q", "v0.2.0", tag.Tag)
+	}
+	if client.calls != 3 {
+		t.Errorf("unexpected number of registry calls, exp=3 got=%d", client.calls)
+	}
+}
+
+// fakeClock is a cache.Clock which only advances when told to.
+type fakeClock struct {
+	now time.Time
+}
+
+func (f *fakeClock) Now() time.Time {
+	return f.now
+}
+
+func TestClockExpiry(t *testing.T) {
+	client := &fakeClient{
+		tags: []api.ImageTag{{Tag: "v0.1.0"}},
+	}
+
+	clock := &fakeClock{now: time.Unix(1600000000, 0)}
+	v := newTestVersion(client, time.Hour, Options{Clock: clock})
+
+	for _, advance := range []time.Duration{0, time.Minute * 30, time.Minute * 31} {
+		clock.now = clock.now.Add(advance)
+		if _, err := v.LatestTagFromImage(context.TODO(), "jetstack/version-checker", new(api.Options)); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// The first lookup, and the lookup after the cache timeout, should fetch.
+	if client.calls != 2 {
+		t.Errorf("unexpected number of registry calls, exp=2 got=%d", client.calls)
+	}
+}
+
+func TestServeStale(t *testing.T) {
+	unavailable := clienterrors.NewErrorDecode("quay.io", http.StatusServiceUnavailable, nil, errors.New("registry unavailable"))
+
+	tests := map[string]struct {
+		err          error
+		advance      time.Duration
+		cacheResults bool
+		expStale     bool
+		expErr       bool
+	}{
+		"registry failure should serve stale tags": {
+			err:      unavailable,
+			advance:  time.Minute * 2,
+			expStale: true,
+		},
+		"registry failure should serve stale cached result": {
+			err:          unavailable,
+			advance:      time.Minute * 2,
+			cacheResults: true,
+			expStale:     true,
+		},
+		"missing image should not serve stale": {
+			err:     clienterrors.NewErrorImageNotFound("quay.io", "jetstack/version-checker"),
+			advance: time.Minute * 2,
+			expErr:  true,
+		},
+		"rejected credentials should not serve stale": {
+			err:     &selfhostederrors.HTTPError{StatusCode: http.StatusUnauthorized},
+			advance: time.Minute * 2,
+			expErr:  true,
+		},
+		"registry failure past max stale should not serve stale": {
+			err:     unavailable,
+			advance: time.Hour * 2,
+			expErr:  true,
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			client := &fakeClient{tags: []api.ImageTag{{Tag: "v0.1.0"}}}
+			clock := &fakeClock{now: time.Unix(1600000000, 0)}
+			v := newTestVersion(client, time.Minute, Options{
+				Clock:        clock,
+				CacheResults: test.cacheResults,
+				ServeStale:   true,
+				MaxStale:     time.Hour,
+			})
+
+			tag, err := v.LatestTagFromImage(context.TODO(), "quay.io/jetstack/version-checker", new(api.Options))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if tag.Stale {
+				t.Error("expected fetched tag not to be stale")
+			}
+
+			clock.now = clock.now.Add(test.advance)
+			client.err = test.err
+
+			tag, err = v.LatestTagFromImage(context.TODO(), "quay.io/jetstack/version-checker", new(api.Options))
+			if (err != nil) != test.expErr {
+				t.Fatalf("unexpected error, exp=%t got=%v", test.expErr, err)
+			}
+			if test.expErr {
+				return
+			}
+			if tag.Tag != "v0.1.0" || tag.Stale != test.expStale {
+				t.Errorf("unexpected tag, exp=v0.1.0 (stale=%t) got=%s (stale=%t)", test.expStale, tag.Tag, tag.Stale)
+			}
+
+			// Once the registry recovers, tags should no longer be stale.
+			client.err = nil
+			tag, err = v.LatestTagFromImage(context.TODO(), "quay.io/jetstack/version-checker", new(api.Options))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if tag.Stale {
+				t.Error("expected tag not to be stale after recovery")
+			}
+		})
+	}
+}
+
+func TestImageCacheAgeFunc(t *testing.T) {
+	client := &fakeClient{
+		tags: []api.ImageTag{{Tag: "v0.1.0"}},
+	}
+
+	var (
+		registries []string
+		ages       []time.Duration
+	)
+
+	clock := &fakeClock{now: time.Unix(1600000000, 0)}
+	v := newTestVersion(client, time.Hour, Options{
+		Clock: clock,
+		ImageCacheAgeFunc: func(registry string, age time.Duration) {
+			registries = append(registries, registry)
+			ages = append(ages, age)
+		},
+	})
+
+	for _, advance := range []time.Duration{0, time.Minute * 10, time.Minute * 20} {
+		clock.now = clock.now.Add(advance)
+		if _, err := v.LatestTagFromImage(context.TODO(), "jetstack/version-checker", new(api.Options)); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// The first lookup is fetched, so is not observed.
+	expAges := []time.Duration{time.Minute * 10, time.Minute * 30}
+	if !reflect.DeepEqual(ages, expAges) {
+		t.Errorf("unexpected ages, exp=%v got=%v", expAges, ages)
+	}
+	if expRegistries := []string{"fake", "fake"}; !reflect.DeepEqual(registries, expRegistries) {
+		t.Errorf("unexpected registries, exp=%v got=%v", expRegistries, registries)
+	}
+}
+
+func TestLatestTagFromCurrent(t *testing.T) {
+	client := &fakeClient{
+		tags: []api.ImageTag{
+			{Tag: "v0.1.0", SHA: "sha256:aaa", Timestamp: time.Unix(100, 0)},
+			{Tag: "v0.2.0", SHA: "sha256:bbb", Timestamp: time.Unix(200, 0)},
+		},
+	}
+
+	v := newTestVersion(client, time.Hour, Options{})
+
+	tests := map[string]struct {
+		current      string
+		reject       bool
+		expTag       string
+		expDowngrade bool
+	}{
+		"newer latest should not be a downgrade": {
+			current: "v0.1.0",
+			reject:  true,
+			expTag:  "v0.2.0",
+		},
+		"same latest should not be a downgrade": {
+			current: "v0.2.0",
+			reject:  true,
+			expTag:  "v0.2.0",
+		},
+		"older latest should be a downgrade": {
+			current:      "v0.3.0",
+			reject:       true,
+			expDowngrade: true,
+		},
+		"older latest should be returned if not rejecting downgrades": {
+			current: "v0.3.0",
+			reject:  false,
+			expTag:  "v0.2.0",
+		},
+		"current which is not a version should not be a downgrade": {
+			current: "latest",
+			reject:  true,
+			expTag:  "v0.2.0",
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			tag, err := v.LatestTagFromCurrent(context.TODO(), "jetstack/version-checker", test.current, &api.Options{
+				RejectDowngrade: test.reject,
+			})
+			if versionerrors.IsDowngrade(err) != test.expDowngrade {
+				t.Fatalf("unexpected downgrade error, exp=%t got=%v", test.expDowngrade, err)
+			}
+			if test.expDowngrade {
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if tag.Tag != test.expTag {
+				t.Errorf("unexpected tag, exp=%q got=%q", test.expTag, tag.Tag)
+			}
+		})
+	}
+}
+
+func TestLatestTagFromTags(t *testing.T) {
+	stringp := func(s string) *string {
+		return &s
+	}
+	timep := func(sec int64) *time.Time {
+		t := time.Unix(sec, 0)
+		return &t
+	}
+
+	tags := []api.ImageTag{
+		{Tag: "v0.1.0", SHA: "sha256:aaa", Timestamp: time.Unix(100, 0)},
+		{Tag: "v0.2.0", SHA: "sha256:bbb", Timestamp: time.Unix(200, 0)},
+		{Tag: "v0.3.0-rc.1", SHA: "sha256:ccc", Timestamp: time.Unix(300, 0)},
+		{Tag: "v1.0.0", SHA: "sha256:ddd", Timestamp: time.Unix(400, 0)},
+		{Tag: "main", SHA: "sha256:eee", Timestamp: time.Unix(500, 0)},
+		{Tag: "stable", SHA: "sha256:bbb", Timestamp: time.Unix(200, 0)},
+	}
+
+	tests := map[string]struct {
+		opts        *api.Options
+		expTag      string
+		expNotFound bool
+	}{
+		"no options should return latest version without metadata": {
+			opts:   new(api.Options),
+			expTag: "v1.0.0",
+		},
+		"pinned major should return latest version of major": {
+			opts:   &api.Options{PinMajor: int64p(0)},
+			expTag: "v0.2.0",
+		},
+		"newer pre-release should return pre-release of major": {
+			opts:   &api.Options{PinMajor: int64p(0), UseNewerPreRelease: true},
+			expTag: "v0.3.0-rc.1",
+		},
+		"sha should return newest image": {
+			opts:   &api.Options{UseSHA: true},
+			expTag: "main",
+		},
+		"floating tag should return its image": {
+			opts:   &api.Options{FloatingTag: stringp("stable")},
+			expTag: "stable",
+		},
+		"before time should return latest version as of then": {
+			opts:   &api.Options{BeforeTime: timep(250)},
+			expTag: "v0.2.0",
+		},
+		"fallback should return newest image without a version": {
+			opts:   &api.Options{PinMajor: int64p(2), FallbackToSHA: true},
+			expTag: "main",
+		},
+		"no matching version should not be found": {
+			opts:        &api.Options{PinMajor: int64p(2)},
+			expNotFound: true,
+		},
+		"missing floating tag should not be found": {
+			opts:        &api.Options{FloatingTag: stringp("edge")},
+			expNotFound: true,
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			// No client, as no registry requests should be made.
+			v := newTestVersion(nil, time.Hour, Options{})
+
+			tag, err := v.LatestTagFromTags(test.opts, tags)
+			if versionerrors.IsNoVersionFound(err) != test.expNotFound {
+				t.Fatalf("unexpected not found error, exp=%t got=%v", test.expNotFound, err)
+			}
+			if test.expNotFound {
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if tag.Tag != test.expTag {
+				t.Errorf("unexpected tag, exp=%q got=%q", test.expTag, tag.Tag)
+			}
+
+			// The selection should be the same as that of the registry's tags.
+			imageTag, err := newTestVersion(&fakeClient{tags: tags}, time.Hour, Options{}).
+				LatestTagFromImage(context.TODO(), "jetstack/version-checker", test.opts)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(tag, imageTag) {
+				t.Errorf("unexpected tag compared to image, exp=%+v got=%+v", imageTag, tag)
+			}
+		})
+	}
+}
+
+func TestLatestTagFromTagsCopy(t *testing.T) {
+	tags := []api.ImageTag{{Tag: "v0.1.0"}, {Tag: "v0.2.0"}}
+
+	v := newTestVersion(nil, time.Hour, Options{})
+
+	tag, err := v.LatestTagFromTags(new(api.Options), tags)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tag.Tag = "mutated"
+	if tags[1].Tag != "v0.2.0" {
+		t.Errorf("expected given tags not to be modified, got %q", tags[1].Tag)
+	}
+
+	if _, err := v.LatestTagFromTags(&api.Options{RequireSignature: true}, tags); err == nil {
+		t.Error("expected error requiring signatures, got none")
+	}
+}
@@ -0,0 +1,125 @@
+package version
+
+import (
+	"context"
+	"regexp"
+	"testing"
+	"time"
+
+	"github.com/jetstack/version-checker/pkg/api"
+	"github.com/jetstack/version-checker/pkg/version/semver"
+)
+
+func TestVersioner(t *testing.T) {
+	tests := map[string]struct {
+		tags      []string
+		opts      api.Options
+		expTag    string
+		expStrict string
+	}{
+		"standard versions should select the same latest": {
+			tags:      []string{"v1.2.3", "v1.10.0", "v1.9.0"},
+			expTag:    "v1.10.0",
+			expStrict: "v1.10.0",
+		},
+		"standard pre-releases should select the same latest": {
+			tags:      []string{"1.2.3-rc.2", "1.2.3-rc.11", "1.2.2"},
+			opts:      api.Options{UseMetaData: true},
+			expTag:    "1.2.3-rc.11",
+			expStrict: "1.2.3-rc.11",
+		},
+		"strict should skip tags which are not semantic versions": {
+			tags:      []string{"1.2.3", "1.3", "latest"},
+			expTag:    "1.3",
+			expStrict: "1.2.3",
+		},
+		"strict should order a pre-release of a higher version above a stable version": {
+			tags:      []string{"1.2.3", "2.0.0-rc.1"},
+			opts:      api.Options{UseMetaData: true},
+			expTag:    "1.2.3",
+			expStrict: "2.0.0-rc.1",
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			var tags []api.ImageTag
+			for _, tag := range test.tags {
+				tags = append(tags, api.ImageTag{Tag: tag})
+			}
+			v := newTestVersion(&fakeClient{tags: tags}, time.Hour, Options{})
+
+			for versioner, expTag := range map[semver.Versioner]string{
+				nil:           test.expTag,
+				semver.Strict: test.expStrict,
+			} {
+				opts := test.opts
+				opts.Versioner = versioner
+
+				tag, err := v.LatestTagFromImage(context.TODO(), "localhost:5000/version-checker", &opts)
+				if err != nil {
+					t.Fatal(err)
+				}
+				if tag.Tag != expTag {
+					t.Errorf("unexpected tag of versioner %v, exp=%q got=%q", versioner, expTag, tag.Tag)
+				}
+			}
+		})
+	}
+}
+
+func TestCalculateHashIndexVersioner(t *testing.T) {
+	defaultHash, err := CalculateHashIndex("localhost:5000/version-checker", &api.Options{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	strictHash, err := CalculateHashIndex("localhost:5000/version-checker", &api.Options{Versioner: semver.Strict})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if defaultHash == strictHash {
+		t.Error("expected lookups of different versioners to have different hash indexes")
+	}
+
+	mustConstraints := func(s string) *semver.Constraints {
+		c, err := semver.ParseConstraints(s)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return c
+	}
+
+	tests := map[string]struct {
+		a, b *api.Options
+	}{
+		"constraint and max version should not run into one another": {
+			a: &api.Options{VersionConstraints: mustConstraints("1"), MaxVersion: semver.Parse("2")},
+			b: &api.Options{VersionConstraints: mustConstraints("12")},
+		},
+		"the same expression as different options should not collide": {
+			a: &api.Options{VersionConstraints: mustConstraints("1.2")},
+			b: &api.Options{MaxVersion: semver.Parse("1.2")},
+		},
+		"extractor and versioner should not run into one another": {
+			a: &api.Options{VersionExtractor: regexp.MustCompile(`^v(.*)`), Versioner: semver.Strict},
+			b: &api.Options{VersionExtractor: regexp.MustCompile(`^v(.*)` + semver.Strict.Name())},
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			a, err := CalculateHashIndex("localhost:5000/version-checker", test.a)
+			if err != nil {
+				t.Fatal(err)
+			}
+			b, err := CalculateHashIndex("localhost:5000/version-checker", test.b)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if a == b {
+				t.Errorf("expected lookups of different options to have different hash indexes, got=%s", a)
+			}
+		})
+	}
+}